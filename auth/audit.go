@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// auditLogFile is the audit log under the config directory.
+const auditLogFile = "audit.log"
+
+// Credential lifecycle events recorded in the audit log.
+const (
+	auditAuthStarted        = "auth_started"
+	auditClientRegistered   = "client_registered"
+	auditTokensSaved        = "tokens_saved"
+	auditTokensRefreshed    = "tokens_refreshed"
+	auditTokensRevoked      = "tokens_revoked"
+	auditTokensDeleted      = "tokens_deleted"
+	auditCredentialsCleared = "credentials_cleared"
+)
+
+var (
+	auditEnabled atomic.Bool
+	// auditMu serializes appends within the process; appends from other
+	// processes are kept whole by O_APPEND.
+	auditMu sync.Mutex
+)
+
+// auditEntry is one line of the audit log. It never holds tokens or client
+// secrets.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Server string    `json:"server,omitempty"`
+	Key    string    `json:"key"`
+	Detail string    `json:"detail,omitempty"`
+	User   string    `json:"user"`
+	Host   string    `json:"host"`
+	PID    int       `json:"pid"`
+}
+
+// SetAuditLog turns the audit log on or off for the process. While on,
+// authorization, registration, token and clearing events are appended to
+// AuditLogPath as JSON lines.
+func SetAuditLog(enabled bool) {
+	auditEnabled.Store(enabled)
+}
+
+// AuditLogPath returns the file the audit log is appended to.
+func AuditLogPath() string {
+	return filepath.Join(getConfigDir(), auditLogFile)
+}
+
+// auditUser is the name of the user running the process.
+var auditUser = sync.OnceValue(func() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+})
+
+// audit appends event for the server with the given key to the audit log,
+// if enabled. Failing to write it is logged but does not fail the operation
+// audited.
+func audit(serverKey, event, detail string) {
+	if !auditEnabled.Load() {
+		return
+	}
+
+	entry := auditEntry{
+		Time:   time.Now().UTC(),
+		Event:  event,
+		Key:    serverKey,
+		Detail: detail,
+		User:   auditUser(),
+		PID:    os.Getpid(),
+	}
+	entry.Host, _ = os.Hostname()
+	if data, err := filelock.ReadFile(filepath.Join(ServerDir(serverKey), serverURLFile)); err == nil {
+		entry.Server = strings.TrimSpace(string(data))
+	}
+
+	if err := appendAuditEntry(&entry); err != nil {
+		slog.Warn("failed to write audit log", "error", err)
+	}
+}
+
+func appendAuditEntry(entry *auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(AuditLogPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
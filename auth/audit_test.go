@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// readAuditLog returns the events recorded in the audit log.
+func readAuditLog(t *testing.T) []auditEntry {
+	t.Helper()
+	f, err := os.Open(AuditLogPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	SetAuditLog(true)
+	defer SetAuditLog(false)
+
+	const serverURL = "https://example.com/mcp"
+	key := ServerKey(serverURL)
+	c, err := NewCoordinator(key, 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.saveServerURL(serverURL)
+	if err := c.SaveTokens(&Tokens{AccessToken: "secret-access", RefreshToken: "secret-refresh"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if err := c.DeleteTokens(); err != nil {
+		t.Fatalf("DeleteTokens failed: %v", err)
+	}
+	if err := ClearServer(NewFileTokenStore(), key); err != nil {
+		t.Fatalf("ClearServer failed: %v", err)
+	}
+
+	entries := readAuditLog(t)
+	var events []string
+	for _, entry := range entries {
+		events = append(events, entry.Event)
+		if entry.Key != key || entry.Server != serverURL || entry.PID != os.Getpid() || entry.Time.IsZero() {
+			t.Errorf("incomplete audit entry: %+v", entry)
+		}
+	}
+	if got, want := strings.Join(events, ","), "tokens_saved,tokens_deleted,credentials_cleared"; got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+
+	data, err := os.ReadFile(AuditLogPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-") {
+		t.Errorf("audit log contains tokens:\n%s", data)
+	}
+	if info, err := os.Stat(AuditLogPath()); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	c, err := NewCoordinator("audit-disabled", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := c.SaveTokens(&Tokens{AccessToken: "access"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if entries := readAuditLog(t); len(entries) != 0 {
+		t.Errorf("expected no audit log by default, got %+v", entries)
+	}
+}
+
+func TestAuditLogRefresh(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	SetAuditLog(true)
+	defer SetAuditLog(false)
+
+	server := newRotatingRefreshServer(t, new(atomic.Int32))
+	c, err := NewCoordinator("audit-refresh", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.serverMetadata = &ServerMetadata{TokenEndpoint: server.URL + "/token"}
+	c.clientInfo = &ClientInfo{ClientID: "test-client"}
+	if err := c.SaveTokens(&Tokens{AccessToken: "at-0", RefreshToken: "rt-0"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if _, err := c.RefreshTokens(t.Context()); err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+
+	entries := readAuditLog(t)
+	if len(entries) != 2 || entries[1].Event != auditTokensRefreshed {
+		t.Errorf("expected tokens_saved then tokens_refreshed, got %+v", entries)
+	}
+}
@@ -2,28 +2,52 @@ package auth
 
 import (
 	"context"
+	"crypto"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/naotama2002/mcp-remote-go/internal/filelock"
 	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
 
+// defaultScopes are requested when no scopes are configured.
+var defaultScopes = []string{"mcp", "offline_access"}
+
 // Tokens holds OAuth tokens
 type Tokens struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	ExpiresIn    int    `json:"expires_in,omitempty"`
 	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresAt is the Unix time at which the access token expires, derived
+	// from ExpiresIn when the tokens are saved.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// Scope is the space-separated scope the tokens were granted. The
+	// requested scope is recorded when the server does not echo it back
+	// (RFC 6749 §5.1).
+	Scope string `json:"scope,omitempty"`
+	// IDToken is returned by OpenID Connect providers when the openid scope
+	// is requested, and is verified before the tokens are used.
+	IDToken string `json:"id_token,omitempty"`
+	// Version counts the saves of the tokens, so that processes sharing
+	// them can tell when another one has replaced them.
+	Version int64 `json:"version,omitempty"`
 }
 
 // ClientInfo holds the OAuth client registration information
@@ -34,6 +58,11 @@ type ClientInfo struct {
 	ClientSecretExpiresAt   int64    `json:"client_secret_expires_at,omitempty"`
 	RedirectURIs            []string `json:"redirect_uris"`
 	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	// RegistrationAccessToken and RegistrationClientURI give access to the
+	// registration for updates (RFC 7592).
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
 	// RegisteredIssuer is the authorization server issuer this client_id was
 	// registered with (RFC 8414 issuer). Used to invalidate stale cache when the
 	// discovered AS changes.
@@ -50,6 +79,17 @@ type ServerMetadata struct {
 	ScopesSupported        []string `json:"scopes_supported,omitempty"`
 	ResponseTypesSupported []string `json:"response_types_supported,omitempty"`
 	GrantTypesSupported    []string `json:"grant_types_supported,omitempty"`
+	// CodeChallengeMethodsSupported lists the PKCE methods the authorization
+	// server accepts (RFC 8414 §2).
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+	// DeviceAuthorizationEndpoint is advertised by servers supporting the
+	// device authorization grant (RFC 8628 §4).
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+	// TokenEndpointAuthMethodsSupported lists the client authentication
+	// methods the token endpoint accepts (RFC 8414 §2).
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	// RevocationEndpoint is where tokens are revoked (RFC 7009, RFC 8414 §2).
+	RevocationEndpoint string `json:"revocation_endpoint,omitempty"`
 }
 
 // Coordinator handles the OAuth flow
@@ -60,13 +100,163 @@ type Coordinator struct {
 	clientInfo     *ClientInfo
 	serverMetadata *ServerMetadata
 	resource       string // RFC 8707 canonical resource URI, reused across the flow
+	resourceURI    string // configured resource indicator; derived from the server URL when empty
 	codeVerifier   string
-	authMutex      sync.Mutex
-	callbackChan   chan string
+	state          string // OAuth state of the pending authorization request
+	store          TokenStore
+	scopes         []string
+	flow           string
+	transport      http.RoundTripper
+	// httpConfig, when set, replaces the default timeout and retries of
+	// requests to the authorization server.
+	httpConfig   *httpclient.Config
+	authMutex    sync.Mutex
+	refreshMu    sync.Mutex
+	callbackChan chan callbackResult
+	// redirectURI is the registered redirect URI used for the current flow.
+	redirectURI string
+	// nonce is sent with OpenID Connect authorization requests and checked
+	// in the ID token.
+	nonce string
+	// callbackOrigins are the Origin headers the callback server accepts.
+	callbackOrigins []string
+	// callbackHost, when set, replaces the loopback address in the
+	// redirect URI, and callbackBind the address the callback server
+	// listens on.
+	callbackHost string
+	callbackBind string
+	// callbackTLS serves the callback over HTTPS.
+	callbackTLS bool
+	// assumePKCE proceeds with S256 when the server does not advertise its
+	// PKCE methods.
+	assumePKCE bool
+	// authTimeout is how long WaitForAuthCode waits for the callback.
+	authTimeout time.Duration
+	// successPage and errorPage replace the default callback pages.
+	successPage *template.Template
+	errorPage   *template.Template
+	// tokenAuthMethod, when set, overrides the registered token endpoint
+	// auth method; assertionKey signs private_key_jwt client assertions.
+	tokenAuthMethod string
+	assertionKey    crypto.Signer
+	assertionKeyID  string
+	// provider replaces discovery for servers that deviate from RFC 8414;
+	// staticClient is a client registered with it beforehand.
+	provider     *Provider
+	staticClient *ClientInfo
+	// authParams are added to authorization and token requests.
+	authParams map[string]string
+	// tokenVersion is the version of the tokens last loaded or saved.
+	tokenVersion atomic.Int64
+}
+
+// ErrNoAuthorizationPending is returned by SubmitAuthorizationResponse when
+// no browser flow is waiting for an authorization response.
+var ErrNoAuthorizationPending = errors.New("no authorization flow in progress")
+
+// callbackResult is what the callback server passes to WaitForAuthCode.
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// CoordinatorOption configures a Coordinator.
+type CoordinatorOption func(*Coordinator)
+
+// WithTokenStore sets the backend used to persist tokens and client
+// registrations. The default is file storage under the config directory.
+func WithTokenStore(store TokenStore) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.store = store
+	}
+}
+
+// WithScopes overrides the OAuth scopes requested during registration and
+// authorization. The default is "mcp offline_access".
+func WithScopes(scopes []string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.scopes = scopes
+	}
+}
+
+// WithResource sets the RFC 8707 resource indicator sent in authorization
+// and token requests, for servers whose canonical URI differs from the URL
+// the proxy connects to. By default it is derived from the server URL.
+func WithResource(resource string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.resourceURI = resource
+		c.resource = resource
+	}
+}
+
+// WithAuthParams adds params to the authorization request and to token
+// requests, for authorization servers that need extra parameters such as
+// audience or prompt. They replace parameters of the same name.
+func WithAuthParams(params map[string]string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.authParams = params
+	}
+}
+
+// WithHTTPTransport sets the transport used for discovery, registration and
+// token requests, e.g. one that goes through a proxy.
+func WithHTTPTransport(transport http.RoundTripper) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.transport = transport
+	}
+}
+
+// WithHTTPTimeout sets how long each discovery, registration and token
+// request may take. A timeout that is not positive keeps the default.
+func WithHTTPTimeout(timeout time.Duration) CoordinatorOption {
+	return func(c *Coordinator) {
+		if timeout > 0 {
+			c.httpSettings().Timeout = timeout
+		}
+	}
+}
+
+// WithHTTPRetries sets how many times a discovery, registration or token
+// request is retried and the delay before each retry. Only idempotent
+// requests are retried after network errors and 5xx statuses, so an
+// authorization code or refresh token is never sent twice. A negative
+// count or a delay that is not positive keeps the default.
+func WithHTTPRetries(retries int, delay time.Duration) CoordinatorOption {
+	return func(c *Coordinator) {
+		if retries >= 0 {
+			c.httpSettings().MaxRetries = retries
+		}
+		if delay > 0 {
+			c.httpSettings().RetryDelay = delay
+		}
+	}
+}
+
+// httpSettings returns the settings the HTTP options adjust, starting
+// from the defaults. It is only called while options are applied.
+func (c *Coordinator) httpSettings() *httpclient.Config {
+	if c.httpConfig == nil {
+		c.httpConfig = httpclient.DefaultConfig()
+	}
+	return c.httpConfig
+}
+
+// DefaultAuthTimeout is how long WaitForAuthCode waits for the user to
+// complete authorization in the browser by default.
+const DefaultAuthTimeout = 5 * time.Minute
+
+// WithAuthTimeout sets how long WaitForAuthCode waits for the authorization
+// response. A timeout that is not positive keeps DefaultAuthTimeout.
+func WithAuthTimeout(timeout time.Duration) CoordinatorOption {
+	return func(c *Coordinator) {
+		if timeout > 0 {
+			c.authTimeout = timeout
+		}
+	}
 }
 
 // NewCoordinator creates a new authentication coordinator
-func NewCoordinator(serverURLHash string, callbackPort int) (*Coordinator, error) {
+func NewCoordinator(serverURLHash string, callbackPort int, opts ...CoordinatorOption) (*Coordinator, error) {
 	// Ensure config directory exists
 	configDir := getConfigDir()
 	serverDir := filepath.Join(configDir, serverURLHash)
@@ -75,11 +265,41 @@ func NewCoordinator(serverURLHash string, callbackPort int) (*Coordinator, error
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	return &Coordinator{
+	c := &Coordinator{
 		serverURLHash: serverURLHash,
 		callbackPort:  callbackPort,
-		callbackChan:  make(chan string),
-	}, nil
+		store:         NewFileTokenStore(),
+		callbackChan:  make(chan callbackResult, 1),
+		authTimeout:   DefaultAuthTimeout,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c, nil
+}
+
+// scope returns the space-separated scope parameter for OAuth requests.
+func (c *Coordinator) scope() string {
+	switch {
+	case len(c.scopes) > 0:
+		return strings.Join(c.scopes, " ")
+	case c.provider != nil && len(c.provider.Scopes) > 0:
+		return strings.Join(c.provider.Scopes, " ")
+	}
+	return strings.Join(defaultScopes, " ")
+}
+
+// ScopeMatches reports whether tokens were granted exactly the configured
+// scopes, ignoring order. Tokens saved before scopes were recorded match.
+func (c *Coordinator) ScopeMatches(tokens *Tokens) bool {
+	if tokens.Scope == "" {
+		return true
+	}
+	granted := strings.Fields(tokens.Scope)
+	requested := strings.Fields(c.scope())
+	slices.Sort(granted)
+	slices.Sort(requested)
+	return slices.Equal(slices.Compact(granted), slices.Compact(requested))
 }
 
 type InitOption func(*initConfig)
@@ -97,8 +317,9 @@ func WithResourceMetadataURL(url string) InitOption {
 	}
 }
 
-// InitializeAuth starts the OAuth flow
-func (c *Coordinator) InitializeAuth(serverURL string, opts ...InitOption) (string, error) {
+// InitializeAuth starts the OAuth flow. ctx bounds the discovery of the
+// authorization server.
+func (c *Coordinator) InitializeAuth(ctx context.Context, serverURL string, opts ...InitOption) (string, error) {
 	c.authMutex.Lock()
 	defer c.authMutex.Unlock()
 
@@ -107,17 +328,20 @@ func (c *Coordinator) InitializeAuth(serverURL string, opts ...InitOption) (stri
 		o(cfg)
 	}
 
-	resource, err := CanonicalResourceURI(serverURL)
+	resource, err := c.resourceFor(serverURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to derive canonical resource URI: %w", err)
 	}
 	c.resource = resource
 
-	metadata, err := c.discoverServerMetadata(serverURL, cfg.resourceMetadataURL)
+	metadata, err := c.discoverServerMetadata(ctx, serverURL, cfg.resourceMetadataURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to discover server metadata: %w", err)
 	}
 	c.serverMetadata = metadata
+	if err := c.checkPKCE(metadata); err != nil {
+		return "", err
+	}
 
 	// 2. Start callback server if not already running to find an available port
 	if c.callbackServer == nil {
@@ -127,7 +351,7 @@ func (c *Coordinator) InitializeAuth(serverURL string, opts ...InitOption) (stri
 	}
 
 	// 3. Register client if needed (uses the potentially updated port)
-	clientInfo, err := c.loadOrRegisterClient()
+	clientInfo, err := c.loadOrRegisterClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("client registration failed: %w", err)
 	}
@@ -139,55 +363,85 @@ func (c *Coordinator) InitializeAuth(serverURL string, opts ...InitOption) (stri
 		return "", fmt.Errorf("failed to build authorization URL: %w", err)
 	}
 
+	audit(c.serverURLHash, auditAuthStarted, "browser")
 	return authURL, nil
 }
 
-// WaitForAuthCode waits for the authorization code from the callback
-func (c *Coordinator) WaitForAuthCode() (string, error) {
-	// Wait for the code from the callback
+// WaitForAuthCode waits for the authorization code from the callback, for
+// at most the time set with WithAuthTimeout. When it gives up, because of
+// the timeout or because ctx is done, the flow is abandoned: a late callback
+// is refused and the callback server stopped until the next InitializeAuth.
+func (c *Coordinator) WaitForAuthCode(ctx context.Context) (string, error) {
+	timer := time.NewTimer(c.authTimeout)
+	defer timer.Stop()
+
 	select {
-	case code := <-c.callbackChan:
-		return code, nil
-	case <-time.After(5 * time.Minute):
-		return "", errors.New("timeout waiting for authorization code")
+	case result := <-c.callbackChan:
+		return result.code, result.err
+	case <-timer.C:
+		c.Close()
+		return "", fmt.Errorf("timeout waiting for authorization code after %v", c.authTimeout)
+	case <-ctx.Done():
+		c.Close()
+		return "", ctx.Err()
 	}
 }
 
-// ExchangeCode exchanges the authorization code for tokens
-func (c *Coordinator) ExchangeCode(code string) (*Tokens, error) {
+// Close abandons a pending browser flow and stops the callback server. A
+// later InitializeAuth starts it again.
+func (c *Coordinator) Close() {
+	c.authMutex.Lock()
+	c.state = ""
+	server := c.callbackServer
+	c.callbackServer = nil
+	c.authMutex.Unlock()
+
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("failed to stop callback server", "error", err)
+		return
+	}
+	slog.Debug("callback server stopped")
+}
+
+// ExchangeCode exchanges the authorization code for tokens. The token
+// request is abandoned once ctx is done.
+func (c *Coordinator) ExchangeCode(ctx context.Context, code string) (*Tokens, error) {
 	if c.serverMetadata == nil || c.clientInfo == nil {
 		return nil, errors.New("auth not initialized")
 	}
 
 	// Prepare form data for token request
-	formData := map[string]string{
-		"grant_type":   "authorization_code",
-		"code":         code,
-		"redirect_uri": fmt.Sprintf("http://localhost:%d/callback", c.callbackPort),
-		"client_id":    c.clientInfo.ClientID,
+	formData := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.callbackURI()},
+		"client_id":    {c.clientInfo.ClientID},
 	}
 
-	// RFC 8707 resource indicator (required by the MCP authorization spec).
-	if c.resource != "" {
-		formData["resource"] = c.resource
-	}
+	c.setResource(formData, c.resource)
+	c.setAuthParams(formData)
 
 	// Add PKCE code_verifier
 	if c.codeVerifier != "" {
-		formData["code_verifier"] = c.codeVerifier
+		formData.Set("code_verifier", c.codeVerifier)
 	}
 
-	// Add client secret if available
-	if c.clientInfo.ClientSecret != "" {
-		formData["client_secret"] = c.clientInfo.ClientSecret
+	headers, err := c.authenticateClient(c.serverMetadata, c.clientInfo, formData)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create HTTP client and send request
-	client := httpclient.New(nil)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	resp, err := client.PostForm(ctx, c.serverMetadata.TokenEndpoint, formData, nil)
+	resp, err := client.PostForm(ctx, c.serverMetadata.TokenEndpoint, formData, headers)
 	if err != nil {
 		return nil, fmt.Errorf("token exchange failed: %w", err)
 	}
@@ -199,70 +453,81 @@ func (c *Coordinator) ExchangeCode(code string) (*Tokens, error) {
 		return nil, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
+	if err := c.verifyIDToken(ctx, c.serverMetadata, c.clientInfo.ClientID, &tokens, c.nonce); err != nil {
+		return nil, err
+	}
+
 	return &tokens, nil
 }
 
-// LoadTokens loads tokens from disk with file locking
+// LoadTokens loads tokens from the token store
 func (c *Coordinator) LoadTokens() (*Tokens, error) {
-	tokensPath := c.getTokensPath()
-	lock := filelock.New(tokensPath)
-
-	var tokens Tokens
-	err := lock.WithLock(5*time.Second, func() error {
-		// Read file
-		data, err := os.ReadFile(tokensPath)
-		if err != nil {
-			return err
-		}
-
-		// Parse tokens
-		if err := json.Unmarshal(data, &tokens); err != nil {
-			return fmt.Errorf("failed to parse tokens file: %w", err)
-		}
-
-		return nil
-	})
-
+	tokens, err := c.readTokens()
 	if err != nil {
 		return nil, err
 	}
+	c.tokenVersion.Store(tokens.Version)
+	return tokens, nil
+}
 
-	return &tokens, nil
+// TokenFile returns the file the tokens are stored in, or "" when the token
+// store keeps them elsewhere, such as in the OS keychain.
+func (c *Coordinator) TokenFile() string {
+	store := c.store
+	if codec, ok := store.(*codecTokenStore); ok {
+		store = codec.store
+	}
+	if _, ok := store.(*FileTokenStore); !ok {
+		return ""
+	}
+	return filepath.Join(ServerDir(c.serverURLHash), tokensFile)
 }
 
-// SaveTokens saves tokens to disk with file locking
+// SaveTokens saves tokens to the token store, replacing the stored ones.
+// ExpiresAt is filled in from ExpiresIn, and Scope from the requested
+// scopes, when not already set; Version is set to the next version.
 func (c *Coordinator) SaveTokens(tokens *Tokens) error {
-	tokensPath := c.getTokensPath()
-	lock := filelock.New(tokensPath)
-
-	return lock.WithLock(5*time.Second, func() error {
-		// Marshal tokens
-		data, err := json.MarshalIndent(tokens, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal tokens: %w", err)
+	return c.withTokenLock(func() error {
+		var version int64
+		if stored, err := c.readTokens(); err == nil {
+			version = stored.Version
 		}
-
-		// Write to file
-		if err := os.WriteFile(tokensPath, data, 0600); err != nil {
-			return fmt.Errorf("failed to write tokens file: %w", err)
+		if err := c.writeTokens(tokens, version); err != nil {
+			return err
 		}
-
+		audit(c.serverURLHash, auditTokensSaved, "")
 		return nil
 	})
 }
 
-func (c *Coordinator) discoverServerMetadata(serverURL, resourceMetadataURL string) (*ServerMetadata, error) {
+func (c *Coordinator) discoverServerMetadata(ctx context.Context, serverURL, resourceMetadataURL string) (*ServerMetadata, error) {
+	c.saveServerURL(serverURL)
+
 	// Skip cache when the caller supplied an explicit PRM URL: the cached
-	// entry may have come from a different (less authoritative) path.
-	if resourceMetadataURL == "" {
+	// entry may have come from a different (less authoritative) path. A
+	// provider profile is authoritative too.
+	if resourceMetadataURL == "" && c.provider == nil {
 		if metadata, err := c.loadServerMetadata(); err == nil {
 			return metadata, nil
 		}
 	}
+	return c.fetchServerMetadata(ctx, serverURL, resourceMetadataURL)
+}
+
+// fetchServerMetadata runs discovery without consulting the cache and saves
+// the result.
+func (c *Coordinator) fetchServerMetadata(ctx context.Context, serverURL, resourceMetadataURL string) (*ServerMetadata, error) {
+	if c.provider != nil {
+		metadata := c.providerMetadata()
+		if err := c.saveServerMetadata(metadata); err != nil {
+			slog.Warn("failed to save server metadata", "error", err)
+		}
+		return metadata, nil
+	}
 
 	// Use the discovery service to find metadata
-	discoveryService := NewMetadataDiscoveryService()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	discoveryService := &MetadataDiscoveryService{client: *c.httpClient()}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	metadata, err := discoveryService.Discover(ctx, serverURL, WithProtectedResourceMetadataURL(resourceMetadataURL))
@@ -272,68 +537,60 @@ func (c *Coordinator) discoverServerMetadata(serverURL, resourceMetadataURL stri
 
 	// Save discovered metadata
 	if err := c.saveServerMetadata(metadata); err != nil {
-		log.Printf("Warning: failed to save server metadata: %v", err)
+		slog.Warn("failed to save server metadata", "error", err)
 	}
 
 	return metadata, nil
 }
 
-// loadOrRegisterClient returns cached ClientInfo when it still matches the
+// loadOrRegisterClient returns the client set with WithClient, or else
+// cached ClientInfo when it still matches the
 // currently-discovered authorization server (RFC 8414 issuer); otherwise it
 // performs RFC 7591 dynamic client registration. Issuer comparison covers the
 // WWW-Authenticate-driven discovery case where the AS may have changed without
 // changing the resource server URL, while still letting AS-with-no-DCR
-// configurations succeed via the cached static client_id.
-func (c *Coordinator) loadOrRegisterClient() (*ClientInfo, error) {
+// configurations succeed via the cached static client_id. A client not
+// registered for the port the callback server is bound to is updated through
+// its RFC 7592 configuration endpoint, or registered again, when the server
+// allows it.
+func (c *Coordinator) loadOrRegisterClient(ctx context.Context) (*ClientInfo, error) {
+	if c.staticClient != nil {
+		// Pre-registered clients are expected to allow loopback redirects
+		// on any port (RFC 8252 §7.3).
+		c.redirectURI = c.localRedirectURI()
+		return c.staticClient, nil
+	}
+
 	clientInfo, err := c.loadClientInfo()
-	if err == nil && c.clientInfoMatchesServer(clientInfo) {
-		return clientInfo, nil
+	if err == nil && c.clientInfoMatchesServer(clientInfo) && c.clientInfoSupportsFlow(clientInfo) {
+		if redirectURI, ok := c.registeredRedirectURI(clientInfo); ok {
+			c.redirectURI = redirectURI
+			return clientInfo, nil
+		}
+		if clientInfo.RegistrationClientURI != "" && clientInfo.RegistrationAccessToken != "" {
+			updated, err := c.updateClient(ctx, clientInfo, c.localRedirectURI())
+			if err == nil {
+				return updated, nil
+			}
+			slog.Info("client registration update failed, registering a new client", "error", err)
+		}
+		if c.serverMetadata.RegistrationEndpoint == "" {
+			// Loopback redirects must be accepted on any port (RFC 8252
+			// §7.3); nothing better can be done without registration.
+			c.redirectURI = c.localRedirectURI()
+			return clientInfo, nil
+		}
 	}
 
 	// Check if registration endpoint is available
 	if c.serverMetadata.RegistrationEndpoint == "" {
+		if c.provider != nil {
+			return nil, fmt.Errorf("%s does not support dynamic registration; a client registered beforehand is required", c.provider.Name)
+		}
 		return nil, errors.New("server does not support dynamic registration")
 	}
 
-	// Register a new client
-	redirectURI := fmt.Sprintf("http://localhost:%d/callback", c.callbackPort)
-
-	// Prepare registration request
-	regReq := map[string]interface{}{
-		"client_name":                "MCP Remote Go Client",
-		"redirect_uris":              []string{redirectURI},
-		"token_endpoint_auth_method": "none",
-		"scope":                      "mcp offline_access",
-		"grant_types":                []string{"authorization_code"},
-	}
-
-	// Send registration request using httpclient
-	client := httpclient.New(nil)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	resp, err := client.Post(ctx, c.serverMetadata.RegistrationEndpoint, regReq, nil)
-	if err != nil {
-		return nil, fmt.Errorf("client registration failed: %w", err)
-	}
-	defer func() { _ = resp.SafeClose() }()
-
-	// Parse response
-	var clientInfoResp ClientInfo
-	if err := resp.JSON(&clientInfoResp); err != nil {
-		return nil, fmt.Errorf("failed to parse client registration response: %w", err)
-	}
-
-	if c.serverMetadata != nil {
-		clientInfoResp.RegisteredIssuer = c.serverMetadata.Issuer
-	}
-
-	// Save client info
-	if err := c.saveClientInfo(&clientInfoResp); err != nil {
-		return nil, fmt.Errorf("failed to save client info: %w", err)
-	}
-
-	return &clientInfoResp, nil
+	return c.registerClient(ctx, c.localRedirectURI())
 }
 
 func (c *Coordinator) clientInfoMatchesServer(clientInfo *ClientInfo) bool {
@@ -353,35 +610,15 @@ func (c *Coordinator) clientInfoMatchesServer(clientInfo *ClientInfo) bool {
 func (c *Coordinator) startCallbackServer() error {
 	mux := http.NewServeMux()
 
-	// Callback handler
-	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			http.Error(w, "Authorization code not found", http.StatusBadRequest)
-			return
-		}
+	mux.Handle("/callback", c.guardCallback(http.HandlerFunc(c.handleCallback)))
 
-		// Send the code to the waiting goroutine
-		select {
-		case c.callbackChan <- code:
-			// Send success response
-			w.Header().Set("Content-Type", "text/html")
-			if _, err := w.Write([]byte(`
-				<html>
-				<head><title>Authorization Successful</title></head>
-				<body>
-					<h1>Authorization Successful</h1>
-					<p>You can close this window and return to the application.</p>
-					<script>window.close();</script>
-				</body>
-				</html>
-			`)); err != nil {
-				log.Printf("Warning: failed to write response: %v", err)
-			}
-		default:
-			http.Error(w, "Authorization flow not in progress", http.StatusBadRequest)
+	var tlsConfig *tls.Config
+	if c.callbackTLS {
+		var err error
+		if tlsConfig, err = c.callbackTLSConfig(); err != nil {
+			return err
 		}
-	})
+	}
 
 	// Find an available port and start the server
 	var listener net.Listener
@@ -389,11 +626,12 @@ func (c *Coordinator) startCallbackServer() error {
 	basePort := c.callbackPort
 	for i := 0; i < 100; i++ { // Try up to 100 ports from the base port
 		port := basePort + i
-		addr := fmt.Sprintf("127.0.0.1:%d", port)
+		addr := net.JoinHostPort(c.callbackBindAddress(), strconv.Itoa(port))
 		listener, err = net.Listen("tcp", addr)
 		if err == nil {
-			c.callbackPort = port // Update to the successfully bound port
-			log.Printf("Callback server listening on %s", addr)
+			// Update to the bound port, which port 0 leaves to the system
+			c.callbackPort = listener.Addr().(*net.TCPAddr).Port
+			slog.Info("callback server listening", "addr", listener.Addr().String())
 			break
 		}
 	}
@@ -401,28 +639,136 @@ func (c *Coordinator) startCallbackServer() error {
 	if err != nil {
 		return fmt.Errorf("could not find an available port for callback server after 100 attempts: %w", err)
 	}
+	if ip := net.ParseIP(c.callbackBindAddress()); ip != nil && !ip.IsLoopback() {
+		slog.Warn("callback server accepts connections from other machines", "bind", c.callbackBindAddress())
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 
 	// Create server
-	c.callbackServer = &http.Server{
-		Handler: mux,
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
+	c.callbackServer = server
 
 	// Start the server in a goroutine
 	go func() {
-		if err := c.callbackServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Printf("Callback server error: %v", err)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("callback server error", "error", err)
 		}
 	}()
 
 	return nil
 }
 
+// handleCallback receives the authorization response (RFC 6749 §4.1.2) and
+// passes the code, or the reason the flow failed, to WaitForAuthCode.
+func (c *Coordinator) handleCallback(w http.ResponseWriter, r *http.Request) {
+	result, ok := c.authorizationResult(r.URL.Query(), true)
+	if !ok {
+		c.writeCallbackError(w, ErrNoAuthorizationPending)
+		return
+	}
+
+	// Hand the result to the waiting goroutine
+	select {
+	case c.callbackChan <- result:
+	default:
+		c.writeCallbackError(w, ErrNoAuthorizationPending)
+		return
+	}
+
+	if result.err != nil {
+		c.writeCallbackError(w, result.err)
+		return
+	}
+	c.writeCallbackSuccess(w)
+}
+
+// authorizationResult turns an authorization response into the result for
+// WaitForAuthCode. With checkState, a response whose state does not match
+// the pending request is rejected, so a code injected by another site
+// cannot complete the flow. It reports false when no flow is in progress.
+func (c *Coordinator) authorizationResult(query url.Values, checkState bool) (callbackResult, bool) {
+	c.authMutex.Lock()
+	expected := c.state
+	valid := !checkState || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(expected)) == 1
+	// A state is good for one response only; a mismatch fails the flow.
+	c.state = ""
+	c.authMutex.Unlock()
+
+	var result callbackResult
+	switch {
+	case expected == "":
+		return result, false
+	case !valid:
+		slog.Warn("rejected authorization callback with mismatched state")
+		result.err = errors.New("authorization callback state mismatch")
+	case query.Get("error") != "":
+		result.err = &AuthorizationError{
+			Code:        query.Get("error"),
+			Description: query.Get("error_description"),
+			URI:         query.Get("error_uri"),
+		}
+	case query.Get("code") == "":
+		result.err = errors.New("authorization code not found in callback")
+	default:
+		result.code = query.Get("code")
+	}
+	return result, true
+}
+
+// SubmitAuthorizationResponse completes a pending browser flow with input
+// pasted by the user, for when the browser cannot reach the callback
+// server. input is either the full redirect URL, whose state is checked
+// like a callback's, or the bare authorization code.
+func (c *Coordinator) SubmitAuthorizationResponse(input string) error {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return errors.New("empty authorization response")
+	}
+
+	query := url.Values{"code": {input}}
+	checkState := false
+	if strings.Contains(input, "code=") || strings.Contains(input, "error=") {
+		u, err := url.Parse(input)
+		if err != nil {
+			return fmt.Errorf("invalid redirect URL: %w", err)
+		}
+		query = u.Query()
+		if u.RawQuery == "" {
+			// A query string pasted without the URL in front of it.
+			if query, err = url.ParseQuery(strings.TrimPrefix(input, "?")); err != nil {
+				return fmt.Errorf("invalid redirect URL: %w", err)
+			}
+		}
+		checkState = true
+	}
+
+	result, ok := c.authorizationResult(query, checkState)
+	if !ok {
+		return ErrNoAuthorizationPending
+	}
+	select {
+	case c.callbackChan <- result:
+	default:
+		return ErrNoAuthorizationPending
+	}
+	return result.err
+}
+
 // buildAuthorizationURL builds the authorization URL with PKCE (S256)
 func (c *Coordinator) buildAuthorizationURL() (string, error) {
 	if c.serverMetadata == nil || c.clientInfo == nil {
 		return "", errors.New("auth not initialized")
 	}
 
+	if err := c.checkPKCE(c.serverMetadata); err != nil {
+		return "", err
+	}
+
 	// Generate PKCE code verifier
 	verifier, err := GenerateCodeVerifier()
 	if err != nil {
@@ -430,19 +776,43 @@ func (c *Coordinator) buildAuthorizationURL() (string, error) {
 	}
 	c.codeVerifier = verifier
 
+	state, err := GenerateState()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	c.state = state
+	// Drop a response left over from an abandoned flow.
+	select {
+	case <-c.callbackChan:
+	default:
+	}
+
 	// Build params
 	params := url.Values{}
 	params.Set("client_id", c.clientInfo.ClientID)
-	params.Set("redirect_uri", fmt.Sprintf("http://localhost:%d/callback", c.callbackPort))
+	params.Set("redirect_uri", c.callbackURI())
 	params.Set("response_type", "code")
-	params.Set("scope", "mcp offline_access")
+	params.Set("scope", c.scope())
 	params.Set("code_challenge", ComputeCodeChallenge(verifier))
 	params.Set("code_challenge_method", "S256")
+	params.Set("state", state)
+	c.nonce = ""
+	if c.requestsOpenID() {
+		// Binds the ID token to this request (OpenID Connect Core §3.1.2.1).
+		if c.nonce, err = GenerateState(); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		params.Set("nonce", c.nonce)
+	}
 
 	// RFC 8707 resource indicator (required by the MCP authorization spec).
-	if c.resource != "" {
-		params.Set("resource", c.resource)
+	c.setResource(params, c.resource)
+	if c.provider != nil {
+		for k, v := range c.provider.AuthorizationParams {
+			params.Set(k, v)
+		}
 	}
+	c.setAuthParams(params)
 
 	// Combine URL
 	baseURL, err := url.Parse(c.serverMetadata.AuthorizationEndpoint)
@@ -459,7 +829,7 @@ func (c *Coordinator) loadServerMetadata() (*ServerMetadata, error) {
 	metadataPath := c.getMetadataPath()
 
 	// Read file
-	data, err := os.ReadFile(metadataPath)
+	data, err := filelock.ReadFile(metadataPath)
 	if err != nil {
 		return nil, err
 	}
@@ -484,24 +854,20 @@ func (c *Coordinator) saveServerMetadata(metadata *ServerMetadata) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(metadataPath, data, 0600); err != nil {
+	if err := filelock.WriteFile(metadataPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write metadata file: %w", err)
 	}
 
 	return nil
 }
 
-// loadClientInfo loads client info from disk
+// loadClientInfo loads client info from the token store
 func (c *Coordinator) loadClientInfo() (*ClientInfo, error) {
-	clientInfoPath := c.getClientInfoPath()
-
-	// Read file
-	data, err := os.ReadFile(clientInfoPath)
+	data, err := c.store.Load(c.serverURLHash, clientInfoFile)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse client info
 	var clientInfo ClientInfo
 	if err := json.Unmarshal(data, &clientInfo); err != nil {
 		return nil, fmt.Errorf("failed to parse client info file: %w", err)
@@ -510,39 +876,14 @@ func (c *Coordinator) loadClientInfo() (*ClientInfo, error) {
 	return &clientInfo, nil
 }
 
-// saveClientInfo saves client info to disk
+// saveClientInfo saves client info to the token store
 func (c *Coordinator) saveClientInfo(clientInfo *ClientInfo) error {
-	clientInfoPath := c.getClientInfoPath()
-
-	// Marshal client info
 	data, err := json.MarshalIndent(clientInfo, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal client info: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(clientInfoPath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write client info file: %w", err)
-	}
-
-	return nil
-}
-
-// getConfigDir gets the base directory for configuration files
-func getConfigDir() string {
-	// Check for custom config dir from env var
-	if dir := os.Getenv("MCP_REMOTE_CONFIG_DIR"); dir != "" {
-		return dir
-	}
-
-	// Default to ~/.mcp-remote-go-auth
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		// Fallback to current directory if home dir can't be determined
-		return ".mcp-remote-go-auth"
-	}
-
-	return filepath.Join(homeDir, ".mcp-remote-go-auth")
+	return c.store.Save(c.serverURLHash, clientInfoFile, data)
 }
 
 // getMetadataPath gets the path for server metadata
@@ -550,12 +891,14 @@ func (c *Coordinator) getMetadataPath() string {
 	return filepath.Join(getConfigDir(), c.serverURLHash, "server_metadata.json")
 }
 
-// getClientInfoPath gets the path for client info
-func (c *Coordinator) getClientInfoPath() string {
-	return filepath.Join(getConfigDir(), c.serverURLHash, "client_info.json")
-}
-
-// getTokensPath gets the path for tokens
-func (c *Coordinator) getTokensPath() string {
-	return filepath.Join(getConfigDir(), c.serverURLHash, "tokens.json")
+// httpClient returns a client for requests to the authorization server.
+func (c *Coordinator) httpClient() *httpclient.Client {
+	config := httpclient.DefaultConfig()
+	if c.httpConfig != nil {
+		config.Timeout = c.httpConfig.Timeout
+		config.MaxRetries = c.httpConfig.MaxRetries
+		config.RetryDelay = c.httpConfig.RetryDelay
+	}
+	config.Transport = c.transport
+	return httpclient.New(config)
 }
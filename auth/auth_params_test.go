@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAuthParamsSentInAuthAndTokenRequests(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var tokenForms []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		tokenForms = append(tokenForms, r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	defer server.Close()
+
+	coordinator, err := NewCoordinator("auth-params", 3353,
+		WithClient("app", ""),
+		WithAuthParams(map[string]string{"audience": "https://api.example.com", "prompt": "login"}))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	defer coordinator.Close()
+	if err := coordinator.saveServerMetadata(&ServerMetadata{
+		AuthorizationEndpoint:         server.URL + "/authorize",
+		CodeChallengeMethodsSupported: []string{"S256"},
+		TokenEndpoint:                 server.URL + "/token",
+	}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+
+	authURL, err := coordinator.InitializeAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("InitializeAuth failed: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("audience") != "https://api.example.com" || query.Get("prompt") != "login" {
+		t.Errorf("auth URL is missing the extra parameters: %s", authURL)
+	}
+	if query.Get("resource") != "https://mcp.example.com/mcp" {
+		t.Errorf("auth URL resource = %q, want it kept", query.Get("resource"))
+	}
+
+	tokens, err := coordinator.ExchangeCode(t.Context(), "code")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if err := coordinator.SaveTokens(tokens); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if _, err := coordinator.RefreshTokens(t.Context()); err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+
+	if len(tokenForms) != 2 {
+		t.Fatalf("got %d token requests, want 2", len(tokenForms))
+	}
+	for i, form := range tokenForms {
+		if form.Get("audience") != "https://api.example.com" || form.Get("prompt") != "login" {
+			t.Errorf("token request %d (%s) is missing the extra parameters: %v", i, form.Get("grant_type"), form)
+		}
+	}
+}
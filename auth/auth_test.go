@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -62,6 +64,59 @@ func TestNewCoordinator(t *testing.T) {
 	}
 }
 
+func TestCoordinatorScopes(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("scope-default", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if got := coordinator.scope(); got != "mcp offline_access" {
+		t.Errorf("Expected default scope 'mcp offline_access', got %q", got)
+	}
+
+	coordinator, err = NewCoordinator("scope-custom", 3334, WithScopes([]string{"read", "write"}))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if got := coordinator.scope(); got != "read write" {
+		t.Errorf("Expected scope 'read write', got %q", got)
+	}
+}
+
+func TestCoordinatorScopeMatches(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("scope-match", 3334, WithScopes([]string{"read", "write"}))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+
+	tokens := &Tokens{AccessToken: "token"}
+	if err := coordinator.SaveTokens(tokens); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if tokens.Scope != "read write" {
+		t.Errorf("Expected requested scope to be recorded, got %q", tokens.Scope)
+	}
+
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"", true},
+		{"read write", true},
+		{"write read", true},
+		{"read", false},
+		{"read write admin", false},
+	}
+	for _, tt := range tests {
+		if got := coordinator.ScopeMatches(&Tokens{Scope: tt.scope}); got != tt.want {
+			t.Errorf("ScopeMatches(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
 func TestTokensMarshalling(t *testing.T) {
 	tokens := &Tokens{
 		AccessToken:  "access-token-123",
@@ -330,7 +385,7 @@ func TestExchangeCode(t *testing.T) {
 	}
 
 	// Exchange authorization code
-	tokens, err := coordinator.ExchangeCode("test-auth-code")
+	tokens, err := coordinator.ExchangeCode(t.Context(), "test-auth-code")
 	if err != nil {
 		t.Fatalf("ExchangeCode failed: %v", err)
 	}
@@ -387,7 +442,7 @@ func TestExchangeCodeError(t *testing.T) {
 	}
 
 	// Exchange authorization code (should fail)
-	_, err = coordinator.ExchangeCode("invalid-code")
+	_, err = coordinator.ExchangeCode(t.Context(), "invalid-code")
 	if err == nil {
 		t.Error("ExchangeCode should fail with invalid server response")
 	}
@@ -420,7 +475,7 @@ func TestExchangeCodeNotInitialized(t *testing.T) {
 	}
 
 	// Exchange authorization code in uninitialized state (should fail)
-	_, err = coordinator.ExchangeCode("test-code")
+	_, err = coordinator.ExchangeCode(t.Context(), "test-code")
 	if err == nil {
 		t.Error("ExchangeCode should fail when not initialized")
 	}
@@ -430,6 +485,33 @@ func TestExchangeCodeNotInitialized(t *testing.T) {
 	}
 }
 
+func TestExchangeCodeStopsWhenCancelled(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	coordinator, err := NewCoordinator("exchange-cancel-test", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{TokenEndpoint: server.URL + "/token"}
+	coordinator.clientInfo = &ClientInfo{ClientID: "client"}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := coordinator.ExchangeCode(ctx, "code"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the exchange to stop once cancelled, took %v", elapsed)
+	}
+}
+
 func TestWaitForAuthCodeTimeout(t *testing.T) {
 	// Create temporary directory for testing
 	tmpDir := t.TempDir()
@@ -483,3 +565,32 @@ func TestWaitForAuthCodeTimeout(t *testing.T) {
 		t.Error("Test should complete within 1 second")
 	}
 }
+
+func TestWithHTTPRetriesAndTimeout(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c, err := NewCoordinator("http-test", 0, WithHTTPRetries(1, time.Millisecond), WithHTTPTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+
+	if _, err := c.httpClient().Get(t.Context(), server.URL, nil); err == nil {
+		t.Error("Expected an error for a 500 response")
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected one retry, got %d attempts", attempts.Load())
+	}
+
+	if _, err := c.httpClient().Get(t.Context(), server.URL+"/slow", nil); err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("Expected the request to time out, got %v", err)
+	}
+}
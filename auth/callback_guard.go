@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// WithCallbackOrigins allows requests to the callback server that carry an
+// Origin header from one of origins, e.g. an authorization server whose
+// consent page posts back to the redirect URI. Browser redirects carry no
+// Origin header and are always accepted. Origins are checked with
+// ValidateCallbackOrigin beforehand; invalid ones are ignored.
+func WithCallbackOrigins(origins []string) CoordinatorOption {
+	return func(c *Coordinator) {
+		for _, origin := range origins {
+			if normalized, err := normalizeOrigin(origin); err == nil {
+				c.callbackOrigins = append(c.callbackOrigins, normalized)
+			}
+		}
+	}
+}
+
+// ValidateCallbackOrigin reports whether origin is a valid entry for
+// WithCallbackOrigins: a scheme and host with an optional port, such as
+// "https://auth.example.com", or "null".
+func ValidateCallbackOrigin(origin string) error {
+	_, err := normalizeOrigin(origin)
+	return err
+}
+
+// normalizeOrigin returns origin in the lowercase form browsers send.
+func normalizeOrigin(origin string) (string, error) {
+	if origin == "null" {
+		return origin, nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid origin %q: expected scheme://host[:port]", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// guardCallback rejects requests to the callback server that did not come
+// from a browser on this machine following the redirect: a Host header
+// other than the loopback address and port the server listens on, as sent
+// after a DNS rebinding, or an Origin header not allowed by
+// WithCallbackOrigins, as sent by a cross-origin request from a web page.
+func (c *Coordinator) guardCallback(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.isCallbackHost(r.Host) {
+			slog.Warn("rejected callback request with unexpected host", "host", r.Host)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !c.isCallbackOrigin(origin) {
+			slog.Warn("rejected callback request from disallowed origin", "origin", origin)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isCallbackHost reports whether host names the callback server: a loopback
+// address, "localhost" or the host given with WithCallbackHost, with the
+// port the server listens on.
+func (c *Coordinator) isCallbackHost(host string) bool {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil || port != strconv.Itoa(c.callbackPort) {
+		return false
+	}
+	return isLoopbackHostname(hostname) || (c.callbackHost != "" && strings.EqualFold(hostname, c.callbackHost))
+}
+
+// isLoopbackHostname reports whether hostname is a loopback address or
+// "localhost".
+func isLoopbackHostname(hostname string) bool {
+	switch strings.ToLower(hostname) {
+	case "127.0.0.1", "localhost", "::1":
+		return true
+	}
+	return false
+}
+
+// isCallbackOrigin reports whether origin is allowed by WithCallbackOrigins.
+func (c *Coordinator) isCallbackOrigin(origin string) bool {
+	normalized, err := normalizeOrigin(origin)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.callbackOrigins {
+		if normalized == allowed {
+			return true
+		}
+	}
+	return false
+}
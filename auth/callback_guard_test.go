@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGuardCallback(t *testing.T) {
+	c := &Coordinator{callbackPort: 3334}
+	WithCallbackOrigins([]string{"https://Auth.Example.com/", "not an origin"})(c)
+	handler := c.guardCallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"loopback IP", "127.0.0.1:3334", "", http.StatusNoContent},
+		{"localhost", "LocalHost:3334", "", http.StatusNoContent},
+		{"IPv6 loopback", "[::1]:3334", "", http.StatusNoContent},
+		{"rebound host", "attacker.example:3334", "", http.StatusForbidden},
+		{"other port", "127.0.0.1:3335", "", http.StatusForbidden},
+		{"no port", "127.0.0.1", "", http.StatusForbidden},
+		{"allowed origin", "127.0.0.1:3334", "https://auth.example.com", http.StatusNoContent},
+		{"disallowed origin", "127.0.0.1:3334", "https://attacker.example", http.StatusForbidden},
+		{"null origin", "127.0.0.1:3334", "null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/callback?code=x&state=y", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestValidateCallbackOrigin(t *testing.T) {
+	for _, origin := range []string{"https://auth.example.com", "http://127.0.0.1:8080", "null"} {
+		if err := ValidateCallbackOrigin(origin); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", origin, err)
+		}
+	}
+	for _, origin := range []string{"auth.example.com", "https://auth.example.com/login", "ftp://auth.example.com", "https://user@auth.example.com", ""} {
+		if err := ValidateCallbackOrigin(origin); err == nil {
+			t.Errorf("Expected %q to be rejected", origin)
+		}
+	}
+}
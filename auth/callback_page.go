@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// AuthorizationError is an error response from the authorization server
+// (RFC 6749 §4.1.2.1), e.g. access_denied when the user declines.
+type AuthorizationError struct {
+	Code        string
+	Description string
+	URI         string
+}
+
+func (e *AuthorizationError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("authorization denied: %s: %s", e.Code, e.Description)
+	}
+	return "authorization denied: " + e.Code
+}
+
+// CallbackPageData is passed to the callback page templates. On success
+// it is empty. On failure Error and ErrorDescription hold the OAuth error
+// code and description, or only ErrorDescription when the callback itself
+// was invalid, e.g. with a mismatched state.
+type CallbackPageData struct {
+	Error            string
+	ErrorDescription string
+	ErrorURI         string
+}
+
+var (
+	defaultSuccessPage = template.Must(template.New("success").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization Successful</title></head>
+<body>
+	<h1>Authorization Successful</h1>
+	<p>You can close this window and return to the application.</p>
+	<script>window.close();</script>
+</body>
+</html>
+`))
+	defaultErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization Failed</title></head>
+<body>
+	<h1>Authorization Failed</h1>
+	{{if .Error}}<p>The authorization server returned <code>{{.Error}}</code>.</p>{{end}}
+	{{if .ErrorDescription}}<p>{{.ErrorDescription}}</p>{{end}}
+	{{if .ErrorURI}}<p><a href="{{.ErrorURI}}">More information</a></p>{{end}}
+	<p>You can close this window and check the application for details.</p>
+</body>
+</html>
+`))
+)
+
+// LoadCallbackPage parses a callback page template for WithCallbackPages.
+// page is the path of a file holding the template or, when no such file
+// exists, the template itself. name identifies the page in errors.
+// Templates use html/template syntax with CallbackPageData; plain text is
+// shown as is.
+func LoadCallbackPage(name, page string) (*template.Template, error) {
+	text := page
+	if info, err := os.Stat(page); err == nil && info.Mode().IsRegular() {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s page: %w", name, err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s page: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// WithCallbackPages replaces the pages the callback server shows in the
+// browser when authorization succeeds or fails. A nil template keeps the
+// default page.
+func WithCallbackPages(success, failure *template.Template) CoordinatorOption {
+	return func(c *Coordinator) {
+		if success != nil {
+			c.successPage = success
+		}
+		if failure != nil {
+			c.errorPage = failure
+		}
+	}
+}
+
+// writeCallbackSuccess shows the success page.
+func (c *Coordinator) writeCallbackSuccess(w http.ResponseWriter) {
+	page := c.successPage
+	if page == nil {
+		page = defaultSuccessPage
+	}
+	writeCallbackPage(w, page, http.StatusOK, CallbackPageData{})
+}
+
+// writeCallbackError shows the error page for err.
+func (c *Coordinator) writeCallbackError(w http.ResponseWriter, err error) {
+	data := CallbackPageData{ErrorDescription: err.Error()}
+	var authErr *AuthorizationError
+	if errors.As(err, &authErr) {
+		data = CallbackPageData{
+			Error:            authErr.Code,
+			ErrorDescription: authErr.Description,
+			ErrorURI:         authErr.URI,
+		}
+	}
+	page := c.errorPage
+	if page == nil {
+		page = defaultErrorPage
+	}
+	writeCallbackPage(w, page, http.StatusBadRequest, data)
+}
+
+func writeCallbackPage(w http.ResponseWriter, page *template.Template, status int, data CallbackPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := page.Execute(w, data); err != nil {
+		slog.Warn("failed to write callback page", "error", err)
+	}
+}
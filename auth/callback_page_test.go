@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleCallbackRendersAuthorizationError(t *testing.T) {
+	c, state := newPendingCoordinator(t)
+	rec, _, err := callbackPage(t, c, "error=access_denied&error_description=User+%3Cb%3Edeclined%3C%2Fb%3E&state="+state)
+
+	var authErr *AuthorizationError
+	if !errors.As(err, &authErr) || authErr.Code != "access_denied" || authErr.Description != "User <b>declined</b>" {
+		t.Fatalf("Expected an AuthorizationError, got %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected an HTML error page, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "access_denied") || !strings.Contains(body, "User &lt;b&gt;declined&lt;/b&gt;") {
+		t.Errorf("Expected the escaped error and description on the page, got %s", body)
+	}
+}
+
+func TestHandleCallbackCustomPages(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "success.html")
+	if err := os.WriteFile(file, []byte("<p>Signed in</p>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	success, err := LoadCallbackPage("success", file)
+	if err != nil {
+		t.Fatalf("LoadCallbackPage failed: %v", err)
+	}
+	failure, err := LoadCallbackPage("error", "Failed: {{.Error}} {{.ErrorDescription}}")
+	if err != nil {
+		t.Fatalf("LoadCallbackPage failed: %v", err)
+	}
+
+	c, state := newPendingCoordinator(t)
+	WithCallbackPages(success, failure)(c)
+	rec, code, _ := callbackPage(t, c, "code=abc&state="+state)
+	if code != "abc" || rec.Body.String() != "<p>Signed in</p>" {
+		t.Errorf("Expected the custom success page, got %q", rec.Body.String())
+	}
+
+	c, state = newPendingCoordinator(t)
+	WithCallbackPages(success, failure)(c)
+	rec, _, _ = callbackPage(t, c, "error=access_denied&state="+state)
+	if got := rec.Body.String(); got != "Failed: access_denied " {
+		t.Errorf("Expected the custom error page, got %q", got)
+	}
+
+	if _, err := LoadCallbackPage("error", "{{.Error"); err == nil {
+		t.Error("Expected an invalid template to be rejected")
+	}
+}
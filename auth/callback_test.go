@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPendingCoordinator returns a coordinator whose authorization URL has
+// been built, together with the state it carries.
+func newPendingCoordinator(t *testing.T) (*Coordinator, string) {
+	t.Helper()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	c, err := NewCoordinator("state-test", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.serverMetadata = &ServerMetadata{AuthorizationEndpoint: "https://auth.example.com/authorize", CodeChallengeMethodsSupported: []string{"S256"}}
+	c.clientInfo = &ClientInfo{ClientID: "client"}
+
+	authURL, err := c.buildAuthorizationURL()
+	if err != nil {
+		t.Fatalf("buildAuthorizationURL failed: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL: %v", err)
+	}
+	state := u.Query().Get("state")
+	if len(state) < 43 {
+		t.Fatalf("Expected a random state of at least 256 bits, got %q", state)
+	}
+	return c, state
+}
+
+// callback sends query to the callback handler while WaitForAuthCode waits.
+func callback(t *testing.T, c *Coordinator, query string) (int, string, error) {
+	t.Helper()
+	rec, code, err := callbackPage(t, c, query)
+	return rec.Code, code, err
+}
+
+// callbackPage is callback returning the page written by the handler.
+func callbackPage(t *testing.T, c *Coordinator, query string) (*httptest.ResponseRecorder, string, error) {
+	t.Helper()
+	type waitResult struct {
+		code string
+		err  error
+	}
+	waited := make(chan waitResult, 1)
+	go func() {
+		code, err := c.WaitForAuthCode(t.Context())
+		waited <- waitResult{code, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	c.handleCallback(rec, httptest.NewRequest(http.MethodGet, "/callback?"+query, nil))
+
+	select {
+	case res := <-waited:
+		return rec, res.code, res.err
+	case <-time.After(time.Second):
+		t.Fatal("Expected WaitForAuthCode to return")
+		return nil, "", nil
+	}
+}
+
+func TestCallbackAcceptsMatchingState(t *testing.T) {
+	c, state := newPendingCoordinator(t)
+
+	status, code, err := callback(t, c, "code=abc&state="+url.QueryEscape(state))
+	if err != nil || code != "abc" {
+		t.Errorf("Expected code 'abc', got %q, %v", code, err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", status)
+	}
+
+	// The state cannot be replayed.
+	rec := httptest.NewRecorder()
+	c.handleCallback(rec, httptest.NewRequest(http.MethodGet, "/callback?code=abc&state="+url.QueryEscape(state), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a replayed callback to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestCallbackRejectsMismatchedState(t *testing.T) {
+	for _, query := range []string{"code=injected&state=forged", "code=injected"} {
+		c, _ := newPendingCoordinator(t)
+
+		status, code, err := callback(t, c, query)
+		if err == nil || !strings.Contains(err.Error(), "state mismatch") {
+			t.Errorf("%s: expected a state mismatch error, got code %q, %v", query, code, err)
+		}
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, status)
+		}
+	}
+}
+
+func TestCallbackSurfacesAuthorizationError(t *testing.T) {
+	c, state := newPendingCoordinator(t)
+
+	_, _, err := callback(t, c, "error=access_denied&error_description=User+declined&state="+url.QueryEscape(state))
+	if err == nil || !strings.Contains(err.Error(), "access_denied: User declined") {
+		t.Errorf("Expected the authorization error, got %v", err)
+	}
+
+	// A pasted redirect carrying an error fails the flow the same way.
+	c, state = newPendingCoordinator(t)
+	_, err, submitErr := submit(t, c, "http://127.0.0.1:3334/callback?error=invalid_scope&error_uri=https%3A%2F%2Fauth.example.com%2Fhelp&state="+url.QueryEscape(state))
+	var authErr *AuthorizationError
+	if !errors.As(err, &authErr) || authErr.Code != "invalid_scope" || authErr.URI != "https://auth.example.com/help" {
+		t.Errorf("Expected a structured authorization error, got %v", err)
+	}
+	if !errors.As(submitErr, &authErr) {
+		t.Errorf("Expected SubmitAuthorizationResponse to return the error, got %v", submitErr)
+	}
+}
+
+// submit passes input to SubmitAuthorizationResponse while WaitForAuthCode
+// waits.
+func submit(t *testing.T, c *Coordinator, input string) (string, error, error) {
+	t.Helper()
+	type waitResult struct {
+		code string
+		err  error
+	}
+	waited := make(chan waitResult, 1)
+	go func() {
+		code, err := c.WaitForAuthCode(t.Context())
+		waited <- waitResult{code, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	submitErr := c.SubmitAuthorizationResponse(input)
+	select {
+	case res := <-waited:
+		return res.code, res.err, submitErr
+	case <-time.After(time.Second):
+		t.Fatal("Expected WaitForAuthCode to return")
+		return "", nil, submitErr
+	}
+}
+
+func TestSubmitAuthorizationResponse(t *testing.T) {
+	c, state := newPendingCoordinator(t)
+	code, err, submitErr := submit(t, c, "http://127.0.0.1:3334/callback?code=abc&state="+url.QueryEscape(state)+"\n")
+	if submitErr != nil || err != nil || code != "abc" {
+		t.Errorf("Expected code 'abc' from the redirect URL, got %q, %v, %v", code, err, submitErr)
+	}
+
+	c, _ = newPendingCoordinator(t)
+	code, err, submitErr = submit(t, c, "  pasted-code ")
+	if submitErr != nil || err != nil || code != "pasted-code" {
+		t.Errorf("Expected the bare code, got %q, %v, %v", code, err, submitErr)
+	}
+
+	c, _ = newPendingCoordinator(t)
+	_, err, submitErr = submit(t, c, "http://127.0.0.1:3334/callback?code=injected&state=forged")
+	if submitErr == nil || err == nil || !strings.Contains(err.Error(), "state mismatch") {
+		t.Errorf("Expected a state mismatch error, got %v, %v", err, submitErr)
+	}
+
+	if err := c.SubmitAuthorizationResponse("late-code"); !errors.Is(err, ErrNoAuthorizationPending) {
+		t.Errorf("Expected ErrNoAuthorizationPending, got %v", err)
+	}
+}
+
+func TestWaitForAuthCodeGivesUp(t *testing.T) {
+	c, _ := newPendingCoordinator(t)
+	WithAuthTimeout(20 * time.Millisecond)(c)
+	if err := c.startCallbackServer(); err != nil {
+		t.Fatalf("startCallbackServer failed: %v", err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", c.callbackPort)
+
+	_, err := c.WaitForAuthCode(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Error("Expected the callback server to be stopped")
+	}
+	if _, ok := c.authorizationResult(url.Values{"code": {"late"}}, false); ok {
+		t.Error("Expected a late callback to be refused")
+	}
+
+	c, _ = newPendingCoordinator(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := c.WaitForAuthCode(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation to end the wait, got %v", err)
+	}
+}
+
+func TestGenerateStateUniqueness(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		state, err := GenerateState()
+		if err != nil {
+			t.Fatalf("GenerateState() error: %v", err)
+		}
+		if seen[state] {
+			t.Fatalf("Duplicate state %q", state)
+		}
+		seen[state] = true
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+const (
+	// callbackCertFile holds the certificate and key of the callback server
+	// in the config directory, so a certificate trusted once keeps working.
+	callbackCertFile = "callback_tls.pem"
+
+	// callbackCertValidity is how long a generated certificate is valid.
+	callbackCertValidity = 365 * 24 * time.Hour
+
+	// callbackCertRenewBefore is how long before expiry a certificate is
+	// replaced.
+	callbackCertRenewBefore = 7 * 24 * time.Hour
+
+	// callbackTLSHost is the host of an https redirect URI, named by the
+	// certificate.
+	callbackTLSHost = "localhost"
+)
+
+// WithCallbackTLS serves the callback over HTTPS and registers an https
+// redirect URI naming localhost, for authorization servers that refuse
+// plaintext redirect URIs. The certificate is self-signed, generated on
+// first use and kept in the config directory.
+func WithCallbackTLS() CoordinatorOption {
+	return func(c *Coordinator) {
+		c.callbackTLS = true
+	}
+}
+
+// callbackCertHosts returns the names the callback server's certificate
+// must cover.
+func (c *Coordinator) callbackCertHosts() []string {
+	hosts := []string{callbackTLSHost, "127.0.0.1", "::1"}
+	if c.callbackHost != "" {
+		hosts = append(hosts, c.callbackHost)
+	}
+	return hosts
+}
+
+// callbackTLSConfig returns the TLS configuration of the callback server,
+// loading the certificate from the config directory or generating one.
+func (c *Coordinator) callbackTLSConfig() (*tls.Config, error) {
+	cert, err := loadOrCreateCallbackCert(filepath.Join(getConfigDir(), callbackCertFile), c.callbackCertHosts())
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// loadOrCreateCallbackCert returns the certificate at path when it covers
+// hosts and is not about to expire, and otherwise generates a self-signed
+// one for hosts and saves it there.
+func loadOrCreateCallbackCert(path string, hosts []string) (tls.Certificate, error) {
+	if data, err := filelock.ReadFile(path); err == nil {
+		cert, err := tls.X509KeyPair(data, data)
+		if err == nil && callbackCertUsable(cert.Leaf, hosts) {
+			return cert, nil
+		}
+		slog.Info("replacing callback server certificate", "path", path)
+	}
+
+	data, err := generateCallbackCert(hosts)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate callback server certificate: %w", err)
+	}
+	if err := filelock.WriteFile(path, data, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to save callback server certificate: %w", err)
+	}
+	slog.Info("generated callback server certificate", "path", path)
+	return tls.X509KeyPair(data, data)
+}
+
+// callbackCertUsable reports whether leaf covers hosts and stays valid for
+// longer than callbackCertRenewBefore.
+func callbackCertUsable(leaf *x509.Certificate, hosts []string) bool {
+	if leaf == nil || time.Now().Add(callbackCertRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateCallbackCert returns a PEM-encoded self-signed certificate for
+// hosts followed by its private key.
+func generateCallbackCert(hosts []string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: callbackTLSHost, Organization: []string{clientName}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(callbackCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...), nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCallbackTLS(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvConfigDir, dir)
+	coordinator := newCoordinatorWithCachedClient(t, "callback-tls-test", 3370, ClientInfo{
+		ClientID:     "client",
+		RedirectURIs: []string{"http://127.0.0.1:3370/callback"},
+	})
+	WithCallbackTLS()(coordinator)
+
+	// A client registered for the plaintext redirect URI does not fit.
+	if _, ok := coordinator.registeredRedirectURI(&ClientInfo{RedirectURIs: []string{"http://127.0.0.1:3370/callback"}}); ok {
+		t.Error("Expected an http redirect URI not to match")
+	}
+	if got, ok := coordinator.registeredRedirectURI(&ClientInfo{RedirectURIs: []string{"https://localhost:3370/callback"}}); !ok || got != "https://localhost:3370/callback" {
+		t.Errorf("Expected the https redirect URI to match, got %q", got)
+	}
+
+	coordinator.callbackPort = 0
+	if err := coordinator.startCallbackServer(); err != nil {
+		t.Fatalf("startCallbackServer failed: %v", err)
+	}
+	defer func() { _ = coordinator.callbackServer.Close() }()
+	if got, want := coordinator.localRedirectURI(), fmt.Sprintf("https://localhost:%d/callback", coordinator.callbackPort); got != want {
+		t.Errorf("localRedirectURI() = %q, want %q", got, want)
+	}
+
+	// The saved certificate verifies the server.
+	data, err := os.ReadFile(filepath.Join(dir, callbackCertFile))
+	if err != nil {
+		t.Fatalf("Expected the certificate saved: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		t.Fatal("Expected a certificate in the saved file")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(coordinator.localRedirectURI())
+	if err != nil {
+		t.Fatalf("Callback server not reachable over HTTPS: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestLoadOrCreateCallbackCert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), callbackCertFile)
+
+	first, err := loadOrCreateCallbackCert(path, []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("loadOrCreateCallbackCert failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the certificate and key saved with mode 0600, got %v", info)
+	}
+
+	again, err := loadOrCreateCallbackCert(path, []string{"localhost"})
+	if err != nil {
+		t.Fatalf("loadOrCreateCallbackCert failed: %v", err)
+	}
+	if !bytes.Equal(again.Certificate[0], first.Certificate[0]) {
+		t.Error("Expected the saved certificate to be reused")
+	}
+
+	// A certificate not naming a new callback host is replaced.
+	replaced, err := loadOrCreateCallbackCert(path, []string{"localhost", "devbox.local"})
+	if err != nil {
+		t.Fatalf("loadOrCreateCallbackCert failed: %v", err)
+	}
+	if bytes.Equal(replaced.Certificate[0], first.Certificate[0]) || replaced.Leaf.VerifyHostname("devbox.local") != nil {
+		t.Error("Expected a certificate covering the new host")
+	}
+
+	// An unreadable file is replaced too.
+	if err := os.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOrCreateCallbackCert(path, []string{"localhost"}); err != nil {
+		t.Errorf("Expected a corrupt certificate replaced, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/jwt"
+)
+
+// Token endpoint client authentication methods (RFC 7591 §2, RFC 7523 §2.2).
+const (
+	TokenAuthClientSecretBasic = "client_secret_basic"
+	TokenAuthClientSecretPost  = "client_secret_post"
+	TokenAuthPrivateKeyJWT     = "private_key_jwt"
+	TokenAuthNone              = "none"
+)
+
+// clientAssertionType is the client_assertion_type of a JWT client
+// assertion (RFC 7523 §2.2).
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long a client assertion is valid.
+const clientAssertionLifetime = 5 * time.Minute
+
+// ValidTokenAuthMethod reports whether method is a supported token endpoint
+// authentication method.
+func ValidTokenAuthMethod(method string) bool {
+	switch method {
+	case TokenAuthClientSecretBasic, TokenAuthClientSecretPost, TokenAuthPrivateKeyJWT, TokenAuthNone:
+		return true
+	}
+	return false
+}
+
+// WithTokenEndpointAuth sets how the client authenticates to the token
+// endpoint, overriding the method the client was registered with. key signs
+// the client assertion for TokenAuthPrivateKeyJWT, announced with keyID
+// when set; giving a key without a method selects TokenAuthPrivateKeyJWT.
+func WithTokenEndpointAuth(method string, key crypto.Signer, keyID string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.tokenAuthMethod = method
+		c.assertionKey = key
+		c.assertionKeyID = keyID
+	}
+}
+
+// tokenEndpointAuthMethod returns how clientInfo authenticates to the token
+// endpoint: the configured method, else the registered one, else
+// private_key_jwt with a signing key, client_secret_post with a secret
+// (client_secret_basic if the server supports only that) and none without.
+func (c *Coordinator) tokenEndpointAuthMethod(metadata *ServerMetadata, clientInfo *ClientInfo) string {
+	switch {
+	case c.tokenAuthMethod != "":
+		return c.tokenAuthMethod
+	case clientInfo.TokenEndpointAuthMethod != "":
+		return clientInfo.TokenEndpointAuthMethod
+	case c.assertionKey != nil:
+		return TokenAuthPrivateKeyJWT
+	case clientInfo.ClientSecret == "":
+		return TokenAuthNone
+	}
+	supported := metadata.TokenEndpointAuthMethodsSupported
+	if len(supported) > 0 && !slices.Contains(supported, TokenAuthClientSecretPost) && slices.Contains(supported, TokenAuthClientSecretBasic) {
+		return TokenAuthClientSecretBasic
+	}
+	return TokenAuthClientSecretPost
+}
+
+// authenticateClient adds the client authentication to a token endpoint
+// request with formData (RFC 6749 §2.3) and returns the headers to send.
+func (c *Coordinator) authenticateClient(metadata *ServerMetadata, clientInfo *ClientInfo, formData url.Values) (map[string]string, error) {
+	headers, err := c.clientAuthentication(metadata, clientInfo, formData)
+	if err != nil {
+		return nil, err
+	}
+	return c.providerHeaders(headers), nil
+}
+
+// clientAuthentication adds the client authentication of the method in use
+// to formData and returns the headers it needs.
+func (c *Coordinator) clientAuthentication(metadata *ServerMetadata, clientInfo *ClientInfo, formData url.Values) (map[string]string, error) {
+	switch method := c.tokenEndpointAuthMethod(metadata, clientInfo); method {
+	case TokenAuthNone:
+		return nil, nil
+	case TokenAuthClientSecretPost:
+		if clientInfo.ClientSecret != "" {
+			formData.Set("client_secret", clientInfo.ClientSecret)
+		}
+		return nil, nil
+	case TokenAuthClientSecretBasic:
+		// The credentials are form-encoded before base64 (RFC 6749 §2.3.1).
+		credentials := url.QueryEscape(clientInfo.ClientID) + ":" + url.QueryEscape(clientInfo.ClientSecret)
+		return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}, nil
+	case TokenAuthPrivateKeyJWT:
+		assertion, err := c.clientAssertion(metadata, clientInfo)
+		if err != nil {
+			return nil, err
+		}
+		formData.Set("client_assertion_type", clientAssertionType)
+		formData.Set("client_assertion", assertion)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported token endpoint auth method %q", method)
+	}
+}
+
+// clientAssertion returns a JWT authenticating clientInfo to the token
+// endpoint (RFC 7523 §3).
+func (c *Coordinator) clientAssertion(metadata *ServerMetadata, clientInfo *ClientInfo) (string, error) {
+	if c.assertionKey == nil {
+		return "", fmt.Errorf("%s requires a signing key", TokenAuthPrivateKeyJWT)
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate assertion ID: %w", err)
+	}
+	now := time.Now()
+	assertion, err := jwt.Sign(map[string]any{
+		"iss": clientInfo.ClientID,
+		"sub": clientInfo.ClientID,
+		"aud": metadata.TokenEndpoint,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	}, c.assertionKey, c.assertionKeyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create client assertion: %w", err)
+	}
+	return assertion, nil
+}
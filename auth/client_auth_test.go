@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticateClient(t *testing.T) {
+	metadata := &ServerMetadata{TokenEndpoint: "https://as.example.com/token"}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []CoordinatorOption
+		client     ClientInfo
+		metadata   ServerMetadata
+		wantForm   string // form field that must be set
+		wantHeader string // Authorization header
+	}{
+		{name: "secret defaults to post", client: ClientInfo{ClientID: "c", ClientSecret: "s"}, wantForm: "client_secret"},
+		{name: "basic only server", client: ClientInfo{ClientID: "c", ClientSecret: "s"}, metadata: ServerMetadata{TokenEndpointAuthMethodsSupported: []string{"client_secret_basic"}},
+			wantHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("c:s"))},
+		{name: "registered basic", client: ClientInfo{ClientID: "a b", ClientSecret: "s:1", TokenEndpointAuthMethod: TokenAuthClientSecretBasic},
+			wantHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("a+b:s%3A1"))},
+		{name: "registered none", client: ClientInfo{ClientID: "c", ClientSecret: "s", TokenEndpointAuthMethod: TokenAuthNone}},
+		{name: "configured overrides registered", opts: []CoordinatorOption{WithTokenEndpointAuth(TokenAuthClientSecretPost, nil, "")},
+			client: ClientInfo{ClientID: "c", ClientSecret: "s", TokenEndpointAuthMethod: TokenAuthClientSecretBasic}, wantForm: "client_secret"},
+		{name: "key selects private_key_jwt", opts: []CoordinatorOption{WithTokenEndpointAuth("", key, "k1")},
+			client: ClientInfo{ClientID: "c"}, wantForm: "client_assertion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Coordinator{}
+			for _, opt := range tt.opts {
+				opt(c)
+			}
+			md := tt.metadata
+			md.TokenEndpoint = metadata.TokenEndpoint
+			form := url.Values{"client_id": {tt.client.ClientID}}
+			headers, err := c.authenticateClient(&md, &tt.client, form)
+			if err != nil {
+				t.Fatalf("authenticateClient failed: %v", err)
+			}
+			for _, field := range []string{"client_secret", "client_assertion"} {
+				if _, ok := form[field]; ok != (field == tt.wantForm) {
+					t.Errorf("Expected %s sent: %v, form %v", field, field == tt.wantForm, form)
+				}
+			}
+			if got := headers["Authorization"]; got != tt.wantHeader {
+				t.Errorf("Expected Authorization %q, got %q", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestClientAssertion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Coordinator{}
+	WithTokenEndpointAuth(TokenAuthPrivateKeyJWT, key, "k1")(c)
+	metadata := &ServerMetadata{TokenEndpoint: "https://as.example.com/token"}
+
+	form := url.Values{}
+	if _, err := c.authenticateClient(metadata, &ClientInfo{ClientID: "m2m"}, form); err != nil {
+		t.Fatalf("authenticateClient failed: %v", err)
+	}
+	if form.Get("client_assertion_type") != clientAssertionType {
+		t.Errorf("Unexpected client_assertion_type %q", form.Get("client_assertion_type"))
+	}
+	parts := strings.Split(form.Get("client_assertion"), ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a JWT, got %q", form.Get("client_assertion"))
+	}
+	var header, claims map[string]any
+	decode := func(segment string, v any) {
+		data, _ := base64.RawURLEncoding.DecodeString(segment)
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("Invalid segment: %v", err)
+		}
+	}
+	decode(parts[0], &header)
+	decode(parts[1], &claims)
+	if header["alg"] != "ES256" || header["kid"] != "k1" {
+		t.Errorf("Unexpected header %v", header)
+	}
+	if claims["iss"] != "m2m" || claims["sub"] != "m2m" || claims["aud"] != metadata.TokenEndpoint || claims["jti"] == "" {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+
+	WithTokenEndpointAuth(TokenAuthPrivateKeyJWT, nil, "")(c)
+	if _, err := c.authenticateClient(metadata, &ClientInfo{ClientID: "m2m"}, url.Values{}); err == nil {
+		t.Error("Expected an error for private_key_jwt without a key")
+	}
+}
+
+func TestClientCredentialsWithBasicAuth(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "m2m" || secret != "s3cret" || r.FormValue("client_secret") != "" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"basic-token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	coordinator, err := NewCoordinator("client-basic", 3334,
+		WithClientCredentials("m2m", "s3cret"), WithTokenEndpointAuth(TokenAuthClientSecretBasic, nil, ""))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.saveServerMetadata(&ServerMetadata{TokenEndpoint: server.URL + "/token"}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+	tokens, err := coordinator.ClientCredentialsAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("ClientCredentialsAuth failed: %v", err)
+	}
+	if tokens.AccessToken != "basic-token" {
+		t.Errorf("Expected the token issued for Basic auth, got %q", tokens.AccessToken)
+	}
+}
@@ -50,7 +50,7 @@ func TestLoadOrRegisterClientSkipsStaleCacheForDifferentIssuer(t *testing.T) {
 		RegistrationEndpoint: as.URL + "/register",
 	}
 
-	clientInfo, err := coordinator.loadOrRegisterClient()
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
 	if err != nil {
 		t.Fatalf("loadOrRegisterClient failed: %v", err)
 	}
@@ -85,7 +85,7 @@ func TestLoadOrRegisterClientReusesCacheWhenIssuerMatches(t *testing.T) {
 		TokenEndpoint:         issuer + "/token",
 	}
 
-	clientInfo, err := coordinator.loadOrRegisterClient()
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
 	if err != nil {
 		t.Fatalf("loadOrRegisterClient failed: %v", err)
 	}
@@ -107,7 +107,7 @@ func TestLoadOrRegisterClientReusesLegacyCacheWithoutIssuer(t *testing.T) {
 		Issuer: "https://as.example.com",
 	}
 
-	clientInfo, err := coordinator.loadOrRegisterClient()
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
 	if err != nil {
 		t.Fatalf("loadOrRegisterClient failed: %v", err)
 	}
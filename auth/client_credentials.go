@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// AuthFlowClientCredentials is the client credentials grant (RFC 6749 §4.4)
+// for machine-to-machine access without a user.
+const AuthFlowClientCredentials = "client_credentials"
+
+// WithClientCredentials sets a pre-registered client ID and secret and
+// selects AuthFlowClientCredentials. Dynamic client registration is skipped,
+// and the secret is kept in memory only.
+func WithClientCredentials(clientID, clientSecret string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.flow = AuthFlowClientCredentials
+		c.clientInfo = &ClientInfo{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+		}
+	}
+}
+
+// ClientCredentialsAuth discovers the authorization server and requests an
+// access token with the configured client credentials.
+func (c *Coordinator) ClientCredentialsAuth(ctx context.Context, serverURL string, opts ...InitOption) (*Tokens, error) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	if c.clientInfo == nil || c.clientInfo.ClientID == "" {
+		return nil, errors.New("client credentials not configured")
+	}
+
+	cfg := &initConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	resource, err := c.resourceFor(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive canonical resource URI: %w", err)
+	}
+	c.resource = resource
+
+	metadata, err := c.discoverServerMetadata(ctx, serverURL, cfg.resourceMetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover server metadata: %w", err)
+	}
+	c.serverMetadata = metadata
+
+	audit(c.serverURLHash, auditAuthStarted, "client_credentials")
+	return c.requestClientCredentialsToken(ctx, metadata, c.clientInfo, c.resource)
+}
+
+// requestClientCredentialsToken performs the client credentials token request.
+func (c *Coordinator) requestClientCredentialsToken(ctx context.Context, metadata *ServerMetadata, clientInfo *ClientInfo, resource string) (*Tokens, error) {
+	formData := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {clientInfo.ClientID},
+		"scope":      {c.scope()},
+	}
+
+	// RFC 8707 resource indicator (required by the MCP authorization spec).
+	c.setResource(formData, resource)
+	c.setAuthParams(formData)
+
+	headers, err := c.authenticateClient(metadata, clientInfo, formData)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.PostForm(ctx, metadata.TokenEndpoint, formData, headers)
+	if err != nil {
+		return nil, fmt.Errorf("client credentials token request failed: %w", err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var tokens Tokens
+	if err := resp.JSON(&tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("token response did not include an access token")
+	}
+	return &tokens, nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newClientCredentialsServer returns a token endpoint that issues
+// "cc-<n>" access tokens for client_credentials grants from client "m2m".
+func newClientCredentialsServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("grant_type") != "client_credentials" ||
+			r.Form.Get("client_id") != "m2m" || r.Form.Get("client_secret") != "s3cret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.Form.Get("resource") != "https://mcp.example.com/mcp" {
+			http.Error(w, `{"error":"invalid_target"}`, http.StatusBadRequest)
+			return
+		}
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("cc-%d", n),
+			"expires_in":   3600,
+			"token_type":   "Bearer",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientCredentialsAuth(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := newClientCredentialsServer(t, &calls)
+
+	coordinator, err := NewCoordinator("client-credentials", 3334, WithClientCredentials("m2m", "s3cret"))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.saveServerMetadata(&ServerMetadata{TokenEndpoint: server.URL + "/token"}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+
+	tokens, err := coordinator.ClientCredentialsAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("ClientCredentialsAuth failed: %v", err)
+	}
+	if tokens.AccessToken != "cc-1" {
+		t.Errorf("Expected access token 'cc-1', got %q", tokens.AccessToken)
+	}
+	if err := coordinator.SaveTokens(tokens); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	// Without a refresh token, renewal repeats the grant.
+	renewed, err := coordinator.RefreshTokens(t.Context())
+	if err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	if renewed.AccessToken != "cc-2" {
+		t.Errorf("Expected renewed access token 'cc-2', got %q", renewed.AccessToken)
+	}
+
+	if _, err := coordinator.loadClientInfo(); err == nil {
+		t.Error("Expected client secret not to be persisted")
+	}
+}
+
+func TestClientCredentialsAuthInvalidClient(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := newClientCredentialsServer(t, &calls)
+
+	coordinator, err := NewCoordinator("client-credentials-invalid", 3334, WithClientCredentials("m2m", "wrong"))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.saveServerMetadata(&ServerMetadata{TokenEndpoint: server.URL + "/token"}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+
+	if _, err := coordinator.ClientCredentialsAuth(t.Context(), "https://mcp.example.com/mcp"); err == nil {
+		t.Error("Expected error for invalid client credentials")
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// EnvConfigDir overrides the directory credentials are stored in.
+const EnvConfigDir = "MCP_REMOTE_CONFIG_DIR"
+
+const (
+	// appDirName is the name of the directory under the platform's config
+	// and state directories.
+	appDirName = "mcp-remote-go"
+
+	// legacyDirName is the directory under the home directory used before
+	// the platform directories were adopted.
+	legacyDirName = ".mcp-remote-go-auth"
+)
+
+var (
+	configDirMu       sync.Mutex
+	configDirOverride string
+)
+
+// SetConfigDir makes the package store its files under dir, taking
+// precedence over MCP_REMOTE_CONFIG_DIR and the platform default. An empty
+// dir restores the default.
+func SetConfigDir(dir string) {
+	configDirMu.Lock()
+	defer configDirMu.Unlock()
+	configDirOverride = dir
+}
+
+// ConfigDir returns the directory credentials and server metadata are
+// stored in.
+func ConfigDir() string {
+	return getConfigDir()
+}
+
+// getConfigDir returns the directory set with SetConfigDir, then
+// MCP_REMOTE_CONFIG_DIR, then the platform default: $XDG_CONFIG_HOME on
+// Linux, %AppData% on Windows and ~/Library/Application Support on macOS.
+func getConfigDir() string {
+	configDirMu.Lock()
+	dir := configDirOverride
+	configDirMu.Unlock()
+	if dir != "" {
+		return dir
+	}
+	if dir := os.Getenv(EnvConfigDir); dir != "" {
+		return dir
+	}
+	return defaultConfigDir()
+}
+
+// getStateDir returns the directory for runtime state such as sockets:
+// $XDG_STATE_HOME on Linux and the config directory elsewhere or when the
+// config directory was chosen explicitly.
+func getStateDir() string {
+	configDirMu.Lock()
+	override := configDirOverride
+	configDirMu.Unlock()
+	if override != "" || os.Getenv(EnvConfigDir) != "" || runtime.GOOS != "linux" {
+		return getConfigDir()
+	}
+
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return getConfigDir()
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, appDirName)
+}
+
+// defaultConfigDir returns the platform config directory, moving a legacy
+// ~/.mcp-remote-go-auth there first.
+func defaultConfigDir() string {
+	home, homeErr := os.UserHomeDir()
+	base, err := os.UserConfigDir()
+	if err != nil {
+		if homeErr != nil {
+			// Fall back to the current directory if no home is known
+			return legacyDirName
+		}
+		return filepath.Join(home, legacyDirName)
+	}
+	dir := filepath.Join(base, appDirName)
+	if homeErr != nil {
+		return dir
+	}
+	return migrateLegacyDir(filepath.Join(home, legacyDirName), dir)
+}
+
+// migrateLegacyDir moves legacy to dir if only legacy exists, and returns
+// the directory to use. If the move fails, e.g. because legacy is a mount
+// point, legacy stays in use.
+func migrateLegacyDir(legacy, dir string) string {
+	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+		return dir
+	}
+	if info, err := os.Stat(legacy); err != nil || !info.IsDir() {
+		return dir
+	}
+
+	err := os.MkdirAll(filepath.Dir(dir), 0700)
+	if err == nil {
+		err = os.Rename(legacy, dir)
+	}
+	if err != nil {
+		slog.Warn("failed to move config directory, continuing to use it", "dir", legacy, "target", dir, "error", err)
+		return legacy
+	}
+	slog.Info("moved config directory", "from", legacy, "to", dir)
+	return dir
+}
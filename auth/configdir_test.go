@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetConfigDirPrecedence(t *testing.T) {
+	t.Setenv(EnvConfigDir, "/from/env")
+	if got := getConfigDir(); got != "/from/env" {
+		t.Errorf("Expected the environment variable, got %s", got)
+	}
+
+	SetConfigDir("/from/flag")
+	defer SetConfigDir("")
+	if got := getConfigDir(); got != "/from/flag" {
+		t.Errorf("Expected SetConfigDir to win, got %s", got)
+	}
+	if got := getStateDir(); got != "/from/flag" {
+		t.Errorf("Expected state under an explicit config dir, got %s", got)
+	}
+}
+
+func TestMigrateLegacyDir(t *testing.T) {
+	home := t.TempDir()
+	legacy := filepath.Join(home, legacyDirName)
+	dir := filepath.Join(home, ".config", appDirName)
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, saltFile), []byte("salt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := migrateLegacyDir(legacy, dir); got != dir {
+		t.Fatalf("Expected %s, got %s", dir, got)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, saltFile)); err != nil || string(data) != "salt" {
+		t.Errorf("Expected the files moved, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("Expected the legacy directory gone, got %v", err)
+	}
+
+	// An existing directory is kept even if a legacy one reappears.
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got := migrateLegacyDir(legacy, dir); got != dir {
+		t.Errorf("Expected %s, got %s", dir, got)
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Authorization flows selectable with WithAuthFlow.
+const (
+	// AuthFlowBrowser is the authorization code flow with a local redirect.
+	AuthFlowBrowser = "browser"
+	// AuthFlowDevice is the device authorization grant (RFC 8628), for
+	// machines without a browser.
+	AuthFlowDevice = "device"
+)
+
+const (
+	// deviceCodeGrantType is the grant_type for device access token requests
+	// (RFC 8628 §3.4).
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// defaultDevicePollInterval applies when the server does not send an
+	// interval (RFC 8628 §3.2).
+	defaultDevicePollInterval = 5 * time.Second
+
+	// deviceSlowDownStep is added to the polling interval on slow_down
+	// (RFC 8628 §3.5).
+	deviceSlowDownStep = 5 * time.Second
+)
+
+// DeviceAuthorization is the device authorization response (RFC 8628 §3.2).
+// The user visits VerificationURI and enters UserCode.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// oauthError is an OAuth 2.0 error response (RFC 6749 §5.2).
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// WithAuthFlow selects the authorization flow. The default is AuthFlowBrowser.
+func WithAuthFlow(flow string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.flow = flow
+	}
+}
+
+// StartDeviceAuth discovers the authorization server, registers a client if
+// needed and requests a device code. No callback server is started.
+func (c *Coordinator) StartDeviceAuth(ctx context.Context, serverURL string, opts ...InitOption) (*DeviceAuthorization, error) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	cfg := &initConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	resource, err := c.resourceFor(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive canonical resource URI: %w", err)
+	}
+	c.resource = resource
+
+	metadata, err := c.discoverServerMetadata(ctx, serverURL, cfg.resourceMetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover server metadata: %w", err)
+	}
+	if metadata.DeviceAuthorizationEndpoint == "" {
+		// Metadata cached before the device endpoint was recorded.
+		if metadata, err = c.fetchServerMetadata(ctx, serverURL, cfg.resourceMetadataURL); err != nil {
+			return nil, fmt.Errorf("failed to discover server metadata: %w", err)
+		}
+	}
+	if metadata.DeviceAuthorizationEndpoint == "" {
+		return nil, errors.New("server does not support the device authorization grant")
+	}
+	c.serverMetadata = metadata
+
+	clientInfo, err := c.loadOrRegisterClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client registration failed: %w", err)
+	}
+	c.clientInfo = clientInfo
+
+	formData := url.Values{
+		"client_id": {clientInfo.ClientID},
+		"scope":     {c.scope()},
+	}
+	c.setResource(formData, c.resource)
+	c.setAuthParams(formData)
+
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.PostForm(ctx, metadata.DeviceAuthorizationEndpoint, formData, c.providerHeaders(nil))
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var da DeviceAuthorization
+	if err := resp.JSON(&da); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization response: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, errors.New("device authorization response is missing required fields")
+	}
+	audit(c.serverURLHash, auditAuthStarted, "device")
+	return &da, nil
+}
+
+// PollDeviceToken polls the token endpoint until the user approves or denies
+// the device authorization, or it expires (RFC 8628 §3.4-3.5).
+func (c *Coordinator) PollDeviceToken(ctx context.Context, da *DeviceAuthorization) (*Tokens, error) {
+	if c.serverMetadata == nil || c.clientInfo == nil {
+		return nil, errors.New("auth not initialized")
+	}
+
+	interval := defaultDevicePollInterval
+	if da.Interval > 0 {
+		interval = time.Duration(da.Interval) * time.Second
+	}
+	if da.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(da.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	formData := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {da.DeviceCode},
+		"client_id":   {c.clientInfo.ClientID},
+	}
+	c.setResource(formData, c.resource)
+	c.setAuthParams(formData)
+
+	client := c.httpClient()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("device code expired before authorization completed")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		// Assertions are single-use, so every poll authenticates anew.
+		headers, err := c.authenticateClient(c.serverMetadata, c.clientInfo, formData)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.PostForm(ctx, c.serverMetadata.TokenEndpoint, formData, headers)
+		if err != nil {
+			if resp == nil {
+				return nil, fmt.Errorf("device token request failed: %w", err)
+			}
+			var oe oauthError
+			_ = json.Unmarshal(resp.BodyBytes, &oe)
+			_ = resp.SafeClose()
+
+			switch oe.Error {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += deviceSlowDownStep
+				continue
+			case "access_denied":
+				return nil, errors.New("device authorization was denied")
+			case "expired_token":
+				return nil, errors.New("device code expired before authorization completed")
+			default:
+				return nil, fmt.Errorf("device token request failed: %w", err)
+			}
+		}
+
+		var tokens Tokens
+		jsonErr := resp.JSON(&tokens)
+		_ = resp.SafeClose()
+		if jsonErr != nil {
+			return nil, fmt.Errorf("failed to parse token response: %w", jsonErr)
+		}
+		if tokens.AccessToken == "" {
+			return nil, errors.New("token response did not include an access token")
+		}
+		if err := c.verifyIDToken(ctx, c.serverMetadata, c.clientInfo.ClientID, &tokens, ""); err != nil {
+			return nil, err
+		}
+		return &tokens, nil
+	}
+}
+
+// registrationGrantTypes returns the grant types requested during dynamic
+// client registration for the configured flow.
+func (c *Coordinator) registrationGrantTypes() []string {
+	if c.flow == AuthFlowDevice {
+		return []string{deviceCodeGrantType}
+	}
+	return []string{"authorization_code"}
+}
+
+// clientInfoSupportsFlow reports whether a cached registration may be used
+// with the configured flow. Registrations that did not record their grant
+// types are assumed to support it.
+func (c *Coordinator) clientInfoSupportsFlow(clientInfo *ClientInfo) bool {
+	if len(clientInfo.GrantTypes) == 0 {
+		return true
+	}
+	want := c.registrationGrantTypes()[0]
+	for _, gt := range clientInfo.GrantTypes {
+		if gt == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newDeviceServer returns an authorization server that issues a device code
+// and answers token polls with the given sequence of OAuth error codes before
+// issuing tokens. An empty entry means success.
+func newDeviceServer(t *testing.T, pollResults []string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/device":
+			if r.Form.Get("client_id") != "device-client" || r.Form.Get("scope") != "mcp offline_access" {
+				http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "dev-code",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://auth.example.com/device",
+				"expires_in":       60,
+				"interval":         1,
+			})
+		case "/token":
+			if r.Form.Get("grant_type") != deviceCodeGrantType || r.Form.Get("device_code") != "dev-code" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			n := int(polls.Add(1))
+			if n <= len(pollResults) && pollResults[n-1] != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": pollResults[n-1]})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "device-access-token",
+				"refresh_token": "device-refresh-token",
+				"expires_in":    3600,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &polls
+}
+
+func newDeviceCoordinator(t *testing.T, server *httptest.Server) *Coordinator {
+	t.Helper()
+	coordinator, err := NewCoordinator("device-flow", 3334, WithAuthFlow(AuthFlowDevice))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.saveServerMetadata(&ServerMetadata{
+		TokenEndpoint:               server.URL + "/token",
+		DeviceAuthorizationEndpoint: server.URL + "/device",
+	}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+	if err := coordinator.saveClientInfo(&ClientInfo{ClientID: "device-client"}); err != nil {
+		t.Fatalf("saveClientInfo failed: %v", err)
+	}
+	return coordinator
+}
+
+func TestDeviceAuthFlow(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server, polls := newDeviceServer(t, []string{"authorization_pending"})
+	coordinator := newDeviceCoordinator(t, server)
+
+	da, err := coordinator.StartDeviceAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("StartDeviceAuth failed: %v", err)
+	}
+	if da.UserCode != "ABCD-EFGH" || da.VerificationURI != "https://auth.example.com/device" {
+		t.Errorf("Unexpected device authorization: %+v", da)
+	}
+	if coordinator.callbackServer != nil {
+		t.Error("Expected no callback server for the device flow")
+	}
+
+	tokens, err := coordinator.PollDeviceToken(t.Context(), da)
+	if err != nil {
+		t.Fatalf("PollDeviceToken failed: %v", err)
+	}
+	if tokens.AccessToken != "device-access-token" {
+		t.Errorf("Expected device-access-token, got %q", tokens.AccessToken)
+	}
+	if polls.Load() != 2 {
+		t.Errorf("Expected 2 token polls, got %d", polls.Load())
+	}
+}
+
+func TestDeviceAuthDenied(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server, _ := newDeviceServer(t, []string{"access_denied"})
+	coordinator := newDeviceCoordinator(t, server)
+
+	da, err := coordinator.StartDeviceAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("StartDeviceAuth failed: %v", err)
+	}
+	_, err = coordinator.PollDeviceToken(t.Context(), da)
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("Expected denied error, got %v", err)
+	}
+}
+
+func TestClientInfoSupportsFlow(t *testing.T) {
+	device := &Coordinator{flow: AuthFlowDevice}
+	browser := &Coordinator{}
+
+	legacy := &ClientInfo{ClientID: "legacy"}
+	if !device.clientInfoSupportsFlow(legacy) || !browser.clientInfoSupportsFlow(legacy) {
+		t.Error("Expected registrations without grant types to be reused")
+	}
+
+	codeOnly := &ClientInfo{ClientID: "code", GrantTypes: []string{"authorization_code", "refresh_token"}}
+	if device.clientInfoSupportsFlow(codeOnly) {
+		t.Error("Expected authorization_code registration to be rejected for the device flow")
+	}
+	if !browser.clientInfoSupportsFlow(codeOnly) {
+		t.Error("Expected authorization_code registration to be reused for the browser flow")
+	}
+}
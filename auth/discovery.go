@@ -3,8 +3,9 @@ package auth
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
+	"strings"
 
 	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
@@ -42,20 +43,11 @@ func (s *StandardOAuthDiscovery) Name() string {
 }
 
 func (s *StandardOAuthDiscovery) Discover(ctx context.Context, serverURL string) (*ServerMetadata, error) {
-	wellKnownURL, err := s.buildWellKnownURL(serverURL, "oauth-authorization-server")
+	candidates, err := wellKnownURLs(serverURL, "oauth-authorization-server", false)
 	if err != nil {
 		return nil, err
 	}
-
-	return s.fetchMetadata(ctx, wellKnownURL)
-}
-
-func (s *StandardOAuthDiscovery) buildWellKnownURL(serverURL, endpoint string) (string, error) {
-	parsed, err := url.Parse(serverURL)
-	if err != nil {
-		return "", fmt.Errorf("invalid server URL: %w", err)
-	}
-	return fmt.Sprintf("%s://%s/.well-known/%s", parsed.Scheme, parsed.Host, endpoint), nil
+	return fetchFirst(ctx, candidates, s.fetchMetadata)
 }
 
 func (s *StandardOAuthDiscovery) fetchMetadata(ctx context.Context, metadataURL string) (*ServerMetadata, error) {
@@ -88,20 +80,11 @@ func (o *OpenIDConnectDiscovery) Name() string {
 }
 
 func (o *OpenIDConnectDiscovery) Discover(ctx context.Context, serverURL string) (*ServerMetadata, error) {
-	wellKnownURL, err := o.buildWellKnownURL(serverURL)
+	candidates, err := wellKnownURLs(serverURL, "openid-configuration", true)
 	if err != nil {
 		return nil, err
 	}
-
-	return o.fetchMetadata(ctx, wellKnownURL)
-}
-
-func (o *OpenIDConnectDiscovery) buildWellKnownURL(serverURL string) (string, error) {
-	parsed, err := url.Parse(serverURL)
-	if err != nil {
-		return "", fmt.Errorf("invalid server URL: %w", err)
-	}
-	return fmt.Sprintf("%s://%s/.well-known/openid-configuration", parsed.Scheme, parsed.Host), nil
+	return fetchFirst(ctx, candidates, o.fetchMetadata)
 }
 
 func (o *OpenIDConnectDiscovery) fetchMetadata(ctx context.Context, metadataURL string) (*ServerMetadata, error) {
@@ -119,6 +102,46 @@ func (o *OpenIDConnectDiscovery) fetchMetadata(ctx context.Context, metadataURL
 	return &metadata, nil
 }
 
+// wellKnownURLs returns the metadata URLs to try for issuer, most specific
+// first. For an issuer with a path, the well-known segment is inserted
+// before the path (RFC 8414 §3.1) and, with appendPath, also appended to it
+// (OpenID Connect Discovery §4.1); the host root comes last, for servers
+// that publish a single document.
+func wellKnownURLs(issuer, name string, appendPath bool) ([]string, error) {
+	parsed, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	root := fmt.Sprintf("%s://%s/.well-known/%s", parsed.Scheme, parsed.Host, name)
+
+	path := strings.TrimSuffix(parsed.EscapedPath(), "/")
+	if path == "" {
+		return []string{root}, nil
+	}
+	urls := []string{root + path}
+	if appendPath {
+		urls = append(urls, fmt.Sprintf("%s://%s%s/.well-known/%s", parsed.Scheme, parsed.Host, path, name))
+	}
+	return append(urls, root), nil
+}
+
+// fetchFirst returns the metadata from the first of urls that fetch succeeds
+// for.
+func fetchFirst(ctx context.Context, urls []string, fetch func(context.Context, string) (*ServerMetadata, error)) (*ServerMetadata, error) {
+	var lastErr error
+	for _, u := range urls {
+		metadata, err := fetch(ctx, u)
+		if err == nil {
+			return metadata, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
 func validatePRMURL(raw string) error {
 	parsed, err := url.Parse(raw)
 	if err != nil {
@@ -162,33 +185,8 @@ func (p *ProtectedResourceDiscovery) Name() string {
 }
 
 func (p *ProtectedResourceDiscovery) Discover(ctx context.Context, serverURL string) (*ServerMetadata, error) {
-	wellKnownURL := p.metadataURL
-	if wellKnownURL == "" {
-		var err error
-		wellKnownURL, err = ProtectedResourceWellKnownURL(serverURL)
-		if err != nil {
-			return nil, fmt.Errorf("invalid server URL: %w", err)
-		}
-	} else {
-		// The PRM URL came from an untrusted WWW-Authenticate header; reject
-		// non-absolute or non-http(s) values rather than fetching them.
-		if err := validatePRMURL(wellKnownURL); err != nil {
-			return nil, err
-		}
-	}
-
-	resp, err := p.client.Get(ctx, wellKnownURL, nil)
+	prm, err := p.fetchProtectedResource(ctx, serverURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch protected resource metadata from %s: %w", wellKnownURL, err)
-	}
-	defer func() { _ = resp.SafeClose() }()
-
-	var prm ProtectedResourceMetadata
-	if err := resp.JSON(&prm); err != nil {
-		return nil, fmt.Errorf("failed to parse protected resource metadata from %s: %w", wellKnownURL, err)
-	}
-
-	if err := ValidatePRMResource(prm.Resource, serverURL); err != nil {
 		return nil, err
 	}
 
@@ -216,6 +214,71 @@ func (p *ProtectedResourceDiscovery) Discover(ctx context.Context, serverURL str
 	return nil, fmt.Errorf("failed to discover OAuth metadata from any authorization server listed in protected resource metadata")
 }
 
+// fetchProtectedResource fetches the PRM document for serverURL. Without a
+// URL from WWW-Authenticate, the path-specific well-known URL is tried
+// first and the host root second (RFC 9728 §3.1); a root document may
+// describe either the server or its origin.
+func (p *ProtectedResourceDiscovery) fetchProtectedResource(ctx context.Context, serverURL string) (*ProtectedResourceMetadata, error) {
+	if p.metadataURL != "" {
+		// The PRM URL came from an untrusted WWW-Authenticate header; reject
+		// non-absolute or non-http(s) values rather than fetching them.
+		if err := validatePRMURL(p.metadataURL); err != nil {
+			return nil, err
+		}
+		return p.fetchPRM(ctx, p.metadataURL, serverURL)
+	}
+
+	wellKnownURL, err := ProtectedResourceWellKnownURL(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	prm, err := p.fetchPRM(ctx, wellKnownURL, serverURL)
+	if err == nil || ctx.Err() != nil {
+		return prm, err
+	}
+
+	origin, originErr := ProtectedResourceWellKnownURL(resourceOrigin(serverURL))
+	if originErr != nil || origin == wellKnownURL {
+		return nil, err
+	}
+	prm, rootErr := p.fetchPRM(ctx, origin, serverURL, resourceOrigin(serverURL))
+	if rootErr != nil {
+		return nil, err
+	}
+	return prm, nil
+}
+
+// fetchPRM fetches and parses the PRM document at metadataURL, requiring its
+// resource to match one of resources.
+func (p *ProtectedResourceDiscovery) fetchPRM(ctx context.Context, metadataURL string, resources ...string) (*ProtectedResourceMetadata, error) {
+	resp, err := p.client.Get(ctx, metadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch protected resource metadata from %s: %w", metadataURL, err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var prm ProtectedResourceMetadata
+	if err := resp.JSON(&prm); err != nil {
+		return nil, fmt.Errorf("failed to parse protected resource metadata from %s: %w", metadataURL, err)
+	}
+
+	for _, resource := range resources {
+		if err = ValidatePRMResource(prm.Resource, resource); err == nil {
+			return &prm, nil
+		}
+	}
+	return nil, err
+}
+
+// resourceOrigin returns the scheme and host of serverURL.
+func resourceOrigin(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return serverURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
 // FallbackDiscovery creates metadata based on common endpoint patterns
 type FallbackDiscovery struct{}
 
@@ -289,7 +352,7 @@ func (m *MetadataDiscoveryService) Discover(ctx context.Context, serverURL strin
 
 	var lastErr error
 	for _, strategy := range strategies {
-		log.Printf("Trying %s for %s", strategy.Name(), serverURL)
+		slog.Debug("trying metadata discovery", "strategy", strategy.Name(), "server", serverURL)
 
 		// Check if context is already cancelled before trying each strategy
 		select {
@@ -300,11 +363,11 @@ func (m *MetadataDiscoveryService) Discover(ctx context.Context, serverURL strin
 
 		metadata, err := strategy.Discover(ctx, serverURL)
 		if err == nil {
-			log.Printf("Successfully discovered metadata using %s", strategy.Name())
+			slog.Info("discovered server metadata", "strategy", strategy.Name())
 			return metadata, nil
 		}
 
-		log.Printf("%s failed: %v", strategy.Name(), err)
+		slog.Debug("metadata discovery failed", "strategy", strategy.Name(), "error", err)
 		lastErr = err
 
 		// If context is cancelled, return immediately instead of trying next strategy
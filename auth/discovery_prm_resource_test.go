@@ -92,3 +92,69 @@ func TestProtectedResourceDiscoveryRejectsMismatchedResource(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestProtectedResourceDiscoveryFallsBackToRootWellKnown(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/oauth-protected-resource":
+			_ = json.NewEncoder(w).Encode(ProtectedResourceMetadata{
+				Resource:             server.URL,
+				AuthorizationServers: []string{server.URL + "/tenant"},
+			})
+		case "/.well-known/oauth-authorization-server/tenant":
+			_ = json.NewEncoder(w).Encode(ServerMetadata{
+				Issuer:                server.URL + "/tenant",
+				AuthorizationEndpoint: server.URL + "/tenant/authorize",
+				TokenEndpoint:         server.URL + "/tenant/token",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	strategy := NewProtectedResourceDiscovery(*httpclient.New(nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	metadata, err := strategy.Discover(ctx, server.URL+"/mcp")
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if metadata.AuthorizationEndpoint != server.URL+"/tenant/authorize" {
+		t.Errorf("AuthorizationEndpoint = %q, want %q", metadata.AuthorizationEndpoint, server.URL+"/tenant/authorize")
+	}
+}
+
+func TestWellKnownURLs(t *testing.T) {
+	tests := []struct {
+		issuer     string
+		appendPath bool
+		want       []string
+	}{
+		{"https://as.example.com", false, []string{
+			"https://as.example.com/.well-known/oauth-authorization-server",
+		}},
+		{"https://as.example.com/tenant/", false, []string{
+			"https://as.example.com/.well-known/oauth-authorization-server/tenant",
+			"https://as.example.com/.well-known/oauth-authorization-server",
+		}},
+		{"https://as.example.com/tenant", true, []string{
+			"https://as.example.com/.well-known/oauth-authorization-server/tenant",
+			"https://as.example.com/tenant/.well-known/oauth-authorization-server",
+			"https://as.example.com/.well-known/oauth-authorization-server",
+		}},
+	}
+
+	for _, tt := range tests {
+		got, err := wellKnownURLs(tt.issuer, "oauth-authorization-server", tt.appendPath)
+		if err != nil {
+			t.Fatalf("wellKnownURLs(%q): %v", tt.issuer, err)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("wellKnownURLs(%q, %v) = %v, want %v", tt.issuer, tt.appendPath, got, tt.want)
+		}
+	}
+}
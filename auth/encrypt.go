@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// EnvStorePassphrase names the environment variable holding the passphrase
+// that encrypts the token store. Without it the OS machine ID is used.
+const EnvStorePassphrase = "MCP_REMOTE_STORE_PASSPHRASE"
+
+const (
+	// encryptedPrefix marks an encrypted document. Documents without it are
+	// read as plaintext, so enabling encryption keeps existing credentials;
+	// they are encrypted the next time they are saved.
+	encryptedPrefix = "mcp-remote-go:enc:v1:"
+
+	// saltFile holds the random salt for key derivation, shared by every
+	// server directory.
+	saltFile = "store_salt"
+
+	// passphraseIterations is the PBKDF2-SHA256 work factor (OWASP 2023).
+	passphraseIterations = 600000
+
+	keyInfo = "mcp-remote-go token store"
+)
+
+// Codec transforms credential documents on their way to and from a
+// TokenStore, e.g. to encrypt them.
+type Codec interface {
+	Encode(plaintext []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// NewCodecTokenStore returns a TokenStore that passes every document through
+// codec before saving it to store and after loading it.
+func NewCodecTokenStore(store TokenStore, codec Codec) TokenStore {
+	return &codecTokenStore{store: store, codec: codec}
+}
+
+type codecTokenStore struct {
+	store TokenStore
+	codec Codec
+}
+
+func (s *codecTokenStore) Name() string {
+	return s.store.Name()
+}
+
+func (s *codecTokenStore) Load(serverKey, name string) ([]byte, error) {
+	data, err := s.store.Load(serverKey, name)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.codec.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+func (s *codecTokenStore) Save(serverKey, name string, data []byte) error {
+	encoded, err := s.codec.Encode(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return s.store.Save(serverKey, name, encoded)
+}
+
+func (s *codecTokenStore) Delete(serverKey, name string) error {
+	return s.store.Delete(serverKey, name)
+}
+
+// NewEncryptionCodec returns a Codec that encrypts documents with
+// AES-256-GCM. The key is derived from the passphrase in
+// MCP_REMOTE_STORE_PASSPHRASE when set, and otherwise from the OS machine ID,
+// which ties the credentials to this machine without prompting.
+func NewEncryptionCodec() (Codec, error) {
+	salt, err := loadOrCreateSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	if passphrase := os.Getenv(EnvStorePassphrase); passphrase != "" {
+		key, err = pbkdf2.Key(sha256.New, passphrase, salt, passphraseIterations, 32)
+	} else {
+		var id string
+		if id, err = machineID(); err != nil {
+			return nil, err
+		}
+		key, err = hkdf.Key(sha256.New, []byte(id), salt, keyInfo, 32)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadCodec{aead: aead}, nil
+}
+
+type aeadCodec struct {
+	aead cipher.AEAD
+}
+
+func (c *aeadCodec) Encode(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (c *aeadCodec) Decode(data []byte) ([]byte, error) {
+	encoded, ok := bytes.CutPrefix(data, []byte(encryptedPrefix))
+	if !ok {
+		return data, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted document: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("invalid encrypted document: too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong passphrase or different machine?): %w", err)
+	}
+	return plaintext, nil
+}
+
+// loadOrCreateSalt returns the key derivation salt from the config
+// directory, creating it on first use.
+func loadOrCreateSalt() ([]byte, error) {
+	path := filepath.Join(getConfigDir(), saltFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Hold the lock from reading to writing, so that proxies starting
+	// together agree on one salt.
+	var salt []byte
+	err := filelock.New(path).WithLock(filelock.DefaultTimeout, func() error {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			salt, err = hex.DecodeString(strings.TrimSpace(string(data)))
+			return err
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", saltFile, err)
+		}
+
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if err := filelock.WriteFileAtomic(path, []byte(hex.EncodeToString(salt)+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", saltFile, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+var (
+	ioregUUID   = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+	machineGUID = regexp.MustCompile(`MachineGuid\s+REG_SZ\s+(\S+)`)
+)
+
+// machineID returns a stable identifier of this machine.
+func machineID() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+		if m := ioregUUID.FindSubmatch(out); err == nil && m != nil {
+			return string(m[1]), nil
+		}
+	case "windows":
+		out, err := exec.Command("reg", "query", `HKLM\SOFTWARE\Microsoft\Cryptography`, "/v", "MachineGuid").Output()
+		if m := machineGUID.FindSubmatch(out); err == nil && m != nil {
+			return string(m[1]), nil
+		}
+	default:
+		for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id", "/etc/hostid"} {
+			if data, err := os.ReadFile(path); err == nil {
+				if id := strings.TrimSpace(string(data)); id != "" {
+					return id, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("machine ID not available on %s; set %s to encrypt the token store", runtime.GOOS, EnvStorePassphrase)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedTokenStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", dir)
+	t.Setenv(EnvStorePassphrase, "correct horse")
+
+	codec, err := NewEncryptionCodec()
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	store := NewCodecTokenStore(NewFileTokenStore(), codec)
+
+	key := ServerKey("https://example.com/mcp")
+	c, err := NewCoordinator(key, 0, WithTokenStore(store))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := c.SaveTokens(&Tokens{AccessToken: "secret-access"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, key, tokensFile))
+	if err != nil {
+		t.Fatalf("Failed to read tokens file: %v", err)
+	}
+	if strings.Contains(string(raw), "secret-access") || !strings.HasPrefix(string(raw), encryptedPrefix) {
+		t.Errorf("Expected the tokens file to be encrypted, got %s", raw)
+	}
+
+	tokens, err := c.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if tokens.AccessToken != "secret-access" {
+		t.Errorf("Expected access token 'secret-access', got '%s'", tokens.AccessToken)
+	}
+
+	// Another passphrase derives another key.
+	t.Setenv(EnvStorePassphrase, "wrong")
+	wrong, err := NewEncryptionCodec()
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	if _, err := NewCodecTokenStore(NewFileTokenStore(), wrong).Load(key, tokensFile); err == nil {
+		t.Error("Expected decryption with the wrong passphrase to fail")
+	}
+}
+
+func TestEncryptionCodecReadsPlaintext(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	t.Setenv(EnvStorePassphrase, "passphrase")
+
+	codec, err := NewEncryptionCodec()
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	plain := []byte(`{"access_token":"a"}`)
+	got, err := codec.Decode(plain)
+	if err != nil || string(got) != string(plain) {
+		t.Errorf("Expected plaintext to pass through, got %q, %v", got, err)
+	}
+}
+
+func TestEncryptionCodecKeepsSalt(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	t.Setenv(EnvStorePassphrase, "passphrase")
+
+	first, err := NewEncryptionCodec()
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	sealed, err := first.Encode([]byte("data"))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	second, err := NewEncryptionCodec()
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	got, err := second.Decode(sealed)
+	if err != nil || string(got) != "data" {
+		t.Errorf("Expected a new codec to decrypt with the saved salt, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/jwt"
+)
+
+// requestsOpenID reports whether the configured scopes make the authorization
+// server an OpenID Connect provider for this flow.
+func (c *Coordinator) requestsOpenID() bool {
+	return slices.Contains(strings.Fields(c.scope()), "openid")
+}
+
+// verifyIDToken validates the ID token of a token response against the
+// provider's signing keys (OpenID Connect Core §3.1.3.7): the signature, the
+// issuer, the client as audience, expiry and, when one was sent, the nonce.
+// Responses without an ID token are left alone.
+func (c *Coordinator) verifyIDToken(ctx context.Context, metadata *ServerMetadata, clientID string, tokens *Tokens, nonce string) error {
+	if tokens.IDToken == "" {
+		return nil
+	}
+	if metadata.JWKSUri == "" {
+		return errors.New("token response included an ID token but the server metadata has no jwks_uri")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := c.httpClient().Get(ctx, metadata.JWKSUri, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys from %s: %w", metadata.JWKSUri, err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	keys, err := jwt.ParseKeySet(resp.BodyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse signing keys from %s: %w", metadata.JWKSUri, err)
+	}
+
+	claims, err := jwt.Verify(tokens.IDToken, keys, jwt.Options{
+		Issuer:   metadata.Issuer,
+		Audience: clientID,
+		Nonce:    nonce,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	slog.Info("id token verified", "iss", claims.Issuer, "sub", claims.Subject)
+	slog.Debug("id token claims", "claims", claims.Raw)
+	return nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signES256 returns an ES256 compact JWS over claims.
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "k1"})
+	payload, _ := json.Marshal(claims)
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("ecdsa.Sign: %v", err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestExchangeCodeVerifiesIDToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding
+
+	var server *httptest.Server
+	var audience, nonce string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/jwks":
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "EC", "kid": "k1", "crv": "P-256",
+				"x": b64.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y": b64.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			}}})
+		case "/token":
+			_ = json.NewEncoder(w).Encode(Tokens{
+				AccessToken: "access",
+				TokenType:   "Bearer",
+				IDToken: signES256(t, key, map[string]any{
+					"iss":   server.URL,
+					"sub":   "user-1",
+					"aud":   audience,
+					"exp":   time.Now().Add(time.Hour).Unix(),
+					"nonce": nonce,
+				}),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	coordinator, err := NewCoordinator("id-token-test", 0, WithScopes([]string{"openid", "mcp"}))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{
+		Issuer:                        server.URL,
+		AuthorizationEndpoint:         server.URL + "/authorize",
+		CodeChallengeMethodsSupported: []string{"S256"},
+		TokenEndpoint:                 server.URL + "/token",
+		JWKSUri:                       server.URL + "/jwks",
+	}
+	coordinator.clientInfo = &ClientInfo{ClientID: "client"}
+
+	authURL, err := coordinator.buildAuthorizationURL()
+	if err != nil {
+		t.Fatalf("buildAuthorizationURL failed: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	nonce = u.Query().Get("nonce")
+	if nonce == "" {
+		t.Fatal("Expected a nonce in the authorization URL when requesting openid")
+	}
+
+	audience = "client"
+	tokens, err := coordinator.ExchangeCode(t.Context(), "code")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if tokens.IDToken == "" {
+		t.Error("Expected the ID token to be kept")
+	}
+
+	audience = "another-client"
+	if _, err := coordinator.ExchangeCode(t.Context(), "code"); err == nil || !strings.Contains(err.Error(), "invalid ID token") {
+		t.Errorf("Expected an ID token for another client to be rejected, got %v", err)
+	}
+}
+
+func TestBuildAuthorizationURLOmitsNonceWithoutOpenID(t *testing.T) {
+	c, _ := newPendingCoordinator(t)
+	authURL, err := c.buildAuthorizationURL()
+	if err != nil {
+		t.Fatalf("buildAuthorizationURL failed: %v", err)
+	}
+	if strings.Contains(authURL, "nonce=") {
+		t.Errorf("Expected no nonce without the openid scope: %s", authURL)
+	}
+}
@@ -18,13 +18,14 @@ func TestOAuthFlowIntegration(t *testing.T) {
 	// Create mock OAuth server
 	authCode := "test-auth-code-123"
 	serverMetadata := &ServerMetadata{
-		Issuer:                 "https://test-server.example.com",
-		AuthorizationEndpoint:  "",
-		TokenEndpoint:          "",
-		RegistrationEndpoint:   "",
-		ScopesSupported:        []string{"mcp", "offline_access"},
-		ResponseTypesSupported: []string{"code"},
-		GrantTypesSupported:    []string{"authorization_code", "refresh_token"},
+		Issuer:                        "https://test-server.example.com",
+		AuthorizationEndpoint:         "",
+		CodeChallengeMethodsSupported: []string{"S256"},
+		TokenEndpoint:                 "",
+		RegistrationEndpoint:          "",
+		ScopesSupported:               []string{"mcp", "offline_access"},
+		ResponseTypesSupported:        []string{"code"},
+		GrantTypesSupported:           []string{"authorization_code", "refresh_token"},
 	}
 
 	var mockServer *httptest.Server
@@ -105,7 +106,7 @@ func TestOAuthFlowIntegration(t *testing.T) {
 	}
 
 	// Test initialization with server metadata discovery
-	authURL, err := coordinator.InitializeAuth(mockServer.URL)
+	authURL, err := coordinator.InitializeAuth(t.Context(), mockServer.URL)
 	if err != nil {
 		t.Fatalf("InitializeAuth failed: %v", err)
 	}
@@ -143,7 +144,7 @@ func TestOAuthFlowIntegration(t *testing.T) {
 	}
 
 	// Test token exchange
-	tokens, err := coordinator.ExchangeCode(authCode)
+	tokens, err := coordinator.ExchangeCode(t.Context(), authCode)
 	if err != nil {
 		t.Fatalf("ExchangeCode failed: %v", err)
 	}
@@ -210,14 +211,14 @@ func TestOAuthFlowWithFallbackMetadata(t *testing.T) {
 	defer func() { _ = os.Setenv("HOME", originalHome) }()
 	_ = os.Setenv("HOME", tmpDir)
 
-	// Create coordinator
-	coordinator, err := NewCoordinator("fallback-test", 3334)
+	// Create coordinator; the fallback metadata does not advertise PKCE methods
+	coordinator, err := NewCoordinator("fallback-test", 3334, WithAssumePKCE())
 	if err != nil {
 		t.Fatalf("NewCoordinator failed: %v", err)
 	}
 
 	// Test initialization with fallback metadata
-	_, err = coordinator.InitializeAuth(mockServer.URL)
+	_, err = coordinator.InitializeAuth(t.Context(), mockServer.URL)
 	if err != nil {
 		t.Fatalf("InitializeAuth with fallback failed: %v", err)
 	}
@@ -272,10 +273,11 @@ func TestAuthErrorRecovery(t *testing.T) {
 						var metadata *ServerMetadata
 						if server != nil {
 							metadata = &ServerMetadata{
-								Issuer:                server.URL,
-								AuthorizationEndpoint: server.URL + "/auth",
-								TokenEndpoint:         server.URL + "/token",
-								RegistrationEndpoint:  server.URL + "/register",
+								Issuer:                        server.URL,
+								AuthorizationEndpoint:         server.URL + "/auth",
+								CodeChallengeMethodsSupported: []string{"S256"},
+								TokenEndpoint:                 server.URL + "/token",
+								RegistrationEndpoint:          server.URL + "/register",
 							}
 						}
 						w.Header().Set("Content-Type", "application/json")
@@ -302,14 +304,14 @@ func TestAuthErrorRecovery(t *testing.T) {
 			defer func() { _ = os.Setenv("HOME", originalHome) }()
 			_ = os.Setenv("HOME", tmpDir)
 
-			// Create coordinator
-			coordinator, err := NewCoordinator("error-test", 3334)
+			// Create coordinator; the fallback metadata does not advertise PKCE methods
+			coordinator, err := NewCoordinator("error-test", 3334, WithAssumePKCE())
 			if err != nil {
 				t.Fatalf("NewCoordinator failed: %v", err)
 			}
 
 			// Test initialization
-			_, err = coordinator.InitializeAuth(mockServer.URL)
+			_, err = coordinator.InitializeAuth(t.Context(), mockServer.URL)
 
 			if tt.expectError {
 				if err == nil {
@@ -423,8 +425,9 @@ func TestCallbackServerLifecycle(t *testing.T) {
 
 	// Set up minimal metadata for callback server
 	coordinator.serverMetadata = &ServerMetadata{
-		AuthorizationEndpoint: "https://example.com/auth",
-		TokenEndpoint:         "https://example.com/token",
+		AuthorizationEndpoint:         "https://example.com/auth",
+		CodeChallengeMethodsSupported: []string{"S256"},
+		TokenEndpoint:                 "https://example.com/token",
 	}
 
 	// Test starting callback server
@@ -441,8 +444,8 @@ func TestCallbackServerLifecycle(t *testing.T) {
 	// Simulate an auth flow by creating a goroutine that will read from the callback channel
 	go func() {
 		select {
-		case code := <-coordinator.callbackChan:
-			t.Logf("Received auth code: %s", code)
+		case result := <-coordinator.callbackChan:
+			t.Logf("Received auth code: %s", result.code)
 		case <-time.After(500 * time.Millisecond):
 			// Timeout is expected for this test
 		}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// serverURLFile records the MCP server URL in the server's directory so
+// cached credentials can be listed by URL instead of by hash. It is not
+// secret and always lives on disk, whatever the token store.
+const serverURLFile = "server_url"
+
+// CachedServer describes the credentials cached for one MCP server.
+type CachedServer struct {
+	// Key is the ServerKey of the server URL and the directory name.
+	Key string
+	// URL is the server URL, or "" when the directory was created by a
+	// version that did not record it.
+	URL string
+	// Tokens is nil when no tokens are stored.
+	Tokens *Tokens
+}
+
+// IsServerKey reports whether s has the form of a ServerKey, or of the key
+// used by earlier versions, so commands can accept either a server URL or
+// the key shown by ListServers.
+func IsServerKey(s string) bool {
+	if len(s) == 64 {
+		_, err := hex.DecodeString(s)
+		return err == nil
+	}
+	i := len(s) - serverKeyHashLen - 1
+	if i < 1 || s[i] != '-' {
+		return false
+	}
+	if _, err := hex.DecodeString(s[i+1:]); err != nil {
+		return false
+	}
+	return strings.Trim(s[:i], "abcdefghijklmnopqrstuvwxyz0123456789.-") == ""
+}
+
+// ResolveServerKey returns the key of the server given by a server URL or
+// key. For a URL cached only under the key of an earlier version, that key
+// is returned so its credentials can still be found.
+func ResolveServerKey(s string) string {
+	if IsServerKey(s) {
+		return s
+	}
+	key := ServerKey(s)
+	if _, err := os.Stat(ServerDir(key)); err != nil {
+		if legacy := legacyServerKey(s); dirExists(ServerDir(legacy)) {
+			return legacy
+		}
+	}
+	return key
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ServerDir returns the directory holding the cached files of the server
+// with the given key.
+func ServerDir(serverKey string) string {
+	return filepath.Join(getConfigDir(), serverKey)
+}
+
+// SocketPath returns the Unix socket a shared proxy for the server with the
+// given key listens on, or its named pipe on Windows. Only a prefix of the
+// key is used, as socket paths are limited to about 100 bytes.
+func SocketPath(serverKey string) string {
+	hash := sha256.Sum256([]byte(serverKey))
+	name := hex.EncodeToString(hash[:8])
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\` + appDirName + "-" + name
+	}
+	return filepath.Join(getStateDir(), "sockets", name+".sock")
+}
+
+// LoadServer returns what is cached for the server with the given key. Tokens
+// are read from store.
+func LoadServer(store TokenStore, serverKey string) (*CachedServer, error) {
+	dir := ServerDir(serverKey)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("no credentials cached for %s: %w", serverKey, err)
+	}
+
+	server := &CachedServer{Key: serverKey}
+	if data, err := filelock.ReadFile(filepath.Join(dir, serverURLFile)); err == nil {
+		server.URL = strings.TrimSpace(string(data))
+	}
+
+	c := &Coordinator{serverURLHash: serverKey, store: store}
+	tokens, err := c.LoadTokens()
+	switch {
+	case err == nil:
+		server.Tokens = tokens
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return server, nil
+}
+
+// ListServers returns every server with a directory under the config
+// directory, sorted by URL.
+func ListServers(store TokenStore) ([]CachedServer, error) {
+	entries, err := os.ReadDir(getConfigDir())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var servers []CachedServer
+	for _, entry := range entries {
+		if !entry.IsDir() || !IsServerKey(entry.Name()) {
+			continue
+		}
+		server, err := LoadServer(store, entry.Name())
+		if err != nil {
+			slog.Warn("skipping unreadable server directory", "key", entry.Name(), "error", err)
+			continue
+		}
+		servers = append(servers, *server)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].URL != servers[j].URL {
+			return servers[i].URL < servers[j].URL
+		}
+		return servers[i].Key < servers[j].Key
+	})
+	return servers, nil
+}
+
+// ClearServer deletes everything cached for the server with the given key:
+// tokens and client registration from store, then the server's directory
+// with its metadata and session state.
+func ClearServer(store TokenStore, serverKey string) error {
+	return clearServer(store, serverKey, "")
+}
+
+// clearServer implements ClearServer, recording why in the audit log.
+func clearServer(store TokenStore, serverKey, reason string) error {
+	// Audited first, while the server URL can still be read.
+	audit(serverKey, auditCredentialsCleared, reason)
+	for _, name := range []string{tokensFile, clientInfoFile} {
+		if err := store.Delete(serverKey, name); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+	}
+	if err := os.RemoveAll(ServerDir(serverKey)); err != nil {
+		return fmt.Errorf("failed to remove server directory: %w", err)
+	}
+	return nil
+}
+
+// DefaultPruneAfter is how long a server's credentials may go unused before
+// PruneServers removes them.
+const DefaultPruneAfter = 90 * 24 * time.Hour
+
+// PruneServers removes every server whose files have not been written for
+// maxAge and whose access token is missing or expired, except the servers
+// with a key in keep. It returns the servers removed.
+func PruneServers(store TokenStore, maxAge time.Duration, now time.Time, keep ...string) ([]CachedServer, error) {
+	servers, err := ListServers(store)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []CachedServer
+	for _, server := range servers {
+		if slices.Contains(keep, server.Key) || tokenValid(server.Tokens, now) {
+			continue
+		}
+		if now.Sub(lastModified(ServerDir(server.Key))) < maxAge {
+			continue
+		}
+		if err := clearServer(store, server.Key, "pruned"); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", server.Key, err)
+		}
+		removed = append(removed, server)
+	}
+	return removed, nil
+}
+
+// tokenValid reports whether tokens hold an access token that has not
+// expired. A token without an expiry counts as valid.
+func tokenValid(tokens *Tokens, now time.Time) bool {
+	return tokens != nil && tokens.AccessToken != "" && (tokens.ExpiresAt == 0 || tokens.ExpiresAt > now.Unix())
+}
+
+// lastModified returns the latest modification time of the files in dir,
+// or of dir itself when it is empty. Lock files are ignored, as reading a
+// file takes its lock.
+func lastModified(dir string) time.Time {
+	var latest time.Time
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".lock") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if latest.IsZero() {
+		if info, err := os.Stat(dir); err == nil {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// saveServerURL records serverURL in the server's directory.
+func (c *Coordinator) saveServerURL(serverURL string) {
+	path := filepath.Join(ServerDir(c.serverURLHash), serverURLFile)
+	if err := filelock.WriteFile(path, []byte(serverURL+"\n"), 0600); err != nil {
+		slog.Warn("failed to record server URL", "error", err)
+	}
+}
+
+// MigrateLegacyKey moves what is cached for serverURL under the key used by
+// earlier versions, a bare hash of the URL, to the coordinator's key. Files
+// already present under the new key are kept.
+func (c *Coordinator) MigrateLegacyKey(serverURL string) {
+	legacy := legacyServerKey(serverURL)
+	if c.serverURLHash != ServerKey(serverURL) {
+		return
+	}
+	legacyDir, dir := ServerDir(legacy), ServerDir(c.serverURLHash)
+	entries, err := os.ReadDir(legacyDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		to := filepath.Join(dir, entry.Name())
+		if _, err := os.Lstat(to); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(legacyDir, entry.Name()), to); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to move cached file", "file", entry.Name(), "error", err)
+		}
+	}
+	// Documents in the OS credential store are addressed by key as well.
+	for _, name := range []string{tokensFile, clientInfoFile} {
+		if _, err := c.store.Load(c.serverURLHash, name); err == nil {
+			continue
+		}
+		data, err := c.store.Load(legacy, name)
+		if err != nil {
+			continue
+		}
+		if err := c.store.Save(c.serverURLHash, name, data); err != nil {
+			slog.Warn("failed to move cached credentials", "document", name, "error", err)
+			continue
+		}
+		_ = c.store.Delete(legacy, name)
+	}
+	// Only succeeds once the directory is empty.
+	_ = os.Remove(legacyDir)
+	slog.Info("moved cached credentials to new directory", "from", legacyDir, "to", dir)
+}
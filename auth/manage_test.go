@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListServers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", dir)
+	store := NewFileTokenStore()
+
+	const serverURL = "https://example.com/mcp"
+	c, err := NewCoordinator(ServerKey(serverURL), 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.saveServerURL(serverURL)
+	if err := c.SaveTokens(&Tokens{AccessToken: "access", ExpiresAt: 1700000000}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	// A directory from an older version, without a recorded URL or tokens.
+	legacy := ServerKey("https://legacy.example.com/mcp")
+	if _, err := NewCoordinator(legacy, 0); err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	// Unrelated entries are ignored.
+	if err := os.Mkdir(filepath.Join(dir, "not-a-server"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	servers, err := ListServers(store)
+	if err != nil {
+		t.Fatalf("ListServers failed: %v", err)
+	}
+	if len(servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %d: %+v", len(servers), servers)
+	}
+	if servers[0].Key != legacy || servers[0].URL != "" || servers[0].Tokens != nil {
+		t.Errorf("Expected the legacy server without URL or tokens first, got %+v", servers[0])
+	}
+	if servers[1].URL != serverURL || servers[1].Tokens == nil || servers[1].Tokens.ExpiresAt != 1700000000 {
+		t.Errorf("Expected %s with tokens, got %+v", serverURL, servers[1])
+	}
+}
+
+func TestListServersWithoutConfigDir(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", filepath.Join(t.TempDir(), "missing"))
+
+	servers, err := ListServers(NewFileTokenStore())
+	if err != nil || len(servers) != 0 {
+		t.Errorf("Expected no servers and no error, got %v, %v", servers, err)
+	}
+}
+
+func TestClearServer(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	store := NewFileTokenStore()
+
+	key := ServerKey("https://example.com/mcp")
+	c, err := NewCoordinator(key, 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := c.SaveTokens(&Tokens{AccessToken: "access"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if err := c.saveClientInfo(&ClientInfo{ClientID: "client"}); err != nil {
+		t.Fatalf("saveClientInfo failed: %v", err)
+	}
+
+	if err := ClearServer(store, key); err != nil {
+		t.Fatalf("ClearServer failed: %v", err)
+	}
+	if _, err := LoadServer(store, key); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the server to be gone, got %v", err)
+	}
+}
+
+func TestIsServerKey(t *testing.T) {
+	if !IsServerKey(ServerKey("https://example.com/mcp")) {
+		t.Error("Expected a ServerKey to be recognized")
+	}
+	if !IsServerKey(legacyServerKey("https://example.com/mcp")) {
+		t.Error("Expected a key of an earlier version to be recognized")
+	}
+	for _, s := range []string{"", "https://example.com/mcp", "zz" + legacyServerKey("x")[2:], "example.com-zz3f2a9c01b4"} {
+		if IsServerKey(s) {
+			t.Errorf("Expected %q not to be a server key", s)
+		}
+	}
+}
+
+func TestServerKey(t *testing.T) {
+	tests := map[string]string{
+		"https://MCP.Example.com/mcp":                 "mcp.example.com-",
+		"http://localhost:8080/sse":                   "localhost-",
+		"wss://[::1]:9000/ws":                         "1-",
+		"not a url":                                   "server-",
+		"https://" + strings.Repeat("a", 60) + ".com": strings.Repeat("a", 48) + "-",
+	}
+	for serverURL, prefix := range tests {
+		key := ServerKey(serverURL)
+		if !strings.HasPrefix(key, prefix) || len(key) != len(prefix)+serverKeyHashLen || !IsServerKey(key) {
+			t.Errorf("%s: expected a key starting with %q, got %q", serverURL, prefix, key)
+		}
+	}
+	if ServerKey("https://example.com/a") == ServerKey("https://example.com/b") {
+		t.Error("Expected different URLs on one host to get different keys")
+	}
+}
+
+func TestProfileServerKey(t *testing.T) {
+	const serverURL = "https://example.com/mcp"
+	if ProfileServerKey(serverURL, "") != ServerKey(serverURL) {
+		t.Error("Expected no profile to give the ServerKey")
+	}
+	work := ProfileServerKey(serverURL, "work")
+	if !strings.HasPrefix(work, "example.com-work-") || !IsServerKey(work) {
+		t.Errorf("Expected a server key naming the profile, got %q", work)
+	}
+	if work == ProfileServerKey(serverURL, "personal") || work == ServerKey(serverURL) {
+		t.Error("Expected each profile to get its own key")
+	}
+	if work == ProfileServerKey("https://example.com/other", "work") {
+		t.Error("Expected a profile to get different keys for different URLs")
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	for _, profile := range []string{"work", "team-2", "0"} {
+		if err := ValidateProfile(profile); err != nil {
+			t.Errorf("ValidateProfile(%q) failed: %v", profile, err)
+		}
+	}
+	for _, profile := range []string{"", "Work", "-work", "a/b", "a.b", strings.Repeat("a", maxProfileLen+1)} {
+		if err := ValidateProfile(profile); err == nil {
+			t.Errorf("Expected ValidateProfile(%q) to fail", profile)
+		}
+	}
+}
+
+func TestMigrateLegacyKey(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	const serverURL = "https://example.com/mcp"
+	legacy := legacyServerKey(serverURL)
+
+	old, err := NewCoordinator(legacy, 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := old.SaveTokens(&Tokens{AccessToken: "access"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if got := ResolveServerKey(serverURL); got != legacy {
+		t.Errorf("Expected the URL to resolve to the legacy key before migrating, got %s", got)
+	}
+
+	c, err := NewCoordinator(ServerKey(serverURL), 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.MigrateLegacyKey(serverURL)
+	tokens, err := c.LoadTokens()
+	if err != nil || tokens.AccessToken != "access" {
+		t.Fatalf("Expected the tokens under the new key, got %+v, %v", tokens, err)
+	}
+	if _, err := os.Stat(ServerDir(legacy)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the legacy directory removed, got %v", err)
+	}
+	if got := ResolveServerKey(serverURL); got != ServerKey(serverURL) {
+		t.Errorf("Expected the URL to resolve to the new key, got %s", got)
+	}
+}
+
+func TestPruneServers(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	store := NewFileTokenStore()
+	now := time.Now()
+	old := now.Add(-2 * DefaultPruneAfter)
+
+	seed := func(serverURL string, tokens *Tokens, modified time.Time) string {
+		t.Helper()
+		key := ServerKey(serverURL)
+		c, err := NewCoordinator(key, 0)
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		if err := c.SaveTokens(tokens); err != nil {
+			t.Fatalf("SaveTokens failed: %v", err)
+		}
+		for _, path := range []string{filepath.Join(ServerDir(key), tokensFile), ServerDir(key)} {
+			if err := os.Chtimes(path, modified, modified); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return key
+	}
+	expired := now.Add(-time.Hour).Unix()
+	stale := seed("https://stale.example.com/mcp", &Tokens{AccessToken: "a", ExpiresAt: expired}, old)
+	recent := seed("https://recent.example.com/mcp", &Tokens{AccessToken: "a", ExpiresAt: expired}, now)
+	valid := seed("https://valid.example.com/mcp", &Tokens{AccessToken: "a", ExpiresAt: now.Add(time.Hour).Unix()}, old)
+	kept := seed("https://kept.example.com/mcp", &Tokens{AccessToken: "a", ExpiresAt: expired}, old)
+
+	removed, err := PruneServers(store, DefaultPruneAfter, now, kept)
+	if err != nil {
+		t.Fatalf("PruneServers failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Key != stale {
+		t.Fatalf("Expected only %s removed, got %+v", stale, removed)
+	}
+	for _, key := range []string{recent, valid, kept} {
+		if _, err := LoadServer(store, key); err != nil {
+			t.Errorf("Expected %s kept, got %v", key, err)
+		}
+	}
+}
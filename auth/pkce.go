@@ -4,6 +4,11 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 )
 
 const (
@@ -37,3 +42,53 @@ func ComputeCodeChallenge(verifier string) string {
 	h := sha256.Sum256([]byte(verifier))
 	return base64.RawURLEncoding.EncodeToString(h[:])
 }
+
+// stateLength is the number of random bytes in the OAuth state parameter.
+const stateLength = 32
+
+// GenerateState generates a cryptographically random OAuth state parameter
+// binding the authorization response to this client (RFC 6749 §10.12).
+func GenerateState() (string, error) {
+	b := make([]byte, stateLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ErrPKCENotAdvertised is returned by InitializeAuth when the authorization
+// server metadata lacks code_challenge_methods_supported, so PKCE support
+// cannot be verified. WithAssumePKCE proceeds regardless.
+var ErrPKCENotAdvertised = errors.New("authorization server metadata does not advertise code_challenge_methods_supported, so PKCE support cannot be verified")
+
+// WithAssumePKCE lets the browser flow proceed with S256 against
+// authorization servers that do not advertise their PKCE methods, for
+// servers that omit the field while enforcing PKCE.
+func WithAssumePKCE() CoordinatorOption {
+	return func(c *Coordinator) {
+		c.assumePKCE = true
+	}
+}
+
+// supportsS256 reports whether the authorization server advertises S256
+// code challenges.
+func (m *ServerMetadata) supportsS256() bool {
+	return slices.Contains(m.CodeChallengeMethodsSupported, "S256")
+}
+
+// checkPKCE returns an error unless the authorization server accepts S256
+// code challenges. Servers that do not advertise their PKCE methods are
+// refused unless WithAssumePKCE is set.
+func (c *Coordinator) checkPKCE(m *ServerMetadata) error {
+	if m.supportsS256() {
+		return nil
+	}
+	if len(m.CodeChallengeMethodsSupported) > 0 {
+		return fmt.Errorf("authorization server does not support PKCE with S256 (supports %s)", strings.Join(m.CodeChallengeMethodsSupported, ", "))
+	}
+	if !c.assumePKCE {
+		return ErrPKCENotAdvertised
+	}
+	slog.Warn("authorization server does not advertise PKCE methods, assuming S256")
+	return nil
+}
@@ -3,6 +3,10 @@ package auth
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -86,3 +90,102 @@ func TestComputeCodeChallengeIsBase64URLNoPadding(t *testing.T) {
 		t.Error("Challenge should use URL-safe base64 encoding")
 	}
 }
+
+func TestCoordinatorPKCERoundTrip(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var challenge string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ComputeCodeChallenge(r.Form.Get("code_verifier")) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer"}`))
+	}))
+	defer tokenServer.Close()
+
+	c, err := NewCoordinator("pkce-test", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.serverMetadata = &ServerMetadata{
+		AuthorizationEndpoint:         "https://auth.example.com/authorize",
+		TokenEndpoint:                 tokenServer.URL,
+		CodeChallengeMethodsSupported: []string{"S256"},
+	}
+	c.clientInfo = &ClientInfo{ClientID: "client"}
+
+	authURL, err := c.buildAuthorizationURL()
+	if err != nil {
+		t.Fatalf("buildAuthorizationURL failed: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if got := u.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("Expected code_challenge_method S256, got %q", got)
+	}
+	challenge = u.Query().Get("code_challenge")
+
+	tokens, err := c.ExchangeCode(t.Context(), "code")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if tokens.AccessToken != "token" {
+		t.Errorf("Expected access token 'token', got %q", tokens.AccessToken)
+	}
+}
+
+func TestBuildAuthorizationURLRequiresS256(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	c, err := NewCoordinator("pkce-plain-test", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	c.serverMetadata = &ServerMetadata{
+		AuthorizationEndpoint:         "https://auth.example.com/authorize",
+		CodeChallengeMethodsSupported: []string{"plain"},
+	}
+	c.clientInfo = &ClientInfo{ClientID: "client"}
+
+	if _, err := c.buildAuthorizationURL(); err == nil || !strings.Contains(err.Error(), "S256") {
+		t.Errorf("Expected an error for a server without S256 support, got %v", err)
+	}
+}
+
+func TestInitializeAuthRequiresAdvertisedPKCE(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	metadata := &ServerMetadata{
+		AuthorizationEndpoint: "https://auth.example.com/authorize",
+		TokenEndpoint:         "https://auth.example.com/token",
+	}
+
+	c, err := NewCoordinator("pkce-unadvertised-test", 0, WithClient("client", ""))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	defer c.Close()
+	if err := c.saveServerMetadata(metadata); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+	if _, err := c.InitializeAuth(t.Context(), "https://mcp.example.com/mcp"); !errors.Is(err, ErrPKCENotAdvertised) {
+		t.Fatalf("Expected ErrPKCENotAdvertised, got %v", err)
+	}
+	if c.callbackServer != nil {
+		t.Error("Expected the flow to stop before starting the callback server")
+	}
+
+	// With the opt-in, S256 is assumed.
+	WithAssumePKCE()(c)
+	authURL, err := c.InitializeAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("InitializeAuth failed: %v", err)
+	}
+	if u, _ := url.Parse(authURL); u.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("Expected an S256 code challenge, got %s", authURL)
+	}
+}
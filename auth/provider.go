@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+)
+
+// Provider is a profile for an authorization server whose discovery or
+// behavior deviates from RFC 8414, so that the usual discovery and dynamic
+// client registration do not work against it. Its metadata is used instead
+// of discovery; clients are registered with the provider beforehand and set
+// with WithClient.
+type Provider struct {
+	// Name identifies the provider, e.g. "github".
+	Name string
+	// Metadata replaces the discovered server metadata.
+	Metadata ServerMetadata
+	// Scopes are requested when no scopes are configured.
+	Scopes []string
+	// ResourceParameter is the parameter carrying the resource indicator:
+	// "resource" for RFC 8707, "audience" for Auth0. It is empty for
+	// providers that reject resource indicators.
+	ResourceParameter string
+	// AuthorizationParams are added to the authorization request.
+	AuthorizationParams map[string]string
+	// Headers are sent with token and device authorization requests.
+	Headers map[string]string
+}
+
+// ProviderNames lists the providers LookupProvider knows.
+var ProviderNames = []string{"github", "google", "azure-ad", "auth0"}
+
+// LookupProvider returns the provider profile for spec, a provider name
+// optionally followed by ":" and a parameter: the tenant for azure-ad
+// ("common" when omitted) and the tenant domain for auth0, which requires it.
+func LookupProvider(spec string) (*Provider, error) {
+	name, param, _ := strings.Cut(spec, ":")
+	switch strings.ToLower(name) {
+	case "github":
+		return githubProvider(), nil
+	case "google":
+		return googleProvider(), nil
+	case "azure-ad", "azure", "entra":
+		if param == "" {
+			param = "common"
+		}
+		return azureADProvider(param), nil
+	case "auth0":
+		if param == "" {
+			return nil, errors.New("auth0 requires the tenant domain, e.g. auth0:example.us.auth0.com")
+		}
+		return auth0Provider(param)
+	}
+	return nil, fmt.Errorf("unknown provider %q (known: %s)", name, strings.Join(ProviderNames, ", "))
+}
+
+func githubProvider() *Provider {
+	return &Provider{
+		Name: "github",
+		Metadata: ServerMetadata{
+			Issuer:                            "https://github.com/login/oauth",
+			AuthorizationEndpoint:             "https://github.com/login/oauth/authorize",
+			TokenEndpoint:                     "https://github.com/login/oauth/access_token",
+			DeviceAuthorizationEndpoint:       "https://github.com/login/device/code",
+			CodeChallengeMethodsSupported:     []string{"S256"},
+			TokenEndpointAuthMethodsSupported: []string{TokenAuthClientSecretPost},
+		},
+		Scopes: []string{"repo", "read:org"},
+		// GitHub answers token requests form-encoded unless asked for JSON.
+		Headers: map[string]string{"Accept": "application/json"},
+	}
+}
+
+func googleProvider() *Provider {
+	return &Provider{
+		Name: "google",
+		Metadata: ServerMetadata{
+			Issuer:                            "https://accounts.google.com",
+			AuthorizationEndpoint:             "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenEndpoint:                     "https://oauth2.googleapis.com/token",
+			DeviceAuthorizationEndpoint:       "https://oauth2.googleapis.com/device/code",
+			RevocationEndpoint:                "https://oauth2.googleapis.com/revoke",
+			JWKSUri:                           "https://www.googleapis.com/oauth2/v3/certs",
+			CodeChallengeMethodsSupported:     []string{"S256"},
+			TokenEndpointAuthMethodsSupported: []string{TokenAuthClientSecretPost, TokenAuthClientSecretBasic},
+		},
+		Scopes: []string{"openid", "email", "profile"},
+		// Google issues refresh tokens only for offline access, and only
+		// on the first consent unless asked again.
+		AuthorizationParams: map[string]string{"access_type": "offline", "prompt": "consent"},
+	}
+}
+
+// azureADProvider returns the Microsoft identity platform (v2.0) profile
+// for tenant. The v2.0 endpoints reject RFC 8707 resource indicators; the
+// resource is named by its scopes instead, e.g. "api://<app-id>/.default".
+// The ID token issuer names the user's tenant ID, which is not known for
+// the common, organizations and consumers tenants, so it is not checked.
+func azureADProvider(tenant string) *Provider {
+	base := "https://login.microsoftonline.com/" + url.PathEscape(tenant)
+	return &Provider{
+		Name: "azure-ad",
+		Metadata: ServerMetadata{
+			AuthorizationEndpoint:             base + "/oauth2/v2.0/authorize",
+			TokenEndpoint:                     base + "/oauth2/v2.0/token",
+			DeviceAuthorizationEndpoint:       base + "/oauth2/v2.0/devicecode",
+			JWKSUri:                           base + "/discovery/v2.0/keys",
+			CodeChallengeMethodsSupported:     []string{"S256"},
+			TokenEndpointAuthMethodsSupported: []string{TokenAuthClientSecretPost, TokenAuthClientSecretBasic, TokenAuthPrivateKeyJWT},
+		},
+		Scopes: []string{"openid", "profile", "offline_access"},
+	}
+}
+
+// auth0Provider returns the profile for the Auth0 tenant at domain. Auth0
+// takes the API an access token is for as the audience parameter.
+func auth0Provider(domain string) (*Provider, error) {
+	u, err := url.Parse("https://" + strings.TrimPrefix(domain, "https://"))
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid auth0 domain %q", domain)
+	}
+	base := "https://" + u.Host
+	return &Provider{
+		Name: "auth0",
+		Metadata: ServerMetadata{
+			Issuer:                            base + "/",
+			AuthorizationEndpoint:             base + "/authorize",
+			TokenEndpoint:                     base + "/oauth/token",
+			RegistrationEndpoint:              base + "/oidc/register",
+			DeviceAuthorizationEndpoint:       base + "/oauth/device/code",
+			RevocationEndpoint:                base + "/oauth/revoke",
+			JWKSUri:                           base + "/.well-known/jwks.json",
+			CodeChallengeMethodsSupported:     []string{"S256"},
+			TokenEndpointAuthMethodsSupported: []string{TokenAuthClientSecretPost, TokenAuthClientSecretBasic, TokenAuthPrivateKeyJWT},
+		},
+		Scopes:            []string{"openid", "profile", "email", "offline_access"},
+		ResourceParameter: "audience",
+	}, nil
+}
+
+// WithProvider uses the profile p instead of discovering the authorization
+// server. Its scopes are requested unless WithScopes sets others.
+func WithProvider(p *Provider) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.provider = p
+	}
+}
+
+// WithClient sets a client registered with the authorization server
+// beforehand, used by the browser and device flows instead of a cached or
+// dynamically registered one. The secret, which public clients leave
+// empty, is kept in memory only.
+func WithClient(clientID, clientSecret string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.staticClient = &ClientInfo{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+		}
+		// Refreshing and revoking tokens saved by an earlier run use it too.
+		c.clientInfo = c.staticClient
+	}
+}
+
+// providerMetadata returns a copy of the configured provider's metadata.
+func (c *Coordinator) providerMetadata() *ServerMetadata {
+	metadata := c.provider.Metadata
+	return &metadata
+}
+
+// resourceParameter returns the name of the parameter carrying the resource
+// indicator, or "" when none is sent.
+func (c *Coordinator) resourceParameter() string {
+	if c.provider == nil {
+		return "resource"
+	}
+	return c.provider.ResourceParameter
+}
+
+// setResource adds the resource indicator (RFC 8707, required by the MCP
+// authorization spec) to the request parameters in params.
+func (c *Coordinator) setResource(params url.Values, resource string) {
+	if name := c.resourceParameter(); name != "" && resource != "" {
+		params.Set(name, resource)
+	}
+}
+
+// setAuthParams adds the parameters given with WithAuthParams to params,
+// replacing any of the same name.
+func (c *Coordinator) setAuthParams(params url.Values) {
+	for k, v := range c.authParams {
+		params.Set(k, v)
+	}
+}
+
+// providerHeaders returns headers with the provider's headers added.
+func (c *Coordinator) providerHeaders(headers map[string]string) map[string]string {
+	if c.provider == nil || len(c.provider.Headers) == 0 {
+		return headers
+	}
+	out := maps.Clone(c.provider.Headers)
+	maps.Copy(out, headers)
+	return out
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLookupProvider(t *testing.T) {
+	tests := []struct {
+		spec          string
+		wantName      string
+		wantTokenURL  string
+		wantResource  string
+		wantErrSubstr string
+	}{
+		{spec: "github", wantName: "github", wantTokenURL: "https://github.com/login/oauth/access_token"},
+		{spec: "Google", wantName: "google", wantTokenURL: "https://oauth2.googleapis.com/token"},
+		{spec: "azure-ad", wantName: "azure-ad", wantTokenURL: "https://login.microsoftonline.com/common/oauth2/v2.0/token"},
+		{spec: "azure-ad:contoso.onmicrosoft.com", wantName: "azure-ad", wantTokenURL: "https://login.microsoftonline.com/contoso.onmicrosoft.com/oauth2/v2.0/token"},
+		{spec: "auth0:example.us.auth0.com", wantName: "auth0", wantTokenURL: "https://example.us.auth0.com/oauth/token", wantResource: "audience"},
+		{spec: "auth0:https://example.us.auth0.com/", wantName: "auth0", wantTokenURL: "https://example.us.auth0.com/oauth/token", wantResource: "audience"},
+		{spec: "auth0", wantErrSubstr: "tenant domain"},
+		{spec: "auth0:example.com/path", wantErrSubstr: "invalid auth0 domain"},
+		{spec: "okta", wantErrSubstr: "unknown provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := LookupProvider(tt.spec)
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Fatalf("LookupProvider(%q) error = %v, want it to contain %q", tt.spec, err, tt.wantErrSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LookupProvider(%q) failed: %v", tt.spec, err)
+			}
+			if p.Name != tt.wantName || p.Metadata.TokenEndpoint != tt.wantTokenURL || p.ResourceParameter != tt.wantResource {
+				t.Errorf("LookupProvider(%q) = %s %s %q, want %s %s %q", tt.spec,
+					p.Name, p.Metadata.TokenEndpoint, p.ResourceParameter, tt.wantName, tt.wantTokenURL, tt.wantResource)
+			}
+		})
+	}
+}
+
+func TestProviderFlow(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var tokenForm url.Values
+	var tokenAccept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			t.Errorf("unexpected request to %s; discovery should be skipped", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		tokenForm, tokenAccept = r.PostForm, r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "provider-token", TokenType: "Bearer"})
+	}))
+	defer server.Close()
+
+	provider := &Provider{
+		Name: "test",
+		Metadata: ServerMetadata{
+			AuthorizationEndpoint:         server.URL + "/authorize",
+			CodeChallengeMethodsSupported: []string{"S256"},
+			TokenEndpoint:                 server.URL + "/token",
+		},
+		Scopes:              []string{"read", "write"},
+		ResourceParameter:   "audience",
+		AuthorizationParams: map[string]string{"access_type": "offline"},
+		Headers:             map[string]string{"Accept": "application/json"},
+	}
+	coordinator, err := NewCoordinator("provider-flow", 3351, WithProvider(provider), WithClient("app", "s3cret"))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	defer coordinator.Close()
+
+	authURL, err := coordinator.InitializeAuth(t.Context(), "https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("InitializeAuth failed: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL: %v", err)
+	}
+	query := parsed.Query()
+	if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+		t.Errorf("auth URL = %s, want the provider's authorization endpoint", authURL)
+	}
+	for param, want := range map[string]string{
+		"client_id":   "app",
+		"scope":       "read write",
+		"audience":    "https://mcp.example.com/mcp",
+		"resource":    "",
+		"access_type": "offline",
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("auth URL %s = %q, want %q", param, got, want)
+		}
+	}
+
+	if _, err := coordinator.ExchangeCode(t.Context(), "code"); err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if got := tokenForm.Get("audience"); got != "https://mcp.example.com/mcp" {
+		t.Errorf("token request audience = %q", got)
+	}
+	if tokenForm.Has("resource") {
+		t.Errorf("token request sent resource %q", tokenForm.Get("resource"))
+	}
+	if got := tokenForm.Get("client_secret"); got != "s3cret" {
+		t.Errorf("token request client_secret = %q, want s3cret", got)
+	}
+	if tokenAccept != "application/json" {
+		t.Errorf("token request Accept = %q, want application/json", tokenAccept)
+	}
+
+	metadata, err := coordinator.loadServerMetadata()
+	if err != nil {
+		t.Fatalf("provider metadata not saved: %v", err)
+	}
+	if metadata.TokenEndpoint != server.URL+"/token" {
+		t.Errorf("saved token endpoint = %q", metadata.TokenEndpoint)
+	}
+}
+
+func TestProviderWithoutClientRequiresRegistration(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	provider, err := LookupProvider("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coordinator, err := NewCoordinator("provider-no-client", 3352, WithProvider(provider))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	defer coordinator.Close()
+
+	_, err = coordinator.InitializeAuth(t.Context(), "https://api.example.com/mcp")
+	if err == nil || !strings.Contains(err.Error(), "github does not support dynamic registration") {
+		t.Fatalf("InitializeAuth error = %v, want a missing client error", err)
+	}
+}
+
+func TestProviderScopesYieldToConfiguredScopes(t *testing.T) {
+	provider := &Provider{Scopes: []string{"openid"}}
+	c := &Coordinator{provider: provider}
+	if got := c.scope(); got != "openid" {
+		t.Errorf("scope() = %q, want the provider's scopes", got)
+	}
+	c.scopes = []string{"custom"}
+	if got := c.scope(); got != "custom" {
+		t.Errorf("scope() = %q, want the configured scopes", got)
+	}
+	c = &Coordinator{}
+	if got := c.scope(); got != "mcp offline_access" {
+		t.Errorf("scope() = %q, want the defaults", got)
+	}
+}
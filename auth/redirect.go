@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultCallbackBind is the address the callback server listens on by
+// default.
+const defaultCallbackBind = "127.0.0.1"
+
+// WithCallbackHost names the callback server by host in the redirect URI
+// instead of 127.0.0.1, e.g. "host.docker.internal" when the browser runs
+// outside the container or WSL distribution the proxy runs in. The host is
+// checked with ValidateCallbackHost beforehand. Requests to the callback
+// server may carry it in their Host header, as well as the loopback names.
+func WithCallbackHost(host string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.callbackHost = host
+	}
+}
+
+// WithCallbackBind sets the IP address the callback server listens on,
+// e.g. "0.0.0.0" to accept the browser redirect from outside a container.
+// The address is checked with ValidateCallbackBind beforehand.
+func WithCallbackBind(addr string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.callbackBind = addr
+	}
+}
+
+// ValidateCallbackHost reports whether host is a valid entry for
+// WithCallbackHost: a host name or IP address without scheme or port.
+func ValidateCallbackHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if host == "" || strings.ContainsAny(host, ":/?#@[] ") {
+		return fmt.Errorf("invalid callback host %q: expected a host name or IP address without scheme or port", host)
+	}
+	return nil
+}
+
+// ValidateCallbackBind reports whether addr is a valid entry for
+// WithCallbackBind: an IP address.
+func ValidateCallbackBind(addr string) error {
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("invalid callback bind address %q: expected an IP address", addr)
+	}
+	return nil
+}
+
+// callbackBindAddress returns the address the callback server listens on.
+func (c *Coordinator) callbackBindAddress() string {
+	if c.callbackBind == "" {
+		return defaultCallbackBind
+	}
+	return c.callbackBind
+}
+
+// loopbackRedirectURI returns the redirect URI for a callback server bound
+// to port. The loopback IP literal is used rather than "localhost", which
+// may resolve elsewhere or to IPv6 first (RFC 8252 §8.3).
+func loopbackRedirectURI(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+}
+
+// localRedirectURI returns the redirect URI reaching the callback server on
+// its current port: the loopback URI, one naming the host given with
+// WithCallbackHost, or with WithCallbackTLS an https URI naming localhost.
+func (c *Coordinator) localRedirectURI() string {
+	switch {
+	case c.callbackHost != "":
+		return c.callbackScheme() + "://" + net.JoinHostPort(c.callbackHost, strconv.Itoa(c.callbackPort)) + "/callback"
+	case c.callbackTLS:
+		return "https://" + net.JoinHostPort(callbackTLSHost, strconv.Itoa(c.callbackPort)) + "/callback"
+	}
+	return loopbackRedirectURI(c.callbackPort)
+}
+
+// callbackScheme returns the scheme the callback server is reached with.
+func (c *Coordinator) callbackScheme() string {
+	if c.callbackTLS {
+		return "https"
+	}
+	return "http"
+}
+
+// callbackURI returns the redirect URI sent in authorization and token
+// requests.
+func (c *Coordinator) callbackURI() string {
+	if c.redirectURI != "" {
+		return c.redirectURI
+	}
+	return c.localRedirectURI()
+}
+
+// registeredRedirectURI returns the redirect URI of clientInfo that reaches
+// the callback server on its current port, and on the host given with
+// WithCallbackHost when there is one. Registrations without redirect URIs
+// are assumed to accept the local redirect URI. Device flow registrations
+// never redirect, so any of them fits.
+func (c *Coordinator) registeredRedirectURI(clientInfo *ClientInfo) (string, bool) {
+	if c.flow == AuthFlowDevice || len(clientInfo.RedirectURIs) == 0 {
+		return c.localRedirectURI(), true
+	}
+	for _, raw := range clientInfo.RedirectURIs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != c.callbackScheme() || u.Path != "/callback" {
+			continue
+		}
+		if c.callbackHost != "" && !strings.EqualFold(u.Hostname(), c.callbackHost) ||
+			c.callbackHost == "" && !isLoopbackHostname(u.Hostname()) {
+			continue
+		}
+		if u.Port() == strconv.Itoa(c.callbackPort) {
+			return raw, true
+		}
+	}
+	slog.Debug("cached client is not registered for the callback port", "port", c.callbackPort, "redirect_uris", clientInfo.RedirectURIs)
+	return "", false
+}
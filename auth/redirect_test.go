@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLoadOrRegisterClientReregistersForNewPort(t *testing.T) {
+	coordinator := newCoordinatorWithCachedClient(t, "redirect-port-test", 3361, ClientInfo{
+		ClientID:     "old-port-client",
+		RedirectURIs: []string{"http://localhost:3360/callback"},
+	})
+
+	var registered []string
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			RedirectURIs []string `json:"redirect_uris"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		registered = req.RedirectURIs
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"client_id":"new-port-client"}`))
+	}))
+	defer as.Close()
+	coordinator.serverMetadata = &ServerMetadata{Issuer: as.URL, RegistrationEndpoint: as.URL + "/register"}
+
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
+	if err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	if clientInfo.ClientID != "new-port-client" {
+		t.Errorf("ClientID = %q, want new-port-client", clientInfo.ClientID)
+	}
+	const want = "http://127.0.0.1:3361/callback"
+	if len(registered) != 1 || registered[0] != want {
+		t.Errorf("registered redirect_uris = %v, want [%s]", registered, want)
+	}
+	if got := coordinator.callbackURI(); got != want {
+		t.Errorf("callbackURI() = %q, want %q", got, want)
+	}
+
+	// The registration is cached with its redirect URI and reused.
+	cached, err := coordinator.loadClientInfo()
+	if err != nil {
+		t.Fatalf("loadClientInfo failed: %v", err)
+	}
+	if len(cached.RedirectURIs) != 1 || cached.RedirectURIs[0] != want {
+		t.Errorf("cached redirect_uris = %v, want [%s]", cached.RedirectURIs, want)
+	}
+}
+
+func TestLoadOrRegisterClientKeepsRegisteredRedirectURI(t *testing.T) {
+	const registered = "http://localhost:3362/callback"
+	coordinator := newCoordinatorWithCachedClient(t, "redirect-keep-test", 3362, ClientInfo{
+		ClientID:     "client",
+		RedirectURIs: []string{registered},
+	})
+	coordinator.serverMetadata = &ServerMetadata{Issuer: "https://as.example.com"}
+
+	if _, err := coordinator.loadOrRegisterClient(t.Context()); err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	if got := coordinator.callbackURI(); got != registered {
+		t.Errorf("callbackURI() = %q, want %q", got, registered)
+	}
+}
+
+func TestCallbackServerRecordsBoundPort(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("redirect-bound-test", 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.startCallbackServer(); err != nil {
+		t.Fatalf("startCallbackServer failed: %v", err)
+	}
+	defer func() { _ = coordinator.callbackServer.Close() }()
+
+	if coordinator.callbackPort == 0 {
+		t.Fatal("Expected the bound port to be recorded")
+	}
+
+	u, err := url.Parse(coordinator.callbackURI())
+	if err != nil {
+		t.Fatalf("Invalid callback URI: %v", err)
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		t.Fatalf("Callback server not reachable at %s: %v", u, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 with no flow in progress, got %d", resp.StatusCode)
+	}
+}
+
+func TestCallbackHostAndBind(t *testing.T) {
+	const registered = "http://host.docker.internal:3363/callback"
+	coordinator := newCoordinatorWithCachedClient(t, "redirect-host-test", 3363, ClientInfo{
+		ClientID:     "client",
+		RedirectURIs: []string{"http://127.0.0.1:3363/callback", registered},
+	})
+	WithCallbackHost("host.docker.internal")(coordinator)
+	WithCallbackBind("0.0.0.0")(coordinator)
+	coordinator.serverMetadata = &ServerMetadata{Issuer: "https://as.example.com"}
+
+	if _, err := coordinator.loadOrRegisterClient(t.Context()); err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	if got := coordinator.callbackURI(); got != registered {
+		t.Errorf("callbackURI() = %q, want %q", got, registered)
+	}
+
+	coordinator.callbackPort = 0
+	if err := coordinator.startCallbackServer(); err != nil {
+		t.Fatalf("startCallbackServer failed: %v", err)
+	}
+	defer func() { _ = coordinator.callbackServer.Close() }()
+	if got, want := coordinator.localRedirectURI(), fmt.Sprintf("http://host.docker.internal:%d/callback", coordinator.callbackPort); got != want {
+		t.Errorf("localRedirectURI() = %q, want %q", got, want)
+	}
+	if !coordinator.isCallbackHost(fmt.Sprintf("host.docker.internal:%d", coordinator.callbackPort)) ||
+		!coordinator.isCallbackHost(fmt.Sprintf("localhost:%d", coordinator.callbackPort)) {
+		t.Error("Expected the callback host and loopback names to be accepted")
+	}
+
+	// Bound to all interfaces, the server still answers on loopback.
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/callback", coordinator.callbackPort))
+	if err != nil {
+		t.Fatalf("Callback server not reachable: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestValidateCallbackHostAndBind(t *testing.T) {
+	for _, host := range []string{"host.docker.internal", "192.168.1.10", "::1"} {
+		if err := ValidateCallbackHost(host); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", host, err)
+		}
+	}
+	for _, host := range []string{"", "http://localhost", "localhost:3334", "[::1]", "a/b"} {
+		if err := ValidateCallbackHost(host); err == nil {
+			t.Errorf("Expected %q to be rejected", host)
+		}
+	}
+	if err := ValidateCallbackBind("0.0.0.0"); err != nil {
+		t.Errorf("Expected 0.0.0.0 to be valid, got %v", err)
+	}
+	if err := ValidateCallbackBind("localhost"); err == nil {
+		t.Error("Expected a host name to be rejected as bind address")
+	}
+}
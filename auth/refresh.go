@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+)
+
+const (
+	// tokenRefreshSkew is how long before expiry the access token is refreshed.
+	tokenRefreshSkew = 60 * time.Second
+
+	// tokenRefreshCheckInterval bounds how long the refresher sleeps between
+	// checks. Expiry is compared against the wall clock, so a short interval
+	// also catches tokens that expired while the machine was suspended.
+	tokenRefreshCheckInterval = 30 * time.Second
+)
+
+// expiresWithin reports whether the access token expires within d of now.
+// Tokens without a known expiry never report as expiring.
+func (t *Tokens) expiresWithin(now time.Time, d time.Duration) bool {
+	return t.ExpiresAt > 0 && now.Add(d).Unix() >= t.ExpiresAt
+}
+
+// RefreshTokens exchanges the stored refresh token for a new access token
+// (RFC 6749 §6) and saves the result. When the server does not rotate the
+// refresh token, the previous one is kept. With client credentials and no
+// refresh token, the grant is simply repeated (RFC 6749 §4.4.3).
+func (c *Coordinator) RefreshTokens(ctx context.Context) (*Tokens, error) {
+	tokens, err := c.refreshTokens(ctx)
+	if err != nil {
+		metrics.TokenRefreshesTotal.Inc(metrics.ResultFailure)
+		return nil, err
+	}
+	metrics.TokenRefreshesTotal.Inc(metrics.ResultSuccess)
+	return tokens, nil
+}
+
+func (c *Coordinator) refreshTokens(ctx context.Context) (*Tokens, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	// Other proxies may share the tokens. Refreshing under the token update
+	// lock, with the tokens read again inside it, means the newest refresh
+	// token is always used: servers that rotate refresh tokens may revoke
+	// the whole family when a replaced one is presented.
+	var tokens *Tokens
+	err := c.withTokenLock(func() error {
+		var err error
+		tokens, err = c.refreshTokensLocked(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// refreshTokensLocked refreshes the tokens while holding the token update
+// lock.
+func (c *Coordinator) refreshTokensLocked(ctx context.Context) (*Tokens, error) {
+	current, err := c.readTokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+	if c.refreshedElsewhere(current) {
+		slog.Debug("tokens already refreshed by another process", "version", current.Version)
+		c.tokenVersion.Store(current.Version)
+		return current, nil
+	}
+	if current.RefreshToken == "" && c.flow != AuthFlowClientCredentials {
+		return nil, errors.New("no refresh token available")
+	}
+
+	c.authMutex.Lock()
+	metadata, clientInfo, resource := c.serverMetadata, c.clientInfo, c.resource
+	c.authMutex.Unlock()
+
+	// After a restart the flow state is only on disk.
+	if metadata == nil {
+		if metadata, err = c.loadServerMetadata(); err != nil {
+			return nil, fmt.Errorf("failed to load server metadata: %w", err)
+		}
+	}
+	if clientInfo == nil {
+		if clientInfo, err = c.loadClientInfo(); err != nil {
+			return nil, fmt.Errorf("failed to load client info: %w", err)
+		}
+	}
+
+	if current.RefreshToken == "" {
+		tokens, err := c.requestClientCredentialsToken(ctx, metadata, clientInfo, resource)
+		if err != nil {
+			return nil, err
+		}
+		return c.saveRefreshedTokens(tokens, current.Version)
+	}
+
+	formData := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {current.RefreshToken},
+		"client_id":     {clientInfo.ClientID},
+	}
+
+	// RFC 8707 resource indicator (required by the MCP authorization spec).
+	c.setResource(formData, resource)
+	c.setAuthParams(formData)
+
+	headers, err := c.authenticateClient(metadata, clientInfo, formData)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.PostForm(ctx, metadata.TokenEndpoint, formData, headers)
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var tokens Tokens
+	if err := resp.JSON(&tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("token response did not include an access token")
+	}
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = current.RefreshToken
+	}
+	if tokens.Scope == "" {
+		tokens.Scope = current.Scope
+	}
+	if err := c.verifyIDToken(ctx, metadata, clientInfo.ClientID, &tokens, ""); err != nil {
+		return nil, err
+	}
+
+	return c.saveRefreshedTokens(&tokens, current.Version)
+}
+
+// saveRefreshedTokens saves tokens refreshed from the given version. If a
+// writer not holding the lock, such as an older version of the proxy,
+// replaced that version in the meantime, its tokens are kept and returned.
+func (c *Coordinator) saveRefreshedTokens(tokens *Tokens, version int64) (*Tokens, error) {
+	err := c.writeTokens(tokens, version)
+	if errors.Is(err, errTokensChanged) {
+		slog.Warn("tokens were replaced during refresh, keeping the newer ones")
+		return c.LoadTokens()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tokens: %w", err)
+	}
+	audit(c.serverURLHash, auditTokensRefreshed, "")
+	return tokens, nil
+}
+
+// StartTokenRefresher refreshes the access token in the background shortly
+// before it expires, until ctx is cancelled. serverURL is used to derive the
+// RFC 8707 resource indicator when no authorization flow ran in this process.
+func (c *Coordinator) StartTokenRefresher(ctx context.Context, serverURL string) {
+	c.authMutex.Lock()
+	if c.resource == "" {
+		if resource, err := c.resourceFor(serverURL); err == nil {
+			c.resource = resource
+		}
+	}
+	c.authMutex.Unlock()
+
+	go c.runTokenRefresher(ctx)
+}
+
+func (c *Coordinator) runTokenRefresher(ctx context.Context) {
+	for {
+		tokens, err := c.LoadTokens()
+		renewable := err == nil && (tokens.RefreshToken != "" || c.flow == AuthFlowClientCredentials)
+		if renewable && tokens.expiresWithin(time.Now(), tokenRefreshSkew) {
+			if _, err := c.RefreshTokens(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("token refresh failed", "error", err)
+			} else {
+				slog.Info("access token refreshed")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tokenRefreshCheckInterval):
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRefreshServer returns a token endpoint that issues "refreshed-<n>" access
+// tokens for refresh_token grants without rotating the refresh token.
+func newRefreshServer(t *testing.T, calls *atomic.Int32, gotResource *atomic.Value) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "test-refresh-token" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		if gotResource != nil {
+			gotResource.Store(r.Form.Get("resource"))
+		}
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("refreshed-%d", n),
+			"expires_in":   3600,
+			"token_type":   "Bearer",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSaveTokensSetsExpiresAt(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("expires-at", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+
+	before := time.Now().Unix()
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "a", ExpiresIn: 120}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	loaded, err := coordinator.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded.ExpiresAt < before+120 || loaded.ExpiresAt > time.Now().Unix()+120 {
+		t.Errorf("Expected ExpiresAt about now+120, got %d", loaded.ExpiresAt)
+	}
+	if !loaded.expiresWithin(time.Now(), 5*time.Minute) {
+		t.Error("Expected token to expire within 5 minutes")
+	}
+	if loaded.expiresWithin(time.Now(), time.Second) {
+		t.Error("Expected token not to expire within 1 second")
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	var gotResource atomic.Value
+	server := newRefreshServer(t, &calls, &gotResource)
+
+	coordinator, err := NewCoordinator("refresh-tokens", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{TokenEndpoint: server.URL + "/token"}
+	coordinator.clientInfo = &ClientInfo{ClientID: "test-client-id"}
+	coordinator.resource = "https://mcp.example.com/mcp"
+
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "old", RefreshToken: "test-refresh-token"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	tokens, err := coordinator.RefreshTokens(t.Context())
+	if err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	if tokens.AccessToken != "refreshed-1" {
+		t.Errorf("Expected access token 'refreshed-1', got %q", tokens.AccessToken)
+	}
+	if tokens.RefreshToken != "test-refresh-token" {
+		t.Errorf("Expected refresh token to be kept, got %q", tokens.RefreshToken)
+	}
+	if gotResource.Load() != "https://mcp.example.com/mcp" {
+		t.Errorf("Expected resource indicator, got %v", gotResource.Load())
+	}
+
+	loaded, err := coordinator.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded.AccessToken != "refreshed-1" || loaded.ExpiresAt == 0 {
+		t.Errorf("Expected refreshed tokens to be saved, got %+v", loaded)
+	}
+}
+
+func TestRefreshTokensLoadsStateFromDisk(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := newRefreshServer(t, &calls, nil)
+
+	// A previous process completed the flow and persisted its state.
+	previous, err := NewCoordinator("refresh-disk", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := previous.saveServerMetadata(&ServerMetadata{TokenEndpoint: server.URL + "/token"}); err != nil {
+		t.Fatalf("saveServerMetadata failed: %v", err)
+	}
+	if err := previous.saveClientInfo(&ClientInfo{ClientID: "test-client-id"}); err != nil {
+		t.Fatalf("saveClientInfo failed: %v", err)
+	}
+	if err := previous.SaveTokens(&Tokens{AccessToken: "old", RefreshToken: "test-refresh-token"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	coordinator, err := NewCoordinator("refresh-disk", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if _, err := coordinator.RefreshTokens(t.Context()); err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 refresh request, got %d", calls.Load())
+	}
+}
+
+func TestRefreshTokensWithoutRefreshToken(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("refresh-none", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "old"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if _, err := coordinator.RefreshTokens(t.Context()); err == nil {
+		t.Error("Expected error without a refresh token")
+	}
+}
+
+func TestTokenRefresherRefreshesBeforeExpiry(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := newRefreshServer(t, &calls, nil)
+
+	coordinator, err := NewCoordinator("refresher", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{TokenEndpoint: server.URL + "/token"}
+	coordinator.clientInfo = &ClientInfo{ClientID: "test-client-id"}
+
+	// Expires inside the refresh skew, so the first check refreshes it.
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "old", RefreshToken: "test-refresh-token", ExpiresIn: 10}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	coordinator.StartTokenRefresher(ctx, "https://mcp.example.com/mcp")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if tokens, err := coordinator.LoadTokens(); err == nil && tokens.AccessToken == "refreshed-1" {
+			if coordinator.resource != "https://mcp.example.com/mcp" {
+				t.Errorf("Expected resource derived from server URL, got %q", coordinator.resource)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected refresher to replace the expiring access token")
+}
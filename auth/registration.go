@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+)
+
+// clientName is the client_name sent in dynamic client registration.
+const clientName = "MCP Remote Go Client"
+
+// clientMetadata returns the client metadata (RFC 7591 §2) registered for
+// redirectURI.
+func (c *Coordinator) clientMetadata(redirectURI string) map[string]interface{} {
+	return map[string]interface{}{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"token_endpoint_auth_method": "none",
+		"scope":                      c.scope(),
+		"grant_types":                c.registrationGrantTypes(),
+	}
+}
+
+// registerClient registers a new client with redirectURI (RFC 7591) and
+// saves it.
+func (c *Coordinator) registerClient(ctx context.Context, redirectURI string) (*ClientInfo, error) {
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.Post(ctx, c.serverMetadata.RegistrationEndpoint, c.clientMetadata(redirectURI), nil)
+	if err != nil {
+		return nil, fmt.Errorf("client registration failed: %w", err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var clientInfo ClientInfo
+	if err := resp.JSON(&clientInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse client registration response: %w", err)
+	}
+	return c.saveRegistration(&clientInfo, redirectURI)
+}
+
+// updateClient replaces the metadata of a registered client so that it
+// accepts redirectURI, through the client configuration endpoint (RFC 7592
+// §2.2). The server may rotate the client secret and the registration
+// access token; values it leaves out are kept.
+func (c *Coordinator) updateClient(ctx context.Context, current *ClientInfo, redirectURI string) (*ClientInfo, error) {
+	metadata := c.clientMetadata(redirectURI)
+	metadata["client_id"] = current.ClientID
+	if current.ClientSecret != "" {
+		metadata["client_secret"] = current.ClientSecret
+	}
+
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.Do(ctx, &httpclient.Request{
+		Method: http.MethodPut,
+		URL:    current.RegistrationClientURI,
+		Headers: map[string]string{
+			"Authorization": "Bearer " + current.RegistrationAccessToken,
+			"Content-Type":  "application/json",
+		},
+		Body: metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client registration update failed: %w", err)
+	}
+	defer func() { _ = resp.SafeClose() }()
+
+	var clientInfo ClientInfo
+	if err := resp.JSON(&clientInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse client registration update response: %w", err)
+	}
+	if clientInfo.ClientID != current.ClientID {
+		return nil, fmt.Errorf("client registration update returned client_id %q, want %q", clientInfo.ClientID, current.ClientID)
+	}
+	if clientInfo.ClientSecret == "" {
+		clientInfo.ClientSecret = current.ClientSecret
+	}
+	if clientInfo.RegistrationAccessToken == "" {
+		clientInfo.RegistrationAccessToken = current.RegistrationAccessToken
+	}
+	if clientInfo.RegistrationClientURI == "" {
+		clientInfo.RegistrationClientURI = current.RegistrationClientURI
+	}
+	return c.saveRegistration(&clientInfo, redirectURI)
+}
+
+// saveRegistration records the issuer and redirect URI of a registration
+// response, saves it and makes redirectURI the one used by the flow.
+func (c *Coordinator) saveRegistration(clientInfo *ClientInfo, redirectURI string) (*ClientInfo, error) {
+	if c.serverMetadata != nil {
+		clientInfo.RegisteredIssuer = c.serverMetadata.Issuer
+	}
+	if len(clientInfo.RedirectURIs) == 0 {
+		clientInfo.RedirectURIs = []string{redirectURI}
+	}
+	c.redirectURI = redirectURI
+
+	if err := c.saveClientInfo(clientInfo); err != nil {
+		return nil, fmt.Errorf("failed to save client info: %w", err)
+	}
+	audit(c.serverURLHash, auditClientRegistered, clientInfo.ClientID)
+	return clientInfo, nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRegistrationServer serves RFC 7591 registration on /register and the
+// RFC 7592 configuration endpoint on /register/client, which only accepts
+// the registration access token "old-reg-token".
+func newRegistrationServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var calls []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/register":
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"client_id":                 "registered",
+				"redirect_uris":             req["redirect_uris"],
+				"registration_access_token": "reg-token",
+				"registration_client_uri":   server.URL + "/register/client",
+			})
+		case r.Method == http.MethodPut && r.URL.Path == "/register/client":
+			if r.Header.Get("Authorization") != "Bearer old-reg-token" || req["client_id"] != "client" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"client_id":                 "client",
+				"redirect_uris":             req["redirect_uris"],
+				"registration_access_token": "new-reg-token",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRegisterClientStoresRegistrationAccess(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	as, _ := newRegistrationServer(t)
+
+	coordinator, err := NewCoordinator("registration-store-test", 3370)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{Issuer: as.URL, RegistrationEndpoint: as.URL + "/register"}
+
+	if _, err := coordinator.loadOrRegisterClient(t.Context()); err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	cached, err := coordinator.loadClientInfo()
+	if err != nil {
+		t.Fatalf("loadClientInfo failed: %v", err)
+	}
+	if cached.RegistrationAccessToken != "reg-token" || cached.RegistrationClientURI != as.URL+"/register/client" {
+		t.Errorf("Expected registration access to be saved, got %q, %q", cached.RegistrationAccessToken, cached.RegistrationClientURI)
+	}
+}
+
+func TestLoadOrRegisterClientUpdatesRegistration(t *testing.T) {
+	as, calls := newRegistrationServer(t)
+	coordinator := newCoordinatorWithCachedClient(t, "registration-update-test", 3372, ClientInfo{
+		ClientID:                "client",
+		RedirectURIs:            []string{"http://127.0.0.1:3371/callback"},
+		RegistrationAccessToken: "old-reg-token",
+		RegistrationClientURI:   as.URL + "/register/client",
+	})
+	coordinator.serverMetadata = &ServerMetadata{Issuer: as.URL, RegistrationEndpoint: as.URL + "/register"}
+
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
+	if err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "PUT /register/client" {
+		t.Errorf("Expected a single update request, got %v", *calls)
+	}
+	if clientInfo.ClientID != "client" {
+		t.Errorf("ClientID = %q, want client", clientInfo.ClientID)
+	}
+	const want = "http://127.0.0.1:3372/callback"
+	if got := coordinator.callbackURI(); got != want {
+		t.Errorf("callbackURI() = %q, want %q", got, want)
+	}
+
+	cached, err := coordinator.loadClientInfo()
+	if err != nil {
+		t.Fatalf("loadClientInfo failed: %v", err)
+	}
+	if cached.RegistrationAccessToken != "new-reg-token" {
+		t.Errorf("Expected the rotated registration access token, got %q", cached.RegistrationAccessToken)
+	}
+	if cached.RegistrationClientURI != as.URL+"/register/client" {
+		t.Errorf("Expected the registration client URI to be kept, got %q", cached.RegistrationClientURI)
+	}
+}
+
+func TestLoadOrRegisterClientRegistersWhenUpdateFails(t *testing.T) {
+	as, calls := newRegistrationServer(t)
+	coordinator := newCoordinatorWithCachedClient(t, "registration-fallback-test", 3374, ClientInfo{
+		ClientID:                "client",
+		RedirectURIs:            []string{"http://127.0.0.1:3373/callback"},
+		RegistrationAccessToken: "revoked",
+		RegistrationClientURI:   as.URL + "/register/client",
+	})
+	coordinator.serverMetadata = &ServerMetadata{Issuer: as.URL, RegistrationEndpoint: as.URL + "/register"}
+
+	clientInfo, err := coordinator.loadOrRegisterClient(t.Context())
+	if err != nil {
+		t.Fatalf("loadOrRegisterClient failed: %v", err)
+	}
+	if len(*calls) != 2 || (*calls)[1] != "POST /register" {
+		t.Errorf("Expected an update followed by a registration, got %v", *calls)
+	}
+	if clientInfo.ClientID != "registered" {
+		t.Errorf("ClientID = %q, want registered", clientInfo.ClientID)
+	}
+}
+
+func TestRegisterClientStopsWhenCancelled(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	coordinator, err := NewCoordinator("registration-cancel-test", 3370)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	coordinator.serverMetadata = &ServerMetadata{Issuer: server.URL, RegistrationEndpoint: server.URL + "/register"}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := coordinator.loadOrRegisterClient(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
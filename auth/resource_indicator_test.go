@@ -26,10 +26,11 @@ func TestResourceParameterSentInAuthAndTokenRequests(t *testing.T) {
 		switch r.URL.Path {
 		case "/.well-known/oauth-authorization-server":
 			metadata := ServerMetadata{
-				Issuer:                mockServer.URL,
-				AuthorizationEndpoint: mockServer.URL + "/auth",
-				TokenEndpoint:         mockServer.URL + "/token",
-				RegistrationEndpoint:  mockServer.URL + "/register",
+				Issuer:                        mockServer.URL,
+				AuthorizationEndpoint:         mockServer.URL + "/auth",
+				CodeChallengeMethodsSupported: []string{"S256"},
+				TokenEndpoint:                 mockServer.URL + "/token",
+				RegistrationEndpoint:          mockServer.URL + "/register",
 			}
 			w.Header().Set("Content-Type", "application/json")
 			_ = json.NewEncoder(w).Encode(metadata)
@@ -82,7 +83,7 @@ func TestResourceParameterSentInAuthAndTokenRequests(t *testing.T) {
 		t.Fatalf("CanonicalResourceURI failed: %v", err)
 	}
 
-	authURL, err := coordinator.InitializeAuth(rawServerURL)
+	authURL, err := coordinator.InitializeAuth(t.Context(), rawServerURL)
 	if err != nil {
 		t.Fatalf("InitializeAuth failed: %v", err)
 	}
@@ -96,7 +97,7 @@ func TestResourceParameterSentInAuthAndTokenRequests(t *testing.T) {
 	}
 
 	// Exchange the code and verify the token request carried the same resource.
-	if _, err := coordinator.ExchangeCode(authCode); err != nil {
+	if _, err := coordinator.ExchangeCode(t.Context(), authCode); err != nil {
 		t.Fatalf("ExchangeCode failed: %v", err)
 	}
 
@@ -129,7 +130,7 @@ func TestResourceParameterRejectsInvalidServerURL(t *testing.T) {
 		t.Fatalf("NewCoordinator failed: %v", err)
 	}
 
-	_, err = coordinator.InitializeAuth("https://example.com#frag")
+	_, err = coordinator.InitializeAuth(t.Context(), "https://example.com#frag")
 	if err == nil {
 		t.Fatal("expected error for URL containing fragment")
 	}
@@ -137,3 +138,27 @@ func TestResourceParameterRejectsInvalidServerURL(t *testing.T) {
 		t.Errorf("expected canonical-URI error, got: %v", err)
 	}
 }
+
+// TestWithResourceOverridesDerivedResource verifies that a configured
+// resource indicator replaces the one derived from the server URL, including
+// for refreshes after a restart when no flow ran in this process.
+func TestWithResourceOverridesDerivedResource(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	const resource = "https://api.example.com"
+	coordinator, err := NewCoordinator("resource-override-hash", 0, WithResource(resource))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if coordinator.resource != resource {
+		t.Errorf("resource before any flow = %q, want %q", coordinator.resource, resource)
+	}
+
+	got, err := coordinator.resourceFor("https://mcp.example.com/mcp")
+	if err != nil {
+		t.Fatalf("resourceFor failed: %v", err)
+	}
+	if got != resource {
+		t.Errorf("resourceFor = %q, want %q", got, resource)
+	}
+}
@@ -55,10 +55,11 @@ func TestInitializeAuthUsesResourceMetadataURL(t *testing.T) {
 
 		case "/.well-known/oauth-authorization-server":
 			meta := ServerMetadata{
-				Issuer:                authServer.URL,
-				AuthorizationEndpoint: authServer.URL + "/auth",
-				TokenEndpoint:         authServer.URL + "/token",
-				RegistrationEndpoint:  authServer.URL + "/register",
+				Issuer:                        authServer.URL,
+				AuthorizationEndpoint:         authServer.URL + "/auth",
+				CodeChallengeMethodsSupported: []string{"S256"},
+				TokenEndpoint:                 authServer.URL + "/token",
+				RegistrationEndpoint:          authServer.URL + "/register",
 			}
 			w.Header().Set("Content-Type", "application/json")
 			_ = json.NewEncoder(w).Encode(meta)
@@ -89,7 +90,7 @@ func TestInitializeAuthUsesResourceMetadataURL(t *testing.T) {
 	}
 
 	prmURL := authServer.URL + "/custom/protected-resource.json"
-	authURL, err := coordinator.InitializeAuth(resourceServer.URL, WithResourceMetadataURL(prmURL))
+	authURL, err := coordinator.InitializeAuth(t.Context(), resourceServer.URL, WithResourceMetadataURL(prmURL))
 	if err != nil {
 		t.Fatalf("InitializeAuth failed: %v", err)
 	}
@@ -62,6 +62,15 @@ func CanonicalResourceURI(serverURL string) (string, error) {
 	return u.String(), nil
 }
 
+// resourceFor returns the resource indicator for serverURL: the configured
+// one, or the server's canonical URI.
+func (c *Coordinator) resourceFor(serverURL string) (string, error) {
+	if c.resourceURI != "" {
+		return c.resourceURI, nil
+	}
+	return CanonicalResourceURI(serverURL)
+}
+
 const protectedResourceWellKnownSuffix = "/.well-known/oauth-protected-resource"
 
 // ProtectedResourceWellKnownURL builds the RFC 9728 §3.1 metadata URL for a
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// ErrRevocationUnsupported is returned by RevokeTokens when the
+// authorization server does not advertise a revocation endpoint.
+var ErrRevocationUnsupported = errors.New("the authorization server does not support token revocation")
+
+// RevokeTokens revokes the stored refresh and access tokens at the
+// authorization server's revocation endpoint (RFC 7009). The refresh token
+// is revoked first, as servers may revoke the access tokens issued with it
+// too. The tokens are left in the store; see DeleteTokens. Without stored
+// tokens there is nothing to revoke and nil is returned.
+func (c *Coordinator) RevokeTokens(ctx context.Context) error {
+	tokens, err := c.LoadTokens()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load tokens: %w", err)
+	}
+
+	c.authMutex.Lock()
+	metadata, clientInfo := c.serverMetadata, c.clientInfo
+	c.authMutex.Unlock()
+	if metadata == nil {
+		if metadata, err = c.loadServerMetadata(); err != nil {
+			return ErrRevocationUnsupported
+		}
+	}
+	if metadata.RevocationEndpoint == "" {
+		return ErrRevocationUnsupported
+	}
+	if clientInfo == nil {
+		if clientInfo, err = c.loadClientInfo(); err != nil {
+			return fmt.Errorf("failed to load client info: %w", err)
+		}
+	}
+
+	var errs []error
+	if tokens.RefreshToken != "" {
+		errs = append(errs, c.revokeToken(ctx, metadata, clientInfo, tokens.RefreshToken, "refresh_token"))
+	}
+	if tokens.AccessToken != "" {
+		errs = append(errs, c.revokeToken(ctx, metadata, clientInfo, tokens.AccessToken, "access_token"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	audit(c.serverURLHash, auditTokensRevoked, "")
+	return nil
+}
+
+// revokeToken asks the revocation endpoint to revoke token, of the type
+// given by hint (RFC 7009 §2.1).
+func (c *Coordinator) revokeToken(ctx context.Context, metadata *ServerMetadata, clientInfo *ClientInfo, token, hint string) error {
+	formData := url.Values{
+		"token":           {token},
+		"token_type_hint": {hint},
+		"client_id":       {clientInfo.ClientID},
+	}
+	headers, err := c.authenticateClient(metadata, clientInfo, formData)
+	if err != nil {
+		return err
+	}
+
+	client := c.httpClient()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := client.PostForm(ctx, metadata.RevocationEndpoint, formData, headers)
+	if err != nil {
+		return fmt.Errorf("failed to revoke %s: %w", hint, err)
+	}
+	_ = resp.SafeClose()
+	slog.Debug("token revoked", "type", hint)
+	return nil
+}
+
+// DeleteTokens removes the stored tokens, keeping the client registration.
+func (c *Coordinator) DeleteTokens() error {
+	if err := c.store.Delete(c.serverURLHash, tokensFile); err != nil {
+		return fmt.Errorf("failed to delete tokens: %w", err)
+	}
+	audit(c.serverURLHash, auditTokensDeleted, "")
+	return nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// newRevocationServer returns a revocation endpoint recording the
+// token_type_hint of each request from client "client".
+func newRevocationServer(t *testing.T, hints *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("client_id") != "client" || r.Form.Get("token") == "" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		*hints = append(*hints, r.Form.Get("token_type_hint"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRevokeTokens(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	var hints []string
+	server := newRevocationServer(t, &hints)
+
+	coordinator, err := NewCoordinator("revoke", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coordinator.RevokeTokens(t.Context()); err != nil {
+		t.Errorf("Expected nothing to revoke without tokens, got %v", err)
+	}
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coordinator.RevokeTokens(t.Context()); !errors.Is(err, ErrRevocationUnsupported) {
+		t.Errorf("Expected ErrRevocationUnsupported without metadata, got %v", err)
+	}
+
+	if err := coordinator.saveServerMetadata(&ServerMetadata{TokenEndpoint: server.URL + "/token", RevocationEndpoint: server.URL + "/revoke"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coordinator.saveClientInfo(&ClientInfo{ClientID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coordinator.RevokeTokens(t.Context()); err != nil {
+		t.Fatalf("RevokeTokens failed: %v", err)
+	}
+	if len(hints) != 2 || hints[0] != "refresh_token" || hints[1] != "access_token" {
+		t.Errorf("Expected the refresh token then the access token revoked, got %v", hints)
+	}
+
+	if err := coordinator.DeleteTokens(); err != nil {
+		t.Fatalf("DeleteTokens failed: %v", err)
+	}
+	if _, err := coordinator.LoadTokens(); err == nil {
+		t.Error("Expected the tokens to be deleted")
+	}
+	if _, err := coordinator.loadClientInfo(); err != nil {
+		t.Errorf("Expected the client registration kept, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+	"github.com/zalando/go-keyring"
+)
+
+// Names of the credential documents kept per server.
+const (
+	tokensFile     = "tokens.json"
+	clientInfoFile = "client_info.json"
+)
+
+// Token store backends selectable with --token-store.
+const (
+	TokenStoreFile     = "file"
+	TokenStoreKeychain = "keychain"
+)
+
+// keyringService is the service name under which entries are stored in the
+// OS credential store.
+const keyringService = "mcp-remote-go"
+
+// serverKeyHashLen is the number of hex digits of the URL hash in a
+// ServerKey.
+const serverKeyHashLen = 12
+
+// maxServerKeyHostLen caps the host part of a ServerKey, keeping directory
+// names short.
+const maxServerKeyHostLen = 48
+
+// ServerKey returns the storage key for serverURL, used as the per-server
+// directory name and keychain account prefix: the URL's host followed by
+// the start of the URL's hex SHA-256, e.g. "mcp.example.com-3f2a9c01b4d7".
+func ServerKey(serverURL string) string {
+	return serverKeyHost(serverURL) + "-" + legacyServerKey(serverURL)[:serverKeyHashLen]
+}
+
+// maxProfileLen caps the length of a profile name.
+const maxProfileLen = 32
+
+// ProfileServerKey returns the storage key for serverURL under a named
+// profile, keeping separate credentials for each identity used with the
+// same server: the host, the profile and the start of the hex SHA-256 of
+// both, e.g. "mcp.example.com-work-8b1e07c2d94a". Without a profile it is
+// ServerKey.
+func ProfileServerKey(serverURL, profile string) string {
+	if profile == "" {
+		return ServerKey(serverURL)
+	}
+	hash := sha256.Sum256([]byte(serverURL + "\n" + profile))
+	return serverKeyHost(serverURL) + "-" + profile + "-" + hex.EncodeToString(hash[:])[:serverKeyHashLen]
+}
+
+// ValidateProfile checks that profile can be part of a storage key: lower
+// case letters, digits and dashes, starting with a letter or digit.
+func ValidateProfile(profile string) error {
+	if profile == "" || len(profile) > maxProfileLen || profile[0] == '-' ||
+		strings.Trim(profile, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+		return fmt.Errorf("invalid profile %q: use up to %d lower case letters, digits and dashes", profile, maxProfileLen)
+	}
+	return nil
+}
+
+// legacyServerKey returns the key earlier versions used for serverURL: the
+// full hex SHA-256 of the URL.
+func legacyServerKey(serverURL string) string {
+	hash := sha256.Sum256([]byte(serverURL))
+	return hex.EncodeToString(hash[:])
+}
+
+// serverKeyHost returns the host of serverURL reduced to characters that
+// are safe in a file name on every platform.
+func serverKeyHost(serverURL string) string {
+	var host string
+	if u, err := url.Parse(serverURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, host)
+	if len(host) > maxServerKeyHostLen {
+		host = host[:maxServerKeyHostLen]
+	}
+	host = strings.Trim(host, ".-")
+	if host == "" {
+		return "server"
+	}
+	return host
+}
+
+// TokenStore persists per-server credential documents such as tokens and
+// client registrations. Entries are addressed by the server directory key and
+// a document name (e.g. "tokens.json"). Load returns an error wrapping
+// fs.ErrNotExist when the entry does not exist.
+type TokenStore interface {
+	Load(serverKey, name string) ([]byte, error)
+	Save(serverKey, name string, data []byte) error
+	Delete(serverKey, name string) error
+	Name() string
+}
+
+// NewTokenStore returns the TokenStore for the given backend name. The
+// keychain backend falls back to file storage when the OS credential store is
+// unavailable.
+func NewTokenStore(kind string) (TokenStore, error) {
+	switch kind {
+	case "", TokenStoreFile:
+		return NewFileTokenStore(), nil
+	case TokenStoreKeychain:
+		return &fallbackTokenStore{
+			primary:  NewKeyringTokenStore(),
+			fallback: NewFileTokenStore(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q (must be %s or %s)", kind, TokenStoreFile, TokenStoreKeychain)
+	}
+}
+
+// FileTokenStore stores credential documents as JSON files under the config
+// directory. Every access holds the file's lock and writes replace the file
+// atomically, so proxies started at the same time cannot corrupt it.
+type FileTokenStore struct{}
+
+// NewFileTokenStore creates a file-backed token store.
+func NewFileTokenStore() *FileTokenStore {
+	return &FileTokenStore{}
+}
+
+func (s *FileTokenStore) Name() string {
+	return TokenStoreFile
+}
+
+func (s *FileTokenStore) path(serverKey, name string) string {
+	return filepath.Join(getConfigDir(), serverKey, name)
+}
+
+func (s *FileTokenStore) Load(serverKey, name string) ([]byte, error) {
+	return filelock.ReadFile(s.path(serverKey, name))
+}
+
+func (s *FileTokenStore) Save(serverKey, name string, data []byte) error {
+	if err := filelock.WriteFile(s.path(serverKey, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *FileTokenStore) Delete(serverKey, name string) error {
+	return filelock.Remove(s.path(serverKey, name))
+}
+
+// KeyringTokenStore stores credential documents in the OS credential store:
+// macOS Keychain, Windows Credential Manager or the Linux Secret Service.
+type KeyringTokenStore struct {
+	service string
+}
+
+// NewKeyringTokenStore creates a token store backed by the OS credential store.
+func NewKeyringTokenStore() *KeyringTokenStore {
+	return &KeyringTokenStore{service: keyringService}
+}
+
+func (s *KeyringTokenStore) Name() string {
+	return TokenStoreKeychain
+}
+
+func (s *KeyringTokenStore) account(serverKey, name string) string {
+	return serverKey + "/" + name
+}
+
+func (s *KeyringTokenStore) Load(serverKey, name string) ([]byte, error) {
+	secret, err := keyring.Get(s.service, s.account(serverKey, name))
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return nil, fmt.Errorf("%s not found in keychain: %w", name, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("keychain read failed: %w", err)
+	}
+	return []byte(secret), nil
+}
+
+func (s *KeyringTokenStore) Save(serverKey, name string, data []byte) error {
+	if err := keyring.Set(s.service, s.account(serverKey, name), string(data)); err != nil {
+		return fmt.Errorf("keychain write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *KeyringTokenStore) Delete(serverKey, name string) error {
+	err := keyring.Delete(s.service, s.account(serverKey, name))
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("keychain delete failed: %w", err)
+	}
+	return nil
+}
+
+// fallbackTokenStore uses primary and falls back to fallback when primary is
+// unavailable (e.g. no Secret Service on a headless Linux box). Entries that
+// exist only in fallback are still found, so switching an existing setup to
+// the keychain keeps previously saved credentials working.
+type fallbackTokenStore struct {
+	primary  TokenStore
+	fallback TokenStore
+}
+
+func (s *fallbackTokenStore) Name() string {
+	return s.primary.Name()
+}
+
+func (s *fallbackTokenStore) Load(serverKey, name string) ([]byte, error) {
+	data, err := s.primary.Load(serverKey, name)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("token store unavailable, falling back", "store", s.primary.Name(), "fallback", s.fallback.Name(), "error", err)
+	}
+	return s.fallback.Load(serverKey, name)
+}
+
+func (s *fallbackTokenStore) Save(serverKey, name string, data []byte) error {
+	if err := s.primary.Save(serverKey, name, data); err != nil {
+		slog.Warn("token store unavailable, falling back", "store", s.primary.Name(), "fallback", s.fallback.Name(), "error", err)
+		return s.fallback.Save(serverKey, name, data)
+	}
+	// Remove any stale plaintext copy now that the primary store holds it.
+	if err := s.fallback.Delete(serverKey, name); err != nil {
+		slog.Warn("failed to remove fallback copy", "store", s.fallback.Name(), "name", name, "error", err)
+	}
+	return nil
+}
+
+func (s *fallbackTokenStore) Delete(serverKey, name string) error {
+	if err := s.primary.Delete(serverKey, name); err != nil {
+		slog.Warn("failed to delete from token store", "store", s.primary.Name(), "name", name, "error", err)
+	}
+	return s.fallback.Delete(serverKey, name)
+}
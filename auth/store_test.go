@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryTokenStore is an in-memory TokenStore used to exercise store wiring.
+type memoryTokenStore struct {
+	entries map[string][]byte
+	err     error
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{entries: make(map[string][]byte)}
+}
+
+func (m *memoryTokenStore) Name() string { return "memory" }
+
+func (m *memoryTokenStore) Load(serverKey, name string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	data, ok := m.entries[serverKey+"/"+name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (m *memoryTokenStore) Save(serverKey, name string, data []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.entries[serverKey+"/"+name] = data
+	return nil
+}
+
+func (m *memoryTokenStore) Delete(serverKey, name string) error {
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.entries, serverKey+"/"+name)
+	return nil
+}
+
+func TestNewTokenStore(t *testing.T) {
+	for _, kind := range []string{"", TokenStoreFile, TokenStoreKeychain} {
+		store, err := NewTokenStore(kind)
+		if err != nil {
+			t.Errorf("NewTokenStore(%q) failed: %v", kind, err)
+			continue
+		}
+		if store == nil {
+			t.Errorf("NewTokenStore(%q) returned nil store", kind)
+		}
+	}
+
+	if _, err := NewTokenStore("vault"); err == nil {
+		t.Error("Expected error for unknown token store")
+	}
+}
+
+func TestFileTokenStoreRoundTrip(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	store := NewFileTokenStore()
+	if _, err := store.Load("server", tokensFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist for missing entry, got %v", err)
+	}
+
+	if err := store.Save("server", tokensFile, []byte(`{"access_token":"abc"}`)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	path := filepath.Join(getConfigDir(), "server", tokensFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected tokens file on disk: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	data, err := store.Load("server", tokensFile)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if string(data) != `{"access_token":"abc"}` {
+		t.Errorf("Unexpected data: %s", data)
+	}
+
+	if err := store.Delete("server", tokensFile); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete("server", tokensFile); err != nil {
+		t.Errorf("Deleting a missing entry should not fail: %v", err)
+	}
+}
+
+func TestFallbackTokenStore(t *testing.T) {
+	primary := newMemoryTokenStore()
+	fallback := newMemoryTokenStore()
+	store := &fallbackTokenStore{primary: primary, fallback: fallback}
+
+	// Entries only present in the fallback (e.g. pre-existing files) are found.
+	fallback.entries["server/"+tokensFile] = []byte("legacy")
+	data, err := store.Load("server", tokensFile)
+	if err != nil || string(data) != "legacy" {
+		t.Fatalf("Expected legacy entry from fallback, got %q, %v", data, err)
+	}
+
+	// Saving to a working primary removes the fallback copy.
+	if err := store.Save("server", tokensFile, []byte("new")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if string(primary.entries["server/"+tokensFile]) != "new" {
+		t.Error("Expected entry saved to primary")
+	}
+	if _, ok := fallback.entries["server/"+tokensFile]; ok {
+		t.Error("Expected fallback copy to be removed")
+	}
+
+	// An unavailable primary falls back for both reads and writes.
+	primary.err = errors.New("secret service unavailable")
+	if err := store.Save("server", clientInfoFile, []byte("client")); err != nil {
+		t.Fatalf("Save with unavailable primary failed: %v", err)
+	}
+	if string(fallback.entries["server/"+clientInfoFile]) != "client" {
+		t.Error("Expected entry saved to fallback")
+	}
+	data, err = store.Load("server", clientInfoFile)
+	if err != nil || string(data) != "client" {
+		t.Errorf("Expected entry loaded from fallback, got %q, %v", data, err)
+	}
+}
+
+func TestCoordinatorUsesTokenStore(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	store := newMemoryTokenStore()
+	coordinator, err := NewCoordinator("store-test", 3334, WithTokenStore(store))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+
+	if err := coordinator.SaveTokens(&Tokens{AccessToken: "stored"}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if _, ok := store.entries["store-test/"+tokensFile]; !ok {
+		t.Fatal("Expected tokens to be written to the configured store")
+	}
+
+	tokens, err := coordinator.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if tokens.AccessToken != "stored" {
+		t.Errorf("Expected access token 'stored', got %q", tokens.AccessToken)
+	}
+
+	if err := coordinator.saveClientInfo(&ClientInfo{ClientID: "client-1"}); err != nil {
+		t.Fatalf("saveClientInfo failed: %v", err)
+	}
+	if _, ok := store.entries["store-test/"+clientInfoFile]; !ok {
+		t.Error("Expected client info to be written to the configured store")
+	}
+}
+
+func TestCoordinatorTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", dir)
+	want := filepath.Join(dir, "token-file-test", tokensFile)
+
+	for _, store := range []TokenStore{NewFileTokenStore(), NewCodecTokenStore(NewFileTokenStore(), nil)} {
+		coordinator, err := NewCoordinator("token-file-test", 3334, WithTokenStore(store))
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		if got := coordinator.TokenFile(); got != want {
+			t.Errorf("TokenFile() = %q, want %q", got, want)
+		}
+	}
+
+	coordinator, err := NewCoordinator("token-file-test", 3334, WithTokenStore(newMemoryTokenStore()))
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if got := coordinator.TokenFile(); got != "" {
+		t.Errorf("Expected no token file for a store keeping tokens elsewhere, got %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// errTokensChanged is returned by writeTokens when the stored tokens are
+// no longer the version the new ones replace.
+var errTokensChanged = errors.New("tokens were replaced by another process")
+
+// tokenLockName is the lock serializing token updates across the proxies
+// sharing a server's credentials, whichever TokenStore holds them.
+const tokenLockName = "tokens-update"
+
+// tokenLockTimeout is how long a token update waits for another process's,
+// which may include a token refresh request.
+const tokenLockTimeout = 45 * time.Second
+
+// withTokenLock runs fn while holding the server's token update lock.
+func (c *Coordinator) withTokenLock(fn func() error) error {
+	dir := filepath.Join(getConfigDir(), c.serverURLHash)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return filelock.New(filepath.Join(dir, tokenLockName)).WithLock(tokenLockTimeout, fn)
+}
+
+// readTokens loads the stored tokens.
+func (c *Coordinator) readTokens() (*Tokens, error) {
+	data, err := c.store.Load(c.serverURLHash, tokensFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens Tokens
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens file: %w", err)
+	}
+	return &tokens, nil
+}
+
+// writeTokens saves tokens in place of the stored tokens of the given
+// version, failing with errTokensChanged if another version is stored: a
+// compare-and-swap, for writers that do not hold the token update lock.
+// The caller holds the lock.
+func (c *Coordinator) writeTokens(tokens *Tokens, version int64) error {
+	if stored, err := c.readTokens(); err == nil && stored.Version != version {
+		return errTokensChanged
+	}
+
+	if tokens.ExpiresAt == 0 && tokens.ExpiresIn > 0 {
+		tokens.ExpiresAt = time.Now().Unix() + int64(tokens.ExpiresIn)
+	}
+	if tokens.Scope == "" {
+		tokens.Scope = c.scope()
+	}
+	tokens.Version = version + 1
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokens: %w", err)
+	}
+	if err := c.store.Save(c.serverURLHash, tokensFile, data); err != nil {
+		return err
+	}
+	c.tokenVersion.Store(tokens.Version)
+	return nil
+}
+
+// refreshedElsewhere reports whether current, read under the token update
+// lock, was saved by another process since this one last used the tokens,
+// and is still fresh: refreshing again would only rotate the refresh token
+// once more.
+func (c *Coordinator) refreshedElsewhere(current *Tokens) bool {
+	seen := c.tokenVersion.Load()
+	return seen != 0 && current.Version != seen && current.AccessToken != "" &&
+		!current.expiresWithin(time.Now(), tokenRefreshSkew)
+}
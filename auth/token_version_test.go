@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRotatingRefreshServer returns a token endpoint that rotates refresh
+// tokens: each refresh token can be used once, starting with "rt-0".
+func newRotatingRefreshServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	valid := "rt-0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Form.Get("refresh_token") != valid {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		n := calls.Add(1)
+		valid = fmt.Sprintf("rt-%d", n)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("at-%d", n),
+			"refresh_token": valid,
+			"expires_in":    3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSaveTokensIncrementsVersion(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("token-version", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		if err := coordinator.SaveTokens(&Tokens{AccessToken: "a"}); err != nil {
+			t.Fatalf("SaveTokens failed: %v", err)
+		}
+		loaded, err := coordinator.LoadTokens()
+		if err != nil {
+			t.Fatalf("LoadTokens failed: %v", err)
+		}
+		if loaded.Version != want {
+			t.Errorf("Version = %d, want %d", loaded.Version, want)
+		}
+	}
+}
+
+func TestWriteTokensRejectsReplacedVersion(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	coordinator, err := NewCoordinator("token-cas", 3334)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	for range 2 {
+		if err := coordinator.SaveTokens(&Tokens{AccessToken: "newer"}); err != nil {
+			t.Fatalf("SaveTokens failed: %v", err)
+		}
+	}
+
+	err = coordinator.withTokenLock(func() error {
+		return coordinator.writeTokens(&Tokens{AccessToken: "stale"}, 1)
+	})
+	if !errors.Is(err, errTokensChanged) {
+		t.Fatalf("writeTokens error = %v, want errTokensChanged", err)
+	}
+	loaded, err := coordinator.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded.AccessToken != "newer" || loaded.Version != 2 {
+		t.Errorf("stored tokens = %q version %d, want the newer ones", loaded.AccessToken, loaded.Version)
+	}
+}
+
+// TestConcurrentRefreshUsesNewestRefreshToken simulates two proxies sharing
+// tokens with a server that rotates refresh tokens: only one refreshes, and
+// the other picks up its tokens instead of presenting the spent refresh
+// token.
+func TestConcurrentRefreshUsesNewestRefreshToken(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := newRotatingRefreshServer(t, &calls)
+
+	var coordinators []*Coordinator
+	for range 2 {
+		c, err := NewCoordinator("rotating-refresh", 3334)
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		c.serverMetadata = &ServerMetadata{TokenEndpoint: server.URL + "/token"}
+		c.clientInfo = &ClientInfo{ClientID: "test-client"}
+		coordinators = append(coordinators, c)
+	}
+	expired := &Tokens{AccessToken: "at-0", RefreshToken: "rt-0", ExpiresAt: time.Now().Unix() - 1}
+	if err := coordinators[0].SaveTokens(expired); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	for _, c := range coordinators {
+		if _, err := c.LoadTokens(); err != nil {
+			t.Fatalf("LoadTokens failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*Tokens, len(coordinators))
+	errs := make([]error, len(coordinators))
+	for i, c := range coordinators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.RefreshTokens(t.Context())
+		}()
+	}
+	wg.Wait()
+
+	for i := range coordinators {
+		if errs[i] != nil {
+			t.Fatalf("RefreshTokens %d failed: %v", i, errs[i])
+		}
+		if results[i].AccessToken != "at-1" {
+			t.Errorf("RefreshTokens %d returned %q, want at-1", i, results[i].AccessToken)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("token endpoint called %d times, want 1", got)
+	}
+
+	// A later refresh presents the rotated refresh token.
+	stored, err := coordinators[1].LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if stored.RefreshToken != "rt-1" || stored.Version != 2 {
+		t.Errorf("stored refresh token %q version %d, want rt-1 version 2", stored.RefreshToken, stored.Version)
+	}
+	tokens, err := coordinators[1].RefreshTokens(t.Context())
+	if err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	if tokens.AccessToken != "at-2" {
+		t.Errorf("RefreshTokens returned %q, want at-2", tokens.AccessToken)
+	}
+}
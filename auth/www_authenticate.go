@@ -13,51 +13,193 @@ type BearerChallenge struct {
 	ErrorDescription string
 }
 
+// Challenge is one challenge of a WWW-Authenticate header (RFC 9110 §11.6.1).
+type Challenge struct {
+	// Scheme is the auth-scheme, lowercased.
+	Scheme string
+	// Token68 is set for challenges of the form "Scheme token68".
+	Token68 string
+	// Params holds the auth-params by lowercased name.
+	Params map[string]string
+}
+
 // ParseWWWAuthenticate returns the first Bearer challenge found in a
 // WWW-Authenticate header, tolerating multiple challenges in the same header.
 func ParseWWWAuthenticate(header string) (BearerChallenge, bool) {
-	header = strings.TrimSpace(header)
-	if header == "" {
-		return BearerChallenge{}, false
+	for _, c := range ParseChallenges(header) {
+		if c.Scheme != "bearer" {
+			continue
+		}
+		return BearerChallenge{
+			ResourceMetadata: c.Params["resource_metadata"],
+			Realm:            c.Params["realm"],
+			Scope:            c.Params["scope"],
+			Error:            c.Params["error"],
+			ErrorDescription: c.Params["error_description"],
+		}, true
 	}
+	return BearerChallenge{}, false
+}
 
-	// A WWW-Authenticate header may contain multiple challenges separated by
-	// commas, but parameters are also comma-separated, which makes naive
-	// splitting unsafe. Scan all "bearer" occurrences and accept the first
-	// one on a proper token boundary, so a non-boundary substring (e.g.
-	// "MyBearer") does not mask a real Bearer challenge later in the header.
-	const scheme = "bearer"
-	lower := strings.ToLower(header)
+// ParseChallenges splits a WWW-Authenticate field value into its challenges.
+// Challenges and their parameters are both comma-separated, so a comma
+// starts a new challenge only when it is followed by a token that is not a
+// parameter name. Quoted strings may contain commas, escaped quotes and
+// scheme names without affecting the split. Malformed input is skipped
+// rather than rejected.
+func ParseChallenges(header string) []Challenge {
+	p := challengeParser{s: header}
+	var challenges []Challenge
+	for {
+		p.skip(" \t,")
+		if p.done() {
+			return challenges
+		}
+		scheme := p.token()
+		if scheme == "" {
+			p.i++ // stray character
+			continue
+		}
+		c := Challenge{Scheme: strings.ToLower(scheme), Params: make(map[string]string)}
 
-	for offset := 0; offset < len(lower); {
-		idx := strings.Index(lower[offset:], scheme)
-		if idx < 0 {
-			return BearerChallenge{}, false
+		p.skip(" \t")
+		if t, ok := p.token68(); ok {
+			c.Token68 = t
+		} else {
+			p.params(c.Params)
 		}
-		pos := offset + idx
-		offset = pos + len(scheme)
-
-		if pos > 0 {
-			prev := header[pos-1]
-			if prev != ' ' && prev != ',' && prev != '\t' {
-				continue
-			}
+		challenges = append(challenges, c)
+	}
+}
+
+// challengeParser scans a WWW-Authenticate field value.
+type challengeParser struct {
+	s string
+	i int
+}
+
+func (p *challengeParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *challengeParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// token reads an RFC 9110 token.
+func (p *challengeParser) token() string {
+	start := p.i
+	for !p.done() && isTChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// token68 reads a token68 when one follows: token68 characters, optional
+// trailing '=' padding, then the end of the challenge. Anything else is left
+// for params.
+func (p *challengeParser) token68() (string, bool) {
+	start := p.i
+	end := start
+	for end < len(p.s) && isToken68Char(p.s[end]) {
+		end++
+	}
+	if end == start {
+		return "", false
+	}
+	for end < len(p.s) && p.s[end] == '=' {
+		end++
+	}
+	rest := end
+	for rest < len(p.s) && (p.s[rest] == ' ' || p.s[rest] == '\t') {
+		rest++
+	}
+	if rest < len(p.s) && p.s[rest] != ',' {
+		return "", false
+	}
+	p.i = end
+	return p.s[start:end], true
+}
+
+// isParamAt reports whether an auth-param ("name=value") starts at i.
+func (p *challengeParser) isParamAt(i int) bool {
+	q := challengeParser{s: p.s, i: i}
+	if q.token() == "" {
+		return false
+	}
+	q.skip(" \t")
+	if q.done() || q.s[q.i] != '=' {
+		return false
+	}
+	q.i++
+	q.skip(" \t")
+	return !q.done() && q.s[q.i] != ','
+}
+
+// params reads comma-separated auth-params into params until the next
+// challenge starts.
+func (p *challengeParser) params(params map[string]string) {
+	for {
+		p.skip(" \t")
+		for !p.done() && p.s[p.i] == ',' {
+			p.i++
+			p.skip(" \t")
+		}
+		if p.done() || !p.isParamAt(p.i) {
+			return
+		}
+		name := strings.ToLower(p.token())
+		p.skip(" \t")
+		p.i++ // '='
+		p.skip(" \t")
+		value := p.value()
+		if _, dup := params[name]; !dup {
+			params[name] = value
 		}
-		rest := header[pos+len(scheme):]
-		if rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != ',' {
-			continue
+	}
+}
+
+// value reads a token or quoted-string.
+func (p *challengeParser) value() string {
+	if p.done() || p.s[p.i] != '"' {
+		return p.token()
+	}
+	p.i++ // opening quote
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '\\' && !p.done():
+			b.WriteByte(p.s[p.i])
+			p.i++
+		case c == '"':
+			return b.String()
+		default:
+			b.WriteByte(c)
 		}
+	}
+	return b.String()
+}
 
-		params := parseAuthParams(strings.TrimSpace(rest))
-		return BearerChallenge{
-			ResourceMetadata: params["resource_metadata"],
-			Realm:            params["realm"],
-			Scope:            params["scope"],
-			Error:            params["error"],
-			ErrorDescription: params["error_description"],
-		}, true
+// isTChar reports whether c is an RFC 9110 tchar.
+func isTChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
 	}
-	return BearerChallenge{}, false
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// isToken68Char reports whether c may appear in a token68 before padding.
+func isToken68Char(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~+/", c) >= 0
 }
 
 // BestWWWAuthenticateHeader selects the most useful WWW-Authenticate field value
@@ -101,76 +243,3 @@ func ParseWWWAuthenticateHeaders(headers []string) (BearerChallenge, bool) {
 	}
 	return ParseWWWAuthenticate(h)
 }
-
-// parseAuthParams parses a comma-separated list of key=value or key="value"
-// pairs. Values containing commas must be quoted. Unrecognised tokens
-// (e.g. another auth-scheme starting after a comma) terminate parsing.
-func parseAuthParams(s string) map[string]string {
-	params := make(map[string]string)
-	i := 0
-	for i < len(s) {
-		// Skip leading whitespace and commas.
-		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == ',') {
-			i++
-		}
-		if i >= len(s) {
-			break
-		}
-
-		// Read key up to '='.
-		keyStart := i
-		for i < len(s) && s[i] != '=' && s[i] != ',' && s[i] != ' ' && s[i] != '\t' {
-			i++
-		}
-		key := strings.ToLower(s[keyStart:i])
-		if key == "" {
-			return params
-		}
-		// Skip whitespace.
-		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
-			i++
-		}
-		if i >= len(s) || s[i] != '=' {
-			// A token without '=' likely marks the start of a new
-			// challenge (e.g. "Basic realm=..."); stop parsing.
-			return params
-		}
-		i++ // consume '='
-		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
-			i++
-		}
-
-		// Read value: quoted or unquoted.
-		var value string
-		if i < len(s) && s[i] == '"' {
-			i++ // consume opening quote
-			var b strings.Builder
-			for i < len(s) {
-				c := s[i]
-				if c == '\\' && i+1 < len(s) {
-					b.WriteByte(s[i+1])
-					i += 2
-					continue
-				}
-				if c == '"' {
-					break
-				}
-				b.WriteByte(c)
-				i++
-			}
-			value = b.String()
-			if i < len(s) && s[i] == '"' {
-				i++ // consume closing quote
-			}
-		} else {
-			valStart := i
-			for i < len(s) && s[i] != ',' && s[i] != ' ' && s[i] != '\t' {
-				i++
-			}
-			value = s[valStart:i]
-		}
-
-		params[key] = value
-	}
-	return params
-}
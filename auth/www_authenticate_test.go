@@ -1,6 +1,9 @@
 package auth
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestParseWWWAuthenticate(t *testing.T) {
 	tests := []struct {
@@ -179,3 +182,74 @@ func TestParseWWWAuthenticateHeaders(t *testing.T) {
 		t.Errorf("ResourceMetadata = %q", challenge.ResourceMetadata)
 	}
 }
+
+func TestParseWWWAuthenticateIgnoresSchemeInQuotedString(t *testing.T) {
+	header := `Basic realm="use bearer, tokens", Bearer resource_metadata="https://example.com/prm"`
+	challenge, ok := ParseWWWAuthenticate(header)
+	if !ok || challenge.ResourceMetadata != "https://example.com/prm" {
+		t.Errorf("Expected the real Bearer challenge, got %+v, %v", challenge, ok)
+	}
+
+	if _, ok := ParseWWWAuthenticate(`Basic realm="Bearer realm=fake"`); ok {
+		t.Error("Expected no Bearer challenge inside a quoted string")
+	}
+}
+
+func TestParseChallenges(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []Challenge
+	}{
+		{
+			name:   "empty",
+			header: "",
+			want:   nil,
+		},
+		{
+			name:   "scheme only",
+			header: "Bearer",
+			want:   []Challenge{{Scheme: "bearer", Params: map[string]string{}}},
+		},
+		{
+			name:   "token68 then params",
+			header: `Negotiate YIIB+g==, Bearer realm="mcp"`,
+			want: []Challenge{
+				{Scheme: "negotiate", Token68: "YIIB+g==", Params: map[string]string{}},
+				{Scheme: "bearer", Params: map[string]string{"realm": "mcp"}},
+			},
+		},
+		{
+			name:   "escaped quotes and commas",
+			header: `Bearer error_description="say \"hi\", please", Error=invalid_token`,
+			want: []Challenge{
+				{Scheme: "bearer", Params: map[string]string{"error_description": `say "hi", please`, "error": "invalid_token"}},
+			},
+		},
+		{
+			name:   "whitespace around equals and first duplicate wins",
+			header: `Bearer realm = "a", realm="b",  Basic realm="c"`,
+			want: []Challenge{
+				{Scheme: "bearer", Params: map[string]string{"realm": "a"}},
+				{Scheme: "basic", Params: map[string]string{"realm": "c"}},
+			},
+		},
+		{
+			name:   "empty list elements",
+			header: `, Bearer , , Basic realm=x`,
+			want: []Challenge{
+				{Scheme: "bearer", Params: map[string]string{}},
+				{Scheme: "basic", Params: map[string]string{"realm": "x"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseChallenges(tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseChallenges(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -75,6 +76,18 @@ func TestParseRemainingArgs_HeaderAfterURL(t *testing.T) {
 	}
 }
 
+func TestParseRemainingArgs_RepeatedScope(t *testing.T) {
+	remaining := []string{"https://example.com/mcp", "--scope", "read", "--scope", "write"}
+	cfg := parseRemainingArgs(remaining, cliConfig{
+		callbackPort:  3334,
+		transportMode: "auto",
+	})
+
+	if len(cfg.scopes) != 2 || cfg.scopes[0] != "read" || cfg.scopes[1] != "write" {
+		t.Errorf("Expected scopes [read write], got %v", cfg.scopes)
+	}
+}
+
 func TestParseRemainingArgs_PortAfterURL(t *testing.T) {
 	remaining := []string{"https://example.com/mcp", "--port", "9090"}
 	cfg := parseRemainingArgs(remaining, cliConfig{
@@ -308,6 +321,18 @@ func TestParseRemainingArgs_HttpsProxyAfterURL(t *testing.T) {
 	}
 }
 
+func TestParseRemainingArgs_ProxyURLSocks5(t *testing.T) {
+	remaining := []string{"https://example.com/mcp", "--proxy-url", "socks5://proxy:1080"}
+	cfg := parseRemainingArgs(remaining, cliConfig{
+		callbackPort:  3334,
+		transportMode: "auto",
+	})
+
+	if cfg.httpProxy != "socks5://proxy:1080" {
+		t.Errorf("Expected proxy 'socks5://proxy:1080', got '%s'", cfg.httpProxy)
+	}
+}
+
 func TestParseRemainingArgs_HttpsProxyEqualsForm(t *testing.T) {
 	remaining := []string{"https://example.com/mcp", "--https-proxy=http://proxy:3128"}
 	cfg := parseRemainingArgs(remaining, cliConfig{
@@ -637,3 +662,57 @@ func TestApplyEnvOverrides_AuthHeaderUnsubstitutedPlaceholderSkipped(t *testing.
 		t.Fatalf("expected no headers, got %v", headers)
 	}
 }
+
+func TestParseRemainingArgs_TokenStoreAfterURL(t *testing.T) {
+	remaining := []string{"https://example.com/mcp", "--token-store", "keychain"}
+	cfg := parseRemainingArgs(remaining, defaultCLIConfig())
+
+	if cfg.serverURL != "https://example.com/mcp" {
+		t.Errorf("Expected server URL 'https://example.com/mcp', got '%s'", cfg.serverURL)
+	}
+	if cfg.tokenStore != "keychain" {
+		t.Errorf("Expected token store 'keychain', got '%s'", cfg.tokenStore)
+	}
+}
+
+func TestParseRemainingArgs_DoubleDashTerminator(t *testing.T) {
+	remaining := []string{"--transport", "sse", "--", "https://example.com/mcp", "--allow-http"}
+	cfg := parseRemainingArgs(remaining, defaultCLIConfig())
+
+	if cfg.transportMode != "sse" {
+		t.Errorf("Expected transport mode 'sse', got '%s'", cfg.transportMode)
+	}
+	if cfg.serverURL != "https://example.com/mcp" {
+		t.Errorf("Expected server URL 'https://example.com/mcp', got '%s'", cfg.serverURL)
+	}
+	if cfg.allowHTTP {
+		t.Error("Flags after '--' must be treated as positional arguments")
+	}
+}
+
+func TestParseRemainingArgs_RepeatedServer(t *testing.T) {
+	remaining := []string{"--server", "github=https://api.github.com/mcp", "--server", "linear=https://mcp.linear.app/sse"}
+	cfg := parseRemainingArgs(remaining, defaultCLIConfig())
+
+	if len(cfg.servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %v", cfg.servers)
+	}
+	if cfg.servers[1] != "linear=https://mcp.linear.app/sse" {
+		t.Errorf("Expected second server 'linear=https://mcp.linear.app/sse', got '%s'", cfg.servers[1])
+	}
+	if cfg.serverURL != "github=https://api.github.com/mcp" {
+		t.Errorf("Expected server URL to be the first -server value, got '%s'", cfg.serverURL)
+	}
+}
+
+func TestParseRemainingArgs_ToolFilters(t *testing.T) {
+	remaining := []string{"https://example.com/mcp", "--allow-tool", "search_*", "--allow-tool=get_*", "--deny-tool", "get_secret"}
+	cfg := parseRemainingArgs(remaining, defaultCLIConfig())
+
+	if !reflect.DeepEqual(cfg.allowTools, []string{"search_*", "get_*"}) {
+		t.Errorf("Expected allow patterns [search_* get_*], got %v", cfg.allowTools)
+	}
+	if !reflect.DeepEqual(cfg.denyTools, []string{"get_secret"}) {
+		t.Errorf("Expected deny patterns [get_secret], got %v", cfg.denyTools)
+	}
+}
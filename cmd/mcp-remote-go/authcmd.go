@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+const authUsage = `Usage: mcp-remote-go auth [-token-store file|keychain] [-encrypt-store] [-config-dir <dir>] [-profile <name>] [-audit-log] <command>
+
+Commands:
+  list                  List servers with cached credentials
+  show <server-url>     Show the cached credentials of a server
+  refresh <server-url>  Refresh the access token of a server now
+  clear <server-url>    Revoke the tokens of a server, if it supports
+                        revocation, and delete its cached credentials
+  prune [-older-than <duration>]
+                        Delete the credentials of servers unused for a
+                        duration (default 2160h, 90 days) whose access
+                        token has expired
+
+A server may also be given by the key shown by "list". With -profile, a
+server URL refers to the credentials kept under that profile.
+`
+
+// runAuthCommand implements "mcp-remote-go auth", which inspects and manages
+// cached credentials without starting the proxy. Tokens themselves are never
+// printed.
+func runAuthCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("mcp-remote-go auth", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	tokenStore := flags.String("token-store", auth.TokenStoreFile, "Credential storage backend: file, keychain")
+	encryptStore := flags.Bool("encrypt-store", false, "Credentials are encrypted")
+	configDir := flags.String("config-dir", "", "Directory credentials are stored in")
+	profile := flags.String("profile", "", "Profile the credentials are kept under")
+	auditLog := flags.Bool("audit-log", false, "Append credential events to the audit log")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, authUsage)
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(authUsage)
+	}
+
+	if *configDir != "" {
+		auth.SetConfigDir(*configDir)
+	}
+	auth.SetAuditLog(*auditLog)
+
+	store, err := newTokenStore(*tokenStore, *encryptStore)
+	if err != nil {
+		return err
+	}
+
+	command, args := args[0], args[1:]
+	if command == "list" {
+		if len(args) != 0 {
+			return errors.New(authUsage)
+		}
+		return authList(stdout, store)
+	}
+	if command == "prune" {
+		return authPrune(stdout, store, args)
+	}
+	if len(args) != 1 {
+		return errors.New(authUsage)
+	}
+	key := auth.ResolveServerKey(args[0])
+	if *profile != "" && !auth.IsServerKey(args[0]) {
+		if err := auth.ValidateProfile(*profile); err != nil {
+			return err
+		}
+		key = auth.ProfileServerKey(args[0], *profile)
+	}
+
+	switch command {
+	case "show":
+		return authShow(stdout, store, key)
+	case "refresh":
+		return authRefresh(stdout, store, key)
+	case "clear":
+		return authClear(stdout, store, key)
+	default:
+		return fmt.Errorf("unknown auth command %q\n\n%s", command, authUsage)
+	}
+}
+
+func authList(w io.Writer, store auth.TokenStore) error {
+	servers, err := auth.ListServers(store)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		_, _ = fmt.Fprintln(w, "No cached credentials.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SERVER\tKEY\tEXPIRES")
+	now := time.Now()
+	for _, s := range servers {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", displayURL(s.URL), s.Key, describeExpiry(s.Tokens, now))
+	}
+	return tw.Flush()
+}
+
+func authShow(w io.Writer, store auth.TokenStore, key string) error {
+	s, err := auth.LoadServer(store, key)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Server:\t%s\n", displayURL(s.URL))
+	_, _ = fmt.Fprintf(tw, "Key:\t%s\n", s.Key)
+	_, _ = fmt.Fprintf(tw, "Token store:\t%s\n", store.Name())
+	if s.Tokens != nil {
+		_, _ = fmt.Fprintf(tw, "Token type:\t%s\n", s.Tokens.TokenType)
+		_, _ = fmt.Fprintf(tw, "Refresh token:\t%s\n", presence(s.Tokens.RefreshToken))
+		if s.Tokens.Scope != "" {
+			_, _ = fmt.Fprintf(tw, "Scope:\t%s\n", s.Tokens.Scope)
+		}
+	}
+	_, _ = fmt.Fprintf(tw, "Expires:\t%s\n", describeExpiry(s.Tokens, time.Now()))
+	_, _ = fmt.Fprintf(tw, "Directory:\t%s\n", auth.ServerDir(key))
+	return tw.Flush()
+}
+
+func authRefresh(w io.Writer, store auth.TokenStore, key string) error {
+	s, err := auth.LoadServer(store, key)
+	if err != nil {
+		return err
+	}
+	opts := []auth.CoordinatorOption{auth.WithTokenStore(store)}
+	if resource, err := auth.CanonicalResourceURI(s.URL); err == nil {
+		opts = append(opts, auth.WithResource(resource))
+	}
+	coord, err := auth.NewCoordinator(key, 0, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	tokens, err := coord.RefreshTokens(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh failed: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "Token refreshed; %s\n", describeExpiry(tokens, time.Now()))
+	return nil
+}
+
+func authClear(w io.Writer, store auth.TokenStore, key string) error {
+	s, err := auth.LoadServer(store, key)
+	if err != nil {
+		return err
+	}
+	if s.Tokens != nil {
+		revokeTokens(w, store, key)
+	}
+	if err := auth.ClearServer(store, key); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Cleared credentials for %s\n", displayURL(s.URL))
+	return nil
+}
+
+// revokeTokens revokes the tokens of the server with the given key at its
+// authorization server. Failures are reported but do not stop the local
+// credentials from being cleared.
+func revokeTokens(w io.Writer, store auth.TokenStore, key string) {
+	coord, err := auth.NewCoordinator(key, 0, auth.WithTokenStore(store))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err = coord.RevokeTokens(ctx)
+	}
+	switch {
+	case err == nil:
+		_, _ = fmt.Fprintln(w, "Revoked tokens at the authorization server")
+	case errors.Is(err, auth.ErrRevocationUnsupported):
+		// Nothing to revoke remotely; the tokens expire on their own.
+	default:
+		_, _ = fmt.Fprintf(w, "Warning: failed to revoke tokens: %v\n", err)
+	}
+}
+
+func authPrune(w io.Writer, store auth.TokenStore, args []string) error {
+	flags := flag.NewFlagSet("mcp-remote-go auth prune", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	olderThan := flags.Duration("older-than", auth.DefaultPruneAfter, "Delete credentials unused for this long")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, authUsage)
+	}
+	if flags.NArg() != 0 || *olderThan <= 0 {
+		return errors.New(authUsage)
+	}
+
+	removed, err := auth.PruneServers(store, *olderThan, time.Now())
+	for _, s := range removed {
+		_, _ = fmt.Fprintf(w, "Cleared credentials for %s\n", displayURL(s.URL))
+	}
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		_, _ = fmt.Fprintln(w, "Nothing to prune.")
+	}
+	return nil
+}
+
+// describeExpiry summarizes when the access token in tokens expires.
+func describeExpiry(tokens *auth.Tokens, now time.Time) string {
+	switch {
+	case tokens == nil || tokens.AccessToken == "":
+		return "no token"
+	case tokens.ExpiresAt == 0:
+		return "unknown"
+	}
+	at := time.Unix(tokens.ExpiresAt, 0)
+	if !at.After(now) {
+		return "expired " + at.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s (in %s)", at.Format(time.RFC3339), at.Sub(now).Round(time.Second))
+}
+
+func displayURL(serverURL string) string {
+	if serverURL == "" {
+		return "(unknown URL)"
+	}
+	return serverURL
+}
+
+func presence(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return "present"
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+const authTestServer = "https://example.com/mcp"
+
+// seedCredentials stores tokens for authTestServer as the proxy would.
+func seedCredentials(t *testing.T, tokens *auth.Tokens) string {
+	t.Helper()
+	key := auth.ServerKey(authTestServer)
+	coord, err := auth.NewCoordinator(key, 0)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	if err := coord.SaveTokens(tokens); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(auth.ServerDir(key), "server_url"), []byte(authTestServer+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthCommandListAndShow(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	seedCredentials(t, &auth.Tokens{AccessToken: "secret-access", RefreshToken: "secret-refresh", TokenType: "Bearer", ExpiresAt: expiresAt})
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"list"}, &out); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), authTestServer) || !strings.Contains(out.String(), time.Unix(expiresAt, 0).Format(time.RFC3339)) {
+		t.Errorf("Expected the server and its expiry in list output, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runAuthCommand([]string{"show", authTestServer}, &out); err != nil {
+		t.Fatalf("show failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Refresh token:  present") {
+		t.Errorf("Expected the refresh token to be reported, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "secret-") {
+		t.Errorf("Expected tokens not to be printed, got:\n%s", out.String())
+	}
+}
+
+func TestAuthCommandListEmpty(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"list"}, &out); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No cached credentials") {
+		t.Errorf("Expected an empty listing, got:\n%s", out.String())
+	}
+}
+
+func TestAuthCommandConfigDir(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	seedCredentials(t, &auth.Tokens{AccessToken: "access"})
+	defer auth.SetConfigDir("")
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"-config-dir", t.TempDir(), "list"}, &out); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No cached credentials") {
+		t.Errorf("Expected -config-dir to select another directory, got:\n%s", out.String())
+	}
+}
+
+func TestAuthCommandProfile(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	seedCredentials(t, &auth.Tokens{AccessToken: "access"})
+
+	var out bytes.Buffer
+	err := runAuthCommand([]string{"-profile", "work", "show", authTestServer}, &out)
+	if err == nil || !strings.Contains(err.Error(), auth.ProfileServerKey(authTestServer, "work")) {
+		t.Fatalf("Expected no credentials under the profile, got %v", err)
+	}
+
+	if err := runAuthCommand([]string{"-profile", "Work", "show", authTestServer}, &out); err == nil {
+		t.Error("Expected an invalid profile to be rejected")
+	}
+}
+
+func TestAuthCommandPrune(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	key := seedCredentials(t, &auth.Tokens{AccessToken: "access", ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"prune"}, &out); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Nothing to prune") {
+		t.Errorf("Expected recently used credentials kept, got:\n%s", out.String())
+	}
+
+	old := time.Now().Add(-time.Hour)
+	for _, path := range []string{filepath.Join(auth.ServerDir(key), "tokens.json"), filepath.Join(auth.ServerDir(key), "server_url"), auth.ServerDir(key)} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Reset()
+	if err := runAuthCommand([]string{"prune", "-older-than", "30m"}, &out); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Cleared credentials for "+authTestServer) {
+		t.Errorf("Expected the expired credentials cleared, got:\n%s", out.String())
+	}
+}
+
+func TestAuthCommandClear(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	key := seedCredentials(t, &auth.Tokens{AccessToken: "access"})
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"clear", key}, &out); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if _, err := os.Stat(auth.ServerDir(key)); !os.IsNotExist(err) {
+		t.Errorf("Expected the server directory to be removed, got %v", err)
+	}
+	if err := runAuthCommand([]string{"show", authTestServer}, &out); err == nil {
+		t.Error("Expected show to fail after clear")
+	}
+}
+
+func TestAuthCommandClearAuditLog(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	defer auth.SetAuditLog(false)
+	key := seedCredentials(t, &auth.Tokens{AccessToken: "access"})
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"-audit-log", "clear", key}, &out); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	data, err := os.ReadFile(auth.AuditLogPath())
+	if err != nil {
+		t.Fatalf("Expected an audit log: %v", err)
+	}
+	if !strings.Contains(string(data), `"event":"credentials_cleared"`) || !strings.Contains(string(data), authTestServer) {
+		t.Errorf("Expected the clear to be audited, got:\n%s", data)
+	}
+}
+
+func TestAuthCommandClearRevokes(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var revoked []string
+	revocationServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		revoked = append(revoked, r.Form.Get("token"))
+	}))
+	defer revocationServer.Close()
+
+	key := seedCredentials(t, &auth.Tokens{AccessToken: "access", RefreshToken: "refresh"})
+	metadata, _ := json.Marshal(auth.ServerMetadata{TokenEndpoint: revocationServer.URL + "/token", RevocationEndpoint: revocationServer.URL + "/revoke"})
+	if err := os.WriteFile(filepath.Join(auth.ServerDir(key), "server_metadata.json"), metadata, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.NewFileTokenStore().Save(key, "client_info.json", []byte(`{"client_id":"client"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"clear", key}, &out); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if len(revoked) != 2 || revoked[0] != "refresh" || revoked[1] != "access" {
+		t.Errorf("Expected both tokens revoked, got %v", revoked)
+	}
+	if !strings.Contains(out.String(), "Revoked tokens") || !strings.Contains(out.String(), "Cleared credentials") {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+	if _, err := os.Stat(auth.ServerDir(key)); !os.IsNotExist(err) {
+		t.Errorf("Expected the server directory to be removed, got %v", err)
+	}
+}
+
+func TestAuthCommandRefresh(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("refresh_token") != "refresh" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	key := seedCredentials(t, &auth.Tokens{AccessToken: "stale", RefreshToken: "refresh", ExpiresAt: 1})
+	metadata, _ := json.Marshal(auth.ServerMetadata{Issuer: tokenServer.URL, TokenEndpoint: tokenServer.URL + "/token"})
+	if err := os.WriteFile(filepath.Join(auth.ServerDir(key), "server_metadata.json"), metadata, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.NewFileTokenStore().Save(key, "client_info.json", []byte(`{"client_id":"client"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runAuthCommand([]string{"refresh", authTestServer}, &out); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	s, err := auth.LoadServer(auth.NewFileTokenStore(), key)
+	if err != nil {
+		t.Fatalf("LoadServer failed: %v", err)
+	}
+	if s.Tokens.AccessToken != "fresh" || s.Tokens.RefreshToken != "refresh" {
+		t.Errorf("Expected refreshed tokens to be saved, got %+v", s.Tokens)
+	}
+}
+
+func TestAuthCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"show"}, {"bogus", authTestServer}, {"-token-store", "nope", "list"}} {
+		if err := runAuthCommand(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
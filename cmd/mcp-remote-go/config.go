@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the configuration file read with -config. Keys mirror the
+// CLI flag names. YAML and JSON are both accepted, since JSON is valid YAML.
+type fileConfig struct {
+	Server          string            `yaml:"server"`
+	Servers         []serverConfig    `yaml:"servers"`
+	Transport       string            `yaml:"transport"`
+	Port            int               `yaml:"port"`
+	AllowHTTP       bool              `yaml:"allow-http"`
+	ProxyURL        string            `yaml:"proxy-url"`
+	HTTPSProxy      string            `yaml:"https-proxy"`
+	TokenStore      string            `yaml:"token-store"`
+	ConfigDir       string            `yaml:"config-dir"`
+	PruneAfter      *time.Duration    `yaml:"prune-after"`
+	HeaderRefresh   *time.Duration    `yaml:"header-refresh"`
+	EncryptStore    bool              `yaml:"encrypt-store"`
+	AuditLog        bool              `yaml:"audit-log"`
+	Headers         map[string]string `yaml:"headers"`
+	Scopes          []string          `yaml:"scopes"`
+	Resource        string            `yaml:"resource"`
+	LogLevel        string            `yaml:"log-level"`
+	LogFormat       string            `yaml:"log-format"`
+	TraceFile       string            `yaml:"trace-file"`
+	Record          string            `yaml:"record"`
+	Replay          string            `yaml:"replay"`
+	StdioFraming    string            `yaml:"stdio-framing"`
+	MaxMessageSize  int               `yaml:"max-message-size"`
+	MaxEventSize    int               `yaml:"max-event-size"`
+	MaxBodySize     int               `yaml:"max-body-size"`
+	AuthFlow        string            `yaml:"auth-flow"`
+	Provider        string            `yaml:"provider"`
+	AuthParams      map[string]string `yaml:"auth-params"`
+	Profile         string            `yaml:"profile"`
+	Auth            string            `yaml:"auth"`
+	AuthEnv         string            `yaml:"auth-env"`
+	ClientID        string            `yaml:"client-id"`
+	ClientSecret    string            `yaml:"client-secret"`
+	TokenAuthMethod string            `yaml:"token-auth-method"`
+	AssertionKey    string            `yaml:"client-assertion-key"`
+	AssertionKeyID  string            `yaml:"client-assertion-key-id"`
+	ResumeSession   bool              `yaml:"resume-session"`
+	NoBrowser       bool              `yaml:"no-browser"`
+	BrowserCmd      string            `yaml:"browser-cmd"`
+	NoCompression   bool              `yaml:"no-compression"`
+	Strict          bool              `yaml:"strict"`
+	EagerInit       bool              `yaml:"eager-init"`
+	DecorateClient  bool              `yaml:"decorate-client-info"`
+	ForwardLogs     bool              `yaml:"forward-server-logs"`
+	RevokeOnExit    bool              `yaml:"revoke-on-exit"`
+	ProtocolVersion string            `yaml:"protocol-version"`
+	Shared          bool              `yaml:"shared"`
+	Listen          string            `yaml:"listen"`
+	StatusPort      int               `yaml:"status-port"`
+	AllowTools      []string          `yaml:"allow-tools"`
+	RedactFields    []string          `yaml:"redact-fields"`
+	CallbackOrigins []string          `yaml:"callback-origins"`
+	DenyTools       []string          `yaml:"deny-tools"`
+	CACert          string            `yaml:"ca-cert"`
+	ClientCert      string            `yaml:"client-cert"`
+	ClientKey       string            `yaml:"client-key"`
+
+	CallbackSuccessPage   string        `yaml:"callback-success-page"`
+	CallbackErrorPage     string        `yaml:"callback-error-page"`
+	CallbackHost          string        `yaml:"callback-host"`
+	CallbackBind          string        `yaml:"callback-bind"`
+	CallbackTLS           bool          `yaml:"callback-tls"`
+	AssumePKCE            bool          `yaml:"assume-pkce"`
+	InsecureSkipTLSVerify bool          `yaml:"insecure-skip-tls-verify"`
+	MaxIdleConns          int           `yaml:"max-idle-conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max-idle-conns-per-host"`
+	MaxConnsPerHost       int           `yaml:"max-conns-per-host"`
+	IdleConnTimeout       time.Duration `yaml:"idle-conn-timeout"`
+	DisableHTTP2          bool          `yaml:"disable-http2"`
+	ShutdownTimeout       time.Duration `yaml:"shutdown-timeout"`
+	RequestTimeout        time.Duration `yaml:"request-timeout"`
+	AuthTimeout           time.Duration `yaml:"auth-timeout"`
+	HTTPTimeout           time.Duration `yaml:"http-timeout"`
+	HTTPRetryDelay        time.Duration `yaml:"http-retry-delay"`
+	ListCacheTTL          time.Duration `yaml:"list-cache-ttl"`
+	KeepaliveInterval     time.Duration `yaml:"keepalive-interval"`
+	ReconnectInitial      time.Duration `yaml:"reconnect-initial"`
+	ReconnectMultiplier   float64       `yaml:"reconnect-multiplier"`
+	ReconnectMaxWait      time.Duration `yaml:"reconnect-max-wait"`
+	ReconnectMaxAttempts  int           `yaml:"reconnect-max-attempts"`
+	MaxRPS                float64       `yaml:"max-rps"`
+	Burst                 int           `yaml:"burst"`
+	QueueWhenRateLimited  bool          `yaml:"queue-when-rate-limited"`
+	BreakerCooldown       time.Duration `yaml:"breaker-cooldown"`
+
+	// BreakerThreshold is a pointer so that 0 can disable the breaker.
+	BreakerThreshold *int `yaml:"breaker-threshold"`
+
+	// SendBuffer is a pointer so that 0 can disable buffering.
+	SendBuffer *int `yaml:"send-buffer"`
+	// OutputQueue is a pointer so that 0 can disable queueing.
+	OutputQueue *int `yaml:"output-queue"`
+	// HTTPRetries is a pointer so that 0 can disable retries.
+	HTTPRetries *int `yaml:"http-retries"`
+}
+
+// serverConfig describes one upstream server in aggregation mode. Headers are
+// added to the global headers; Scopes and Profile replace the global ones.
+type serverConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Scopes  []string          `yaml:"scopes"`
+	Profile string            `yaml:"profile"`
+}
+
+// loadConfigFile reads and validates the configuration file at path. A
+// leading "~/" is expanded because MCP clients usually launch the binary
+// without a shell.
+func loadConfigFile(path string) (*fileConfig, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fc fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if fc.Server != "" && len(fc.Servers) > 0 {
+		return nil, fmt.Errorf("config file %s: set either server or servers, not both", path)
+	}
+	for i, s := range fc.Servers {
+		if s.URL == "" {
+			return nil, fmt.Errorf("config file %s: servers[%d] is missing url", path, i)
+		}
+	}
+	return &fc, nil
+}
+
+// applyTo fills in cfg from the config file. Flags given explicitly on the
+// command line (and a positional server URL) take precedence; config headers
+// are added before CLI headers so a CLI header with the same name wins.
+func (fc *fileConfig) applyTo(cfg *cliConfig) {
+	if cfg.serverURL == "" {
+		switch {
+		case len(fc.Servers) > 0:
+			cfg.serverConfigs = fc.Servers
+			cfg.serverURL = fc.Servers[0].URL
+		case fc.Server != "":
+			cfg.serverURL = fc.Server
+		}
+	}
+	if fc.Transport != "" && !cfg.setFlags["transport"] {
+		cfg.transportMode = fc.Transport
+	}
+	if fc.Port != 0 && !cfg.setFlags["port"] {
+		cfg.callbackPort = fc.Port
+	}
+	if fc.AllowHTTP && !cfg.setFlags["allow-http"] {
+		cfg.allowHTTP = true
+	}
+	if !cfg.setFlags["proxy-url"] && !cfg.setFlags["https-proxy"] {
+		switch {
+		case fc.ProxyURL != "":
+			cfg.httpProxy = fc.ProxyURL
+		case fc.HTTPSProxy != "":
+			cfg.httpProxy = fc.HTTPSProxy
+		}
+	}
+	if fc.TokenStore != "" && !cfg.setFlags["token-store"] {
+		cfg.tokenStore = fc.TokenStore
+	}
+	if fc.ConfigDir != "" && !cfg.setFlags["config-dir"] {
+		cfg.configDir = fc.ConfigDir
+	}
+	if fc.PruneAfter != nil && !cfg.setFlags["prune-after"] {
+		cfg.pruneAfter = *fc.PruneAfter
+	}
+	if fc.HeaderRefresh != nil && !cfg.setFlags["header-refresh"] {
+		cfg.headerRefresh = *fc.HeaderRefresh
+	}
+	if fc.LogLevel != "" && !cfg.setFlags["log-level"] {
+		cfg.logLevel = fc.LogLevel
+	}
+	if fc.LogFormat != "" && !cfg.setFlags["log-format"] {
+		cfg.logFormat = fc.LogFormat
+	}
+	if fc.AuthFlow != "" && !cfg.setFlags["auth-flow"] {
+		cfg.authFlow = fc.AuthFlow
+	}
+	if fc.Provider != "" && !cfg.setFlags["provider"] {
+		cfg.provider = fc.Provider
+	}
+	if fc.Profile != "" && !cfg.setFlags["profile"] {
+		cfg.profile = fc.Profile
+	}
+	if fc.Auth != "" && !cfg.setFlags["auth"] && !cfg.setFlags["auth-env"] {
+		cfg.auth = fc.Auth
+	}
+	if fc.AuthEnv != "" && !cfg.setFlags["auth"] && !cfg.setFlags["auth-env"] {
+		cfg.authEnv = fc.AuthEnv
+	}
+	if fc.Resource != "" && !cfg.setFlags["resource"] {
+		cfg.resource = fc.Resource
+	}
+	if fc.ClientID != "" && !cfg.setFlags["client-id"] {
+		cfg.clientID = fc.ClientID
+	}
+	if fc.ClientSecret != "" && !cfg.setFlags["client-secret"] {
+		cfg.clientSecret = fc.ClientSecret
+	}
+	if fc.TokenAuthMethod != "" && !cfg.setFlags["token-auth-method"] {
+		cfg.tokenAuth = fc.TokenAuthMethod
+	}
+	if fc.AssertionKey != "" && !cfg.setFlags["client-assertion-key"] {
+		cfg.assertionKey = fc.AssertionKey
+	}
+	if fc.AssertionKeyID != "" && !cfg.setFlags["client-assertion-key-id"] {
+		cfg.assertionKeyID = fc.AssertionKeyID
+	}
+	if fc.CACert != "" && !cfg.setFlags["ca-cert"] {
+		cfg.caCert = fc.CACert
+	}
+	if fc.ClientCert != "" && !cfg.setFlags["client-cert"] {
+		cfg.clientCert = fc.ClientCert
+	}
+	if fc.ClientKey != "" && !cfg.setFlags["client-key"] {
+		cfg.clientKey = fc.ClientKey
+	}
+	if fc.InsecureSkipTLSVerify && !cfg.setFlags["insecure-skip-tls-verify"] {
+		cfg.insecureSkipTLSVerify = true
+	}
+	if fc.MaxIdleConns != 0 && !cfg.setFlags["max-idle-conns"] {
+		cfg.pool.MaxIdleConns = fc.MaxIdleConns
+	}
+	if fc.MaxIdleConnsPerHost != 0 && !cfg.setFlags["max-idle-conns-per-host"] {
+		cfg.pool.MaxIdleConnsPerHost = fc.MaxIdleConnsPerHost
+	}
+	if fc.MaxConnsPerHost != 0 && !cfg.setFlags["max-conns-per-host"] {
+		cfg.pool.MaxConnsPerHost = fc.MaxConnsPerHost
+	}
+	if fc.IdleConnTimeout != 0 && !cfg.setFlags["idle-conn-timeout"] {
+		cfg.pool.IdleConnTimeout = fc.IdleConnTimeout
+	}
+	if fc.DisableHTTP2 && !cfg.setFlags["disable-http2"] {
+		cfg.pool.DisableHTTP2 = true
+	}
+	if len(fc.AllowTools) > 0 && !cfg.setFlags["allow-tool"] {
+		cfg.allowTools = fc.AllowTools
+	}
+	if len(fc.RedactFields) > 0 && !cfg.setFlags["redact-field"] {
+		cfg.redactFields = fc.RedactFields
+	}
+	if len(fc.CallbackOrigins) > 0 && !cfg.setFlags["callback-origin"] {
+		cfg.callbackOrigins = fc.CallbackOrigins
+	}
+	if fc.CallbackSuccessPage != "" && !cfg.setFlags["callback-success-page"] {
+		cfg.callbackSuccessPage = fc.CallbackSuccessPage
+	}
+	if fc.CallbackErrorPage != "" && !cfg.setFlags["callback-error-page"] {
+		cfg.callbackErrorPage = fc.CallbackErrorPage
+	}
+	if fc.CallbackHost != "" && !cfg.setFlags["callback-host"] {
+		cfg.callbackHost = fc.CallbackHost
+	}
+	if fc.CallbackBind != "" && !cfg.setFlags["callback-bind"] {
+		cfg.callbackBind = fc.CallbackBind
+	}
+	if fc.CallbackTLS && !cfg.setFlags["callback-tls"] {
+		cfg.callbackTLS = true
+	}
+	if fc.AssumePKCE && !cfg.setFlags["assume-pkce"] {
+		cfg.assumePKCE = true
+	}
+	if len(fc.DenyTools) > 0 && !cfg.setFlags["deny-tool"] {
+		cfg.denyTools = fc.DenyTools
+	}
+	if fc.StatusPort != 0 && !cfg.setFlags["status-port"] {
+		cfg.statusPort = fc.StatusPort
+	}
+	if fc.ShutdownTimeout != 0 && !cfg.setFlags["shutdown-timeout"] {
+		cfg.shutdownTimeout = fc.ShutdownTimeout
+	}
+	if fc.AuthTimeout != 0 && !cfg.setFlags["auth-timeout"] {
+		cfg.authTimeout = fc.AuthTimeout
+	}
+	if fc.HTTPTimeout != 0 && !cfg.setFlags["http-timeout"] {
+		cfg.httpTimeout = fc.HTTPTimeout
+	}
+	if fc.HTTPRetries != nil && !cfg.setFlags["http-retries"] {
+		cfg.httpRetries = *fc.HTTPRetries
+	}
+	if fc.HTTPRetryDelay != 0 && !cfg.setFlags["http-retry-delay"] {
+		cfg.httpRetryDelay = fc.HTTPRetryDelay
+	}
+	if fc.RequestTimeout != 0 && !cfg.setFlags["request-timeout"] {
+		cfg.requestTimeout = fc.RequestTimeout
+	}
+	if fc.ListCacheTTL != 0 && !cfg.setFlags["list-cache-ttl"] {
+		cfg.listCacheTTL = fc.ListCacheTTL
+	}
+	if fc.KeepaliveInterval != 0 && !cfg.setFlags["keepalive-interval"] {
+		cfg.keepaliveInterval = fc.KeepaliveInterval
+	}
+	if fc.ReconnectInitial != 0 && !cfg.setFlags["reconnect-initial"] {
+		cfg.reconnect.Initial = fc.ReconnectInitial
+	}
+	if fc.ReconnectMultiplier != 0 && !cfg.setFlags["reconnect-multiplier"] {
+		cfg.reconnect.Multiplier = fc.ReconnectMultiplier
+	}
+	if fc.ReconnectMaxWait != 0 && !cfg.setFlags["reconnect-max-wait"] {
+		cfg.reconnect.MaxWait = fc.ReconnectMaxWait
+	}
+	if fc.ReconnectMaxAttempts != 0 && !cfg.setFlags["reconnect-max-attempts"] {
+		cfg.reconnect.MaxAttempts = fc.ReconnectMaxAttempts
+	}
+	if fc.EncryptStore && !cfg.setFlags["encrypt-store"] {
+		cfg.encryptStore = true
+	}
+	if fc.AuditLog && !cfg.setFlags["audit-log"] {
+		cfg.auditLog = true
+	}
+	if fc.ResumeSession && !cfg.setFlags["resume-session"] {
+		cfg.resumeSession = true
+	}
+	if fc.NoBrowser && !cfg.setFlags["no-browser"] {
+		cfg.noBrowser = true
+	}
+	if fc.BrowserCmd != "" && !cfg.setFlags["browser-cmd"] {
+		cfg.browserCmd = fc.BrowserCmd
+	}
+	if fc.NoCompression && !cfg.setFlags["no-compression"] {
+		cfg.noCompression = true
+	}
+	if fc.Strict && !cfg.setFlags["strict"] {
+		cfg.strict = true
+	}
+	if fc.EagerInit && !cfg.setFlags["eager-init"] {
+		cfg.eagerInit = true
+	}
+	if fc.ForwardLogs && !cfg.setFlags["forward-server-logs"] {
+		cfg.forwardLogs = true
+	}
+	if fc.RevokeOnExit && !cfg.setFlags["revoke-on-exit"] {
+		cfg.revokeOnExit = true
+	}
+	if fc.DecorateClient && !cfg.setFlags["decorate-client-info"] {
+		cfg.decorateClient = true
+	}
+	if fc.ProtocolVersion != "" && !cfg.setFlags["protocol-version"] {
+		cfg.protocolVersion = fc.ProtocolVersion
+	}
+	if fc.Shared && !cfg.setFlags["shared"] {
+		cfg.shared = true
+	}
+	if fc.Listen != "" && !cfg.setFlags["listen"] {
+		cfg.listen = fc.Listen
+	}
+	if fc.MaxRPS != 0 && !cfg.setFlags["max-rps"] {
+		cfg.maxRPS = fc.MaxRPS
+	}
+	if fc.Burst != 0 && !cfg.setFlags["burst"] {
+		cfg.burst = fc.Burst
+	}
+	if fc.QueueWhenRateLimited && !cfg.setFlags["queue-when-rate-limited"] {
+		cfg.queueRateLimited = true
+	}
+	if fc.SendBuffer != nil && !cfg.setFlags["send-buffer"] {
+		cfg.sendBuffer = *fc.SendBuffer
+	}
+	if fc.OutputQueue != nil && !cfg.setFlags["output-queue"] {
+		cfg.outputQueue = *fc.OutputQueue
+	}
+	if fc.BreakerThreshold != nil && !cfg.setFlags["breaker-threshold"] {
+		cfg.breakerThreshold = *fc.BreakerThreshold
+	}
+	if fc.BreakerCooldown != 0 && !cfg.setFlags["breaker-cooldown"] {
+		cfg.breakerCooldown = fc.BreakerCooldown
+	}
+	if fc.TraceFile != "" && !cfg.setFlags["trace-file"] {
+		cfg.traceFile = fc.TraceFile
+	}
+	if fc.Record != "" && !cfg.setFlags["record"] {
+		cfg.recordFile = fc.Record
+	}
+	if fc.Replay != "" && !cfg.setFlags["replay"] {
+		cfg.replayFile = fc.Replay
+	}
+	if fc.StdioFraming != "" && !cfg.setFlags["stdio-framing"] {
+		cfg.stdioFraming = fc.StdioFraming
+	}
+	if fc.MaxMessageSize != 0 && !cfg.setFlags["max-message-size"] {
+		cfg.maxMessageSize = fc.MaxMessageSize
+	}
+	if fc.MaxEventSize != 0 && !cfg.setFlags["max-event-size"] {
+		cfg.maxEventSize = fc.MaxEventSize
+	}
+	if fc.MaxBodySize != 0 && !cfg.setFlags["max-body-size"] {
+		cfg.maxBodySize = fc.MaxBodySize
+	}
+	if len(fc.Headers) > 0 {
+		cfg.headers = append(headerEntries(fc.Headers), cfg.headers...)
+	}
+	if len(cfg.scopes) == 0 {
+		cfg.scopes = fc.Scopes
+	}
+	if len(fc.AuthParams) > 0 {
+		// Command-line parameters come later and win.
+		cfg.authParams = append(authParamEntries(fc.AuthParams), cfg.authParams...)
+	}
+}
+
+// authParamEntries converts an auth parameter map into "key=value" entries
+// sorted by key.
+func authParamEntries(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, key+"="+params[key])
+	}
+	return entries
+}
+
+// headerEntries converts a header map into "Key:Value" entries sorted by name.
+func headerEntries(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, name+":"+headers[name])
+	}
+	return entries
+}
@@ -0,0 +1,921 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/headervalue"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+	"github.com/naotama2002/mcp-remote-go/internal/resilience"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server: https://example.com/mcp
+transport: streamable-http
+port: 4000
+headers:
+  X-API-Key: secret
+scopes: [read, write]
+log-level: debug
+log-format: json
+`)
+
+	fc, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if fc.Server != "https://example.com/mcp" {
+		t.Errorf("Expected server 'https://example.com/mcp', got '%s'", fc.Server)
+	}
+	if fc.Transport != "streamable-http" {
+		t.Errorf("Expected transport 'streamable-http', got '%s'", fc.Transport)
+	}
+	if fc.Port != 4000 {
+		t.Errorf("Expected port 4000, got %d", fc.Port)
+	}
+	if fc.Headers["X-API-Key"] != "secret" {
+		t.Errorf("Expected X-API-Key header, got %v", fc.Headers)
+	}
+	if !reflect.DeepEqual(fc.Scopes, []string{"read", "write"}) {
+		t.Errorf("Expected scopes [read write], got %v", fc.Scopes)
+	}
+	if fc.LogLevel != "debug" || fc.LogFormat != "json" {
+		t.Errorf("Expected log-level debug and log-format json, got %q and %q", fc.LogLevel, fc.LogFormat)
+	}
+}
+
+func TestLoadConfigFile_JSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+  "servers": [
+    {"name": "github", "url": "https://api.github.com/mcp", "scopes": ["repo"]},
+    {"name": "linear", "url": "https://mcp.linear.app/sse"}
+  ],
+  "token-store": "keychain"
+}`)
+
+	fc, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if len(fc.Servers) != 2 || fc.Servers[0].Name != "github" || fc.Servers[1].URL != "https://mcp.linear.app/sse" {
+		t.Errorf("Unexpected servers: %+v", fc.Servers)
+	}
+	if fc.TokenStore != "keychain" {
+		t.Errorf("Expected token-store 'keychain', got '%s'", fc.TokenStore)
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":        "sever: https://example.com/mcp\n",
+		"server and servers": "server: https://a.example.com\nservers:\n  - url: https://b.example.com\n",
+		"server without url": "servers:\n  - name: a\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfigFile(writeConfigFile(t, "config.yaml", content)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestLoadConfigFile_ExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "config.yaml"), []byte("server: https://example.com/mcp\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	fc, err := loadConfigFile("~/config.yaml")
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if fc.Server != "https://example.com/mcp" {
+		t.Errorf("Expected server from home config, got '%s'", fc.Server)
+	}
+}
+
+func TestFileConfigApplyTo_CLITakesPrecedence(t *testing.T) {
+	// Simulates: mcp-remote-go --config c.yaml --transport sse --header "X-API-Key: cli"
+	cfg := parseRemainingArgs([]string{"--config", "c.yaml", "--transport", "sse", "--header", "X-API-Key: cli"}, defaultCLIConfig())
+
+	fc := &fileConfig{
+		Server:    "https://example.com/mcp",
+		Transport: "streamable-http",
+		Port:      4000,
+		Headers:   map[string]string{"X-API-Key": "file", "X-Tenant": "acme"},
+	}
+	fc.applyTo(&cfg)
+
+	if cfg.configPath != "c.yaml" {
+		t.Errorf("Expected config path 'c.yaml', got '%s'", cfg.configPath)
+	}
+	if cfg.serverURL != "https://example.com/mcp" {
+		t.Errorf("Expected server URL from config, got '%s'", cfg.serverURL)
+	}
+	if cfg.transportMode != "sse" {
+		t.Errorf("Expected CLI transport 'sse' to win, got '%s'", cfg.transportMode)
+	}
+	if cfg.callbackPort != 4000 {
+		t.Errorf("Expected port 4000 from config, got %d", cfg.callbackPort)
+	}
+	want := []string{"X-API-Key:file", "X-Tenant:acme", "X-API-Key: cli"}
+	if !reflect.DeepEqual(cfg.headers, want) {
+		t.Errorf("Expected headers %v, got %v", want, cfg.headers)
+	}
+}
+
+func TestFileConfigApplyTo_PositionalURLWins(t *testing.T) {
+	cfg := parseRemainingArgs([]string{"https://cli.example.com/mcp"}, defaultCLIConfig())
+
+	fc := &fileConfig{Servers: []serverConfig{{Name: "a", URL: "https://a.example.com/mcp"}}}
+	fc.applyTo(&cfg)
+
+	if cfg.serverURL != "https://cli.example.com/mcp" {
+		t.Errorf("Expected positional URL to win, got '%s'", cfg.serverURL)
+	}
+	if len(cfg.serverConfigs) != 0 {
+		t.Errorf("Expected config servers to be ignored, got %v", cfg.serverConfigs)
+	}
+}
+
+func TestFileConfigApplyTo_ProxyURL(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{ProxyURL: "socks5://proxy:1080", HTTPSProxy: "http://legacy:8080"}
+	fc.applyTo(&cfg)
+	if cfg.httpProxy != "socks5://proxy:1080" {
+		t.Errorf("Expected proxy-url to win over https-proxy, got '%s'", cfg.httpProxy)
+	}
+
+	cfg = parseRemainingArgs([]string{"--https-proxy", "http://cli:3128"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.httpProxy != "http://cli:3128" {
+		t.Errorf("Expected CLI proxy to win, got '%s'", cfg.httpProxy)
+	}
+}
+
+func TestFileConfigApplyTo_ShutdownTimeout(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.shutdownTimeout != 10*time.Second {
+		t.Errorf("Expected default shutdown timeout 10s, got %v", cfg.shutdownTimeout)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "shutdown-timeout: 30s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.shutdownTimeout != 30*time.Second {
+		t.Errorf("Expected shutdown timeout 30s from config, got %v", cfg.shutdownTimeout)
+	}
+
+	cfg = parseRemainingArgs([]string{"--shutdown-timeout", "0"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.shutdownTimeout != 0 {
+		t.Errorf("Expected CLI shutdown timeout to win, got %v", cfg.shutdownTimeout)
+	}
+}
+
+func TestFileConfigApplyTo_Resource(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{Resource: "https://api.example.com"}
+	fc.applyTo(&cfg)
+	if cfg.resource != "https://api.example.com" {
+		t.Errorf("Expected resource from config, got '%s'", cfg.resource)
+	}
+
+	cfg = parseRemainingArgs([]string{"--resource", "https://cli.example.com"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.resource != "https://cli.example.com" {
+		t.Errorf("Expected CLI resource to win, got '%s'", cfg.resource)
+	}
+}
+
+func TestFileConfigApplyTo_NoBrowser(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{NoBrowser: true}
+	fc.applyTo(&cfg)
+	if !cfg.noBrowser {
+		t.Error("Expected noBrowser from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--no-browser=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.noBrowser {
+		t.Error("Expected CLI -no-browser=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_BrowserCmd(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "browser-cmd: firefox --private-window %s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.browserCmd != "firefox --private-window %s" {
+		t.Errorf("Expected browser-cmd from config, got %q", cfg.browserCmd)
+	}
+
+	cfg = parseRemainingArgs([]string{"--browser-cmd", "chromium"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.browserCmd != "chromium" {
+		t.Errorf("Expected CLI browser-cmd to win, got %q", cfg.browserCmd)
+	}
+}
+
+func TestFileConfigApplyTo_NoCompression(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.noCompression {
+		t.Error("Expected compression to be on by default")
+	}
+	fc := &fileConfig{NoCompression: true}
+	fc.applyTo(&cfg)
+	if !cfg.noCompression {
+		t.Error("Expected noCompression from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--no-compression=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.noCompression {
+		t.Error("Expected CLI -no-compression=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_ConnectionPool(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "max-idle-conns: 20\nmax-idle-conns-per-host: 4\nmax-conns-per-host: 8\nidle-conn-timeout: 1m\ndisable-http2: true\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.pool.MaxIdleConnsPerHost != httpclient.DefaultMaxIdleConnsPerHost || cfg.pool.DisableHTTP2 {
+		t.Errorf("Expected the default pool, got %+v", cfg.pool)
+	}
+	fc.applyTo(&cfg)
+	want := httpclient.PoolOptions{MaxIdleConns: 20, MaxIdleConnsPerHost: 4, MaxConnsPerHost: 8, IdleConnTimeout: time.Minute, DisableHTTP2: true}
+	if cfg.pool != want {
+		t.Errorf("Expected %+v from config, got %+v", want, cfg.pool)
+	}
+
+	cfg = parseRemainingArgs([]string{"--max-idle-conns-per-host", "2", "--disable-http2=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.pool.MaxIdleConnsPerHost != 2 || cfg.pool.DisableHTTP2 || cfg.pool.MaxConnsPerHost != 8 {
+		t.Errorf("Expected CLI flags to win over config, got %+v", cfg.pool)
+	}
+}
+
+func TestFileConfigApplyTo_StdioFraming(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.stdioFraming != "auto" {
+		t.Errorf("Expected default stdio framing 'auto', got '%s'", cfg.stdioFraming)
+	}
+	fc := &fileConfig{StdioFraming: "content-length"}
+	fc.applyTo(&cfg)
+	if cfg.stdioFraming != "content-length" {
+		t.Errorf("Expected stdio framing from config, got '%s'", cfg.stdioFraming)
+	}
+
+	cfg = parseRemainingArgs([]string{"--stdio-framing", "newline"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.stdioFraming != "newline" {
+		t.Errorf("Expected CLI stdio framing to win, got '%s'", cfg.stdioFraming)
+	}
+}
+
+func TestFileConfigApplyTo_MaxMessageSize(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.maxMessageSize != proxy.DefaultMaxMessageSize {
+		t.Errorf("Expected default max message size %d, got %d", proxy.DefaultMaxMessageSize, cfg.maxMessageSize)
+	}
+	fc := &fileConfig{MaxMessageSize: 1024}
+	fc.applyTo(&cfg)
+	if cfg.maxMessageSize != 1024 {
+		t.Errorf("Expected max message size from config, got %d", cfg.maxMessageSize)
+	}
+
+	cfg = parseRemainingArgs([]string{"--max-message-size", "2048"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.maxMessageSize != 2048 {
+		t.Errorf("Expected CLI max message size to win, got %d", cfg.maxMessageSize)
+	}
+}
+
+func TestFileConfigApplyTo_MaxEventAndBodySize(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.maxEventSize != proxy.DefaultMaxEventSize || cfg.maxBodySize != proxy.DefaultMaxBodySize {
+		t.Errorf("Expected the default limits, got %d and %d", cfg.maxEventSize, cfg.maxBodySize)
+	}
+	fc := &fileConfig{MaxEventSize: 1024, MaxBodySize: 4096}
+	fc.applyTo(&cfg)
+	if cfg.maxEventSize != 1024 || cfg.maxBodySize != 4096 {
+		t.Errorf("Expected the limits from config, got %d and %d", cfg.maxEventSize, cfg.maxBodySize)
+	}
+
+	cfg = parseRemainingArgs([]string{"--max-event-size", "2048"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.maxEventSize != 2048 || cfg.maxBodySize != 4096 {
+		t.Errorf("Expected the CLI event limit to win, got %d and %d", cfg.maxEventSize, cfg.maxBodySize)
+	}
+}
+
+func TestFileConfigApplyTo_Strict(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.strict {
+		t.Error("Expected strict mode to be off by default")
+	}
+	fc := &fileConfig{Strict: true}
+	fc.applyTo(&cfg)
+	if !cfg.strict {
+		t.Error("Expected strict mode from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--strict=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.strict {
+		t.Error("Expected CLI -strict=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_EagerInit(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.eagerInit {
+		t.Error("Expected eager initialization to be off by default")
+	}
+	fc := &fileConfig{EagerInit: true}
+	fc.applyTo(&cfg)
+	if !cfg.eagerInit {
+		t.Error("Expected eager initialization from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--eager-init=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.eagerInit {
+		t.Error("Expected CLI -eager-init=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_ForwardServerLogs(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.forwardLogs {
+		t.Error("Expected server logs not forwarded by default")
+	}
+	fc := &fileConfig{ForwardLogs: true}
+	fc.applyTo(&cfg)
+	if !cfg.forwardLogs {
+		t.Error("Expected server log forwarding from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--forward-server-logs=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.forwardLogs {
+		t.Error("Expected CLI -forward-server-logs=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_DecorateClientInfo(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.decorateClient {
+		t.Error("Expected clientInfo decoration to be off by default")
+	}
+	fc := &fileConfig{DecorateClient: true}
+	fc.applyTo(&cfg)
+	if !cfg.decorateClient {
+		t.Error("Expected clientInfo decoration from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--decorate-client-info=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.decorateClient {
+		t.Error("Expected CLI -decorate-client-info=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_TokenAuthMethod(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{TokenAuthMethod: "private_key_jwt", AssertionKey: "key.pem", AssertionKeyID: "k1"}
+	fc.applyTo(&cfg)
+	if cfg.tokenAuth != "private_key_jwt" || cfg.assertionKey != "key.pem" || cfg.assertionKeyID != "k1" {
+		t.Errorf("Expected token endpoint auth from config, got %q, %q, %q", cfg.tokenAuth, cfg.assertionKey, cfg.assertionKeyID)
+	}
+
+	cfg = parseRemainingArgs([]string{"--token-auth-method", "client_secret_basic"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.tokenAuth != "client_secret_basic" {
+		t.Errorf("Expected CLI -token-auth-method to win, got %q", cfg.tokenAuth)
+	}
+}
+
+func TestFileConfigApplyTo_RevokeOnExit(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{RevokeOnExit: true}
+	fc.applyTo(&cfg)
+	if !cfg.revokeOnExit {
+		t.Error("Expected revoke-on-exit from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--revoke-on-exit=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.revokeOnExit {
+		t.Error("Expected CLI -revoke-on-exit=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_Provider(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{Provider: "azure-ad:contoso"}
+	fc.applyTo(&cfg)
+	if cfg.provider != "azure-ad:contoso" {
+		t.Errorf("Expected provider from config, got %q", cfg.provider)
+	}
+
+	cfg = parseRemainingArgs([]string{"--provider", "github"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.provider != "github" {
+		t.Errorf("Expected CLI provider to win, got %q", cfg.provider)
+	}
+}
+
+func TestFileConfigApplyTo_Listen(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{Listen: ":8080"}
+	fc.applyTo(&cfg)
+	if cfg.listen != ":8080" {
+		t.Errorf("Expected listen from config, got %q", cfg.listen)
+	}
+
+	cfg = parseRemainingArgs([]string{"--listen", "127.0.0.1:9090"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.listen != "127.0.0.1:9090" {
+		t.Errorf("Expected CLI listen to win, got %q", cfg.listen)
+	}
+}
+
+func TestFileConfigApplyTo_AuditLog(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{AuditLog: true}
+	fc.applyTo(&cfg)
+	if !cfg.auditLog {
+		t.Error("Expected audit-log from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--audit-log=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.auditLog {
+		t.Error("Expected CLI -audit-log=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_Profile(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{Profile: "work"}
+	fc.applyTo(&cfg)
+	if cfg.profile != "work" {
+		t.Errorf("Expected profile from config, got %q", cfg.profile)
+	}
+
+	cfg = parseRemainingArgs([]string{"--profile", "personal"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.profile != "personal" {
+		t.Errorf("Expected CLI profile to win, got %q", cfg.profile)
+	}
+}
+
+func TestFileConfigApplyTo_AuthParams(t *testing.T) {
+	cfg := parseRemainingArgs([]string{"--auth-param", "prompt=login"}, defaultCLIConfig())
+	fc := &fileConfig{AuthParams: map[string]string{"prompt": "consent", "audience": "api"}}
+	fc.applyTo(&cfg)
+	want := []string{"audience=api", "prompt=consent", "prompt=login"}
+	if !reflect.DeepEqual(cfg.authParams, want) {
+		t.Errorf("Expected auth params %v, got %v", want, cfg.authParams)
+	}
+}
+
+func TestFileConfigApplyTo_RecordReplay(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{Record: "session.jsonl", Replay: "old.jsonl"}
+	fc.applyTo(&cfg)
+	if cfg.recordFile != "session.jsonl" || cfg.replayFile != "old.jsonl" {
+		t.Errorf("Expected record and replay files from config, got %q and %q", cfg.recordFile, cfg.replayFile)
+	}
+
+	cfg = parseRemainingArgs([]string{"--replay", "cli.jsonl"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.replayFile != "cli.jsonl" {
+		t.Errorf("Expected CLI -replay to win, got %q", cfg.replayFile)
+	}
+}
+
+func TestFileConfigApplyTo_ProtocolVersion(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc := &fileConfig{ProtocolVersion: "2025-03-26"}
+	fc.applyTo(&cfg)
+	if cfg.protocolVersion != "2025-03-26" {
+		t.Errorf("Expected protocol version from config, got %q", cfg.protocolVersion)
+	}
+
+	cfg = parseRemainingArgs([]string{"--protocol-version", "2025-06-18"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.protocolVersion != "2025-06-18" {
+		t.Errorf("Expected CLI -protocol-version to win, got %q", cfg.protocolVersion)
+	}
+}
+
+func TestFileConfigApplyTo_ConfigDir(t *testing.T) {
+	fc := &fileConfig{ConfigDir: "/from/file"}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.configDir != "/from/file" {
+		t.Errorf("Expected config-dir from config, got %q", cfg.configDir)
+	}
+
+	cfg = parseRemainingArgs([]string{"--config-dir", "/from/flag"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.configDir != "/from/flag" {
+		t.Errorf("Expected CLI -config-dir to win, got %q", cfg.configDir)
+	}
+}
+
+func TestFileConfigApplyTo_PruneAfter(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.pruneAfter != auth.DefaultPruneAfter {
+		t.Errorf("Expected prune-after %v by default, got %v", auth.DefaultPruneAfter, cfg.pruneAfter)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "prune-after: 0s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.pruneAfter != 0 {
+		t.Errorf("Expected prune-after 0 from config to disable pruning, got %v", cfg.pruneAfter)
+	}
+
+	cfg = parseRemainingArgs([]string{"--prune-after", "720h"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.pruneAfter != 720*time.Hour {
+		t.Errorf("Expected CLI prune-after to win, got %v", cfg.pruneAfter)
+	}
+}
+
+func TestFileConfigApplyTo_HeaderRefresh(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.headerRefresh != headervalue.DefaultRefresh {
+		t.Errorf("Expected header-refresh %v by default, got %v", headervalue.DefaultRefresh, cfg.headerRefresh)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "header-refresh: 0s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.headerRefresh != 0 {
+		t.Errorf("Expected header-refresh 0 from config, got %v", cfg.headerRefresh)
+	}
+
+	cfg = parseRemainingArgs([]string{"--header-refresh", "1m"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.headerRefresh != time.Minute {
+		t.Errorf("Expected CLI header-refresh to win, got %v", cfg.headerRefresh)
+	}
+}
+
+func TestFileConfigApplyTo_Shared(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.shared {
+		t.Error("Expected shared mode to be off by default")
+	}
+	fc := &fileConfig{Shared: true}
+	fc.applyTo(&cfg)
+	if !cfg.shared {
+		t.Error("Expected shared mode from config")
+	}
+
+	cfg = parseRemainingArgs([]string{"--shared=false"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.shared {
+		t.Error("Expected CLI -shared=false to win")
+	}
+}
+
+func TestFileConfigApplyTo_RedactFields(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "redact-fields:\n  - params.arguments.password\n  - remote_to_local:result.secret\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if len(cfg.redactFields) != 2 || cfg.redactFields[1] != "remote_to_local:result.secret" {
+		t.Errorf("Expected redact fields from config, got %q", cfg.redactFields)
+	}
+
+	cfg = parseRemainingArgs([]string{"--redact-field", "params.arguments.key"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if len(cfg.redactFields) != 1 || cfg.redactFields[0] != "params.arguments.key" {
+		t.Errorf("Expected CLI redact fields to win, got %q", cfg.redactFields)
+	}
+}
+
+func TestFileConfigApplyTo_CallbackOrigins(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "callback-origins:\n  - https://auth.example.com\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if len(cfg.callbackOrigins) != 1 || cfg.callbackOrigins[0] != "https://auth.example.com" {
+		t.Errorf("Expected callback origins from config, got %q", cfg.callbackOrigins)
+	}
+
+	cfg = parseRemainingArgs([]string{"--callback-origin", "https://login.example.com"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if len(cfg.callbackOrigins) != 1 || cfg.callbackOrigins[0] != "https://login.example.com" {
+		t.Errorf("Expected CLI callback origins to win, got %q", cfg.callbackOrigins)
+	}
+}
+
+func TestFileConfigApplyTo_CallbackPages(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "callback-success-page: success.html\ncallback-error-page: 'Failed: {{.Error}}'\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.callbackSuccessPage != "success.html" || cfg.callbackErrorPage != "Failed: {{.Error}}" {
+		t.Errorf("Expected callback pages from config, got %q and %q", cfg.callbackSuccessPage, cfg.callbackErrorPage)
+	}
+
+	cfg = parseRemainingArgs([]string{"--callback-success-page", "Done"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.callbackSuccessPage != "Done" || cfg.callbackErrorPage != "Failed: {{.Error}}" {
+		t.Errorf("Expected the CLI success page to win, got %q and %q", cfg.callbackSuccessPage, cfg.callbackErrorPage)
+	}
+}
+
+func TestFileConfigApplyTo_AuthTimeout(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.authTimeout != auth.DefaultAuthTimeout {
+		t.Errorf("Expected auth timeout %v by default, got %v", auth.DefaultAuthTimeout, cfg.authTimeout)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "auth-timeout: 15m\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.authTimeout != 15*time.Minute {
+		t.Errorf("Expected auth-timeout from config, got %v", cfg.authTimeout)
+	}
+
+	cfg = parseRemainingArgs([]string{"--auth-timeout", "30s"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.authTimeout != 30*time.Second {
+		t.Errorf("Expected CLI auth-timeout to win, got %v", cfg.authTimeout)
+	}
+}
+
+func TestFileConfigApplyTo_HTTPRetries(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.httpTimeout != 30*time.Second || cfg.httpRetries != 3 || cfg.httpRetryDelay != time.Second {
+		t.Errorf("Expected the httpclient defaults, got %v, %d, %v", cfg.httpTimeout, cfg.httpRetries, cfg.httpRetryDelay)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "http-timeout: 10s\nhttp-retries: 0\nhttp-retry-delay: 250ms\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.httpTimeout != 10*time.Second || cfg.httpRetries != 0 || cfg.httpRetryDelay != 250*time.Millisecond {
+		t.Errorf("Expected the settings from config, got %v, %d, %v", cfg.httpTimeout, cfg.httpRetries, cfg.httpRetryDelay)
+	}
+
+	cfg = parseRemainingArgs([]string{"--http-retries", "5"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.httpRetries != 5 || cfg.httpTimeout != 10*time.Second {
+		t.Errorf("Expected CLI http-retries to win, got %d", cfg.httpRetries)
+	}
+}
+
+func TestFileConfigApplyTo_CallbackHost(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "callback-host: devbox.local\ncallback-bind: 0.0.0.0\ncallback-tls: true\nassume-pkce: true\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.callbackHost != "devbox.local" || cfg.callbackBind != "0.0.0.0" || !cfg.callbackTLS || !cfg.assumePKCE {
+		t.Errorf("Expected the callback settings from config, got %q, %q, %v, %v", cfg.callbackHost, cfg.callbackBind, cfg.callbackTLS, cfg.assumePKCE)
+	}
+
+	cfg = parseRemainingArgs([]string{"--callback-bind", "192.168.1.5"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.callbackBind != "192.168.1.5" || cfg.callbackHost != "devbox.local" {
+		t.Errorf("Expected CLI callback-bind to win, got %q", cfg.callbackBind)
+	}
+}
+
+func TestFileConfigApplyTo_RequestTimeout(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.requestTimeout != 0 {
+		t.Errorf("Expected no request timeout by default, got %v", cfg.requestTimeout)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "request-timeout: 2m\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.requestTimeout != 2*time.Minute {
+		t.Errorf("Expected request timeout 2m from config, got %v", cfg.requestTimeout)
+	}
+
+	cfg = parseRemainingArgs([]string{"--request-timeout", "30s"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.requestTimeout != 30*time.Second {
+		t.Errorf("Expected CLI request timeout to win, got %v", cfg.requestTimeout)
+	}
+}
+
+func TestFileConfigApplyTo_ListCacheTTL(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.listCacheTTL != 0 {
+		t.Errorf("Expected the list cache off by default, got %v", cfg.listCacheTTL)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "list-cache-ttl: 30s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.listCacheTTL != 30*time.Second {
+		t.Errorf("Expected list cache TTL 30s from config, got %v", cfg.listCacheTTL)
+	}
+
+	cfg = parseRemainingArgs([]string{"--list-cache-ttl", "0"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.listCacheTTL != 0 {
+		t.Errorf("Expected CLI -list-cache-ttl 0 to win, got %v", cfg.listCacheTTL)
+	}
+}
+
+func TestFileConfigApplyTo_KeepaliveInterval(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "keepalive-interval: 15s\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.keepaliveInterval != 15*time.Second {
+		t.Errorf("Expected keepalive interval 15s from config, got %v", cfg.keepaliveInterval)
+	}
+
+	cfg = parseRemainingArgs([]string{"--keepalive-interval", "1m"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.keepaliveInterval != time.Minute {
+		t.Errorf("Expected CLI keepalive interval to win, got %v", cfg.keepaliveInterval)
+	}
+}
+
+func TestFileConfigApplyTo_ReconnectPolicy(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.reconnect != backoff.Default() {
+		t.Errorf("Expected the default reconnect policy, got %+v", cfg.reconnect)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "reconnect-initial: 500ms\nreconnect-multiplier: 1.5\nreconnect-max-wait: 1m\nreconnect-max-attempts: -1\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	want := backoff.Policy{Initial: 500 * time.Millisecond, Multiplier: 1.5, MaxWait: time.Minute, MaxAttempts: -1}
+	if cfg.reconnect != want {
+		t.Errorf("Expected %+v from config, got %+v", want, cfg.reconnect)
+	}
+
+	cfg = parseRemainingArgs([]string{"--reconnect-max-attempts", "10", "--reconnect-initial", "2s"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.reconnect.MaxAttempts != 10 || cfg.reconnect.Initial != 2*time.Second || cfg.reconnect.MaxWait != time.Minute {
+		t.Errorf("Expected CLI flags to win over config, got %+v", cfg.reconnect)
+	}
+}
+
+func TestFileConfigApplyTo_MaxRPS(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "max-rps: 2.5\nburst: 5\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.maxRPS != 0 {
+		t.Errorf("Expected no client rate limit by default, got %v", cfg.maxRPS)
+	}
+	fc.applyTo(&cfg)
+	if cfg.maxRPS != 2.5 || cfg.burst != 5 {
+		t.Errorf("Expected max-rps 2.5 and burst 5 from config, got %v and %d", cfg.maxRPS, cfg.burst)
+	}
+
+	cfg = parseRemainingArgs([]string{"--max-rps", "10"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.maxRPS != 10 || cfg.burst != 5 {
+		t.Errorf("Expected CLI max-rps to win, got %v and %d", cfg.maxRPS, cfg.burst)
+	}
+}
+
+func TestFileConfigApplyTo_Breaker(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "breaker-threshold: 0\nbreaker-cooldown: 1m\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.breakerThreshold != resilience.DefaultThreshold || cfg.breakerCooldown != resilience.DefaultCooldown {
+		t.Errorf("Expected the default breaker, got %d and %v", cfg.breakerThreshold, cfg.breakerCooldown)
+	}
+	fc.applyTo(&cfg)
+	if cfg.breakerThreshold != 0 || cfg.breakerCooldown != time.Minute {
+		t.Errorf("Expected the breaker disabled from config, got %d and %v", cfg.breakerThreshold, cfg.breakerCooldown)
+	}
+
+	cfg = parseRemainingArgs([]string{"--breaker-threshold", "3"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.breakerThreshold != 3 {
+		t.Errorf("Expected CLI breaker-threshold to win, got %d", cfg.breakerThreshold)
+	}
+}
+
+func TestFileConfigApplyTo_QueueWhenRateLimited(t *testing.T) {
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "queue-when-rate-limited: true\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.queueRateLimited {
+		t.Error("Expected rate-limited messages not to be queued by default")
+	}
+	fc.applyTo(&cfg)
+	if !cfg.queueRateLimited {
+		t.Error("Expected queue-when-rate-limited from config")
+	}
+}
+
+func TestFileConfigApplyTo_SendBuffer(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.sendBuffer != proxy.DefaultSendBuffer {
+		t.Errorf("Expected send buffer %d by default, got %d", proxy.DefaultSendBuffer, cfg.sendBuffer)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "send-buffer: 0\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.sendBuffer != 0 {
+		t.Errorf("Expected send-buffer 0 from config to disable buffering, got %d", cfg.sendBuffer)
+	}
+
+	cfg = parseRemainingArgs([]string{"--send-buffer", "500"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.sendBuffer != 500 {
+		t.Errorf("Expected CLI send buffer to win, got %d", cfg.sendBuffer)
+	}
+}
+
+func TestFileConfigApplyTo_OutputQueue(t *testing.T) {
+	cfg := parseRemainingArgs(nil, defaultCLIConfig())
+	if cfg.outputQueue != proxy.DefaultOutputQueue {
+		t.Errorf("Expected output queue %d by default, got %d", proxy.DefaultOutputQueue, cfg.outputQueue)
+	}
+
+	fc, err := loadConfigFile(writeConfigFile(t, "c.yaml", "output-queue: 0\n"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	fc.applyTo(&cfg)
+	if cfg.outputQueue != 0 {
+		t.Errorf("Expected output-queue 0 from config to disable queueing, got %d", cfg.outputQueue)
+	}
+
+	cfg = parseRemainingArgs([]string{"--output-queue", "50"}, defaultCLIConfig())
+	fc.applyTo(&cfg)
+	if cfg.outputQueue != 50 {
+		t.Errorf("Expected CLI output queue to win, got %d", cfg.outputQueue)
+	}
+}
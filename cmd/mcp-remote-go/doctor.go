@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/headervalue"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+const doctorUsage = `Usage: mcp-remote-go doctor [-header 'Key:Value'] [-proxy-url <proxy-url>] [-ca-cert <file>] [-client-cert <file> -client-key <file>] [-insecure-skip-tls-verify] [-timeout <duration>] <server-url>
+
+Checks that the server can be reached, which transports it supports and how
+it is authorized, without starting the proxy.
+`
+
+// certExpiryWarning is how close to expiry a server certificate is reported.
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// errDoctorFailed is returned when at least one check failed.
+var errDoctorFailed = errors.New("doctor found problems")
+
+// doctorReport prints the result of each check.
+type doctorReport struct {
+	w      io.Writer
+	failed bool
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	_, _ = fmt.Fprintf(r.w, "[ok]   "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(hint, format string, args ...any) {
+	_, _ = fmt.Fprintf(r.w, "[warn] "+format+"\n", args...)
+	r.hint(hint)
+}
+
+func (r *doctorReport) fail(hint, format string, args ...any) {
+	r.failed = true
+	_, _ = fmt.Fprintf(r.w, "[fail] "+format+"\n", args...)
+	r.hint(hint)
+}
+
+func (r *doctorReport) hint(hint string) {
+	if hint != "" {
+		_, _ = fmt.Fprintf(r.w, "       %s\n", hint)
+	}
+}
+
+// doctor holds what the checks share.
+type doctor struct {
+	report    *doctorReport
+	serverURL *url.URL
+	headers   map[string]string
+	transport *http.Transport
+	client    *http.Client
+
+	// challenge is the Bearer challenge of a 401 response, if any.
+	challenge    auth.BearerChallenge
+	unauthorized bool
+}
+
+// runDoctor implements "mcp-remote-go doctor", which diagnoses the
+// connection to a server: TLS, transports and OAuth metadata.
+func runDoctor(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("mcp-remote-go doctor", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var headers flagList
+	flags.Var(&headers, "header", "Custom header to send (can be repeated)")
+	proxyURL := flags.String("proxy-url", "", "HTTP/HTTPS/SOCKS5 proxy URL")
+	var tlsOpts httpclient.TLSOptions
+	flags.StringVar(&tlsOpts.CACertFile, "ca-cert", "", "PEM file of CA certificates to trust")
+	flags.StringVar(&tlsOpts.ClientCertFile, "client-cert", "", "PEM client certificate for mutual TLS")
+	flags.StringVar(&tlsOpts.ClientKeyFile, "client-key", "", "PEM private key for -client-cert")
+	flags.BoolVar(&tlsOpts.InsecureSkipVerify, "insecure-skip-tls-verify", false, "Skip TLS certificate verification")
+	timeout := flags.Duration("timeout", 10*time.Second, "Timeout of each check")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, doctorUsage)
+	}
+	if flags.NArg() != 1 {
+		return errors.New(doctorUsage)
+	}
+
+	tlsConfig, err := tlsOpts.TLSConfig()
+	if err != nil {
+		return err
+	}
+	transport, err := httpclient.NewProxyTransport(*proxyURL)
+	if err != nil {
+		return err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	d := &doctor{
+		report:    &doctorReport{w: stdout},
+		headers:   make(map[string]string),
+		transport: transport,
+		client:    &http.Client{Transport: transport, Timeout: *timeout},
+	}
+	for _, h := range headers {
+		if key, value, ok := strings.Cut(h, ":"); ok {
+			expanded, err := headervalue.Expand(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("header %s: %w", strings.TrimSpace(key), err)
+			}
+			d.headers[strings.TrimSpace(key)] = expanded
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 4**timeout)
+	defer cancel()
+	if d.checkURL(flags.Arg(0)) {
+		d.checkTransports(ctx)
+		d.checkOAuth(ctx)
+	}
+	if d.report.failed {
+		return errDoctorFailed
+	}
+	return nil
+}
+
+// checkURL validates the server URL and reports whether the other checks
+// can run.
+func (d *doctor) checkURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		d.report.fail("Pass the full URL of the MCP endpoint, e.g. https://mcp.example.com/mcp", "invalid server URL %q", raw)
+		return false
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		d.report.ok("server URL %s", u)
+	case "http", "ws":
+		d.report.warn("Start the proxy with -allow-http to connect to it.", "server URL %s is not encrypted", u)
+	default:
+		d.report.fail("Use an http, https, ws or wss URL.", "unsupported URL scheme %q", u.Scheme)
+		return false
+	}
+	d.serverURL = u
+	return true
+}
+
+// checkTransports probes the transports the server supports.
+func (d *doctor) checkTransports(ctx context.Context) {
+	if d.serverURL.Scheme == "ws" || d.serverURL.Scheme == "wss" {
+		d.checkWebSocket(ctx)
+		return
+	}
+
+	streamable := d.checkStreamable(ctx)
+	if d.report.failed {
+		// The server could not be reached at all.
+		return
+	}
+	sse := d.checkSSE(ctx)
+	if !streamable && !sse && !d.unauthorized {
+		d.report.fail("Check the URL path; MCP servers usually serve /mcp (Streamable HTTP) or /sse (SSE).", "no MCP transport found at %s", d.serverURL)
+	}
+}
+
+// checkStreamable sends initialize as the Streamable HTTP transport does.
+func (d *doctor) checkStreamable(ctx context.Context) bool {
+	body := `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"` + proxy.MCPProtocolVersion + `","capabilities":{},"clientInfo":{"name":"mcp-remote-go-doctor","version":"` + version + `"}}}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.serverURL.String(), strings.NewReader(body))
+	if err != nil {
+		d.report.fail("", "failed to create request: %v", err)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set(proxy.HeaderMCPProtocolVersion, proxy.MCPProtocolVersion)
+	resp, err := d.do(req)
+	if err != nil {
+		d.connectionFailed(err)
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+	d.checkTLS(resp.TLS)
+
+	if sessionID := resp.Header.Get(proxy.HeaderMCPSessionID); sessionID != "" {
+		defer d.endSession(sessionID)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case d.checkUnauthorized(resp, "Streamable HTTP"):
+		return false
+	case resp.StatusCode/100 == 2 && (contentType == "application/json" || contentType == "text/event-stream"):
+		d.report.ok("Streamable HTTP supported (HTTP %d, %s)", resp.StatusCode, contentType)
+		return true
+	default:
+		d.report.warn("", "Streamable HTTP not supported (HTTP %d)", resp.StatusCode)
+		return false
+	}
+}
+
+// checkSSE opens an SSE stream as the SSE transport does.
+func (d *doctor) checkSSE(ctx context.Context) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.serverURL.String(), nil)
+	if err != nil {
+		d.report.fail("", "failed to create request: %v", err)
+		return false
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	d.setHeaders(req)
+	// The stream stays open, so only the response headers are waited for.
+	client := *d.client
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		d.report.warn("", "SSE not supported: %v", err)
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case d.checkUnauthorized(resp, "SSE"):
+		return false
+	case resp.StatusCode == http.StatusOK && contentType == "text/event-stream":
+		d.report.ok("SSE supported")
+		return true
+	default:
+		d.report.warn("", "SSE not supported (HTTP %d)", resp.StatusCode)
+		return false
+	}
+}
+
+// checkWebSocket performs the WebSocket handshake.
+func (d *doctor) checkWebSocket(ctx context.Context) {
+	dialer := &websocket.Dialer{
+		Proxy:            d.transport.Proxy,
+		TLSClientConfig:  d.transport.TLSClientConfig,
+		HandshakeTimeout: d.client.Timeout,
+		Subprotocols:     []string{proxy.WebSocketSubprotocol},
+	}
+	header := http.Header{}
+	for k, v := range d.headers {
+		header.Set(k, v)
+	}
+	conn, resp, err := dialer.DialContext(ctx, d.serverURL.String(), header)
+	if resp != nil && resp.Body != nil {
+		defer func() { _ = resp.Body.Close() }()
+	}
+	if err != nil {
+		if resp != nil && d.checkUnauthorized(resp, "WebSocket") {
+			return
+		}
+		if resp != nil {
+			d.report.fail("Check the URL path of the WebSocket endpoint.", "WebSocket handshake failed (HTTP %d)", resp.StatusCode)
+			return
+		}
+		d.connectionFailed(err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	d.checkTLS(resp.TLS)
+	d.report.ok("WebSocket supported (subprotocol %q)", conn.Subprotocol())
+}
+
+// checkUnauthorized reports a 401 response and records its challenge.
+func (d *doctor) checkUnauthorized(resp *http.Response, transport string) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if !d.unauthorized {
+		d.unauthorized = true
+		d.challenge, _ = auth.ParseWWWAuthenticateHeaders(resp.Header.Values("WWW-Authenticate"))
+	}
+	d.report.ok("%s endpoint answers and requires authorization (HTTP 401)", transport)
+	return true
+}
+
+// checkTLS reports on the certificate the server presented.
+func (d *doctor) checkTLS(state *tls.ConnectionState) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return
+	}
+	cert := state.PeerCertificates[0]
+	remaining := time.Until(cert.NotAfter)
+	if remaining < certExpiryWarning {
+		d.report.warn("Renew the server certificate soon.", "TLS certificate for %s expires %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+		return
+	}
+	d.report.ok("TLS %s, certificate issued by %s, valid until %s", tls.VersionName(state.Version), cert.Issuer.CommonName, cert.NotAfter.Format("2006-01-02"))
+}
+
+// connectionFailed reports a request that did not get a response, with a
+// hint for the likely cause.
+func (d *doctor) connectionFailed(err error) {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		d.report.fail("Pass the CA that signed the server certificate with -ca-cert, or use -insecure-skip-tls-verify for testing only.", "TLS certificate is not trusted: %v", err)
+	case errors.As(err, &hostname):
+		d.report.fail("Connect using a host name the certificate is issued for.", "TLS certificate does not match the host: %v", err)
+	case errors.As(err, &invalid):
+		d.report.fail("Check the server certificate and the system clock.", "TLS certificate is invalid: %v", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		d.report.fail("Check that the server is running and reachable, or set -proxy-url if a proxy is required.", "server did not respond in time: %v", err)
+	default:
+		d.report.fail("Check the host name, the network and any proxy settings (-proxy-url, HTTPS_PROXY).", "cannot reach server: %v", err)
+	}
+}
+
+// checkOAuth looks for the authorization server the proxy would use.
+func (d *doctor) checkOAuth(ctx context.Context) {
+	if d.report.failed {
+		return
+	}
+	client := *httpclient.New(&httpclient.Config{Timeout: d.client.Timeout, Transport: d.transport})
+
+	prm := auth.NewProtectedResourceDiscovery(client)
+	if d.challenge.ResourceMetadata != "" {
+		prm = auth.NewProtectedResourceDiscoveryFromURL(client, d.challenge.ResourceMetadata)
+	}
+	strategies := []auth.DiscoveryStrategy{prm, auth.NewStandardOAuthDiscovery(client), auth.NewOpenIDConnectDiscovery(client)}
+
+	var metadata *auth.ServerMetadata
+	for _, strategy := range strategies {
+		var err error
+		if metadata, err = strategy.Discover(ctx, d.serverURL.String()); err == nil {
+			d.report.ok("authorization server found via %s: %s", strategy.Name(), metadata.Issuer)
+			break
+		}
+	}
+	if metadata == nil {
+		if d.unauthorized {
+			d.report.fail("The server may expect a static token instead; pass it with -auth or -auth-env.", "server requires authorization but publishes no OAuth metadata")
+		} else {
+			d.report.ok("no OAuth metadata published; the server does not appear to require authorization")
+		}
+		return
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		d.report.fail("The authorization server metadata is incomplete; contact the server operator.", "authorization server metadata lacks an authorization or token endpoint")
+	}
+	if metadata.RegistrationEndpoint == "" {
+		d.report.warn("Register a client with the provider and pass -client-id.", "authorization server does not support dynamic client registration")
+	} else {
+		d.report.ok("dynamic client registration at %s", metadata.RegistrationEndpoint)
+	}
+	if len(metadata.CodeChallengeMethodsSupported) == 0 {
+		d.report.warn("Pass -assume-pkce if the server enforces PKCE without advertising it.", "authorization server does not advertise its PKCE methods; the browser flow refuses it")
+	} else if !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		d.report.warn("Authorization may fail if the server requires PKCE with another method.", "authorization server does not advertise PKCE S256 support")
+	}
+	if metadata.DeviceAuthorizationEndpoint != "" {
+		d.report.ok("device authorization supported (-auth-flow device)")
+	}
+}
+
+// do sends req with the configured headers.
+func (d *doctor) do(req *http.Request) (*http.Response, error) {
+	d.setHeaders(req)
+	return d.client.Do(req)
+}
+
+func (d *doctor) setHeaders(req *http.Request) {
+	for k, v := range d.headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// endSession ends a session the initialize probe created.
+func (d *doctor) endSession(sessionID string) {
+	req, err := http.NewRequest(http.MethodDelete, d.serverURL.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set(proxy.HeaderMCPSessionID, sessionID)
+	if resp, err := d.do(req); err == nil {
+		_ = resp.Body.Close()
+	}
+}
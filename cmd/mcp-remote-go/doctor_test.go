@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDoctorStreamableServer(t *testing.T) {
+	var deleted bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Mcp-Session-Id", "s1")
+			_, _ = fmt.Fprint(w, `{"jsonrpc":"2.0","id":0,"result":{}}`)
+		case http.MethodDelete:
+			deleted = r.Header.Get("Mcp-Session-Id") == "s1"
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := runDoctor([]string{server.URL + "/mcp"}, &out); err != nil {
+		t.Fatalf("doctor failed: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{"[warn] server URL", "[ok]   Streamable HTTP supported", "[warn] SSE not supported (HTTP 405)", "does not appear to require authorization"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+	if !deleted {
+		t.Error("Expected the probe session to be ended")
+	}
+}
+
+func TestDoctorOAuthServer(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-protected-resource/mcp", "/.well-known/oauth-protected-resource":
+			_, _ = fmt.Fprintf(w, `{"resource":"%s/mcp","authorization_servers":["%s"]}`, server.URL, server.URL)
+		case "/.well-known/oauth-authorization-server":
+			_, _ = fmt.Fprintf(w, `{"issuer":"%[1]s","authorization_endpoint":"%[1]s/authorize","token_endpoint":"%[1]s/token","code_challenge_methods_supported":["S256"]}`, server.URL)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+server.URL+`/.well-known/oauth-protected-resource/mcp"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := runDoctor([]string{server.URL + "/mcp"}, &out); err != nil {
+		t.Fatalf("doctor failed: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{"requires authorization (HTTP 401)", "[ok]   authorization server found", "[warn] authorization server does not support dynamic client registration"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDoctorFailures(t *testing.T) {
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"mcp.example.com", "invalid server URL"},
+		{"ftp://mcp.example.com", "unsupported URL scheme"},
+		{notFound.URL + "/mcp", "no MCP transport found"},
+		{untrusted.URL + "/mcp", "-ca-cert"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := runDoctor([]string{tt.url}, &out)
+		if !errors.Is(err, errDoctorFailed) {
+			t.Errorf("%s: expected errDoctorFailed, got %v", tt.url, err)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s: expected %q in output:\n%s", tt.url, tt.want, out.String())
+		}
+	}
+}
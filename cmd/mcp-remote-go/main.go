@@ -1,16 +1,33 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
+	"io"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/browser"
+	"github.com/naotama2002/mcp-remote-go/internal/headervalue"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+	"github.com/naotama2002/mcp-remote-go/internal/jwt"
+	"github.com/naotama2002/mcp-remote-go/internal/logging"
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+	"github.com/naotama2002/mcp-remote-go/internal/redact"
+	"github.com/naotama2002/mcp-remote-go/internal/resilience"
+	"github.com/naotama2002/mcp-remote-go/internal/status"
+	"github.com/naotama2002/mcp-remote-go/internal/trace"
 	"github.com/naotama2002/mcp-remote-go/proxy"
 )
 
@@ -22,60 +39,101 @@ var (
 )
 
 func main() {
-	log.Printf("mcp-remote-go version=%s commit=%s built=%s", version, gitCommit, buildTime)
-
-	var serverURL string
-	var callbackPort int
-	var allowHTTP bool
-	var transportMode string
-	var httpProxy string
-	var headers flagList
-
-	flag.StringVar(&serverURL, "server", "", "The MCP server URL to connect to")
-	flag.IntVar(&callbackPort, "port", 3334, "The callback port for OAuth")
-	flag.BoolVar(&allowHTTP, "allow-http", false, "Allow HTTP connections (only for trusted networks)")
-	flag.StringVar(&transportMode, "transport", "auto", "Transport mode: auto, streamable-http, sse")
-	flag.StringVar(&httpProxy, "https-proxy", "", "HTTP/HTTPS proxy URL (e.g. http://proxy:8080)")
-	flag.Var(&headers, "header", "Custom header to include in requests (format: 'Key:Value')")
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		var command func(args []string, stdout io.Writer) error
+		switch args[0] {
+		case "auth":
+			command = runAuthCommand
+		case "doctor":
+			command = runDoctor
+		case "version":
+			command = runVersion
+		case "mock-server":
+			command = runMockServer
+		case "serve":
+			command = runServe
+		case "run":
+			// The default command, named for scripts that spell it out.
+			args = args[1:]
+		}
+		if command != nil {
+			if err := command(args[1:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg := defaultCLIConfig()
+	fs := newFlagSet(&cfg, flag.ExitOnError)
+	_ = fs.Parse(args)
+	markSetFlags(fs, &cfg)
 
 	// Go's flag package stops parsing at the first non-flag argument.
 	// Re-parse remaining args to support flags after positional arguments.
-	cfg := parseRemainingArgs(flag.Args(), cliConfig{
-		serverURL:     serverURL,
-		callbackPort:  callbackPort,
-		allowHTTP:     allowHTTP,
-		transportMode: transportMode,
-		httpProxy:     httpProxy,
-		headers:       []string(headers),
-	})
-	serverURL = cfg.serverURL
-	callbackPort = cfg.callbackPort
-	allowHTTP = cfg.allowHTTP
-	transportMode = cfg.transportMode
-	httpProxy = cfg.httpProxy
-	headers = flagList(cfg.headers)
+	cfg = parseRemainingArgs(fs.Args(), cfg)
+
+	if cfg.showVersion {
+		if err := runVersion(nil, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Config file values fill in anything not given on the command line.
+	if cfg.configPath != "" {
+		fc, err := loadConfigFile(cfg.configPath)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fc.applyTo(&cfg)
+	}
+
+	redactor, err := redact.New(cfg.redactFields)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := logging.Setup(cfg.logLevel, cfg.logFormat, redactor); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	slog.Info("mcp-remote-go", "version", version, "commit", gitCommit, "built", buildTime)
+
+	serverURL := cfg.serverURL
+	callbackPort := cfg.callbackPort
+	allowHTTP := cfg.allowHTTP
+	transportMode := cfg.transportMode
+	httpProxy := cfg.httpProxy
+	headers := flagList(cfg.headers)
 
 	// Environment variable overrides (used by MCPB user_config)
 	applyEnvOverrides(&serverURL, &callbackPort, &allowHTTP, &transportMode, &httpProxy, &headers)
 
-	if serverURL == "" {
-		fmt.Println("Usage: mcp-remote-go -server <server-url> [-port <callback-port>] [-allow-http] [-transport auto|streamable-http|sse] [-https-proxy <proxy-url>] [-header 'Key:Value'] ...")
-		os.Exit(1)
+	var recording []proxy.RecordedMessage
+	if cfg.replayFile != "" {
+		if cfg.recordFile != "" {
+			log.Fatal("Error: -record cannot be combined with -replay")
+		}
+		var recordedServer string
+		if recording, recordedServer, err = loadRecording(cfg.replayFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if serverURL == "" {
+			serverURL = recordedServer
+		}
 	}
 
-	// Validate URL scheme
-	if !allowHTTP && !strings.HasPrefix(serverURL, "https://") {
-		log.Fatal("Error: Only HTTPS URLs are allowed. Use -allow-http for insecure connections.")
+	if serverURL == "" {
+		fmt.Println("Usage: mcp-remote-go [run] -server <server-url>|<name=url> [-server <name=url> ...] [-port <callback-port>] [-allow-http] [-transport auto|http-first|sse-first|streamable-http|sse|websocket] [-proxy-url <proxy-url>] [-ca-cert <file>] [-client-cert <file> -client-key <file>] [-insecure-skip-tls-verify] [-no-compression] [-max-idle-conns <n>] [-max-idle-conns-per-host <n>] [-max-conns-per-host <n>] [-idle-conn-timeout <duration>] [-disable-http2] [-header 'Key:Value'] [-header-refresh <duration>] [-token-store file|keychain] [-encrypt-store] [-config-dir <dir>] [-audit-log] [-prune-after <duration>] [-profile <name>] [-auth-flow browser|device] [-provider <name>[:<tenant>]] [-auth-param <key>=<value>] [-auth-timeout <duration>] [-http-timeout <duration>] [-http-retries <n>] [-http-retry-delay <duration>] [-no-browser] [-browser-cmd <command>] [-auth bearer:<token>|-auth-env <VAR>] [-client-id <id> -client-secret <secret>] [-token-auth-method <method>] [-client-assertion-key <file> [-client-assertion-key-id <kid>]] [-revoke-on-exit] [-resource <uri>] [-callback-origin <origin>] [-callback-host <host>] [-callback-bind <addr>] [-callback-tls] [-assume-pkce] [-callback-success-page <file|text>] [-callback-error-page <file|text>] [-config <file>] [-log-level debug|info|warn|error] [-log-format text|json] [-forward-server-logs] [-allow-tool <glob>] [-deny-tool <glob>] [-status-port <port>] [-shutdown-timeout <duration>] [-request-timeout <duration>] [-list-cache-ttl <duration>] [-keepalive-interval <duration>] [-reconnect-initial <duration>] [-reconnect-multiplier <factor>] [-reconnect-max-wait <duration>] [-reconnect-max-attempts <n>] [-max-rps <n> [-burst <n>]] [-breaker-threshold <n>] [-breaker-cooldown <duration>] [-queue-when-rate-limited] [-send-buffer <messages>] [-output-queue <messages>] [-resume-session] [-stdio-framing auto|newline|content-length] [-max-message-size <bytes>] [-max-event-size <bytes>] [-max-body-size <bytes>] [-strict] [-eager-init] [-decorate-client-info] [-protocol-version <version>] [-shared] [-listen <addr>] [-trace-file <file>] [-record <file>|-replay <file>] [-redact-field <path>] [-version] ...")
+		fmt.Println("       mcp-remote-go auth|doctor|mock-server|serve|version ...")
+		os.Exit(1)
 	}
 
-	// Validate transport mode
-	mode := proxy.TransportMode(transportMode)
-	switch mode {
-	case proxy.TransportModeAuto, proxy.TransportModeStreamableHTTP, proxy.TransportModeSSE:
-		// valid
-	default:
-		log.Fatalf("Error: Invalid transport mode '%s'. Must be one of: auto, streamable-http, sse", transportMode)
+	mode, err := proxy.ParseTransportMode(transportMode)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
 	// Convert headers to a map
@@ -87,36 +145,602 @@ func main() {
 		}
 	}
 
-	// Get server URL hash for storage
-	serverURLHash := getServerURLHash(serverURL)
+	if cfg.configDir != "" {
+		auth.SetConfigDir(cfg.configDir)
+	}
+	auth.SetAuditLog(cfg.auditLog)
+	tokenStore, err := newTokenStore(cfg.tokenStore, cfg.encryptStore)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	switch cfg.authFlow {
+	case auth.AuthFlowBrowser, auth.AuthFlowDevice:
+		// valid
+	default:
+		log.Fatalf("Error: Invalid auth flow '%s'. Must be one of: browser, device", cfg.authFlow)
+	}
+	if cfg.protocolVersion != "" && !proxy.IsSupportedProtocolVersion(cfg.protocolVersion) {
+		log.Fatalf("Error: Invalid protocol version '%s'. Must be one of: %s", cfg.protocolVersion, strings.Join(proxy.SupportedProtocolVersions, ", "))
+	}
+
+	proxyOpts := []proxy.Option{
+		proxy.WithTokenStore(tokenStore),
+		proxy.WithAuthFlow(cfg.authFlow),
+		proxy.WithShutdownTimeout(cfg.shutdownTimeout),
+		proxy.WithRequestTimeout(cfg.requestTimeout),
+		proxy.WithListCache(cfg.listCacheTTL),
+		proxy.WithMaxRPS(cfg.maxRPS, cfg.burst),
+		proxy.WithCircuitBreaker(cfg.breakerThreshold, cfg.breakerCooldown),
+		proxy.WithHeaderTemplates(cfg.headerRefresh),
+		proxy.WithKeepaliveInterval(cfg.keepaliveInterval),
+		proxy.WithReconnectPolicy(cfg.reconnect),
+		proxy.WithSendBuffer(cfg.sendBuffer),
+	}
+
+	staticToken, err := resolveStaticToken(cfg.auth, cfg.authEnv)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if staticToken != "" {
+		proxyOpts = append(proxyOpts, proxy.WithStaticToken(staticToken))
+	}
+
+	clientID, clientSecret := cfg.clientID, cfg.clientSecret
+	if clientID == "" {
+		clientID = mcpbEnv("MCP_CLIENT_ID")
+	}
+	if clientSecret == "" {
+		clientSecret = mcpbEnv("MCP_CLIENT_SECRET")
+	}
+	if cfg.profile != "" {
+		if err := auth.ValidateProfile(cfg.profile); err != nil {
+			log.Fatalf("Error: -profile: %v", err)
+		}
+	}
+	if cfg.provider != "" {
+		provider, err := auth.LookupProvider(cfg.provider)
+		if err != nil {
+			log.Fatalf("Error: -provider: %v", err)
+		}
+		if staticToken != "" {
+			log.Fatal("Error: -provider cannot be combined with -auth or -auth-env")
+		}
+		proxyOpts = append(proxyOpts, proxy.WithProvider(provider))
+	}
+	authParams, err := parseAuthParams(cfg.authParams)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(authParams) > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithAuthParams(authParams))
+	}
+	if clientID != "" {
+		if staticToken != "" {
+			log.Fatal("Error: -client-id cannot be combined with -auth or -auth-env")
+		}
+		if cfg.provider != "" {
+			// The client registered with the provider, for -auth-flow.
+			proxyOpts = append(proxyOpts, proxy.WithClient(clientID, clientSecret))
+		} else {
+			proxyOpts = append(proxyOpts, proxy.WithClientCredentials(clientID, clientSecret))
+		}
+	} else if clientSecret != "" {
+		log.Fatal("Error: -client-secret requires -client-id")
+	}
+	if cfg.tokenAuth != "" || cfg.assertionKey != "" {
+		tokenAuth, err := tokenEndpointAuth(cfg.tokenAuth, cfg.assertionKey, cfg.assertionKeyID)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		proxyOpts = append(proxyOpts, tokenAuth)
+	}
+
+	if cfg.resumeSession {
+		proxyOpts = append(proxyOpts, proxy.WithSessionResume())
+	}
+	if cfg.noBrowser {
+		proxyOpts = append(proxyOpts, proxy.WithNoBrowser())
+	}
+	if cfg.browserCmd != "" {
+		if err := browser.ValidateCommand(cfg.browserCmd); err != nil {
+			log.Fatalf("Error: -browser-cmd: %v", err)
+		}
+		proxyOpts = append(proxyOpts, proxy.WithBrowserCommand(cfg.browserCmd))
+	}
+	if cfg.revokeOnExit {
+		proxyOpts = append(proxyOpts, proxy.WithRevokeOnExit())
+	}
+	if cfg.noCompression {
+		proxyOpts = append(proxyOpts, proxy.WithNoCompression())
+	}
+	if cfg.strict {
+		proxyOpts = append(proxyOpts, proxy.WithStrict())
+	}
+	if cfg.forwardLogs {
+		proxyOpts = append(proxyOpts, proxy.WithForwardServerLogs())
+	}
+	if cfg.decorateClient {
+		proxyOpts = append(proxyOpts, proxy.WithClientVersion(version))
+	}
+	if cfg.eagerInit {
+		proxyOpts = append(proxyOpts, proxy.WithEagerInit(version))
+	}
+	if cfg.protocolVersion != "" {
+		proxyOpts = append(proxyOpts, proxy.WithProtocolVersion(cfg.protocolVersion))
+	}
+	if cfg.queueRateLimited {
+		proxyOpts = append(proxyOpts, proxy.WithQueueWhenRateLimited())
+	}
+	if len(cfg.allowTools) > 0 || len(cfg.denyTools) > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithToolFilter(cfg.allowTools, cfg.denyTools))
+	}
 
-	// Create and start the proxy
-	p, err := proxy.NewProxyWithOptions(serverURL, callbackPort, headerMap, serverURLHash, mode, httpProxy)
+	tlsConfig, err := httpclient.TLSOptions{
+		CACertFile:         cfg.caCert,
+		ClientCertFile:     cfg.clientCert,
+		ClientKeyFile:      cfg.clientKey,
+		InsecureSkipVerify: cfg.insecureSkipTLSVerify,
+	}.TLSConfig()
 	if err != nil {
-		log.Fatalf("Failed to create proxy: %v", err)
+		log.Fatalf("Error: %v", err)
+	}
+	if tlsConfig != nil {
+		if cfg.insecureSkipTLSVerify {
+			slog.Warn("TLS certificate verification is disabled; use only for testing")
+		}
+		proxyOpts = append(proxyOpts, proxy.WithTLSConfig(tlsConfig))
+	}
+
+	proxyOpts = append(proxyOpts, proxy.WithConnectionPool(cfg.pool), proxy.WithOutputQueue(cfg.outputQueue))
+	for _, origin := range cfg.callbackOrigins {
+		if err := auth.ValidateCallbackOrigin(origin); err != nil {
+			log.Fatalf("Error: -callback-origin: %v", err)
+		}
+	}
+	proxyOpts = append(proxyOpts, proxy.WithCallbackOrigins(cfg.callbackOrigins), proxy.WithAuthTimeout(cfg.authTimeout))
+	if cfg.callbackHost != "" {
+		if err := auth.ValidateCallbackHost(cfg.callbackHost); err != nil {
+			log.Fatalf("Error: -callback-host: %v", err)
+		}
+	}
+	if cfg.callbackBind != "" {
+		if err := auth.ValidateCallbackBind(cfg.callbackBind); err != nil {
+			log.Fatalf("Error: -callback-bind: %v", err)
+		}
+	}
+	proxyOpts = append(proxyOpts, proxy.WithCallbackHost(cfg.callbackHost), proxy.WithCallbackBind(cfg.callbackBind))
+	if cfg.callbackTLS {
+		proxyOpts = append(proxyOpts, proxy.WithCallbackTLS())
+	}
+	if cfg.assumePKCE {
+		proxyOpts = append(proxyOpts, proxy.WithAssumePKCE())
+	}
+	if cfg.httpTimeout <= 0 || cfg.httpRetries < 0 || cfg.httpRetryDelay <= 0 {
+		log.Fatal("Error: -http-timeout and -http-retry-delay must be positive and -http-retries must not be negative")
+	}
+	proxyOpts = append(proxyOpts, proxy.WithHTTPTimeout(cfg.httpTimeout), proxy.WithHTTPRetries(cfg.httpRetries, cfg.httpRetryDelay))
+	var successPage, errorPage *template.Template
+	if cfg.callbackSuccessPage != "" {
+		if successPage, err = auth.LoadCallbackPage("success", cfg.callbackSuccessPage); err != nil {
+			log.Fatalf("Error: -callback-success-page: %v", err)
+		}
+	}
+	if cfg.callbackErrorPage != "" {
+		if errorPage, err = auth.LoadCallbackPage("error", cfg.callbackErrorPage); err != nil {
+			log.Fatalf("Error: -callback-error-page: %v", err)
+		}
+	}
+	proxyOpts = append(proxyOpts, proxy.WithCallbackPages(successPage, errorPage))
+	proxyOpts = append(proxyOpts, proxy.WithStdioFraming(cfg.stdioFraming), proxy.WithMaxMessageSize(cfg.maxMessageSize),
+		proxy.WithMaxEventSize(cfg.maxEventSize), proxy.WithMaxBodySize(cfg.maxBodySize))
+
+	if cfg.traceFile != "" {
+		tracer, err := trace.Open(cfg.traceFile, 0, 0)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		tracer.SetRedactor(redactor)
+		defer func() { _ = tracer.Close() }()
+		proxyOpts = append(proxyOpts, proxy.WithTracer(tracer))
+	}
+	if cfg.recordFile != "" {
+		recorder, err := trace.Create(cfg.recordFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		recorder.SetRedactor(redactor)
+		defer func() { _ = recorder.Close() }()
+		proxyOpts = append(proxyOpts, proxy.WithTracer(recorder))
+	}
+	if recording != nil {
+		proxyOpts = append(proxyOpts, proxy.WithReplay(recording))
+	}
+
+	var p runner
+	var report status.ReportFunc
+	var statuses func() []proxy.Status
+	if isAggregateMode(cfg.servers) || len(cfg.serverConfigs) > 0 {
+		if staticToken != "" || clientID != "" {
+			log.Fatal("Error: -auth, -auth-env and -client-id apply to a single server. Use per-server headers to authenticate several servers.")
+		}
+		if cfg.resource != "" {
+			log.Fatal("Error: -resource applies to a single server")
+		}
+		if cfg.provider != "" {
+			log.Fatal("Error: -provider applies to a single server")
+		}
+		if len(cfg.authParams) > 0 {
+			log.Fatal("Error: -auth-param applies to a single server")
+		}
+		if cfg.shared {
+			log.Fatal("Error: -shared applies to a single server")
+		}
+		if cfg.listen != "" {
+			log.Fatal("Error: -listen applies to a single server")
+		}
+		if recording != nil {
+			log.Fatal("Error: -replay applies to a single server")
+		}
+		servers := cfg.serverConfigs
+		if len(servers) == 0 {
+			servers = serverConfigsFromSpecs(cfg.servers)
+		}
+		for i := range servers {
+			if servers[i].Profile == "" {
+				servers[i].Profile = cfg.profile
+			}
+		}
+		upstreams, err := buildUpstreams(servers, headerMap, cfg.scopes)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		for _, u := range upstreams {
+			if !allowHTTP && !isSecureURL(u.ServerURL) {
+				log.Fatalf("Error: Only HTTPS (or WSS) URLs are allowed (server %q). Use -allow-http for insecure connections.", u.Name)
+			}
+		}
+		if cfg.pruneAfter > 0 {
+			keep := make([]string, 0, len(upstreams))
+			for _, u := range upstreams {
+				keep = append(keep, u.ServerURLHash)
+			}
+			pruneCredentials(tokenStore, cfg.pruneAfter, keep...)
+		}
+
+		agg, err := proxy.NewAggregator(proxy.AggregatorConfig{
+			Upstreams:    upstreams,
+			CallbackPort: callbackPort,
+			Mode:         mode,
+			HTTPProxyURL: httpProxy,
+			Version:      version,
+		}, proxyOpts...)
+		if err != nil {
+			log.Fatalf("Failed to create aggregator: %v", err)
+		}
+		p = agg
+		statuses = agg.Status
+		report = func() (bool, any) {
+			statuses := agg.Status()
+			for _, st := range statuses {
+				if st.Connected {
+					return true, statuses
+				}
+			}
+			return false, statuses
+		}
+	} else {
+		// Validate URL scheme
+		if !allowHTTP && recording == nil && !isSecureURL(serverURL) {
+			log.Fatal("Error: Only HTTPS (or WSS) URLs are allowed. Use -allow-http for insecure connections.")
+		}
+
+		// Get server URL hash for storage
+		serverURLHash := getServerURLHash(serverURL, cfg.profile)
+		if cfg.pruneAfter > 0 {
+			pruneCredentials(tokenStore, cfg.pruneAfter, serverURLHash)
+		}
+
+		// Create the proxy
+		single, err := proxy.NewProxyWithOptions(serverURL, callbackPort, headerMap, serverURLHash, mode, httpProxy,
+			append(proxyOpts, proxy.WithScopes(cfg.scopes), proxy.WithResource(cfg.resource))...)
+		if err != nil {
+			log.Fatalf("Failed to create proxy: %v", err)
+		}
+		p = single
+		statuses = func() []proxy.Status { return []proxy.Status{single.Status()} }
+		report = func() (bool, any) {
+			st := single.Status()
+			return st.Connected, st
+		}
+
+		if cfg.shared && cfg.listen != "" {
+			log.Fatal("Error: -listen cannot be combined with -shared")
+		}
+		if cfg.shared {
+			conn, listener, err := proxy.ConnectShared(auth.SocketPath(serverURLHash))
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			if conn != nil {
+				if err := proxy.AttachStdio(conn, proxyOpts...); err != nil {
+					log.Fatalf("Proxy error: %v", err)
+				}
+				return
+			}
+			p = proxy.NewMultiplexer(single, listener)
+		}
+		if cfg.listen != "" {
+			listener, err := proxy.ListenHTTP(cfg.listen)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			p = proxy.NewHTTPServer(single, listener)
+		}
+	}
+
+	if cfg.statusPort != 0 {
+		statusServer := status.New(report)
+		statusServer.Handle("GET /metrics", metrics.Handler())
+		if err := statusServer.Start(cfg.statusPort); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer func() { _ = statusServer.Close() }()
+		slog.Info("status endpoint listening", "addr", statusServer.Addr())
 	}
 
 	// Set up graceful shutdown
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
+	// Shutdown makes Start return, so the latency summary is written once
+	// below however the proxy stops.
 	go func() {
 		<-signals
 		fmt.Println("Shutting down...")
 		p.Shutdown()
-		os.Exit(0)
 	}()
 
 	// Start the proxy
 	if err := p.Start(); err != nil {
 		log.Fatalf("Proxy error: %v", err)
 	}
+	_ = proxy.WriteLatencySummary(os.Stderr, statuses())
+}
+
+const versionUsage = "Usage: mcp-remote-go version"
+
+// runVersion prints the version, commit and build time of the binary and
+// the Go version it was built with.
+func runVersion(args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return errors.New(versionUsage)
+	}
+	_, err := fmt.Fprintf(stdout, "mcp-remote-go %s (commit %s, built %s, %s)\n", version, gitCommit, buildTime, runtime.Version())
+	return err
+}
+
+// runner is implemented by the single-server Proxy, the Aggregator and the
+// Multiplexer.
+type runner interface {
+	Start() error
+	Shutdown()
+}
+
+// isAggregateMode reports whether the -server values request aggregation:
+// more than one server, or any server given in "name=url" form.
+func isAggregateMode(specs []string) bool {
+	if len(specs) > 1 {
+		return true
+	}
+	for _, spec := range specs {
+		if name, _ := parseServerSpec(spec); name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseServerSpec splits a -server value of the form "name=url". Values
+// without a name prefix return an empty name and the value as the URL.
+func parseServerSpec(spec string) (name, serverURL string) {
+	if i := strings.Index(spec, "="); i > 0 && !strings.Contains(spec[:i], "://") {
+		return spec[:i], spec[i+1:]
+	}
+	return "", spec
+}
+
+// serverConfigsFromSpecs converts -server values into server definitions.
+func serverConfigsFromSpecs(specs []string) []serverConfig {
+	servers := make([]serverConfig, 0, len(specs))
+	for _, spec := range specs {
+		name, serverURL := parseServerSpec(spec)
+		servers = append(servers, serverConfig{Name: name, URL: serverURL})
+	}
+	return servers
+}
+
+// buildUpstreams converts server definitions into aggregator upstreams.
+// Servers without an explicit name are named after the first label of their
+// host. Per-server headers are layered over the global headers, and
+// per-server scopes replace the global scopes.
+func buildUpstreams(servers []serverConfig, headers map[string]string, scopes []string) ([]proxy.Upstream, error) {
+	used := make(map[string]bool)
+	var upstreams []proxy.Upstream
+	for _, s := range servers {
+		if s.URL == "" {
+			return nil, fmt.Errorf("missing URL for server %q", s.Name)
+		}
+		name := s.Name
+		if name == "" {
+			name = deriveServerName(s.URL)
+			base := name
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s-%d", base, i)
+			}
+		}
+		if used[name] {
+			return nil, fmt.Errorf("duplicate server name %q", name)
+		}
+		used[name] = true
+
+		upstreamHeaders := headers
+		if len(s.Headers) > 0 {
+			upstreamHeaders = make(map[string]string, len(headers)+len(s.Headers))
+			for k, v := range headers {
+				upstreamHeaders[k] = v
+			}
+			for k, v := range s.Headers {
+				upstreamHeaders[k] = v
+			}
+		}
+		upstreamScopes := scopes
+		if len(s.Scopes) > 0 {
+			upstreamScopes = s.Scopes
+		}
+
+		if s.Profile != "" {
+			if err := auth.ValidateProfile(s.Profile); err != nil {
+				return nil, fmt.Errorf("server %q: %w", name, err)
+			}
+		}
+
+		upstreams = append(upstreams, proxy.Upstream{
+			Name:          name,
+			ServerURL:     s.URL,
+			ServerURLHash: getServerURLHash(s.URL, s.Profile),
+			Headers:       upstreamHeaders,
+			Scopes:        upstreamScopes,
+		})
+	}
+	return upstreams, nil
+}
+
+// deriveServerName returns a namespace for serverURL, e.g. "mcp" for
+// https://mcp.example.com/sse. Characters outside [A-Za-z0-9_-] become '_'.
+func deriveServerName(serverURL string) string {
+	host := serverURL
+	if u, err := url.Parse(serverURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	label, _, _ := strings.Cut(host, ".")
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, label)
+	if name == "" {
+		return "server"
+	}
+	return name
+}
+
+// resolveStaticToken returns the bearer token configured with -auth or
+// -auth-env, or "" when OAuth should be used.
+func resolveStaticToken(spec, envVar string) (string, error) {
+	if spec != "" && envVar != "" {
+		return "", errors.New("-auth and -auth-env cannot be used together")
+	}
+	if envVar != "" {
+		token := strings.TrimSpace(os.Getenv(envVar))
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty or not set", envVar)
+		}
+		return token, nil
+	}
+	if spec == "" {
+		return "", nil
+	}
+
+	scheme, token, ok := strings.Cut(spec, ":")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return "", errors.New("invalid -auth value: expected 'bearer:<token>'")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("invalid -auth value: token is empty")
+	}
+	return token, nil
 }
 
-// getServerURLHash creates a unique hash based on the server URL
-func getServerURLHash(serverURL string) string {
-	hash := sha256.Sum256([]byte(serverURL))
-	return hex.EncodeToString(hash[:])
+// parseAuthParams parses -auth-param "key=value" entries; later entries win.
+func parseAuthParams(entries []string) (map[string]string, error) {
+	params := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -auth-param %q: expected 'key=value'", entry)
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// tokenEndpointAuth returns the option for -token-auth-method and
+// -client-assertion-key, loading the key from keyFile.
+func tokenEndpointAuth(method, keyFile, keyID string) (proxy.Option, error) {
+	if method != "" && !auth.ValidTokenAuthMethod(method) {
+		return nil, fmt.Errorf("invalid -token-auth-method %q: must be one of %s, %s, %s, %s", method,
+			auth.TokenAuthClientSecretBasic, auth.TokenAuthClientSecretPost, auth.TokenAuthPrivateKeyJWT, auth.TokenAuthNone)
+	}
+	if keyFile == "" {
+		if method == auth.TokenAuthPrivateKeyJWT {
+			return nil, errors.New("-token-auth-method private_key_jwt requires -client-assertion-key")
+		}
+		return proxy.WithTokenEndpointAuth(method, nil, ""), nil
+	}
+	if method != "" && method != auth.TokenAuthPrivateKeyJWT {
+		return nil, fmt.Errorf("-client-assertion-key applies to private_key_jwt, not %s", method)
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client assertion key: %w", err)
+	}
+	key, err := jwt.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client assertion key %s: %w", keyFile, err)
+	}
+	return proxy.WithTokenEndpointAuth(auth.TokenAuthPrivateKeyJWT, key, keyID), nil
+}
+
+// isSecureURL reports whether serverURL uses an encrypted scheme (https or wss).
+func isSecureURL(serverURL string) bool {
+	return strings.HasPrefix(serverURL, "https://") || strings.HasPrefix(serverURL, "wss://")
+}
+
+// newTokenStore returns the token store backend kind, encrypting documents
+// when encrypt is set.
+func newTokenStore(kind string, encrypt bool) (auth.TokenStore, error) {
+	store, err := auth.NewTokenStore(kind)
+	if err != nil || !encrypt {
+		return store, err
+	}
+	codec, err := auth.NewEncryptionCodec()
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewCodecTokenStore(store, codec), nil
+}
+
+// pruneCredentials removes the credentials of servers other than keep that
+// have gone unused for maxAge.
+func pruneCredentials(store auth.TokenStore, maxAge time.Duration, keep ...string) {
+	removed, err := auth.PruneServers(store, maxAge, time.Now(), keep...)
+	for _, s := range removed {
+		slog.Info("removed unused credentials", "server", displayURL(s.URL), "key", s.Key)
+	}
+	if err != nil {
+		slog.Warn("failed to prune unused credentials", "error", err)
+	}
+}
+
+// getServerURLHash creates a unique hash based on the server URL and the
+// credential profile, if any
+func getServerURLHash(serverURL, profile string) string {
+	return auth.ProfileServerKey(serverURL, profile)
 }
 
 // flagList is a custom flag type to handle multiple header entries
@@ -131,49 +755,298 @@ func (f *flagList) Set(value string) error {
 	return nil
 }
 
+// serverFlag collects repeated -server values. The first value is also
+// stored as cliConfig.serverURL so single-server usage is unchanged.
+type serverFlag struct {
+	cfg *cliConfig
+}
+
+func (f serverFlag) String() string {
+	if f.cfg == nil {
+		return ""
+	}
+	return strings.Join(f.cfg.servers, ",")
+}
+
+func (f serverFlag) Set(value string) error {
+	f.cfg.servers = append(f.cfg.servers, value)
+	if len(f.cfg.servers) == 1 {
+		f.cfg.serverURL = value
+	}
+	return nil
+}
+
 // cliConfig holds parsed CLI configuration.
 type cliConfig struct {
-	serverURL     string
-	callbackPort  int
-	allowHTTP     bool
-	transportMode string
-	httpProxy     string
-	headers       []string
+	serverURL       string
+	servers         []string
+	callbackPort    int
+	allowHTTP       bool
+	transportMode   string
+	httpProxy       string
+	headers         []string
+	tokenStore      string
+	configDir       string
+	pruneAfter      time.Duration
+	headerRefresh   time.Duration
+	configPath      string
+	scopes          []string
+	resource        string
+	serverConfigs   []serverConfig
+	logLevel        string
+	logFormat       string
+	traceFile       string
+	recordFile      string
+	replayFile      string
+	stdioFraming    string
+	maxMessageSize  int
+	maxEventSize    int
+	maxBodySize     int
+	authFlow        string
+	provider        string
+	authParams      []string
+	profile         string
+	auth            string
+	authEnv         string
+	clientID        string
+	clientSecret    string
+	tokenAuth       string
+	assertionKey    string
+	assertionKeyID  string
+	resumeSession   bool
+	noBrowser       bool
+	browserCmd      string
+	noCompression   bool
+	strict          bool
+	eagerInit       bool
+	decorateClient  bool
+	forwardLogs     bool
+	revokeOnExit    bool
+	protocolVersion string
+	shared          bool
+	listen          string
+	showVersion     bool
+	encryptStore    bool
+	auditLog        bool
+	statusPort      int
+	allowTools      []string
+	redactFields    []string
+	callbackOrigins []string
+	denyTools       []string
+	caCert          string
+	clientCert      string
+	clientKey       string
+
+	shutdownTimeout time.Duration
+	requestTimeout  time.Duration
+	authTimeout     time.Duration
+	httpTimeout     time.Duration
+	httpRetries     int
+	httpRetryDelay  time.Duration
+	listCacheTTL    time.Duration
+
+	keepaliveInterval time.Duration
+	reconnect         backoff.Policy
+	maxRPS            float64
+	burst             int
+	breakerThreshold  int
+	breakerCooldown   time.Duration
+	queueRateLimited  bool
+	sendBuffer        int
+	outputQueue       int
+
+	insecureSkipTLSVerify bool
+	pool                  httpclient.PoolOptions
+	callbackSuccessPage   string
+	callbackErrorPage     string
+	callbackHost          string
+	callbackBind          string
+	callbackTLS           bool
+	assumePKCE            bool
+
+	// setFlags records flags given explicitly on the command line.
+	setFlags map[string]bool
+}
+
+// defaultCLIConfig returns the configuration used when no flags are given.
+func defaultCLIConfig() cliConfig {
+	return cliConfig{
+		callbackPort:   3334,
+		transportMode:  "auto",
+		tokenStore:     auth.TokenStoreFile,
+		pruneAfter:     auth.DefaultPruneAfter,
+		headerRefresh:  headervalue.DefaultRefresh,
+		logLevel:       "info",
+		logFormat:      logging.FormatText,
+		authFlow:       auth.AuthFlowBrowser,
+		authTimeout:    auth.DefaultAuthTimeout,
+		httpTimeout:    httpclient.DefaultConfig().Timeout,
+		httpRetries:    httpclient.DefaultConfig().MaxRetries,
+		httpRetryDelay: httpclient.DefaultConfig().RetryDelay,
+		stdioFraming:   proxy.FramingAuto,
+		maxMessageSize: proxy.DefaultMaxMessageSize,
+		maxEventSize:   proxy.DefaultMaxEventSize,
+		maxBodySize:    proxy.DefaultMaxBodySize,
+
+		shutdownTimeout: 10 * time.Second,
+		reconnect:       backoff.Default(),
+		sendBuffer:      proxy.DefaultSendBuffer,
+		outputQueue:     proxy.DefaultOutputQueue,
+
+		breakerThreshold: resilience.DefaultThreshold,
+		breakerCooldown:  resilience.DefaultCooldown,
+
+		pool: httpclient.PoolOptions{
+			MaxIdleConns:        httpclient.DefaultMaxIdleConns,
+			MaxIdleConnsPerHost: httpclient.DefaultMaxIdleConnsPerHost,
+			IdleConnTimeout:     httpclient.DefaultIdleConnTimeout,
+		},
+	}
+}
+
+// newFlagSet returns a FlagSet whose flags are bound to cfg, using the
+// current values in cfg as defaults.
+func newFlagSet(cfg *cliConfig, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet("mcp-remote-go", errorHandling)
+	fs.Var(serverFlag{cfg}, "server", "The MCP server URL to connect to; repeat as name=url to aggregate several servers")
+	fs.IntVar(&cfg.callbackPort, "port", cfg.callbackPort, "The callback port for OAuth")
+	fs.BoolVar(&cfg.allowHTTP, "allow-http", cfg.allowHTTP, "Allow HTTP connections (only for trusted networks)")
+	fs.StringVar(&cfg.transportMode, "transport", cfg.transportMode, "Transport mode: auto, http-first, sse-first, streamable-http, sse, websocket")
+	fs.StringVar(&cfg.httpProxy, "proxy-url", cfg.httpProxy, "Proxy URL for all requests: http://, https://, socks5:// or socks5h:// (default: HTTP_PROXY/HTTPS_PROXY/NO_PROXY)")
+	fs.StringVar(&cfg.httpProxy, "https-proxy", cfg.httpProxy, "Alias for -proxy-url")
+	fs.Var((*flagList)(&cfg.headers), "header", "Custom header to include in requests (format: 'Key:Value'); values may use ${VAR} and $(command)")
+	fs.DurationVar(&cfg.headerRefresh, "header-refresh", cfg.headerRefresh, "How often header values using $(command) are evaluated again (0 evaluates them once)")
+	fs.StringVar(&cfg.tokenStore, "token-store", cfg.tokenStore, "Credential storage backend: file, keychain (falls back to file when unavailable)")
+	fs.StringVar(&cfg.configDir, "config-dir", cfg.configDir, "Directory to store credentials in (default: the platform config directory)")
+	fs.DurationVar(&cfg.pruneAfter, "prune-after", cfg.pruneAfter, "At startup, delete credentials of other servers unused for this long whose token has expired (0 disables)")
+	fs.BoolVar(&cfg.auditLog, "audit-log", cfg.auditLog, "Append authentication and credential events to audit.log in the config directory")
+	fs.BoolVar(&cfg.encryptStore, "encrypt-store", cfg.encryptStore, "Encrypt stored credentials with a key from MCP_REMOTE_STORE_PASSPHRASE or the machine ID")
+	fs.StringVar(&cfg.configPath, "config", cfg.configPath, "Path to a YAML or JSON config file")
+	fs.StringVar(&cfg.logLevel, "log-level", cfg.logLevel, "Log level: debug, info, warn, error")
+	fs.StringVar(&cfg.logFormat, "log-format", cfg.logFormat, "Log format written to stderr: text, json")
+	fs.StringVar(&cfg.authFlow, "auth-flow", cfg.authFlow, "OAuth flow: browser, device (for machines without a browser)")
+	fs.Var((*flagList)(&cfg.authParams), "auth-param", "Extra parameter for OAuth authorization and token requests (format: key=value; repeatable)")
+	fs.StringVar(&cfg.profile, "profile", cfg.profile, "Keep separate credentials under this name, e.g. to use several accounts with one server")
+	fs.StringVar(&cfg.provider, "provider", cfg.provider, "Authorization server profile instead of discovery: github, google, azure-ad[:<tenant>], auth0:<domain>")
+	fs.StringVar(&cfg.auth, "auth", cfg.auth, "Static credentials instead of OAuth (format: 'bearer:<token>')")
+	fs.DurationVar(&cfg.authTimeout, "auth-timeout", cfg.authTimeout, "How long to wait for authorization in the browser")
+	fs.DurationVar(&cfg.httpTimeout, "http-timeout", cfg.httpTimeout, "How long each OAuth discovery, registration and token request may take")
+	fs.IntVar(&cfg.httpRetries, "http-retries", cfg.httpRetries, "Retries of an OAuth request after a network error or 5xx status; token exchanges and other POSTs are not retried")
+	fs.DurationVar(&cfg.httpRetryDelay, "http-retry-delay", cfg.httpRetryDelay, "Delay before retrying an OAuth request")
+	fs.BoolVar(&cfg.noBrowser, "no-browser", cfg.noBrowser, "Print the authorization URL instead of opening a browser, and accept the pasted redirect URL")
+	fs.StringVar(&cfg.browserCmd, "browser-cmd", cfg.browserCmd, "Command opening the authorization URL, e.g. 'firefox --private-window %s' (default: $BROWSER or the system browser)")
+	fs.BoolVar(&cfg.noCompression, "no-compression", cfg.noCompression, "Do not ask the server for gzip or deflate compressed responses")
+	fs.StringVar(&cfg.authEnv, "auth-env", cfg.authEnv, "Name of an environment variable holding a static bearer token")
+	fs.Var((*flagList)(&cfg.scopes), "scope", "OAuth scope to request (repeatable; default: mcp offline_access)")
+	fs.StringVar(&cfg.resource, "resource", cfg.resource, "OAuth resource indicator (RFC 8707) to request tokens for (default: derived from the server URL)")
+	fs.Var((*flagList)(&cfg.callbackOrigins), "callback-origin", "Accept requests from this origin (e.g. https://auth.example.com) on the OAuth callback server (repeatable)")
+	fs.StringVar(&cfg.callbackHost, "callback-host", cfg.callbackHost, "Host name or IP address of the OAuth callback server in the redirect URI (default: 127.0.0.1)")
+	fs.StringVar(&cfg.callbackBind, "callback-bind", cfg.callbackBind, "IP address the OAuth callback server listens on (default: 127.0.0.1)")
+	fs.BoolVar(&cfg.callbackTLS, "callback-tls", cfg.callbackTLS, "Serve the OAuth callback over HTTPS with a self-signed certificate and an https://localhost redirect URI")
+	fs.BoolVar(&cfg.assumePKCE, "assume-pkce", cfg.assumePKCE, "Use PKCE with S256 even when the authorization server does not advertise its PKCE methods")
+	fs.StringVar(&cfg.callbackSuccessPage, "callback-success-page", cfg.callbackSuccessPage, "HTML template file, or inline text, shown in the browser when OAuth authorization succeeds")
+	fs.StringVar(&cfg.callbackErrorPage, "callback-error-page", cfg.callbackErrorPage, "HTML template file, or inline text, shown in the browser when OAuth authorization fails ({{.Error}}, {{.ErrorDescription}})")
+	fs.StringVar(&cfg.clientID, "client-id", cfg.clientID, "OAuth client ID for the client credentials grant (or MCP_CLIENT_ID)")
+	fs.StringVar(&cfg.clientSecret, "client-secret", cfg.clientSecret, "OAuth client secret for the client credentials grant (or MCP_CLIENT_SECRET)")
+	fs.BoolVar(&cfg.revokeOnExit, "revoke-on-exit", cfg.revokeOnExit, "Revoke the OAuth tokens at the authorization server and delete them when the proxy exits")
+	fs.StringVar(&cfg.tokenAuth, "token-auth-method", cfg.tokenAuth, "Token endpoint client authentication: client_secret_basic, client_secret_post, private_key_jwt or none (default: as registered)")
+	fs.StringVar(&cfg.assertionKey, "client-assertion-key", cfg.assertionKey, "PEM private key signing private_key_jwt client assertions")
+	fs.StringVar(&cfg.assertionKeyID, "client-assertion-key-id", cfg.assertionKeyID, "Key ID (kid) announced in private_key_jwt client assertions")
+	fs.Var((*flagList)(&cfg.allowTools), "allow-tool", "Only expose tools matching this glob pattern (repeatable)")
+	fs.Var((*flagList)(&cfg.denyTools), "deny-tool", "Hide and block tools matching this glob pattern (repeatable; overrides -allow-tool)")
+	fs.Var((*flagList)(&cfg.redactFields), "redact-field", "Redact this field (e.g. params.arguments.password) from logs and traces (repeatable)")
+	fs.IntVar(&cfg.statusPort, "status-port", cfg.statusPort, "Serve /healthz, /status and /metrics on this local port (0 disables)")
+	fs.StringVar(&cfg.caCert, "ca-cert", cfg.caCert, "PEM file with CA certificates to trust in addition to the system roots")
+	fs.StringVar(&cfg.clientCert, "client-cert", cfg.clientCert, "PEM client certificate for mutual TLS (requires -client-key)")
+	fs.StringVar(&cfg.clientKey, "client-key", cfg.clientKey, "PEM private key for -client-cert")
+	fs.BoolVar(&cfg.insecureSkipTLSVerify, "insecure-skip-tls-verify", cfg.insecureSkipTLSVerify, "Do not verify server TLS certificates (only for testing)")
+	fs.IntVar(&cfg.pool.MaxIdleConns, "max-idle-conns", cfg.pool.MaxIdleConns, "Idle connections kept open across all hosts")
+	fs.IntVar(&cfg.pool.MaxIdleConnsPerHost, "max-idle-conns-per-host", cfg.pool.MaxIdleConnsPerHost, "Idle connections kept open per host")
+	fs.IntVar(&cfg.pool.MaxConnsPerHost, "max-conns-per-host", cfg.pool.MaxConnsPerHost, "Connections per host, including those in use (0 for no limit)")
+	fs.DurationVar(&cfg.pool.IdleConnTimeout, "idle-conn-timeout", cfg.pool.IdleConnTimeout, "How long an idle connection is kept open")
+	fs.BoolVar(&cfg.pool.DisableHTTP2, "disable-http2", cfg.pool.DisableHTTP2, "Use HTTP/1.1 only, e.g. for proxies that mishandle HTTP/2")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", cfg.shutdownTimeout, "How long to wait for in-flight requests on shutdown (0 closes immediately)")
+	fs.DurationVar(&cfg.requestTimeout, "request-timeout", cfg.requestTimeout, "Answer requests the server has not answered within this duration with an error (0 waits forever)")
+	fs.DurationVar(&cfg.listCacheTTL, "list-cache-ttl", cfg.listCacheTTL, "Answer repeated tools/list, resources/list and prompts/list requests from a cache for this duration (0 disables)")
+	fs.DurationVar(&cfg.keepaliveInterval, "keepalive-interval", cfg.keepaliveInterval, "Detect dead connections: ping the server, or expect SSE data, at this interval (0 disables)")
+	fs.DurationVar(&cfg.reconnect.Initial, "reconnect-initial", cfg.reconnect.Initial, "Delay before the first reconnection attempt")
+	fs.Float64Var(&cfg.reconnect.Multiplier, "reconnect-multiplier", cfg.reconnect.Multiplier, "Factor the delay grows by after each failed reconnection attempt")
+	fs.DurationVar(&cfg.reconnect.MaxWait, "reconnect-max-wait", cfg.reconnect.MaxWait, "Longest delay between reconnection attempts")
+	fs.IntVar(&cfg.reconnect.MaxAttempts, "reconnect-max-attempts", cfg.reconnect.MaxAttempts, "Reconnection attempts before giving up (negative retries forever)")
+	fs.Float64Var(&cfg.maxRPS, "max-rps", cfg.maxRPS, "Limit requests to the server to this many per second (0 disables)")
+	fs.IntVar(&cfg.burst, "burst", cfg.burst, "Requests that may be sent at once under -max-rps (default: -max-rps rounded up)")
+	fs.IntVar(&cfg.breakerThreshold, "breaker-threshold", cfg.breakerThreshold, "Consecutive failures after which requests are rejected without reaching the server (0 disables)")
+	fs.DurationVar(&cfg.breakerCooldown, "breaker-cooldown", cfg.breakerCooldown, "How long requests are rejected once -breaker-threshold is reached, before a trial request")
+	fs.BoolVar(&cfg.queueRateLimited, "queue-when-rate-limited", cfg.queueRateLimited, "Hold messages while the server is rate limiting (429/Retry-After), or over -max-rps, instead of failing them")
+	fs.IntVar(&cfg.sendBuffer, "send-buffer", cfg.sendBuffer, "Messages to buffer while reconnecting to the server, sent in order once it is back (0 disables)")
+	fs.IntVar(&cfg.outputQueue, "output-queue", cfg.outputQueue, "Messages for the client queued while it is slow to read them; more are dropped with an error (0 writes directly)")
+	fs.BoolVar(&cfg.resumeSession, "resume-session", cfg.resumeSession, "Keep the Streamable HTTP session across restarts instead of terminating it on exit")
+	fs.BoolVar(&cfg.strict, "strict", cfg.strict, "Reject messages that are not valid JSON-RPC 2.0 instead of forwarding them")
+	fs.BoolVar(&cfg.eagerInit, "eager-init", cfg.eagerInit, "Initialize the server on startup and answer the client's initialize from the cached result")
+	fs.BoolVar(&cfg.forwardLogs, "forward-server-logs", cfg.forwardLogs, "Pass the server's log messages (notifications/message) to the client as well as logging them")
+	fs.BoolVar(&cfg.decorateClient, "decorate-client-info", cfg.decorateClient, "Append \"(via mcp-remote-go <version>)\" to the clientInfo name the client sends in initialize")
+	fs.StringVar(&cfg.protocolVersion, "protocol-version", cfg.protocolVersion, "MCP protocol version to request instead of the client's")
+	fs.BoolVar(&cfg.shared, "shared", cfg.shared, "Share one connection to the server between every client started with -shared")
+	fs.StringVar(&cfg.listen, "listen", cfg.listen, "Serve the server as a local Streamable HTTP endpoint at http://<addr>/mcp instead of over stdio (e.g. :8080, loopback only unless a host is given)")
+	fs.StringVar(&cfg.stdioFraming, "stdio-framing", cfg.stdioFraming, "Stdio message framing: auto, newline, content-length")
+	fs.IntVar(&cfg.maxMessageSize, "max-message-size", cfg.maxMessageSize, "Largest message in bytes accepted on stdin; larger messages are skipped")
+	fs.IntVar(&cfg.maxEventSize, "max-event-size", cfg.maxEventSize, "Largest SSE event in bytes accepted from the server; a larger event drops the stream")
+	fs.IntVar(&cfg.maxBodySize, "max-body-size", cfg.maxBodySize, "Largest JSON response body or WebSocket message in bytes accepted from the server")
+	fs.StringVar(&cfg.traceFile, "trace-file", cfg.traceFile, "Write every JSON-RPC message to this file as JSONL (rotated at 10 MB)")
+	fs.StringVar(&cfg.recordFile, "record", cfg.recordFile, "Record the session's remote traffic to this file as JSONL for -replay")
+	fs.StringVar(&cfg.replayFile, "replay", cfg.replayFile, "Answer requests from a session recorded with -record instead of connecting to the server")
+	fs.BoolVar(&cfg.showVersion, "version", cfg.showVersion, "Print the version and exit")
+	return fs
+}
+
+// markSetFlags records the flags set on fs so config file values do not
+// override them.
+func markSetFlags(fs *flag.FlagSet, cfg *cliConfig) {
+	fs.Visit(func(f *flag.Flag) {
+		if cfg.setFlags == nil {
+			cfg.setFlags = make(map[string]bool)
+		}
+		cfg.setFlags[f.Name] = true
+	})
 }
 
 // parseRemainingArgs re-parses remaining args after flag.Parse() to support
 // flags after positional arguments (e.g.: mcp-remote-go https://server/mcp --transport streamable-http).
+// Flags and positional arguments may be freely interleaved; everything after
+// a "--" terminator is treated as positional.
 func parseRemainingArgs(remaining []string, defaults cliConfig) cliConfig {
 	cfg := defaults
-	var positionalArgs []string
+	cfg.headers = append([]string(nil), defaults.headers...)
+	cfg.servers = append([]string(nil), defaults.servers...)
+	cfg.allowTools = append([]string(nil), defaults.allowTools...)
+	cfg.denyTools = append([]string(nil), defaults.denyTools...)
+	cfg.redactFields = append([]string(nil), defaults.redactFields...)
+	cfg.scopes = append([]string(nil), defaults.scopes...)
+	cfg.callbackOrigins = append([]string(nil), defaults.callbackOrigins...)
+	cfg.setFlags = make(map[string]bool, len(defaults.setFlags))
+	for name := range defaults.setFlags {
+		cfg.setFlags[name] = true
+	}
+	fs := newFlagSet(&cfg, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
 
-	for i := 0; i < len(remaining); i++ {
-		arg := remaining[i]
-		switch {
-		case (arg == "--transport" || arg == "-transport") && i+1 < len(remaining):
-			cfg.transportMode = remaining[i+1]
-			i++
-		case strings.HasPrefix(arg, "--transport=") || strings.HasPrefix(arg, "-transport="):
-			cfg.transportMode = strings.SplitN(arg, "=", 2)[1]
-		case (arg == "--header" || arg == "-header") && i+1 < len(remaining):
-			cfg.headers = append(cfg.headers, remaining[i+1])
-			i++
-		case strings.HasPrefix(arg, "--header=") || strings.HasPrefix(arg, "-header="):
-			cfg.headers = append(cfg.headers, strings.SplitN(arg, "=", 2)[1])
-		case (arg == "--https-proxy" || arg == "-https-proxy") && i+1 < len(remaining):
-			cfg.httpProxy = remaining[i+1]
-			i++
-		case strings.HasPrefix(arg, "--https-proxy=") || strings.HasPrefix(arg, "-https-proxy="):
-			cfg.httpProxy = strings.SplitN(arg, "=", 2)[1]
-		case arg == "--allow-http" || arg == "-allow-http":
-			cfg.allowHTTP = true
-		case (arg == "--port" || arg == "-port") && i+1 < len(remaining):
-			if _, err := fmt.Sscanf(remaining[i+1], "%d", &cfg.callbackPort); err != nil {
-				log.Printf("Warning: failed to parse port: %v", err)
-			}
-			i++
-		default:
-			positionalArgs = append(positionalArgs, arg)
+	var positionalArgs []string
+	args := remaining
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			slog.Warn("failed to parse arguments", "error", err)
+			break
+		}
+		markSetFlags(fs, &cfg)
+		consumed := args[:len(args)-fs.NArg()]
+		args = fs.Args()
+		if len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			positionalArgs = append(positionalArgs, args...)
+			break
+		}
+		if len(args) > 0 {
+			positionalArgs = append(positionalArgs, args[0])
+			args = args[1:]
 		}
 	}
 
@@ -182,7 +1055,7 @@ func parseRemainingArgs(remaining []string, defaults cliConfig) cliConfig {
 		cfg.serverURL = positionalArgs[0]
 		if len(positionalArgs) > 1 {
 			if _, err := fmt.Sscanf(positionalArgs[1], "%d", &cfg.callbackPort); err != nil {
-				log.Printf("Warning: failed to parse callback port: %v", err)
+				slog.Warn("failed to parse callback port", "error", err)
 			}
 		}
 	}
@@ -216,7 +1089,7 @@ func applyEnvOverrides(serverURL *string, callbackPort *int, allowHTTP *bool, tr
 	}
 	if v := mcpbEnv("MCP_PORT"); v != "" && *callbackPort == 3334 {
 		if _, err := fmt.Sscanf(v, "%d", callbackPort); err != nil {
-			log.Printf("Warning: failed to parse MCP_PORT: %v", err)
+			slog.Warn("failed to parse MCP_PORT", "error", err)
 		}
 	}
 	if v := mcpbEnv("MCP_HTTPS_PROXY"); v != "" && *httpProxy == "" {
@@ -285,7 +1158,7 @@ func parseHeaderLines(raw string) []string {
 		}
 		idx := strings.Index(line, ":")
 		if idx < 0 {
-			log.Printf("Warning: ignoring header entry without ':' separator: %q", line)
+			slog.Warn("ignoring header entry without ':' separator", "entry", line)
 			continue
 		}
 		name := strings.TrimSpace(line[:idx])
@@ -298,7 +1171,7 @@ func parseHeaderLines(raw string) []string {
 			// e.g. a human-readable label like "Redash API Key" typed into the
 			// header-name field. Sending it would make net/http reject the whole
 			// request, so skip this entry and keep the others.
-			log.Printf("Warning: ignoring header entry with invalid header name %q (HTTP header names cannot contain spaces or special characters)", name)
+			slog.Warn("ignoring header entry with invalid header name (HTTP header names cannot contain spaces or special characters)", "name", name)
 			continue
 		}
 		out = append(out, line)
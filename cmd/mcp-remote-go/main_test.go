@@ -1,37 +1,45 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
 )
 
 func TestGetServerURLHash(t *testing.T) {
 	tests := []struct {
 		name      string
 		serverURL string
-		expected  string
 	}{
 		{
 			name:      "https URL",
 			serverURL: "https://example.com",
-			expected:  "4f5b812789fc606be1b3b16908db13fc7a9adf8ca72a1fdd6d91b1d769b56ba8",
 		},
 		{
 			name:      "http URL",
 			serverURL: "http://localhost:8080",
-			expected:  "bb3c84cb5b5df4133c3cf711fcc3ab51eb8e3b9a49b8b0b8d97e39b1a2e7b8b1",
 		},
 		{
 			name:      "empty string",
 			serverURL: "",
-			expected:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := getServerURLHash(tt.serverURL)
-			if len(result) != 64 {
-				t.Errorf("Expected hash length 64, got %d", len(result))
+			result := getServerURLHash(tt.serverURL, "")
+			if !auth.IsServerKey(result) {
+				t.Errorf("Expected a server key, got %q", result)
 			}
 			// Verify hash is unique
 			if result == "" {
@@ -41,14 +49,14 @@ func TestGetServerURLHash(t *testing.T) {
 	}
 
 	// Verify same input produces same hash
-	hash1 := getServerURLHash("https://example.com")
-	hash2 := getServerURLHash("https://example.com")
+	hash1 := getServerURLHash("https://example.com", "")
+	hash2 := getServerURLHash("https://example.com", "")
 	if hash1 != hash2 {
 		t.Errorf("Same input should produce same hash, got %s and %s", hash1, hash2)
 	}
 
 	// Verify different inputs produce different hashes
-	hash3 := getServerURLHash("https://different.com")
+	hash3 := getServerURLHash("https://different.com", "")
 	if hash1 == hash3 {
 		t.Error("Different inputs should produce different hashes")
 	}
@@ -97,3 +105,238 @@ func TestFlagList(t *testing.T) {
 		t.Errorf("Expected '%s', got '%s'", expected, result)
 	}
 }
+
+func TestIsSecureURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/mcp": true,
+		"wss://example.com/mcp":   true,
+		"http://localhost/mcp":    false,
+		"ws://localhost/mcp":      false,
+	}
+	for in, want := range tests {
+		if got := isSecureURL(in); got != want {
+			t.Errorf("isSecureURL(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestParseServerSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantName string
+		wantURL  string
+	}{
+		{"github=https://api.github.com/mcp", "github", "https://api.github.com/mcp"},
+		{"https://example.com/mcp", "", "https://example.com/mcp"},
+		{"https://example.com/mcp?a=b", "", "https://example.com/mcp?a=b"},
+		{"=https://example.com/mcp", "", "=https://example.com/mcp"},
+	}
+	for _, tt := range tests {
+		name, serverURL := parseServerSpec(tt.spec)
+		if name != tt.wantName || serverURL != tt.wantURL {
+			t.Errorf("parseServerSpec(%q) = (%q, %q), want (%q, %q)", tt.spec, name, serverURL, tt.wantName, tt.wantURL)
+		}
+	}
+}
+
+func TestIsAggregateMode(t *testing.T) {
+	tests := []struct {
+		specs []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"https://example.com/mcp"}, false},
+		{[]string{"github=https://api.github.com/mcp"}, true},
+		{[]string{"https://a.example.com/mcp", "https://b.example.com/mcp"}, true},
+	}
+	for _, tt := range tests {
+		if got := isAggregateMode(tt.specs); got != tt.want {
+			t.Errorf("isAggregateMode(%v) = %v, want %v", tt.specs, got, tt.want)
+		}
+	}
+}
+
+func TestBuildUpstreams(t *testing.T) {
+	upstreams, err := buildUpstreams(serverConfigsFromSpecs([]string{
+		"github=https://api.github.com/mcp",
+		"https://mcp.example.com/sse",
+		"https://mcp.other.com/sse",
+	}), map[string]string{"X-Test": "1"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantNames := []string{"github", "mcp", "mcp-2"}
+	for i, u := range upstreams {
+		if u.Name != wantNames[i] {
+			t.Errorf("Upstream %d: expected name %q, got %q", i, wantNames[i], u.Name)
+		}
+		if u.ServerURLHash != getServerURLHash(u.ServerURL, "") {
+			t.Errorf("Upstream %d: expected hash of its own URL", i)
+		}
+		if u.Headers["X-Test"] != "1" {
+			t.Errorf("Upstream %d: expected shared headers", i)
+		}
+	}
+
+	dup := serverConfigsFromSpecs([]string{"a=https://x.example.com", "a=https://y.example.com"})
+	if _, err := buildUpstreams(dup, nil, nil); err == nil {
+		t.Error("Expected error for duplicate server names")
+	}
+}
+
+func TestBuildUpstreamsPerServerOverrides(t *testing.T) {
+	upstreams, err := buildUpstreams([]serverConfig{
+		{Name: "a", URL: "https://a.example.com/mcp", Headers: map[string]string{"X-Key": "a"}, Scopes: []string{"read"}},
+		{Name: "b", URL: "https://b.example.com/mcp"},
+	}, map[string]string{"X-Key": "global", "X-Other": "1"}, []string{"mcp"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if upstreams[0].Headers["X-Key"] != "a" || upstreams[0].Headers["X-Other"] != "1" {
+		t.Errorf("Expected per-server header layered over globals, got %v", upstreams[0].Headers)
+	}
+	if len(upstreams[0].Scopes) != 1 || upstreams[0].Scopes[0] != "read" {
+		t.Errorf("Expected per-server scopes, got %v", upstreams[0].Scopes)
+	}
+	if upstreams[1].Headers["X-Key"] != "global" {
+		t.Errorf("Expected global headers for b, got %v", upstreams[1].Headers)
+	}
+	if len(upstreams[1].Scopes) != 1 || upstreams[1].Scopes[0] != "mcp" {
+		t.Errorf("Expected global scopes for b, got %v", upstreams[1].Scopes)
+	}
+}
+
+func TestBuildUpstreamsProfile(t *testing.T) {
+	upstreams, err := buildUpstreams([]serverConfig{
+		{Name: "work", URL: "https://mcp.example.com/mcp", Profile: "work"},
+		{Name: "personal", URL: "https://mcp.example.com/mcp"},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if upstreams[0].ServerURLHash != getServerURLHash("https://mcp.example.com/mcp", "work") {
+		t.Errorf("Expected the profile's key, got %s", upstreams[0].ServerURLHash)
+	}
+	if upstreams[0].ServerURLHash == upstreams[1].ServerURLHash {
+		t.Error("Expected a profile to keep credentials apart from the default ones")
+	}
+
+	if _, err := buildUpstreams([]serverConfig{{URL: "https://mcp.example.com/mcp", Profile: "Work/1"}}, nil, nil); err == nil {
+		t.Error("Expected error for an invalid profile")
+	}
+}
+
+func TestResolveStaticToken(t *testing.T) {
+	t.Setenv("TEST_MCP_TOKEN", " env-token ")
+	t.Setenv("TEST_MCP_EMPTY", "")
+
+	tests := []struct {
+		name     string
+		spec     string
+		envVar   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "none", expected: ""},
+		{name: "bearer", spec: "bearer:abc123", expected: "abc123"},
+		{name: "scheme case-insensitive", spec: "Bearer: abc123", expected: "abc123"},
+		{name: "token with colon", spec: "bearer:a:b", expected: "a:b"},
+		{name: "env", envVar: "TEST_MCP_TOKEN", expected: "env-token"},
+		{name: "unknown scheme", spec: "basic:abc", wantErr: true},
+		{name: "missing scheme", spec: "abc123", wantErr: true},
+		{name: "empty token", spec: "bearer:", wantErr: true},
+		{name: "empty env", envVar: "TEST_MCP_EMPTY", wantErr: true},
+		{name: "both", spec: "bearer:abc", envVar: "TEST_MCP_TOKEN", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := resolveStaticToken(tt.spec, tt.envVar)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got token %q", token)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if token != tt.expected {
+				t.Errorf("Expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params, err := parseAuthParams([]string{"audience=https://api.example.com", "prompt=login", "prompt=consent", "empty=", "eq=a=b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]string{"audience": "https://api.example.com", "prompt": "consent", "empty": "", "eq": "a=b"}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("Expected %v, got %v", want, params)
+	}
+
+	for _, entry := range []string{"audience", "=value"} {
+		if _, err := parseAuthParams([]string{entry}); err == nil {
+			t.Errorf("Expected error for %q", entry)
+		}
+	}
+}
+
+func TestTokenEndpointAuth(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		keyFile string
+		wantErr bool
+	}{
+		{name: "basic", method: "client_secret_basic"},
+		{name: "key alone", keyFile: keyFile},
+		{name: "private_key_jwt", method: "private_key_jwt", keyFile: keyFile},
+		{name: "unknown method", method: "tls_client_auth", wantErr: true},
+		{name: "private_key_jwt without key", method: "private_key_jwt", wantErr: true},
+		{name: "key with other method", method: "client_secret_post", keyFile: keyFile, wantErr: true},
+		{name: "missing key file", keyFile: filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt, err := tokenEndpointAuth(tt.method, tt.keyFile, "kid")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && opt == nil {
+				t.Error("Expected an option")
+			}
+		})
+	}
+}
+
+func TestRunVersion(t *testing.T) {
+	var out bytes.Buffer
+	if err := runVersion(nil, &out); err != nil {
+		t.Fatalf("runVersion failed: %v", err)
+	}
+	if got, want := out.String(), "mcp-remote-go dev (commit unknown, built unknown, "+runtime.Version()+")\n"; got != want {
+		t.Errorf("Unexpected version output %q", got)
+	}
+	if err := runVersion([]string{"extra"}, &out); err == nil {
+		t.Error("Expected arguments to be rejected")
+	}
+}
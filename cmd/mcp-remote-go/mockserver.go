@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/mockserver"
+)
+
+const mockServerUsage = `Usage: mcp-remote-go mock-server [-listen <addr>] [-config <file>] [-name <name>] [-tool <name>[=<result>] ...] [-latency <duration>] [-token <token>]
+
+Serves a mock MCP server for trying out the proxy and client configurations:
+Streamable HTTP on /mcp and the legacy SSE transport on /sse. Tools return
+the given result, or echo their arguments. -config reads the server from a
+YAML file with per-tool latencies and errors; flags override it.
+`
+
+// defaultMockServerAddr is where the mock server listens by default.
+const defaultMockServerAddr = "127.0.0.1:8808"
+
+// runMockServer implements "mcp-remote-go mock-server", which serves a
+// mock MCP server until interrupted.
+func runMockServer(args []string, stdout io.Writer) error {
+	cfg, addr, err := parseMockServerArgs(args)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	server := &http.Server{Handler: mockserver.New(cfg).Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	base := "http://" + listener.Addr().String()
+	_, _ = fmt.Fprintf(stdout, "Mock MCP server listening:\n  Streamable HTTP: %s/mcp\n  SSE:             %s/sse\n", base, base)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// parseMockServerArgs returns the mock server configuration and listen
+// address given by args.
+func parseMockServerArgs(args []string) (mockserver.Config, string, error) {
+	flags := flag.NewFlagSet("mcp-remote-go mock-server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	addr := flags.String("listen", defaultMockServerAddr, "Address to listen on")
+	configPath := flags.String("config", "", "YAML file describing the server")
+	name := flags.String("name", "", "Server name reported in initialize")
+	var tools flagList
+	flags.Var(&tools, "tool", "Tool to offer as name or name=result (can be repeated)")
+	latency := flags.Duration("latency", 0, "Delay before every response")
+	token := flags.String("token", "", "Bearer token requests must carry")
+	if err := flags.Parse(args); err != nil {
+		return mockserver.Config{}, "", fmt.Errorf("%w\n\n%s", err, mockServerUsage)
+	}
+	if flags.NArg() != 0 {
+		return mockserver.Config{}, "", errors.New(mockServerUsage)
+	}
+
+	var cfg mockserver.Config
+	if *configPath != "" {
+		var err error
+		if cfg, err = mockserver.LoadConfig(*configPath); err != nil {
+			return cfg, "", err
+		}
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["name"] {
+		cfg.Name = *name
+	}
+	if set["latency"] {
+		cfg.Latency = *latency
+	}
+	if set["token"] {
+		cfg.Token = *token
+	}
+	for _, tool := range tools {
+		toolName, result, _ := strings.Cut(tool, "=")
+		if toolName == "" {
+			return cfg, "", fmt.Errorf("invalid -tool %q: expected name or name=result", tool)
+		}
+		cfg.Tools = append(cfg.Tools, mockserver.Tool{Name: toolName, Result: result})
+	}
+	return cfg, *addr, nil
+}
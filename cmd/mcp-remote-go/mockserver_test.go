@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMockServerArgs(t *testing.T) {
+	cfg, addr, err := parseMockServerArgs(nil)
+	if err != nil || addr != defaultMockServerAddr || len(cfg.Tools) != 0 {
+		t.Fatalf("Unexpected defaults: %+v, %q, %v", cfg, addr, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mock.yaml")
+	if err := os.WriteFile(path, []byte("name: file\nlatency: 1s\ntools:\n  - name: slow\n    latency: 2s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, addr, err = parseMockServerArgs([]string{"-config", path, "-listen", "127.0.0.1:0", "-latency", "50ms", "-tool", "greet=hello", "-tool", "echo"})
+	if err != nil {
+		t.Fatalf("parseMockServerArgs failed: %v", err)
+	}
+	if addr != "127.0.0.1:0" || cfg.Name != "file" || cfg.Latency != 50*time.Millisecond {
+		t.Errorf("Expected flags to override the file, got %+v on %q", cfg, addr)
+	}
+	if len(cfg.Tools) != 3 || cfg.Tools[0].Latency != 2*time.Second || cfg.Tools[1].Name != "greet" || cfg.Tools[1].Result != "hello" || cfg.Tools[2].Result != "" {
+		t.Errorf("Unexpected tools %+v", cfg.Tools)
+	}
+
+	for _, args := range [][]string{{"extra"}, {"-tool", "=x"}, {"-unknown"}, {"-config", filepath.Join(t.TempDir(), "missing.yaml")}} {
+		if _, _, err := parseMockServerArgs(args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
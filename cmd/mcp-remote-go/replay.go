@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/naotama2002/mcp-remote-go/internal/trace"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+// loadRecording reads a session recorded with -record (or -trace-file) for
+// -replay. It returns the recorded messages and the server they were
+// exchanged with.
+func loadRecording(path string) ([]proxy.RecordedMessage, string, error) {
+	entries, err := trace.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var server string
+	recording := make([]proxy.RecordedMessage, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry.Message) == 0 {
+			continue
+		}
+		if server == "" {
+			server = entry.Server
+		}
+		recording = append(recording, proxy.RecordedMessage{Direction: entry.Direction, Message: entry.Message})
+	}
+	if len(recording) == 0 {
+		return nil, "", fmt.Errorf("recording %s contains no messages", path)
+	}
+	return recording, server, nil
+}
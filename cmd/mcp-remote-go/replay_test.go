@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naotama2002/mcp-remote-go/internal/trace"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+func TestLoadRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := trace.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	recorder.Trace(proxy.TraceLocalToRemote, "https://example.com/mcp", []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	recorder.Trace(proxy.TraceRemoteToLocal, "https://example.com/mcp", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recording, server, err := loadRecording(path)
+	if err != nil {
+		t.Fatalf("loadRecording failed: %v", err)
+	}
+	if server != "https://example.com/mcp" || len(recording) != 2 || recording[1].Direction != proxy.TraceRemoteToLocal {
+		t.Errorf("Unexpected recording %v from %q", recording, server)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.jsonl")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadRecording(empty); err == nil {
+		t.Error("Expected an error for an empty recording")
+	}
+}
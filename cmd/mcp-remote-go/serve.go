@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/naotama2002/mcp-remote-go/internal/logging"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+const serveUsage = `Usage: mcp-remote-go serve [-listen <addr>] [-token <token> | -token-env <VAR>] [-log-level <level>] -- <command> [args...]
+
+Runs a local stdio MCP server and exposes it over Streamable HTTP on /mcp
+and the legacy SSE transport on /sse, the reverse of the proxy. The server
+is restarted if it exits. Only pages served from this machine may call the
+endpoint; with -token (or -token-env naming a variable holding it), every
+request must also carry "Authorization: Bearer <token>".
+`
+
+// defaultServeAddr is where "serve" listens by default.
+const defaultServeAddr = "127.0.0.1:8080"
+
+// serveConfig is the configuration of "mcp-remote-go serve".
+type serveConfig struct {
+	addr     string
+	token    string
+	logLevel string
+	command  []string
+}
+
+// runServe implements "mcp-remote-go serve", which serves a local stdio
+// MCP server over HTTP until interrupted.
+func runServe(args []string, stdout io.Writer) error {
+	cfg, err := parseServeArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := logging.Setup(cfg.logLevel, logging.FormatText, nil); err != nil {
+		return err
+	}
+
+	var opts []proxy.HTTPServerOption
+	if cfg.token != "" {
+		opts = append(opts, proxy.WithBearerToken(cfg.token))
+	}
+	p, err := proxy.NewProxyWithOptions(strings.Join(cfg.command, " "), 0, nil, "", proxy.TransportModeCommand, "", proxy.WithCommand(cfg.command))
+	if err != nil {
+		return err
+	}
+	listener, err := proxy.ListenHTTP(cfg.addr)
+	if err != nil {
+		return err
+	}
+	server := proxy.NewHTTPServer(p, listener, opts...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown()
+	}()
+
+	base := "http://" + server.Addr().String()
+	_, _ = fmt.Fprintf(stdout, "Serving %s:\n  Streamable HTTP: %s%s\n  SSE:             %s%s\n", cfg.command[0], base, proxy.HTTPEndpointPath, base, proxy.SSEEndpointPath)
+	return server.Start()
+}
+
+// parseServeArgs returns the configuration given by args.
+func parseServeArgs(args []string) (serveConfig, error) {
+	flags := flag.NewFlagSet("mcp-remote-go serve", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	addr := flags.String("listen", defaultServeAddr, "Address to listen on")
+	token := flags.String("token", "", "Bearer token requests must carry")
+	tokenEnv := flags.String("token-env", "", "Environment variable holding the bearer token")
+	logLevel := flags.String("log-level", "info", "Log level: debug, info, warn, error")
+	if err := flags.Parse(args); err != nil {
+		return serveConfig{}, fmt.Errorf("%w\n\n%s", err, serveUsage)
+	}
+	if flags.NArg() == 0 {
+		return serveConfig{}, errors.New(serveUsage)
+	}
+
+	cfg := serveConfig{addr: *addr, token: *token, logLevel: *logLevel, command: flags.Args()}
+	if *tokenEnv != "" {
+		if cfg.token != "" {
+			return serveConfig{}, errors.New("-token cannot be combined with -token-env")
+		}
+		cfg.token = os.Getenv(*tokenEnv)
+		if cfg.token == "" {
+			return serveConfig{}, fmt.Errorf("environment variable %s is empty or not set", *tokenEnv)
+		}
+	}
+	return cfg, nil
+}
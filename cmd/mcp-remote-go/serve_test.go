@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseServeArgs(t *testing.T) {
+	cfg, err := parseServeArgs([]string{"--", "server", "-v"})
+	if err != nil || cfg.addr != defaultServeAddr || cfg.token != "" || strings.Join(cfg.command, " ") != "server -v" {
+		t.Fatalf("Unexpected defaults: %+v, %v", cfg, err)
+	}
+
+	t.Setenv("SERVE_TEST_TOKEN", "from-env")
+	cfg, err = parseServeArgs([]string{"-listen", ":0", "-token-env", "SERVE_TEST_TOKEN", "server"})
+	if err != nil {
+		t.Fatalf("parseServeArgs failed: %v", err)
+	}
+	if cfg.addr != ":0" || cfg.token != "from-env" {
+		t.Errorf("Expected the token from the environment, got %+v", cfg)
+	}
+
+	for _, args := range [][]string{nil, {"-token", "x"}, {"-token", "x", "-token-env", "SERVE_TEST_TOKEN", "server"}, {"-token-env", "SERVE_TEST_UNSET", "server"}, {"-unknown", "server"}} {
+		if _, err := parseServeArgs(args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package backoff computes the delays between reconnection attempts:
+// exponentially growing, capped and jittered so that many clients dropped by
+// the same server outage do not reconnect in lockstep.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults used for zero Policy fields.
+const (
+	DefaultInitial     = time.Second
+	DefaultMultiplier  = 2.0
+	DefaultMaxWait     = 30 * time.Second
+	DefaultMaxAttempts = 5
+)
+
+// Policy describes how reconnection attempts are spaced and when to give
+// up. Zero fields take the defaults above.
+type Policy struct {
+	// Initial is the delay before the first attempt.
+	Initial time.Duration
+	// Multiplier grows the delay after each attempt.
+	Multiplier float64
+	// MaxWait caps the delay between attempts.
+	MaxWait time.Duration
+	// MaxAttempts is the number of consecutive attempts before giving up. A
+	// negative value never gives up.
+	MaxAttempts int
+}
+
+// Default returns the policy with every field set to its default.
+func Default() Policy {
+	return Policy{}.withDefaults()
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.Initial <= 0 {
+		p.Initial = DefaultInitial
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+	if p.MaxWait <= 0 {
+		p.MaxWait = DefaultMaxWait
+	}
+	if p.MaxWait < p.Initial {
+		p.MaxWait = p.Initial
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	return p
+}
+
+// Attempts returns the effective MaxAttempts, or -1 without a limit.
+func (p Policy) Attempts() int {
+	if n := p.withDefaults().MaxAttempts; n > 0 {
+		return n
+	}
+	return -1
+}
+
+// Backoff tracks the attempts made under a Policy. It is not safe for
+// concurrent use.
+type Backoff struct {
+	policy  Policy
+	attempt int
+	delay   time.Duration
+}
+
+// New returns a Backoff starting at the first attempt of p.
+func (p Policy) New() *Backoff {
+	b := &Backoff{policy: p.withDefaults()}
+	b.Reset()
+	return b
+}
+
+// Next returns the delay to wait before the next attempt: a random duration
+// between half and all of the current delay. ok is false once the policy's
+// attempts are used up.
+func (b *Backoff) Next() (d time.Duration, ok bool) {
+	if b.policy.MaxAttempts > 0 && b.attempt >= b.policy.MaxAttempts {
+		return 0, false
+	}
+	b.attempt++
+
+	d = b.delay
+	if grown := time.Duration(float64(b.delay) * b.policy.Multiplier); grown > b.delay && grown <= b.policy.MaxWait {
+		b.delay = grown
+	} else {
+		b.delay = b.policy.MaxWait
+	}
+
+	half := d / 2
+	return half + rand.N(d-half+1), true
+}
+
+// Attempt returns the number of the attempt the last Next was for, starting
+// at 1.
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Reset starts over from the first attempt, e.g. after a connection was
+// established.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+	b.delay = b.policy.Initial
+}
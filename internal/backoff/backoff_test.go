@@ -0,0 +1,63 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsWithJitter(t *testing.T) {
+	b := Policy{Initial: time.Second, MaxWait: 8 * time.Second, MaxAttempts: -1}.New()
+
+	for i, ceiling := range []time.Duration{1, 2, 4, 8, 8, 8} {
+		ceiling *= time.Second
+		d, ok := b.Next()
+		if !ok {
+			t.Fatalf("Attempt %d: expected no limit", i+1)
+		}
+		if d < ceiling/2 || d > ceiling {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", i+1, ceiling/2, ceiling, d)
+		}
+	}
+}
+
+func TestBackoffMultiplier(t *testing.T) {
+	b := Policy{Initial: 100 * time.Millisecond, Multiplier: 3, MaxWait: time.Second}.New()
+
+	for i, ceiling := range []time.Duration{100, 300, 900, 1000} {
+		ceiling *= time.Millisecond
+		if d, _ := b.Next(); d < ceiling/2 || d > ceiling {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", i+1, ceiling/2, ceiling, d)
+		}
+	}
+}
+
+func TestBackoffMaxAttempts(t *testing.T) {
+	b := Policy{Initial: time.Millisecond, MaxAttempts: 2}.New()
+
+	for i := 1; i <= 2; i++ {
+		if _, ok := b.Next(); !ok || b.Attempt() != i {
+			t.Fatalf("Expected attempt %d, got %d (ok %v)", i, b.Attempt(), ok)
+		}
+	}
+	if _, ok := b.Next(); ok {
+		t.Error("Expected attempts to be used up")
+	}
+
+	b.Reset()
+	if d, ok := b.Next(); !ok || d > time.Millisecond {
+		t.Errorf("Expected Reset to start over, got %v (ok %v)", d, ok)
+	}
+}
+
+func TestPolicyDefaults(t *testing.T) {
+	p := Default()
+	if p.Initial != DefaultInitial || p.Multiplier != DefaultMultiplier || p.MaxWait != DefaultMaxWait || p.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("Unexpected defaults %+v", p)
+	}
+	if n := (Policy{}).Attempts(); n != DefaultMaxAttempts {
+		t.Errorf("Expected %d attempts by default, got %d", DefaultMaxAttempts, n)
+	}
+	if n := (Policy{MaxAttempts: -1}).Attempts(); n != -1 {
+		t.Errorf("Expected no limit, got %d", n)
+	}
+}
@@ -0,0 +1,164 @@
+// Package browser opens authorization URLs for the user. A configured
+// command, such as "firefox --private-window %s", or the BROWSER environment
+// variable takes precedence over the system's default browser.
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/browser"
+)
+
+// EnvBrowser names the environment variable holding browser commands,
+// separated by the OS path list separator and tried in order.
+const EnvBrowser = "BROWSER"
+
+func init() {
+	// Stdout carries the MCP protocol, so whatever the opener prints goes
+	// to stderr.
+	browser.Stdout = os.Stderr
+}
+
+// Launcher opens URLs with a command, or with the system's default browser
+// when there is none.
+type Launcher struct {
+	commands []string
+}
+
+// New returns a Launcher running command, or the commands in BROWSER when
+// command is empty. A command's "%s" is replaced by the URL; without one
+// the URL is appended as the last argument. Commands are checked with
+// ValidateCommand beforehand.
+func New(command string) *Launcher {
+	if command != "" {
+		return &Launcher{commands: []string{command}}
+	}
+	var commands []string
+	for _, c := range strings.Split(os.Getenv(EnvBrowser), string(os.PathListSeparator)) {
+		if strings.TrimSpace(c) != "" {
+			commands = append(commands, c)
+		}
+	}
+	return &Launcher{commands: commands}
+}
+
+// ValidateCommand reports whether command is a valid browser command: a
+// program with optional arguments, quoted with ' or " where they contain
+// spaces.
+func ValidateCommand(command string) error {
+	args, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("empty browser command")
+	}
+	return nil
+}
+
+// Open opens rawURL, trying each command in turn and the default browser
+// when none is configured. Only http and https URLs are opened.
+func (l *Launcher) Open(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return errors.New("only http and https URLs are allowed")
+	}
+
+	if len(l.commands) == 0 {
+		return browser.OpenURL(rawURL)
+	}
+	for _, command := range l.commands {
+		if err = run(command, rawURL); err == nil {
+			return nil
+		}
+		slog.Debug("browser command failed", "command", command, "error", err)
+	}
+	return err
+}
+
+// run starts command for rawURL without waiting for the browser to exit.
+func run(command, rawURL string) error {
+	args, err := commandArgs(command, rawURL)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run browser command %q: %w", args[0], err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// commandArgs returns the arguments running command for rawURL. The URL
+// replaces "%s" within an argument, so it is never split or interpreted
+// by a shell.
+func commandArgs(command, rawURL string) ([]string, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty browser command")
+	}
+	substituted := false
+	for i, arg := range args[1:] {
+		if strings.Contains(arg, "%s") {
+			args[i+1] = strings.ReplaceAll(arg, "%s", rawURL)
+			substituted = true
+		}
+	}
+	if !substituted {
+		args = append(args, rawURL)
+	}
+	return args, nil
+}
+
+// splitCommand splits command into arguments at unquoted spaces. Single
+// and double quotes group words and are removed.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in browser command %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
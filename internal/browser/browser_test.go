@@ -0,0 +1,91 @@
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandArgs(t *testing.T) {
+	const authURL = "https://as.example.com/authorize?a=1&b=two words"
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"firefox --private-window %s", []string{"firefox", "--private-window", authURL}},
+		{"open -a 'Google Chrome'", []string{"open", "-a", "Google Chrome", authURL}},
+		{`"/opt/My Browser/browser" --url=%s`, []string{"/opt/My Browser/browser", "--url=" + authURL}},
+		// The program itself is never substituted.
+		{"%s", []string{"%s", authURL}},
+	}
+	for _, tt := range tests {
+		got, err := commandArgs(tt.command, authURL)
+		if err != nil {
+			t.Errorf("%q: %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	if err := ValidateCommand("firefox %s"); err != nil {
+		t.Errorf("Expected a valid command, got %v", err)
+	}
+	for _, command := range []string{"", "  ", "firefox 'unterminated"} {
+		if err := ValidateCommand(command); err == nil {
+			t.Errorf("Expected %q to be rejected", command)
+		}
+	}
+}
+
+func TestNewUsesBrowserEnv(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	t.Setenv(EnvBrowser, "missing-browser"+sep+sep+"firefox %s")
+	if got := New("").commands; !reflect.DeepEqual(got, []string{"missing-browser", "firefox %s"}) {
+		t.Errorf("Expected the BROWSER commands, got %q", got)
+	}
+	if got := New("chromium").commands; !reflect.DeepEqual(got, []string{"chromium"}) {
+		t.Errorf("Expected the configured command to win, got %q", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as browser")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "url")
+	script := filepath.Join(dir, "browser.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s' \"$2\" > \""+out+"\"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// A command that cannot start falls through to the next one.
+	launcher := &Launcher{commands: []string{filepath.Join(dir, "missing"), script + " --new-tab %s"}}
+	const authURL = "https://as.example.com/authorize?state=x y"
+	if err := launcher.Open(authURL); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil && string(data) == authURL {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the browser to receive the URL, got %q (%v)", data, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := launcher.Open("file:///etc/passwd"); err == nil || !strings.Contains(err.Error(), "http") {
+		t.Errorf("Expected a non-http URL to be refused, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 )
 
@@ -25,6 +29,29 @@ const (
 	TimeoutError ErrorType = "timeout_error"
 )
 
+// JSON-RPC error codes reported for each ErrorType, from the range JSON-RPC
+// 2.0 reserves for implementation-defined server errors. Timeouts share
+// -32001 with requests the proxy times out itself.
+const (
+	CodeTimeout        = -32001
+	CodeAuthentication = -32010
+	CodeAuthorization  = -32011
+	CodeNetwork        = -32012
+	CodeConfiguration  = -32013
+	CodeValidation     = -32014
+	CodeServer         = -32015
+)
+
+var jsonRPCCodes = map[ErrorType]int{
+	TimeoutError:        CodeTimeout,
+	AuthenticationError: CodeAuthentication,
+	AuthorizationError:  CodeAuthorization,
+	NetworkError:        CodeNetwork,
+	ConfigurationError:  CodeConfiguration,
+	ValidationError:     CodeValidation,
+	ServerError:         CodeServer,
+}
+
 // AppError represents a structured application error
 type AppError struct {
 	Type       ErrorType `json:"type"`
@@ -76,6 +103,14 @@ func (e *AppError) WithStatusCode(code int) *AppError {
 	return e
 }
 
+// JSONRPCCode returns the JSON-RPC error code for the error's type.
+func (e *AppError) JSONRPCCode() int {
+	if code, ok := jsonRPCCodes[e.Type]; ok {
+		return code
+	}
+	return CodeServer
+}
+
 // IsType checks if an error is of a specific type
 func IsType(err error, errorType ErrorType) bool {
 	var appErr *AppError
@@ -166,3 +201,26 @@ func FromHTTPStatus(statusCode int, message string) *AppError {
 
 	return New(errorType, message).WithStatusCode(statusCode)
 }
+
+// Classify returns the AppError in err's chain or, when there is none, wraps
+// err in an AppError of the type it suggests: a timeout, a network failure
+// or otherwise a server error. It returns nil for a nil error.
+func Classify(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+	var appErr *AppError
+	if As(err, &appErr) {
+		return appErr
+	}
+
+	var netErr net.Error
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded) || (stderrors.As(err, &netErr) && netErr.Timeout()):
+		return Wrap(err, TimeoutError, err.Error()).WithStatusCode(http.StatusGatewayTimeout)
+	case netErr != nil || stderrors.Is(err, io.EOF) || stderrors.Is(err, io.ErrUnexpectedEOF):
+		return Wrap(err, NetworkError, err.Error()).WithStatusCode(http.StatusServiceUnavailable)
+	default:
+		return Wrap(err, ServerError, err.Error()).WithStatusCode(http.StatusBadGateway)
+	}
+}
@@ -1,8 +1,12 @@
 package errors
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"syscall"
 	"testing"
 )
 
@@ -260,3 +264,38 @@ func TestErrorChaining(t *testing.T) {
 		t.Errorf("Expected final error type %s, got %s", AuthenticationError, finalErr.Type)
 	}
 }
+
+func TestClassify(t *testing.T) {
+	appErr := NewAuthorizationError("denied")
+	tests := []struct {
+		name         string
+		err          error
+		expectedType ErrorType
+		expectedCode int
+	}{
+		{"app error in chain", fmt.Errorf("connect: %w", appErr), AuthorizationError, CodeAuthorization},
+		{"deadline", fmt.Errorf("request: %w", context.DeadlineExceeded), TimeoutError, CodeTimeout},
+		{"dial", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, NetworkError, CodeNetwork},
+		{"connection closed", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), NetworkError, CodeNetwork},
+		{"other", fmt.Errorf("server returned error status: 500"), ServerError, CodeServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.err)
+			if got.Type != tt.expectedType {
+				t.Errorf("Expected type %s, got %s", tt.expectedType, got.Type)
+			}
+			if got.JSONRPCCode() != tt.expectedCode {
+				t.Errorf("Expected code %d, got %d", tt.expectedCode, got.JSONRPCCode())
+			}
+			if got != appErr && got.Unwrap() != tt.err {
+				t.Error("Expected the error to be kept as the cause")
+			}
+		})
+	}
+
+	if Classify(nil) != nil {
+		t.Error("Expected nil for a nil error")
+	}
+}
@@ -1,12 +1,31 @@
+// Package filelock guards files shared by several proxy processes, such as
+// the credentials written when an MCP client starts many proxies at once.
 package filelock
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultTimeout is how long ReadFile, WriteFile and Remove wait for
+	// another process to release a file.
+	DefaultTimeout = 5 * time.Second
+
+	// staleAfter is the age at which a lock file is assumed to be left
+	// behind by a process that died while holding it, in case its PID was
+	// reused. Locks are held for a single read or write, or at most for a
+	// token refresh request, so a live holder never gets close.
+	staleAfter = 2 * time.Minute
+)
+
 // FileLock provides file-based locking to prevent concurrent access
 type FileLock struct {
 	path     string
@@ -35,6 +54,8 @@ func (fl *FileLock) Lock(timeout time.Duration) error {
 	for time.Now().Before(deadline) {
 		file, err := os.OpenFile(fl.path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
 		if err == nil {
+			// Record the holder so others can tell when it has died.
+			_, _ = file.WriteString(strconv.Itoa(os.Getpid()))
 			fl.file = file
 			fl.acquired = true
 			return nil
@@ -42,6 +63,7 @@ func (fl *FileLock) Lock(timeout time.Duration) error {
 
 		// If file exists, wait and retry
 		if os.IsExist(err) {
+			fl.removeIfStale()
 			time.Sleep(10 * time.Millisecond)
 			continue
 		}
@@ -53,6 +75,27 @@ func (fl *FileLock) Lock(timeout time.Duration) error {
 	return fmt.Errorf("timeout acquiring lock after %v", timeout)
 }
 
+// removeIfStale removes the lock file if its holder has died: its recorded
+// process is gone, or the file is older than staleAfter.
+func (fl *FileLock) removeIfStale() {
+	info, err := os.Stat(fl.path)
+	if err != nil {
+		return
+	}
+	if time.Since(info.ModTime()) > staleAfter {
+		_ = os.Remove(fl.path)
+		return
+	}
+	data, err := os.ReadFile(fl.path)
+	if err != nil {
+		return
+	}
+	// An empty file is a lock whose holder has not written its PID yet.
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 && !processAlive(pid) {
+		_ = os.Remove(fl.path)
+	}
+}
+
 // Unlock releases the file lock
 func (fl *FileLock) Unlock() error {
 	fl.mu.Lock()
@@ -88,3 +131,66 @@ func (fl *FileLock) WithLock(timeout time.Duration, fn func() error) error {
 
 	return fn()
 }
+
+// ReadFile reads the file at path while holding its lock.
+func ReadFile(path string) ([]byte, error) {
+	var data []byte
+	err := New(path).WithLock(DefaultTimeout, func() error {
+		var err error
+		data, err = os.ReadFile(path)
+		return err
+	})
+	return data, err
+}
+
+// WriteFile replaces the file at path with data while holding its lock,
+// creating the parent directory if needed.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return New(path).WithLock(DefaultTimeout, func() error {
+		return WriteFileAtomic(path, data, perm)
+	})
+}
+
+// Remove removes the file at path while holding its lock. A missing file is
+// not an error.
+func Remove(path string) error {
+	return New(path).WithLock(DefaultTimeout, func() error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers see either the old or the new content and never
+// a partial write. The caller holds the lock if one is needed.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package filelock
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "tokens.json")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte('a' + i)}, 64<<10)
+			if err := WriteFile(path, data, 0600); err != nil {
+				t.Errorf("WriteFile failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != 64<<10 || !bytes.Equal(data, bytes.Repeat(data[:1], len(data))) {
+		t.Errorf("Expected the content of a single writer, got %d mixed bytes", len(data))
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected no temporary or lock files left, got %q", names)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := ReadFile(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+	if err := Remove(path); err != nil {
+		t.Errorf("Expected removing a missing file to succeed, got %v", err)
+	}
+}
+
+func TestLockRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path+".lock", nil, 0600); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+
+	// A fresh lock is respected.
+	if err := New(path).Lock(50 * time.Millisecond); err == nil {
+		t.Fatal("Expected a held lock to time out")
+	}
+
+	// One left behind by a dead process is not.
+	old := time.Now().Add(-2 * staleAfter)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	lock := New(path)
+	if err := lock.Lock(time.Second); err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got %v", err)
+	}
+	_ = lock.Unlock()
+}
+
+func TestLockRemovesLockOfDeadProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	// Held by this process, which is alive.
+	if err := os.WriteFile(path+".lock", []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+	if err := New(path).Lock(50 * time.Millisecond); err == nil {
+		t.Fatal("Expected a lock held by a live process to time out")
+	}
+
+	// Held by a PID above any system's limit, so no such process exists.
+	if err := os.WriteFile(path+".lock", []byte("2147483647"), 0600); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+	lock := New(path)
+	if err := lock.Lock(time.Second); err != nil {
+		t.Fatalf("Expected the lock of a dead process to be taken over, got %v", err)
+	}
+	_ = lock.Unlock()
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package filelock
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID exists. Signal
+// 0 checks for the process without disturbing it; EPERM means it exists but
+// belongs to another user.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,177 @@
+// Package headervalue expands templated HTTP header values. A value may refer
+// to environment variables as ${NAME} and to the output of shell commands as
+// $(command), so short-lived credentials can be fetched when the proxy
+// starts and again as they expire.
+package headervalue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRefresh is how often values with command substitutions are
+// evaluated again.
+const DefaultRefresh = 5 * time.Minute
+
+// commandTimeout bounds how long a substituted command may run.
+const commandTimeout = 30 * time.Second
+
+// Expand returns value with every ${NAME} replaced by the environment
+// variable NAME and every $(command) by the command's output, trailing
+// newlines removed. "$$" stands for a literal "$". An unset variable
+// expands to "", a failing command is an error.
+func Expand(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '$' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+		switch value[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				return "", errors.New("unterminated ${ in header value")
+			}
+			b.WriteString(os.Getenv(value[i+2 : i+2+end]))
+			i += 2 + end
+		case '(':
+			end := closingParen(value, i+2)
+			if end < 0 {
+				return "", errors.New("unterminated $( in header value")
+			}
+			out, err := runCommand(value[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(out)
+			i = end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// HasCommand reports whether value contains a $(command) substitution.
+func HasCommand(value string) bool {
+	return strings.Contains(strings.ReplaceAll(value, "$$", ""), "$(")
+}
+
+// closingParen returns the index of the parenthesis closing the one
+// before start, or -1.
+func closingParen(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// runCommand runs command with the platform shell and returns its output.
+func runCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("header command %q failed: %w: %s", command, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("header command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// Provider holds a set of templated headers and their expanded values.
+// Values with command substitutions are evaluated again once they are
+// older than the refresh interval. It is safe for concurrent use.
+type Provider struct {
+	templates map[string]string
+	refresh   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	values   map[string]string
+	expanded time.Time
+	dynamic  bool
+}
+
+// NewProvider expands headers once and returns a provider for them. A
+// refresh of zero or less never evaluates the values again.
+func NewProvider(headers map[string]string, refresh time.Duration) (*Provider, error) {
+	p := &Provider{templates: headers, refresh: refresh, now: time.Now}
+	for _, v := range headers {
+		if HasCommand(v) {
+			p.dynamic = true
+		}
+	}
+	values, err := p.expand()
+	if err != nil {
+		return nil, err
+	}
+	p.values = values
+	p.expanded = p.now()
+	return p, nil
+}
+
+func (p *Provider) expand() (map[string]string, error) {
+	values := make(map[string]string, len(p.templates))
+	for name, template := range p.templates {
+		v, err := Expand(template)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// Get returns the current header values. The returned map must not be
+// modified. If evaluating them again fails, the previous values are kept.
+func (p *Provider) Get() map[string]string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dynamic && p.refresh > 0 && p.now().Sub(p.expanded) >= p.refresh {
+		p.expanded = p.now()
+		values, err := p.expand()
+		if err != nil {
+			slog.Warn("failed to refresh headers, keeping the previous values", "error", err)
+		} else {
+			p.values = values
+		}
+	}
+	return p.values
+}
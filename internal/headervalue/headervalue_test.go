@@ -0,0 +1,93 @@
+package headervalue
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("HEADERVALUE_TOKEN", "secret")
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Bearer abc", "Bearer abc"},
+		{"Bearer ${HEADERVALUE_TOKEN}", "Bearer secret"},
+		{"${HEADERVALUE_UNSET}", ""},
+		{"$$HOME costs $5", "$HOME costs $5"},
+		{"trailing $", "trailing $"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"${HEADERVALUE_TOKEN", "$(echo"} {
+		if _, err := Expand(value); err == nil || !strings.Contains(err.Error(), "unterminated") {
+			t.Errorf("Expand(%q): expected an unterminated error, got %v", value, err)
+		}
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	got, err := Expand("Bearer $(echo $(printf tok)en)")
+	if err != nil || got != "Bearer token" {
+		t.Errorf("Expected the command output, got %q, %v", got, err)
+	}
+	if _, err := Expand("$(echo oops >&2; exit 3)"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("Expected the command's error output, got %v", err)
+	}
+	if !HasCommand("Bearer $(cat token)") || HasCommand("Bearer $${x}") || HasCommand("$$(not a command)") {
+		t.Error("HasCommand misreported a value")
+	}
+}
+
+func TestProviderRefresh(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	dir := t.TempDir()
+	write := func(s string) {
+		if err := os.WriteFile(filepath.Join(dir, "token"), []byte(s+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("one")
+
+	p, err := NewProvider(map[string]string{"Authorization": "Bearer $(cat " + dir + "/token)", "X-Static": "v"}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	write("two")
+	if got := p.Get()["Authorization"]; got != "Bearer one" {
+		t.Errorf("Expected the value kept until the refresh interval, got %q", got)
+	}
+	now = now.Add(time.Minute)
+	if got := p.Get()["Authorization"]; got != "Bearer two" {
+		t.Errorf("Expected the value evaluated again, got %q", got)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "token")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if got := p.Get()["Authorization"]; got != "Bearer two" {
+		t.Errorf("Expected the previous value kept after a failure, got %q", got)
+	}
+
+	if _, err := NewProvider(map[string]string{"X-Bad": "$(exit 1)"}, 0); err == nil {
+		t.Error("Expected NewProvider to fail when a command fails")
+	}
+}
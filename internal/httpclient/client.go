@@ -17,6 +17,10 @@ type Config struct {
 	MaxRetries     int
 	RetryDelay     time.Duration
 	DefaultHeaders map[string]string
+
+	// Transport is used for requests when set; otherwise
+	// http.DefaultTransport, which honors the proxy environment variables.
+	Transport http.RoundTripper
 }
 
 // DefaultConfig returns a default HTTP client configuration
@@ -43,7 +47,8 @@ func New(config *Config) *Client {
 
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: config.Timeout,
+			Timeout:   config.Timeout,
+			Transport: config.Transport,
 		},
 		config: config,
 	}
@@ -84,17 +89,24 @@ func (r *Response) String() string {
 	return string(r.BodyBytes)
 }
 
-// Do performs an HTTP request with retries and proper error handling
+// Do performs an HTTP request with retries and proper error handling.
+// Idempotent requests are retried after network errors and 5xx statuses;
+// others, such as a POST exchanging an authorization code, are sent once,
+// as the server may have acted on them. A 429, or a 503 with Retry-After,
+// was turned away unprocessed and is retried whatever the method, after
+// the delay the server asked for when that is longer than RetryDelay.
 func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
 	var lastErr error
+	delay := c.config.RetryDelay
 
 	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
 		if attempt > 0 {
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
-			case <-time.After(c.config.RetryDelay):
+			case <-time.After(delay):
 			}
+			delay = c.config.RetryDelay
 		}
 
 		resp, err := c.doSingle(ctx, req)
@@ -109,14 +121,32 @@ func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
 			return nil, ctx.Err()
 		}
 
-		if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return resp, err
+		if resp != nil {
+			if retryAfter, limited := rateLimitDelay(resp.Response); limited {
+				delay = max(delay, retryAfter)
+				continue
+			}
+			if resp.StatusCode < 500 || !isIdempotent(req.Method) {
+				return resp, err
+			}
+		} else if !isIdempotent(req.Method) {
+			return nil, err
 		}
 	}
 
 	return nil, fmt.Errorf("request failed after %d attempts: %w", c.config.MaxRetries+1, lastErr)
 }
 
+// isIdempotent reports whether a request with method may be sent again
+// without changing its effect (RFC 9110, section 9.2.2).
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
 // doSingle performs a single HTTP request
 func (c *Client) doSingle(ctx context.Context, req *Request) (*Response, error) {
 	// Prepare request body
@@ -217,22 +247,19 @@ func (c *Client) Post(ctx context.Context, url string, body interface{}, headers
 	})
 }
 
-// PostForm performs a POST request with form data
-func (c *Client) PostForm(ctx context.Context, url string, formData map[string]string, headers map[string]string) (*Response, error) {
+// PostForm performs a POST request with form data, URL-encoded so that
+// values may contain '&', '+' or '='. A key with several values is sent
+// once per value.
+func (c *Client) PostForm(ctx context.Context, url string, formData neturl.Values, headers map[string]string) (*Response, error) {
 	if headers == nil {
 		headers = make(map[string]string)
 	}
 	headers["Content-Type"] = "application/x-www-form-urlencoded"
 
-	values := make(neturl.Values, len(formData))
-	for key, value := range formData {
-		values.Set(key, value)
-	}
-
 	return c.Do(ctx, &Request{
 		Method:  http.MethodPost,
 		URL:     url,
 		Headers: headers,
-		Body:    values.Encode(),
+		Body:    formData.Encode(),
 	})
 }
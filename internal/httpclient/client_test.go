@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -133,6 +134,9 @@ func TestPostForm(t *testing.T) {
 		if r.Form.Get("username") != "testuser" {
 			t.Errorf("Expected username 'testuser', got %s", r.Form.Get("username"))
 		}
+		if scopes := r.Form["scope"]; len(scopes) != 2 || scopes[0] != "read" || scopes[1] != "write" {
+			t.Errorf("Expected both scope values, got %v", scopes)
+		}
 
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("form received"))
@@ -140,9 +144,10 @@ func TestPostForm(t *testing.T) {
 	defer server.Close()
 
 	client := New(nil)
-	formData := map[string]string{
-		"username": "testuser",
-		"password": "testpass",
+	formData := url.Values{
+		"username": {"testuser"},
+		"password": {"testpass"},
+		"scope":    {"read", "write"},
 	}
 
 	resp, err := client.PostForm(context.Background(), server.URL, formData, nil)
@@ -171,6 +176,7 @@ func TestPostFormEncodesSpecialCharacters(t *testing.T) {
 
 	var (
 		gotResource string
+		gotSecret   string
 		gotOther    string
 	)
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -178,14 +184,16 @@ func TestPostFormEncodesSpecialCharacters(t *testing.T) {
 			t.Fatalf("ParseForm failed: %v", err)
 		}
 		gotResource = r.Form.Get("resource")
+		gotSecret = r.Form.Get("client_secret")
 		gotOther = r.Form.Get("other")
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
 
-	resp, err := New(nil).PostForm(context.Background(), server.URL, map[string]string{
-		"resource": tricky,
-		"other":    "plain",
+	resp, err := New(nil).PostForm(context.Background(), server.URL, url.Values{
+		"resource":      {tricky},
+		"client_secret": {"a+b=c&d"},
+		"other":         {"plain"},
 	}, nil)
 	if err != nil {
 		t.Fatalf("PostForm failed: %v", err)
@@ -195,6 +203,9 @@ func TestPostFormEncodesSpecialCharacters(t *testing.T) {
 	if gotResource != tricky {
 		t.Errorf("resource decoded as %q, want %q", gotResource, tricky)
 	}
+	if gotSecret != "a+b=c&d" {
+		t.Errorf("client_secret decoded as %q, want %q", gotSecret, "a+b=c&d")
+	}
 	if gotOther != "plain" {
 		t.Errorf("other = %q, want %q", gotOther, "plain")
 	}
@@ -260,6 +271,33 @@ func TestRetryLogic(t *testing.T) {
 	}
 }
 
+func TestNonIdempotentNotRetried(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := New(&Config{Timeout: 5 * time.Second, MaxRetries: 3, RetryDelay: time.Millisecond})
+	resp, err := client.PostForm(context.Background(), server.URL, url.Values{"grant_type": {"authorization_code"}}, nil)
+	if err == nil {
+		t.Fatal("Expected an error for a 502 response")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the 502 response, got %v", resp)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a POST to be sent once, got %d attempts", attempts)
+	}
+
+	// A POST that may not have reached the server is not resent either.
+	server.Close()
+	if _, err := client.Post(context.Background(), server.URL, map[string]string{}, nil); err == nil || strings.Contains(err.Error(), "attempts") {
+		t.Errorf("Expected the network error without retries, got %v", err)
+	}
+}
+
 func TestTimeoutHandling(t *testing.T) {
 	// Server with delay
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
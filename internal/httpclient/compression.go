@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AcceptEncoding is the Accept-Encoding header sent by CompressionTransport.
+const AcceptEncoding = "gzip, deflate"
+
+// CompressionTransport asks servers for gzip or deflate compressed
+// responses and decompresses them, including streamed SSE bodies. Unlike
+// the compression built into http.Transport it also accepts deflate.
+// Requests that set Accept-Encoding themselves are passed through as is.
+type CompressionTransport struct {
+	// Base performs the requests; http.DefaultTransport when nil.
+	Base http.RoundTripper
+}
+
+// NewCompressionTransport returns a CompressionTransport over base.
+func NewCompressionTransport(base http.RoundTripper) *CompressionTransport {
+	return &CompressionTransport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *CompressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "deflate" {
+		return resp, nil
+	}
+	resp.Body = &decompressingBody{body: resp.Body, encoding: encoding}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressingBody decodes a compressed response body. The decoder is
+// created on the first Read, so that a streamed body is not read before the
+// caller asks for it.
+type decompressingBody struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	err      error
+}
+
+func (b *decompressingBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.err = newDecompressor(b.encoding, b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decompressingBody) Close() error {
+	if c, ok := b.reader.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return b.body.Close()
+}
+
+// newDecompressor returns a reader decoding r. "deflate" is zlib data as
+// the HTTP specification says, but raw deflate data sent by some servers is
+// accepted too.
+func newDecompressor(encoding string, r io.Reader) (io.Reader, error) {
+	if encoding == "gzip" {
+		return gzip.NewReader(r)
+	}
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && len(header) < 2 {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if isZlibHeader(header[0], header[1]) {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// isZlibHeader reports whether cmf and flg start a zlib stream using the
+// deflate method.
+func isZlibHeader(cmf, flg byte) bool {
+	return cmf&0x0f == 8 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
@@ -0,0 +1,124 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func compress(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	_, _ = io.WriteString(w, body)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func TestCompressionTransport(t *testing.T) {
+	const body = `{"jsonrpc":"2.0","id":1,"result":{"contents":[{"text":"a large resource"}]}}`
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "identity"} {
+		t.Run(encoding, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != AcceptEncoding {
+					t.Errorf("Expected Accept-Encoding %q, got %q", AcceptEncoding, got)
+				}
+				if encoding == "identity" {
+					_, _ = io.WriteString(w, body)
+					return
+				}
+				w.Header().Set("Content-Encoding", strings.TrimPrefix(encoding, "raw-"))
+				_, _ = w.Write(compress(t, encoding, body))
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: NewCompressionTransport(nil)}
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+			if string(got) != body {
+				t.Errorf("Expected the decompressed body, got %q", got)
+			}
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Error("Expected Content-Encoding removed from the decompressed response")
+			}
+		})
+	}
+}
+
+func TestCompressionTransportStreams(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = io.WriteString(gz, "data: first\n\n")
+		_ = gz.Flush()
+		w.(http.Flusher).Flush()
+		<-release
+		_ = gz.Close()
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: NewCompressionTransport(nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		lines <- line
+	}()
+	select {
+	case line := <-lines:
+		if line != "data: first\n" {
+			t.Errorf("Expected the first event, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the event before the stream ends")
+	}
+}
+
+func TestCompressionTransportKeepsAcceptEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Accept-Encoding"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := (&http.Client{Transport: NewCompressionTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if got, _ := io.ReadAll(resp.Body); string(got) != "identity" {
+		t.Errorf("Expected the request's Accept-Encoding kept, got %q", got)
+	}
+}
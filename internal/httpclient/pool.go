@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Defaults used for zero PoolOptions fields.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// tlsSessionCacheSize is how many TLS sessions are kept for resumption.
+const tlsSessionCacheSize = 64
+
+// PoolOptions tunes how connections to servers are kept and reused. Zero
+// fields take the defaults above.
+type PoolOptions struct {
+	// MaxIdleConns caps the idle connections kept across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the idle connections kept per host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps the connections per host, including those in
+	// use. Zero means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept.
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 restricts connections to HTTP/1.1.
+	DisableHTTP2 bool
+}
+
+// NewTransport returns the transport a proxy uses for all its requests:
+// routed through proxyURL as with NewProxyTransport, using tlsConfig when
+// not nil, with connections pooled as pool describes and TLS sessions
+// resumed across connections.
+func NewTransport(proxyURL string, tlsConfig *tls.Config, pool PoolOptions) (*http.Transport, error) {
+	transport, err := NewProxyTransport(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(tlsSessionCacheSize)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	pool.apply(transport)
+	return transport, nil
+}
+
+// apply sets the pooling fields of transport.
+func (o PoolOptions) apply(transport *http.Transport) {
+	transport.MaxIdleConns = o.MaxIdleConns
+	if transport.MaxIdleConns <= 0 {
+		transport.MaxIdleConns = DefaultMaxIdleConns
+	}
+	transport.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = o.IdleConnTimeout
+	if transport.IdleConnTimeout <= 0 {
+		transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if o.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = o.MaxConnsPerHost
+	}
+
+	if o.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto turns off HTTP/2.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		transport.ForceAttemptHTTP2 = true
+	}
+}
@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestNewTransportDefaults(t *testing.T) {
+	transport, err := NewTransport("", nil, PoolOptions{})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if transport.MaxIdleConns != DefaultMaxIdleConns || transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || transport.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Errorf("Expected the default pool settings, got %d, %d, %v", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("Expected HTTP/2 enabled")
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.ClientSessionCache == nil {
+		t.Error("Expected a TLS session cache for resumption")
+	}
+}
+
+func TestNewTransportTuned(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "mcp.example.com"}
+	transport, err := NewTransport("http://proxy.example.com:8080", tlsConfig, PoolOptions{
+		MaxIdleConns:        20,
+		MaxIdleConnsPerHost: 4,
+		MaxConnsPerHost:     8,
+		IdleConnTimeout:     time.Minute,
+		DisableHTTP2:        true,
+	})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	if transport.MaxIdleConns != 20 || transport.MaxIdleConnsPerHost != 4 || transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("Expected the configured pool settings, got %d, %d, %d, %v", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost, transport.IdleConnTimeout)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("Expected HTTP/2 disabled")
+	}
+	if transport.TLSClientConfig.ServerName != "mcp.example.com" {
+		t.Error("Expected the given TLS settings kept")
+	}
+	if tlsConfig.ClientSessionCache != nil {
+		t.Error("Expected the caller's TLS config left unchanged")
+	}
+}
+
+func TestNewTransportInvalidProxy(t *testing.T) {
+	if _, err := NewTransport("ftp://proxy:21", nil, PoolOptions{}); err == nil {
+		t.Error("Expected error for an invalid proxy URL")
+	}
+}
@@ -0,0 +1,37 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+// NewProxyTransport returns a clone of http.DefaultTransport that sends all
+// requests through proxyURL, preserving HTTP/2, timeouts and connection
+// pooling defaults. Supported schemes are http, https, socks5 and socks5h
+// (host names resolved by the proxy). With an empty proxyURL the transport
+// uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment.
+func NewProxyTransport(proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return transport, nil
+	}
+
+	parsed, err := neturl.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", proxyURL)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+	}
+
+	transport.Proxy = http.ProxyURL(parsed)
+	return transport, nil
+}
@@ -0,0 +1,125 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetryAfter is how long to hold off after a 429 response that does
+// not say how long to wait.
+const DefaultRetryAfter = time.Second
+
+// RateLimitError reports a 429 Too Many Requests response, or a 503 Service
+// Unavailable response carrying Retry-After.
+type RateLimitError struct {
+	StatusCode int
+	// RetryAfter is how long the server asked clients to wait.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("server returned %d %s, retry after %s", e.StatusCode, http.StatusText(e.StatusCode), e.RetryAfter)
+}
+
+// ParseRetryAfter parses a Retry-After header value, either a number of
+// seconds or an HTTP date, into a delay from now. ok is false for a missing
+// or malformed value.
+func ParseRetryAfter(value string, now time.Time) (d time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d = at.Sub(now); d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// RateLimitFromResponse returns a RateLimitError for a response asking the
+// client to slow down, draining and closing its body, or nil for any other
+// response, which is left untouched.
+func RateLimitFromResponse(resp *http.Response) *RateLimitError {
+	retryAfter, limited := rateLimitDelay(resp)
+	if !limited {
+		return nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return &RateLimitError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+}
+
+// rateLimitDelay returns how long resp asks the client to wait, and whether
+// it is a rate limit response at all.
+func rateLimitDelay(resp *http.Response) (time.Duration, bool) {
+	retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if !ok {
+			retryAfter = DefaultRetryAfter
+		}
+		return retryAfter, true
+	case resp.StatusCode == http.StatusServiceUnavailable && ok:
+		return retryAfter, true
+	default:
+		return 0, false
+	}
+}
+
+// Gate holds off requests to a server until the delay it asked for has
+// passed. The zero value is open.
+type Gate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// Close keeps the gate closed for d, or longer if it already is.
+func (g *Gate) Close(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// Remaining returns how long the gate stays closed, zero when it is open.
+func (g *Gate) Remaining() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if d := time.Until(g.until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Wait blocks until the gate opens or ctx is done.
+func (g *Gate) Wait(ctx context.Context) error {
+	for {
+		d := g.Remaining()
+		if d == 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
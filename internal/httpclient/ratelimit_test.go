@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%q: expected %v, %v; got %v, %v", tt.value, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestRateLimitFromResponse(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		want       *RateLimitError
+	}{
+		{http.StatusTooManyRequests, "7", &RateLimitError{StatusCode: 429, RetryAfter: 7 * time.Second}},
+		{http.StatusTooManyRequests, "", &RateLimitError{StatusCode: 429, RetryAfter: DefaultRetryAfter}},
+		{http.StatusServiceUnavailable, "3", &RateLimitError{StatusCode: 503, RetryAfter: 3 * time.Second}},
+		{http.StatusServiceUnavailable, "", nil},
+		{http.StatusInternalServerError, "3", nil},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: http.NoBody}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		got := RateLimitFromResponse(resp)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%d %q: expected %v, got %v", tt.status, tt.retryAfter, tt.want, got)
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	var g Gate
+	if d := g.Remaining(); d != 0 {
+		t.Fatalf("Expected an open gate, got %v", d)
+	}
+
+	g.Close(50 * time.Millisecond)
+	g.Close(10 * time.Millisecond)
+	if d := g.Remaining(); d <= 10*time.Millisecond {
+		t.Errorf("Expected a shorter delay not to reopen the gate early, got %v", d)
+	}
+
+	start := time.Now()
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected Wait to block until the gate opens, returned after %v", elapsed)
+	}
+
+	g.Close(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.Wait(ctx); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientRetriesRateLimited(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(&Config{Timeout: 5 * time.Second, MaxRetries: 1, RetryDelay: time.Millisecond})
+	start := time.Now()
+	resp, err := client.Get(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Expected the rate-limited request to be retried, got %v", err)
+	}
+	if !strings.Contains(resp.String(), "ok") {
+		t.Errorf("Unexpected body %q", resp.String())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for Retry-After, took %v", elapsed)
+	}
+}
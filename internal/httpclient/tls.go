@@ -0,0 +1,63 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions describes TLS settings for connections to servers behind a
+// private CA or requiring client certificates.
+type TLSOptions struct {
+	// CACertFile is a PEM bundle trusted in addition to the system roots.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are a PEM certificate and key
+	// presented for mutual TLS.
+	ClientCertFile string
+	ClientKeyFile  string
+	// InsecureSkipVerify disables server certificate verification.
+	InsecureSkipVerify bool
+}
+
+// TLSConfig builds a tls.Config from o. It returns nil when o is empty, so
+// that the default configuration is kept.
+func (o TLSOptions) TLSConfig() (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+	if (o.ClientCertFile == "") != (o.ClientKeyFile == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CACertFile != "" {
+		pem, err := os.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CACertFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
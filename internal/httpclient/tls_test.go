@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a PEM block to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// newClientCert generates a self-signed client certificate and key.
+func newClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mcp-remote-go test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	return writePEM(t, dir, "client.crt", "CERTIFICATE", der), writePEM(t, dir, "client.key", "PRIVATE KEY", keyDER), cert
+}
+
+func TestTLSOptionsEmpty(t *testing.T) {
+	cfg, err := TLSOptions{}.TLSConfig()
+	if err != nil || cfg != nil {
+		t.Errorf("Expected nil config for empty options, got %v (err %v)", cfg, err)
+	}
+}
+
+func TestTLSOptionsErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]TLSOptions{
+		"missing CA file":  {CACertFile: filepath.Join(dir, "missing.pem")},
+		"CA without certs": {CACertFile: notPEM},
+		"cert without key": {ClientCertFile: notPEM},
+		"invalid key pair": {ClientCertFile: notPEM, ClientKeyFile: notPEM},
+	} {
+		if _, err := opts.TLSConfig(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestTLSOptionsCACertAndClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := newClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	// Without the CA the server certificate is rejected.
+	if _, err := New(&Config{Timeout: 5 * time.Second}).Get(context.Background(), server.URL, nil); err == nil {
+		t.Error("Expected certificate verification error without the CA")
+	}
+
+	tlsConfig, err := TLSOptions{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig failed: %v", err)
+	}
+	transport, err := NewProxyTransport("")
+	if err != nil {
+		t.Fatalf("NewProxyTransport failed: %v", err)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	resp, err := New(&Config{Timeout: 5 * time.Second, Transport: transport}).Get(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	_ = resp.SafeClose()
+	if resp.String() != "ok" {
+		t.Errorf("Expected 'ok', got %q", resp.String())
+	}
+}
+
+func TestTLSOptionsInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	tlsConfig, err := TLSOptions{InsecureSkipVerify: true}.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig failed: %v", err)
+	}
+	transport, _ := NewProxyTransport("")
+	transport.TLSClientConfig = tlsConfig
+
+	resp, err := New(&Config{Timeout: 5 * time.Second, Transport: transport}).Get(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	_ = resp.SafeClose()
+}
@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+)
+
+// KeySet is a parsed JSON Web Key Set.
+type KeySet struct {
+	keys []jsonWebKey
+}
+
+// jsonWebKey is a public key from a key set (RFC 7517 §4).
+type jsonWebKey struct {
+	keyType   string
+	keyID     string
+	algorithm string
+	curve     string
+	public    crypto.PublicKey
+}
+
+// rawJWK is the JSON form of a public JWK.
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet parses a JWKS document. Keys that cannot be used to verify
+// signatures are skipped.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse key set: %w", err)
+	}
+
+	set := &KeySet{}
+	for _, raw := range doc.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		public, err := raw.publicKey()
+		if err != nil {
+			slog.Debug("skipping unusable key", "kid", raw.Kid, "kty", raw.Kty, "error", err)
+			continue
+		}
+		set.keys = append(set.keys, jsonWebKey{
+			keyType:   raw.Kty,
+			keyID:     raw.Kid,
+			algorithm: raw.Alg,
+			curve:     raw.Crv,
+			public:    public,
+		})
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("key set contains no usable signing keys")
+	}
+	return set, nil
+}
+
+// verify checks signature with the keys matching header: the key named by
+// kid, or every key of the right type when the token names none.
+func (s *KeySet) verify(header Header, alg algorithm, input, signature []byte) error {
+	tried := 0
+	for _, key := range s.keys {
+		if header.KeyID != "" && key.keyID != header.KeyID {
+			continue
+		}
+		if key.keyType != alg.keyType || (alg.curve != "" && key.curve != alg.curve) {
+			continue
+		}
+		if key.algorithm != "" && key.algorithm != header.Algorithm {
+			continue
+		}
+		tried++
+		if err := alg.verifySignature(key.public, input, signature); err == nil {
+			return nil
+		}
+	}
+	if tried == 0 {
+		return fmt.Errorf("%w: kid %q, alg %s", ErrKeyNotFound, header.KeyID, header.Algorithm)
+	}
+	return ErrInvalidSignature
+}
+
+func (k rawJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key too small: %d bits", n.BitLen())
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if x.BitLen() > 8*size || y.BitLen() > 8*size {
+			return nil, errors.New("coordinate too large for curve")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4 // uncompressed
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		// Rejects points that are not on the curve.
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,231 @@
+// Package jwt verifies signed JSON Web Tokens (RFC 7519), such as OpenID
+// Connect ID tokens, against a JSON Web Key Set (RFC 7517), and signs
+// tokens such as client assertions (RFC 7523).
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verify, wrapped with details.
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrKeyNotFound      = errors.New("no matching signing key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidClaims    = errors.New("invalid claims")
+)
+
+// DefaultLeeway is the clock skew tolerated when checking exp, nbf and iat.
+const DefaultLeeway = time.Minute
+
+// Header is the JOSE header of a token.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Claims holds the registered claims of a token and the standard OpenID
+// Connect profile claims. Raw holds every claim as decoded JSON.
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Nonce     string   `json:"nonce,omitempty"`
+	// AuthorizedParty is the azp claim, the client the token was issued to
+	// when it has several audiences.
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email,omitempty"`
+	Name            string `json:"name,omitempty"`
+
+	Raw map[string]any `json:"-"`
+}
+
+// Audience is the aud claim, which may be a single string or an array.
+type Audience []string
+
+// UnmarshalJSON accepts a string or an array of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("aud must be a string or an array of strings: %w", err)
+	}
+	*a = multiple
+	return nil
+}
+
+// Options are the checks Verify applies to the claims. Empty fields are not
+// checked.
+type Options struct {
+	Issuer   string
+	Audience string
+	Nonce    string
+	// Now returns the current time; time.Now when nil.
+	Now func() time.Time
+	// Leeway is the clock skew tolerated; DefaultLeeway when zero.
+	Leeway time.Duration
+}
+
+// Verify checks the signature of token against keys and its claims against
+// opts, and returns the claims. Tokens must carry an exp claim.
+func Verify(token string, keys *KeySet, opts Options) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 segments, got %d", ErrMalformed, len(parts))
+	}
+
+	var header Header
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrMalformed, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrMalformed, err)
+	}
+
+	alg, ok := algorithms[header.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, header.Algorithm)
+	}
+	if err := keys.verify(header, alg, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: payload: %w", ErrMalformed, err)
+	}
+	if err := decodeSegment(parts[1], &claims.Raw); err != nil {
+		return nil, fmt.Errorf("%w: payload: %w", ErrMalformed, err)
+	}
+	if err := claims.validate(opts); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// validate checks the claims against opts.
+func (c *Claims) validate(opts Options) error {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	leeway := opts.Leeway
+	if leeway == 0 {
+		leeway = DefaultLeeway
+	}
+	t := now()
+
+	if opts.Issuer != "" && c.Issuer != opts.Issuer {
+		return fmt.Errorf("%w: iss %q, want %q", ErrInvalidClaims, c.Issuer, opts.Issuer)
+	}
+	if opts.Audience != "" {
+		if !slices.Contains(c.Audience, opts.Audience) {
+			return fmt.Errorf("%w: aud %q does not include %q", ErrInvalidClaims, []string(c.Audience), opts.Audience)
+		}
+		if len(c.Audience) > 1 && c.AuthorizedParty != "" && c.AuthorizedParty != opts.Audience {
+			return fmt.Errorf("%w: azp %q, want %q", ErrInvalidClaims, c.AuthorizedParty, opts.Audience)
+		}
+	}
+	if opts.Nonce != "" && c.Nonce != opts.Nonce {
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidClaims)
+	}
+	if c.ExpiresAt == 0 {
+		return fmt.Errorf("%w: missing exp", ErrInvalidClaims)
+	}
+	if t.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrInvalidClaims, time.Unix(c.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	}
+	if c.NotBefore != 0 && t.Add(leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return fmt.Errorf("%w: not valid before %s", ErrInvalidClaims, time.Unix(c.NotBefore, 0).UTC().Format(time.RFC3339))
+	}
+	if c.IssuedAt != 0 && t.Add(leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidClaims)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// algorithm describes a JWS signing algorithm (RFC 7518 §3).
+type algorithm struct {
+	keyType string // JWK kty
+	hash    crypto.Hash
+	pss     bool
+	curve   string // JWK crv for EC and OKP keys
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {keyType: "RSA", hash: crypto.SHA256},
+	"RS384": {keyType: "RSA", hash: crypto.SHA384},
+	"RS512": {keyType: "RSA", hash: crypto.SHA512},
+	"PS256": {keyType: "RSA", hash: crypto.SHA256, pss: true},
+	"PS384": {keyType: "RSA", hash: crypto.SHA384, pss: true},
+	"PS512": {keyType: "RSA", hash: crypto.SHA512, pss: true},
+	"ES256": {keyType: "EC", hash: crypto.SHA256, curve: "P-256"},
+	"ES384": {keyType: "EC", hash: crypto.SHA384, curve: "P-384"},
+	"ES512": {keyType: "EC", hash: crypto.SHA512, curve: "P-521"},
+	"EdDSA": {keyType: "OKP", curve: "Ed25519"},
+}
+
+// verifySignature checks signature over input with key.
+func (a algorithm) verifySignature(key crypto.PublicKey, input, signature []byte) error {
+	var digest []byte
+	if a.hash != 0 {
+		h := a.hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if a.pss {
+			return rsa.VerifyPSS(k, a.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(k, a.hash, digest, signature)
+	case *ecdsa.PublicKey:
+		// JWS uses the fixed-size R || S encoding (RFC 7518 §3.4).
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("wrong signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("verification failed")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, input, signature) {
+			return errors.New("verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected key type %T", key)
+}
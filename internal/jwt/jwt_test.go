@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// sign returns a compact JWS over claims with header alg and kid.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		if alg == "PS256" {
+			sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+	set *KeySet
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64.EncodeToString(rsaKey.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))), "y": b64.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64.EncodeToString(edPublic)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64.EncodeToString(rsaKey.N.Bytes()), "e": "AQAB"},
+	}})
+	set, err := ParseKeySet(jwks)
+	if err != nil {
+		t.Fatalf("ParseKeySet failed: %v", err)
+	}
+	if len(set.keys) != 3 {
+		t.Fatalf("Expected 3 signing keys, got %d", len(set.keys))
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey, ed: edKey, set: set}
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   "https://issuer.example.com",
+		"sub":   "user-1",
+		"aud":   "client",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"email": "user@example.com",
+	}
+}
+
+var validOptions = Options{Issuer: "https://issuer.example.com", Audience: "client"}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		alg, kid string
+		key      crypto.Signer
+	}{
+		{"RS256", "rsa", keys.rsa},
+		{"PS256", "rsa", keys.rsa},
+		{"ES256", "ec", keys.ec},
+		{"EdDSA", "ed", keys.ed},
+		{"ES256", "", keys.ec},
+	}
+	for _, tt := range tests {
+		token := sign(t, tt.alg, tt.kid, tt.key, validClaims())
+		claims, err := Verify(token, keys.set, validOptions)
+		if err != nil {
+			t.Errorf("%s/%q: Verify failed: %v", tt.alg, tt.kid, err)
+			continue
+		}
+		if claims.Subject != "user-1" || claims.Email != "user@example.com" || claims.Raw["sub"] != "user-1" {
+			t.Errorf("%s: unexpected claims %+v", tt.alg, claims)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	with := func(name string, value any) map[string]any {
+		c := validClaims()
+		if value == nil {
+			delete(c, name)
+		} else {
+			c[name] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		opts  Options
+		want  error
+	}{
+		{"wrong key", sign(t, "ES256", "ec", other, validClaims()), validOptions, ErrInvalidSignature},
+		{"unknown kid", sign(t, "ES256", "missing", keys.ec, validClaims()), validOptions, ErrKeyNotFound},
+		{"alg mismatch", sign(t, "RS256", "ec", keys.rsa, validClaims()), validOptions, ErrKeyNotFound},
+		{"alg none", b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{}`)) + ".", validOptions, ErrUnsupportedAlg},
+		{"malformed", "not-a-token", validOptions, ErrMalformed},
+		{"wrong issuer", sign(t, "ES256", "ec", keys.ec, with("iss", "https://evil.example.com")), validOptions, ErrInvalidClaims},
+		{"wrong audience", sign(t, "ES256", "ec", keys.ec, with("aud", []string{"other", "another"})), validOptions, ErrInvalidClaims},
+		{"expired", sign(t, "ES256", "ec", keys.ec, with("exp", time.Now().Add(-time.Hour).Unix())), validOptions, ErrInvalidClaims},
+		{"missing exp", sign(t, "ES256", "ec", keys.ec, with("exp", nil)), validOptions, ErrInvalidClaims},
+		{"not yet valid", sign(t, "ES256", "ec", keys.ec, with("nbf", time.Now().Add(time.Hour).Unix())), validOptions, ErrInvalidClaims},
+		{"nonce", sign(t, "ES256", "ec", keys.ec, with("nonce", "a")), Options{Nonce: "b"}, ErrInvalidClaims},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(tt.token, keys.set, tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestVerifyTamperedPayload(t *testing.T) {
+	keys := newTestKeys(t)
+	token := sign(t, "RS256", "rsa", keys.rsa, validClaims())
+	parts := strings.Split(token, ".")
+	forged := validClaims()
+	forged["sub"] = "admin"
+	payload, _ := json.Marshal(forged)
+	parts[1] = b64.EncodeToString(payload)
+
+	if _, err := Verify(strings.Join(parts, "."), keys.set, validOptions); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerifyAudienceArrayAndLeeway(t *testing.T) {
+	keys := newTestKeys(t)
+	claims := validClaims()
+	claims["aud"] = []string{"client", "api"}
+	claims["azp"] = "client"
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+
+	if _, err := Verify(sign(t, "EdDSA", "ed", keys.ed, claims), keys.set, validOptions); err != nil {
+		t.Errorf("Expected a token within the leeway to verify, got %v", err)
+	}
+
+	claims["azp"] = "api"
+	if _, err := Verify(sign(t, "EdDSA", "ed", keys.ed, claims), keys.set, validOptions); !errors.Is(err, ErrInvalidClaims) {
+		t.Errorf("Expected an azp mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestParseKeySetRejectsUnusableKeys(t *testing.T) {
+	for _, jwks := range []string{
+		`{"keys":[]}`,
+		`{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`,
+		`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`,
+		`not json`,
+	} {
+		if _, err := ParseKeySet([]byte(jwks)); err == nil {
+			t.Errorf("Expected %s to be rejected", jwks)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Sign returns a compact JWS over claims signed with key: RS256 for RSA
+// keys, ES256, ES384 or ES512 for ECDSA keys by curve, and EdDSA for
+// Ed25519 keys. keyID, when set, is sent as the kid header.
+func Sign(claims any, key crypto.Signer, keyID string) (string, error) {
+	alg, err := signingAlgorithm(key)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(Header{Algorithm: alg, KeyID: keyID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := algorithms[alg].sign(key, []byte(input))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signingAlgorithm returns the algorithm Sign uses for key.
+func signingAlgorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return "ES256", nil
+		case "P-384":
+			return "ES384", nil
+		case "P-521":
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("%w: curve %s", ErrUnsupportedAlg, k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	}
+	return "", fmt.Errorf("%w: key type %T", ErrUnsupportedAlg, key)
+}
+
+// sign signs input with key.
+func (a algorithm) sign(key crypto.Signer, input []byte) ([]byte, error) {
+	if k, ok := key.(ed25519.PrivateKey); ok {
+		return ed25519.Sign(k, input), nil
+	}
+	h := a.hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	k, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return key.Sign(rand.Reader, digest, a.hash)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+	if err != nil {
+		return nil, err
+	}
+	// JWS uses the fixed-size R || S encoding (RFC 7518 §3.4).
+	size := (k.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+	return signature, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA, ECDSA or Ed25519 private key in
+// PKCS #8, PKCS #1 or SEC 1 form.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key in PEM block %q", block.Type)
+}
@@ -0,0 +1,67 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestSignVerifies(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		kid string
+		key crypto.Signer
+	}{
+		{"rsa", keys.rsa},
+		{"ec", keys.ec},
+		{"ed", keys.ed},
+	}
+	for _, tt := range tests {
+		token, err := Sign(validClaims(), tt.key, tt.kid)
+		if err != nil {
+			t.Errorf("%s: Sign failed: %v", tt.kid, err)
+			continue
+		}
+		if _, err := Verify(token, keys.set, validOptions); err != nil {
+			t.Errorf("%s: Verify failed: %v", tt.kid, err)
+		}
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	keys := newTestKeys(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(keys.ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(keys.ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		"pkcs8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+		"pkcs1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(keys.rsa)},
+		"sec1":  {Type: "EC PRIVATE KEY", Bytes: sec1},
+	} {
+		key, err := ParsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("%s: ParsePrivateKey failed: %v", name, err)
+			continue
+		}
+		if _, err := Sign(validClaims(), key, ""); err != nil {
+			t.Errorf("%s: Sign failed: %v", name, err)
+		}
+	}
+
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("Expected an error without a PEM block")
+	}
+	if _, err := Sign(validClaims(), unsupportedSigner{keys.rsa}, ""); !errors.Is(err, ErrUnsupportedAlg) {
+		t.Errorf("Expected ErrUnsupportedAlg for an unknown key type, got %v", err)
+	}
+}
+
+// unsupportedSigner hides the type of the key it wraps.
+type unsupportedSigner struct{ crypto.Signer }
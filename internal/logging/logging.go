@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/naotama2002/mcp-remote-go/internal/redact"
+)
+
+// Log formats accepted by New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel converts a level name (debug, info, warn, error) into a slog.Level
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q: must be one of debug, info, warn, error", s)
+	}
+}
+
+// New creates a logger writing to w at the given level and format.
+// Credentials and the fields r names are redacted from every line; a nil r
+// applies the built-in rules only.
+func New(w io.Writer, level, format string, r *redact.Redactor) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: r.ReplaceAttr}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+}
+
+// Setup installs a stderr logger as the slog default. Output from the
+// standard log package is routed through it as well, so every diagnostic
+// line shares the same format.
+func Setup(level, format string, r *redact.Redactor) error {
+	logger, err := New(os.Stderr, level, format, r)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
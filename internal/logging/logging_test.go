@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"", slog.LevelInfo, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			level, err := ParseLevel(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if level != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, level)
+			}
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", FormatJSON, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("reconnecting", "attempt", 2)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line above the level, got %d: %q", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", lines[0], err)
+	}
+	if entry["msg"] != "reconnecting" || entry["level"] != "WARN" || entry["attempt"] != float64(2) {
+		t.Errorf("Unexpected entry: %v", entry)
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", "", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Debug("probe", "status", 405)
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "status=405") {
+		t.Errorf("Unexpected text output: %q", got)
+	}
+}
+
+func TestNewRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("request failed", "authorization", "Bearer abc", "error", errors.New("got Bearer abc back"))
+	if got := buf.String(); strings.Contains(got, "abc") || !strings.Contains(got, "authorization=[REDACTED]") {
+		t.Errorf("Expected credentials redacted, got %q", got)
+	}
+}
+
+func TestNewInvalidFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "info", "xml", nil); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,268 @@
+// Package metrics collects proxy metrics and exposes them in the Prometheus
+// text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics recorded by the proxy, transports and auth coordinator.
+var (
+	MessagesTotal = NewCounterVec("mcp_remote_messages_total",
+		"JSON-RPC messages proxied, by server and direction.", "server", "direction")
+	RequestDuration = NewHistogramVec("mcp_remote_request_duration_seconds",
+		"Time from forwarding a request to receiving its response, by server and method.",
+		DefaultBuckets, "server", "method")
+	ReconnectsTotal = NewCounterVec("mcp_remote_reconnects_total",
+		"Reconnection attempts after a transport error, by server and result.", "server", "result")
+	TokenRefreshesTotal = NewCounterVec("mcp_remote_token_refreshes_total",
+		"OAuth access token refreshes, by result.", "result")
+	TransportFallbacksTotal = NewCounterVec("mcp_remote_transport_fallbacks_total",
+		"Transport negotiations that fell back to another transport, by server.", "server", "from", "to")
+)
+
+// Result label values.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to remote tool calls.
+var DefaultBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// collector is a metric family that can write itself.
+type collector interface {
+	write(w io.Writer) error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves all metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = Write(w)
+	})
+}
+
+// Write writes all metrics to w in the Prometheus text format.
+func Write(w io.Writer) error {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// family holds the fields shared by counter and histogram vectors.
+type family struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// key joins label values into a map key.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats label values as {a="x",b="y"}, followed by extra
+// pre-formatted pairs.
+func (f *family) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) header(w io.Writer, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, typ)
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a family of counters partitioned by label values.
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers a counter family.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: family{name: name, help: help, labels: labels}, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the given label values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	k := c.key(labelValues)
+	c.mu.Lock()
+	c.values[k] += v
+	c.mu.Unlock()
+}
+
+// Value returns the counter with the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	k := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[k]
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(k), formatFloat(c.values[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a family of histograms partitioned by label values.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram family with the given
+// upper bucket bounds, which must be sorted.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		family:  family{name: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	register(h)
+	return h
+}
+
+// Observe records v in the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// ObserveDuration records d in seconds.
+func (h *HistogramVec) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+// Count returns the number of observations with the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[k]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, `le="`+formatFloat(le)+`"`), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.labelPairs(k, `le="+Inf"`), s.count,
+			h.name, h.labelPairs(k), formatFloat(s.sum),
+			h.name, h.labelPairs(k), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
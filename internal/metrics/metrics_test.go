@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	c := &CounterVec{family: family{name: "test_total", help: "Test counter.", labels: []string{"server", "direction"}}, values: make(map[string]float64)}
+	c.Inc("https://b.example.com", "remote_to_local")
+	c.Add(2, "https://a.example.com", "local_to_remote")
+	c.Inc(`we"ird`, "x")
+
+	var sb strings.Builder
+	if err := c.write(&sb); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{server="https://a.example.com",direction="local_to_remote"} 2
+test_total{server="https://b.example.com",direction="remote_to_local"} 1
+test_total{server="we\"ird",direction="x"} 1
+`
+	if sb.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, sb.String())
+	}
+	if got := c.Value("https://a.example.com", "local_to_remote"); got != 2 {
+		t.Errorf("Expected value 2, got %v", got)
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	h := &HistogramVec{family: family{name: "test_seconds", help: "Test histogram.", labels: []string{"method"}}, buckets: []float64{0.1, 1}, series: make(map[string]*histogram)}
+	h.Observe(0.05, "tools/call")
+	h.Observe(0.1, "tools/call")
+	h.Observe(0.5, "tools/call")
+	h.Observe(5, "tools/call")
+
+	var sb strings.Builder
+	if err := h.write(&sb); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="tools/call",le="0.1"} 2
+test_seconds_bucket{method="tools/call",le="1"} 3
+test_seconds_bucket{method="tools/call",le="+Inf"} 4
+test_seconds_sum{method="tools/call"} 5.65
+test_seconds_count{method="tools/call"} 4
+`
+	if sb.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, sb.String())
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for wrong number of label values")
+		}
+	}()
+	c := &CounterVec{family: family{name: "x", labels: []string{"a"}}, values: make(map[string]float64)}
+	c.Inc("a", "b")
+}
+
+func TestHandler(t *testing.T) {
+	TokenRefreshesTotal.Inc(ResultSuccess)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE mcp_remote_messages_total counter",
+		"# TYPE mcp_remote_request_duration_seconds histogram",
+		`mcp_remote_token_refreshes_total{result="success"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+}
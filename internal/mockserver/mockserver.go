@@ -0,0 +1,365 @@
+// Package mockserver is a small MCP server for trying out the proxy and host
+// configurations without a real remote server, and for end-to-end tests. It
+// serves the Streamable HTTP transport on /mcp and the legacy HTTP+SSE
+// transport on /sse, with tools whose results, latencies and failures are
+// configured up front.
+package mockserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProtocolVersion is the MCP protocol version the server answers with.
+const ProtocolVersion = "2025-11-25"
+
+// DefaultName is the serverInfo name reported when Config.Name is empty.
+const DefaultName = "mcp-remote-go-mock"
+
+// Tool is a tool the server offers.
+type Tool struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Result is the text the tool returns. When empty, the tool returns its
+	// arguments as JSON.
+	Result string `yaml:"result"`
+	// Error, when set, makes calls fail with this message as a tool error
+	// (isError in the result).
+	Error string `yaml:"error"`
+	// Latency delays the answer to each call, on top of Config.Latency.
+	Latency time.Duration `yaml:"latency"`
+}
+
+// Config describes the server.
+type Config struct {
+	// Name is the serverInfo name; DefaultName when empty.
+	Name string `yaml:"name"`
+	// Tools are the tools offered. Without any, a single "echo" tool
+	// returns its arguments.
+	Tools []Tool `yaml:"tools"`
+	// Latency delays the answer to every request.
+	Latency time.Duration `yaml:"latency"`
+	// Token, when set, is the bearer token every request must carry;
+	// others are rejected with 401 and a Bearer challenge.
+	Token string `yaml:"token"`
+}
+
+// LoadConfig reads a Config from a YAML file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read mock server config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid mock server config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Server is a mock MCP server. Use Handler to serve it.
+type Server struct {
+	cfg   Config
+	tools map[string]Tool
+
+	mu       sync.Mutex
+	sessions map[string]bool       // Streamable HTTP sessions
+	streams  map[string]*sseStream // legacy SSE streams by session
+	counts   map[string]int        // requests answered by method
+}
+
+// New returns a Server for cfg.
+func New(cfg Config) *Server {
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+	if len(cfg.Tools) == 0 {
+		cfg.Tools = []Tool{{Name: "echo", Description: "Returns its arguments"}}
+	}
+	s := &Server{
+		cfg:      cfg,
+		tools:    make(map[string]Tool, len(cfg.Tools)),
+		sessions: make(map[string]bool),
+		streams:  make(map[string]*sseStream),
+		counts:   make(map[string]int),
+	}
+	for _, tool := range cfg.Tools {
+		s.tools[tool.Name] = tool
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving /mcp, /sse and /messages.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", s.handleStreamable)
+	mux.HandleFunc("/sse", s.handleSSE)
+	mux.HandleFunc("/messages", s.handleSSEMessage)
+	return s.authorize(mux)
+}
+
+// Requests returns how many requests with method the server answered.
+func (s *Server) Requests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[method]
+}
+
+// authorize rejects requests without the configured bearer token.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.Token {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStreamable serves the Streamable HTTP transport.
+func (s *Server) handleStreamable(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+	switch r.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			writeJSON(w, errorResponse(nil, -32700, "parse error"))
+			return
+		}
+		if msg.Method == "initialize" {
+			sessionID = newID()
+			s.mu.Lock()
+			s.sessions[sessionID] = true
+			s.mu.Unlock()
+			w.Header().Set("Mcp-Session-Id", sessionID)
+		} else if !s.knownSession(sessionID) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeJSON(w, s.answer(msg))
+
+	case http.MethodGet:
+		if !s.knownSession(sessionID) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) knownSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// handleSSE opens a legacy SSE stream, announcing the endpoint to post
+// messages to, and writes the answers to those messages on it.
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sessionID := newID()
+	stream := &sseStream{events: make(chan []byte, 16), done: make(chan struct{})}
+	s.mu.Lock()
+	s.streams[sessionID] = stream
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, sessionID)
+		s.mu.Unlock()
+		close(stream.done)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprintf(w, "event: endpoint\ndata: /messages?session_id=%s\n\n", sessionID)
+	flusher.Flush()
+	for {
+		select {
+		case data := <-stream.events:
+			_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleSSEMessage accepts a message for a legacy SSE session and sends the
+// answer on its stream.
+func (s *Server) handleSSEMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	stream, ok := s.streams[r.URL.Query().Get("session_id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if msg.ID == nil {
+		return
+	}
+	go func() {
+		data, err := json.Marshal(s.answer(msg))
+		if err != nil {
+			slog.Warn("failed to encode mock response", "error", err)
+			return
+		}
+		select {
+		case stream.events <- data:
+		case <-stream.done:
+		}
+	}()
+}
+
+// sseStream is an open legacy SSE stream; done is closed once it ends.
+type sseStream struct {
+	events chan []byte
+	done   chan struct{}
+}
+
+// message is a JSON-RPC message from the client.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// answer returns the response to request msg, after the configured latency.
+func (s *Server) answer(msg message) map[string]any {
+	s.mu.Lock()
+	s.counts[msg.Method]++
+	s.mu.Unlock()
+	time.Sleep(s.cfg.Latency)
+
+	switch msg.Method {
+	case "initialize":
+		return result(msg.ID, map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.cfg.Name, "version": "1.0.0"},
+		})
+	case "ping":
+		return result(msg.ID, map[string]any{})
+	case "tools/list":
+		tools := make([]map[string]any, 0, len(s.cfg.Tools))
+		for _, tool := range s.cfg.Tools {
+			tools = append(tools, map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": map[string]any{"type": "object"},
+			})
+		}
+		return result(msg.ID, map[string]any{"tools": tools})
+	case "tools/call":
+		return s.callTool(msg)
+	default:
+		return errorResponse(msg.ID, -32601, "method not found: "+msg.Method)
+	}
+}
+
+// callTool runs a tools/call request.
+func (s *Server) callTool(msg message) map[string]any {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return errorResponse(msg.ID, -32602, "invalid params")
+	}
+	tool, ok := s.tools[params.Name]
+	if !ok {
+		return errorResponse(msg.ID, -32602, "unknown tool: "+params.Name)
+	}
+	time.Sleep(tool.Latency)
+
+	if tool.Error != "" {
+		return result(msg.ID, map[string]any{
+			"content": []map[string]any{{"type": "text", "text": tool.Error}},
+			"isError": true,
+		})
+	}
+	text := tool.Result
+	if text == "" {
+		text = strings.TrimSpace(string(params.Arguments))
+		if text == "" {
+			text = "{}"
+		}
+	}
+	return result(msg.ID, map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+	})
+}
+
+func result(id json.RawMessage, res any) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "result": res}
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "error": map[string]any{"code": code, "message": msg}}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write mock response", "error", err)
+	}
+}
+
+// newID returns a random session ID.
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package mockserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, url, session, token, body string) (*http.Response, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var msg map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&msg)
+	return resp, msg
+}
+
+func TestStreamableHTTP(t *testing.T) {
+	s := New(Config{
+		Name:  "test",
+		Token: "secret",
+		Tools: []Tool{
+			{Name: "greet", Result: "hello", Latency: 20 * time.Millisecond},
+			{Name: "broken", Error: "it broke"},
+		},
+	})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	endpoint := server.URL + "/mcp"
+
+	if resp, _ := post(t, endpoint, "", "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`); resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("Expected a 401 challenge without the token, got %d", resp.StatusCode)
+	}
+
+	resp, msg := post(t, endpoint, "", "secret", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session := resp.Header.Get("Mcp-Session-Id")
+	info, _ := msg["result"].(map[string]any)["serverInfo"].(map[string]any)
+	if session == "" || info["name"] != "test" {
+		t.Fatalf("Expected a session and the server name, got %q and %v", session, msg)
+	}
+
+	if resp, _ := post(t, endpoint, "other", "secret", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+	if resp, _ := post(t, endpoint, session, "secret", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	_, msg = post(t, endpoint, session, "secret", `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"greet"}}`)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the tool latency, answered in %v", elapsed)
+	}
+	if got := toolText(msg); got != "hello" {
+		t.Errorf("Expected the configured result, got %v", msg)
+	}
+	_, msg = post(t, endpoint, session, "secret", `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"broken"}}`)
+	if result, _ := msg["result"].(map[string]any); result["isError"] != true || toolText(msg) != "it broke" {
+		t.Errorf("Expected a tool error, got %v", msg)
+	}
+	_, msg = post(t, endpoint, session, "secret", `{"jsonrpc":"2.0","id":5,"method":"resources/list"}`)
+	if _, ok := msg["error"]; !ok {
+		t.Errorf("Expected an error for an unsupported method, got %v", msg)
+	}
+	if n := s.Requests("tools/call"); n != 2 {
+		t.Errorf("Expected 2 tool calls counted, got %d", n)
+	}
+}
+
+func TestLegacySSE(t *testing.T) {
+	server := httptest.NewServer(New(Config{}).Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/sse")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	events := bufio.NewScanner(resp.Body)
+	next := func() string {
+		for events.Scan() {
+			if data, ok := strings.CutPrefix(events.Text(), "data: "); ok {
+				return data
+			}
+		}
+		t.Fatal("Stream ended")
+		return ""
+	}
+
+	endpoint := next()
+	if !strings.HasPrefix(endpoint, "/messages?session_id=") {
+		t.Fatalf("Expected the message endpoint, got %q", endpoint)
+	}
+	if resp, _ := post(t, server.URL+endpoint, "", "", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"a":1}}}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", resp.StatusCode)
+	}
+	var msg map[string]any
+	if err := json.Unmarshal([]byte(next()), &msg); err != nil || toolText(msg) != `{"a":1}` {
+		t.Errorf("Expected the echoed arguments on the stream, got %v", msg)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := t.TempDir() + "/mock.yaml"
+	if err := os.WriteFile(path, []byte("name: demo\nlatency: 10ms\ntools:\n  - name: slow\n    latency: 2s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Name != "demo" || cfg.Latency != 10*time.Millisecond || len(cfg.Tools) != 1 || cfg.Tools[0].Latency != 2*time.Second {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+}
+
+func toolText(msg map[string]any) string {
+	result, _ := msg["result"].(map[string]any)
+	content, _ := result["content"].([]any)
+	if len(content) == 0 {
+		return ""
+	}
+	text, _ := content[0].(map[string]any)["text"].(string)
+	return text
+}
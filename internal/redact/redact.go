@@ -0,0 +1,221 @@
+// Package redact removes secrets from the JSON-RPC messages and log lines
+// the proxy writes for diagnostics. Built-in rules hide credentials such as
+// Authorization headers and OAuth tokens; further fields are named by path,
+// e.g. "params.arguments.password".
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "[REDACTED]"
+
+// Directions a rule may be limited to. They match the directions recorded
+// in trace files.
+const (
+	DirectionLocalToRemote = "local_to_remote"
+	DirectionRemoteToLocal = "remote_to_local"
+)
+
+// sensitiveKeys are object keys and log attributes whose values are always
+// redacted, compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"token":               true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"id_token":            true,
+	"client_secret":       true,
+	"password":            true,
+	"api_key":             true,
+	"x-api-key":           true,
+}
+
+var (
+	// credentialPattern matches credentials in the Authorization header
+	// syntax.
+	credentialPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	// parameterPattern matches credentials passed as URL or form parameters.
+	parameterPattern = regexp.MustCompile(`(?i)\b(access_token|refresh_token|id_token|client_secret)=[^&\s"']+`)
+)
+
+// Redactor applies the built-in rules and the configured field paths. A nil
+// Redactor applies the built-in rules only.
+type Redactor struct {
+	rules []rule
+}
+
+// rule redacts the value at path, in messages travelling in direction, or
+// in both when direction is "".
+type rule struct {
+	direction string
+	path      []string
+}
+
+// New returns a Redactor for fields. Each field is a dot-separated path of
+// object keys from the top of a JSON-RPC message, where "*" matches any key
+// and arrays are searched element by element. A "local_to_remote:" or
+// "remote_to_local:" prefix limits a field to messages in that direction.
+func New(fields []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, field := range fields {
+		direction := ""
+		for _, d := range []string{DirectionLocalToRemote, DirectionRemoteToLocal} {
+			if rest, ok := strings.CutPrefix(field, d+":"); ok {
+				direction, field = d, rest
+			}
+		}
+		path := strings.Split(field, ".")
+		for _, segment := range path {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid redact field %q: empty path segment", field)
+			}
+		}
+		r.rules = append(r.rules, rule{direction: direction, path: path})
+	}
+	return r, nil
+}
+
+// Message returns message with sensitive values replaced by Placeholder.
+// direction is the direction the message travels, or "" to apply every
+// rule. Messages that are not JSON have credentials removed from the text.
+// The message is returned unchanged when nothing was redacted.
+func (r *Redactor) Message(direction string, message []byte) []byte {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return []byte(String(string(message)))
+	}
+	changed := redactKeys(v)
+	if r != nil {
+		for _, rule := range r.rules {
+			if rule.direction == "" || direction == "" || rule.direction == direction {
+				changed = redactPath(v, rule.path) || changed
+			}
+		}
+	}
+	if !changed {
+		return message
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return message
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n"))
+}
+
+// String returns s with credentials in the Authorization header syntax and
+// in URL or form parameters replaced by Placeholder.
+func String(s string) string {
+	s = credentialPattern.ReplaceAllString(s, "$1 "+Placeholder)
+	return parameterPattern.ReplaceAllString(s, "$1="+Placeholder)
+}
+
+// ReplaceAttr is a slog.HandlerOptions.ReplaceAttr function that redacts
+// sensitive attributes, credentials in strings and errors, and the
+// configured fields of JSON values.
+func (r *Redactor) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Placeholder)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s := a.Value.String()
+		if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			s = string(r.Message("", []byte(s)))
+		}
+		a.Value = slog.StringValue(String(s))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			if s := String(v.Error()); s != v.Error() {
+				a.Value = slog.StringValue(s)
+			}
+		case json.RawMessage:
+			a.Value = slog.StringValue(string(r.Message("", v)))
+		case []byte:
+			a.Value = slog.StringValue(string(r.Message("", v)))
+		}
+	}
+	return a
+}
+
+// redactKeys replaces the values of sensitive keys anywhere in v and
+// credentials in its strings. It reports whether anything changed.
+func redactKeys(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitiveKeys[strings.ToLower(key)] {
+				if value != Placeholder {
+					v[key] = Placeholder
+					changed = true
+				}
+				continue
+			}
+			if s, ok := value.(string); ok {
+				if redacted := String(s); redacted != s {
+					v[key] = redacted
+					changed = true
+				}
+				continue
+			}
+			changed = redactKeys(value) || changed
+		}
+	case []interface{}:
+		for i, value := range v {
+			if s, ok := value.(string); ok {
+				if redacted := String(s); redacted != s {
+					v[i] = redacted
+					changed = true
+				}
+				continue
+			}
+			changed = redactKeys(value) || changed
+		}
+	}
+	return changed
+}
+
+// redactPath replaces the values at path in v. It reports whether anything
+// changed.
+func redactPath(v interface{}, path []string) bool {
+	switch v := v.(type) {
+	case []interface{}:
+		changed := false
+		for _, element := range v {
+			changed = redactPath(element, path) || changed
+		}
+		return changed
+	case map[string]interface{}:
+		changed := false
+		for key, value := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				if value != Placeholder {
+					v[key] = Placeholder
+					changed = true
+				}
+				continue
+			}
+			changed = redactPath(value, path[1:]) || changed
+		}
+		return changed
+	}
+	return false
+}
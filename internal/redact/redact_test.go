@@ -0,0 +1,74 @@
+package redact
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMessageBuiltinRules(t *testing.T) {
+	message := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"login","arguments":{"user":"a","Password":"hunter2","note":"use Bearer abc.def <ok>"}}}`)
+	got := string((*Redactor)(nil).Message("", message))
+	for _, unwanted := range []string{"hunter2", "abc.def"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Expected %q redacted, got %s", unwanted, got)
+		}
+	}
+	if !strings.Contains(got, `"user":"a"`) || !strings.Contains(got, `"note":"use Bearer [REDACTED] <ok>"`) {
+		t.Errorf("Expected other values kept, got %s", got)
+	}
+
+	unchanged := []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if got := (*Redactor)(nil).Message("", unchanged); string(got) != string(unchanged) {
+		t.Errorf("Expected the message unchanged, got %s", got)
+	}
+	if got := string((*Redactor)(nil).Message("", []byte("GET /cb?access_token=xyz&state=1"))); got != "GET /cb?access_token=[REDACTED]&state=1" {
+		t.Errorf("Expected text redacted, got %q", got)
+	}
+}
+
+func TestMessageFields(t *testing.T) {
+	r, err := New([]string{"params.arguments.ssn", "remote_to_local:result.content.text", "params.*.pin"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	request := []byte(`{"id":1,"params":{"arguments":{"ssn":"123","name":"x"},"meta":{"pin":42}}}`)
+	got := string(r.Message(DirectionLocalToRemote, request))
+	if strings.Contains(got, "123") || strings.Contains(got, "42") || !strings.Contains(got, `"name":"x"`) {
+		t.Errorf("Unexpected redaction: %s", got)
+	}
+
+	response := []byte(`{"id":1,"result":{"content":[{"type":"text","text":"secret"},{"type":"text","text":"also"}]}}`)
+	if got := string(r.Message(DirectionLocalToRemote, response)); got != string(response) {
+		t.Errorf("Expected a remote_to_local field ignored in the other direction, got %s", got)
+	}
+	got = string(r.Message(DirectionRemoteToLocal, response))
+	if strings.Contains(got, "secret") || strings.Contains(got, "also") || !strings.Contains(got, `"type":"text"`) {
+		t.Errorf("Expected text in every content element redacted, got %s", got)
+	}
+
+	if _, err := New([]string{"params..x"}); err == nil {
+		t.Error("Expected an error for an empty path segment")
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	r, _ := New([]string{"params.arguments.ssn"})
+	tests := []struct {
+		attr slog.Attr
+		want string
+	}{
+		{slog.String("Authorization", "Bearer abc"), Placeholder},
+		{slog.String("url", "https://x/cb?refresh_token=abc"), "https://x/cb?refresh_token=" + Placeholder},
+		{slog.Any("error", errors.New("rejected Bearer abc")), "rejected Bearer " + Placeholder},
+		{slog.String("message", `{"params":{"arguments":{"ssn":"123"}}}`), `{"params":{"arguments":{"ssn":"` + Placeholder + `"}}}`},
+		{slog.Int("attempt", 2), "2"},
+	}
+	for _, tt := range tests {
+		if got := r.ReplaceAttr(nil, tt.attr).Value.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.attr.Key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package resilience protects the remote server from a proxy that keeps
+// sending to it while it is failing.
+package resilience
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults used for zero Breaker settings.
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = 30 * time.Second
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects every call until the cooldown is over.
+	Open
+	// HalfOpen lets a single trial call through; its outcome closes or
+	// reopens the breaker.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// OpenError is returned for calls rejected by an open breaker.
+type OpenError struct {
+	// Failures is the number of consecutive failures that opened the
+	// breaker.
+	Failures int
+	// RetryAfter is how long until a trial call is let through.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	if e.RetryAfter <= 0 {
+		return fmt.Sprintf("circuit breaker is open after %d consecutive failures, waiting for a trial request", e.Failures)
+	}
+	return fmt.Sprintf("circuit breaker is open after %d consecutive failures, retrying in %s", e.Failures, e.RetryAfter.Round(time.Second))
+}
+
+// Breaker is a circuit breaker. After threshold consecutive failures it
+// opens and rejects calls for the cooldown; it then lets one trial call
+// through, closing again if that succeeds and reopening if it fails.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker returns a Breaker opening after threshold consecutive failures
+// for cooldown. A zero cooldown is DefaultCooldown. It returns nil when
+// threshold is not positive; a nil Breaker lets every call through.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may be made, returning an *OpenError if not.
+// A caller that is allowed must report the outcome with Success or Failure.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if wait := b.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+			return &OpenError{Failures: b.failures, RetryAfter: wait}
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return nil
+	case HalfOpen:
+		if b.trial {
+			return &OpenError{Failures: b.failures}
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Success records a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = Closed
+	b.failures = 0
+	b.trial = false
+}
+
+// Release records a call that ended without an outcome, e.g. because it
+// was canceled, so that a half-open breaker lets another trial through.
+func (b *Breaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// Failure records a failed call. It opens the breaker once threshold calls
+// failed in a row, or right away when the trial call of a half-open breaker
+// fails. It reports whether the breaker opened.
+func (b *Breaker) Failure() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.state = Open
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// State returns the current state. An open breaker whose cooldown is over
+// is reported as half-open.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,96 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	b := NewBreaker(threshold, cooldown)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Call %d: expected the breaker closed, got %v", i+1, err)
+		}
+		if b.Failure() {
+			t.Fatalf("Call %d: expected the breaker to stay closed", i+1)
+		}
+	}
+	b.Success()
+	for i := 0; i < 2; i++ {
+		b.Failure()
+	}
+	if b.State() != Closed {
+		t.Fatalf("Expected a success to reset the failure count, got %v", b.State())
+	}
+	if !b.Failure() {
+		t.Fatal("Expected the third consecutive failure to open the breaker")
+	}
+
+	var open *OpenError
+	if err := b.Allow(); !errors.As(err, &open) {
+		t.Fatalf("Expected an OpenError, got %v", err)
+	}
+	if open.Failures != 3 || open.RetryAfter != time.Minute {
+		t.Errorf("Expected 3 failures and a minute to wait, got %+v", open)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	b.Failure()
+
+	*now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected half-open after the cooldown, got %v", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a trial call, got %v", err)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("Expected a second call to wait for the trial")
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a released trial to let another through, got %v", err)
+	}
+	if !b.Failure() {
+		t.Fatal("Expected a failed trial to reopen the breaker")
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("Expected the reopened breaker to reject calls")
+	}
+
+	*now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a trial call, got %v", err)
+	}
+	b.Success()
+	if b.State() != Closed {
+		t.Fatalf("Expected a successful trial to close the breaker, got %v", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("Expected the closed breaker to allow calls, got %v", err)
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	b := NewBreaker(0, time.Minute)
+	if b != nil {
+		t.Fatal("Expected no breaker without a threshold")
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("Expected a nil breaker to allow calls, got %v", err)
+	}
+	if b.Failure() || b.State() != Closed {
+		t.Error("Expected a nil breaker to stay closed")
+	}
+}
@@ -0,0 +1,101 @@
+// Package status serves health and status information about a running proxy
+// on a local HTTP port.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ReportFunc returns whether the proxy is healthy and a JSON-serializable
+// description of its state.
+type ReportFunc func() (healthy bool, status any)
+
+// Server serves /healthz and /status. Further handlers can be added with
+// Handle before Start.
+type Server struct {
+	mux      *http.ServeMux
+	srv      *http.Server
+	listener net.Listener
+}
+
+// New returns a Server that reports the state returned by report.
+func New(report ReportFunc) *Server {
+	s := &Server{mux: http.NewServeMux()}
+
+	// /healthz answers 200 while connected and 503 otherwise, for liveness
+	// and readiness probes.
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		healthy, _ := report()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("unavailable\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+
+	s.mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		_, st := report()
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(st); err != nil {
+			slog.Warn("failed to write status", "error", err)
+		}
+	})
+
+	return s
+}
+
+// Handle registers an additional handler, e.g. for metrics.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start listens on port on the loopback interface and serves in the
+// background. Port 0 picks a free port; see Addr.
+func (s *Server) Start(port int) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen on status port %d: %w", port, err)
+	}
+	s.listener = listener
+	s.srv = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("status server failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	if s.srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,76 @@
+package status
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func startServer(t *testing.T, report ReportFunc) string {
+	t.Helper()
+	s := New(report)
+	if err := s.Start(0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return "http://" + s.Addr()
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHealthz(t *testing.T) {
+	var healthy atomic.Bool
+	base := startServer(t, func() (bool, any) { return healthy.Load(), nil })
+
+	if code, _ := get(t, base+"/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while unhealthy, got %d", code)
+	}
+	healthy.Store(true)
+	if code, body := get(t, base+"/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("Expected 200 ok while healthy, got %d %q", code, body)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	base := startServer(t, func() (bool, any) {
+		return true, map[string]any{"server": "https://mcp.example.com/mcp", "messages_sent": 3}
+	})
+
+	code, body := get(t, base+"/status")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", body, err)
+	}
+	if got["server"] != "https://mcp.example.com/mcp" || got["messages_sent"] != float64(3) {
+		t.Errorf("Unexpected status: %v", got)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	s := New(func() (bool, any) { return true, nil })
+	s.Handle("GET /extra", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("extra"))
+	}))
+	if err := s.Start(0); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	if code, body := get(t, "http://"+s.Addr()+"/extra"); code != http.StatusOK || body != "extra" {
+		t.Errorf("Expected extra handler, got %d %q", code, body)
+	}
+}
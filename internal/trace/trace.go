@@ -0,0 +1,186 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/redact"
+)
+
+// Default rotation limits
+const (
+	DefaultMaxSize    = 10 * 1024 * 1024
+	DefaultMaxBackups = 3
+)
+
+// Entry is one line of the trace file
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"`
+	Server    string          `json:"server,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"`
+	Raw       string          `json:"raw,omitempty"`
+}
+
+// Writer appends trace entries to a JSONL file, rotating it once it grows past
+// MaxSize. Rotated files are named path.1 (newest) to path.N (oldest).
+type Writer struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	// redactor removes secrets from messages before they are written.
+	redactor *redact.Redactor
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens path for appending, creating it if needed. A maxSize or
+// maxBackups of zero selects the default.
+func Open(path string, maxSize int64, maxBackups int) (*Writer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+
+	w := &Writer{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetRedactor sets the fields redacted from traced messages in addition to
+// the built-in credential rules. It must be called before the first Trace.
+func (w *Writer) SetRedactor(r *redact.Redactor) {
+	w.redactor = r
+}
+
+// Create starts a recording at path, replacing any file there. Unlike Open,
+// it never rotates, so the recording stays complete for ReadFile.
+func Create(path string) (*Writer, error) {
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	return Open(path, math.MaxInt64, 1)
+}
+
+// ReadFile reads the entries of a trace file or recording.
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	dec := json.NewDecoder(file)
+	for {
+		var entry Entry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func (w *Writer) openFile() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat trace file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Trace records a message travelling in direction, with secrets redacted.
+// Messages that are not valid JSON are kept in the raw field. Errors are not returned,
+// since tracing must never interrupt the proxy.
+func (w *Writer) Trace(direction, server string, message []byte) {
+	entry := Entry{
+		Direction: direction,
+		Server:    server,
+	}
+	message = w.redactor.Message(direction, bytes.TrimSpace(message))
+	if json.Valid(message) {
+		entry.Message = append(json.RawMessage(nil), message...)
+	} else {
+		entry.Raw = string(message)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return
+		}
+	}
+	n, _ := w.file.Write(line)
+	w.size += int64(n)
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1 and reopens path.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		w.file = nil
+		return err
+	}
+	w.file = nil
+
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		// Keep appending to the current file rather than losing the trace.
+		_ = w.openFile()
+		return fmt.Errorf("failed to rotate trace file: %w", err)
+	}
+	return w.openFile()
+}
+
+// Close closes the trace file. Later calls to Trace are ignored.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naotama2002/mcp-remote-go/internal/redact"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid JSONL line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWriterTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	w, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	w.Trace("local_to_remote", "https://example.com/mcp", []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`+"\n"))
+	w.Trace("remote_to_local", "", []byte("not json"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	w.Trace("local_to_remote", "", []byte(`{}`))
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Direction != "local_to_remote" || entries[0].Server != "https://example.com/mcp" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if string(entries[0].Message) != `{"jsonrpc":"2.0","id":1,"method":"ping"}` {
+		t.Errorf("Expected message embedded as JSON, got %s", entries[0].Message)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("Expected timestamp")
+	}
+	if entries[1].Raw != "not json" || entries[1].Message != nil {
+		t.Errorf("Expected invalid JSON kept as raw, got %+v", entries[1])
+	}
+}
+
+func TestWriterRedacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	w, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	r, err := redact.New([]string{"local_to_remote:params.arguments.pin"})
+	if err != nil {
+		t.Fatalf("redact.New failed: %v", err)
+	}
+	w.SetRedactor(r)
+
+	w.Trace("local_to_remote", "", []byte(`{"id":1,"params":{"arguments":{"pin":"1234","token":"abc"}}}`))
+	w.Trace("remote_to_local", "", []byte(`{"id":1,"result":{"pin":"5678"}}`))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if got := string(entries[0].Message); got != `{"id":1,"params":{"arguments":{"pin":"[REDACTED]","token":"[REDACTED]"}}}` {
+		t.Errorf("Expected the request redacted, got %s", got)
+	}
+	if got := string(entries[1].Message); got != `{"id":1,"result":{"pin":"5678"}}` {
+		t.Errorf("Expected the response kept, got %s", got)
+	}
+}
+
+func TestWriterRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	w, err := Open(path, 200, 2)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	msg := []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`)
+	for i := 0; i < 10; i++ {
+		w.Trace("remote_to_local", "", msg)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("Expected %s to be at most 200 bytes, got %d", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups, stat %s.3 returned %v", path, err)
+	}
+}
+
+func TestCreateAndReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte("stale\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	w.Trace("local_to_remote", "https://example.com/mcp", []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	w.Trace("remote_to_local", "https://example.com/mcp", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Direction != "local_to_remote" || string(entries[1].Message) != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Errorf("Expected the recording replacing the old file, got %+v", entries)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Error("Expected an error for an invalid recording")
+	}
+}
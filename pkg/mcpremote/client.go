@@ -0,0 +1,355 @@
+// Package mcpremote embeds the mcp-remote-go bridge in other Go programs.
+//
+// A Client connects to a remote MCP server with the same transport
+// negotiation and OAuth handling as the mcp-remote-go binary, but exchanges
+// JSON-RPC messages through Go calls instead of stdio:
+//
+//	client, err := mcpremote.Dial(ctx, mcpremote.Options{
+//		ServerURL: "https://remote.mcp.server/mcp",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//
+//	result, err := client.Call(ctx, "initialize", initializeParams)
+//
+// Tokens are stored in the same place as the CLI's, so a server authorized
+// with one is already authorized for the other.
+package mcpremote
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+// TransportMode selects the transport used to reach the server.
+type TransportMode = proxy.TransportMode
+
+// Transport modes accepted in Options.Transport.
+const (
+	TransportAuto           = proxy.TransportModeAuto
+	TransportHTTPFirst      = proxy.TransportModeHTTPFirst
+	TransportSSEFirst       = proxy.TransportModeSSEFirst
+	TransportStreamableHTTP = proxy.TransportModeStreamableHTTP
+	TransportSSE            = proxy.TransportModeSSE
+	TransportWebSocket      = proxy.TransportModeWebSocket
+)
+
+// defaultCallbackPort matches the CLI's default OAuth callback port.
+const defaultCallbackPort = 3334
+
+// messageBuffer is the number of server messages queued for Messages before
+// delivery blocks the connection.
+const messageBuffer = 64
+
+// ErrClosed is returned by operations on a closed Client.
+var ErrClosed = errors.New("mcpremote: client closed")
+
+// Options configures a Client. Only ServerURL is required.
+type Options struct {
+	// ServerURL is the remote MCP server endpoint.
+	ServerURL string
+
+	// Transport defaults to TransportAuto.
+	Transport TransportMode
+
+	// Headers are added to every request sent to the server.
+	Headers map[string]string
+
+	// HeaderProvider, when set, is called for every request sent to the
+	// server, and its headers are set over Headers. Use it for credentials
+	// that rotate during a long session.
+	HeaderProvider func() map[string]string
+
+	// CallbackPort is the preferred local port for the OAuth redirect
+	// (default 3334). The next free port is used when it is taken.
+	CallbackPort int
+
+	// HTTPProxyURL routes traffic through an HTTP/HTTPS or SOCKS5 proxy.
+	HTTPProxyURL string
+
+	// TLSConfig, when set, is used for connections to the server and the
+	// authorization server, e.g. to trust a private CA.
+	TLSConfig *tls.Config
+
+	// TokenStore persists OAuth credentials. Defaults to file storage in
+	// the same directory as the CLI.
+	TokenStore auth.TokenStore
+
+	// Scopes overrides the OAuth scopes requested (default "mcp offline_access").
+	Scopes []string
+
+	// OnAuthURL is called with the authorization URL when the user must sign
+	// in. When nil the browser is opened with BrowserCommand.
+	OnAuthURL func(authURL string) error
+
+	// BrowserCommand opens the authorization URL, e.g. "firefox %s"; "%s"
+	// is replaced by the URL. When empty the BROWSER environment variable
+	// or the system browser is used.
+	BrowserCommand string
+
+	// Middleware inspects and transforms messages in both directions; see
+	// proxy.Middleware.
+	Middleware []proxy.Middleware
+}
+
+// RPCError is a JSON-RPC error returned by the server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcpremote: server error %d: %s", e.Code, e.Message)
+}
+
+// Client is a connection to a remote MCP server.
+type Client struct {
+	proxy    *proxy.Proxy
+	messages chan json.RawMessage
+	done     chan struct{}
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[string]chan *rpcResponse
+}
+
+// rpcResponse is the part of a JSON-RPC response the Client interprets.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// Dial connects to the server described by opts, running the OAuth flow if
+// the server requires authorization. Dial returns once connected; cancelling
+// ctx abandons the attempt, including a pending sign-in.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.ServerURL == "" {
+		return nil, errors.New("mcpremote: ServerURL is required")
+	}
+	if opts.Transport == "" {
+		opts.Transport = TransportAuto
+	}
+	if _, err := proxy.ParseTransportMode(string(opts.Transport)); err != nil {
+		return nil, fmt.Errorf("mcpremote: %w", err)
+	}
+	if opts.CallbackPort == 0 {
+		opts.CallbackPort = defaultCallbackPort
+	}
+
+	c := &Client{
+		messages: make(chan json.RawMessage, messageBuffer),
+		done:     make(chan struct{}),
+		pending:  make(map[string]chan *rpcResponse),
+	}
+
+	proxyOpts := []proxy.Option{
+		proxy.WithMessageHandler(c.handleMessage),
+		proxy.WithScopes(opts.Scopes),
+	}
+	if opts.TokenStore != nil {
+		proxyOpts = append(proxyOpts, proxy.WithTokenStore(opts.TokenStore))
+	}
+	if opts.OnAuthURL != nil {
+		proxyOpts = append(proxyOpts, proxy.WithAuthURLHandler(opts.OnAuthURL))
+	}
+	if opts.BrowserCommand != "" {
+		proxyOpts = append(proxyOpts, proxy.WithBrowserCommand(opts.BrowserCommand))
+	}
+	if len(opts.Middleware) > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithMiddleware(opts.Middleware...))
+	}
+	if opts.TLSConfig != nil {
+		proxyOpts = append(proxyOpts, proxy.WithTLSConfig(opts.TLSConfig))
+	}
+	if opts.HeaderProvider != nil {
+		proxyOpts = append(proxyOpts, proxy.WithHeaderProvider(opts.HeaderProvider))
+	}
+
+	p, err := proxy.NewProxyWithOptions(opts.ServerURL, opts.CallbackPort, opts.Headers,
+		auth.ServerKey(opts.ServerURL), opts.Transport, opts.HTTPProxyURL, proxyOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("mcpremote: %w", err)
+	}
+	c.proxy = p
+
+	connected := make(chan error, 1)
+	go func() { connected <- p.Connect() }()
+	select {
+	case err := <-connected:
+		if err != nil {
+			p.Shutdown()
+			return nil, fmt.Errorf("mcpremote: %w", err)
+		}
+	case <-ctx.Done():
+		p.Shutdown()
+		return nil, ctx.Err()
+	}
+
+	go func() {
+		select {
+		case <-p.Done():
+			c.shutdown()
+		case <-c.done:
+		}
+	}()
+	return c, nil
+}
+
+// Call sends a request and waits for its response. params may be nil or any
+// JSON-serializable value. A JSON-RPC error from the server is returned as
+// *RPCError.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.nextID++
+	id := strconv.Quote("mcpremote-" + strconv.FormatInt(c.nextID, 10))
+	ch := make(chan *rpcResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	msg, err := encodeMessage(json.RawMessage(id), method, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Send(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := encodeMessage(nil, method, params)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, msg)
+}
+
+// Send forwards a raw JSON-RPC message. Responses to requests sent this way,
+// like all other server messages, arrive on Messages.
+func (c *Client) Send(ctx context.Context, message []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	if err := c.proxy.Send(ctx, message); err != nil {
+		return fmt.Errorf("mcpremote: %w", err)
+	}
+	return nil
+}
+
+// Messages returns server messages not consumed by Call: notifications,
+// server-initiated requests and responses to messages sent with Send. It
+// must be drained, since a full buffer holds up delivery of Call responses
+// too. Use Done to detect shutdown.
+func (c *Client) Messages() <-chan json.RawMessage {
+	return c.messages
+}
+
+// SessionID returns the MCP session ID assigned by the server, if any.
+func (c *Client) SessionID() string {
+	return c.proxy.SessionID()
+}
+
+// Done is closed when the client shuts down, either through Close or because
+// the connection could not be re-established.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close disconnects from the server.
+func (c *Client) Close() error {
+	c.shutdown()
+	c.proxy.Shutdown()
+	return nil
+}
+
+func (c *Client) shutdown() {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.pending = nil
+		close(c.done)
+		c.mu.Unlock()
+	})
+}
+
+// handleMessage routes a server message to a waiting Call or to Messages.
+func (c *Client) handleMessage(data []byte) {
+	msg := json.RawMessage(append([]byte(nil), data...))
+
+	var resp rpcResponse
+	if err := json.Unmarshal(msg, &resp); err == nil && resp.Method == "" && resp.ID != nil {
+		c.mu.Lock()
+		ch, ok := c.pending[string(resp.ID)]
+		c.mu.Unlock()
+		if ok {
+			select {
+			case ch <- &resp:
+			default: // duplicate response for the same ID
+			}
+			return
+		}
+	}
+
+	c.mu.Lock()
+	closed := c.pending == nil
+	c.mu.Unlock()
+	if closed {
+		return
+	}
+	select {
+	case c.messages <- msg:
+	case <-c.done:
+	}
+}
+
+func encodeMessage(id json.RawMessage, method string, params interface{}) ([]byte, error) {
+	msg := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  interface{}     `json:"params,omitempty"`
+	}{JSONRPC: "2.0", ID: id, Method: method, Params: params}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("mcpremote: failed to encode %s: %w", method, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,165 @@
+package mcpremote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/proxy"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]interface{}
+		_ = json.Unmarshal(body, &msg)
+		if _, ok := msg["id"]; !ok {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": msg["id"]}
+		switch msg["method"] {
+		case "initialize":
+			resp["result"] = map[string]interface{}{
+				"protocolVersion": proxy.MCPProtocolVersion,
+				"capabilities":    map[string]interface{}{},
+				"serverInfo":      map[string]string{"name": "test", "version": "1.0.0"},
+			}
+		case "ping":
+			resp["result"] = map[string]interface{}{}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(proxy.HeaderMCPSessionID, "lib-session")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dialTestServer(t *testing.T) *Client {
+	t.Helper()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	server := newTestServer(t)
+
+	client, err := Dial(t.Context(), Options{
+		ServerURL: server.URL,
+		Transport: TransportStreamableHTTP,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestClientCall(t *testing.T) {
+	client := dialTestServer(t)
+
+	result, err := client.Call(t.Context(), "initialize", map[string]interface{}{
+		"protocolVersion": proxy.MCPProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "lib-test", "version": "1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := json.Unmarshal(result, &init); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if init.ProtocolVersion != proxy.MCPProtocolVersion {
+		t.Errorf("Expected protocol version %s, got %s", proxy.MCPProtocolVersion, init.ProtocolVersion)
+	}
+	if client.SessionID() != "lib-session" {
+		t.Errorf("Expected session ID 'lib-session', got %q", client.SessionID())
+	}
+
+	if err := client.Notify(t.Context(), "notifications/initialized", nil); err != nil {
+		t.Errorf("Notify failed: %v", err)
+	}
+}
+
+func TestClientCallError(t *testing.T) {
+	client := dialTestServer(t)
+
+	_, err := client.Call(t.Context(), "unknown/method", nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("Expected *RPCError, got %v", err)
+	}
+	if rpcErr.Code != -32601 {
+		t.Errorf("Expected code -32601, got %d", rpcErr.Code)
+	}
+}
+
+func TestClientSendDeliversToMessages(t *testing.T) {
+	client := dialTestServer(t)
+
+	if err := client.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":42,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case msg := <-client.Messages():
+		var resp struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(msg, &resp); err != nil || resp.ID != 42 {
+			t.Errorf("Expected response with id 42, got %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	client := dialTestServer(t)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Error("Expected Done to be closed after Close")
+	}
+	if _, err := client.Call(context.Background(), "ping", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestDialRequiresServerURL(t *testing.T) {
+	if _, err := Dial(t.Context(), Options{}); err == nil {
+		t.Error("Expected error without ServerURL")
+	}
+}
+
+func TestDialRejectsUnknownTransport(t *testing.T) {
+	if _, err := Dial(t.Context(), Options{ServerURL: "https://example.com/mcp", Transport: "carrier-pigeon"}); err == nil || !strings.Contains(err.Error(), "invalid transport mode") {
+		t.Errorf("Expected an invalid transport error, got %v", err)
+	}
+}
@@ -0,0 +1,935 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NamespaceSeparator joins a server name and a tool or prompt name in
+// aggregation mode, e.g. "github.search_issues".
+const NamespaceSeparator = "."
+
+const (
+	// aggregateRequestTimeout bounds how long fan-out requests such as
+	// initialize and tools/list wait for a slow upstream.
+	aggregateRequestTimeout = 60 * time.Second
+
+	// aggregateMaxListPages caps how many pages are fetched from a single
+	// upstream when following nextCursor.
+	aggregateMaxListPages = 50
+
+	aggregatorServerName = "mcp-remote-go"
+)
+
+var upstreamNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Upstream describes one remote MCP server served by an Aggregator.
+type Upstream struct {
+	Name          string
+	ServerURL     string
+	ServerURLHash string
+	Headers       map[string]string
+	Scopes        []string
+}
+
+// AggregatorConfig holds configuration for creating an Aggregator.
+type AggregatorConfig struct {
+	Upstreams    []Upstream
+	CallbackPort int
+	Mode         TransportMode
+	HTTPProxyURL string
+	Version      string
+}
+
+// Aggregator exposes several remote MCP servers through a single stdio
+// endpoint. Tools and prompts are namespaced as "<server>.<name>"; resources
+// keep their URIs and are routed to the server that listed them.
+type Aggregator struct {
+	upstreams []*upstream
+	byName    map[string]*upstream
+	version   string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stdioReader *bufio.Reader
+	stdioWriter *bufio.Writer
+	framing     *stdioFraming
+	writerMu    sync.Mutex
+	wg          sync.WaitGroup
+
+	// batches merges the responses to client batches.
+	batches batchResponses
+
+	// output queues messages for the client while Start serves stdio.
+	output outputQueue
+
+	// strict rejects client messages that are not valid JSON-RPC 2.0.
+	strict bool
+
+	mu             sync.Mutex
+	nextID         int64
+	pending        map[pendingKey]*pendingCall
+	serverRequests map[string]serverRequest
+	resourceOwners map[string]*upstream
+	templateOwners map[string]*upstream
+}
+
+// upstream is a connected remote server and the Proxy that talks to it.
+type upstream struct {
+	name      string
+	proxy     *Proxy
+	connected bool
+}
+
+// pendingKey identifies a request the aggregator sent to an upstream.
+type pendingKey struct {
+	server string
+	id     string
+}
+
+// pendingCall is the continuation for an outstanding upstream request.
+type pendingCall struct {
+	clientID   json.RawMessage
+	onResponse func(resp *rpcMessage)
+	timer      *time.Timer
+}
+
+// serverRequest remembers where a server-initiated request came from so the
+// client's response can be routed back with the original ID.
+type serverRequest struct {
+	server *upstream
+	id     json.RawMessage
+}
+
+// upstreamResult pairs an upstream with its response to a fan-out request.
+type upstreamResult struct {
+	server *upstream
+	resp   *rpcMessage
+}
+
+// NewAggregator creates an aggregator with one Proxy per upstream. Options
+// are applied to every upstream.
+func NewAggregator(cfg AggregatorConfig, opts ...Option) (*Aggregator, error) {
+	if len(cfg.Upstreams) == 0 {
+		return nil, errors.New("at least one upstream server is required")
+	}
+
+	o := options{outputQueue: DefaultOutputQueue}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	framing, err := newStdioFraming(o.stdioFraming, o.maxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Aggregator{
+		byName:         make(map[string]*upstream),
+		version:        cfg.Version,
+		ctx:            ctx,
+		cancel:         cancel,
+		stdioReader:    bufio.NewReader(os.Stdin),
+		stdioWriter:    bufio.NewWriter(os.Stdout),
+		framing:        framing,
+		output:         outputQueue{size: o.outputQueue},
+		strict:         o.strict,
+		pending:        make(map[pendingKey]*pendingCall),
+		serverRequests: make(map[string]serverRequest),
+		resourceOwners: make(map[string]*upstream),
+		templateOwners: make(map[string]*upstream),
+	}
+
+	for _, u := range cfg.Upstreams {
+		if !upstreamNamePattern.MatchString(u.Name) {
+			cancel()
+			return nil, fmt.Errorf("invalid server name %q: use letters, digits, '-' or '_'", u.Name)
+		}
+		if _, dup := a.byName[u.Name]; dup {
+			cancel()
+			return nil, fmt.Errorf("duplicate server name %q", u.Name)
+		}
+
+		upOpts := append(append([]Option(nil), opts...), WithScopes(u.Scopes))
+		p, err := NewProxyWithOptions(u.ServerURL, cfg.CallbackPort, u.Headers, u.ServerURLHash, cfg.Mode, cfg.HTTPProxyURL, upOpts...)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("server %q: %w", u.Name, err)
+		}
+
+		p.name = u.Name
+		if p.tools != nil {
+			p.tools.namespace = u.Name
+		}
+		up := &upstream{name: u.Name, proxy: p}
+		p.messageSink = func(data []byte) { a.handleUpstreamMessage(up, data) }
+		a.upstreams = append(a.upstreams, up)
+		a.byName[u.Name] = up
+	}
+
+	return a, nil
+}
+
+// Start connects to every upstream and then serves stdio until input closes.
+// Upstreams that fail to connect are skipped; Start fails only when none
+// could be reached.
+func (a *Aggregator) Start() error {
+	slog.Info("starting MCP aggregator", "servers", len(a.upstreams))
+
+	connected := 0
+	for _, u := range a.upstreams {
+		slog.Info("connecting to remote server", "name", u.name, "server", u.proxy.serverURL)
+		if err := u.proxy.connectToServer(); err != nil {
+			slog.Warn("failed to connect to server", "name", u.name, "error", err)
+			continue
+		}
+		u.proxy.startTokenRefresher()
+		a.setConnected(u, true)
+		connected++
+	}
+	if connected == 0 {
+		return errors.New("failed to connect to any server")
+	}
+
+	a.output.start(a.writeMessage)
+	a.wg.Add(1)
+	go a.processStdioInput()
+
+	a.wg.Wait()
+	a.output.stop()
+	return nil
+}
+
+// Shutdown gracefully stops the aggregator and every upstream proxy.
+func (a *Aggregator) Shutdown() {
+	slog.Info("shutting down aggregator")
+
+	// Drain every upstream at once so the timeout applies to the whole
+	// shutdown, not to each server in turn.
+	var drained sync.WaitGroup
+	for _, u := range a.upstreams {
+		drained.Add(1)
+		go func() {
+			defer drained.Done()
+			u.proxy.drain()
+		}()
+	}
+	drained.Wait()
+
+	for _, u := range a.upstreams {
+		u.proxy.Shutdown()
+	}
+	a.cancel()
+	a.wg.Wait()
+	a.output.stop()
+}
+
+// SetStdio replaces the stdio reader and writer (for testing).
+func (a *Aggregator) SetStdio(reader *bufio.Reader, writer *bufio.Writer) {
+	a.stdioReader = reader
+	a.stdioWriter = writer
+}
+
+func (a *Aggregator) setConnected(u *upstream, connected bool) {
+	a.mu.Lock()
+	u.connected = connected
+	a.mu.Unlock()
+}
+
+// active returns the upstreams that are currently usable, in configured order.
+func (a *Aggregator) active() []*upstream {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []*upstream
+	for _, u := range a.upstreams {
+		if u.connected {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// processStdioInput reads client messages from stdin and dispatches them.
+func (a *Aggregator) processStdioInput() {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		default:
+			line, err := a.framing.readMessage(a.stdioReader)
+			if len(line) > 0 {
+				a.handleClientMessage(line)
+			}
+			if err != nil {
+				if err == io.EOF {
+					slog.Info("STDIO input closed")
+					for _, u := range a.upstreams {
+						if t := u.proxy.currentTransport(); t != nil {
+							if closeErr := t.Close(); closeErr != nil {
+								slog.Warn("failed to close transport", "name", u.name, "error", closeErr)
+							}
+						}
+						u.proxy.revokeTokens()
+						u.proxy.cancel()
+					}
+					a.cancel()
+					return
+				}
+				slog.Error("failed to read from STDIO", "error", err)
+			}
+		}
+	}
+}
+
+// handleClientMessage dispatches one JSON-RPC message from the client.
+func (a *Aggregator) handleClientMessage(line []byte) {
+	if elements, ok := splitBatch(line); ok {
+		a.handleClientBatch(elements)
+		return
+	}
+	if a.strict {
+		if err := validateMessage(line); err != nil {
+			slog.Warn("rejecting invalid message from client", "error", err)
+			a.writeToStdout(invalidMessageResponse(line, err))
+			return
+		}
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		a.writeToStdout(newErrorMessage(nil, jsonRPCParseError, "parse error"))
+		return
+	}
+
+	switch {
+	case msg.isRequest():
+		slog.Debug("local to remote", "method", msg.Method)
+		a.handleClientRequest(&msg)
+	case msg.isNotification():
+		slog.Debug("local to remote", "method", msg.Method)
+		a.handleClientNotification(&msg)
+	case msg.isResponse():
+		a.routeClientResponse(&msg)
+	default:
+		a.writeToStdout(newErrorMessage(msg.ID, jsonRPCInvalidRequest, "invalid request"))
+	}
+}
+
+func (a *Aggregator) handleClientRequest(msg *rpcMessage) {
+	switch msg.Method {
+	case "initialize":
+		a.handleInitialize(msg)
+	case "ping":
+		a.reply(msg.ID, struct{}{})
+	case "tools/list":
+		a.handleList(msg, "tools", namespaceItem)
+	case "prompts/list":
+		a.handleList(msg, "prompts", namespaceItem)
+	case "resources/list":
+		a.handleList(msg, "resources", a.recordResource)
+	case "resources/templates/list":
+		a.handleList(msg, "resourceTemplates", a.recordTemplate)
+	case "tools/call", "prompts/get":
+		a.routeByName(msg)
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+		a.routeByURI(msg)
+	case "completion/complete":
+		a.routeCompletion(msg)
+	case "logging/setLevel":
+		a.broadcastRequest(msg)
+	default:
+		a.writeToStdout(newErrorMessage(msg.ID, jsonRPCMethodNotFound,
+			fmt.Sprintf("method %q is not supported in aggregation mode", msg.Method)))
+	}
+}
+
+func (a *Aggregator) handleClientNotification(msg *rpcMessage) {
+	if msg.Method == "notifications/cancelled" {
+		a.forwardCancellation(msg)
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Warn("failed to encode notification", "error", err)
+		return
+	}
+	for _, u := range a.active() {
+		a.sendRaw(u, data)
+	}
+}
+
+// forwardCancellation translates the client's request ID into the upstream
+// request IDs it was fanned out to.
+func (a *Aggregator) forwardCancellation(msg *rpcMessage) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+	clientID := params["requestId"]
+	if merged := a.batches.cancel(string(clientID)); merged != nil {
+		a.writeToStdout(merged)
+	}
+
+	type target struct {
+		server *upstream
+		id     string
+	}
+	var targets []target
+	a.mu.Lock()
+	for key, call := range a.pending {
+		if bytes.Equal(call.clientID, clientID) {
+			if call.timer != nil {
+				call.timer.Stop()
+			}
+			delete(a.pending, key)
+			targets = append(targets, target{a.byName[key.server], key.id})
+		}
+	}
+	a.mu.Unlock()
+
+	for _, tgt := range targets {
+		params["requestId"] = json.RawMessage(tgt.id)
+		raw, _ := json.Marshal(params)
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: msg.Method, Params: raw})
+		a.sendRaw(tgt.server, data)
+	}
+}
+
+// routeClientResponse returns the client's answer to a server-initiated
+// request (e.g. sampling/createMessage) to the upstream that asked.
+func (a *Aggregator) routeClientResponse(msg *rpcMessage) {
+	a.mu.Lock()
+	req, ok := a.serverRequests[string(msg.ID)]
+	delete(a.serverRequests, string(msg.ID))
+	a.mu.Unlock()
+	if !ok {
+		slog.Warn("dropping response with unknown ID", "id", string(msg.ID))
+		return
+	}
+
+	msg.ID = req.id
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Warn("failed to encode response", "error", err)
+		return
+	}
+	a.sendRaw(req.server, data)
+}
+
+// handleUpstreamMessage processes a message received from one upstream.
+func (a *Aggregator) handleUpstreamMessage(u *upstream, data []byte) {
+	if elements, ok := splitBatch(data); ok {
+		for _, element := range elements {
+			a.handleUpstreamMessage(u, element)
+		}
+		return
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		slog.Warn("dropping invalid message", "name", u.name, "error", err)
+		return
+	}
+
+	switch {
+	case msg.isResponse():
+		key := pendingKey{server: u.name, id: string(msg.ID)}
+		a.mu.Lock()
+		call := a.pending[key]
+		delete(a.pending, key)
+		a.mu.Unlock()
+		if call == nil {
+			slog.Warn("unexpected response ID", "id", string(msg.ID), "name", u.name)
+			return
+		}
+		if call.timer != nil {
+			call.timer.Stop()
+		}
+		call.onResponse(&msg)
+
+	case msg.isRequest():
+		a.mu.Lock()
+		a.nextID++
+		aggID, _ := json.Marshal(u.name + "-" + strconv.FormatInt(a.nextID, 10))
+		a.serverRequests[string(aggID)] = serverRequest{server: u, id: msg.ID}
+		a.mu.Unlock()
+
+		msg.ID = aggID
+		out, err := json.Marshal(msg)
+		if err != nil {
+			slog.Warn("failed to encode request", "name", u.name, "error", err)
+			return
+		}
+		a.writeToStdout(out)
+
+	default:
+		a.writeToStdout(data)
+	}
+}
+
+// call sends a request to u and invokes onResponse with its response. A
+// non-zero timeout produces a synthetic error response if the upstream does
+// not answer in time. Send failures are reported the same way.
+func (a *Aggregator) call(u *upstream, method string, params json.RawMessage, clientID json.RawMessage, timeout time.Duration, onResponse func(*rpcMessage)) {
+	a.mu.Lock()
+	a.nextID++
+	id := strconv.FormatInt(a.nextID, 10)
+	key := pendingKey{server: u.name, id: id}
+	pc := &pendingCall{clientID: clientID, onResponse: onResponse}
+	a.pending[key] = pc
+	if timeout > 0 {
+		pc.timer = time.AfterFunc(timeout, func() {
+			if a.takePending(key) != nil {
+				onResponse(errorResponse(jsonRPCInternalError, fmt.Sprintf("server %q did not respond within %s", u.name, timeout)))
+			}
+		})
+	}
+	a.mu.Unlock()
+
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: params})
+	if err == nil {
+		err = u.proxy.Send(u.proxy.ctx, data)
+	}
+	if err != nil {
+		if call := a.takePending(key); call != nil {
+			if call.timer != nil {
+				call.timer.Stop()
+			}
+			onResponse(errorResponse(jsonRPCInternalError, fmt.Sprintf("server %q: %v", u.name, err)))
+		}
+	}
+}
+
+func (a *Aggregator) takePending(key pendingKey) *pendingCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	call := a.pending[key]
+	delete(a.pending, key)
+	return call
+}
+
+// sendRaw sends a message that expects no response, logging failures.
+func (a *Aggregator) sendRaw(u *upstream, data []byte) {
+	if err := u.proxy.Send(u.proxy.ctx, data); err != nil {
+		slog.Error("failed to send to server", "name", u.name, "error", err)
+	}
+}
+
+// fanOut sends the same request to every active upstream and calls done once
+// all of them have answered or timed out.
+func (a *Aggregator) fanOut(clientID json.RawMessage, method string, params json.RawMessage, done func([]upstreamResult)) {
+	servers := a.active()
+	if len(servers) == 0 {
+		done(nil)
+		return
+	}
+
+	results := make([]upstreamResult, len(servers))
+	var mu sync.Mutex
+	remaining := len(servers)
+	for i, u := range servers {
+		a.call(u, method, params, clientID, aggregateRequestTimeout, func(resp *rpcMessage) {
+			mu.Lock()
+			results[i] = upstreamResult{server: u, resp: resp}
+			remaining--
+			finished := remaining == 0
+			mu.Unlock()
+			if finished {
+				done(results)
+			}
+		})
+	}
+}
+
+// handleInitialize initializes every upstream with the client's parameters
+// and answers with the union of their capabilities.
+func (a *Aggregator) handleInitialize(msg *rpcMessage) {
+	a.fanOut(msg.ID, msg.Method, msg.Params, func(results []upstreamResult) {
+		var (
+			protocolVersion string
+			capabilities    = map[string]interface{}{}
+			instructions    []string
+			firstErr        *rpcError
+		)
+
+		for _, r := range results {
+			if r.resp.Error != nil {
+				slog.Warn("server failed to initialize", "name", r.server.name, "error", r.resp.Error.Message)
+				a.setConnected(r.server, false)
+				if firstErr == nil {
+					firstErr = r.resp.Error
+				}
+				continue
+			}
+
+			var result struct {
+				ProtocolVersion string                 `json:"protocolVersion"`
+				Capabilities    map[string]interface{} `json:"capabilities"`
+				Instructions    string                 `json:"instructions"`
+			}
+			if err := json.Unmarshal(r.resp.Result, &result); err != nil {
+				slog.Warn("server returned an invalid initialize result", "name", r.server.name, "error", err)
+				a.setConnected(r.server, false)
+				continue
+			}
+
+			if protocolVersion == "" {
+				protocolVersion = result.ProtocolVersion
+			} else if result.ProtocolVersion != protocolVersion {
+				slog.Warn("server negotiated a different protocol version", "name", r.server.name, "version", result.ProtocolVersion, "expected", protocolVersion)
+			}
+			mergeCapabilities(capabilities, result.Capabilities)
+			if result.Instructions != "" {
+				instructions = append(instructions, fmt.Sprintf("[%s] %s", r.server.name, result.Instructions))
+			}
+		}
+
+		if protocolVersion == "" {
+			if firstErr == nil {
+				firstErr = &rpcError{Code: jsonRPCInternalError, Message: "no upstream server initialized"}
+			}
+			a.writeToStdout(mustMarshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: firstErr}))
+			return
+		}
+
+		result := map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities":    capabilities,
+			"serverInfo": map[string]string{
+				"name":    aggregatorServerName,
+				"version": a.version,
+			},
+		}
+		if len(instructions) > 0 {
+			result["instructions"] = strings.Join(instructions, "\n\n")
+		}
+		a.reply(msg.ID, result)
+	})
+}
+
+// mergeCapabilities adds src into dst. Capability objects are merged key by
+// key and boolean flags such as listChanged are true if any server sets them.
+func mergeCapabilities(dst, src map[string]interface{}) {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		dstObj, ok1 := existing.(map[string]interface{})
+		srcObj, ok2 := value.(map[string]interface{})
+		if !ok1 || !ok2 {
+			continue
+		}
+		for k, v := range srcObj {
+			if b, ok := v.(bool); ok {
+				prev, _ := dstObj[k].(bool)
+				dstObj[k] = prev || b
+			} else if _, exists := dstObj[k]; !exists {
+				dstObj[k] = v
+			}
+		}
+	}
+}
+
+// handleList fans a list request out to every upstream, follows pagination
+// on each and returns the merged items in a single page.
+func (a *Aggregator) handleList(msg *rpcMessage, key string, rewrite func(u *upstream, item map[string]interface{})) {
+	servers := a.active()
+	lists := make([][]json.RawMessage, len(servers))
+	errs := make([]*rpcError, len(servers))
+
+	var mu sync.Mutex
+	remaining := len(servers)
+	finish := func() {
+		items := []json.RawMessage{}
+		var firstErr *rpcError
+		for i, u := range servers {
+			if errs[i] != nil {
+				slog.Warn("request failed on server", "method", msg.Method, "name", u.name, "error", errs[i].Message)
+				if firstErr == nil {
+					firstErr = errs[i]
+				}
+				continue
+			}
+			for _, raw := range lists[i] {
+				var item map[string]interface{}
+				if err := json.Unmarshal(raw, &item); err != nil {
+					continue
+				}
+				rewrite(u, item)
+				items = append(items, mustMarshal(item))
+			}
+		}
+		if len(items) == 0 && firstErr != nil {
+			a.writeToStdout(mustMarshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: firstErr}))
+			return
+		}
+		a.reply(msg.ID, map[string]interface{}{key: items})
+	}
+
+	if remaining == 0 {
+		finish()
+		return
+	}
+	for i, u := range servers {
+		a.fetchList(u, msg.ID, msg.Method, key, func(items []json.RawMessage, err *rpcError) {
+			mu.Lock()
+			lists[i], errs[i] = items, err
+			remaining--
+			finished := remaining == 0
+			mu.Unlock()
+			if finished {
+				finish()
+			}
+		})
+	}
+}
+
+// fetchList retrieves every page of a list method from u.
+func (a *Aggregator) fetchList(u *upstream, clientID json.RawMessage, method, key string, done func([]json.RawMessage, *rpcError)) {
+	var items []json.RawMessage
+	var fetch func(cursor string, page int)
+	fetch = func(cursor string, page int) {
+		var params json.RawMessage
+		if cursor != "" {
+			params = mustMarshal(map[string]string{"cursor": cursor})
+		}
+		a.call(u, method, params, clientID, aggregateRequestTimeout, func(resp *rpcMessage) {
+			if resp.Error != nil {
+				done(nil, resp.Error)
+				return
+			}
+			var result map[string]json.RawMessage
+			if err := json.Unmarshal(resp.Result, &result); err != nil {
+				done(nil, &rpcError{Code: jsonRPCInternalError, Message: fmt.Sprintf("invalid %s result: %v", method, err)})
+				return
+			}
+			var pageItems []json.RawMessage
+			_ = json.Unmarshal(result[key], &pageItems)
+			items = append(items, pageItems...)
+
+			var next string
+			_ = json.Unmarshal(result["nextCursor"], &next)
+			if next != "" {
+				if page+1 < aggregateMaxListPages {
+					fetch(next, page+1)
+					return
+				}
+				slog.Warn("list exceeded page limit, truncating", "method", method, "name", u.name, "max_pages", aggregateMaxListPages)
+			}
+			done(items, nil)
+		})
+	}
+	fetch("", 0)
+}
+
+// namespaceItem prefixes a tool or prompt name with the server name.
+func namespaceItem(u *upstream, item map[string]interface{}) {
+	if name, ok := item["name"].(string); ok {
+		item["name"] = u.name + NamespaceSeparator + name
+	}
+}
+
+// recordResource remembers which server owns a resource URI.
+func (a *Aggregator) recordResource(u *upstream, item map[string]interface{}) {
+	if uri, ok := item["uri"].(string); ok {
+		a.mu.Lock()
+		a.resourceOwners[uri] = u
+		a.mu.Unlock()
+	}
+	namespaceItem(u, item)
+}
+
+// recordTemplate remembers which server owns a resource template, keyed by
+// the literal prefix before its first expression.
+func (a *Aggregator) recordTemplate(u *upstream, item map[string]interface{}) {
+	if tmpl, ok := item["uriTemplate"].(string); ok {
+		prefix, _, _ := strings.Cut(tmpl, "{")
+		a.mu.Lock()
+		a.templateOwners[prefix] = u
+		a.mu.Unlock()
+	}
+	namespaceItem(u, item)
+}
+
+// splitNamespaced splits "server.name" into its upstream and local name.
+func (a *Aggregator) splitNamespaced(name string) (*upstream, string, bool) {
+	server, local, ok := strings.Cut(name, NamespaceSeparator)
+	if !ok || local == "" {
+		return nil, "", false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.byName[server]
+	if !ok || !u.connected {
+		return nil, "", false
+	}
+	return u, local, true
+}
+
+// resourceOwner finds the upstream serving uri, preferring an exact match from
+// resources/list over the longest matching template prefix.
+func (a *Aggregator) resourceOwner(uri string) *upstream {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if u, ok := a.resourceOwners[uri]; ok {
+		return u
+	}
+	var best *upstream
+	bestLen := -1
+	for prefix, u := range a.templateOwners {
+		if strings.HasPrefix(uri, prefix) && len(prefix) > bestLen {
+			best, bestLen = u, len(prefix)
+		}
+	}
+	return best
+}
+
+// routeByName forwards tools/call and prompts/get to the server named by the
+// namespaced "name" parameter, stripping the namespace.
+func (a *Aggregator) routeByName(msg *rpcMessage) {
+	var params map[string]json.RawMessage
+	var name string
+	if err := json.Unmarshal(msg.Params, &params); err == nil {
+		_ = json.Unmarshal(params["name"], &name)
+	}
+	u, local, ok := a.splitNamespaced(name)
+	if !ok {
+		a.writeToStdout(newErrorMessage(msg.ID, jsonRPCInvalidParams, fmt.Sprintf("unknown name %q: expected <server>%s<name>", name, NamespaceSeparator)))
+		return
+	}
+	params["name"] = mustMarshal(local)
+	a.forward(u, msg, mustMarshal(params))
+}
+
+// routeByURI forwards resource requests to the server that owns the URI.
+func (a *Aggregator) routeByURI(msg *rpcMessage) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	_ = json.Unmarshal(msg.Params, &params)
+	u := a.resourceOwner(params.URI)
+	if u == nil {
+		a.writeToStdout(newErrorMessage(msg.ID, jsonRPCInvalidParams, fmt.Sprintf("unknown resource URI %q", params.URI)))
+		return
+	}
+	a.forward(u, msg, msg.Params)
+}
+
+// routeCompletion forwards completion/complete based on its prompt or
+// resource reference.
+func (a *Aggregator) routeCompletion(msg *rpcMessage) {
+	var params map[string]json.RawMessage
+	var ref map[string]interface{}
+	if err := json.Unmarshal(msg.Params, &params); err == nil {
+		_ = json.Unmarshal(params["ref"], &ref)
+	}
+
+	var u *upstream
+	switch ref["type"] {
+	case "ref/prompt":
+		name, _ := ref["name"].(string)
+		if owner, local, ok := a.splitNamespaced(name); ok {
+			u = owner
+			ref["name"] = local
+			params["ref"] = mustMarshal(ref)
+		}
+	case "ref/resource":
+		uri, _ := ref["uri"].(string)
+		u = a.resourceOwner(uri)
+	}
+	if u == nil {
+		a.writeToStdout(newErrorMessage(msg.ID, jsonRPCInvalidParams, "unknown completion reference"))
+		return
+	}
+	a.forward(u, msg, mustMarshal(params))
+}
+
+// broadcastRequest sends a request to every upstream and answers with an
+// empty result once all have responded.
+func (a *Aggregator) broadcastRequest(msg *rpcMessage) {
+	a.fanOut(msg.ID, msg.Method, msg.Params, func(results []upstreamResult) {
+		var firstErr *rpcError
+		ok := 0
+		for _, r := range results {
+			if r.resp.Error != nil {
+				slog.Warn("request failed on server", "method", msg.Method, "name", r.server.name, "error", r.resp.Error.Message)
+				if firstErr == nil {
+					firstErr = r.resp.Error
+				}
+				continue
+			}
+			ok++
+		}
+		if ok == 0 && firstErr != nil {
+			a.writeToStdout(mustMarshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: firstErr}))
+			return
+		}
+		a.reply(msg.ID, struct{}{})
+	})
+}
+
+// forward sends msg to u with params and relays the response unchanged apart
+// from restoring the client's request ID.
+func (a *Aggregator) forward(u *upstream, msg *rpcMessage, params json.RawMessage) {
+	a.call(u, msg.Method, params, msg.ID, 0, func(resp *rpcMessage) {
+		a.writeToStdout(mustMarshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: resp.Result, Error: resp.Error}))
+	})
+}
+
+func (a *Aggregator) reply(id json.RawMessage, result interface{}) {
+	data, err := newResultMessage(id, result)
+	if err != nil {
+		a.writeToStdout(newErrorMessage(id, jsonRPCInternalError, err.Error()))
+		return
+	}
+	a.writeToStdout(data)
+}
+
+// writeToStdout queues one message for stdout. Responses to a client batch
+// are held back until the batch is complete.
+func (a *Aggregator) writeToStdout(data []byte) {
+	if data = a.batches.collect(data); data == nil {
+		return
+	}
+	_ = a.output.push(data, a.writeMessage)
+}
+
+// writeMessage safely writes one framed message to stdout.
+func (a *Aggregator) writeMessage(data []byte) {
+	a.writerMu.Lock()
+	defer a.writerMu.Unlock()
+
+	if err := a.framing.writeMessage(a.stdioWriter, data); err != nil {
+		slog.Error("failed to write to STDIO", "error", err)
+	}
+}
+
+func errorResponse(code int, message string) *rpcMessage {
+	return &rpcMessage{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: message}}
+}
+
+// mustMarshal encodes values that are known to be serializable.
+func mustMarshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("proxy: marshal %T: %v", v, err))
+	}
+	return data
+}
@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAggregateMockServer returns a Streamable HTTP server that exposes one
+// tool and one resource, and echoes its name from tools/call.
+// When paginate is set, tools/list is split across two pages. The server is
+// closed on test cleanup.
+func newAggregateMockServer(t *testing.T, name string, paginate bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]interface{}
+		_ = json.Unmarshal(body, &msg)
+		params, _ := msg["params"].(map[string]interface{})
+
+		var result interface{}
+		switch msg["method"] {
+		case "ping":
+			result = map[string]interface{}{}
+		case "initialize":
+			result = map[string]interface{}{
+				"protocolVersion": MCPProtocolVersion,
+				"capabilities": map[string]interface{}{
+					"tools": map[string]interface{}{"listChanged": name == "beta"},
+				},
+				"serverInfo": map[string]string{"name": name, "version": "1.0.0"},
+			}
+		case "tools/list":
+			tools := []map[string]interface{}{{"name": "search"}}
+			page := map[string]interface{}{"tools": tools}
+			if paginate {
+				if params["cursor"] == "page2" {
+					page["tools"] = []map[string]interface{}{{"name": "create"}}
+				} else {
+					page["nextCursor"] = "page2"
+				}
+			}
+			result = page
+		case "resources/list":
+			result = map[string]interface{}{
+				"resources": []map[string]interface{}{{"uri": "file:///" + name + "/readme", "name": "readme"}},
+			}
+		case "resources/read":
+			result = map[string]interface{}{
+				"contents": []map[string]interface{}{{"uri": params["uri"], "text": name}},
+			}
+		case "tools/call":
+			result = map[string]interface{}{
+				"content": []map[string]interface{}{{"type": "text", "text": name + ":" + params["name"].(string)}},
+			}
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      msg["id"],
+			"result":  result,
+		})
+	}))
+	// Registered before the aggregator's cleanup so the server outlives it.
+	t.Cleanup(server.Close)
+	return server
+}
+
+func startTestAggregator(t *testing.T, upstreams []Upstream) (*Aggregator, io.WriteCloser, *safeBuffer) {
+	t.Helper()
+	agg, err := NewAggregator(AggregatorConfig{
+		Upstreams: upstreams,
+		Mode:      TransportModeStreamableHTTP,
+		Version:   "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create aggregator: %v", err)
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	var stdout safeBuffer
+	agg.SetStdio(bufio.NewReader(stdinReader), bufio.NewWriter(&stdout))
+
+	go func() { _ = agg.Start() }()
+	t.Cleanup(func() {
+		_ = stdinWriter.Close()
+		agg.Shutdown()
+	})
+	return agg, stdinWriter, &stdout
+}
+
+func TestAggregatorMergesAndRoutes(t *testing.T) {
+	alpha := newAggregateMockServer(t, "alpha", true)
+	beta := newAggregateMockServer(t, "beta", false)
+
+	_, stdin, stdout := startTestAggregator(t, []Upstream{
+		{Name: "alpha", ServerURL: alpha.URL, ServerURLHash: "agg-alpha"},
+		{Name: "beta", ServerURL: beta.URL, ServerURLHash: "agg-beta"},
+	})
+
+	writeJSON(t, stdin, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "initialize",
+		"params": map[string]interface{}{
+			"protocolVersion": MCPProtocolVersion,
+			"capabilities":    map[string]interface{}{},
+			"clientInfo":      map[string]string{"name": "test-client", "version": "1.0.0"},
+		},
+	})
+	initResp := readJSONResponse(t, stdout, 2*time.Second)
+	if initResp == nil {
+		t.Fatal("Expected initialize response")
+	}
+	result, _ := initResp["result"].(map[string]interface{})
+	caps, _ := result["capabilities"].(map[string]interface{})
+	tools, _ := caps["tools"].(map[string]interface{})
+	if tools["listChanged"] != true {
+		t.Errorf("Expected merged tools.listChanged=true, got %v", caps["tools"])
+	}
+	serverInfo, _ := result["serverInfo"].(map[string]interface{})
+	if serverInfo["name"] != aggregatorServerName {
+		t.Errorf("Expected serverInfo name %q, got %v", aggregatorServerName, serverInfo["name"])
+	}
+
+	writeJSON(t, stdin, map[string]interface{}{"jsonrpc": "2.0", "id": 2, "method": "tools/list"})
+	listResp := readJSONResponse(t, stdout, 2*time.Second)
+	if listResp == nil {
+		t.Fatal("Expected tools/list response")
+	}
+	if listResp["id"] != float64(2) {
+		t.Errorf("Expected id 2, got %v", listResp["id"])
+	}
+	listResult, _ := listResp["result"].(map[string]interface{})
+	var names []string
+	for _, tool := range listResult["tools"].([]interface{}) {
+		names = append(names, tool.(map[string]interface{})["name"].(string))
+	}
+	if got := strings.Join(names, ","); got != "alpha.search,alpha.create,beta.search" {
+		t.Errorf("Unexpected merged tool names: %s", got)
+	}
+	if _, ok := listResult["nextCursor"]; ok {
+		t.Error("Expected merged list to have no nextCursor")
+	}
+
+	writeJSON(t, stdin, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "call-1",
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": "beta.search", "arguments": map[string]interface{}{}},
+	})
+	callResp := readJSONResponse(t, stdout, 2*time.Second)
+	if callResp == nil {
+		t.Fatal("Expected tools/call response")
+	}
+	if callResp["id"] != "call-1" {
+		t.Errorf("Expected id call-1, got %v", callResp["id"])
+	}
+	content := callResp["result"].(map[string]interface{})["content"].([]interface{})
+	if text := content[0].(map[string]interface{})["text"]; text != "beta:search" {
+		t.Errorf("Expected call routed to beta with stripped name, got %v", text)
+	}
+
+	writeJSON(t, stdin, map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "resources/list"})
+	if resp := readJSONResponse(t, stdout, 2*time.Second); resp == nil {
+		t.Fatal("Expected resources/list response")
+	}
+	writeJSON(t, stdin, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      4,
+		"method":  "resources/read",
+		"params":  map[string]interface{}{"uri": "file:///alpha/readme"},
+	})
+	readResp := readJSONResponse(t, stdout, 2*time.Second)
+	if readResp == nil {
+		t.Fatal("Expected resources/read response")
+	}
+	contents := readResp["result"].(map[string]interface{})["contents"].([]interface{})
+	if text := contents[0].(map[string]interface{})["text"]; text != "alpha" {
+		t.Errorf("Expected resource read routed to alpha, got %v", text)
+	}
+}
+
+func TestAggregatorUnknownNamespace(t *testing.T) {
+	alpha := newAggregateMockServer(t, "alpha", false)
+
+	_, stdin, stdout := startTestAggregator(t, []Upstream{
+		{Name: "alpha", ServerURL: alpha.URL, ServerURLHash: "agg-unknown"},
+	})
+
+	writeJSON(t, stdin, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      7,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": "gamma.search"},
+	})
+	resp := readJSONResponse(t, stdout, 2*time.Second)
+	if resp == nil {
+		t.Fatal("Expected error response")
+	}
+	errObj, ok := resp["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected error, got %v", resp)
+	}
+	if errObj["code"] != float64(jsonRPCInvalidParams) {
+		t.Errorf("Expected invalid params code, got %v", errObj["code"])
+	}
+}
+
+func TestNewAggregatorValidatesNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		upstreams []Upstream
+	}{
+		{name: "empty", upstreams: nil},
+		{name: "dot in name", upstreams: []Upstream{{Name: "a.b", ServerURL: "https://a.example.com"}}},
+		{name: "duplicate", upstreams: []Upstream{
+			{Name: "a", ServerURL: "https://a.example.com", ServerURLHash: "dup-a"},
+			{Name: "a", ServerURL: "https://b.example.com", ServerURLHash: "dup-b"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAggregator(AggregatorConfig{Upstreams: tt.upstreams}); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestMergeCapabilities(t *testing.T) {
+	dst := map[string]interface{}{
+		"tools": map[string]interface{}{"listChanged": false},
+	}
+	mergeCapabilities(dst, map[string]interface{}{
+		"tools":     map[string]interface{}{"listChanged": true},
+		"resources": map[string]interface{}{"subscribe": true},
+	})
+
+	if dst["tools"].(map[string]interface{})["listChanged"] != true {
+		t.Errorf("Expected listChanged to be OR-ed, got %v", dst["tools"])
+	}
+	if _, ok := dst["resources"]; !ok {
+		t.Error("Expected resources capability to be added")
+	}
+}
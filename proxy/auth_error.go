@@ -3,7 +3,7 @@ package proxy
 import (
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/naotama2002/mcp-remote-go/auth"
@@ -14,9 +14,9 @@ import (
 // (RFC 9728 §5.1).
 const HeaderWWWAuthenticate = "WWW-Authenticate"
 
-// UnauthorizedError signals an HTTP 401 from the MCP server and carries the
-// WWW-Authenticate header so callers can locate the Protected Resource
-// Metadata document per RFC 9728 §5.1.
+// UnauthorizedError signals an HTTP 401, or a 403 with a Bearer challenge,
+// from the MCP server and carries the WWW-Authenticate header so callers can
+// locate the Protected Resource Metadata document per RFC 9728 §5.1.
 type UnauthorizedError struct {
 	StatusCode      int
 	WWWAuthenticate string
@@ -24,9 +24,9 @@ type UnauthorizedError struct {
 
 func (e *UnauthorizedError) Error() string {
 	if e.WWWAuthenticate != "" {
-		return fmt.Sprintf("server returned %d Unauthorized (WWW-Authenticate: %s)", e.StatusCode, e.WWWAuthenticate)
+		return fmt.Sprintf("server returned %d %s (WWW-Authenticate: %s)", e.StatusCode, http.StatusText(e.StatusCode), e.WWWAuthenticate)
 	}
-	return fmt.Sprintf("server returned %d Unauthorized", e.StatusCode)
+	return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
 }
 
 // unauthorizedFromResponse drains and closes the response body, then returns
@@ -34,10 +34,24 @@ func (e *UnauthorizedError) Error() string {
 func unauthorizedFromResponse(resp *http.Response) *UnauthorizedError {
 	_, _ = io.Copy(io.Discard, resp.Body)
 	if err := resp.Body.Close(); err != nil {
-		log.Printf("Warning: failed to close response body: %v", err)
+		slog.Warn("failed to close response body", "error", err)
 	}
 	return &UnauthorizedError{
 		StatusCode:      resp.StatusCode,
 		WWWAuthenticate: auth.BestWWWAuthenticateHeader(resp.Header.Values(HeaderWWWAuthenticate)),
 	}
 }
+
+// isAuthChallenge reports whether resp asks for new credentials: a 401, or a
+// 403 carrying a WWW-Authenticate challenge such as insufficient_scope
+// (RFC 6750 §3.1). A bare 403 is a permission denial that new tokens would
+// not fix.
+func isAuthChallenge(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get(HeaderWWWAuthenticate) != ""
+	}
+	return false
+}
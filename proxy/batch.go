@@ -0,0 +1,231 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// batchTransport is implemented by transports whose protocol accepts a
+// JSON-RPC batch as a single message. Batches for other transports are split
+// into single messages and the responses merged back into one batch.
+type batchTransport interface {
+	acceptsBatch() bool
+}
+
+// splitBatch returns the elements of a JSON-RPC batch, or false when data is
+// not a JSON array.
+func splitBatch(data []byte) ([][]byte, bool) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return nil, false
+	}
+	elements := make([][]byte, len(raw))
+	for i, element := range raw {
+		elements[i] = element
+	}
+	return elements, true
+}
+
+// joinBatch encodes messages as a JSON-RPC batch.
+func joinBatch(messages [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, message := range messages {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(message)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// batchResponses holds the responses to batches whose requests were
+// answered one by one, so the client receives each batch's responses as one
+// batch, as JSON-RPC requires.
+type batchResponses struct {
+	mu      sync.Mutex
+	pending map[string]*pendingBatch // by request ID
+}
+
+type pendingBatch struct {
+	waiting   int
+	responses [][]byte
+}
+
+// expect registers a batch awaiting the responses to the requests ids.
+// replies are responses the proxy already made to other elements. It returns
+// the merged batch when there is nothing to wait for, or nil.
+func (b *batchResponses) expect(ids []string, replies [][]byte) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := &pendingBatch{responses: replies}
+	for _, id := range ids {
+		if _, ok := b.pending[id]; ok {
+			// A duplicate ID cannot be told apart; its response is
+			// delivered on its own.
+			continue
+		}
+		if b.pending == nil {
+			b.pending = make(map[string]*pendingBatch)
+		}
+		b.pending[id] = batch
+		batch.waiting++
+	}
+	if batch.waiting == 0 && len(replies) > 0 {
+		return joinBatch(replies)
+	}
+	return nil
+}
+
+// collect returns message unless it is a response to a pending batch. The
+// response is then held back, and the merged batch returned once it is
+// complete.
+func (b *batchResponses) collect(message []byte) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		return message
+	}
+
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isResponse() {
+		return message
+	}
+	batch, ok := b.pending[string(msg.ID)]
+	if !ok {
+		return message
+	}
+	batch.responses = append(batch.responses, message)
+	return b.done(string(msg.ID), batch)
+}
+
+// cancel stops waiting for the response to request id, which the client
+// cancelled. It returns the merged batch if that completed it, or nil.
+func (b *batchResponses) cancel(id string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch, ok := b.pending[id]
+	if !ok {
+		return nil
+	}
+	return b.done(id, batch)
+}
+
+func (b *batchResponses) done(id string, batch *pendingBatch) []byte {
+	delete(b.pending, id)
+	batch.waiting--
+	if batch.waiting > 0 || len(batch.responses) == 0 {
+		return nil
+	}
+	return joinBatch(batch.responses)
+}
+
+// sendBatch forwards a batch from the client. Each element passes through
+// the middleware chain on its own. The batch is sent whole when the
+// transport accepts batches and every element was forwarded; otherwise the
+// elements are sent one by one and their responses merged.
+func (p *Proxy) sendBatch(elements [][]byte) {
+	if len(elements) == 0 {
+		p.writeToStdout(newErrorMessage(nil, jsonRPCInvalidRequest, "invalid request: empty batch"))
+		return
+	}
+
+	var forward, replies [][]byte
+	var forwardIDs []json.RawMessage
+	var ids []string
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) != nil || (!msg.isRequest() && !msg.isNotification() && !msg.isResponse()) {
+			replies = append(replies, newErrorMessage(nil, jsonRPCInvalidRequest, "invalid request"))
+			continue
+		}
+		logMessage("local to remote", element)
+		p.releaseCancelled(element)
+
+		out, reply := p.filterOutgoing(element)
+		if reply != nil {
+			replies = append(replies, reply)
+			continue
+		}
+		if out == nil {
+			continue
+		}
+		forward = append(forward, out)
+		if msg.isRequest() {
+			forwardIDs = append(forwardIDs, msg.ID)
+			ids = append(ids, string(msg.ID))
+		} else {
+			forwardIDs = append(forwardIDs, nil)
+		}
+	}
+
+	if bt, ok := p.currentTransport().(batchTransport); ok && bt.acceptsBatch() && len(replies) == 0 {
+		if len(forward) > 0 {
+			_ = p.sendToServer(joinBatch(forward))
+		}
+		return
+	}
+
+	if merged := p.batches.expect(ids, replies); merged != nil {
+		p.writeToStdout(merged)
+	}
+	for i, message := range forward {
+		if err := p.sendToServer(message); err != nil && forwardIDs[i] != nil {
+			p.writeToStdout(newErrorMessage(forwardIDs[i], jsonRPCInternalError, "failed to send to server: "+err.Error()))
+		}
+	}
+}
+
+// releaseCancelled stops a split batch from waiting for the response to a
+// request the client cancelled.
+func (p *Proxy) releaseCancelled(message []byte) {
+	var msg struct {
+		Method string `json:"method"`
+		Params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(message, &msg) != nil || msg.Method != "notifications/cancelled" {
+		return
+	}
+	if merged := p.batches.cancel(string(msg.Params.RequestID)); merged != nil {
+		p.writeToStdout(merged)
+	}
+}
+
+// handleClientBatch dispatches each element of a batch from the client and
+// merges the responses into one batch.
+func (a *Aggregator) handleClientBatch(elements [][]byte) {
+	if len(elements) == 0 {
+		a.writeToStdout(newErrorMessage(nil, jsonRPCInvalidRequest, "invalid request: empty batch"))
+		return
+	}
+
+	var valid, replies [][]byte
+	var ids []string
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) != nil || (!msg.isRequest() && !msg.isNotification() && !msg.isResponse()) {
+			replies = append(replies, newErrorMessage(nil, jsonRPCInvalidRequest, "invalid request"))
+			continue
+		}
+		valid = append(valid, element)
+		if msg.isRequest() {
+			ids = append(ids, string(msg.ID))
+		}
+	}
+
+	if merged := a.batches.expect(ids, replies); merged != nil {
+		a.writeToStdout(merged)
+	}
+	for _, element := range valid {
+		a.handleClientMessage(element)
+	}
+}
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// batchTestTransport records sent messages. batches sets whether it accepts
+// JSON-RPC batches.
+type batchTestTransport struct {
+	batches bool
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (t *batchTestTransport) Connect(context.Context) error     { return nil }
+func (t *batchTestTransport) SetOnMessage(func(string, []byte)) {}
+func (t *batchTestTransport) SetOnError(func(error))            {}
+func (t *batchTestTransport) Close() error                      { return nil }
+func (t *batchTestTransport) SessionID() string                 { return "" }
+func (t *batchTestTransport) acceptsBatch() bool                { return t.batches }
+
+func (t *batchTestTransport) Send(_ context.Context, message []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, string(message))
+	return nil
+}
+
+func newBatchTestProxy(transport Transport) (*Proxy, *bytes.Buffer) {
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	var out bytes.Buffer
+	return &Proxy{
+		ctx:         context.Background(),
+		transport:   transport,
+		framing:     framing,
+		stdioWriter: bufio.NewWriter(&out),
+	}, &out
+}
+
+func TestSplitAndJoinBatch(t *testing.T) {
+	elements, ok := splitBatch([]byte(` [{"id":1}, {"id":2}]`))
+	if !ok || len(elements) != 2 || string(elements[1]) != `{"id":2}` {
+		t.Fatalf("Unexpected split: %q, %v", elements, ok)
+	}
+	if got := string(joinBatch(elements)); got != `[{"id":1},{"id":2}]` {
+		t.Errorf("Expected the elements joined, got %s", got)
+	}
+	for _, input := range []string{`{"id":1}`, `[`, ``} {
+		if _, ok := splitBatch([]byte(input)); ok {
+			t.Errorf("%q: expected no batch", input)
+		}
+	}
+}
+
+func TestProxySplitsBatchAndMergesResponses(t *testing.T) {
+	transport := &batchTestTransport{}
+	p, out := newBatchTestProxy(transport)
+	p.Use(func(direction string, message []byte) ([]byte, error) {
+		if direction == TraceLocalToRemote && strings.Contains(string(message), "blocked") {
+			return nil, &RPCError{Code: jsonRPCInvalidParams, Message: "blocked"}
+		}
+		return message, nil
+	})
+
+	p.sendBatch(splitMessages(t, `[
+		{"jsonrpc":"2.0","id":1,"method":"tools/list"},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":2,"method":"blocked"},
+		{"jsonrpc":"2.0","id":3,"method":"ping"},
+		42
+	]`))
+
+	if len(transport.sent) != 3 || strings.HasPrefix(transport.sent[0], "[") {
+		t.Fatalf("Expected 3 single messages sent, got %q", transport.sent)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("Expected no output before the batch is answered, got %s", out)
+	}
+
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":3,"result":{}}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/progress"}`))
+	if got := out.String(); got != `{"jsonrpc":"2.0","method":"notifications/progress"}`+"\n" {
+		t.Fatalf("Expected only the notification before the batch completes, got %s", got)
+	}
+	out.Reset()
+
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`))
+	var responses []rpcMessage
+	if err := json.Unmarshal(out.Bytes(), &responses); err != nil {
+		t.Fatalf("Expected one merged batch, got %s", out)
+	}
+	ids := map[string]int{}
+	for _, r := range responses {
+		code := 0
+		if r.Error != nil {
+			code = r.Error.Code
+		}
+		ids[string(r.ID)] = code
+	}
+	want := map[string]int{"1": 0, "2": jsonRPCInvalidParams, "3": 0, "null": jsonRPCInvalidRequest}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected responses %v, got %s", want, out)
+	}
+	for id, code := range want {
+		if got, ok := ids[id]; !ok || got != code {
+			t.Errorf("Response %s: expected code %d, got %d (present %v)", id, code, got, ok)
+		}
+	}
+}
+
+func TestProxySendsBatchWhole(t *testing.T) {
+	transport := &batchTestTransport{batches: true}
+	p, out := newBatchTestProxy(transport)
+
+	p.sendBatch(splitMessages(t, `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`))
+	if len(transport.sent) != 1 || !strings.HasPrefix(transport.sent[0], "[") {
+		t.Fatalf("Expected the batch sent as one message, got %q", transport.sent)
+	}
+
+	p.handleServerMessage("message", []byte(`[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","id":2,"result":{}}]`))
+	want := `[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","id":2,"result":{}}]` + "\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+	if n := p.inflight.count(); n != 0 {
+		t.Errorf("Expected batch responses to complete tracked requests, %d left", n)
+	}
+}
+
+func TestProxyBatchCancellationCompletesBatch(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+
+	p.sendBatch(splitMessages(t, `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"tools/call"}]`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	p.releaseCancelled([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":2}}`))
+
+	if got := out.String(); got != `[{"jsonrpc":"2.0","id":1,"result":{}}]`+"\n" {
+		t.Errorf("Expected the batch delivered without the cancelled request, got %s", got)
+	}
+}
+
+func TestProxyEmptyBatch(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+	p.sendBatch(nil)
+
+	var resp rpcMessage
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil || resp.Error == nil || resp.Error.Code != jsonRPCInvalidRequest {
+		t.Errorf("Expected an invalid request error, got %s", out)
+	}
+}
+
+func TestAggregatorBatch(t *testing.T) {
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	var out bytes.Buffer
+	a := &Aggregator{framing: framing, stdioWriter: bufio.NewWriter(&out)}
+
+	a.handleClientMessage([]byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"unknown/method"}]`))
+
+	var responses []rpcMessage
+	if err := json.Unmarshal(out.Bytes(), &responses); err != nil || len(responses) != 2 {
+		t.Fatalf("Expected one batch of two responses, got %s", out.String())
+	}
+	if responses[0].Error != nil || responses[1].Error == nil || responses[1].Error.Code != jsonRPCMethodNotFound {
+		t.Errorf("Unexpected responses %s", out.String())
+	}
+}
+
+func splitMessages(t *testing.T, batch string) [][]byte {
+	t.Helper()
+	elements, ok := splitBatch([]byte(batch))
+	if !ok {
+		t.Fatalf("Invalid batch %s", batch)
+	}
+	return elements
+}
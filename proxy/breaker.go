@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+	"github.com/naotama2002/mcp-remote-go/internal/resilience"
+)
+
+// sendTransport sends message over t unless the circuit breaker is open,
+// recording the outcome in the breaker.
+func (p *Proxy) sendTransport(ctx context.Context, t Transport, message []byte) error {
+	if err := p.breaker.Allow(); err != nil {
+		return err
+	}
+	err := t.Send(ctx, message)
+	p.recordOutcome(err)
+	return err
+}
+
+// recordOutcome counts a failed exchange with the server towards opening
+// the circuit breaker. Rate limits and authorization errors are answers
+// from a working server and count as successes; cancellation counts as
+// neither.
+func (p *Proxy) recordOutcome(err error) {
+	if p.breaker == nil {
+		return
+	}
+	var limited *httpclient.RateLimitError
+	var unauth *UnauthorizedError
+	switch {
+	case err == nil, errors.As(err, &limited), errors.As(err, &unauth):
+		if p.breaker.State() != resilience.Closed {
+			slog.Info("circuit breaker closed")
+		}
+		p.breaker.Success()
+	case errors.Is(err, context.Canceled):
+		p.breaker.Release()
+	default:
+		if p.breaker.Failure() {
+			slog.Warn("circuit breaker opened, rejecting requests to the server", "error", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/resilience"
+)
+
+// failingTransport fails every send while failing is set.
+type failingTransport struct {
+	batchTestTransport
+
+	mu      sync.Mutex
+	failing bool
+	calls   int
+}
+
+func (t *failingTransport) Send(ctx context.Context, message []byte) error {
+	t.mu.Lock()
+	t.calls++
+	failing := t.failing
+	t.mu.Unlock()
+	if failing {
+		return errors.New("server returned error status: 502 - bad gateway")
+	}
+	return t.batchTestTransport.Send(ctx, message)
+}
+
+func TestProxyCircuitBreaker(t *testing.T) {
+	transport := &failingTransport{failing: true}
+	p, _ := newBatchTestProxy(transport)
+	p.breaker = resilience.NewBreaker(2, time.Hour)
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+
+	for i := 0; i < 2; i++ {
+		if err := p.sendToServer(request); err == nil {
+			t.Fatalf("Send %d: expected the transport error", i+1)
+		}
+	}
+	err := p.sendToServer(request)
+	var open *resilience.OpenError
+	if !errors.As(err, &open) {
+		t.Fatalf("Expected the breaker to reject the request, got %v", err)
+	}
+	if transport.calls != 2 {
+		t.Errorf("Expected the open breaker to keep the request from the server, got %d calls", transport.calls)
+	}
+	if st := p.Status(); st.Breaker != "open" {
+		t.Errorf("Expected the status to report the open breaker, got %q", st.Breaker)
+	}
+}
+
+func TestRecordOutcomeIgnoresServerAnswers(t *testing.T) {
+	p, _ := newBatchTestProxy(&batchTestTransport{})
+	p.breaker = resilience.NewBreaker(1, time.Hour)
+
+	p.recordOutcome(&UnauthorizedError{})
+	p.recordOutcome(context.Canceled)
+	if p.breaker.State() != resilience.Closed {
+		t.Fatalf("Expected answers from the server not to open the breaker, got %v", p.breaker.State())
+	}
+	p.recordOutcome(errors.New("POST request failed: connection refused"))
+	if p.breaker.State() != resilience.Open {
+		t.Errorf("Expected a failed request to open the breaker, got %v", p.breaker.State())
+	}
+}
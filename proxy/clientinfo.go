@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// clientInfoRequest appends the proxy and its version to the clientInfo
+// name in an initialize request from the client, e.g. "Claude Desktop (via
+// mcp-remote-go 1.2.0)". The clientInfo version is left alone, as servers
+// may compare it. It returns the message to forward.
+func (p *Proxy) clientInfoRequest(message []byte) []byte {
+	if p.clientVersion == "" {
+		return message
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() || msg.Method != "initialize" {
+		return message
+	}
+	var params map[string]json.RawMessage
+	if json.Unmarshal(msg.Params, &params) != nil || params == nil {
+		return message
+	}
+	var info map[string]json.RawMessage
+	if json.Unmarshal(params["clientInfo"], &info) != nil || info == nil {
+		return message
+	}
+	var name string
+	if json.Unmarshal(info["name"], &name) != nil || strings.Contains(name, "(via mcp-remote-go ") {
+		return message
+	}
+	info["name"], _ = json.Marshal(strings.TrimSpace(name + " (via mcp-remote-go " + p.clientVersion + ")"))
+	params["clientInfo"], _ = json.Marshal(info)
+	msg.Params, _ = json.Marshal(params)
+	rewritten, err := json.Marshal(msg)
+	if err != nil {
+		return message
+	}
+	return rewritten
+}
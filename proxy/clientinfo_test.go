@@ -0,0 +1,29 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientInfoRequest(t *testing.T) {
+	p, _ := newBatchTestProxy(&batchTestTransport{})
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","clientInfo":{"name":"Claude Desktop","version":"0.9.2"}}}`
+
+	if out := p.outgoing([]byte(initialize)); string(out) != initialize {
+		t.Errorf("Expected initialize unchanged without a client version, got %s", out)
+	}
+
+	p.clientVersion = "1.2.0"
+	out := string(p.outgoing([]byte(initialize)))
+	if !strings.Contains(out, `"name":"Claude Desktop (via mcp-remote-go 1.2.0)"`) || !strings.Contains(out, `"version":"0.9.2"`) {
+		t.Errorf("Expected the proxy appended to the client name, got %s", out)
+	}
+	if again := string(p.outgoing([]byte(out))); strings.Count(again, "via mcp-remote-go") != 1 {
+		t.Errorf("Expected the proxy appended once, got %s", again)
+	}
+
+	other := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"clientInfo":{"name":"x"}}}`
+	if out := p.outgoing([]byte(other)); string(out) != other {
+		t.Errorf("Expected other requests unchanged, got %s", out)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/mockserver"
 )
 
 // TestE2EStreamableHTTPAutoNegotiation tests the full pipeline:
@@ -41,8 +43,8 @@ func TestE2EStreamableHTTPAutoNegotiation(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	// Verify auto-negotiation selected Streamable HTTP
-	if proxy.transportMode != TransportModeStreamableHTTP {
-		t.Fatalf("Expected auto-negotiation to select streamable-http, got '%s'", proxy.transportMode)
+	if proxy.currentTransportMode() != TransportModeStreamableHTTP {
+		t.Fatalf("Expected auto-negotiation to select streamable-http, got '%s'", proxy.currentTransportMode())
 	}
 
 	// Send initialize via stdin
@@ -305,8 +307,8 @@ func TestE2ESSEFallbackPipeline(t *testing.T) {
 	time.Sleep(300 * time.Millisecond)
 
 	// Verify SSE was selected
-	if proxy.transportMode != TransportModeSSE {
-		t.Fatalf("Expected auto-negotiation to fall back to SSE, got '%s'", proxy.transportMode)
+	if proxy.currentTransportMode() != TransportModeSSE {
+		t.Fatalf("Expected auto-negotiation to fall back to SSE, got '%s'", proxy.currentTransportMode())
 	}
 
 	// Send initialize
@@ -369,6 +371,56 @@ func (sb *safeBuffer) ReadLine() (string, bool) {
 	return strings.TrimRight(line, "\n"), true
 }
 
+// TestE2EMockServer runs a tool call through the proxy against the mock
+// server on both transports, with a static token and tool latency.
+func TestE2EMockServer(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	server := httptest.NewServer(mockserver.New(mockserver.Config{
+		Token: "secret",
+		Tools: []mockserver.Tool{{Name: "slow", Result: "done", Latency: 50 * time.Millisecond}},
+	}).Handler())
+	defer server.Close()
+
+	for mode, path := range map[TransportMode]string{TransportModeStreamableHTTP: "/mcp", TransportModeSSE: "/sse"} {
+		t.Run(string(mode), func(t *testing.T) {
+			received := make(chan []byte, 4)
+			p, err := NewProxyWithOptions(server.URL+path, 0, map[string]string{}, "e2e-mock-"+string(mode), mode, "",
+				WithStaticToken("secret"),
+				WithMessageHandler(func(data []byte) { received <- data }))
+			if err != nil {
+				t.Fatalf("Failed to create proxy: %v", err)
+			}
+			if err := p.Connect(); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer p.Shutdown()
+			if sse, ok := p.currentTransport().(*SSETransport); ok {
+				waitFor(t, func() bool { return sse.getCommandEndpointValue() != "" })
+			}
+
+			for _, message := range []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{}}}`,
+				`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+				`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slow"}}`,
+			} {
+				if err := p.Send(t.Context(), []byte(message)); err != nil {
+					t.Fatalf("Send failed: %v", err)
+				}
+			}
+			for _, want := range []string{`"serverInfo"`, `"text":"done"`} {
+				select {
+				case msg := <-received:
+					if !strings.Contains(string(msg), want) {
+						t.Errorf("Expected %s in %s", want, msg)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("Timed out waiting for %s", want)
+				}
+			}
+		})
+	}
+}
+
 func newMockMCPServer(t *testing.T) *httptest.Server {
 	t.Helper()
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// eagerInitID is the request ID of the proxy's own initialize request.
+	eagerInitID = "mcp-remote-go-initialize"
+
+	// eagerInitTimeout bounds the wait for the server's initialize result
+	// when no request timeout is set.
+	eagerInitTimeout = 30 * time.Second
+)
+
+// eagerInit is the initialize handshake the proxy performs itself, so the
+// client's initialize can be answered from the cached result.
+type eagerInit struct {
+	enabled bool
+	// version is reported as the proxy's clientInfo version.
+	version string
+
+	mu         sync.Mutex
+	result     json.RawMessage
+	negotiated string
+	// waiting receives the server's response while the proxy's initialize
+	// is outstanding.
+	waiting chan *rpcMessage
+}
+
+// initializeResult is the part of an initialize result the proxy reads.
+type initializeResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+// initializeServer performs the initialize handshake with the server and
+// caches its result.
+func (p *Proxy) initializeServer() error {
+	ch := make(chan *rpcMessage, 1)
+	p.init.mu.Lock()
+	p.init.waiting = ch
+	p.init.mu.Unlock()
+	defer func() {
+		p.init.mu.Lock()
+		p.init.waiting = nil
+		p.init.mu.Unlock()
+	}()
+
+	request, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      eagerInitID,
+		"method":  "initialize",
+		"params": map[string]interface{}{
+			"protocolVersion": p.protocol.requested(),
+			"capabilities":    map[string]interface{}{},
+			"clientInfo":      map[string]string{"name": "mcp-remote-go", "version": p.init.version},
+		},
+	})
+	if err := p.sendToServer(request); err != nil {
+		return fmt.Errorf("failed to send initialize: %w", err)
+	}
+
+	timeout := p.requestTimeout
+	if timeout <= 0 {
+		timeout = eagerInitTimeout
+	}
+	var resp *rpcMessage
+	select {
+	case resp = <-ch:
+	case <-time.After(timeout):
+		return errors.New("timed out waiting for the initialize result")
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("server rejected initialize: %s", resp.Error.Message)
+	}
+
+	var result initializeResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.ProtocolVersion == "" {
+		return errors.New("server returned an invalid initialize result")
+	}
+	p.protocol.negotiated(result.ProtocolVersion)
+	slog.Info("initialized server", "name", result.ServerInfo.Name, "version", result.ServerInfo.Version, "protocol_version", result.ProtocolVersion)
+
+	p.init.mu.Lock()
+	p.init.result = resp.Result
+	p.init.negotiated = result.ProtocolVersion
+	p.init.mu.Unlock()
+
+	initialized, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/initialized"})
+	if err := p.sendToServer(initialized); err != nil {
+		return fmt.Errorf("failed to send initialized notification: %w", err)
+	}
+	return nil
+}
+
+// eagerInitResponse passes the server's answer to the proxy's initialize to
+// initializeServer and reports whether data was that answer.
+func (p *Proxy) eagerInitResponse(data []byte) bool {
+	if !p.init.enabled || !bytes.Contains(data, []byte(eagerInitID)) {
+		return false
+	}
+	var msg rpcMessage
+	if json.Unmarshal(data, &msg) != nil || !msg.isResponse() || string(msg.ID) != `"`+eagerInitID+`"` {
+		return false
+	}
+	p.init.mu.Lock()
+	ch := p.init.waiting
+	p.init.mu.Unlock()
+	if ch != nil {
+		ch <- &msg
+	}
+	return true
+}
+
+// answerInitialize answers the client's initialize from the cached result
+// and drops its notifications/initialized, which the server already
+// received. It reports whether message was handled.
+func (p *Proxy) answerInitialize(message []byte) bool {
+	if !p.init.enabled || !bytes.Contains(message, []byte("initialize")) {
+		return false
+	}
+	p.init.mu.Lock()
+	result, negotiated := p.init.result, p.init.negotiated
+	p.init.mu.Unlock()
+	if result == nil {
+		return false
+	}
+
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil {
+		return false
+	}
+	switch {
+	case msg.isNotification() && msg.Method == "notifications/initialized":
+		return true
+	case msg.isRequest() && msg.Method == "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil && params.ProtocolVersion != negotiated {
+			slog.Warn("client requested a different protocol version than the server negotiated", "requested", params.ProtocolVersion, "negotiated", negotiated)
+		}
+		reply, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		p.deliver(reply)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// initTestTransport answers the proxy's initialize with result.
+type initTestTransport struct {
+	batchTestTransport
+	p      *Proxy
+	result string
+}
+
+func (t *initTestTransport) Send(ctx context.Context, message []byte) error {
+	_ = t.batchTestTransport.Send(ctx, message)
+	if strings.Contains(string(message), `"id":"`+eagerInitID+`"`) {
+		go t.p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":"`+eagerInitID+`",`+t.result+`}`))
+	}
+	return nil
+}
+
+func newEagerInitTestProxy(result string) (*Proxy, *initTestTransport, func() string) {
+	transport := &initTestTransport{result: result}
+	p, out := newBatchTestProxy(transport)
+	p.init = eagerInit{enabled: true, version: "1.2.3"}
+	transport.p = p
+	return p, transport, func() string {
+		_ = p.stdioWriter.Flush()
+		return out.String()
+	}
+}
+
+func TestEagerInitAnswersClientInitialize(t *testing.T) {
+	p, transport, output := newEagerInitTestProxy(`"result":{"protocolVersion":"2025-11-25","serverInfo":{"name":"test"}}`)
+	if err := p.initializeServer(); err != nil {
+		t.Fatalf("initializeServer failed: %v", err)
+	}
+	if len(transport.sent) != 2 || !strings.Contains(transport.sent[0], `"version":"1.2.3"`) || !strings.Contains(transport.sent[1], "notifications/initialized") {
+		t.Fatalf("Expected initialize and notifications/initialized, got %q", transport.sent)
+	}
+
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)) != nil {
+		t.Error("Expected the client's initialize not to be forwarded")
+	}
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)) != nil {
+		t.Error("Expected the client's notifications/initialized not to be forwarded")
+	}
+	if got := output(); !strings.Contains(got, `"id":0`) || !strings.Contains(got, `"serverInfo":{"name":"test"}`) {
+		t.Errorf("Expected the cached result under the client's ID, got %q", got)
+	}
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)) == nil {
+		t.Error("Expected other requests to be forwarded")
+	}
+}
+
+func TestEagerInitFailures(t *testing.T) {
+	tests := []struct {
+		result string
+		want   string
+	}{
+		{`"error":{"code":-32602,"message":"unsupported"}`, "server rejected initialize: unsupported"},
+		{`"result":{}`, "invalid initialize result"},
+	}
+	for _, tt := range tests {
+		p, _, _ := newEagerInitTestProxy(tt.result)
+		err := p.initializeServer()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.result, tt.want, err)
+		}
+		if p.outgoing([]byte(`{"jsonrpc":"2.0","id":0,"method":"initialize"}`)) == nil {
+			t.Errorf("%s: expected the client's initialize forwarded without a cached result", tt.result)
+		}
+	}
+}
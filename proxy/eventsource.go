@@ -1,23 +1,30 @@
 package proxy
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
 
-// EventSource provides a client for Server-Sent Events (SSE)
+// EventSource provides a client for Server-Sent Events (SSE). When the stream
+// drops it reconnects on its own with exponential backoff, sending the last
+// received event ID as Last-Event-ID so the server can replay missed events.
 type EventSource struct {
 	request   *http.Request
 	client    *http.Client
 	lastID    string
+	retry     time.Duration // server-requested reconnection delay (retry: field)
 	reconnect bool
+	reopen    bool // the stream was closed by Reopen
 	mu        sync.Mutex
 	ctx       context.Context
 	cancel    context.CancelFunc
@@ -25,12 +32,24 @@ type EventSource struct {
 	// Callbacks
 	OnOpen    func()
 	OnMessage func(event string, data []byte)
-	OnError   func(err error)
+	// OnError is called when the stream fails and cannot be re-established.
+	OnError func(err error)
+	// PrepareRequest, when set, is called before every connection attempt,
+	// e.g. to refresh the Authorization header.
+	PrepareRequest func(req *http.Request)
+	// IdleTimeout, when set, drops and reconnects a stream on which nothing,
+	// not even a comment, arrived for that long.
+	IdleTimeout time.Duration
+	// Backoff spaces reconnection attempts. A retry: value sent by the
+	// server replaces its initial delay.
+	Backoff backoff.Policy
+	// MaxEventSize limits the data of one event, in bytes; zero means
+	// DefaultMaxEventSize. A larger event drops the stream.
+	MaxEventSize int
 
 	// State
 	connected bool
 	response  *http.Response
-	reader    *bufio.Reader
 }
 
 // NewEventSource creates a new SSE client
@@ -55,6 +74,14 @@ func (es *EventSource) Connect() error {
 		return nil
 	}
 
+	if es.ctx.Err() != nil {
+		return es.ctx.Err()
+	}
+
+	if es.PrepareRequest != nil {
+		es.PrepareRequest(es.request)
+	}
+
 	// Add Last-Event-ID header if we have one from previous connection
 	if es.lastID != "" {
 		es.request.Header.Set("Last-Event-ID", es.lastID)
@@ -69,26 +96,28 @@ func (es *EventSource) Connect() error {
 	if resp.StatusCode == http.StatusUnauthorized {
 		return unauthorizedFromResponse(resp)
 	}
+	if limited := httpclient.RateLimitFromResponse(resp); limited != nil {
+		return limited
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		body := readErrorBody(resp.Body)
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
-		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, string(body))
+		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, body)
 	}
 
 	// Verify that the content type is text/event-stream
 	contentType := resp.Header.Get("Content-Type")
 	if contentType != "text/event-stream" {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 		return fmt.Errorf("expected content-type text/event-stream, got %s", contentType)
 	}
 
 	es.response = resp
-	es.reader = bufio.NewReader(resp.Body)
 	es.connected = true
 
 	// Notify that we're connected
@@ -97,96 +126,199 @@ func (es *EventSource) Connect() error {
 	}
 
 	// Start reading events
-	go es.readEvents()
+	go es.run()
 
 	return nil
 }
 
+// run reads events until the stream ends, then reconnects unless the
+// EventSource was closed.
+func (es *EventSource) run() {
+	err := es.readEvents()
+	if es.ctx.Err() != nil {
+		return
+	}
+
+	es.mu.Lock()
+	reopen := es.reopen
+	es.reopen = false
+	es.mu.Unlock()
+	if reopen {
+		if err = es.Connect(); err == nil {
+			return
+		}
+		var unauth *UnauthorizedError
+		if errors.As(err, &unauth) {
+			if es.OnError != nil {
+				es.OnError(err)
+			}
+			return
+		}
+	}
+
+	if es.reconnect {
+		if err != nil {
+			slog.Warn("SSE stream error, reconnecting", "error", err)
+		} else {
+			slog.Info("SSE stream closed by server, reconnecting")
+		}
+		err = es.reconnectWithBackoff()
+	}
+	if err != nil && es.OnError != nil {
+		es.OnError(err)
+	}
+}
+
+// reconnectWithBackoff re-establishes the stream, waiting longer between
+// each failed attempt as set by Backoff. Authorization failures are
+// returned immediately.
+func (es *EventSource) reconnectWithBackoff() error {
+	policy := es.Backoff
+	es.mu.Lock()
+	if es.retry > 0 {
+		policy.Initial = es.retry
+	}
+	es.mu.Unlock()
+
+	b := policy.New()
+	var lastErr error
+	for {
+		delay, ok := b.Next()
+		if !ok {
+			break
+		}
+		select {
+		case <-es.ctx.Done():
+			return nil
+		case <-time.After(retryDelay(delay, lastErr)):
+		}
+
+		err := es.Connect()
+		if err == nil {
+			slog.Info("SSE stream reconnected")
+			return nil
+		}
+		if es.ctx.Err() != nil {
+			return nil
+		}
+
+		var unauth *UnauthorizedError
+		if errors.As(err, &unauth) {
+			return unauth
+		}
+
+		lastErr = err
+		slog.Warn("SSE reconnect attempt failed", "attempt", b.Attempt(), "max_attempts", policy.Attempts(), "error", err)
+	}
+
+	return fmt.Errorf("SSE reconnection failed after %d attempts: %w", b.Attempt(), lastErr)
+}
+
+// Reopen closes the current stream and opens it again right away, with
+// the request prepared anew by PrepareRequest. It reports false when no
+// stream is open, e.g. while reconnecting.
+func (es *EventSource) Reopen() bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if !es.connected || es.response == nil || es.response.Body == nil {
+		return false
+	}
+	es.reopen = true
+	if err := es.response.Body.Close(); err != nil {
+		slog.Warn("failed to close response body", "error", err)
+	}
+	return true
+}
+
 // Close closes the SSE connection
 func (es *EventSource) Close() {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
+	// Cancel the context to stop all operations, including a pending
+	// reconnection.
+	es.cancel()
+
 	if !es.connected {
 		return
 	}
 
-	// Cancel the context to stop all operations
-	es.cancel()
-
 	// Close the response body
 	if es.response != nil && es.response.Body != nil {
 		if err := es.response.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}
 
 	es.connected = false
 }
 
-// readEvents continuously reads SSE events
-func (es *EventSource) readEvents() {
+// readEvents reads SSE events until the stream ends. It returns nil when the
+// server closed the stream and the read error otherwise.
+func (es *EventSource) readEvents() error {
 	defer func() {
 		es.mu.Lock()
 		es.connected = false
 		if es.response != nil && es.response.Body != nil {
 			if err := es.response.Body.Close(); err != nil {
-				log.Printf("Warning: failed to close response body: %v", err)
+				slog.Warn("failed to close response body", "error", err)
 			}
 		}
 		es.mu.Unlock()
 	}()
 
-	var event string
-	var data bytes.Buffer
+	var idle atomic.Bool
+	resetIdle := func() {}
+	if es.IdleTimeout > 0 {
+		watchdog := time.AfterFunc(es.IdleTimeout, func() {
+			idle.Store(true)
+			es.mu.Lock()
+			if es.response != nil && es.response.Body != nil {
+				_ = es.response.Body.Close()
+			}
+			es.mu.Unlock()
+		})
+		defer watchdog.Stop()
+		resetIdle = func() { watchdog.Reset(es.IdleTimeout) }
+	}
+
+	es.mu.Lock()
+	d := newSSEDecoder(es.response.Body, es.MaxEventSize)
+	es.mu.Unlock()
+	defer d.close()
+	d.onLine = resetIdle
 
 	for {
 		// Check if context is done
 		select {
 		case <-es.ctx.Done():
-			return
+			return nil
 		default:
 		}
 
-		// Read a line
-		line, err := es.reader.ReadBytes('\n')
+		evt, err := d.next()
 		if err != nil {
-			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
-				return
+			if idle.Load() {
+				return fmt.Errorf("%w: nothing received for %s", errConnectionDead, es.IdleTimeout)
 			}
-
-			if es.OnError != nil {
-				es.OnError(err)
+			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+				return nil
 			}
-			return
+			return err
 		}
 
-		// Trim the line
-		line = bytes.TrimSpace(line)
-
-		// Empty line marks the end of an event
-		if len(line) == 0 {
-			if data.Len() > 0 {
-				if es.OnMessage != nil {
-					es.OnMessage(event, data.Bytes())
-				}
-
-				// Reset for next event
-				event = ""
-				data.Reset()
+		if evt.ID != "" || evt.Retry > 0 {
+			es.mu.Lock()
+			if evt.ID != "" {
+				es.lastID = evt.ID
 			}
-			continue
+			if evt.Retry > 0 {
+				es.retry = evt.Retry
+			}
+			es.mu.Unlock()
 		}
-
-		// Parse the line
-		if bytes.HasPrefix(line, []byte("event:")) {
-			event = string(bytes.TrimSpace(line[6:]))
-		} else if bytes.HasPrefix(line, []byte("data:")) {
-			dataLine := bytes.TrimSpace(line[5:])
-			data.Write(dataLine)
-		} else if bytes.HasPrefix(line, []byte("id:")) {
-			es.lastID = string(bytes.TrimSpace(line[3:]))
+		if len(evt.Data) > 0 && es.OnMessage != nil {
+			es.OnMessage(evt.Event, evt.Data)
 		}
-		// Note: retry field is not currently implemented
 	}
 }
@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
 )
 
 func TestNewEventSource(t *testing.T) {
@@ -351,3 +354,141 @@ func TestEventSourceCallbacks(t *testing.T) {
 		t.Errorf("OnError should not have been called, but got: %v", errorReceived)
 	}
 }
+
+func TestEventSourceReconnectsWithLastEventID(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastEventIDs)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 1 {
+			// Short retry so the test reconnects quickly, then drop the stream.
+			_, _ = w.Write([]byte("retry: 10\nid: 41\nevent: message\ndata: first\n\n"))
+			return
+		}
+		_, _ = w.Write([]byte("id: 42\nevent: message\ndata: second\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	es := NewEventSource(req, server.Client())
+	defer es.Close()
+
+	messages := make(chan string, 2)
+	es.OnMessage = func(event string, data []byte) {
+		messages <- string(data)
+	}
+	es.OnError = func(err error) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := es.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case got := <-messages:
+			if got != want {
+				t.Errorf("Expected message %q, got %q", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastEventIDs) != 2 || lastEventIDs[0] != "" || lastEventIDs[1] != "41" {
+		t.Errorf("Expected Last-Event-ID sent only on reconnect, got %q", lastEventIDs)
+	}
+}
+
+func TestEventSourceCloseStopsReconnect(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("retry: 50\n\n"))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	es := NewEventSource(req, server.Client())
+	if err := es.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// The stream ends at once; close while the reconnect is pending.
+	time.Sleep(10 * time.Millisecond)
+	es.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if connections != 1 {
+		t.Errorf("Expected no reconnection after Close, got %d connections", connections)
+	}
+}
+
+func TestEventSourceBackoffMaxAttempts(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		n := connections
+		mu.Unlock()
+		if n > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// Drop the stream at once so the client has to reconnect.
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	es := NewEventSource(req, server.Client())
+	es.Backoff = backoff.Policy{Initial: 10 * time.Millisecond, MaxAttempts: 3}
+	defer es.Close()
+
+	errs := make(chan error, 1)
+	es.OnError = func(err error) { errs <- err }
+	if err := es.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Errorf("Expected failure after 3 attempts, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the reconnection to give up")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if connections != 4 {
+		t.Errorf("Expected the first connection and 3 attempts, got %d", connections)
+	}
+}
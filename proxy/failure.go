@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	apperrors "github.com/naotama2002/mcp-remote-go/internal/errors"
+)
+
+// startupFailureGrace is how long a proxy that could not connect keeps
+// answering requests the client already sent, such as initialize, before
+// Start returns.
+const startupFailureGrace = 250 * time.Millisecond
+
+// failureLogger names the proxy as the logger of the notifications/message
+// reporting a failure.
+const failureLogger = "mcp-remote-go"
+
+// classifyFailure returns err as an AppError, recognising the proxy's and
+// the auth package's errors before falling back to apperrors.Classify.
+func classifyFailure(err error) *apperrors.AppError {
+	var unauth *UnauthorizedError
+	var denied *auth.AuthorizationError
+	switch {
+	case errors.As(err, &unauth):
+		return apperrors.Wrap(err, apperrors.AuthenticationError, err.Error()).WithStatusCode(unauth.StatusCode)
+	case errors.As(err, &denied):
+		return apperrors.Wrap(err, apperrors.AuthorizationError, err.Error()).WithStatusCode(http.StatusForbidden)
+	}
+	return apperrors.Classify(err)
+}
+
+// failureError returns the JSON-RPC error object reporting appErr for the
+// server at serverURL. The data carries the classification so a host can
+// tell, say, an expired login from an unreachable server.
+func failureError(appErr *apperrors.AppError, serverURL string) *rpcError {
+	data, _ := json.Marshal(struct {
+		*apperrors.AppError
+		Server string `json:"server"`
+	}{appErr, serverURL})
+	return &rpcError{Code: appErr.JSONRPCCode(), Message: appErr.Message, Data: data}
+}
+
+// failureNotification returns the notifications/message (MCP logging)
+// telling the client the proxy has stopped because of rpcErr.
+func failureNotification(rpcErr *rpcError) []byte {
+	params, _ := json.Marshal(map[string]any{
+		"level":  "error",
+		"logger": failureLogger,
+		"data":   map[string]any{"message": "connection to the MCP server failed: " + rpcErr.Message, "error": rpcErr.Data},
+	})
+	data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/message", Params: params})
+	return data
+}
+
+// reportFailure tells the client the connection to the server failed for
+// good: every outstanding request is answered with an error classifying
+// err, and a notifications/message carries it for clients with nothing
+// outstanding. It is called before the proxy shuts down.
+func (p *Proxy) reportFailure(err error) {
+	rpcErr := failureError(classifyFailure(err), p.serverURL)
+	for _, id := range p.inflight.removeAll() {
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage(id), Error: rpcErr})
+		p.deliver(data)
+	}
+	p.deliver(failureNotification(rpcErr))
+}
+
+// reportStartupFailure tells the client the proxy could not connect. The
+// failure is sent as a notification, and requests waiting on stdin or
+// arriving within startupFailureGrace are answered with the error, so a
+// host sees why instead of a closed pipe.
+func (p *Proxy) reportStartupFailure(err error) {
+	rpcErr := failureError(classifyFailure(err), p.serverURL)
+	p.writeMessage(failureNotification(rpcErr))
+
+	// Answers written after the grace period would race with the caller
+	// exiting, so they are dropped.
+	var mu sync.Mutex
+	stopped := false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			line, err := p.framing.readMessage(p.stdioReader)
+			if err != nil {
+				return
+			}
+			if response := failedResponse(line, rpcErr); response != nil {
+				mu.Lock()
+				if !stopped {
+					p.writeMessage(response)
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(startupFailureGrace):
+	}
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+}
+
+// failedResponse answers the requests in message, a message or a batch,
+// with rpcErr. It returns nil when message holds no request.
+func failedResponse(message []byte, rpcErr *rpcError) []byte {
+	elements, batch := splitBatch(message)
+	if !batch {
+		elements = [][]byte{message}
+	}
+	var responses []json.RawMessage
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) != nil || !msg.isRequest() {
+			continue
+		}
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr})
+		responses = append(responses, data)
+	}
+	switch {
+	case len(responses) == 0:
+		return nil
+	case !batch:
+		return responses[0]
+	}
+	data, _ := json.Marshal(responses)
+	return data
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	apperrors "github.com/naotama2002/mcp-remote-go/internal/errors"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want apperrors.ErrorType
+	}{
+		{fmt.Errorf("reconnection failed: %w", &UnauthorizedError{StatusCode: http.StatusUnauthorized}), apperrors.AuthenticationError},
+		{fmt.Errorf("re-authentication failed: %w", &auth.AuthorizationError{Code: "access_denied"}), apperrors.AuthorizationError},
+		{errors.New("server returned error status: 500 - oops"), apperrors.ServerError},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.err); got.Type != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.err, got.Type, tt.want)
+		}
+	}
+}
+
+// decodeMessages decodes the newline-delimited messages in out.
+func decodeMessages(t *testing.T, out string) []rpcMessage {
+	t.Helper()
+	var messages []rpcMessage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var msg rpcMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("invalid message %q: %v", line, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestReportFailure(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+	p.serverURL = "https://mcp.example.com/mcp"
+	p.trackRequests(TraceLocalToRemote, []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search"}}`))
+
+	p.reportFailure(fmt.Errorf("reconnection failed: %w", &UnauthorizedError{StatusCode: http.StatusUnauthorized}))
+	_ = p.stdioWriter.Flush()
+
+	messages := decodeMessages(t, out.String())
+	if len(messages) != 2 {
+		t.Fatalf("Expected a response and a notification, got %s", out.String())
+	}
+	response := messages[0]
+	if string(response.ID) != "7" || response.Error == nil || response.Error.Code != apperrors.CodeAuthentication {
+		t.Errorf("Expected the request answered with an authentication error, got %+v", response)
+	}
+	var data struct {
+		Type   string `json:"type"`
+		Server string `json:"server"`
+	}
+	if err := json.Unmarshal(response.Error.Data, &data); err != nil || data.Type != string(apperrors.AuthenticationError) || data.Server != p.serverURL {
+		t.Errorf("Unexpected error data %s", response.Error.Data)
+	}
+	if messages[1].Method != "notifications/message" || !strings.Contains(string(messages[1].Params), `"level":"error"`) {
+		t.Errorf("Expected an error log notification, got %+v", messages[1])
+	}
+	if p.inflight.count() != 0 {
+		t.Error("Expected the answered request no longer tracked")
+	}
+}
+
+func TestStartReportsConnectFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL := server.URL
+	server.Close()
+
+	p, err := NewProxyWithOptions(serverURL, 0, nil, "", TransportModeSSE, "")
+	if err != nil {
+		t.Fatalf("NewProxyWithOptions failed: %v", err)
+	}
+	var out strings.Builder
+	stdin := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}` + "\n" + `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"
+	writer := bufio.NewWriter(&out)
+	p.SetStdio(bufio.NewReader(strings.NewReader(stdin)), writer)
+
+	if err := p.Start(); err == nil {
+		t.Fatal("Expected Start to fail")
+	}
+	_ = writer.Flush()
+
+	messages := decodeMessages(t, out.String())
+	if len(messages) != 2 || messages[0].Method != "notifications/message" {
+		t.Fatalf("Expected a notification and a response, got %s", out.String())
+	}
+	if response := messages[1]; string(response.ID) != "1" || response.Error == nil || response.Error.Code != apperrors.CodeNetwork {
+		t.Errorf("Expected initialize answered with a network error, got %+v", response)
+	}
+}
@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Stdio framings accepted by WithStdioFraming.
+const (
+	// FramingAuto detects the framing from each message the client sends
+	// and answers in the framing last seen.
+	FramingAuto = "auto"
+	// FramingNewline is newline-delimited JSON, the MCP stdio transport.
+	FramingNewline = "newline"
+	// FramingContentLength prefixes each message with LSP-style headers.
+	FramingContentLength = "content-length"
+)
+
+// DefaultMaxMessageSize is the largest message read from stdio by default.
+const DefaultMaxMessageSize = 64 << 20
+
+// ErrMessageTooLarge is returned for a message larger than the configured
+// maximum. A stdio message is skipped; a server's event or response body
+// aborts the stream or request it arrived on.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// stdioFraming reads and writes messages on stdio in one of the framings.
+type stdioFraming struct {
+	mode    string
+	maxSize int
+
+	mu sync.Mutex
+	// output is the framing used for writes; in auto mode it follows the
+	// client's input.
+	output string
+}
+
+// newStdioFraming returns a framing for mode that rejects messages over
+// maxSize bytes; zero means DefaultMaxMessageSize.
+func newStdioFraming(mode string, maxSize int) (*stdioFraming, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	switch mode {
+	case "", FramingAuto:
+		return &stdioFraming{mode: FramingAuto, maxSize: maxSize, output: FramingNewline}, nil
+	case FramingNewline, FramingContentLength:
+		return &stdioFraming{mode: mode, maxSize: maxSize, output: mode}, nil
+	}
+	return nil, fmt.Errorf("invalid stdio framing %q: use auto, newline or content-length", mode)
+}
+
+// readMessage returns the next message from r, skipping blank lines. Without
+// headers, a JSON object or array is read whole even when it spans several
+// lines; anything else is read up to the end of the line. A final message
+// without a trailing newline is returned before io.EOF.
+func (f *stdioFraming) readMessage(r *bufio.Reader) ([]byte, error) {
+	for {
+		next, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = r.ReadByte()
+			continue
+		case '{', '[':
+			if f.mode != FramingContentLength {
+				f.setOutput(FramingNewline)
+				return readJSONValue(r, f.maxSize)
+			}
+		}
+
+		line, err := r.ReadBytes('\n')
+		trimmed := bytes.TrimSpace(line)
+		if f.mode != FramingNewline && isFramingHeader(trimmed) {
+			f.setOutput(FramingContentLength)
+			return readContentLengthBody(r, trimmed, f.maxSize)
+		}
+		if f.mode == FramingContentLength {
+			return nil, fmt.Errorf("expected Content-Length header, got %q", truncate(trimmed, 40))
+		}
+		if len(trimmed) > f.maxSize {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, f.maxSize)
+		}
+		if len(trimmed) == 0 {
+			return nil, err
+		}
+		f.setOutput(FramingNewline)
+		return trimmed, nil
+	}
+}
+
+// readJSONValue reads one JSON object or array from r, whatever its line
+// layout, by tracking nesting outside of strings. A json.Decoder would
+// buffer past the value and take the start of the next message with it.
+// A value over limit bytes is consumed and reported as ErrMessageTooLarge.
+func readJSONValue(r *bufio.Reader, limit int) ([]byte, error) {
+	var buf []byte
+	depth := 0
+	inString, escaped, tooLarge := false, false, false
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read message: %w", err)
+		}
+		if !tooLarge {
+			if len(buf) == limit {
+				tooLarge, buf = true, nil
+			} else {
+				buf = append(buf, b)
+			}
+		}
+
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+			if depth == 0 {
+				if tooLarge {
+					return nil, fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, limit)
+				}
+				return buf, nil
+			}
+		}
+	}
+}
+
+// writeMessage writes data to w in the output framing and flushes it.
+func (f *stdioFraming) writeMessage(w *bufio.Writer, data []byte) error {
+	f.mu.Lock()
+	output := f.output
+	f.mu.Unlock()
+
+	if output == FramingContentLength {
+		var header [40]byte
+		h := strconv.AppendInt(append(header[:0], "Content-Length: "...), int64(len(data)), 10)
+		if _, err := w.Write(append(h, "\r\n\r\n"...)); err != nil {
+			return err
+		}
+	}
+	// A message larger than the free buffer space goes to the output
+	// directly once the buffer is flushed, rather than through the buffer.
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if output != FramingContentLength {
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (f *stdioFraming) setOutput(framing string) {
+	if f.mode != FramingAuto {
+		return
+	}
+	f.mu.Lock()
+	f.output = framing
+	f.mu.Unlock()
+}
+
+// isFramingHeader reports whether line starts an LSP-style header block.
+func isFramingHeader(line []byte) bool {
+	return len(line) > 8 && bytes.EqualFold(line[:8], []byte("content-"))
+}
+
+// readContentLengthBody reads the remaining headers after first, up to the
+// blank line, and then the body they announce. A body over limit bytes is
+// skipped and reported as ErrMessageTooLarge.
+func readContentLengthBody(r *bufio.Reader, first []byte, limit int) ([]byte, error) {
+	length := -1
+	line := first
+	for len(line) > 0 {
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			return nil, fmt.Errorf("malformed header %q", truncate(line, 40))
+		}
+		if bytes.EqualFold(bytes.TrimSpace(name), []byte("Content-Length")) {
+			n, err := strconv.Atoi(string(bytes.TrimSpace(value)))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", bytes.TrimSpace(value))
+			}
+			length = n
+		}
+
+		raw, err := r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read headers: %w", err)
+		}
+		line = bytes.TrimSpace(raw)
+	}
+
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	if length > limit {
+		if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+			return nil, fmt.Errorf("failed to skip message body: %w", err)
+		}
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrMessageTooLarge, length, limit)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	return body, nil
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) > n {
+		return string(b[:n]) + "..."
+	}
+	return string(b)
+}
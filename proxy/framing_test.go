@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, f *stdioFraming, input string) []string {
+	t.Helper()
+	r := bufio.NewReader(strings.NewReader(input))
+	var messages []string
+	for {
+		msg, err := f.readMessage(r)
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("readMessage failed: %v", err)
+		}
+		messages = append(messages, string(msg))
+	}
+}
+
+func TestStdioFramingAutoDetect(t *testing.T) {
+	f, err := newStdioFraming(FramingAuto, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "{\"id\":1}\n\n" +
+		"Content-Length: 8\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{\"id\":2}" +
+		"content-length: 10\r\n\r\n{\"id\":\"3\"}" +
+		"{\"id\":4}"
+	got := readAll(t, f, input)
+	want := []string{`{"id":1}`, `{"id":2}`, `{"id":"3"}`, `{"id":4}`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestStdioFramingWriteFollowsInput(t *testing.T) {
+	f, err := newStdioFraming(FramingAuto, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+
+	if err := f.writeMessage(w, []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, f, "Content-Length: 2\r\n\r\n{}")
+	if err := f.writeMessage(w, []byte(`{"b":2}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "{\"a\":1}\nContent-Length: 7\r\n\r\n{\"b\":2}"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestStdioFramingFixedModes(t *testing.T) {
+	newline, _ := newStdioFraming(FramingNewline, 0)
+	// A newline-only client never has headers, so the line is passed on as is.
+	if got := readAll(t, newline, "Content-Length: 2\n"); len(got) != 1 || got[0] != "Content-Length: 2" {
+		t.Errorf("Expected the header line as a message, got %v", got)
+	}
+
+	contentLength, _ := newStdioFraming(FramingContentLength, 0)
+	if _, err := contentLength.readMessage(bufio.NewReader(strings.NewReader("{\"id\":1}\n"))); err == nil {
+		t.Error("Expected a message without headers to be rejected")
+	}
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	if err := contentLength.writeMessage(w, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Content-Length: 2\r\n\r\n{}" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	if _, err := newStdioFraming("lsp", 0); err == nil {
+		t.Error("Expected an unknown framing to be rejected")
+	}
+}
+
+func TestStdioFramingMalformedHeaders(t *testing.T) {
+	f, _ := newStdioFraming(FramingAuto, 0)
+	for _, input := range []string{
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Type: json\r\n\r\n{}",
+		"Content-Length: 100\r\n\r\n{}",
+		"Content-Length: 2\r\n",
+	} {
+		if _, err := f.readMessage(bufio.NewReader(strings.NewReader(input))); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%q: expected a framing error, got %v", input, err)
+		}
+	}
+}
+
+func TestStdioFramingMultiLineJSON(t *testing.T) {
+	f, _ := newStdioFraming(FramingAuto, 0)
+	input := "{\n  \"id\": 1,\n  \"params\": {\"text\": \"a } \\\" [\\n\"}\n}\n" +
+		"{\"id\":2}{\"id\":3} [{\"id\":4}]\n"
+	got := readAll(t, f, input)
+	want := []string{
+		"{\n  \"id\": 1,\n  \"params\": {\"text\": \"a } \\\" [\\n\"}\n}",
+		`{"id":2}`, `{"id":3}`, `[{"id":4}]`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d messages, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Message %d: expected %q, got %q", i, want[i], got[i])
+		}
+		if !json.Valid([]byte(got[i])) {
+			t.Errorf("Message %d is not valid JSON: %q", i, got[i])
+		}
+	}
+}
+
+func TestStdioFramingMaxMessageSize(t *testing.T) {
+	f, _ := newStdioFraming(FramingAuto, 16)
+	large := `{"id":1,"params":"` + strings.Repeat("x", 32) + `"}`
+	r := bufio.NewReader(strings.NewReader(large + "\n" +
+		"Content-Length: 40\r\n\r\n" + strings.Repeat(" ", 40) +
+		`{"id":2}` + "\n"))
+
+	for range 2 {
+		if _, err := f.readMessage(r); !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("Expected ErrMessageTooLarge, got %v", err)
+		}
+	}
+	// Oversized messages are skipped, so the next one is read intact.
+	msg, err := f.readMessage(r)
+	if err != nil || string(msg) != `{"id":2}` {
+		t.Errorf(`Expected {"id":2}, got %q (%v)`, msg, err)
+	}
+}
+
+func TestStdioFramingTruncatedJSON(t *testing.T) {
+	f, _ := newStdioFraming(FramingNewline, 0)
+	_, err := f.readMessage(bufio.NewReader(strings.NewReader(`{"id":1,"params":{`)))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
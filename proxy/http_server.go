@@ -0,0 +1,570 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPEndpointPath is the path of the local Streamable HTTP endpoint.
+const HTTPEndpointPath = "/mcp"
+
+// SSEEndpointPath and SSEMessagePath are the paths of the legacy HTTP+SSE
+// endpoint: clients open an event stream at SSEEndpointPath and post their
+// messages to the SSEMessagePath URL announced on it.
+const (
+	SSEEndpointPath = "/sse"
+	SSEMessagePath  = "/message"
+)
+
+// httpSessionHeader carries the local session ID (Streamable HTTP).
+const httpSessionHeader = "Mcp-Session-Id"
+
+// httpStreamBuffer is how many server messages wait for a slow event stream
+// before further ones are dropped.
+const httpStreamBuffer = 64
+
+// HTTPServer exposes the proxy's server as a Streamable HTTP endpoint at
+// HTTPEndpointPath, and a legacy HTTP+SSE endpoint at SSEEndpointPath, for
+// MCP hosts that cannot launch a stdio server. Each HTTP session is a
+// session of a Multiplexer, so all of them share the proxy's connection and
+// authorization. Streamable HTTP responses are returned in the POST that
+// carried the request; other server messages are sent on the session's GET
+// event stream.
+type HTTPServer struct {
+	mux      *Multiplexer
+	listener net.Listener
+	server   *http.Server
+	// token, when set, must be presented as a bearer token.
+	token string
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+
+	closeOnce sync.Once
+}
+
+// httpSession is one local Streamable HTTP session.
+type httpSession struct {
+	id  string
+	mux *muxSession
+
+	mu sync.Mutex
+	// waiting maps the IDs of requests in flight to the POST waiting for
+	// their response.
+	waiting map[string]chan []byte
+	// stream receives the other server messages while a GET event stream
+	// is open.
+	stream chan []byte
+}
+
+// HTTPServerOption configures an HTTPServer.
+type HTTPServerOption func(*HTTPServer)
+
+// WithBearerToken requires clients to send token in an Authorization
+// header as a bearer token.
+func WithBearerToken(token string) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.token = token
+	}
+}
+
+// NewHTTPServer returns an HTTPServer serving the proxy on listener.
+func NewHTTPServer(p *Proxy, listener net.Listener, opts ...HTTPServerOption) *HTTPServer {
+	m := newMultiplexer(p)
+	m.persistent = true
+	s := &HTTPServer{
+		mux:      m,
+		listener: listener,
+		sessions: make(map[string]*httpSession),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	handler := http.NewServeMux()
+	handler.HandleFunc(HTTPEndpointPath, s.handle)
+	handler.HandleFunc(SSEEndpointPath, s.handleSSE)
+	handler.HandleFunc(SSEMessagePath, s.handleSSEMessage)
+	s.server = &http.Server{Handler: s.guard(handler), ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Addr returns the address the server listens on.
+func (s *HTTPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Start connects to the server and serves local sessions until the proxy
+// shuts down.
+func (s *HTTPServer) Start() error {
+	slog.Info("starting MCP proxy HTTP endpoint", "server", s.mux.proxy.serverURL, "url", "http://"+s.listener.Addr().String()+HTTPEndpointPath)
+	if err := s.mux.proxy.Connect(); err != nil {
+		_ = s.listener.Close()
+		return err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.server.Serve(s.listener)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case <-s.mux.proxy.ctx.Done():
+	}
+	s.Shutdown()
+	return err
+}
+
+// Shutdown stops the HTTP server and shuts the proxy down.
+func (s *HTTPServer) Shutdown() {
+	s.closeOnce.Do(func() {
+		_ = s.server.Close()
+		s.mux.proxy.Shutdown()
+	})
+}
+
+// guard rejects requests from web pages not served from this machine, and,
+// on a loopback listener, requests with a Host header of another name, as
+// sent after a DNS rebinding. Pages on this machine are allowed
+// cross-origin access. With WithBearerToken, requests other than CORS
+// preflights must carry the token.
+func (s *HTTPServer) guard(next http.Handler) http.Handler {
+	loopback := isLoopbackAddr(s.listener.Addr())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if loopback && !isLoopbackHost(r.Host) {
+			slog.Warn("rejected HTTP request with unexpected host", "host", r.Host)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if !isLoopbackOrigin(origin) {
+				slog.Warn("rejected HTTP request from disallowed origin", "origin", origin)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", httpSessionHeader)
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, Mcp-Session-Id, Mcp-Protocol-Version, Last-Event-ID")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if s.token != "" && !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-remote-go"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether r carries the bearer token.
+func (s *HTTPServer) authorized(r *http.Request) bool {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	return ok && strings.EqualFold(scheme, "Bearer") &&
+		subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(s.token)) == 1
+}
+
+func (s *HTTPServer) handle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost forwards the messages in the body and answers with the
+// responses to its requests, in order.
+func (s *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	elements, batch := splitBatch(body)
+	if !batch {
+		elements = [][]byte{body}
+	}
+	var requests []*rpcMessage
+	initialize := false
+	for _, element := range elements {
+		var msg rpcMessage
+		if err := json.Unmarshal(element, &msg); err != nil {
+			writeJSONError(w, http.StatusBadRequest, newErrorMessage(nil, jsonRPCParseError, "parse error"))
+			return
+		}
+		if msg.isRequest() {
+			requests = append(requests, &msg)
+			initialize = initialize || msg.Method == "initialize"
+		}
+	}
+
+	session, status := s.session(r, initialize)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	responses := make([]chan []byte, len(requests))
+	for i, req := range requests {
+		ch, ok := session.await(req.ID)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, newErrorMessage(req.ID, jsonRPCInvalidRequest, "request ID already in use"))
+			return
+		}
+		responses[i] = ch
+	}
+
+	s.mux.handleClientMessage(session.mux, body)
+	if len(requests) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	results := make([]json.RawMessage, len(requests))
+	for i := range requests {
+		select {
+		case data := <-responses[i]:
+			results[i] = data
+		case <-r.Context().Done():
+			s.cancel(session, requests[i:])
+			return
+		case <-s.mux.proxy.ctx.Done():
+			http.Error(w, "Proxy is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if initialize {
+		w.Header().Set(httpSessionHeader, session.id)
+	}
+	if batch {
+		_ = json.NewEncoder(w).Encode(results)
+		return
+	}
+	_, _ = w.Write(results[0])
+}
+
+// handleGet opens the session's event stream for server notifications and
+// requests.
+func (s *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, status := s.session(r, false)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	stream := make(chan []byte, httpStreamBuffer)
+	session.mu.Lock()
+	if session.stream != nil {
+		session.mu.Unlock()
+		http.Error(w, "An event stream is already open for this session", http.StatusConflict)
+		return
+	}
+	session.stream = stream
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		session.stream = nil
+		session.mu.Unlock()
+	}()
+
+	startEventStream(w, flusher)
+	s.writeEvents(w, r, flusher, stream)
+}
+
+// writeEvents sends the messages on stream as server-sent events until the
+// client or the proxy goes away.
+func (s *HTTPServer) writeEvents(w http.ResponseWriter, r *http.Request, flusher http.Flusher, stream <-chan []byte) {
+	for {
+		select {
+		case data := <-stream:
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.mux.proxy.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleDelete ends a session.
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, status := s.session(r, false)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	s.endSession(session)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSSE serves a legacy HTTP+SSE session for as long as its event
+// stream is open. The first event announces the URL to post messages to.
+func (s *HTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	session := s.newSession()
+	stream := make(chan []byte, httpStreamBuffer)
+	session.mu.Lock()
+	session.stream = stream
+	session.mu.Unlock()
+	defer s.endSession(session)
+
+	startEventStream(w, flusher)
+	if _, err := fmt.Fprintf(w, "event: endpoint\ndata: %s?sessionId=%s\n\n", SSEMessagePath, session.id); err != nil {
+		return
+	}
+	flusher.Flush()
+	s.writeEvents(w, r, flusher, stream)
+}
+
+// handleSSEMessage forwards a message posted to a legacy HTTP+SSE session.
+// The answer is sent on the session's event stream.
+func (s *HTTPServer) handleSSEMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	session, ok := s.sessions[r.URL.Query().Get("sessionId")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	if !json.Valid(body) {
+		writeJSONError(w, http.StatusBadRequest, newErrorMessage(nil, jsonRPCParseError, "parse error"))
+		return
+	}
+	s.mux.handleClientMessage(session.mux, body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// readBody reads a request body of at most the maximum message size,
+// answering the request itself if that fails.
+func (s *HTTPServer) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(s.mux.proxy.framing.maxSize)+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(body) > s.mux.proxy.framing.maxSize {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+// newSession starts an HTTP session.
+func (s *HTTPServer) newSession() *httpSession {
+	session := &httpSession{id: rand.Text(), waiting: make(map[string]chan []byte)}
+	session.mux = s.mux.addSession(session.deliver)
+	s.mu.Lock()
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+	return session
+}
+
+// endSession ends an HTTP session; its outstanding requests are cancelled.
+func (s *HTTPServer) endSession(session *httpSession) {
+	s.mu.Lock()
+	_, ok := s.sessions[session.id]
+	delete(s.sessions, session.id)
+	s.mu.Unlock()
+	if ok {
+		s.mux.removeSession(session.mux)
+	}
+}
+
+// session returns the session named by the request's Mcp-Session-Id header,
+// or a new one for an initialize request without the header. Otherwise it
+// returns nil and the status to answer with.
+func (s *HTTPServer) session(r *http.Request, initialize bool) (*httpSession, int) {
+	id := r.Header.Get(httpSessionHeader)
+	if id == "" {
+		if !initialize {
+			return nil, http.StatusBadRequest
+		}
+		return s.newSession(), http.StatusOK
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, http.StatusNotFound
+	}
+	return session, http.StatusOK
+}
+
+// cancel tells the server that the client gave up on requests, e.g. because
+// it disconnected before they were answered.
+func (s *HTTPServer) cancel(session *httpSession, requests []*rpcMessage) {
+	for _, req := range requests {
+		session.forget(req.ID)
+		params, _ := json.Marshal(map[string]interface{}{"requestId": req.ID, "reason": "client disconnected"})
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params})
+		s.mux.handleClientMessage(session.mux, data)
+	}
+}
+
+// await registers a POST waiting for the response to the request id. It
+// fails if a request with that id is already in flight.
+func (hs *httpSession) await(id json.RawMessage) (chan []byte, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, ok := hs.waiting[string(id)]; ok {
+		return nil, false
+	}
+	ch := make(chan []byte, 1)
+	hs.waiting[string(id)] = ch
+	return ch, true
+}
+
+func (hs *httpSession) forget(id json.RawMessage) {
+	hs.mu.Lock()
+	delete(hs.waiting, string(id))
+	hs.mu.Unlock()
+}
+
+// deliver passes a message for the session to the POST waiting for it, or
+// else to the open event stream. Without one the message is dropped.
+func (hs *httpSession) deliver(data []byte) {
+	var msg rpcMessage
+	if json.Unmarshal(data, &msg) == nil && msg.isResponse() {
+		hs.mu.Lock()
+		ch, ok := hs.waiting[string(msg.ID)]
+		delete(hs.waiting, string(msg.ID))
+		hs.mu.Unlock()
+		if ok {
+			ch <- data
+			return
+		}
+	}
+
+	hs.mu.Lock()
+	stream := hs.stream
+	hs.mu.Unlock()
+	if stream == nil {
+		slog.Debug("no event stream open, dropping server message", "session", hs.id)
+		return
+	}
+	select {
+	case stream <- data:
+	default:
+		slog.Warn("event stream is not keeping up, dropping server message", "session", hs.id)
+	}
+}
+
+func startEventStream(w http.ResponseWriter, flusher http.Flusher) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+}
+
+func writeJSONError(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// isLoopbackAddr reports whether addr is a loopback address.
+func isLoopbackAddr(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// isLoopbackHost reports whether the Host header host names this machine.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackOrigin reports whether origin is a page served from this
+// machine.
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && isLoopbackHost(u.Host)
+}
+
+// ListenHTTP listens on addr for NewHTTPServer. An address without a host,
+// such as ":8080", listens on the loopback interface only, as the endpoint
+// uses the proxy's credentials for whoever connects.
+func ListenHTTP(addr string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if !isLoopbackAddr(listener.Addr()) {
+		slog.Warn("HTTP endpoint listens beyond this machine; anyone who can reach it uses the proxy's credentials", "addr", listener.Addr().String())
+	}
+	return listener, nil
+}
@@ -0,0 +1,283 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoTransport answers every request with a result naming its method.
+type echoTransport struct {
+	batchTestTransport
+	reply func([]byte)
+}
+
+func (t *echoTransport) Send(ctx context.Context, message []byte) error {
+	_ = t.batchTestTransport.Send(ctx, message)
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) == nil && msg.isRequest() {
+		result, _ := json.Marshal(map[string]string{"method": msg.Method})
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		go t.reply(data)
+	}
+	return nil
+}
+
+// newTestHTTPServer serves an HTTPServer in front of an echoTransport on a
+// loopback port and returns its endpoint URL.
+func newTestHTTPServer(t *testing.T, opts ...HTTPServerOption) (*HTTPServer, string) {
+	t.Helper()
+	transport := &echoTransport{}
+	p, _ := newBatchTestProxy(transport)
+	listener, err := ListenHTTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenHTTP failed: %v", err)
+	}
+	s := NewHTTPServer(p, listener, opts...)
+	transport.reply = s.mux.handleServerMessage
+	go func() { _ = s.server.Serve(listener) }()
+	t.Cleanup(func() { _ = s.server.Close() })
+	return s, "http://" + listener.Addr().String() + HTTPEndpointPath
+}
+
+func postMCP(t *testing.T, endpoint, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(httpSessionHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestHTTPServerSessions(t *testing.T) {
+	_, endpoint := newTestHTTPServer(t)
+
+	resp := postMCP(t, endpoint, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a request without session to be refused, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, endpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	sessionID := resp.Header.Get(httpSessionHeader)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || sessionID == "" || body != `{"jsonrpc":"2.0","id":1,"result":{"method":"initialize"}}` {
+		t.Fatalf("Expected the initialize result and a session, got %d %q %s", resp.StatusCode, sessionID, body)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, endpoint, sessionID, `[{"jsonrpc":"2.0","id":"a","method":"tools/list"},{"jsonrpc":"2.0","id":"b","method":"prompts/list"}]`)
+	var batch []rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatalf("Expected a batch response: %v", err)
+	}
+	if len(batch) != 2 || string(batch[0].ID) != `"a"` || string(batch[1].Result) != `{"method":"prompts/list"}` {
+		t.Errorf("Expected the responses in request order, got %+v", batch)
+	}
+
+	resp = postMCP(t, endpoint, "unknown", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, endpoint, nil)
+	req.Header.Set(httpSessionHeader, sessionID)
+	deleted, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	_ = deleted.Body.Close()
+	if deleted.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the session deleted, got %d", deleted.StatusCode)
+	}
+	resp = postMCP(t, endpoint, sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 after DELETE, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPServerEventStream(t *testing.T) {
+	s, endpoint := newTestHTTPServer(t)
+
+	resp := postMCP(t, endpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	sessionID := resp.Header.Get(httpSessionHeader)
+
+	req, _ := http.NewRequest(http.MethodGet, endpoint, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(httpSessionHeader, sessionID)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer func() { _ = stream.Body.Close() }()
+	if stream.StatusCode != http.StatusOK || stream.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", stream.StatusCode, stream.Header.Get("Content-Type"))
+	}
+
+	// Wait until the stream is registered before the server notifies.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		session := s.sessions[sessionID]
+		s.mu.Unlock()
+		session.mu.Lock()
+		open := session.stream != nil
+		session.mu.Unlock()
+		if open || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	notification := `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`
+	s.mux.handleServerMessage([]byte(notification))
+
+	reader := bufio.NewReader(stream.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected the notification on the stream: %v", err)
+		}
+		if strings.TrimSpace(line) == "data: "+notification {
+			break
+		}
+	}
+}
+
+func TestHTTPServerGuard(t *testing.T) {
+	_, endpoint := newTestHTTPServer(t)
+
+	post := func(header, value string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		if header == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	if resp := post("Origin", "https://evil.example.com"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a foreign origin to be refused, got %d", resp.StatusCode)
+	}
+	if resp := post("Host", "evil.example.com"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a rebinding host to be refused, got %d", resp.StatusCode)
+	}
+	resp := post("Origin", "http://localhost:3000")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Errorf("Expected a local page to be allowed, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestHTTPServerBearerToken(t *testing.T) {
+	_, endpoint := newTestHTTPServer(t, WithBearerToken("s3cret"))
+
+	resp := postMCP(t, endpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected a request without the token to be refused, got %d", resp.StatusCode)
+	}
+
+	for token, want := range map[string]int{"wrong": http.StatusUnauthorized, "s3cret": http.StatusOK} {
+		req, _ := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("token %q: got %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestHTTPServerLegacySSE(t *testing.T) {
+	_, endpoint := newTestHTTPServer(t)
+	base := strings.TrimSuffix(endpoint, HTTPEndpointPath)
+
+	stream, err := http.Get(base + SSEEndpointPath)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer func() { _ = stream.Body.Close() }()
+	reader := bufio.NewReader(stream.Body)
+	readData := func() string {
+		t.Helper()
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read the event stream: %v", err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				return data
+			}
+		}
+	}
+
+	messageURL := readData()
+	if !strings.HasPrefix(messageURL, SSEMessagePath+"?sessionId=") {
+		t.Fatalf("Expected the message endpoint, got %q", messageURL)
+	}
+	resp, err := http.Post(base+messageURL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202, got %d", resp.StatusCode)
+	}
+	if got := readData(); got != `{"jsonrpc":"2.0","id":1,"result":{"method":"initialize"}}` {
+		t.Errorf("Expected the response on the stream, got %s", got)
+	}
+
+	resp, err = http.Post(base+SSEMessagePath+"?sessionId=unknown", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenHTTPDefaultsToLoopback(t *testing.T) {
+	listener, err := ListenHTTP(":0")
+	if err != nil {
+		t.Fatalf("ListenHTTP failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	if ip := listener.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+		t.Errorf("Expected a loopback address, got %s", ip)
+	}
+}
@@ -0,0 +1,328 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+)
+
+// maxInflightRequests bounds the number of outstanding requests tracked, so
+// requests that never get a response cannot grow the set without limit.
+const maxInflightRequests = 1024
+
+// inflightRequests tracks requests forwarded to the server that have not
+// been answered yet, for the latency histogram, the request timeout and for
+// draining on shutdown.
+type inflightRequests struct {
+	mu       sync.Mutex
+	started  map[string]inflightRequest
+	expired  map[string]struct{} // timed out requests whose response is dropped
+	progress map[string]string   // progress token to request ID
+	idle     chan struct{}       // closed when the set becomes empty; nil without waiters
+	seq      uint64              // orders requests, to evict the oldest
+}
+
+type inflightRequest struct {
+	method  string
+	tool    string // tool name of a tools/call
+	at      time.Time
+	timer   *time.Timer
+	timeout time.Duration
+	token   string
+	cancel  context.CancelFunc // aborts the exchange carrying the request
+	seq     uint64
+}
+
+// add tracks request id, calling tool for a tools/call. With a timeout,
+// onTimeout is called if the request is still outstanding once it elapses.
+// A request reusing the ID of one still outstanding replaces it. Past
+// maxInflightRequests, the oldest request is no longer tracked and its
+// exchange is aborted.
+func (r *inflightRequests) add(id, method, tool string, timeout time.Duration, onTimeout func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started == nil {
+		r.started = make(map[string]inflightRequest)
+	}
+	if prev, ok := r.started[id]; ok {
+		slog.Warn("request ID reused while the request is outstanding", "id", id, "method", prev.method)
+		r.releaseLocked(prev)
+	} else if len(r.started) >= maxInflightRequests {
+		oldest := r.oldestLocked()
+		slog.Warn("too many outstanding requests, no longer tracking the oldest", "id", oldest, "method", r.started[oldest].method, "limit", maxInflightRequests)
+		r.releaseLocked(r.started[oldest])
+		delete(r.started, oldest)
+	}
+	r.seq++
+	req := inflightRequest{method: method, tool: tool, at: time.Now(), timeout: timeout, seq: r.seq}
+	if timeout > 0 {
+		req.timer = time.AfterFunc(timeout, onTimeout)
+	}
+	r.started[id] = req
+}
+
+// oldestLocked returns the ID of the request tracked the longest.
+func (r *inflightRequests) oldestLocked() string {
+	var oldest string
+	var seq uint64
+	for id, req := range r.started {
+		if oldest == "" || req.seq < seq {
+			oldest, seq = id, req.seq
+		}
+	}
+	return oldest
+}
+
+func (r *inflightRequests) remove(id string) (inflightRequest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removeLocked(id)
+}
+
+func (r *inflightRequests) removeLocked(id string) (inflightRequest, bool) {
+	req, ok := r.started[id]
+	if !ok {
+		return req, false
+	}
+	r.releaseLocked(req)
+	delete(r.started, id)
+	if len(r.started) == 0 && r.idle != nil {
+		close(r.idle)
+		r.idle = nil
+	}
+	return req, true
+}
+
+// releaseLocked stops req's timer, aborts the exchange carrying it and
+// forgets its progress token.
+func (r *inflightRequests) releaseLocked(req inflightRequest) {
+	if req.timer != nil {
+		req.timer.Stop()
+	}
+	if req.cancel != nil {
+		req.cancel()
+	}
+	if req.token != "" {
+		delete(r.progress, req.token)
+	}
+}
+
+// removeAll stops tracking every outstanding request and returns their IDs.
+func (r *inflightRequests) removeAll() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.started))
+	for id := range r.started {
+		r.removeLocked(id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// context returns a context derived from parent for sending request id,
+// cancelled once the request is answered, cancelled by the client or timed
+// out. Untracked requests get parent.
+func (r *inflightRequests) context(parent context.Context, id string) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.started[id]
+	if !ok || req.cancel != nil {
+		return parent
+	}
+	ctx, cancel := context.WithCancel(parent)
+	req.cancel = cancel
+	r.started[id] = req
+	return ctx
+}
+
+// expire stops tracking request id after it timed out and remembers it, so
+// a late response can be dropped.
+func (r *inflightRequests) expire(id string) (inflightRequest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.removeLocked(id)
+	if !ok {
+		return req, false
+	}
+	if r.expired == nil || len(r.expired) >= maxInflightRequests {
+		r.expired = make(map[string]struct{})
+	}
+	r.expired[id] = struct{}{}
+	return req, true
+}
+
+// late reports whether id is a request that timed out, forgetting it.
+func (r *inflightRequests) late(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.expired[id]; !ok {
+		return false
+	}
+	delete(r.expired, id)
+	return true
+}
+
+// anyExpired reports whether a timed out request may still be answered.
+func (r *inflightRequests) anyExpired() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.expired) > 0
+}
+
+func (r *inflightRequests) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.started)
+}
+
+// wait blocks until no requests are outstanding or ctx is done.
+func (r *inflightRequests) wait(ctx context.Context) error {
+	r.mu.Lock()
+	if len(r.started) == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	if r.idle == nil {
+		r.idle = make(chan struct{})
+	}
+	idle := r.idle
+	r.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackRequests records requests forwarded to the server and matches
+// responses to them, observing the request latency.
+func (p *Proxy) trackRequests(direction string, message []byte) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Name          string          `json:"name"`
+			RequestID     json.RawMessage `json:"requestId"`
+			ProgressToken json.RawMessage `json:"progressToken"`
+			Meta          struct {
+				ProgressToken json.RawMessage `json:"progressToken"`
+			} `json:"_meta"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return
+	}
+	id := string(msg.ID)
+
+	switch {
+	case direction == TraceLocalToRemote && msg.Method == "notifications/cancelled":
+		// The server need not answer a cancelled request.
+		p.inflight.remove(string(msg.Params.RequestID))
+	case direction == TraceLocalToRemote && msg.Method != "" && id != "":
+		p.inflight.add(id, msg.Method, msg.Params.Name, p.requestTimeout, func() { p.expireRequest(msg.ID) })
+		if token := string(msg.Params.Meta.ProgressToken); token != "" {
+			p.inflight.watchProgress(id, token)
+		}
+	case direction == TraceRemoteToLocal && msg.Method == "notifications/progress":
+		p.progressReceived(string(msg.Params.ProgressToken))
+	case direction == TraceRemoteToLocal && msg.Method == "" && id != "":
+		if req, ok := p.inflight.remove(id); ok {
+			elapsed := time.Since(req.at)
+			metrics.RequestDuration.ObserveDuration(elapsed, p.serverURL, req.method)
+			p.latency.observe(latencyKey(req.method, req.tool), elapsed)
+			if req.method == "initialize" {
+				p.recordInitializeResponse(message)
+			}
+		}
+	}
+}
+
+// requestContext returns the context to send message with. For a request,
+// it is cancelled when the request no longer needs an answer, aborting an
+// HTTP exchange still carrying it, e.g. a POST streaming its response.
+func (p *Proxy) requestContext(parent context.Context, message []byte) context.Context {
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
+		return parent
+	}
+	return p.inflight.context(parent, string(msg.ID))
+}
+
+// cancelledRequest reports whether err is the abort of a request the
+// client cancelled or that timed out, rather than a failure to report.
+func cancelledRequest(ctx, parent context.Context, err error) bool {
+	return err != nil && ctx != parent && ctx.Err() != nil && parent.Err() == nil
+}
+
+// expireRequest answers a request the server did not answer within the
+// request timeout and tells the server to stop working on it.
+func (p *Proxy) expireRequest(id json.RawMessage) {
+	req, ok := p.inflight.expire(string(id))
+	if !ok {
+		return
+	}
+	slog.Warn("request timed out", "id", string(id), "method", req.method, "timeout", p.requestTimeout)
+	p.deliver(newErrorMessage(id, jsonRPCRequestTimeout, fmt.Sprintf("request timed out after %s", p.requestTimeout)))
+
+	if p.currentTransport() == nil {
+		return
+	}
+	params, _ := json.Marshal(map[string]interface{}{"requestId": id, "reason": "request timed out"})
+	cancel, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params})
+	_ = p.sendToServer(cancel)
+}
+
+// lateResponse reports whether message answers a request that already timed
+// out; the client has been answered, so the message is dropped.
+func (p *Proxy) lateResponse(message []byte) bool {
+	if !p.inflight.anyExpired() {
+		return false
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isResponse() || !p.inflight.late(string(msg.ID)) {
+		return false
+	}
+	slog.Debug("dropping response to timed out request", "id", string(msg.ID))
+	return true
+}
+
+// drain stops forwarding new requests to the server and waits up to the
+// shutdown timeout for outstanding requests to be answered.
+func (p *Proxy) drain() {
+	if p.draining.Swap(true) || p.shutdownTimeout <= 0 {
+		return
+	}
+	n := p.inflight.count()
+	if n == 0 {
+		return
+	}
+
+	slog.Info("waiting for in-flight requests", "count", n, "timeout", p.shutdownTimeout)
+	ctx, cancel := context.WithTimeout(p.ctx, p.shutdownTimeout)
+	defer cancel()
+	if err := p.inflight.wait(ctx); err != nil {
+		slog.Warn("shutting down with unanswered requests", "count", p.inflight.count())
+	}
+}
+
+// rejectWhileDraining returns an error for requests sent after draining
+// began, so the client is answered instead of left waiting.
+func (p *Proxy) rejectWhileDraining(message []byte) error {
+	if !p.draining.Load() {
+		return nil
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
+		// Responses and notifications, e.g. cancellations, may still
+		// complete outstanding requests.
+		return nil
+	}
+	return &RPCError{Code: jsonRPCInternalError, Message: "proxy is shutting down"}
+}
@@ -0,0 +1,346 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInflightRequestsWait(t *testing.T) {
+	var r inflightRequests
+	if err := r.wait(t.Context()); err != nil {
+		t.Fatalf("Expected wait to return at once with no requests, got %v", err)
+	}
+
+	r.add("1", "tools/call", "", 0, nil)
+	r.add("2", "tools/call", "", 0, nil)
+	done := make(chan error, 1)
+	go func() { done <- r.wait(t.Context()) }()
+
+	r.remove("1")
+	select {
+	case err := <-done:
+		t.Fatalf("Expected wait to block with a request outstanding, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, ok := r.remove("2"); !ok {
+		t.Fatal("Expected request 2 to be tracked")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected wait to return once all requests completed")
+	}
+
+	r.add("3", "tools/call", "", 0, nil)
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.wait(ctx); err == nil {
+		t.Error("Expected an error when the context expires")
+	}
+}
+
+func TestInflightRequestsEvictOldestPastLimit(t *testing.T) {
+	var r inflightRequests
+	timerFired := make(chan struct{}, 1)
+	r.add("0", "tools/call", "", 50*time.Millisecond, func() { timerFired <- struct{}{} })
+	ctx := r.context(t.Context(), "0")
+	for i := 1; i < maxInflightRequests; i++ {
+		r.add(strconv.Itoa(i), "tools/call", "", 0, nil)
+	}
+
+	// Without a timeout, the oldest request makes room.
+	r.add("untimed", "tools/call", "", 0, nil)
+	if n := r.count(); n != maxInflightRequests {
+		t.Errorf("Expected %d tracked requests, got %d", maxInflightRequests, n)
+	}
+	if _, ok := r.remove("0"); ok {
+		t.Error("Expected the oldest request to be evicted")
+	}
+	if ctx.Err() == nil {
+		t.Error("Expected the evicted request's exchange to be aborted")
+	}
+	select {
+	case <-timerFired:
+		t.Error("Expected the evicted request's timeout to be stopped")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// With a timeout, the same applies and the timer still fires.
+	fired := make(chan struct{})
+	r.add("timed", "tools/call", "", 10*time.Millisecond, func() { close(fired) })
+	if _, ok := r.remove("1"); ok {
+		t.Error("Expected the next oldest request to be evicted")
+	}
+	if n := r.count(); n != maxInflightRequests {
+		t.Errorf("Expected %d tracked requests, got %d", maxInflightRequests, n)
+	}
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timeout to fire for a request past the limit")
+	}
+}
+
+func TestInflightRequestsReusedID(t *testing.T) {
+	var r inflightRequests
+	firstFired := make(chan struct{}, 1)
+	r.add("1", "tools/call", "slow", 50*time.Millisecond, func() { firstFired <- struct{}{} })
+	first := r.context(t.Context(), "1")
+
+	r.add("1", "tools/list", "", 0, nil)
+	if first.Err() == nil {
+		t.Error("Expected the replaced request's exchange to be aborted")
+	}
+	select {
+	case <-firstFired:
+		t.Error("Expected the replaced request's timeout to be stopped")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if second := r.context(t.Context(), "1"); second.Err() != nil {
+		t.Error("Expected a fresh context for the new request")
+	}
+	if req, ok := r.remove("1"); !ok || req.method != "tools/list" {
+		t.Errorf("Expected the new request tracked, got %+v, %v", req, ok)
+	}
+}
+
+func TestProxyShutdownDrainsInflightRequests(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var answered, deletedEarly atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		case http.MethodDelete:
+			if !answered.Load() {
+				deletedEarly.Store(true)
+			}
+			return
+		}
+		var msg struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		time.Sleep(200 * time.Millisecond)
+		answered.Store(true)
+		w.Header().Set("Mcp-Session-Id", "drain-session")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":{}}`))
+	}))
+	defer server.Close()
+
+	received := make(chan []byte, 2)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "drain-test", TransportModeStreamableHTTP, "",
+		WithMessageHandler(func(data []byte) { received <- data }),
+		WithShutdownTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	go func() {
+		_ = p.Send(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`))
+	}()
+	waitFor(t, func() bool { return p.inflight.count() == 1 })
+
+	shutdown := make(chan struct{})
+	go func() {
+		p.Shutdown()
+		close(shutdown)
+	}()
+	waitFor(t, p.draining.Load)
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	rejected := <-received
+	if !strings.Contains(string(rejected), `"id":2`) || !strings.Contains(string(rejected), "shutting down") {
+		t.Errorf("Expected request 2 to be rejected, got %s", rejected)
+	}
+
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Shutdown to return")
+	}
+	select {
+	case msg := <-received:
+		if !strings.Contains(string(msg), `"id":1`) {
+			t.Errorf("Expected the response to request 1, got %s", msg)
+		}
+	default:
+		t.Error("Expected the in-flight request to be answered before shutdown")
+	}
+	if deletedEarly.Load() {
+		t.Error("Expected the session to be deleted after the in-flight request completed")
+	}
+}
+
+func TestProxyShutdownTimeout(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "drain-timeout-test", TransportModeStreamableHTTP, "",
+		WithMessageHandler(func([]byte) {}),
+		WithShutdownTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	go func() {
+		_ = p.Send(p.ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`))
+	}()
+	waitFor(t, func() bool { return p.inflight.count() == 1 })
+
+	start := time.Now()
+	p.Shutdown()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Shutdown to give up after the timeout, took %v", elapsed)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProxyRequestTimeout(t *testing.T) {
+	transport := &batchTestTransport{}
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	var out safeBuffer
+	p := &Proxy{
+		ctx:            t.Context(),
+		transport:      transport,
+		framing:        framing,
+		stdioWriter:    bufio.NewWriter(&out),
+		requestTimeout: 20 * time.Millisecond,
+	}
+
+	_ = p.sendToServer([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`))
+	_ = p.sendToServer([]byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":2,"result":{}}`))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for out.Len() == len(`{"jsonrpc":"2.0","id":2,"result":{}}`)+1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected the response and a timeout error, got %q", lines)
+	}
+	var resp rpcMessage
+	if err := json.Unmarshal([]byte(lines[1]), &resp); err != nil || string(resp.ID) != "1" || resp.Error == nil || resp.Error.Code != jsonRPCRequestTimeout {
+		t.Errorf("Expected a -32001 error for request 1, got %s", lines[1])
+	}
+
+	transport.mu.Lock()
+	sent := append([]string(nil), transport.sent...)
+	transport.mu.Unlock()
+	if len(sent) != 3 || !strings.Contains(sent[2], "notifications/cancelled") || !strings.Contains(sent[2], `"requestId":1`) {
+		t.Errorf("Expected a cancellation sent to the server, got %q", sent)
+	}
+
+	// The server's late answer is dropped; the client already has one.
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	if got := strings.Count(out.String(), "\n"); got != 2 {
+		t.Errorf("Expected the late response to be dropped, got %s", out.String())
+	}
+	if p.inflight.count() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", p.inflight.count())
+	}
+}
+
+func TestProxyCancelAbortsRequest(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if msg.isNotification() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		// Stream the response, which never comes.
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer server.Close()
+
+	var delivered safeBuffer
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "cancel-test", TransportModeStreamableHTTP, "",
+		WithMessageHandler(func(data []byte) { _, _ = delivered.Write(data) }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer p.Shutdown()
+
+	if err := p.sendToServer([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	waitFor(t, func() bool { return p.inflight.count() == 1 })
+
+	if err := p.sendToServer([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)); err != nil {
+		t.Fatalf("Sending the cancellation failed: %v", err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the POST carrying the cancelled request to be aborted")
+	}
+	if n := p.inflight.count(); n != 0 {
+		t.Errorf("Expected no requests in flight, got %d", n)
+	}
+	if p.Status().LastError != "" || delivered.Len() != 0 {
+		t.Errorf("Expected the cancellation not reported as an error, got %q and %q", p.Status().LastError, delivered.String())
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"encoding/json"
+)
+
+// JSON-RPC 2.0 error codes used when the proxy answers a request itself.
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCInternalError  = -32603
+
+	// jsonRPCRequestTimeout answers a request the server did not answer
+	// within the request timeout.
+	jsonRPCRequestTimeout = -32001
+)
+
+// rpcMessage is a JSON-RPC 2.0 message as seen by the proxy. Fields the proxy
+// does not interpret are kept as raw JSON so they are forwarded unchanged.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC 2.0 error object.
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// isRequest reports whether m is a request (has both a method and an id).
+func (m *rpcMessage) isRequest() bool {
+	return m.Method != "" && m.ID != nil
+}
+
+// isNotification reports whether m is a notification (method without id).
+func (m *rpcMessage) isNotification() bool {
+	return m.Method != "" && m.ID == nil
+}
+
+// isResponse reports whether m is a response (id without method).
+func (m *rpcMessage) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
+
+// newResultMessage builds a JSON-RPC response carrying result.
+func newResultMessage(id json.RawMessage, result interface{}) ([]byte, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rpcMessage{JSONRPC: "2.0", ID: id, Result: raw})
+}
+
+// newErrorMessage builds a JSON-RPC error response.
+func newErrorMessage(id json.RawMessage, code int, message string) []byte {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	data, _ := json.Marshal(rpcMessage{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &rpcError{Code: code, Message: message},
+	})
+	return data
+}
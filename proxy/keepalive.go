@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// keepaliveMaxMissed is how many keepalive intervals may pass without a
+	// sign of life before the connection is considered dead.
+	keepaliveMaxMissed = 3
+
+	// keepaliveIDPrefix starts the request ID of keepalive pings, so their
+	// responses can be told apart from the client's.
+	keepaliveIDPrefix = "mcp-remote-go-keepalive-"
+)
+
+// errConnectionDead reports a connection that stopped showing signs of life
+// within the keepalive interval.
+var errConnectionDead = errors.New("connection is dead")
+
+// keepaliveDeadline is how long a connection may stay silent with interval.
+func keepaliveDeadline(interval time.Duration) time.Duration {
+	return keepaliveMaxMissed * interval
+}
+
+// keepalivePing returns an MCP ping request with id.
+func keepalivePing(id string) []byte {
+	data, _ := json.Marshal(map[string]string{"jsonrpc": "2.0", "id": id, "method": "ping"})
+	return data
+}
+
+// keepaliveResponseID returns the ID of a response to a keepalive ping, or
+// "" for any other message.
+func keepaliveResponseID(data []byte) string {
+	if !bytes.Contains(data, []byte(keepaliveIDPrefix)) {
+		return ""
+	}
+	var msg rpcMessage
+	if json.Unmarshal(data, &msg) != nil || !msg.isResponse() {
+		return ""
+	}
+	var id string
+	if json.Unmarshal(msg.ID, &id) != nil || !strings.HasPrefix(id, keepaliveIDPrefix) {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestKeepaliveResponseID(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`{"jsonrpc":"2.0","id":"mcp-remote-go-keepalive-3","result":{}}`, "mcp-remote-go-keepalive-3"},
+		{`{"jsonrpc":"2.0","id":"mcp-remote-go-keepalive-4","error":{"code":-32601,"message":"no"}}`, "mcp-remote-go-keepalive-4"},
+		{`{"jsonrpc":"2.0","id":"mcp-remote-go-keepalive-5","method":"ping"}`, ""},
+		{`{"jsonrpc":"2.0","id":1,"result":{"text":"mcp-remote-go-keepalive-1"}}`, ""},
+	}
+	for _, tt := range tests {
+		if got := keepaliveResponseID([]byte(tt.message)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.message, tt.want, got)
+		}
+	}
+}
+
+func TestEventSourceIdleTimeoutReconnects(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := connections.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "retry: 10\n\n")
+		if n > 1 {
+			_, _ = io.WriteString(w, "data: reconnected\n\n")
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	es := NewEventSource(req, server.Client())
+	es.IdleTimeout = 100 * time.Millisecond
+	received := make(chan string, 1)
+	es.OnMessage = func(_ string, data []byte) { received <- string(data) }
+	if err := es.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer es.Close()
+
+	select {
+	case data := <-received:
+		if data != "reconnected" {
+			t.Errorf("Unexpected event %q", data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected a silent stream to be reconnected, got %d connections", connections.Load())
+	}
+}
+
+func TestStreamableKeepalive(t *testing.T) {
+	var answer atomic.Bool
+	answer.Store(true)
+	var pings atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if msg.Method == "ping" {
+			pings.Add(1)
+		}
+		if !answer.Load() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:          server.URL,
+		Client:            server.Client(),
+		KeepaliveInterval: 20 * time.Millisecond,
+	})
+	var mu sync.Mutex
+	var delivered []string
+	transport.SetOnMessage(func(_ string, data []byte) {
+		mu.Lock()
+		delivered = append(delivered, string(data))
+		mu.Unlock()
+	})
+	dead := make(chan error, 1)
+	transport.SetOnError(func(err error) { dead <- err })
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	time.Sleep(150 * time.Millisecond)
+	if pings.Load() < 3 {
+		t.Errorf("Expected periodic pings, got %d", pings.Load())
+	}
+	mu.Lock()
+	if len(delivered) != 0 {
+		t.Errorf("Expected ping responses to be swallowed, got %q", delivered)
+	}
+	mu.Unlock()
+	select {
+	case err := <-dead:
+		t.Fatalf("Expected no error while pings are answered, got %v", err)
+	default:
+	}
+
+	answer.Store(false)
+	select {
+	case err := <-dead:
+		if !errors.Is(err, errConnectionDead) {
+			t.Errorf("Expected errConnectionDead, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected unanswered pings to be reported")
+	}
+}
+
+func TestWebSocketKeepaliveReconnects(t *testing.T) {
+	var connections atomic.Int32
+	server := newWebSocketEchoServer(t, func(conn *websocket.Conn) {
+		if connections.Add(1) == 1 {
+			// Never read, so pings are not answered.
+			time.Sleep(2 * time.Second)
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"notifications/reconnected"}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	transport := NewWebSocketTransport(WebSocketTransportConfig{
+		Endpoint:          server.URL,
+		Client:            &http.Client{},
+		KeepaliveInterval: 50 * time.Millisecond,
+	})
+	received := make(chan string, 1)
+	transport.SetOnMessage(func(_ string, data []byte) { received <- string(data) })
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "reconnected") {
+			t.Errorf("Unexpected message %q", data)
+		}
+	case <-time.After(4 * time.Second):
+		t.Fatalf("Expected an unresponsive connection to be replaced, got %d connections", connections.Load())
+	}
+}
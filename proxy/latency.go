@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxLatencySamples bounds the latencies kept per method for percentiles;
+// the oldest are replaced once it is reached.
+const maxLatencySamples = 1000
+
+// MethodLatency summarizes the latency of the requests of one method, or of
+// one tool for tools/call ("tools/call <name>"). Durations are in
+// milliseconds. P50 and P95 are taken over the most recent requests.
+type MethodLatency struct {
+	Method string  `json:"method"`
+	Count  uint64  `json:"count"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// latencyStats records request latencies by method.
+type latencyStats struct {
+	mu      sync.Mutex
+	methods map[string]*methodLatency
+}
+
+type methodLatency struct {
+	count   uint64
+	max     time.Duration
+	samples []time.Duration // ring of the latest maxLatencySamples
+	next    int
+}
+
+func (s *latencyStats) observe(method string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.methods == nil {
+		s.methods = make(map[string]*methodLatency)
+	}
+	m := s.methods[method]
+	if m == nil {
+		m = &methodLatency{}
+		s.methods[method] = m
+	}
+	m.count++
+	m.max = max(m.max, d)
+	if len(m.samples) < maxLatencySamples {
+		m.samples = append(m.samples, d)
+		return
+	}
+	m.samples[m.next] = d
+	m.next = (m.next + 1) % maxLatencySamples
+}
+
+// summary returns the latency of every method seen, slowest p95 first.
+func (s *latencyStats) summary() []MethodLatency {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]MethodLatency, 0, len(s.methods))
+	for method, m := range s.methods {
+		sorted := slices.Clone(m.samples)
+		slices.Sort(sorted)
+		out = append(out, MethodLatency{
+			Method: method,
+			Count:  m.count,
+			P50:    milliseconds(percentile(sorted, 50)),
+			P95:    milliseconds(percentile(sorted, 95)),
+			Max:    milliseconds(m.max),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].P95 != out[j].P95 {
+			return out[i].P95 > out[j].P95
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// percentile returns the nearest-rank pth percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// latencyKey is the method requests are summarized under: tools/call is
+// broken down by tool, so slow tools stand out.
+func latencyKey(method, tool string) string {
+	if method == "tools/call" && tool != "" {
+		return method + " " + tool
+	}
+	return method
+}
+
+// WriteLatencySummary writes a table of the request latencies in statuses
+// to w, with a server column when there are several servers. Nothing is
+// written if no request was answered.
+func WriteLatencySummary(w io.Writer, statuses []Status) error {
+	var rows int
+	for _, st := range statuses {
+		rows += len(st.Latency)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	perServer := len(statuses) > 1
+	if perServer {
+		_, _ = fmt.Fprint(tw, "SERVER\t")
+	}
+	_, _ = fmt.Fprintln(tw, "METHOD\tCOUNT\tP50 (ms)\tP95 (ms)\tMAX (ms)")
+	for _, st := range statuses {
+		server := st.Name
+		if server == "" {
+			server = st.Server
+		}
+		for _, l := range st.Latency {
+			if perServer {
+				_, _ = fmt.Fprintf(tw, "%s\t", server)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t%.1f\n", l.Method, l.Count, l.P50, l.P95, l.Max)
+		}
+	}
+	return tw.Flush()
+}
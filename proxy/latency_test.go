@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyStatsSummary(t *testing.T) {
+	var s latencyStats
+	for i := 1; i <= 100; i++ {
+		s.observe("tools/call build", time.Duration(i)*time.Millisecond)
+	}
+	s.observe("tools/list", 5*time.Millisecond)
+
+	summary := s.summary()
+	if len(summary) != 2 || summary[0].Method != "tools/call build" {
+		t.Fatalf("Expected the slowest method first, got %+v", summary)
+	}
+	if got := summary[0]; got.Count != 100 || got.P50 != 50 || got.P95 != 95 || got.Max != 100 {
+		t.Errorf("Unexpected percentiles %+v", got)
+	}
+	if got := summary[1]; got.Count != 1 || got.P50 != 5 || got.P95 != 5 || got.Max != 5 {
+		t.Errorf("Unexpected single sample summary %+v", got)
+	}
+}
+
+func TestLatencyStatsBoundsSamples(t *testing.T) {
+	var s latencyStats
+	s.observe("ping", time.Second)
+	for range maxLatencySamples {
+		s.observe("ping", time.Millisecond)
+	}
+	got := s.summary()[0]
+	if got.Count != maxLatencySamples+1 || got.P95 != 1 || got.Max != 1000 {
+		t.Errorf("Expected old samples to leave the percentiles but not the max, got %+v", got)
+	}
+}
+
+func TestProxyRecordsLatencyByTool(t *testing.T) {
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	var out safeBuffer
+	p := &Proxy{
+		ctx:         t.Context(),
+		transport:   &batchTestTransport{},
+		framing:     framing,
+		stdioWriter: bufio.NewWriter(&out),
+	}
+
+	_ = p.sendToServer([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"build"}}`))
+	_ = p.sendToServer([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":2,"result":{"tools":[]}}`))
+	time.Sleep(10 * time.Millisecond)
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+
+	latency := p.Status().Latency
+	if len(latency) != 2 || latency[0].Method != "tools/call build" || latency[1].Method != "tools/list" {
+		t.Fatalf("Expected latency for the tool and tools/list, got %+v", latency)
+	}
+	if latency[0].Max < 10 {
+		t.Errorf("Expected at least 10ms for the tool call, got %+v", latency[0])
+	}
+}
+
+func TestWriteLatencySummary(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLatencySummary(&buf, []Status{{Server: "https://example.com/mcp"}}); err != nil || buf.Len() != 0 {
+		t.Errorf("Expected nothing written without requests, got %q, %v", buf.String(), err)
+	}
+
+	statuses := []Status{
+		{Name: "docs", Latency: []MethodLatency{{Method: "tools/call search", Count: 3, P50: 12, P95: 40.5, Max: 41}}},
+		{Name: "files", Latency: []MethodLatency{{Method: "tools/list", Count: 1, P50: 2, P95: 2, Max: 2}}},
+	}
+	if err := WriteLatencySummary(&buf, statuses); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "SERVER") || !strings.Contains(lines[1], "tools/call search") || !strings.Contains(lines[1], "40.5") || !strings.HasPrefix(lines[2], "files") {
+		t.Errorf("Unexpected summary:\n%s", buf.String())
+	}
+}
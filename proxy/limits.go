@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultMaxEventSize is the largest SSE event, in bytes of data, accepted
+// from a server by default.
+const DefaultMaxEventSize = 64 << 20
+
+// DefaultMaxBodySize is the largest JSON response body or WebSocket
+// message, in bytes, accepted from a server by default.
+const DefaultMaxBodySize = 64 << 20
+
+// maxErrorBodySize is the most of an error response's body read to quote
+// in the error.
+const maxErrorBodySize = 4 << 10
+
+// orDefault returns size, or def when size is not positive.
+func orDefault(size, def int) int {
+	if size <= 0 {
+		return def
+	}
+	return size
+}
+
+// readResponseBody reads a response body of at most limit bytes. A larger
+// body is not buffered but reported as ErrMessageTooLarge.
+func readResponseBody(body io.Reader, limit int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("%w: response body over %d bytes", ErrMessageTooLarge, limit)
+	}
+	return data, nil
+}
+
+// readErrorBody returns the start of an error response's body, for the
+// error message.
+func readErrorBody(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize))
+	return string(data)
+}
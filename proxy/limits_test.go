@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadResponseBody(t *testing.T) {
+	data, err := readResponseBody(strings.NewReader("0123456789"), 10)
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("Expected a body at the limit to be read, got %q, %v", data, err)
+	}
+	if _, err := readResponseBody(strings.NewReader("0123456789x"), 10); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+	if body := readErrorBody(strings.NewReader(strings.Repeat("e", 2*maxErrorBodySize))); len(body) != maxErrorBodySize {
+		t.Errorf("Expected the error body cut to %d bytes, got %d", maxErrorBodySize, len(body))
+	}
+}
+
+func TestStreamableHTTPMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"text":"` + strings.Repeat("x", 1024) + `"}}`))
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:    server.URL,
+		Client:      &http.Client{},
+		MaxBodySize: 512,
+	})
+	transport.SetOnMessage(func(string, []byte) {
+		t.Error("an oversized body should not be dispatched")
+	})
+	err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","method":"tools/call","id":1}`))
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestStreamableHTTPOversizedResponseEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n")
+		_, _ = fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":2,\"result\":{\"text\":\"%s\"}}\n\n", strings.Repeat("x", 1024))
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:     server.URL,
+		Client:       &http.Client{},
+		MaxEventSize: 512,
+	})
+	messages := make(chan string, 4)
+	transport.SetOnMessage(func(_ string, data []byte) { messages <- string(data) })
+	transport.SetOnError(func(err error) {
+		t.Errorf("Expected the connection to be left alone, got onError(%v)", err)
+	})
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"tools/call"}]`
+	if err := transport.Send(t.Context(), []byte(batch)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	for _, want := range []string{`"id":1,"result"`, `"id":2,"error"`} {
+		select {
+		case got := <-messages:
+			if !strings.Contains(got, want) {
+				t.Errorf("Expected a message with %s, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected a message with %s", want)
+		}
+	}
+	select {
+	case got := <-messages:
+		t.Errorf("Expected no further messages, got %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
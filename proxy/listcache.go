@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listChanged maps each list_changed notification to the listing it makes
+// stale.
+var listChanged = map[string]string{
+	"notifications/tools/list_changed":     "tools/list",
+	"notifications/resources/list_changed": "resources/list",
+	"notifications/prompts/list_changed":   "prompts/list",
+}
+
+// listCache answers repeated tools/list, resources/list and prompts/list
+// requests from the server's last result for up to ttl. Entries are keyed
+// by method and cursor, so each page is cached separately.
+type listCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]listEntry
+	pending map[string]string // request ID -> cache key
+}
+
+type listEntry struct {
+	result  json.RawMessage
+	expires time.Time
+}
+
+// newListCache returns a cache holding results for ttl, or nil when ttl is
+// not positive.
+func newListCache(ttl time.Duration) *listCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &listCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]listEntry),
+		pending: make(map[string]string),
+	}
+}
+
+// listCacheKey returns the cache key of a listing request, or "" if msg is
+// not one.
+func listCacheKey(msg *rpcMessage) string {
+	switch msg.Method {
+	case "tools/list", "resources/list", "prompts/list":
+	default:
+		return ""
+	}
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	_ = json.Unmarshal(msg.Params, &params)
+	return msg.Method + "\x00" + params.Cursor
+}
+
+// lookup returns the cached result for a request from the client.
+func (c *listCache) lookup(msg *rpcMessage) (json.RawMessage, bool) {
+	key := listCacheKey(msg)
+	if key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// track notes a request forwarded to the server so its result can be
+// cached. An initialize starts a new session, so it empties the cache.
+func (c *listCache) track(msg *rpcMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Method == "initialize" {
+		c.entries = make(map[string]listEntry)
+		return
+	}
+	if key := listCacheKey(msg); key != "" {
+		c.pending[string(msg.ID)] = key
+	}
+}
+
+// observe stores the result of a tracked request and drops the listings a
+// list_changed notification makes stale.
+func (c *listCache) observe(msg *rpcMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if method, ok := listChanged[msg.Method]; ok && msg.isNotification() {
+		for key := range c.entries {
+			if strings.HasPrefix(key, method+"\x00") {
+				delete(c.entries, key)
+			}
+		}
+		return
+	}
+	if !msg.isResponse() {
+		return
+	}
+	key, ok := c.pending[string(msg.ID)]
+	if !ok {
+		return
+	}
+	delete(c.pending, string(msg.ID))
+	if msg.Error == nil && msg.Result != nil {
+		c.entries[key] = listEntry{result: msg.Result, expires: c.now().Add(c.ttl)}
+	}
+}
+
+// clear drops every cached listing.
+func (c *listCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]listEntry)
+}
+
+// answerFromListCache answers a listing request from the cache and reports
+// whether it did.
+func (p *Proxy) answerFromListCache(message []byte) bool {
+	if p.listCache == nil {
+		return false
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
+		return false
+	}
+	result, ok := p.listCache.lookup(&msg)
+	if !ok {
+		return false
+	}
+	slog.Debug("answering from list cache", "method", msg.Method)
+	reply, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	p.deliver(reply)
+	return true
+}
+
+// trackForListCache notes a request forwarded to the server.
+func (p *Proxy) trackForListCache(message []byte) {
+	if p.listCache == nil {
+		return
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) == nil && msg.isRequest() {
+		p.listCache.track(&msg)
+	}
+}
+
+// observeForListCache passes a message for the client to the cache.
+func (p *Proxy) observeForListCache(message []byte) {
+	if p.listCache == nil {
+		return
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) == nil {
+		p.listCache.observe(&msg)
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newListCacheTestProxy(t *testing.T) (*Proxy, *batchTestTransport, func() int, *time.Time) {
+	t.Helper()
+	transport := &batchTestTransport{}
+	p, out := newBatchTestProxy(transport)
+	p.listCache = newListCache(time.Minute)
+	now := time.Now()
+	p.listCache.now = func() time.Time { return now }
+	return p, transport, func() int {
+		_ = p.stdioWriter.Flush()
+		return strings.Count(out.String(), "\n")
+	}, &now
+}
+
+func TestListCacheAnswersRepeatedListing(t *testing.T) {
+	p, transport, replies, now := newListCacheTestProxy(t)
+
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)) == nil {
+		t.Fatal("Expected the first listing to be forwarded")
+	}
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"a"}]}}`))
+
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)) != nil {
+		t.Error("Expected the repeated listing to be answered from the cache")
+	}
+	if n := replies(); n != 2 {
+		t.Errorf("Expected two replies to the client, got %d", n)
+	}
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list","params":{"cursor":"next"}}`)) == nil {
+		t.Error("Expected another page to be forwarded")
+	}
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":4,"method":"prompts/list"}`)) == nil {
+		t.Error("Expected another listing to be forwarded")
+	}
+
+	*now = now.Add(time.Minute)
+	if p.outgoing([]byte(`{"jsonrpc":"2.0","id":5,"method":"tools/list"}`)) == nil {
+		t.Error("Expected an expired entry to be forwarded")
+	}
+	if len(transport.sent) != 0 {
+		t.Errorf("Expected outgoing not to send, got %q", transport.sent)
+	}
+}
+
+func TestListCacheInvalidation(t *testing.T) {
+	p, _, _, _ := newListCacheTestProxy(t)
+	list := func(id string) []byte {
+		return p.outgoing([]byte(`{"jsonrpc":"2.0","id":` + id + `,"method":"resources/list"}`))
+	}
+
+	list("1")
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{"resources":[]}}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+	if list("2") != nil {
+		t.Error("Expected a tools change to keep the resource listing")
+	}
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/resources/list_changed"}`))
+	if list("3") == nil {
+		t.Error("Expected a resources change to drop the resource listing")
+	}
+
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":3,"error":{"code":-32603,"message":"busy"}}`))
+	if list("4") == nil {
+		t.Error("Expected errors not to be cached")
+	}
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":4,"result":{"resources":[]}}`))
+	p.outgoing([]byte(`{"jsonrpc":"2.0","id":5,"method":"initialize","params":{}}`))
+	if list("6") == nil {
+		t.Error("Expected initialize to empty the cache")
+	}
+}
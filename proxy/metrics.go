@@ -0,0 +1,16 @@
+package proxy
+
+import "github.com/naotama2002/mcp-remote-go/internal/metrics"
+
+// recordMetrics counts the message, or each message of a batch, and tracks
+// requests for the latency histogram.
+func (p *Proxy) recordMetrics(direction string, message []byte) {
+	if elements, ok := splitBatch(message); ok {
+		for _, element := range elements {
+			p.recordMetrics(direction, element)
+		}
+		return
+	}
+	metrics.MessagesTotal.Inc(p.serverURL, direction)
+	p.trackRequests(direction, message)
+}
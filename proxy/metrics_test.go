@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+)
+
+func TestProxyRecordsMetrics(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":7,"result":{}}`))
+	}))
+	defer server.Close()
+
+	received := make(chan []byte, 1)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "metrics-test", TransportModeStreamableHTTP, "",
+		WithMessageHandler(func(data []byte) { received <- data }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a response")
+	}
+
+	if got := metrics.MessagesTotal.Value(server.URL, TraceLocalToRemote); got != 1 {
+		t.Errorf("Expected 1 message sent, got %v", got)
+	}
+	if got := metrics.MessagesTotal.Value(server.URL, TraceRemoteToLocal); got != 1 {
+		t.Errorf("Expected 1 message received, got %v", got)
+	}
+	if got := metrics.RequestDuration.Count(server.URL, "tools/list"); got != 1 {
+		t.Errorf("Expected 1 tools/list latency observation, got %d", got)
+	}
+	if n := p.inflight.count(); n != 0 {
+		t.Errorf("Expected no outstanding requests, got %d", n)
+	}
+}
+
+func TestProxyRecordsTransportFallback(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("event: endpoint\ndata: /messages\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "fallback-metrics-test", TransportModeAuto, "",
+		WithMessageHandler(func([]byte) {}))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if got := metrics.TransportFallbacksTotal.Value(server.URL, string(TransportModeStreamableHTTP), string(TransportModeSSE)); got != 1 {
+		t.Errorf("Expected 1 fallback to SSE, got %v", got)
+	}
+}
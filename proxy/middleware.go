@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+)
+
+// Middleware inspects or transforms a JSON-RPC message passing through the
+// proxy. direction is TraceLocalToRemote or TraceRemoteToLocal.
+//
+// The returned message replaces the original; returning nil drops it. An
+// error also drops the message; when the message is a request sent to the
+// server, the client is answered with a JSON-RPC error instead. Return an
+// *RPCError to choose the error code.
+type Middleware func(direction string, message []byte) ([]byte, error)
+
+// RPCError is a JSON-RPC error returned by a Middleware.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// Use appends middleware to the chain. Messages to the server pass through
+// the chain in order, and messages from the server in reverse order. Use
+// must be called before Start or Connect.
+func (p *Proxy) Use(mw ...Middleware) {
+	p.middleware = append(p.middleware, mw...)
+}
+
+// applyMiddleware runs message through the chain for direction. It returns
+// nil when a middleware dropped the message.
+func (p *Proxy) applyMiddleware(direction string, message []byte) ([]byte, error) {
+	n := len(p.middleware)
+	for i := range n {
+		mw := p.middleware[i]
+		if direction == TraceRemoteToLocal {
+			mw = p.middleware[n-1-i]
+		}
+
+		var err error
+		if message, err = mw(direction, message); err != nil {
+			return nil, err
+		}
+		if message == nil {
+			return nil, nil
+		}
+	}
+	return message, nil
+}
+
+// outgoing runs a message from the client through the middleware chain and
+// returns what to forward to the server, or nil. Rejected requests are
+// answered here.
+func (p *Proxy) outgoing(message []byte) []byte {
+	if p.answerInitialize(message) || p.answerFromListCache(message) {
+		return nil
+	}
+	out, reply := p.filterOutgoing(message)
+	if reply != nil {
+		p.deliver(reply)
+	}
+	if out != nil {
+		p.trackForListCache(out)
+	}
+	return out
+}
+
+// filterOutgoing runs a message from the client through the middleware
+// chain. It returns what to forward to the server, or, for a rejected
+// request, the error response to answer it with.
+func (p *Proxy) filterOutgoing(message []byte) (out, reply []byte) {
+	if p.strict {
+		if err := validateMessage(message); err != nil {
+			slog.Warn("rejecting invalid message from client", "error", err)
+			return nil, invalidMessageResponse(message, err)
+		}
+	}
+
+	err := p.rejectWhileDraining(message)
+	if err == nil {
+		if out, err = p.applyMiddleware(TraceLocalToRemote, message); err == nil {
+			if out != nil {
+				out = p.clientInfoRequest(p.protocolRequest(out))
+			}
+			return out, nil
+		}
+	}
+
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
+		slog.Debug("middleware dropped message", "error", err)
+		return nil, nil
+	}
+	code := jsonRPCInternalError
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		code = rpcErr.Code
+	}
+	return nil, newErrorMessage(msg.ID, code, err.Error())
+}
+
+// incoming runs a message from the server through the middleware chain and
+// returns what to deliver to the client, or nil.
+func (p *Proxy) incoming(message []byte) []byte {
+	out, err := p.applyMiddleware(TraceRemoteToLocal, message)
+	if err != nil {
+		slog.Warn("middleware dropped message from server", "error", err)
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApplyMiddlewareOrder(t *testing.T) {
+	p := &Proxy{}
+	var order []string
+	tag := func(name string) Middleware {
+		return func(direction string, message []byte) ([]byte, error) {
+			order = append(order, name)
+			return append(message, name...), nil
+		}
+	}
+	p.Use(tag("a"), tag("b"))
+
+	out, err := p.applyMiddleware(TraceLocalToRemote, []byte("m"))
+	if err != nil || string(out) != "mab" {
+		t.Errorf("Expected 'mab' for outgoing, got %q (err %v)", out, err)
+	}
+	out, err = p.applyMiddleware(TraceRemoteToLocal, []byte("m"))
+	if err != nil || string(out) != "mba" {
+		t.Errorf("Expected 'mba' for incoming, got %q (err %v)", out, err)
+	}
+	if strings.Join(order, "") != "abba" {
+		t.Errorf("Expected call order 'abba', got %q", strings.Join(order, ""))
+	}
+}
+
+func TestApplyMiddlewareDrop(t *testing.T) {
+	p := &Proxy{}
+	called := false
+	p.Use(
+		func(string, []byte) ([]byte, error) { return nil, nil },
+		func(_ string, m []byte) ([]byte, error) { called = true; return m, nil },
+	)
+	if out, err := p.applyMiddleware(TraceLocalToRemote, []byte("m")); out != nil || err != nil {
+		t.Errorf("Expected message to be dropped, got %q (err %v)", out, err)
+	}
+	if called {
+		t.Error("Expected the chain to stop after a drop")
+	}
+}
+
+func TestProxyMiddleware(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(r.Body)
+		mu.Lock()
+		bodies = append(bodies, buf.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"secret":"s3cret"}}`))
+	}))
+	defer server.Close()
+
+	redact := func(direction string, message []byte) ([]byte, error) {
+		if direction == TraceRemoteToLocal {
+			return bytes.ReplaceAll(message, []byte("s3cret"), []byte("[redacted]")), nil
+		}
+		if bytes.Contains(message, []byte(`"forbidden"`)) {
+			return nil, &RPCError{Code: -32001, Message: "method not permitted"}
+		}
+		if bytes.Contains(message, []byte(`"failing"`)) {
+			return nil, errors.New("middleware failed")
+		}
+		return bytes.ReplaceAll(message, []byte("ping"), []byte("tools/list")), nil
+	}
+
+	received := make(chan []byte, 3)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "middleware-test", TransportModeStreamableHTTP, "",
+		WithMiddleware(redact),
+		WithMessageHandler(func(data []byte) { received <- data }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case data := <-received:
+			if !strings.Contains(string(data), want) {
+				t.Errorf("Expected message containing %q, got %s", want, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected message containing %q", want)
+		}
+	}
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	expect(`"secret":"[redacted]"`)
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2,"method":"forbidden"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	expect(`"code":-32001`)
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":3,"method":"failing"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	expect(`"code":-32603`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"method":"tools/list"`) {
+		t.Errorf("Expected only the rewritten request to reach the server, got %v", bodies)
+	}
+}
@@ -0,0 +1,452 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Multiplexer serves several MCP client sessions through one Proxy, so they
+// share its remote connection and authorization. Request IDs are rewritten
+// so sessions cannot collide. The first initialize is forwarded and later
+// ones are answered with its result. Server notifications go to every
+// session and server requests to the oldest one.
+type Multiplexer struct {
+	proxy    *Proxy
+	listener net.Listener
+
+	mu             sync.Mutex
+	sessions       map[int64]*muxSession
+	nextSession    int64
+	nextID         int64
+	pending        map[string]muxPending
+	serverRequests map[string]*muxSession
+	init           *muxInit
+	initialized    bool
+
+	// persistent keeps the multiplexer running when the last session
+	// ends, for clients that come and go, such as HTTP sessions.
+	persistent bool
+
+	// idle is closed when the last session ends. A client may still attach
+	// before Shutdown closes the listener, so its session can end the
+	// multiplexer a second time.
+	idle      chan struct{}
+	idleOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// muxSession is one client attached to a Multiplexer.
+type muxSession struct {
+	id    int64
+	write func(data []byte)
+
+	// ids maps the client's request IDs to the forwarded ones, so its
+	// cancellations can be rewritten.
+	ids map[string]string
+}
+
+// muxPending is a forwarded request waiting for the server's response.
+type muxPending struct {
+	session *muxSession
+	id      json.RawMessage
+
+	// onResponse, when set, sees the server's response first.
+	onResponse func(resp *rpcMessage)
+}
+
+// muxInit is the shared initialize exchange. Sessions that initialize while
+// it is outstanding wait for its result.
+type muxInit struct {
+	done    bool
+	result  json.RawMessage
+	waiters []muxPending
+}
+
+// NewMultiplexer returns a Multiplexer that serves the proxy's stdio as its
+// first session and clients connecting to listener as further sessions.
+func NewMultiplexer(p *Proxy, listener net.Listener) *Multiplexer {
+	m := newMultiplexer(p)
+	m.listener = listener
+	return m
+}
+
+// newMultiplexer returns a Multiplexer routing the proxy's server messages
+// to its sessions, without any session yet.
+func newMultiplexer(p *Proxy) *Multiplexer {
+	m := &Multiplexer{
+		proxy:          p,
+		sessions:       make(map[int64]*muxSession),
+		pending:        make(map[string]muxPending),
+		serverRequests: make(map[string]*muxSession),
+		idle:           make(chan struct{}),
+	}
+	p.messageSink = m.handleServerMessage
+	return m
+}
+
+// Start connects to the server and serves sessions until the last one ends.
+func (m *Multiplexer) Start() error {
+	slog.Info("starting shared MCP proxy", "server", m.proxy.serverURL, "socket", m.listener.Addr().String())
+	if err := m.proxy.Connect(); err != nil {
+		_ = m.listener.Close()
+		return err
+	}
+
+	writerMu := &sync.Mutex{}
+	m.serve(m.proxy.stdioReader, func(data []byte) {
+		writerMu.Lock()
+		defer writerMu.Unlock()
+		if err := m.proxy.framing.writeMessage(m.proxy.stdioWriter, data); err != nil {
+			slog.Error("failed to write to STDIO", "error", err)
+		}
+	}, m.proxy.framing, nil)
+	go m.accept()
+
+	select {
+	case <-m.idle:
+		slog.Info("no clients left, stopping shared proxy")
+	case <-m.proxy.ctx.Done():
+	}
+	m.Shutdown()
+	return nil
+}
+
+// Shutdown stops accepting clients and shuts the proxy down.
+func (m *Multiplexer) Shutdown() {
+	m.closeOnce.Do(func() {
+		_ = m.listener.Close()
+		m.proxy.Shutdown()
+	})
+}
+
+// accept serves every client that connects to the listener.
+func (m *Multiplexer) accept() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("failed to accept client", "error", err)
+			}
+			return
+		}
+		framing, _ := newStdioFraming(FramingNewline, m.proxy.framing.maxSize)
+		w := bufio.NewWriter(conn)
+		writerMu := &sync.Mutex{}
+		m.serve(bufio.NewReader(conn), func(data []byte) {
+			writerMu.Lock()
+			defer writerMu.Unlock()
+			if err := framing.writeMessage(w, data); err != nil {
+				slog.Debug("failed to write to client", "error", err)
+			}
+		}, framing, conn)
+	}
+}
+
+// serve adds a session for a client and reads its messages until it
+// disconnects. closer, if set, is closed when the session ends.
+func (m *Multiplexer) serve(r *bufio.Reader, write func([]byte), framing *stdioFraming, closer io.Closer) {
+	s := m.addSession(write)
+	go func() {
+		defer func() {
+			if closer != nil {
+				_ = closer.Close()
+			}
+			m.removeSession(s)
+		}()
+		for {
+			message, err := framing.readMessage(r)
+			if len(message) > 0 {
+				m.handleClientMessage(s, message)
+			}
+			if err != nil {
+				if err == io.EOF || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				if errors.Is(err, ErrMessageTooLarge) {
+					slog.Error("failed to read from client", "session", s.id, "error", err)
+					continue
+				}
+				slog.Debug("client disconnected", "session", s.id, "error", err)
+				return
+			}
+		}
+	}()
+}
+
+func (m *Multiplexer) addSession(write func([]byte)) *muxSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextSession++
+	s := &muxSession{id: m.nextSession, write: write, ids: make(map[string]string)}
+	m.sessions[s.id] = s
+	slog.Info("client session started", "session", s.id, "sessions", len(m.sessions))
+	return s
+}
+
+// removeSession ends a session. Its outstanding requests are cancelled and
+// server requests waiting on it are failed.
+func (m *Multiplexer) removeSession(s *muxSession) {
+	m.mu.Lock()
+	delete(m.sessions, s.id)
+	remaining := len(m.sessions)
+	var cancelled []string
+	for id, p := range m.pending {
+		if p.session == s {
+			delete(m.pending, id)
+			cancelled = append(cancelled, id)
+		}
+	}
+	var unanswered []string
+	for id, target := range m.serverRequests {
+		if target == s {
+			delete(m.serverRequests, id)
+			unanswered = append(unanswered, id)
+		}
+	}
+	m.mu.Unlock()
+	slog.Info("client session ended", "session", s.id, "sessions", remaining)
+
+	for _, id := range cancelled {
+		params, _ := json.Marshal(map[string]interface{}{"requestId": json.RawMessage(id), "reason": "client disconnected"})
+		m.sendToServer(rpcMessage{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params})
+	}
+	for _, id := range unanswered {
+		m.sendRaw(newErrorMessage(json.RawMessage(id), jsonRPCInternalError, "client disconnected"))
+	}
+	if remaining == 0 && !m.persistent {
+		m.idleOnce.Do(func() { close(m.idle) })
+	}
+}
+
+// handleClientMessage forwards one message from session s to the server.
+func (m *Multiplexer) handleClientMessage(s *muxSession, data []byte) {
+	if elements, ok := splitBatch(data); ok {
+		if len(elements) == 0 {
+			s.write(newErrorMessage(nil, jsonRPCInvalidRequest, "empty batch"))
+		}
+		for _, element := range elements {
+			m.handleClientMessage(s, element)
+		}
+		return
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.write(newErrorMessage(nil, jsonRPCParseError, "parse error"))
+		return
+	}
+
+	switch {
+	case msg.isRequest() && msg.Method == "initialize":
+		m.initialize(s, &msg)
+	case msg.isRequest():
+		m.forwardRequest(s, &msg, nil)
+	case msg.isNotification():
+		m.forwardNotification(s, &msg)
+	case msg.isResponse():
+		m.mu.Lock()
+		delete(m.serverRequests, string(msg.ID))
+		m.mu.Unlock()
+		m.sendRaw(data)
+	default:
+		s.write(newErrorMessage(msg.ID, jsonRPCInvalidRequest, "invalid request"))
+	}
+}
+
+// initialize forwards the first initialize request and answers later ones
+// with its result, as all sessions share the server's session.
+func (m *Multiplexer) initialize(s *muxSession, msg *rpcMessage) {
+	m.mu.Lock()
+	switch {
+	case m.init == nil:
+		m.init = &muxInit{}
+		m.mu.Unlock()
+		m.forwardRequest(s, msg, m.initializeDone)
+	case !m.init.done:
+		m.init.waiters = append(m.init.waiters, muxPending{session: s, id: msg.ID})
+		m.mu.Unlock()
+	default:
+		result := m.init.result
+		m.mu.Unlock()
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		s.write(data)
+	}
+}
+
+// initializeDone records the server's answer to initialize and passes it
+// to the sessions waiting for it. After an error the next initialize is
+// forwarded again.
+func (m *Multiplexer) initializeDone(resp *rpcMessage) {
+	m.mu.Lock()
+	waiters := m.init.waiters
+	if resp.Error != nil {
+		m.init = nil
+	} else {
+		m.init = &muxInit{done: true, result: resp.Result}
+	}
+	m.mu.Unlock()
+
+	for _, w := range waiters {
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: w.id, Result: resp.Result, Error: resp.Error})
+		w.session.write(data)
+	}
+}
+
+// forwardRequest sends a request to the server under a new ID.
+func (m *Multiplexer) forwardRequest(s *muxSession, msg *rpcMessage, onResponse func(*rpcMessage)) {
+	m.mu.Lock()
+	m.nextID++
+	id := fmt.Sprintf(`"mux-%d"`, m.nextID)
+	m.pending[id] = muxPending{session: s, id: msg.ID, onResponse: onResponse}
+	s.ids[string(msg.ID)] = id
+	m.mu.Unlock()
+
+	out := *msg
+	out.ID = json.RawMessage(id)
+	if err := m.sendToServer(out); err != nil {
+		if p, ok := m.take(id); ok {
+			m.respond(p, &rpcMessage{Error: &rpcError{Code: jsonRPCInternalError, Message: "failed to send to server: " + err.Error()}})
+		}
+	}
+}
+
+// forwardNotification sends a client notification to the server. Only the
+// first notifications/initialized is forwarded, and cancellations are
+// rewritten to the forwarded request ID.
+func (m *Multiplexer) forwardNotification(s *muxSession, msg *rpcMessage) {
+	switch msg.Method {
+	case "notifications/initialized":
+		m.mu.Lock()
+		sent := m.initialized
+		m.initialized = true
+		m.mu.Unlock()
+		if sent {
+			return
+		}
+	case "notifications/cancelled":
+		var params map[string]json.RawMessage
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return
+		}
+		m.mu.Lock()
+		id, ok := s.ids[string(params["requestId"])]
+		if ok {
+			delete(s.ids, string(params["requestId"]))
+			delete(m.pending, id)
+		}
+		m.mu.Unlock()
+		if !ok {
+			return
+		}
+		params["requestId"] = json.RawMessage(id)
+		msg.Params, _ = json.Marshal(params)
+	}
+	_ = m.sendToServer(*msg)
+}
+
+// handleServerMessage routes a message from the server: responses to the
+// session that sent the request, requests to the oldest session and
+// notifications to every session.
+func (m *Multiplexer) handleServerMessage(data []byte) {
+	if elements, ok := splitBatch(data); ok {
+		for _, element := range elements {
+			m.handleServerMessage(element)
+		}
+		return
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	switch {
+	case msg.isResponse():
+		p, ok := m.take(string(msg.ID))
+		if !ok {
+			slog.Debug("dropping response to unknown request", "id", string(msg.ID))
+			return
+		}
+		m.respond(p, &msg)
+	case msg.isRequest():
+		s := m.oldest()
+		if s == nil {
+			m.sendRaw(newErrorMessage(msg.ID, jsonRPCInternalError, "no client connected"))
+			return
+		}
+		m.mu.Lock()
+		m.serverRequests[string(msg.ID)] = s
+		m.mu.Unlock()
+		s.write(data)
+	default:
+		for _, s := range m.snapshot() {
+			s.write(data)
+		}
+	}
+}
+
+// respond answers a pending request with resp under the client's ID.
+func (m *Multiplexer) respond(p muxPending, resp *rpcMessage) {
+	if p.onResponse != nil {
+		p.onResponse(resp)
+	}
+	data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: p.id, Result: resp.Result, Error: resp.Error})
+	p.session.write(data)
+}
+
+// take removes and returns the pending request forwarded as id.
+func (m *Multiplexer) take(id string) (muxPending, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.pending[id]
+	if ok {
+		delete(m.pending, id)
+		delete(p.session.ids, string(p.id))
+	}
+	return p, ok
+}
+
+// oldest returns the session that connected first, or nil.
+func (m *Multiplexer) oldest() *muxSession {
+	sessions := m.snapshot()
+	if len(sessions) == 0 {
+		return nil
+	}
+	return sessions[0]
+}
+
+// snapshot returns the current sessions in the order they connected.
+func (m *Multiplexer) snapshot() []*muxSession {
+	m.mu.Lock()
+	sessions := make([]*muxSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	return sessions
+}
+
+func (m *Multiplexer) sendToServer(msg rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return m.sendRaw(data)
+}
+
+func (m *Multiplexer) sendRaw(data []byte) error {
+	if err := m.proxy.Send(m.proxy.ctx, data); err != nil {
+		slog.Error("failed to send to server", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSession collects the messages written to a multiplexer session.
+type recordingSession struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordingSession) write(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, string(data))
+}
+
+func (r *recordingSession) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+func newTestMultiplexer(t *testing.T) (*Multiplexer, *batchTestTransport) {
+	t.Helper()
+	transport := &batchTestTransport{}
+	p, _ := newBatchTestProxy(transport)
+	return NewMultiplexer(p, nil), transport
+}
+
+func TestMultiplexerRewritesRequestIDs(t *testing.T) {
+	m, transport := newTestMultiplexer(t)
+	var a, b recordingSession
+	sa, sb := m.addSession(a.write), m.addSession(b.write)
+
+	m.handleClientMessage(sa, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	m.handleClientMessage(sb, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if len(transport.sent) != 2 || transport.sent[0] == transport.sent[1] {
+		t.Fatalf("Expected two requests with distinct IDs, got %q", transport.sent)
+	}
+	if !strings.Contains(transport.sent[1], `"id":"mux-2"`) {
+		t.Errorf("Expected the forwarded ID to be rewritten, got %s", transport.sent[1])
+	}
+
+	m.handleServerMessage([]byte(`{"jsonrpc":"2.0","id":"mux-2","result":{"tools":[]}}`))
+	if got := b.received(); len(got) != 1 || got[0] != `{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}` {
+		t.Errorf("Expected the response restored to the client's ID, got %q", got)
+	}
+	if got := a.received(); len(got) != 0 {
+		t.Errorf("Expected nothing for the other session, got %q", got)
+	}
+}
+
+func TestMultiplexerSharesInitialize(t *testing.T) {
+	m, transport := newTestMultiplexer(t)
+	var a, b, c recordingSession
+	sa, sb, sc := m.addSession(a.write), m.addSession(b.write), m.addSession(c.write)
+
+	initialize := []byte(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`)
+	m.handleClientMessage(sa, initialize)
+	m.handleClientMessage(sb, initialize)
+	if len(transport.sent) != 1 {
+		t.Fatalf("Expected one initialize sent to the server, got %q", transport.sent)
+	}
+
+	m.handleServerMessage([]byte(`{"jsonrpc":"2.0","id":"mux-1","result":{"protocolVersion":"2025-03-26"}}`))
+	m.handleClientMessage(sc, initialize)
+	for name, s := range map[string]*recordingSession{"a": &a, "b": &b, "c": &c} {
+		if got := s.received(); len(got) != 1 || !strings.Contains(got[0], `"protocolVersion":"2025-03-26"`) {
+			t.Errorf("%s: expected the shared initialize result, got %q", name, got)
+		}
+	}
+
+	initialized := []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	m.handleClientMessage(sa, initialized)
+	m.handleClientMessage(sb, initialized)
+	if len(transport.sent) != 2 {
+		t.Errorf("Expected notifications/initialized forwarded once, got %q", transport.sent)
+	}
+}
+
+func TestMultiplexerRoutesServerMessages(t *testing.T) {
+	m, transport := newTestMultiplexer(t)
+	var a, b recordingSession
+	sa := m.addSession(a.write)
+	m.addSession(b.write)
+
+	m.handleServerMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+	if len(a.received()) != 1 || len(b.received()) != 1 {
+		t.Errorf("Expected notifications broadcast, got %q and %q", a.received(), b.received())
+	}
+
+	m.handleServerMessage([]byte(`{"jsonrpc":"2.0","id":7,"method":"sampling/createMessage"}`))
+	if len(a.received()) != 2 || len(b.received()) != 1 {
+		t.Errorf("Expected the server request sent to the oldest session only")
+	}
+
+	// The session leaves before answering, so the server gets an error.
+	m.removeSession(sa)
+	if len(transport.sent) != 1 || !strings.Contains(transport.sent[0], `"id":7`) || !strings.Contains(transport.sent[0], `"error"`) {
+		t.Errorf("Expected an error response to the server request, got %q", transport.sent)
+	}
+}
+
+func TestMultiplexerCancelsOnDisconnect(t *testing.T) {
+	m, transport := newTestMultiplexer(t)
+	var a, b recordingSession
+	sa := m.addSession(a.write)
+	m.addSession(b.write)
+
+	m.handleClientMessage(sa, []byte(`{"jsonrpc":"2.0","id":"x","method":"tools/call"}`))
+	m.removeSession(sa)
+	if len(transport.sent) != 2 || !strings.Contains(transport.sent[1], `"method":"notifications/cancelled"`) || !strings.Contains(transport.sent[1], `"requestId":"mux-1"`) {
+		t.Fatalf("Expected the pending request cancelled, got %q", transport.sent)
+	}
+
+	m.handleServerMessage([]byte(`{"jsonrpc":"2.0","id":"mux-1","result":{}}`))
+	if got := b.received(); len(got) != 0 {
+		t.Errorf("Expected the late response dropped, got %q", got)
+	}
+	select {
+	case <-m.idle:
+		t.Error("Expected the multiplexer to stay up while a session remains")
+	default:
+	}
+}
+
+func TestMultiplexerIdleAfterReattach(t *testing.T) {
+	m, _ := newTestMultiplexer(t)
+	var a, b recordingSession
+
+	m.removeSession(m.addSession(a.write))
+	// A client attaching before Shutdown closes the listener leaves again.
+	m.removeSession(m.addSession(b.write))
+
+	select {
+	case <-m.idle:
+	default:
+		t.Error("Expected the multiplexer to be idle")
+	}
+}
+
+// sharedTestPath returns a socket path, or a pipe name on Windows, unique
+// to the test.
+func sharedTestPath(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\mcp-remote-go-test-` + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return filepath.Join(t.TempDir(), "s.sock")
+}
+
+func TestConnectShared(t *testing.T) {
+	path := sharedTestPath(t)
+	conn, listener, err := ConnectShared(path)
+	if err != nil {
+		t.Fatalf("ConnectShared failed: %v", err)
+	}
+	if conn != nil || listener == nil {
+		t.Fatal("Expected the first caller to listen")
+	}
+	defer func() { _ = listener.Close() }()
+
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = server.Write([]byte("hello\n"))
+		_ = server.Close()
+	}()
+
+	conn, second, err := ConnectShared(path)
+	if err != nil {
+		t.Fatalf("ConnectShared failed: %v", err)
+	}
+	if conn == nil || second != nil {
+		t.Fatal("Expected the second caller to attach")
+	}
+	defer func() { _ = conn.Close() }()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Errorf("Expected to reach the listener, got %q, %v", line, err)
+	}
+}
+
+func TestConnectSharedReplacesStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes do not outlive their process")
+	}
+	path := filepath.Join(t.TempDir(), "s.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	// Keep the socket file as a crashed proxy would.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	conn, listener, err := ConnectShared(path)
+	if err != nil {
+		t.Fatalf("ConnectShared failed: %v", err)
+	}
+	if conn != nil || listener == nil {
+		t.Fatal("Expected the stale socket to be replaced")
+	}
+	_ = listener.Close()
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+)
+
+// negotiationOrders are the transports the negotiating modes try, in order.
+var negotiationOrders = map[TransportMode][]TransportMode{
+	TransportModeAuto:      {TransportModeStreamableHTTP, TransportModeSSE},
+	TransportModeHTTPFirst: {TransportModeStreamableHTTP, TransportModeSSE},
+	TransportModeSSEFirst:  {TransportModeSSE, TransportModeStreamableHTTP},
+}
+
+// ProbeOutcome is what a TransportProbe learned about the server.
+type ProbeOutcome int
+
+const (
+	// ProbeUnsupported means the server does not speak the transport, and
+	// negotiation moves on to the next one.
+	ProbeUnsupported ProbeOutcome = iota
+	// ProbeSupported means the server speaks the transport.
+	ProbeSupported
+	// ProbeUnauthorized means the server requires authorization before
+	// it can be probed.
+	ProbeUnauthorized
+)
+
+func (o ProbeOutcome) String() string {
+	switch o {
+	case ProbeSupported:
+		return "supported"
+	case ProbeUnauthorized:
+		return "unauthorized"
+	default:
+		return "unsupported"
+	}
+}
+
+// ProbeResult is the result of a TransportProbe.
+type ProbeResult struct {
+	Outcome ProbeOutcome
+	// Reason explains the outcome in the negotiation trace, e.g. "HTTP 404".
+	Reason string
+	// WWWAuthenticate is the server's challenge for ProbeUnauthorized.
+	WWWAuthenticate string
+}
+
+// TransportProbe checks whether the server supports a transport.
+type TransportProbe func(ctx context.Context) ProbeResult
+
+// NegotiationStep records one transport considered during negotiation.
+type NegotiationStep struct {
+	Transport TransportMode `json:"transport"`
+	// Outcome is "supported", "unsupported", "unauthorized", or "fallback"
+	// for the last transport, which is used without probing.
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Negotiation is the result of TransportNegotiator.Negotiate.
+type Negotiation struct {
+	// Transport is the chosen transport. It is empty when the server
+	// requires authorization first.
+	Transport       TransportMode
+	Unauthorized    bool
+	WWWAuthenticate string
+	Trace           []NegotiationStep
+}
+
+// TransportNegotiator picks a transport by probing candidates in order and
+// choosing the first the server supports. The last candidate is the
+// fallback: it is chosen without probing when all others are unsupported.
+type TransportNegotiator struct {
+	order  []TransportMode
+	probes map[TransportMode]TransportProbe
+}
+
+// NewTransportNegotiator returns a negotiator trying order. Candidates
+// without a probe are assumed to be supported.
+func NewTransportNegotiator(order ...TransportMode) *TransportNegotiator {
+	return &TransportNegotiator{order: order, probes: make(map[TransportMode]TransportProbe)}
+}
+
+// SetProbe sets the probe for mode, replacing any earlier one.
+func (n *TransportNegotiator) SetProbe(mode TransportMode, probe TransportProbe) {
+	n.probes[mode] = probe
+}
+
+// Order returns the candidates in the order they are tried.
+func (n *TransportNegotiator) Order() []TransportMode {
+	return append([]TransportMode(nil), n.order...)
+}
+
+// Negotiate probes the candidates and returns the chosen transport with
+// the trace of how it was chosen.
+func (n *TransportNegotiator) Negotiate(ctx context.Context) Negotiation {
+	var result Negotiation
+	for i, mode := range n.order {
+		probe := n.probes[mode]
+		if i == len(n.order)-1 || probe == nil {
+			step := NegotiationStep{Transport: mode, Outcome: ProbeSupported.String(), Reason: "not probed"}
+			if i > 0 {
+				step.Outcome = "fallback"
+			}
+			result.Trace = append(result.Trace, step)
+			result.Transport = mode
+			return result
+		}
+
+		probed := probe(ctx)
+		result.Trace = append(result.Trace, NegotiationStep{Transport: mode, Outcome: probed.Outcome.String(), Reason: probed.Reason})
+		switch probed.Outcome {
+		case ProbeSupported:
+			result.Transport = mode
+			return result
+		case ProbeUnauthorized:
+			result.Unauthorized = true
+			result.WWWAuthenticate = probed.WWWAuthenticate
+			return result
+		}
+	}
+	return result
+}
+
+// formatTrace renders a negotiation trace on one line for the log.
+func formatTrace(trace []NegotiationStep) string {
+	parts := make([]string, len(trace))
+	for i, step := range trace {
+		parts[i] = string(step.Transport) + ": " + step.Outcome
+		if step.Reason != "" {
+			parts[i] += " (" + step.Reason + ")"
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// negotiateTransport picks a transport with the negotiator for the proxy's
+// mode and connects with it.
+func (p *Proxy) negotiateTransport() error {
+	slog.Debug("negotiating transport", "mode", p.transportMode)
+	n := NewTransportNegotiator(negotiationOrders[p.transportMode]...)
+	n.SetProbe(TransportModeStreamableHTTP, p.probeStreamableHTTP)
+	n.SetProbe(TransportModeSSE, p.probeSSE)
+	for mode, probe := range p.probes {
+		n.SetProbe(mode, probe)
+	}
+
+	result := n.Negotiate(p.ctx)
+	p.stats.recordNegotiation(result.Trace)
+	if result.Unauthorized {
+		slog.Info("authentication required", "trace", formatTrace(result.Trace))
+		return p.handleAuthentication(result.WWWAuthenticate)
+	}
+	slog.Info("transport negotiated", "transport", result.Transport, "trace", formatTrace(result.Trace))
+	if first := n.Order()[0]; result.Transport != first {
+		metrics.TransportFallbacksTotal.Inc(p.serverURL, string(first), string(result.Transport))
+	}
+	return p.connectWithMode(result.Transport)
+}
+
+// probeStreamableHTTP sends a ping as a Streamable HTTP POST. A success
+// status, or an error status with a JSON-RPC body, shows the server
+// understands the transport.
+func (p *Proxy) probeStreamableHTTP(ctx context.Context) ProbeResult {
+	probeReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.serverURL, strings.NewReader(`{"jsonrpc":"2.0","method":"ping","id":0}`))
+	if err != nil {
+		return ProbeResult{Reason: err.Error()}
+	}
+	p.setProbeHeaders(probeReq)
+	probeReq.Header.Set("Content-Type", "application/json")
+	probeReq.Header.Set("Accept", "application/json, text/event-stream")
+	if version := p.protocol.header(); version != "" {
+		probeReq.Header.Set(HeaderMCPProtocolVersion, version)
+	}
+
+	resp, err := p.client.Do(probeReq)
+	if err != nil {
+		return ProbeResult{Reason: err.Error()}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		slog.Warn("failed to close probe response body", "error", closeErr)
+	}
+
+	// A JSON-RPC body means the server understands the protocol even if
+	// it answered with an error status.
+	isJSONRPC := len(body) > 0 && json.Valid(body) && isJSONRPCResponse(body)
+
+	status := fmt.Sprintf("HTTP %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted:
+		return ProbeResult{Outcome: ProbeSupported, Reason: status}
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ProbeResult{Outcome: ProbeUnauthorized, Reason: status, WWWAuthenticate: auth.BestWWWAuthenticateHeader(resp.Header.Values(HeaderWWWAuthenticate))}
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		return ProbeResult{Reason: status}
+	case isJSONRPC:
+		return ProbeResult{Outcome: ProbeSupported, Reason: status + " with a JSON-RPC body"}
+	default:
+		return ProbeResult{Reason: "unexpected " + status}
+	}
+}
+
+// probeSSE opens the event stream as the SSE transport does and closes it
+// once the response headers arrive.
+func (p *Proxy) probeSSE(ctx context.Context) ProbeResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	probeReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.serverURL, nil)
+	if err != nil {
+		return ProbeResult{Reason: err.Error()}
+	}
+	p.setProbeHeaders(probeReq)
+	probeReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.client.Do(probeReq)
+	if err != nil {
+		return ProbeResult{Reason: err.Error()}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	status := fmt.Sprintf("HTTP %d", resp.StatusCode)
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ProbeResult{Outcome: ProbeUnauthorized, Reason: status, WWWAuthenticate: auth.BestWWWAuthenticateHeader(resp.Header.Values(HeaderWWWAuthenticate))}
+	case resp.StatusCode == http.StatusOK && contentType == "text/event-stream":
+		return ProbeResult{Outcome: ProbeSupported, Reason: status}
+	default:
+		return ProbeResult{Reason: status + " " + contentType}
+	}
+}
+
+// setProbeHeaders adds the configured headers and the access token to a
+// probe request.
+func (p *Proxy) setProbeHeaders(req *http.Request) {
+	for k, v := range p.headers() {
+		req.Header.Set(k, v)
+	}
+	if token := p.getAuthToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -3,6 +3,7 @@ package proxy
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -274,3 +275,125 @@ func TestTransportModeStreamableHTTPDirect(t *testing.T) {
 		t.Errorf("Expected streamable-http mode, got '%s'", proxy.transportMode)
 	}
 }
+
+func TestTransportNegotiator(t *testing.T) {
+	probe := func(outcome ProbeOutcome) TransportProbe {
+		return func(context.Context) ProbeResult {
+			return ProbeResult{Outcome: outcome, Reason: "test", WWWAuthenticate: "Bearer"}
+		}
+	}
+
+	n := NewTransportNegotiator(TransportModeStreamableHTTP, TransportModeSSE)
+	n.SetProbe(TransportModeStreamableHTTP, probe(ProbeUnsupported))
+	n.SetProbe(TransportModeSSE, func(context.Context) ProbeResult {
+		t.Error("the fallback transport should not be probed")
+		return ProbeResult{}
+	})
+	result := n.Negotiate(t.Context())
+	if result.Transport != TransportModeSSE || formatTrace(result.Trace) != "streamable-http: unsupported (test); sse: fallback (not probed)" {
+		t.Errorf("Expected a fallback to SSE, got %+v", result)
+	}
+
+	n = NewTransportNegotiator(TransportModeSSE, TransportModeStreamableHTTP)
+	n.SetProbe(TransportModeSSE, probe(ProbeSupported))
+	if result := n.Negotiate(t.Context()); result.Transport != TransportModeSSE || len(result.Trace) != 1 {
+		t.Errorf("Expected the first supported transport, got %+v", result)
+	}
+
+	n.SetProbe(TransportModeSSE, probe(ProbeUnauthorized))
+	if result := n.Negotiate(t.Context()); !result.Unauthorized || result.Transport != "" || result.WWWAuthenticate != "Bearer" {
+		t.Errorf("Expected negotiation to stop for authorization, got %+v", result)
+	}
+}
+
+// newDualTransportServer serves both the Streamable HTTP and SSE transports.
+func newDualTransportServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, `{"jsonrpc":"2.0","id":0,"result":{}}`)
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "event: endpoint\ndata: /message\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNegotiateTransportSSEFirst(t *testing.T) {
+	server := newDualTransportServer(t)
+
+	proxy, err := NewProxyWithTransport(server.URL, 0, map[string]string{}, "sse-first-test", TransportModeSSEFirst)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer proxy.Shutdown()
+
+	if err := proxy.connectToServer(); err != nil {
+		t.Fatalf("connectToServer failed: %v", err)
+	}
+	if proxy.transportMode != TransportModeSSE {
+		t.Errorf("Expected sse-first to pick SSE, got '%s'", proxy.transportMode)
+	}
+	st := proxy.Status()
+	if len(st.Negotiation) != 1 || st.Negotiation[0].Transport != TransportModeSSE || st.Negotiation[0].Outcome != "supported" {
+		t.Errorf("Expected the negotiation in the status, got %+v", st.Negotiation)
+	}
+}
+
+func TestNegotiateTransportInjectedProbe(t *testing.T) {
+	server := newDualTransportServer(t)
+
+	proxy, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "probe-test", TransportModeAuto, "",
+		WithTransportProbe(TransportModeStreamableHTTP, func(context.Context) ProbeResult {
+			return ProbeResult{Reason: "disabled"}
+		}))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer proxy.Shutdown()
+
+	if err := proxy.connectToServer(); err != nil {
+		t.Fatalf("connectToServer failed: %v", err)
+	}
+	if proxy.transportMode != TransportModeSSE {
+		t.Errorf("Expected the injected probe to rule out Streamable HTTP, got '%s'", proxy.transportMode)
+	}
+}
+
+func TestParseTransportMode(t *testing.T) {
+	for _, name := range []string{"auto", "http-first", "sse-first", "streamable-http", "sse", "websocket"} {
+		if mode, err := ParseTransportMode(name); err != nil || string(mode) != name {
+			t.Errorf("ParseTransportMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+	for _, name := range []string{"", "replay", "command", "SSE", "http"} {
+		if _, err := ParseTransportMode(name); err == nil {
+			t.Errorf("ParseTransportMode(%q): expected an error", name)
+		}
+	}
+}
+
+func TestNegotiateTransportHTTPFirst(t *testing.T) {
+	server := newDualTransportServer(t)
+
+	proxy, err := NewProxyWithTransport(server.URL, 0, map[string]string{}, "http-first-test", TransportModeHTTPFirst)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer proxy.Shutdown()
+
+	if err := proxy.connectToServer(); err != nil {
+		t.Fatalf("connectToServer failed: %v", err)
+	}
+	if proxy.transportMode != TransportModeStreamableHTTP {
+		t.Errorf("Expected http-first to pick Streamable HTTP, got '%s'", proxy.transportMode)
+	}
+}
@@ -0,0 +1,608 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/tls"
+	"html/template"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+)
+
+// Option configures optional Proxy behavior.
+type Option func(*options)
+
+type options struct {
+	coordinatorOpts   []auth.CoordinatorOption
+	messageHandler    func(data []byte)
+	authURLHandler    func(authURL string) error
+	noBrowser         bool
+	browserCommand    string
+	noCompression     bool
+	stdioFraming      string
+	maxMessageSize    int
+	maxEventSize      int
+	maxBodySize       int
+	strict            bool
+	forwardServerLogs bool
+	revokeOnExit      bool
+	eagerInit         bool
+	clientVersion     string
+	protocolVersion   string
+	listCacheTTL      time.Duration
+	maxRPS            float64
+	burst             int
+	breakerThreshold  int
+	breakerCooldown   time.Duration
+	headerTemplates   bool
+	headerRefresh     time.Duration
+	headerProvider    HeaderProvider
+	requestTimeout    time.Duration
+	keepalive         time.Duration
+	reconnect         backoff.Policy
+	queueLimited      bool
+	sendBuffer        int
+	outputQueue       int
+	tracer            Tracer
+	replay            []RecordedMessage
+	command           []string
+	probes            map[TransportMode]TransportProbe
+	authFlow          string
+	staticToken       string
+	resumeSession     bool
+	tlsConfig         *tls.Config
+	pool              httpclient.PoolOptions
+	allowTools        []string
+	denyTools         []string
+	middleware        []Middleware
+	shutdownTimeout   time.Duration
+}
+
+// Directions passed to Tracer.Trace.
+const (
+	TraceLocalToRemote = "local_to_remote"
+	TraceRemoteToLocal = "remote_to_local"
+)
+
+// Tracer records the JSON-RPC messages exchanged with a server. server is
+// the remote server URL.
+type Tracer interface {
+	Trace(direction, server string, message []byte)
+}
+
+// WithTokenStore selects the backend used to persist OAuth tokens and client
+// registrations.
+func WithTokenStore(store auth.TokenStore) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithTokenStore(store))
+	}
+}
+
+// WithScopes sets the OAuth scopes requested from the authorization server.
+// An empty list keeps the default "mcp offline_access".
+func WithScopes(scopes []string) Option {
+	return func(o *options) {
+		if len(scopes) > 0 {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithScopes(scopes))
+		}
+	}
+}
+
+// WithResource overrides the RFC 8707 resource indicator sent in OAuth
+// requests, which is otherwise derived from the server URL.
+func WithResource(resource string) Option {
+	return func(o *options) {
+		if resource != "" {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithResource(resource))
+		}
+	}
+}
+
+// WithMessageHandler delivers messages from the server to handler instead of
+// writing them to stdout. Used when embedding the proxy as a library.
+func WithMessageHandler(handler func(data []byte)) Option {
+	return func(o *options) {
+		o.messageHandler = handler
+	}
+}
+
+// WithAuthURLHandler replaces opening the system browser when authorization
+// is required. The handler receives the authorization URL; the proxy then
+// waits for the OAuth callback as usual.
+func WithAuthURLHandler(handler func(authURL string) error) Option {
+	return func(o *options) {
+		o.authURLHandler = handler
+	}
+}
+
+// WithNoBrowser prints the authorization URL to stderr instead of opening
+// the system browser, and accepts the redirect URL or code pasted on the
+// terminal for when the browser cannot reach the callback server, e.g. over
+// SSH or in a container.
+func WithNoBrowser() Option {
+	return func(o *options) {
+		o.noBrowser = true
+	}
+}
+
+// WithBrowserCommand opens the authorization URL with command, e.g.
+// "firefox --private-window %s", instead of the system browser. "%s" is
+// replaced by the URL, which is otherwise appended. Without this option the
+// BROWSER environment variable is honoured.
+func WithBrowserCommand(command string) Option {
+	return func(o *options) {
+		o.browserCommand = command
+	}
+}
+
+// WithNoCompression stops the proxy from asking the server and the
+// authorization server for compressed responses. By default gzip and
+// deflate responses are requested and decompressed transparently.
+func WithNoCompression() Option {
+	return func(o *options) {
+		o.noCompression = true
+	}
+}
+
+// WithStdioFraming selects how messages are framed on stdio: FramingAuto
+// (default), FramingNewline or FramingContentLength.
+func WithStdioFraming(framing string) Option {
+	return func(o *options) {
+		o.stdioFraming = framing
+	}
+}
+
+// WithStrict validates every message against JSON-RPC 2.0. Invalid messages
+// from the client are answered with an error instead of being forwarded, and
+// invalid messages from the server are dropped.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithForwardServerLogs passes the log messages the server sends as
+// notifications/message to the client. They are always written to the
+// proxy's own log; without this option they are not forwarded.
+func WithForwardServerLogs() Option {
+	return func(o *options) {
+		o.forwardServerLogs = true
+	}
+}
+
+// WithRevokeOnExit revokes the OAuth tokens at the authorization server and
+// deletes them locally when the proxy shuts down (RFC 7009), so no usable
+// credentials outlive the session.
+func WithRevokeOnExit() Option {
+	return func(o *options) {
+		o.revokeOnExit = true
+	}
+}
+
+// WithEagerInit makes the proxy perform the initialize handshake with the
+// server as soon as it connects, reporting version in its clientInfo. The
+// client's initialize is then answered from the cached result, and a
+// server that cannot be initialized fails the connection.
+func WithEagerInit(version string) Option {
+	return func(o *options) {
+		o.eagerInit = true
+		o.clientVersion = version
+	}
+}
+
+// WithClientVersion makes the proxy append "(via mcp-remote-go version)" to
+// the clientInfo name in the client's initialize, so the server can tell
+// the client is connected through this proxy and which release it is.
+// Without it the client's initialize is forwarded as sent.
+func WithClientVersion(version string) Option {
+	return func(o *options) {
+		o.clientVersion = version
+	}
+}
+
+// WithProtocolVersion makes the proxy ask the server for version instead of
+// the version the client requests in initialize. Until the server answers,
+// it is also sent in the Mcp-Protocol-Version header.
+func WithProtocolVersion(version string) Option {
+	return func(o *options) {
+		o.protocolVersion = version
+	}
+}
+
+// WithMaxRPS limits the requests sent to the server to rps per second,
+// allowing bursts of up to burst requests (rps rounded up when burst is
+// zero). A request over the limit is answered with an error, or with
+// WithQueueWhenRateLimited, held until it may be sent. Notifications and
+// responses are not limited.
+func WithMaxRPS(rps float64, burst int) Option {
+	return func(o *options) {
+		o.maxRPS = rps
+		o.burst = burst
+	}
+}
+
+// WithCircuitBreaker stops sending to the server after threshold
+// consecutive failed requests or reconnection attempts. For cooldown the
+// client's requests are answered with an error without reaching the server;
+// then a single trial request is sent, closing the breaker if it succeeds.
+// A zero cooldown is resilience.DefaultCooldown.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.breakerThreshold = threshold
+		o.breakerCooldown = cooldown
+	}
+}
+
+// WithListCache makes the proxy answer repeated tools/list, resources/list
+// and prompts/list requests from the server's last result for up to ttl.
+// A list_changed notification from the server drops the cached listing.
+func WithListCache(ttl time.Duration) Option {
+	return func(o *options) {
+		o.listCacheTTL = ttl
+	}
+}
+
+// WithHeaderTemplates expands ${VAR} and $(command) in the values of the
+// headers given to NewProxyWithOptions, evaluating values with a command
+// again every refresh (zero evaluates them only once). See package
+// headervalue.
+func WithHeaderTemplates(refresh time.Duration) Option {
+	return func(o *options) {
+		o.headerTemplates = true
+		o.headerRefresh = refresh
+	}
+}
+
+// WithHeaderProvider sets a provider called for every request to the
+// server. Its headers are set over the fixed headers, so rotating
+// credentials can be supplied without reconnecting.
+func WithHeaderProvider(provider HeaderProvider) Option {
+	return func(o *options) {
+		o.headerProvider = provider
+	}
+}
+
+// WithMaxMessageSize sets the largest message, in bytes, accepted from the
+// client on stdio. Larger messages are skipped. The default is
+// DefaultMaxMessageSize.
+func WithMaxMessageSize(size int) Option {
+	return func(o *options) {
+		o.maxMessageSize = size
+	}
+}
+
+// WithMaxEventSize sets the largest SSE event, in bytes of data, accepted
+// from the server. A larger event drops the stream it arrived on instead of
+// being buffered. The default is DefaultMaxEventSize.
+func WithMaxEventSize(size int) Option {
+	return func(o *options) {
+		o.maxEventSize = size
+	}
+}
+
+// WithMaxBodySize sets the largest JSON response body or WebSocket message,
+// in bytes, accepted from the server. A larger body fails the request it
+// answers; a larger WebSocket message drops the connection. The default is
+// DefaultMaxBodySize.
+func WithMaxBodySize(size int) Option {
+	return func(o *options) {
+		o.maxBodySize = size
+	}
+}
+
+// WithTracer records every message sent to and received from the server.
+// Given more than once, every tracer records them.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		if o.tracer != nil {
+			tracer = multiTracer{o.tracer, tracer}
+		}
+		o.tracer = tracer
+	}
+}
+
+// multiTracer passes each message to several tracers.
+type multiTracer []Tracer
+
+func (m multiTracer) Trace(direction, server string, message []byte) {
+	for _, t := range m {
+		t.Trace(direction, server, message)
+	}
+}
+
+// WithReplay makes the proxy answer requests from recording, e.g. one made
+// with a Tracer, instead of connecting to the server; see
+// TransportModeReplay. No network requests are made.
+func WithReplay(recording []RecordedMessage) Option {
+	return func(o *options) {
+		o.replay = recording
+		if o.replay == nil {
+			o.replay = []RecordedMessage{}
+		}
+	}
+}
+
+// WithCommand makes the proxy run command, a local MCP server speaking the
+// stdio transport, instead of connecting to a remote server; see
+// TransportModeCommand. command holds the program and its arguments. If
+// the server exits it is started again, as a lost connection would be
+// reconnected.
+func WithCommand(command []string) Option {
+	return func(o *options) {
+		o.command = command
+	}
+}
+
+// WithTransportProbe replaces the probe used for mode when negotiating a
+// transport in TransportModeAuto, TransportModeHTTPFirst or
+// TransportModeSSEFirst.
+func WithTransportProbe(mode TransportMode, probe TransportProbe) Option {
+	return func(o *options) {
+		if o.probes == nil {
+			o.probes = make(map[TransportMode]TransportProbe)
+		}
+		o.probes[mode] = probe
+	}
+}
+
+// WithAuthFlow selects the OAuth flow used when the server requires
+// authorization: auth.AuthFlowBrowser (default) or auth.AuthFlowDevice. Use
+// WithClientCredentials for auth.AuthFlowClientCredentials.
+func WithAuthFlow(flow string) Option {
+	return func(o *options) {
+		o.authFlow = flow
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithAuthFlow(flow))
+	}
+}
+
+// WithStaticToken sends token as the bearer token on every request instead of
+// running OAuth. No auth coordinator, callback server or token files are used.
+func WithStaticToken(token string) Option {
+	return func(o *options) {
+		o.staticToken = token
+	}
+}
+
+// WithClientCredentials authenticates with the OAuth client credentials grant
+// using a pre-registered client, without a browser or user.
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(o *options) {
+		o.authFlow = auth.AuthFlowClientCredentials
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithClientCredentials(clientID, clientSecret))
+	}
+}
+
+// WithProvider authenticates against the authorization server described by
+// the provider profile p instead of discovering it; see auth.WithProvider.
+func WithProvider(p *auth.Provider) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithProvider(p))
+	}
+}
+
+// WithClient uses a client registered with the authorization server
+// beforehand for the browser and device flows, instead of dynamic client
+// registration.
+func WithClient(clientID, clientSecret string) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithClient(clientID, clientSecret))
+	}
+}
+
+// WithAuthParams adds params to OAuth authorization and token requests; see
+// auth.WithAuthParams.
+func WithAuthParams(params map[string]string) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithAuthParams(params))
+	}
+}
+
+// WithTokenEndpointAuth sets how the client authenticates to the token
+// endpoint: one of the auth.TokenAuth methods, or the registered method when
+// empty. key signs private_key_jwt client assertions; see
+// auth.WithTokenEndpointAuth.
+func WithTokenEndpointAuth(method string, key crypto.Signer, keyID string) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithTokenEndpointAuth(method, key, keyID))
+	}
+}
+
+// WithAuthTimeout sets how long the browser flow waits for the user to
+// authorize access. The default is auth.DefaultAuthTimeout.
+func WithAuthTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithAuthTimeout(timeout))
+	}
+}
+
+// WithHTTPTimeout sets how long each OAuth discovery, registration and
+// token request may take; see auth.WithHTTPTimeout.
+func WithHTTPTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithHTTPTimeout(timeout))
+	}
+}
+
+// WithHTTPRetries sets how often OAuth discovery, registration and token
+// requests are retried, and the delay between attempts; see
+// auth.WithHTTPRetries.
+func WithHTTPRetries(retries int, delay time.Duration) Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithHTTPRetries(retries, delay))
+	}
+}
+
+// WithCallbackPages replaces the pages shown in the browser when OAuth
+// authorization succeeds or fails; see auth.LoadCallbackPage. A nil
+// template keeps the default page.
+func WithCallbackPages(success, failure *template.Template) Option {
+	return func(o *options) {
+		if success != nil || failure != nil {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithCallbackPages(success, failure))
+		}
+	}
+}
+
+// WithCallbackOrigins allows cross-origin requests from origins to the OAuth
+// callback server, which otherwise only accepts the browser redirect.
+func WithCallbackOrigins(origins []string) Option {
+	return func(o *options) {
+		if len(origins) > 0 {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithCallbackOrigins(origins))
+		}
+	}
+}
+
+// WithCallbackHost names the OAuth callback server by host in the redirect
+// URI instead of 127.0.0.1, for a browser outside the container or WSL
+// distribution the proxy runs in.
+func WithCallbackHost(host string) Option {
+	return func(o *options) {
+		if host != "" {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithCallbackHost(host))
+		}
+	}
+}
+
+// WithCallbackBind sets the IP address the OAuth callback server listens on.
+func WithCallbackBind(addr string) Option {
+	return func(o *options) {
+		if addr != "" {
+			o.coordinatorOpts = append(o.coordinatorOpts, auth.WithCallbackBind(addr))
+		}
+	}
+}
+
+// WithCallbackTLS serves the OAuth callback over HTTPS with a self-signed
+// certificate and registers an https redirect URI naming localhost.
+func WithCallbackTLS() Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithCallbackTLS())
+	}
+}
+
+// WithAssumePKCE uses PKCE with S256 even when the authorization server
+// does not advertise its PKCE methods, which is otherwise refused.
+func WithAssumePKCE() Option {
+	return func(o *options) {
+		o.coordinatorOpts = append(o.coordinatorOpts, auth.WithAssumePKCE())
+	}
+}
+
+// WithSessionResume keeps the Streamable HTTP session open on shutdown and
+// saves its session ID and last event ID in the per-server config directory,
+// so that the next run resumes the session instead of starting a new one.
+func WithSessionResume() Option {
+	return func(o *options) {
+		o.resumeSession = true
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for connections to the server and
+// the authorization server, e.g. to trust a private CA or present a client
+// certificate.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithConnectionPool tunes how connections to the server and the
+// authorization server are pooled and reused. Zero fields keep their
+// defaults.
+func WithConnectionPool(pool httpclient.PoolOptions) Option {
+	return func(o *options) {
+		o.pool = pool
+	}
+}
+
+// WithToolFilter restricts the tools exposed by the server. allow and deny
+// are glob patterns (path.Match syntax) matched against tool names; deny
+// takes precedence, and an empty allow list permits every tool not denied.
+// Filtered tools are removed from tools/list results and calls to them are
+// rejected without reaching the server.
+func WithToolFilter(allow, deny []string) Option {
+	return func(o *options) {
+		o.allowTools = append(o.allowTools, allow...)
+		o.denyTools = append(o.denyTools, deny...)
+	}
+}
+
+// WithMiddleware adds middleware to the proxy's chain; see Proxy.Use.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// WithRequestTimeout answers requests the server has not answered within d
+// with a JSON-RPC error (code -32001) and sends the server a cancellation.
+// A response arriving later is dropped. Zero, the default, waits forever.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = d
+	}
+}
+
+// WithKeepaliveInterval detects dead connections. The SSE stream is
+// reconnected when nothing arrives for three intervals; over Streamable
+// HTTP an MCP ping is sent every interval, and over WebSocket a ping frame.
+// Zero, the default, disables keepalive.
+func WithKeepaliveInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.keepalive = d
+	}
+}
+
+// WithReconnectPolicy sets how reconnection attempts are spaced and when
+// the proxy gives up, both for the connection to the server and for the SSE
+// streams the transports keep open. Zero fields keep their defaults.
+func WithReconnectPolicy(policy backoff.Policy) Option {
+	return func(o *options) {
+		o.reconnect = policy
+	}
+}
+
+// WithQueueWhenRateLimited makes a message the server rejects with 429, or
+// 503 with Retry-After, wait as long as the server asked and be sent again,
+// holding back the messages after it. Without it such a request is answered
+// with an error, as are requests sent before the wait is over. Requests over
+// the WithMaxRPS limit likewise wait instead of failing.
+func WithQueueWhenRateLimited() Option {
+	return func(o *options) {
+		o.queueLimited = true
+	}
+}
+
+// WithSendBuffer sets how many messages for the server are buffered while
+// the proxy reconnects; they are sent in order once the connection is back.
+// A message arriving with the buffer full fails, and zero disables
+// buffering. The default is DefaultSendBuffer.
+func WithSendBuffer(n int) Option {
+	return func(o *options) {
+		o.sendBuffer = n
+	}
+}
+
+// WithOutputQueue sets how many messages for the client are queued while
+// it is slow to read stdout, so that reading from the server never waits
+// for the client. A message arriving with the queue full is dropped and
+// logged, and a dropped response is answered with an error once the client
+// catches up. Zero writes every message directly, blocking until the
+// client reads it. The default is DefaultOutputQueue.
+func WithOutputQueue(n int) Option {
+	return func(o *options) {
+		o.outputQueue = n
+	}
+}
+
+// WithShutdownTimeout makes Shutdown wait up to d for requests already sent
+// to the server to be answered before closing the connection. New requests
+// are rejected while waiting.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// DefaultOutputQueue is how many messages for the client are queued by
+// default while it is slow to read them.
+const DefaultOutputQueue = 1000
+
+// errOutputQueueFull is returned for a message dropped because the client
+// did not read its messages fast enough.
+var errOutputQueueFull = errors.New("output queue is full: the client is not reading messages fast enough")
+
+// outputQueue hands messages for the client to a dedicated writer
+// goroutine, so that a client slow to read stdout does not stall the
+// goroutines reading from the server, and with them keepalives. Before
+// start and after stop messages are written directly.
+//
+// When the queue is full a message is dropped rather than blocking. A
+// dropped response is answered with an error once the client catches up,
+// so that no request is left waiting.
+type outputQueue struct {
+	size int
+
+	mu       sync.Mutex
+	messages chan []byte
+	stopping chan struct{}
+	done     chan struct{}
+	dropped  []json.RawMessage
+}
+
+// start runs the writer, which passes queued messages to write. It does
+// nothing when the queue size is not positive.
+func (q *outputQueue) start(write func(data []byte)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size <= 0 || q.messages != nil {
+		return
+	}
+	q.messages = make(chan []byte, q.size)
+	q.stopping = make(chan struct{})
+	q.done = make(chan struct{})
+	go q.run(q.messages, q.stopping, q.done, write)
+}
+
+func (q *outputQueue) run(messages <-chan []byte, stopping, done chan struct{}, write func(data []byte)) {
+	defer close(done)
+	for {
+		select {
+		case data := <-messages:
+			write(data)
+			q.answerDropped(write)
+		case <-stopping:
+			for {
+				select {
+				case data := <-messages:
+					write(data)
+				default:
+					q.answerDropped(write)
+					return
+				}
+			}
+		}
+	}
+}
+
+// stop writes the messages still queued and stops the writer.
+func (q *outputQueue) stop() {
+	q.mu.Lock()
+	if q.messages == nil {
+		q.mu.Unlock()
+		return
+	}
+	q.messages = nil
+	stopping, done := q.stopping, q.done
+	q.mu.Unlock()
+
+	close(stopping)
+	<-done
+}
+
+// push queues data for the writer, or writes it right away when the writer
+// is not running. It returns errOutputQueueFull when data was dropped.
+func (q *outputQueue) push(data []byte, write func(data []byte)) error {
+	q.mu.Lock()
+	if q.messages == nil {
+		q.mu.Unlock()
+		write(data)
+		return nil
+	}
+	select {
+	case q.messages <- data:
+		q.mu.Unlock()
+		return nil
+	default:
+	}
+	ids := responseIDs(data)
+	q.dropped = append(q.dropped, ids...)
+	q.mu.Unlock()
+
+	slog.Error("dropping message for the client", "error", errOutputQueueFull, "responses", len(ids))
+	return errOutputQueueFull
+}
+
+// answerDropped writes an error response for every response dropped so far.
+func (q *outputQueue) answerDropped(write func(data []byte)) {
+	q.mu.Lock()
+	ids := q.dropped
+	q.dropped = nil
+	q.mu.Unlock()
+	for _, id := range ids {
+		write(newErrorMessage(id, jsonRPCInternalError, "response dropped: "+errOutputQueueFull.Error()))
+	}
+}
+
+// responseIDs returns the IDs of the responses in data, a message or a
+// batch.
+func responseIDs(data []byte) []json.RawMessage {
+	elements, ok := splitBatch(data)
+	if !ok {
+		elements = [][]byte{data}
+	}
+	var ids []json.RawMessage
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) == nil && msg.isResponse() && string(msg.ID) != "null" {
+			ids = append(ids, msg.ID)
+		}
+	}
+	return ids
+}
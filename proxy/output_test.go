@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingWriter records written messages; each write waits on release.
+type blockingWriter struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	written []string
+}
+
+func (w *blockingWriter) write(data []byte) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = append(w.written, string(data))
+}
+
+func TestOutputQueueDropsWhenFull(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	q := outputQueue{size: 1}
+	q.start(w.write)
+
+	// The writer takes the first message and blocks writing it; the
+	// second fills the queue.
+	if err := q.push([]byte(`{"jsonrpc":"2.0","method":"notifications/progress"}`), w.write); err != nil {
+		t.Fatalf("Expected the first message queued, got %v", err)
+	}
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = q.push([]byte(`{"jsonrpc":"2.0","id":7,"result":{}}`), w.write)
+	}
+	if !errors.Is(err, errOutputQueueFull) {
+		t.Fatalf("Expected the full queue to drop a message, got %v", err)
+	}
+
+	close(w.release)
+	q.stop()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := w.written[len(w.written)-1]
+	if !strings.Contains(last, `"id":7`) || !strings.Contains(last, "response dropped") {
+		t.Errorf("Expected the dropped response answered with an error, got %q", last)
+	}
+}
+
+func TestOutputQueueWritesDirectlyWhenStopped(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	close(w.release)
+	q := outputQueue{size: 10}
+
+	_ = q.push([]byte("before"), w.write)
+	q.start(w.write)
+	_ = q.push([]byte("queued"), w.write)
+	q.stop()
+	_ = q.push([]byte("after"), w.write)
+
+	if got := strings.Join(w.written, ","); got != "before,queued,after" {
+		t.Errorf("Expected every message written in order, got %q", got)
+	}
+}
+
+func TestResponseIDs(t *testing.T) {
+	ids := responseIDs([]byte(`[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","method":"ping","id":2},{"jsonrpc":"2.0","id":null,"error":{"code":1,"message":"x"}}]`))
+	if len(ids) != 1 || string(ids[0]) != "1" {
+		t.Errorf("Expected only the response ID, got %q", ids)
+	}
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import "log/slog"
+
+// watchProgress associates the progress token a request carries in
+// params._meta with it, so progress notifications for the request can
+// extend its timeout.
+func (r *inflightRequests) watchProgress(id, token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.started[id]
+	if !ok {
+		return
+	}
+	if r.progress == nil {
+		r.progress = make(map[string]string)
+	}
+	req.token = token
+	r.started[id] = req
+	r.progress[token] = id
+}
+
+// progressed restarts the timeout of the request token belongs to, as MCP
+// lets a request run for as long as it reports progress. It reports
+// whether token belongs to an outstanding request.
+func (r *inflightRequests) progressed(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.started[r.progress[token]]
+	if !ok {
+		return false
+	}
+	if req.timer != nil {
+		req.timer.Reset(req.timeout)
+	}
+	return true
+}
+
+// progressTokens returns the number of outstanding requests that asked for
+// progress notifications.
+func (r *inflightRequests) progressTokens() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.progress)
+}
+
+// progressReceived counts a notifications/progress from the server and
+// extends the timeout of the request it reports on. Notifications for a
+// token no outstanding request carries are still passed to the client,
+// which decides what to do with them, but counted separately.
+func (p *Proxy) progressReceived(token string) {
+	p.stats.progress.Add(1)
+	if token != "" && p.inflight.progressed(token) {
+		return
+	}
+	p.stats.unmatchedProgress.Add(1)
+	slog.Debug("progress notification for an unknown token", "progress_token", token)
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressExtendsRequestTimeout(t *testing.T) {
+	transport := &batchTestTransport{}
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	var out safeBuffer
+	p := &Proxy{
+		ctx:            t.Context(),
+		transport:      transport,
+		framing:        framing,
+		stdioWriter:    bufio.NewWriter(&out),
+		requestTimeout: 60 * time.Millisecond,
+	}
+
+	_ = p.sendToServer([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"build","_meta":{"progressToken":"build-1"}}}`))
+	if n := p.Status().ActiveProgressTokens; n != 1 {
+		t.Errorf("Expected 1 active progress token, got %d", n)
+	}
+
+	// Progress keeps the request alive past its timeout.
+	for range 4 {
+		time.Sleep(30 * time.Millisecond)
+		p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"build-1","progress":1}}`))
+	}
+	if p.inflight.count() != 1 {
+		t.Fatal("Expected the request still outstanding while progress flows")
+	}
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	if strings.Contains(out.String(), "timed out") {
+		t.Errorf("Expected no timeout error, got %s", out.String())
+	}
+
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"build-1","progress":2}}`))
+	st := p.Status()
+	if st.ProgressNotifications != 5 || st.UnmatchedProgress != 1 || st.ActiveProgressTokens != 0 {
+		t.Errorf("Expected 5 progress notifications, 1 unmatched and no active tokens, got %d, %d, %d", st.ProgressNotifications, st.UnmatchedProgress, st.ActiveProgressTokens)
+	}
+	if got := strings.Count(out.String(), "notifications/progress"); got != 5 {
+		t.Errorf("Expected every progress notification passed to the client, got %d", got)
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+// openTerminal opens the controlling terminal for reading. Stdin carries the
+// MCP protocol, so prompts cannot read from it.
+func openTerminal() (io.ReadCloser, error) {
+	if runtime.GOOS == "windows" {
+		return os.Open("CONIN$")
+	}
+	return os.Open("/dev/tty")
+}
+
+// promptAuthorizationResponse writes authURL to stderr and, while the
+// callback server waits, reads the redirect URL or code the user pastes on
+// the terminal. The returned function stops reading.
+func (p *Proxy) promptAuthorizationResponse(authURL string) func() {
+	fmt.Fprintf(os.Stderr, "\nTo authorize access, open this URL in a browser:\n\n    %s\n\n", authURL)
+
+	in, err := p.openPrompt()
+	if err != nil {
+		slog.Debug("no terminal to read the authorization response from", "error", err)
+		return func() {}
+	}
+	fmt.Fprint(os.Stderr, "If the browser cannot reach this machine after you approve, paste the URL it was redirected to (or the code) and press Enter:\n")
+
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			// A failed submission fails the flow, so there is no retry.
+			err := p.authCoord.SubmitAuthorizationResponse(line)
+			if err != nil && !errors.Is(err, auth.ErrNoAuthorizationPending) {
+				fmt.Fprintf(os.Stderr, "Authorization failed: %v\n", err)
+			}
+			return
+		}
+	}()
+	return func() { _ = in.Close() }
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticateWithPastedCode(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"registration_endpoint":  server.URL + "/register",
+
+				"code_challenge_methods_supported": []string{"S256"},
+			})
+		case "/register":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"client_id":"client"}`))
+		case "/token":
+			if err := r.ParseForm(); err != nil || r.Form.Get("code") != "pasted-code" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"access","token_type":"Bearer"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewProxyWithOptions(server.URL+"/mcp", 0, map[string]string{}, "prompt-test", TransportModeStreamableHTTP, "", WithNoBrowser())
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+	p.openPrompt = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("\npasted-code\n")), nil
+	}
+
+	if err := p.authenticate(""); err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if got := p.getAuthToken(); got != "access" {
+		t.Errorf("Expected token 'access', got %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// SupportedProtocolVersions lists the MCP protocol versions the proxy
+// understands, newest first.
+var SupportedProtocolVersions = []string{MCPProtocolVersion, "2025-06-18", "2025-03-26", "2024-11-05"}
+
+// protocolVersionHeaderSince is the first protocol version that defines the
+// Mcp-Protocol-Version header. Servers on older versions do not expect it.
+const protocolVersionHeaderSince = "2025-06-18"
+
+// IsSupportedProtocolVersion reports whether version is in
+// SupportedProtocolVersions.
+func IsSupportedProtocolVersion(version string) bool {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// protocolState tracks the protocol version agreed with the server.
+type protocolState struct {
+	// override, when set, replaces the version the client asks for in
+	// initialize.
+	override string
+
+	mu sync.Mutex
+	// pending holds the IDs of initialize requests awaiting the server.
+	pending map[string]bool
+	// version is the version the server answered initialize with.
+	version string
+}
+
+// requested returns the version the proxy offers before one is agreed.
+func (s *protocolState) requested() string {
+	if s.override != "" {
+		return s.override
+	}
+	return MCPProtocolVersion
+}
+
+// current returns the agreed version, or the requested one before
+// initialize was answered.
+func (s *protocolState) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.version != "" {
+		return s.version
+	}
+	return s.requested()
+}
+
+// header returns the value of the Mcp-Protocol-Version header, or "" when
+// the version predates the header and it must be left out. Versions are
+// dates, so they compare as strings.
+func (s *protocolState) header() string {
+	if v := s.current(); v >= protocolVersionHeaderSince {
+		return v
+	}
+	return ""
+}
+
+// negotiated records the version the server answered initialize with.
+func (s *protocolState) negotiated(version string) {
+	s.mu.Lock()
+	previous := s.version
+	s.version = version
+	s.mu.Unlock()
+	if version == previous {
+		return
+	}
+	if !IsSupportedProtocolVersion(version) {
+		slog.Warn("server negotiated an unknown protocol version", "version", version)
+	}
+	if version != s.requested() {
+		slog.Info("negotiated older protocol version", "version", version, "requested", s.requested())
+	}
+	slog.Debug("protocol version negotiated", "version", version)
+}
+
+// protocolRequest notes an initialize request from the client so its
+// response can be read, and with an override, rewrites the version it
+// asks for. It returns the message to forward.
+func (p *Proxy) protocolRequest(message []byte) []byte {
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() || msg.Method != "initialize" {
+		return message
+	}
+	p.protocol.mu.Lock()
+	if p.protocol.pending == nil {
+		p.protocol.pending = make(map[string]bool)
+	}
+	p.protocol.pending[string(msg.ID)] = true
+	p.protocol.mu.Unlock()
+
+	if p.protocol.override == "" {
+		return message
+	}
+	var params map[string]json.RawMessage
+	if json.Unmarshal(msg.Params, &params) != nil || params == nil {
+		params = make(map[string]json.RawMessage)
+	}
+	version, _ := json.Marshal(p.protocol.override)
+	if string(params["protocolVersion"]) == string(version) {
+		return message
+	}
+	params["protocolVersion"] = version
+	msg.Params, _ = json.Marshal(params)
+	rewritten, err := json.Marshal(msg)
+	if err != nil {
+		return message
+	}
+	return rewritten
+}
+
+// protocolResponse reads the agreed version from the server's answer to a
+// client's initialize.
+func (p *Proxy) protocolResponse(message []byte) {
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isResponse() {
+		return
+	}
+	p.protocol.mu.Lock()
+	pending := p.protocol.pending[string(msg.ID)]
+	delete(p.protocol.pending, string(msg.ID))
+	p.protocol.mu.Unlock()
+	if !pending || msg.Error != nil {
+		return
+	}
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if json.Unmarshal(msg.Result, &result) == nil && result.ProtocolVersion != "" {
+		p.protocol.negotiated(result.ProtocolVersion)
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProtocolVersionNegotiation(t *testing.T) {
+	p, _ := newBatchTestProxy(&batchTestTransport{})
+	if got := p.protocol.header(); got != MCPProtocolVersion {
+		t.Errorf("Expected %s before initialize, got %q", MCPProtocolVersion, got)
+	}
+
+	p.outgoing([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25"}}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18"}}`))
+	if got := p.protocol.header(); got != "2025-06-18" {
+		t.Errorf("Expected the negotiated version in the header, got %q", got)
+	}
+
+	// Servers on versions before the header was defined do not get it.
+	p.outgoing([]byte(`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}}`))
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":2,"result":{"protocolVersion":"2024-11-05"}}`))
+	if got := p.protocol.current(); got != "2024-11-05" {
+		t.Errorf("Expected the downgrade recorded, got %q", got)
+	}
+	if got := p.protocol.header(); got != "" {
+		t.Errorf("Expected no header for 2024-11-05, got %q", got)
+	}
+
+	// Responses to other requests are not taken for initialize results.
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":3,"result":{"protocolVersion":"2025-11-25"}}`))
+	if got := p.protocol.current(); got != "2024-11-05" {
+		t.Errorf("Expected the version unchanged, got %q", got)
+	}
+}
+
+func TestProtocolVersionOverride(t *testing.T) {
+	p, _ := newBatchTestProxy(&batchTestTransport{})
+	p.protocol.override = "2025-03-26"
+	if got := p.protocol.header(); got != "" {
+		t.Errorf("Expected no header for a 2025-03-26 override, got %q", got)
+	}
+
+	out := p.outgoing([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{}}}`))
+	if !strings.Contains(string(out), `"protocolVersion":"2025-03-26"`) || !strings.Contains(string(out), `"capabilities":{}`) {
+		t.Errorf("Expected the requested version rewritten, got %s", out)
+	}
+	out = p.outgoing([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{"protocolVersion":"x"}}`))
+	if !strings.Contains(string(out), `"protocolVersion":"x"`) {
+		t.Errorf("Expected other requests unchanged, got %s", out)
+	}
+}
+
+func TestStreamableHTTPTransportProtocolVersionHeader(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(HeaderMCPProtocolVersion))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	version := "2025-06-18"
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:        server.URL,
+		Client:          server.Client(),
+		ProtocolVersion: func() string { return version },
+	})
+	for _, v := range []string{"2025-06-18", ""} {
+		version = v
+		if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if len(got) != 2 || got[0] != "2025-06-18" || got[1] != "" {
+		t.Errorf("Expected the header to follow the negotiated version, got %q", got)
+	}
+}
@@ -3,20 +3,26 @@ package proxy
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/naotama2002/mcp-remote-go/auth"
-	"github.com/pkg/browser"
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/browser"
+	"github.com/naotama2002/mcp-remote-go/internal/headervalue"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+	"github.com/naotama2002/mcp-remote-go/internal/metrics"
+	"github.com/naotama2002/mcp-remote-go/internal/resilience"
 )
 
 // TransportMode specifies which transport to use.
@@ -26,13 +32,52 @@ const (
 	TransportModeAuto           TransportMode = "auto"
 	TransportModeStreamableHTTP TransportMode = "streamable-http"
 	TransportModeSSE            TransportMode = "sse"
+	TransportModeWebSocket      TransportMode = "websocket"
+
+	// TransportModeHTTPFirst and TransportModeSSEFirst negotiate a
+	// transport, trying Streamable HTTP or SSE first and falling back to
+	// the other. TransportModeAuto negotiates as TransportModeHTTPFirst.
+	TransportModeHTTPFirst TransportMode = "http-first"
+	TransportModeSSEFirst  TransportMode = "sse-first"
+
+	// TransportModeReplay answers requests from a recording instead of
+	// a server; it is selected by WithReplay.
+	TransportModeReplay TransportMode = "replay"
+
+	// TransportModeCommand runs a local stdio MCP server instead of
+	// connecting to a remote one; it is selected by WithCommand.
+	TransportModeCommand TransportMode = "command"
 )
 
+// selectableTransportModes are the modes a user may choose, in the order
+// they are listed in errors.
+var selectableTransportModes = []TransportMode{
+	TransportModeAuto,
+	TransportModeHTTPFirst,
+	TransportModeSSEFirst,
+	TransportModeStreamableHTTP,
+	TransportModeSSE,
+	TransportModeWebSocket,
+}
+
+// ParseTransportMode returns the transport mode named s. Replay and command
+// modes are selected by their options and are not accepted.
+func ParseTransportMode(s string) (TransportMode, error) {
+	names := make([]string, len(selectableTransportModes))
+	for i, mode := range selectableTransportModes {
+		if TransportMode(s) == mode {
+			return mode, nil
+		}
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("invalid transport mode %q: must be one of %s", s, strings.Join(names, ", "))
+}
+
 // Proxy handles the bidirectional communication between stdio (MCP client) and the remote server
 type Proxy struct {
 	serverURL     string
 	callbackPort  int
-	headers       map[string]string
+	headers       HeaderProvider
 	serverURLHash string
 	transportMode TransportMode
 	authCoord     *auth.Coordinator
@@ -42,8 +87,143 @@ type Proxy struct {
 	transport     Transport
 	stdioReader   *bufio.Reader
 	stdioWriter   *bufio.Writer
+	framing       *stdioFraming
 	writerMu      sync.Mutex
 	wg            sync.WaitGroup
+	refresherOnce sync.Once
+
+	// transportMu guards transport and transportMode, which reconnects
+	// replace while other goroutines read them.
+	transportMu sync.RWMutex
+
+	// messageSink, when set, receives server messages instead of stdout.
+	// The aggregator uses it to intercept each upstream's traffic.
+	messageSink func(data []byte)
+
+	// authURLHandler, when set, is called with the authorization URL instead
+	// of opening the browser.
+	authURLHandler func(authURL string) error
+
+	// noBrowser prints the authorization URL instead of opening the browser.
+	noBrowser bool
+	// browser opens the authorization URL.
+	browser *browser.Launcher
+
+	// openPrompt opens the terminal that pasted authorization responses are
+	// read from.
+	openPrompt func() (io.ReadCloser, error)
+
+	// tracer, when set, records messages in both directions.
+	tracer Tracer
+
+	// replay, when set, answers requests instead of the server.
+	replay []RecordedMessage
+	// command, when set, is the local server run instead of connecting.
+	command []string
+	// probes replace the default transport probes during negotiation.
+	probes map[TransportMode]TransportProbe
+
+	// authFlow selects the OAuth flow run when the server requires
+	// authorization (auth.AuthFlowBrowser or auth.AuthFlowDevice).
+	authFlow string
+
+	// staticToken, when set, is sent as the bearer token and authCoord is nil.
+	staticToken string
+
+	// sessions, when set, persists the Streamable HTTP session across restarts.
+	sessions *sessionStore
+
+	// stats backs Status.
+	stats proxyStats
+
+	// inflight tracks requests awaiting a response from the server.
+	inflight inflightRequests
+
+	// latency records how long the server took to answer, by method.
+	latency latencyStats
+
+	// batches merges the responses to client batches that were split.
+	batches batchResponses
+
+	// strict rejects messages that are not valid JSON-RPC 2.0.
+	strict bool
+
+	// name identifies the server in log records when it is one of several
+	// aggregated servers.
+	name string
+
+	// forwardServerLogs passes the server's log messages to the client as
+	// well as logging them.
+	forwardServerLogs bool
+
+	// revokeOnExit revokes and deletes the tokens on shutdown, once.
+	revokeOnExit bool
+	revokeOnce   sync.Once
+
+	// protocol tracks the protocol version agreed with the server.
+	protocol protocolState
+
+	// throttle, when set, limits the rate of requests sent to the server.
+	throttle *throttle
+
+	// listCache, when set, answers repeated listing requests.
+	listCache *listCache
+	// resync holds what the client is told to refresh after a reconnect.
+	resync resyncState
+
+	// breaker, when set, stops sending to a server that keeps failing.
+	breaker *resilience.Breaker
+
+	// init, when enabled, performs the initialize handshake on connect and
+	// answers the client's initialize from its result.
+	init eagerInit
+
+	// clientVersion, when set, is appended to the clientInfo name in the
+	// client's initialize.
+	clientVersion string
+
+	// requestTimeout, when non-zero, bounds how long a request waits for
+	// the server's response.
+	requestTimeout time.Duration
+
+	// keepaliveInterval, when non-zero, enables dead connection detection
+	// in the transports.
+	keepaliveInterval time.Duration
+
+	// reconnect spaces reconnection attempts, here and in the transports.
+	reconnect backoff.Policy
+
+	// queueWhenRateLimited makes the transports hold rate-limited messages
+	// instead of failing them.
+	queueWhenRateLimited bool
+
+	// maxEventSize and maxBodySize limit what the transports read from the
+	// server; zero means the defaults.
+	maxEventSize int
+	maxBodySize  int
+
+	// sendQueue buffers messages for the server while reconnecting.
+	sendQueue sendQueue
+
+	// output queues messages for the client while Start serves stdio.
+	output outputQueue
+
+	// shutdownTimeout bounds how long Shutdown waits for in-flight requests.
+	// Zero closes the connection immediately.
+	shutdownTimeout time.Duration
+
+	// draining is set once Shutdown begins; new requests are then rejected.
+	draining atomic.Bool
+
+	// reauthMu serializes re-authentication after a rejected request.
+	reauthMu sync.Mutex
+
+	// tools, when set, hides and blocks tools by name. It is the first
+	// middleware in the chain.
+	tools *toolFilter
+
+	// middleware inspects and transforms messages in both directions.
+	middleware []Middleware
 }
 
 // NewProxy creates a new MCP proxy
@@ -57,27 +237,75 @@ func NewProxyWithTransport(serverURL string, callbackPort int, headers map[strin
 }
 
 // NewProxyWithOptions creates a new MCP proxy with full configuration including HTTP proxy support
-func NewProxyWithOptions(serverURL string, callbackPort int, headers map[string]string, serverURLHash string, mode TransportMode, httpProxyURL string) (*Proxy, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewProxyWithOptions(serverURL string, callbackPort int, headers map[string]string, serverURLHash string, mode TransportMode, httpProxyURL string, opts ...Option) (*Proxy, error) {
+	cfg := &options{sendBuffer: DefaultSendBuffer, outputQueue: DefaultOutputQueue}
+	for _, o := range opts {
+		o(cfg)
+	}
 
-	// Create auth coordinator
-	authCoord, err := auth.NewCoordinator(serverURLHash, callbackPort)
+	tools, err := newToolFilter(cfg.allowTools, cfg.denyTools)
 	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create auth coordinator: %w", err)
+		return nil, err
+	}
+	framing, err := newStdioFraming(cfg.stdioFraming, cfg.maxMessageSize)
+	if err != nil {
+		return nil, err
 	}
+	headerProvider := StaticHeaders(headers)
+	if cfg.headerTemplates {
+		templates, err := headervalue.NewProvider(headers, cfg.headerRefresh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand headers: %w", err)
+		}
+		headerProvider = templates.Get
+	}
+	if cfg.headerProvider != nil {
+		headerProvider = layerHeaders(headerProvider, cfg.headerProvider)
+	}
+
+	if cfg.replay != nil {
+		mode = TransportModeReplay
+	}
+	if cfg.command != nil {
+		mode = TransportModeCommand
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// Build HTTP client with optional proxy
-	httpClient, err := buildHTTPClient(httpProxyURL)
+	httpClient, err := buildHTTPClient(httpProxyURL, cfg.tlsConfig, cfg.pool)
 	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("failed to configure HTTP proxy: %w", err)
+		return nil, fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+	configureCompression(httpClient, !cfg.noCompression)
+
+	// Create auth coordinator, unless a static token replaces OAuth entirely
+	// or a recording or local command replaces the server. OAuth requests
+	// use the same proxy and TLS settings as MCP traffic.
+	var authCoord *auth.Coordinator
+	if cfg.staticToken == "" && cfg.replay == nil && cfg.command == nil {
+		coordinatorOpts := cfg.coordinatorOpts
+		if httpClient.Transport != nil {
+			coordinatorOpts = append(coordinatorOpts, auth.WithHTTPTransport(httpClient.Transport))
+		}
+		authCoord, err = auth.NewCoordinator(serverURLHash, callbackPort, coordinatorOpts...)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create auth coordinator: %w", err)
+		}
+		authCoord.MigrateLegacyKey(serverURL)
+	}
+
+	var sessions *sessionStore
+	if cfg.resumeSession {
+		sessions = newSessionStore(serverURLHash)
 	}
 
-	return &Proxy{
+	p := &Proxy{
 		serverURL:     serverURL,
 		callbackPort:  callbackPort,
-		headers:       headers,
+		headers:       headerProvider,
 		serverURLHash: serverURLHash,
 		transportMode: mode,
 		authCoord:     authCoord,
@@ -86,77 +314,216 @@ func NewProxyWithOptions(serverURL string, callbackPort int, headers map[string]
 		client:        httpClient,
 		stdioReader:   bufio.NewReader(os.Stdin),
 		stdioWriter:   bufio.NewWriter(os.Stdout),
-	}, nil
-}
-
-// buildHTTPClient creates an http.Client with optional proxy configuration.
-// When a proxy is configured, it clones http.DefaultTransport to preserve
-// HTTP/2, timeouts, and connection pooling defaults.
-func buildHTTPClient(proxyURL string) (*http.Client, error) {
-	if proxyURL == "" {
-		return &http.Client{}, nil
+		framing:       framing,
+
+		messageSink:       cfg.messageHandler,
+		authURLHandler:    cfg.authURLHandler,
+		noBrowser:         cfg.noBrowser,
+		browser:           browser.New(cfg.browserCommand),
+		openPrompt:        openTerminal,
+		tracer:            cfg.tracer,
+		replay:            cfg.replay,
+		command:           cfg.command,
+		probes:            cfg.probes,
+		authFlow:          cfg.authFlow,
+		staticToken:       cfg.staticToken,
+		sessions:          sessions,
+		tools:             tools,
+		strict:            cfg.strict,
+		forwardServerLogs: cfg.forwardServerLogs,
+		revokeOnExit:      cfg.revokeOnExit,
+		init:              eagerInit{enabled: cfg.eagerInit, version: cfg.clientVersion},
+		clientVersion:     cfg.clientVersion,
+		protocol:          protocolState{override: cfg.protocolVersion},
+		listCache:         newListCache(cfg.listCacheTTL),
+		throttle:          newThrottle(cfg.maxRPS, cfg.burst, cfg.queueLimited),
+		breaker:           resilience.NewBreaker(cfg.breakerThreshold, cfg.breakerCooldown),
+
+		shutdownTimeout: cfg.shutdownTimeout,
+		requestTimeout:  cfg.requestTimeout,
+
+		keepaliveInterval: cfg.keepalive,
+		reconnect:         cfg.reconnect,
+
+		queueWhenRateLimited: cfg.queueLimited,
+		maxEventSize:         cfg.maxEventSize,
+		maxBodySize:          cfg.maxBodySize,
+		sendQueue:            sendQueue{size: cfg.sendBuffer},
+		output:               outputQueue{size: cfg.outputQueue},
 	}
-
-	parsed, err := url.Parse(proxyURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	if tools != nil {
+		p.Use(tools.handle)
 	}
+	p.Use(cfg.middleware...)
+	return p, nil
+}
 
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http or https", proxyURL)
-	}
-	if parsed.Host == "" {
-		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+// buildHTTPClient creates the http.Client used for every request of the
+// proxy, to the server and the authorization server alike, so that they
+// share one connection pool. Without proxyURL, HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY are honored.
+func buildHTTPClient(proxyURL string, tlsConfig *tls.Config, pool httpclient.PoolOptions) (*http.Client, error) {
+	transport, err := httpclient.NewTransport(proxyURL, tlsConfig, pool)
+	if err != nil {
+		return nil, err
 	}
-
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.Proxy = http.ProxyURL(parsed)
-
 	return &http.Client{
 		Transport: transport,
 	}, nil
 }
 
+// configureCompression makes client ask for gzip or deflate compressed
+// responses and decompress them, or, when enabled is false, not ask for
+// compression at all.
+func configureCompression(client *http.Client, enabled bool) {
+	if enabled {
+		client.Transport = httpclient.NewCompressionTransport(client.Transport)
+		return
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.DisableCompression = true
+	client.Transport = transport
+}
+
 // Start initializes the proxy and begins bidirectional communication
 func (p *Proxy) Start() error {
-	log.Println("Starting MCP proxy")
-	log.Println("Connecting to remote server:", p.serverURL)
+	slog.Info("starting MCP proxy", "server", p.serverURL)
 
-	if err := p.connectToServer(); err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+	if err := p.Connect(); err != nil {
+		p.reportStartupFailure(err)
+		return err
 	}
 
+	p.output.start(p.writeMessage)
 	p.wg.Add(1)
 	go p.processStdioInput()
 
 	p.wg.Wait()
+	p.output.stop()
 	return nil
 }
 
-// Shutdown gracefully stops the proxy
+// Connect establishes the connection to the remote server, running the OAuth
+// flow if required, without serving stdio. Server messages go to the handler
+// set with WithMessageHandler.
+func (p *Proxy) Connect() error {
+	if err := p.connectToServer(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	p.startTokenRefresher()
+	if p.init.enabled {
+		if err := p.initializeServer(); err != nil {
+			return fmt.Errorf("failed to initialize server: %w", err)
+		}
+	}
+	return nil
+}
+
+// Send forwards a single JSON-RPC message to the remote server.
+func (p *Proxy) Send(ctx context.Context, message []byte) error {
+	t := p.currentTransport()
+	if t == nil {
+		return errors.New("not connected to server")
+	}
+	if message = p.outgoing(message); message == nil {
+		return nil
+	}
+	if err := p.throttleRequest(ctx, message); err != nil {
+		return err
+	}
+	p.trace(TraceLocalToRemote, message)
+	if queued, err := p.sendQueue.enqueue(message); queued {
+		return err
+	}
+	reqCtx := p.requestContext(ctx, message)
+	if err := p.sendTransport(reqCtx, t, message); err != nil {
+		if cancelledRequest(reqCtx, ctx, err) {
+			slog.Debug("request cancelled before the server answered", "error", err)
+			return nil
+		}
+		p.stats.recordError(err)
+		return err
+	}
+	return nil
+}
+
+// SessionID returns the MCP session ID assigned by the server, if any.
+func (p *Proxy) SessionID() string {
+	t := p.currentTransport()
+	if t == nil {
+		return ""
+	}
+	return t.SessionID()
+}
+
+// Done returns a channel that is closed when the proxy shuts down, including
+// after an unrecoverable connection error.
+func (p *Proxy) Done() <-chan struct{} {
+	return p.ctx.Done()
+}
+
+// Shutdown gracefully stops the proxy. New requests are rejected and, when a
+// shutdown timeout is set, requests already sent to the server are given that
+// long to complete before the connection is closed.
 func (p *Proxy) Shutdown() {
-	log.Println("Shutting down proxy")
-	if p.transport != nil {
-		if err := p.transport.Close(); err != nil {
-			log.Printf("Warning: failed to close transport: %v", err)
+	slog.Info("shutting down proxy")
+	p.drain()
+	if t := p.currentTransport(); t != nil {
+		if err := t.Close(); err != nil {
+			slog.Warn("failed to close transport", "error", err)
 		}
 	}
+	p.revokeTokens()
 	p.cancel()
+	if p.authCoord != nil {
+		p.authCoord.Close()
+	}
 	p.wg.Wait()
+	p.output.stop()
+}
+
+// startTokenRefresher keeps the stored access token fresh for the lifetime
+// of the proxy. Transports read it through getAuthToken on every request, so
+// a refreshed token is used without reconnecting; event streams are reopened
+// with it by watchTokens.
+func (p *Proxy) startTokenRefresher() {
+	if p.authCoord == nil {
+		return
+	}
+	p.refresherOnce.Do(func() {
+		p.authCoord.StartTokenRefresher(p.ctx, p.serverURL)
+		go p.watchTokens()
+	})
 }
 
 // getAuthToken returns the current auth token if available.
 func (p *Proxy) getAuthToken() string {
+	if p.staticToken != "" {
+		return p.staticToken
+	}
 	tokens, err := p.authCoord.LoadTokens()
-	if err == nil && tokens.AccessToken != "" {
-		return tokens.AccessToken
+	if err != nil || tokens.AccessToken == "" {
+		return ""
 	}
-	return ""
+	// Tokens granted other scopes than configured are not sent, so the
+	// server's 401 starts a new authorization for the configured scopes.
+	if !p.authCoord.ScopeMatches(tokens) {
+		slog.Debug("stored tokens were granted different scopes", "granted", tokens.Scope)
+		return ""
+	}
+	return tokens.AccessToken
 }
 
 // connectToServer establishes a connection using the configured transport
 func (p *Proxy) connectToServer() error {
-	if p.transportMode == TransportModeAuto {
+	if _, ok := negotiationOrders[p.transportMode]; ok {
+		// ws:// and wss:// URLs can only be served by the WebSocket transport.
+		if isWebSocketURL(p.serverURL) {
+			return p.connectWithMode(TransportModeWebSocket)
+		}
 		return p.negotiateTransport()
 	}
 
@@ -168,81 +535,17 @@ func (p *Proxy) connectToServer() error {
 	if err != nil {
 		var unauth *UnauthorizedError
 		if errors.As(err, &unauth) {
-			log.Println("Authentication required")
+			slog.Info("authentication required")
 			return p.handleAuthentication(unauth.WWWAuthenticate)
 		}
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
-	p.transport = t
-	log.Println("Connected to server successfully")
+	p.setTransport(t, p.transportMode)
+	slog.Info("connected to server")
 	return nil
 }
 
-// negotiateTransport attempts Streamable HTTP first, then falls back to SSE.
-func (p *Proxy) negotiateTransport() error {
-	log.Println("Auto-detecting transport...")
-
-	// Try Streamable HTTP first: send a POST probe to the server URL
-	probeReq, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.serverURL, strings.NewReader(`{"jsonrpc":"2.0","method":"ping","id":0}`))
-	if err != nil {
-		// If we can't even create the request, fall back to SSE
-		log.Printf("Failed to create probe request: %v, falling back to SSE", err)
-		return p.connectWithMode(TransportModeSSE)
-	}
-
-	for k, v := range p.headers {
-		probeReq.Header.Set(k, v)
-	}
-	if token := p.getAuthToken(); token != "" {
-		probeReq.Header.Set("Authorization", "Bearer "+token)
-	}
-	probeReq.Header.Set("Content-Type", "application/json")
-	probeReq.Header.Set("Accept", "application/json, text/event-stream")
-	probeReq.Header.Set(HeaderMCPProtocolVersion, MCPProtocolVersion)
-
-	resp, err := p.client.Do(probeReq)
-	if err != nil {
-		log.Printf("Streamable HTTP probe failed: %v, falling back to SSE", err)
-		return p.connectWithMode(TransportModeSSE)
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	wwwAuth := auth.BestWWWAuthenticateHeader(resp.Header.Values(HeaderWWWAuthenticate))
-	if closeErr := resp.Body.Close(); closeErr != nil {
-		log.Printf("Warning: failed to close probe response body: %v", closeErr)
-	}
-
-	// Check if response body looks like JSON-RPC (indicates Streamable HTTP support)
-	isJSONRPC := len(body) > 0 && json.Valid(body) && isJSONRPCResponse(body)
-
-	switch {
-	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted:
-		// Server supports Streamable HTTP
-		log.Println("Server supports Streamable HTTP transport")
-		return p.connectWithMode(TransportModeStreamableHTTP)
-
-	case resp.StatusCode == http.StatusUnauthorized:
-		log.Println("Authentication required")
-		return p.handleAuthentication(wwwAuth)
-
-	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
-		// Server does not support Streamable HTTP, fall back to SSE
-		log.Printf("Server returned %d, falling back to SSE transport", resp.StatusCode)
-		return p.connectWithMode(TransportModeSSE)
-
-	case isJSONRPC:
-		// Server returned an error status but with a JSON-RPC body,
-		// which means it understands the protocol (Streamable HTTP)
-		log.Printf("Server returned %d with JSON-RPC body, using Streamable HTTP transport", resp.StatusCode)
-		return p.connectWithMode(TransportModeStreamableHTTP)
-
-	default:
-		log.Printf("Unexpected status %d from probe, falling back to SSE", resp.StatusCode)
-		return p.connectWithMode(TransportModeSSE)
-	}
-}
-
 // connectWithMode connects using a specific transport mode.
 func (p *Proxy) connectWithMode(mode TransportMode) error {
 	t := p.createTransport(mode)
@@ -253,16 +556,39 @@ func (p *Proxy) connectWithMode(mode TransportMode) error {
 	if err != nil {
 		var unauth *UnauthorizedError
 		if errors.As(err, &unauth) {
-			log.Println("Authentication required")
+			slog.Info("authentication required")
 			return p.handleAuthentication(unauth.WWWAuthenticate)
 		}
 		return fmt.Errorf("failed to connect with %s transport: %w", mode, err)
 	}
 
+	p.setTransport(t, mode)
+	slog.Info("connected", "transport", mode)
+	return nil
+}
+
+// setTransport makes t, connected with mode, the transport messages are
+// sent through.
+func (p *Proxy) setTransport(t Transport, mode TransportMode) {
+	p.transportMu.Lock()
 	p.transport = t
 	p.transportMode = mode
-	log.Printf("Connected using %s transport", mode)
-	return nil
+	p.transportMu.Unlock()
+}
+
+// currentTransport returns the transport, for goroutines running alongside
+// reconnects, which replace it.
+func (p *Proxy) currentTransport() Transport {
+	p.transportMu.RLock()
+	defer p.transportMu.RUnlock()
+	return p.transport
+}
+
+// currentTransportMode returns the mode of the current transport.
+func (p *Proxy) currentTransportMode() TransportMode {
+	p.transportMu.RLock()
+	defer p.transportMu.RUnlock()
+	return p.transportMode
 }
 
 // createTransport creates the appropriate Transport for the given mode.
@@ -272,67 +598,153 @@ func (p *Proxy) createTransport(mode TransportMode) Transport {
 		return NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
 			Endpoint:     p.serverURL,
 			Client:       p.client,
-			Headers:      p.headers,
+			GetHeaders:   p.headers,
 			GetAuthToken: p.getAuthToken,
+			sessions:     p.sessions,
+
+			ProtocolVersion: p.protocol.header,
+
+			Reauthenticate:    p.reauthenticate,
+			KeepaliveInterval: p.keepaliveInterval,
+			Reconnect:         p.reconnect,
+
+			QueueWhenRateLimited: p.queueWhenRateLimited,
+
+			MaxEventSize: p.maxEventSize,
+			MaxBodySize:  p.maxBodySize,
+		})
+	case TransportModeReplay:
+		return newReplayTransport(p.replay)
+	case TransportModeCommand:
+		return NewCommandTransport(p.command)
+	case TransportModeWebSocket:
+		return NewWebSocketTransport(WebSocketTransportConfig{
+			Endpoint:     p.serverURL,
+			Client:       p.client,
+			GetHeaders:   p.headers,
+			GetAuthToken: p.getAuthToken,
+
+			KeepaliveInterval: p.keepaliveInterval,
+			Reconnect:         p.reconnect,
+
+			MaxMessageSize: p.maxBodySize,
 		})
 	default: // SSE
 		return NewSSETransport(SSETransportConfig{
 			ServerURL:    p.serverURL,
 			Client:       p.client,
-			Headers:      p.headers,
+			GetHeaders:   p.headers,
 			GetAuthToken: p.getAuthToken,
+
+			KeepaliveInterval: p.keepaliveInterval,
+			Reconnect:         p.reconnect,
+
+			QueueWhenRateLimited: p.queueWhenRateLimited,
+
+			MaxEventSize: p.maxEventSize,
 		})
 	}
 }
 
-// openBrowser opens the specified URL in the default browser
-func openBrowser(rawURL string) error {
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+// handleAuthentication runs the OAuth flow and reconnects with the new token.
+func (p *Proxy) handleAuthentication(wwwAuthenticate string) error {
+	if err := p.authenticate(wwwAuthenticate); err != nil {
+		return err
+	}
+	return p.connectToServer()
+}
+
+// reauthenticate obtains a new access token after the server rejected
+// rejectedToken, without reconnecting: transports read the token on every
+// request. A refresh is tried first, unless the server asked for more scope;
+// if it fails the OAuth flow runs again. Concurrent callers share one
+// attempt, since a token that already differs from rejectedToken is fresh.
+func (p *Proxy) reauthenticate(ctx context.Context, rejectedToken string, unauth *UnauthorizedError) error {
+	if p.authCoord == nil {
+		return errors.New("server rejected the configured bearer token")
 	}
 
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return errors.New("only http and https URLs are allowed")
+	p.reauthMu.Lock()
+	defer p.reauthMu.Unlock()
+
+	if token := p.getAuthToken(); token != "" && token != rejectedToken {
+		return nil
 	}
 
-	return browser.OpenURL(rawURL)
+	// Without a usable token (none stored, or one granted other scopes)
+	// there is nothing worth refreshing.
+	challenge, _ := auth.ParseWWWAuthenticate(unauth.WWWAuthenticate)
+	if challenge.Error != "insufficient_scope" && rejectedToken != "" {
+		_, err := p.authCoord.RefreshTokens(ctx)
+		if err == nil {
+			slog.Info("access token refreshed")
+			return nil
+		}
+		slog.Info("token refresh failed, starting authorization", "error", err)
+	}
+	return p.authenticate(unauth.WWWAuthenticate)
 }
 
-// handleAuthentication runs the OAuth flow. When the triggering 401 carried a
-// WWW-Authenticate Bearer challenge, its resource_metadata URL (RFC 9728 §5.1)
-// is forwarded to discovery.
-func (p *Proxy) handleAuthentication(wwwAuthenticate string) error {
+// authenticate runs the OAuth flow and saves the tokens. When the triggering
+// 401 carried a WWW-Authenticate Bearer challenge, its resource_metadata URL
+// (RFC 9728 §5.1) is forwarded to discovery.
+func (p *Proxy) authenticate(wwwAuthenticate string) error {
+	if p.authCoord == nil {
+		return errors.New("server rejected the configured bearer token")
+	}
+
 	var initOpts []auth.InitOption
 	if wwwAuthenticate != "" {
 		if challenge, ok := auth.ParseWWWAuthenticate(wwwAuthenticate); ok && challenge.ResourceMetadata != "" {
-			log.Printf("Using resource_metadata URL from WWW-Authenticate: %s", challenge.ResourceMetadata)
+			slog.Debug("using resource_metadata URL from WWW-Authenticate", "url", challenge.ResourceMetadata)
 			initOpts = append(initOpts, auth.WithResourceMetadataURL(challenge.ResourceMetadata))
 		}
 	}
 
-	authURL, err := p.authCoord.InitializeAuth(p.serverURL, initOpts...)
+	switch p.authFlow {
+	case auth.AuthFlowDevice:
+		return p.handleDeviceAuthentication(initOpts)
+	case auth.AuthFlowClientCredentials:
+		return p.handleClientCredentialsAuthentication(initOpts)
+	}
+
+	authURL, err := p.authCoord.InitializeAuth(p.ctx, p.serverURL, initOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to initialize auth: %w", err)
 	}
 
-	log.Println("Please authorize access in your browser at:", authURL)
+	stopPrompt := func() {}
+	switch {
+	case p.authURLHandler != nil:
+		if err := p.authURLHandler(authURL); err != nil {
+			return fmt.Errorf("authorization URL handler failed: %w", err)
+		}
+	case p.noBrowser:
+		stopPrompt = p.promptAuthorizationResponse(authURL)
+	default:
+		slog.Info("please authorize access in your browser", "url", authURL)
 
-	if err := openBrowser(authURL); err != nil {
-		log.Printf("Failed to open browser automatically: %v", err)
-		log.Println("Please open the URL manually in your browser.")
-	} else {
-		log.Println("Opening browser...")
+		if err := p.browser.Open(authURL); err != nil {
+			slog.Warn("failed to open browser automatically, please open the URL manually", "error", err)
+			stopPrompt = p.promptAuthorizationResponse(authURL)
+		} else {
+			slog.Info("opening browser")
+		}
 	}
 
-	code, err := p.authCoord.WaitForAuthCode()
+	code, err := p.authCoord.WaitForAuthCode(p.ctx)
+	stopPrompt()
+	var denied *auth.AuthorizationError
+	if errors.As(err, &denied) {
+		slog.Error("authorization server returned an error", "error", denied.Code, "description", denied.Description, "uri", denied.URI)
+	}
 	if err != nil {
 		return fmt.Errorf("auth code retrieval failed: %w", err)
 	}
 
-	log.Println("Auth code received, exchanging for tokens...")
+	slog.Info("auth code received, exchanging for tokens")
 
-	tokens, err := p.authCoord.ExchangeCode(code)
+	tokens, err := p.authCoord.ExchangeCode(p.ctx, code)
 	if err != nil {
 		return fmt.Errorf("token exchange failed: %w", err)
 	}
@@ -340,8 +752,50 @@ func (p *Proxy) handleAuthentication(wwwAuthenticate string) error {
 	if err := p.authCoord.SaveTokens(tokens); err != nil {
 		return fmt.Errorf("failed to save tokens: %w", err)
 	}
+	return nil
+}
 
-	return p.connectToServer()
+// handleClientCredentialsAuthentication obtains a token with the configured
+// client ID and secret; no user interaction is needed.
+func (p *Proxy) handleClientCredentialsAuthentication(initOpts []auth.InitOption) error {
+	tokens, err := p.authCoord.ClientCredentialsAuth(p.ctx, p.serverURL, initOpts...)
+	if err != nil {
+		return fmt.Errorf("client credentials authentication failed: %w", err)
+	}
+
+	if err := p.authCoord.SaveTokens(tokens); err != nil {
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return nil
+}
+
+// handleDeviceAuthentication runs the device authorization grant (RFC 8628).
+// The user code is written to stderr, since stdout carries the MCP protocol.
+func (p *Proxy) handleDeviceAuthentication(initOpts []auth.InitOption) error {
+	da, err := p.authCoord.StartDeviceAuth(p.ctx, p.serverURL, initOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to start device authorization: %w", err)
+	}
+
+	if p.authURLHandler != nil && da.VerificationURIComplete != "" {
+		if err := p.authURLHandler(da.VerificationURIComplete); err != nil {
+			return fmt.Errorf("authorization URL handler failed: %w", err)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "\nTo authorize access, visit:\n\n    %s\n\nand enter the code: %s\n\n", da.VerificationURI, da.UserCode)
+	}
+
+	tokens, err := p.authCoord.PollDeviceToken(p.ctx, da)
+	if err != nil {
+		return fmt.Errorf("device authorization failed: %w", err)
+	}
+
+	slog.Info("device authorized")
+
+	if err := p.authCoord.SaveTokens(tokens); err != nil {
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return nil
 }
 
 // processStdioInput reads messages from stdin and forwards them to the server
@@ -353,81 +807,188 @@ func (p *Proxy) processStdioInput() {
 		case <-p.ctx.Done():
 			return
 		default:
-			line, err := p.stdioReader.ReadString('\n')
+			line, err := p.framing.readMessage(p.stdioReader)
 			if err != nil {
 				if err == io.EOF {
-					log.Println("STDIO input closed")
+					slog.Info("STDIO input closed")
+					p.drain()
 					// Close transport and cancel context directly instead of calling
 					// Shutdown() to avoid deadlock (Shutdown calls wg.Wait, but this
 					// goroutine hasn't called wg.Done yet via defer).
-					if p.transport != nil {
-						if closeErr := p.transport.Close(); closeErr != nil {
-							log.Printf("Warning: failed to close transport: %v", closeErr)
+					if t := p.currentTransport(); t != nil {
+						if closeErr := t.Close(); closeErr != nil {
+							slog.Warn("failed to close transport", "error", closeErr)
 						}
 					}
+					p.revokeTokens()
 					p.cancel()
 					return
 				}
-				log.Printf("Error reading from STDIO: %v", err)
+				slog.Error("failed to read from STDIO", "error", err)
 				continue
 			}
 
-			var msg map[string]interface{}
-			if err := json.Unmarshal([]byte(line), &msg); err == nil {
-				if method, ok := msg["method"].(string); ok {
-					log.Printf("[Local→Remote] %s", method)
-				} else if id, ok := msg["id"].(float64); ok {
-					log.Printf("[Local→Remote] Response ID: %v", id)
-				}
+			if p.currentTransport() == nil {
+				slog.Error("failed to send to server: not connected")
+				continue
 			}
-
-			if p.transport == nil {
-				log.Printf("Error sending to server: not connected")
+			if elements, ok := splitBatch(line); ok {
+				p.sendBatch(elements)
 				continue
 			}
-			if err := p.transport.Send(p.ctx, []byte(line)); err != nil {
-				log.Printf("Error sending to server: %v", err)
+
+			logMessage("local to remote", line)
+			p.releaseCancelled(line)
+			if message := p.outgoing(line); message != nil {
+				if err := p.sendToServer(message); err != nil {
+					p.failSend(message, err)
+				}
 			}
 		}
 	}
 }
 
-// handleServerMessage processes messages received from the server
-func (p *Proxy) handleServerMessage(event string, data []byte) {
-	if event != "message" && event != "" {
+// sendToServer traces message and sends it to the server, or buffers it
+// while the proxy reconnects.
+func (p *Proxy) sendToServer(message []byte) error {
+	if err := p.throttleRequest(p.ctx, message); err != nil {
+		return err
+	}
+	p.trace(TraceLocalToRemote, message)
+	if queued, err := p.sendQueue.enqueue(message); queued {
+		return err
+	}
+	ctx := p.requestContext(p.ctx, message)
+	if err := p.sendTransport(ctx, p.currentTransport(), message); err != nil {
+		if cancelledRequest(ctx, p.ctx, err) {
+			slog.Debug("request cancelled before the server answered", "error", err)
+			return nil
+		}
+		// The connection may have dropped under the message; if the proxy
+		// started reconnecting meanwhile, send it again once it is back.
+		if queued, qerr := p.sendQueue.enqueue(message); queued && qerr == nil {
+			slog.Debug("send failed while reconnecting, buffering message", "error", err)
+			return nil
+		}
+		slog.Error("failed to send to server", "error", err)
+		p.stats.recordError(err)
+		return err
+	}
+	return nil
+}
+
+// failSend answers a request that could not be sent to the server, e.g.
+// because the server is rate limiting the proxy, so the client is not left
+// waiting for it.
+func (p *Proxy) failSend(message []byte, err error) {
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
 		return
 	}
+	p.inflight.remove(string(msg.ID))
+	p.deliver(newErrorMessage(msg.ID, jsonRPCInternalError, "failed to send to server: "+err.Error()))
+}
 
+// logMessage logs the method, or for a response the ID, of a message.
+func logMessage(direction string, data []byte) {
 	var msg map[string]interface{}
 	if err := json.Unmarshal(data, &msg); err == nil {
 		if method, ok := msg["method"].(string); ok {
-			log.Printf("[Remote→Local] %s", method)
+			slog.Debug(direction, "method", method)
 		} else if id, ok := msg["id"].(float64); ok {
-			log.Printf("[Remote→Local] Response ID: %v", id)
+			slog.Debug(direction, "response_id", id)
+		}
+	}
+}
+
+// handleServerMessage processes messages received from the server
+func (p *Proxy) handleServerMessage(event string, data []byte) {
+	if event != "message" && event != "" {
+		return
+	}
+	p.trace(TraceRemoteToLocal, data)
+	if p.eagerInitResponse(data) {
+		return
+	}
+
+	if elements, ok := splitBatch(data); ok {
+		var out [][]byte
+		for _, element := range elements {
+			logMessage("remote to local", element)
+			p.protocolResponse(element)
+			if !p.acceptFromServer(element) || p.lateResponse(element) || !p.serverLog(element) {
+				continue
+			}
+			if element = p.incoming(element); element != nil {
+				p.observeForListCache(element)
+				out = append(out, element)
+			}
 		}
+		if len(out) > 0 {
+			p.deliver(joinBatch(out))
+		}
+		return
 	}
 
+	logMessage("remote to local", data)
+	p.protocolResponse(data)
+	if !p.acceptFromServer(data) || p.lateResponse(data) || !p.serverLog(data) {
+		return
+	}
+	if data = p.incoming(data); data != nil {
+		p.observeForListCache(data)
+		p.deliver(data)
+	}
+}
+
+// deliver passes a message for the client to the message sink or stdout.
+func (p *Proxy) deliver(data []byte) {
+	if p.messageSink != nil {
+		p.messageSink(data)
+		return
+	}
 	p.writeToStdout(data)
 }
 
-// writeToStdout safely writes data to stdout with a newline.
+// trace counts message, records its metrics and passes it to the tracer, if
+// one is configured.
+func (p *Proxy) trace(direction string, message []byte) {
+	if direction == TraceLocalToRemote {
+		p.stats.sent.Add(1)
+	} else {
+		p.stats.received.Add(1)
+	}
+	p.recordMetrics(direction, message)
+	if p.tracer != nil {
+		p.tracer.Trace(direction, p.serverURL, message)
+	}
+}
+
+// writeToStdout queues one message for stdout. Responses to a split batch
+// are held back until the batch is complete.
 func (p *Proxy) writeToStdout(data []byte) {
+	if data = p.batches.collect(data); data == nil {
+		return
+	}
+	if err := p.output.push(data, p.writeMessage); err != nil {
+		p.stats.recordError(err)
+	}
+}
+
+// writeMessage safely writes one framed message to stdout.
+func (p *Proxy) writeMessage(data []byte) {
 	p.writerMu.Lock()
 	defer p.writerMu.Unlock()
 
-	data = append(data, '\n')
-	if _, err := p.stdioWriter.Write(data); err != nil {
-		log.Printf("Error writing to STDIO: %v", err)
-		return
-	}
-	if err := p.stdioWriter.Flush(); err != nil {
-		log.Printf("Error flushing STDIO: %v", err)
+	if err := p.framing.writeMessage(p.stdioWriter, data); err != nil {
+		slog.Error("failed to write to STDIO", "error", err)
 	}
 }
 
 // handleServerError handles errors from the transport
 func (p *Proxy) handleServerError(err error) {
-	log.Printf("Transport error: %v", err)
+	slog.Warn("transport error", "error", err)
+	p.stats.recordError(err)
 
 	if errors.Is(err, context.Canceled) {
 		return
@@ -435,20 +996,54 @@ func (p *Proxy) handleServerError(err error) {
 
 	var unauth *UnauthorizedError
 	if errors.As(err, &unauth) {
-		log.Println("Authentication error, trying to re-authenticate...")
+		slog.Info("authentication error, trying to re-authenticate")
 		if err := p.handleAuthentication(unauth.WWWAuthenticate); err != nil {
-			log.Printf("Re-authentication failed: %v", err)
+			slog.Error("re-authentication failed", "error", err)
+			p.reportFailure(fmt.Errorf("re-authentication failed: %w", err))
 			p.Shutdown()
 		}
 		return
 	}
 
-	time.Sleep(5 * time.Second)
-	log.Println("Attempting to reconnect...")
-	if err := p.connectToServer(); err != nil {
-		log.Printf("Reconnection failed: %v", err)
-		p.Shutdown()
+	p.sendQueue.hold()
+	b := p.reconnect.New()
+	lastErr := err
+	for {
+		delay, ok := b.Next()
+		if !ok {
+			break
+		}
+		select {
+		case <-p.ctx.Done():
+			p.failSendQueue(p.ctx.Err())
+			return
+		case <-time.After(retryDelay(delay, lastErr)):
+		}
+
+		attempt := b.Attempt()
+		slog.Info("attempting to reconnect", "attempt", attempt, "max_attempts", p.reconnect.Attempts())
+		lastErr = p.connectToServer()
+		p.recordOutcome(lastErr)
+		if lastErr == nil {
+			metrics.ReconnectsTotal.Inc(p.serverURL, metrics.ResultSuccess)
+			p.resyncAfterReconnect()
+			p.flushSendQueue()
+			return
+		}
+		metrics.ReconnectsTotal.Inc(p.serverURL, metrics.ResultFailure)
+		slog.Warn("reconnect attempt failed", "attempt", attempt, "error", lastErr)
+		var denied *auth.AuthorizationError
+		if errors.As(lastErr, &denied) {
+			// Access was refused; trying again would only ask the user
+			// for the same authorization.
+			break
+		}
 	}
+
+	slog.Error("reconnection failed", "error", lastErr)
+	p.failSendQueue(fmt.Errorf("reconnection failed: %w", lastErr))
+	p.reportFailure(fmt.Errorf("reconnection failed: %w", lastErr))
+	p.Shutdown()
 }
 
 // isJSONRPCResponse checks if the body looks like a JSON-RPC response.
@@ -469,14 +1064,14 @@ func (p *Proxy) SetStdio(reader *bufio.Reader, writer *bufio.Writer) {
 
 // SetCommandEndpoint sets the command endpoint URL (for backward compatibility in tests).
 func (p *Proxy) SetCommandEndpoint(endpoint string) {
-	if sseTransport, ok := p.transport.(*SSETransport); ok {
+	if sseTransport, ok := p.currentTransport().(*SSETransport); ok {
 		sseTransport.setCommandEndpoint(endpoint)
 	}
 }
 
 // GetCommandEndpoint returns the command endpoint URL (for backward compatibility in tests).
 func (p *Proxy) GetCommandEndpoint() string {
-	if sseTransport, ok := p.transport.(*SSETransport); ok {
+	if sseTransport, ok := p.currentTransport().(*SSETransport); ok {
 		return sseTransport.getCommandEndpointValue()
 	}
 	return ""
@@ -1,9 +1,21 @@
 package proxy
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
 
 func TestNewProxy(t *testing.T) {
@@ -78,8 +90,8 @@ func TestNewProxy(t *testing.T) {
 
 				// Verify headers
 				for k, v := range tt.headers {
-					if proxy.headers[k] != v {
-						t.Errorf("Expected header %s: %s, got %s", k, v, proxy.headers[k])
+					if got := proxy.headers()[k]; got != v {
+						t.Errorf("Expected header %s: %s, got %s", k, v, got)
 					}
 				}
 
@@ -152,8 +164,58 @@ func TestProxyContext(t *testing.T) {
 	}
 }
 
+func TestProxyReconnectWhileSending(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set(HeaderMCPSessionID, "race-session")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	proxy, err := NewProxyWithTransport(server.URL, 0, map[string]string{}, "reconnect-race-test", TransportModeStreamableHTTP)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer proxy.Shutdown()
+	if err := proxy.connectToServer(); err != nil {
+		t.Fatalf("connectToServer failed: %v", err)
+	}
+
+	// Senders read the transport while reconnects replace it; run with
+	// -race to catch unguarded reads.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = proxy.Send(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+				_ = proxy.SessionID()
+			}
+		}()
+	}
+	for range 10 {
+		old := proxy.currentTransport()
+		if err := proxy.connectToServer(); err != nil {
+			t.Errorf("reconnect failed: %v", err)
+		}
+		_ = old.Close()
+	}
+	close(stop)
+	wg.Wait()
+}
+
 func TestBuildHTTPClient_NoProxy(t *testing.T) {
-	client, err := buildHTTPClient("")
+	client, err := buildHTTPClient("", nil, httpclient.PoolOptions{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -161,13 +223,20 @@ func TestBuildHTTPClient_NoProxy(t *testing.T) {
 		t.Fatal("Client should not be nil")
 		return
 	}
-	if client.Transport != nil {
-		t.Error("Transport should be nil (default) when no proxy is set")
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected a pooled *http.Transport, got %T", client.Transport)
+	}
+	if transport.Proxy == nil {
+		t.Error("Transport should honor the proxy environment variables when no proxy is set")
+	}
+	if transport.MaxIdleConnsPerHost != httpclient.DefaultMaxIdleConnsPerHost {
+		t.Errorf("Expected the default pool settings, got %d idle connections per host", transport.MaxIdleConnsPerHost)
 	}
 }
 
 func TestBuildHTTPClient_WithProxy(t *testing.T) {
-	client, err := buildHTTPClient("http://proxy.example.com:8080")
+	client, err := buildHTTPClient("http://proxy.example.com:8080", nil, httpclient.PoolOptions{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -181,26 +250,52 @@ func TestBuildHTTPClient_WithProxy(t *testing.T) {
 }
 
 func TestBuildHTTPClient_InvalidProxyURL(t *testing.T) {
-	_, err := buildHTTPClient("://invalid")
+	_, err := buildHTTPClient("://invalid", nil, httpclient.PoolOptions{})
 	if err == nil {
 		t.Error("Expected error for invalid proxy URL")
 	}
 }
 
 func TestBuildHTTPClient_MissingScheme(t *testing.T) {
-	_, err := buildHTTPClient("proxy:8080")
+	_, err := buildHTTPClient("proxy:8080", nil, httpclient.PoolOptions{})
 	if err == nil {
 		t.Error("Expected error for proxy URL without http/https scheme")
 	}
 }
 
+func TestBuildHTTPClient_Socks5(t *testing.T) {
+	client, err := buildHTTPClient("socks5://proxy.example.com:1080", nil, httpclient.PoolOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.Transport == nil {
+		t.Fatal("Transport should not be nil when proxy is set")
+	}
+}
+
 func TestBuildHTTPClient_MissingHost(t *testing.T) {
-	_, err := buildHTTPClient("http://")
+	_, err := buildHTTPClient("http://", nil, httpclient.PoolOptions{})
 	if err == nil {
 		t.Error("Expected error for proxy URL without host")
 	}
 }
 
+func TestConfigureCompression(t *testing.T) {
+	client, _ := buildHTTPClient("http://proxy.example.com:8080", nil, httpclient.PoolOptions{})
+	proxied := client.Transport
+	configureCompression(client, true)
+	ct, ok := client.Transport.(*httpclient.CompressionTransport)
+	if !ok || ct.Base != proxied {
+		t.Fatalf("Expected the proxy transport wrapped for compression, got %T", client.Transport)
+	}
+
+	client, _ = buildHTTPClient("", nil, httpclient.PoolOptions{})
+	configureCompression(client, false)
+	if ht, ok := client.Transport.(*http.Transport); !ok || !ht.DisableCompression {
+		t.Errorf("Expected a transport with compression disabled, got %T", client.Transport)
+	}
+}
+
 func TestNewProxyWithOptions_Proxy(t *testing.T) {
 	p, err := NewProxyWithOptions("https://example.com", 3334, map[string]string{}, "test-hash", TransportModeAuto, "http://proxy:8080")
 	if err != nil {
@@ -215,3 +310,245 @@ func TestNewProxyWithOptions_Proxy(t *testing.T) {
 		t.Fatal("HTTP transport should not be nil when proxy is configured")
 	}
 }
+
+func TestNewProxyWithOptions_Headers(t *testing.T) {
+	t.Setenv("PROXY_TEST_KEY", "expanded")
+	headers := map[string]string{"X-Key": "${PROXY_TEST_KEY}", "X-Tenant": "acme"}
+
+	p, err := NewProxyWithOptions("https://example.com", 3334, headers, "test-hash", TransportModeAuto, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p.cancel()
+	if got := p.headers()["X-Key"]; got != "${PROXY_TEST_KEY}" {
+		t.Errorf("Expected headers unexpanded without templates, got %q", got)
+	}
+
+	token := "one"
+	p, err = NewProxyWithOptions("https://example.com", 3334, headers, "test-hash", TransportModeAuto, "",
+		WithHeaderTemplates(0),
+		WithHeaderProvider(func() map[string]string { return map[string]string{"X-Tenant": "override", "X-Token": token} }))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer p.cancel()
+	token = "two"
+	got := p.headers()
+	if got["X-Key"] != "expanded" || got["X-Tenant"] != "override" || got["X-Token"] != "two" {
+		t.Errorf("Expected expanded headers with the provider's on top, got %v", got)
+	}
+}
+
+// recordingTracer collects traced messages for inspection.
+type recordingTracer struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (r *recordingTracer) Trace(direction, server string, message []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, direction+" "+strings.TrimSpace(string(message)))
+}
+
+func (r *recordingTracer) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.entries...)
+}
+
+func TestProxyTracesMessages(t *testing.T) {
+	server := newMockMCPServer(t)
+	defer server.Close()
+
+	tracer := &recordingTracer{}
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "trace-test", TransportModeStreamableHTTP, "", WithTracer(tracer))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	var stdout safeBuffer
+	p.SetStdio(bufio.NewReader(stdinReader), bufio.NewWriter(&stdout))
+	go func() { _ = p.Start() }()
+	defer func() {
+		_ = stdinWriter.Close()
+		p.Shutdown()
+	}()
+
+	writeJSON(t, stdinWriter, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "ping"})
+	if resp := readJSONResponse(t, &stdout, 2*time.Second); resp == nil {
+		t.Fatal("Expected ping response")
+	}
+
+	entries := tracer.snapshot()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 trace entries, got %d: %v", len(entries), entries)
+	}
+	if !strings.HasPrefix(entries[0], TraceLocalToRemote+" ") || !strings.Contains(entries[0], `"method":"ping"`) {
+		t.Errorf("Unexpected outbound entry: %s", entries[0])
+	}
+	if !strings.HasPrefix(entries[1], TraceRemoteToLocal+" ") || !strings.Contains(entries[1], `"result"`) {
+		t.Errorf("Unexpected inbound entry: %s", entries[1])
+	}
+}
+
+func TestProxyStaticToken(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", configDir)
+
+	var gotAuth []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		gotAuth = append(gotAuth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer static-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":{}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "static-token-test", TransportModeStreamableHTTP, "", WithStaticToken("static-token"))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if p.authCoord != nil {
+		t.Error("Expected no auth coordinator with a static token")
+	}
+	if entries, _ := os.ReadDir(configDir); len(entries) != 0 {
+		t.Errorf("Expected nothing written to the config directory, got %d entries", len(entries))
+	}
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, h := range gotAuth {
+		if h != "Bearer static-token" {
+			t.Errorf("Request %d: expected static bearer token, got %q", i, h)
+		}
+	}
+}
+
+func TestProxyStaticTokenRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "static-token-rejected", TransportModeAuto, "", WithStaticToken("bad-token"))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	err = p.Connect()
+	if err == nil || !strings.Contains(err.Error(), "bearer token") {
+		t.Errorf("Expected bearer token rejection, got %v", err)
+	}
+}
+
+func TestProxyReauthenticate(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	p, err := NewProxyWithOptions("https://example.com/mcp", 0, map[string]string{}, "reauth-test", TransportModeStreamableHTTP, "")
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	// Another request already replaced the rejected token.
+	if err := p.authCoord.SaveTokens(&auth.Tokens{AccessToken: "new"}); err != nil {
+		t.Fatalf("Failed to save tokens: %v", err)
+	}
+	if err := p.reauthenticate(t.Context(), "old", &UnauthorizedError{StatusCode: http.StatusUnauthorized}); err != nil {
+		t.Errorf("Expected the newer token to be used, got %v", err)
+	}
+
+	static, err := NewProxyWithOptions("https://example.com/mcp", 0, map[string]string{}, "reauth-static", TransportModeStreamableHTTP, "", WithStaticToken("token"))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer static.Shutdown()
+	if err := static.reauthenticate(t.Context(), "token", &UnauthorizedError{StatusCode: http.StatusUnauthorized}); err == nil {
+		t.Error("Expected an error for a rejected static token")
+	}
+}
+
+func TestProxyIgnoresTokensGrantedOtherScopes(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	p, err := NewProxyWithOptions("https://example.com/mcp", 0, map[string]string{}, "scope-test", TransportModeStreamableHTTP, "",
+		WithScopes([]string{"read", "write"}))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if err := p.authCoord.SaveTokens(&auth.Tokens{AccessToken: "narrow", Scope: "read"}); err != nil {
+		t.Fatalf("Failed to save tokens: %v", err)
+	}
+	if got := p.getAuthToken(); got != "" {
+		t.Errorf("Expected tokens granted other scopes to be ignored, got %q", got)
+	}
+
+	if err := p.authCoord.SaveTokens(&auth.Tokens{AccessToken: "granted", Scope: "write read"}); err != nil {
+		t.Fatalf("Failed to save tokens: %v", err)
+	}
+	if got := p.getAuthToken(); got != "granted" {
+		t.Errorf("Expected token 'granted', got %q", got)
+	}
+}
+
+func TestProxyTLSConfig(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	received := make(chan []byte, 1)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "tls-test", TransportModeStreamableHTTP, "",
+		WithTLSConfig(&tls.Config{RootCAs: roots}),
+		WithMessageHandler(func(data []byte) { received <- data }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Error("Expected a response from the TLS server")
+	}
+}
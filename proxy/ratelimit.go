@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+)
+
+// rateLimiter holds back messages while the server is rate limiting the
+// client. With queue set, a rate-limited message waits for the server's
+// Retry-After and is sent again; otherwise it fails.
+type rateLimiter struct {
+	gate  httpclient.Gate
+	queue bool
+}
+
+// send calls post, first waiting out or failing on an earlier rate limit.
+func (l *rateLimiter) send(ctx context.Context, post func() error) error {
+	for {
+		if d := l.gate.Remaining(); d > 0 {
+			if !l.queue {
+				return &httpclient.RateLimitError{StatusCode: http.StatusTooManyRequests, RetryAfter: d}
+			}
+			slog.Info("rate limited by server, holding message", "retry_after", d)
+			if err := l.gate.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := post()
+		var limited *httpclient.RateLimitError
+		if !errors.As(err, &limited) {
+			return err
+		}
+		l.gate.Close(limited.RetryAfter)
+		if !l.queue {
+			return err
+		}
+	}
+}
+
+// retryDelay returns delay, or the wait the server asked for in err when
+// that is longer.
+func retryDelay(delay time.Duration, err error) time.Duration {
+	var limited *httpclient.RateLimitError
+	if errors.As(err, &limited) && limited.RetryAfter > delay {
+		return limited.RetryAfter
+	}
+	return delay
+}
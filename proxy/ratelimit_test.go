@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+)
+
+// newRateLimitedServer answers the first POST with 429 and Retry-After: 1,
+// and later ones with an empty JSON response.
+func newRateLimitedServer(t *testing.T, posts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if posts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamableRateLimitFailsSend(t *testing.T) {
+	var posts atomic.Int32
+	server := newRateLimitedServer(t, &posts)
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: server.URL, Client: server.Client()})
+
+	message := []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	var limited *httpclient.RateLimitError
+	if err := transport.Send(context.Background(), message); !errors.As(err, &limited) || limited.RetryAfter != time.Second {
+		t.Fatalf("Expected a rate limit error with Retry-After 1s, got %v", err)
+	}
+
+	// Until Retry-After has passed, sends fail without reaching the server.
+	if err := transport.Send(context.Background(), message); !errors.As(err, &limited) {
+		t.Errorf("Expected the second send to be held back, got %v", err)
+	}
+	if n := posts.Load(); n != 1 {
+		t.Errorf("Expected 1 POST, got %d", n)
+	}
+}
+
+func TestStreamableRateLimitQueuesSend(t *testing.T) {
+	var posts atomic.Int32
+	server := newRateLimitedServer(t, &posts)
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:             server.URL,
+		Client:               server.Client(),
+		QueueWhenRateLimited: true,
+	})
+
+	start := time.Now()
+	if err := transport.Send(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Expected the queued send to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the send to wait for Retry-After, took %v", elapsed)
+	}
+	if n := posts.Load(); n != 2 {
+		t.Errorf("Expected the message sent again, got %d POSTs", n)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	limited := &httpclient.RateLimitError{StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Second}
+	if d := retryDelay(time.Second, limited); d != 10*time.Second {
+		t.Errorf("Expected Retry-After to lengthen the delay, got %v", d)
+	}
+	if d := retryDelay(time.Minute, limited); d != time.Minute {
+		t.Errorf("Expected a longer backoff delay to be kept, got %v", d)
+	}
+	if d := retryDelay(time.Second, errors.New("boom")); d != time.Second {
+		t.Errorf("Expected other errors to keep the delay, got %v", d)
+	}
+}
+
+func TestProxyAnswersUnsentRequest(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+	p.trackRequests(TraceLocalToRemote, []byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call"}`))
+
+	p.failSend([]byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call"}`), &httpclient.RateLimitError{StatusCode: 429, RetryAfter: time.Second})
+	if !strings.Contains(out.String(), `"id":4`) || !strings.Contains(out.String(), "429") {
+		t.Errorf("Expected an error response for request 4, got %s", out.String())
+	}
+	if n := p.inflight.count(); n != 0 {
+		t.Errorf("Expected the request no longer tracked, %d left", n)
+	}
+
+	out.Reset()
+	p.failSend([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`), errors.New("boom"))
+	if out.Len() != 0 {
+		t.Errorf("Expected notifications not to be answered, got %s", out.String())
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// reinitIDPrefix starts the request ID of the initialize request the
+	// proxy replays on a new session, so its response is not passed to the
+	// client, which already has one.
+	reinitIDPrefix = "mcp-remote-go-reinitialize-"
+
+	// reinitTimeout bounds the wait for the result of a replayed
+	// initialize.
+	reinitTimeout = 30 * time.Second
+)
+
+// recordHandshake remembers the client's initialize request and whether it
+// sent notifications/initialized, so the handshake can be replayed when the
+// server expires the session. It reports whether message is an initialize
+// request.
+func (t *StreamableHTTPTransport) recordHandshake(message []byte) bool {
+	if !bytes.Contains(message, []byte("initialize")) {
+		return false
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case msg.isRequest() && msg.Method == "initialize":
+		t.initParams = msg.Params
+		t.initialized = false
+		return true
+	case msg.isNotification() && msg.Method == "notifications/initialized":
+		t.initialized = true
+	}
+	return false
+}
+
+// reinitialize starts a new session after the server expired the previous
+// one, by replaying the client's initialize handshake (MCP 2025-11-25,
+// Session Management). It does nothing when another request already
+// started the new session, or when the client has not initialized yet.
+func (t *StreamableHTTPTransport) reinitialize(ctx context.Context) error {
+	t.reinitMu.Lock()
+	defer t.reinitMu.Unlock()
+
+	t.mu.Lock()
+	params, initialized := t.initParams, t.initialized
+	started := t.sessionID != ""
+	t.reinits++
+	id := fmt.Sprintf("%s%d", reinitIDPrefix, t.reinits)
+	t.mu.Unlock()
+	if started || params == nil {
+		return nil
+	}
+
+	result := make(chan *rpcMessage, 1)
+	t.mu.Lock()
+	t.reinitID, t.reinitResult = id, result
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.reinitID, t.reinitResult = "", nil
+		t.mu.Unlock()
+	}()
+
+	idJSON, _ := json.Marshal(id)
+	request, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: idJSON, Method: "initialize", Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode initialize: %w", err)
+	}
+	slog.Info("replaying initialize on a new session")
+	if err := t.send(ctx, request); err != nil {
+		return fmt.Errorf("failed to replay initialize: %w", err)
+	}
+
+	timer := time.NewTimer(reinitTimeout)
+	defer timer.Stop()
+	var resp *rpcMessage
+	select {
+	case resp = <-result:
+	case <-timer.C:
+		return errors.New("timed out waiting for the replayed initialize result")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("server rejected the replayed initialize: %s", resp.Error.Message)
+	}
+
+	if initialized {
+		notification, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/initialized"})
+		if err := t.send(ctx, notification); err != nil {
+			return fmt.Errorf("failed to send initialized notification: %w", err)
+		}
+	}
+	slog.Info("started a new session", "session_id", t.SessionID())
+	return nil
+}
+
+// reinitResponse passes the server's answer to a replayed initialize to
+// reinitialize and reports whether data was that answer.
+func (t *StreamableHTTPTransport) reinitResponse(data []byte) bool {
+	if !bytes.Contains(data, []byte(reinitIDPrefix)) {
+		return false
+	}
+	var msg rpcMessage
+	if json.Unmarshal(data, &msg) != nil || !msg.isResponse() {
+		return false
+	}
+	var id string
+	if json.Unmarshal(msg.ID, &id) != nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id != t.reinitID {
+		// The answer to a replay that was given up on.
+		return strings.HasPrefix(id, reinitIDPrefix)
+	}
+	t.reinitResult <- &msg
+	t.reinitID, t.reinitResult = "", nil
+	return true
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStreamableHTTPTransportReinitializesExpiredSession(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	sessions := 0
+	live := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get(HeaderMCPSessionID)+" "+msg.Method)
+		if msg.Method == "initialize" {
+			sessions++
+			live = fmt.Sprintf("session-%d", sessions)
+			w.Header().Set(HeaderMCPSessionID, live)
+		} else if r.Header.Get(HeaderMCPSessionID) != live {
+			http.NotFound(w, r)
+			return
+		}
+		if msg.isNotification() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-11-25"}}`, msg.ID)
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: server.URL, Client: &http.Client{}})
+	var forwarded []string
+	transport.SetOnMessage(func(event string, data []byte) {
+		forwarded = append(forwarded, string(data))
+	})
+
+	for _, message := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+	} {
+		if err := transport.Send(t.Context(), []byte(message)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	// The server expires the session.
+	mu.Lock()
+	live = "expired"
+	mu.Unlock()
+
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send after expiry failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		" initialize",
+		"session-1 notifications/initialized",
+		"session-1 tools/list",
+		" initialize",
+		"session-2 notifications/initialized",
+		"session-2 tools/list",
+	}
+	if strings.Join(received, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the handshake replayed on a new session:\n got %q\nwant %q", received, want)
+	}
+	if len(forwarded) != 2 || !strings.Contains(forwarded[0], `"id":1`) || !strings.Contains(forwarded[1], `"id":2`) {
+		t.Errorf("Expected only the client's responses forwarded, got %q", forwarded)
+	}
+	if transport.SessionID() != "session-2" {
+		t.Errorf("Expected session 'session-2', got %q", transport.SessionID())
+	}
+}
+
+func TestStreamableHTTPTransportReinitializeWithoutHandshake(t *testing.T) {
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: "http://127.0.0.1:1", Client: &http.Client{}})
+	// Nothing to replay: the message is retried on its own.
+	if err := transport.reinitialize(t.Context()); err != nil {
+		t.Errorf("Expected no replay without a recorded initialize, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// RecordedMessage is one message of a recording, in the direction it
+// travelled: TraceLocalToRemote or TraceRemoteToLocal.
+type RecordedMessage struct {
+	Direction string
+	Message   json.RawMessage
+}
+
+// replayExchange is a recorded request and the server's response to it.
+type replayExchange struct {
+	method   string
+	params   string // canonical JSON, so key order does not matter
+	response *rpcMessage
+	used     bool
+}
+
+// replayTransport answers requests with the responses recorded for the
+// same requests, without contacting a server. A request is matched to an
+// unused recorded request with the same method and params, then to one
+// with the same method only, then to a used one with the same method and
+// params. Server notifications and requests are not replayed.
+type replayTransport struct {
+	mu        sync.Mutex
+	exchanges []*replayExchange
+	onMessage func(event string, data []byte)
+}
+
+func newReplayTransport(recording []RecordedMessage) *replayTransport {
+	t := &replayTransport{}
+	pending := make(map[string]*replayExchange)
+	for _, rec := range recording {
+		var msg rpcMessage
+		if json.Unmarshal(rec.Message, &msg) != nil {
+			continue
+		}
+		switch {
+		case rec.Direction == TraceLocalToRemote && msg.isRequest():
+			exchange := &replayExchange{method: msg.Method, params: canonicalJSON(msg.Params)}
+			pending[string(msg.ID)] = exchange
+			t.exchanges = append(t.exchanges, exchange)
+		case rec.Direction == TraceRemoteToLocal && msg.isResponse():
+			if exchange := pending[string(msg.ID)]; exchange != nil {
+				exchange.response = &msg
+				delete(pending, string(msg.ID))
+			}
+		}
+	}
+	return t
+}
+
+func (t *replayTransport) Connect(ctx context.Context) error {
+	slog.Info("replaying recorded responses", "requests", len(t.exchanges))
+	return nil
+}
+
+func (t *replayTransport) Send(ctx context.Context, message []byte) error {
+	elements, batch := splitBatch(message)
+	if !batch {
+		elements = [][]byte{message}
+	}
+	var replies [][]byte
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) != nil || !msg.isRequest() {
+			continue
+		}
+		replies = append(replies, t.answer(&msg))
+	}
+	if len(replies) == 0 || t.onMessage == nil {
+		return nil
+	}
+	if batch {
+		t.onMessage("message", joinBatch(replies))
+		return nil
+	}
+	t.onMessage("message", replies[0])
+	return nil
+}
+
+// answer returns the recorded response to req, carrying req's ID.
+func (t *replayTransport) answer(req *rpcMessage) []byte {
+	params := canonicalJSON(req.Params)
+	t.mu.Lock()
+	exchange := t.match(func(e *replayExchange) bool { return !e.used && e.method == req.Method && e.params == params })
+	if exchange == nil {
+		exchange = t.match(func(e *replayExchange) bool { return !e.used && e.method == req.Method })
+	}
+	if exchange == nil {
+		exchange = t.match(func(e *replayExchange) bool { return e.method == req.Method && e.params == params })
+	}
+	if exchange != nil {
+		exchange.used = true
+	}
+	t.mu.Unlock()
+
+	if exchange == nil {
+		slog.Warn("no recorded response", "method", req.Method)
+		return newErrorMessage(req.ID, jsonRPCInternalError, "no recorded response for "+req.Method)
+	}
+	resp := *exchange.response
+	resp.ID = req.ID
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return newErrorMessage(req.ID, jsonRPCInternalError, "invalid recorded response: "+err.Error())
+	}
+	return data
+}
+
+// match returns the first answered exchange accepted by ok. t.mu must be
+// held.
+func (t *replayTransport) match(ok func(*replayExchange) bool) *replayExchange {
+	for _, e := range t.exchanges {
+		if e.response != nil && ok(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// acceptsBatch reports true: batches are answered element by element.
+func (t *replayTransport) acceptsBatch() bool {
+	return true
+}
+
+func (t *replayTransport) SetOnMessage(handler func(event string, data []byte)) {
+	t.onMessage = handler
+}
+
+func (t *replayTransport) SetOnError(handler func(err error)) {}
+
+func (t *replayTransport) Close() error { return nil }
+
+func (t *replayTransport) SessionID() string { return "" }
+
+// canonicalJSON re-encodes data with sorted object keys.
+func canonicalJSON(data json.RawMessage) string {
+	var v any
+	if len(data) == 0 || json.Unmarshal(data, &v) != nil {
+		return string(data)
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
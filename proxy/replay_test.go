@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReplayTransport(t *testing.T) {
+	recording := []RecordedMessage{
+		{TraceLocalToRemote, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)},
+		{TraceRemoteToLocal, json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":{"serverInfo":{"name":"recorded"}}}`)},
+		{TraceLocalToRemote, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)},
+		{TraceLocalToRemote, json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"a","arguments":{"x":1,"y":2}}}`)},
+		{TraceLocalToRemote, json.RawMessage(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"b"}}`)},
+		{TraceRemoteToLocal, json.RawMessage(`{"jsonrpc":"2.0","id":3,"result":{"content":"b"}}`)},
+		{TraceRemoteToLocal, json.RawMessage(`{"jsonrpc":"2.0","id":2,"result":{"content":"a"}}`)},
+	}
+	transport := newReplayTransport(recording)
+	var received []string
+	transport.SetOnMessage(func(_ string, data []byte) { received = append(received, string(data)) })
+
+	send := func(message string) string {
+		t.Helper()
+		received = nil
+		if err := transport.Send(context.Background(), []byte(message)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if len(received) != 1 {
+			t.Fatalf("Expected one reply to %s, got %v", message, received)
+		}
+		return received[0]
+	}
+
+	if got := send(`{"jsonrpc":"2.0","id":"init","method":"initialize","params":{}}`); !strings.Contains(got, `"id":"init"`) || !strings.Contains(got, "recorded") {
+		t.Errorf("Expected the recorded initialize result with the client's ID, got %s", got)
+	}
+	if got := send(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"arguments":{"y":2,"x":1},"name":"a"}}`); !strings.Contains(got, `"content":"a"`) {
+		t.Errorf("Expected the result recorded for the same params, got %s", got)
+	}
+	if got := send(`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"c"}}`); !strings.Contains(got, `"content":"b"`) {
+		t.Errorf("Expected the unused result for the same method, got %s", got)
+	}
+	if got := send(`{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"b"}}`); !strings.Contains(got, `"content":"b"`) {
+		t.Errorf("Expected a used result to be replayed again, got %s", got)
+	}
+	if got := send(`{"jsonrpc":"2.0","id":10,"method":"resources/list"}`); !strings.Contains(got, "no recorded response for resources/list") {
+		t.Errorf("Expected an error for an unrecorded method, got %s", got)
+	}
+	if got := send(`[{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":11,"method":"initialize","params":{}}]`); !strings.HasPrefix(got, "[") || !strings.Contains(got, `"id":11`) {
+		t.Errorf("Expected a batch reply, got %s", got)
+	}
+
+	received = nil
+	if err := transport.Send(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil || len(received) != 0 {
+		t.Errorf("Expected notifications to go unanswered, got %v, %v", received, err)
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// listChangedCapabilities maps the server capabilities that may declare
+// listChanged to the notification announcing a change.
+var listChangedCapabilities = map[string]string{
+	"tools":     "notifications/tools/list_changed",
+	"resources": "notifications/resources/list_changed",
+	"prompts":   "notifications/prompts/list_changed",
+}
+
+// resyncState remembers which list_changed notifications the server
+// declared in its initialize result, so the proxy may send them itself
+// after reconnecting.
+type resyncState struct {
+	mu            sync.Mutex
+	notifications []string
+}
+
+// recordInitializeResult notes the list_changed notifications the server
+// declared in result.
+func (r *resyncState) recordInitializeResult(result json.RawMessage) {
+	var init struct {
+		Capabilities map[string]struct {
+			ListChanged bool `json:"listChanged"`
+		} `json:"capabilities"`
+	}
+	if json.Unmarshal(result, &init) != nil {
+		return
+	}
+	var notifications []string
+	for _, capability := range []string{"tools", "resources", "prompts"} {
+		if init.Capabilities[capability].ListChanged {
+			notifications = append(notifications, listChangedCapabilities[capability])
+		}
+	}
+	r.mu.Lock()
+	r.notifications = notifications
+	r.mu.Unlock()
+}
+
+// listChangedNotifications returns the list_changed notifications to send
+// after reconnecting.
+func (r *resyncState) listChangedNotifications() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.notifications
+}
+
+// resyncAfterReconnect tells the client to refresh what it learned from the
+// server before the connection dropped, as the server's tools, resources
+// or prompts may have changed meanwhile, e.g. when it was redeployed. A
+// list_changed notification is sent for each list the server declared it
+// announces changes of, and cached listings are dropped.
+func (p *Proxy) resyncAfterReconnect() {
+	if p.listCache != nil {
+		p.listCache.clear()
+	}
+	for _, method := range p.resync.listChangedNotifications() {
+		slog.Debug("notifying client after reconnect", "method", method)
+		data, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: method})
+		p.deliver(data)
+	}
+}
+
+// recordInitializeResponse notes the capabilities in the server's response
+// to an initialize request.
+func (p *Proxy) recordInitializeResponse(message []byte) {
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) == nil && msg.Error == nil && msg.Result != nil {
+		p.resync.recordInitializeResult(msg.Result)
+	}
+}
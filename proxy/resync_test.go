@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResyncAfterReconnect(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+	p.listCache = newListCache(time.Minute)
+
+	p.trackRequests(TraceLocalToRemote, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	p.trackRequests(TraceRemoteToLocal, []byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{"listChanged":true},"resources":{"subscribe":true},"prompts":{"listChanged":true}}}}`))
+
+	list := &rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "tools/list"}
+	p.listCache.track(list)
+	p.listCache.observe(&rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("2"), Result: json.RawMessage(`{"tools":[]}`)})
+
+	p.resyncAfterReconnect()
+	_ = p.stdioWriter.Flush()
+
+	want := `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}` + "\n"
+	if out.String() != want {
+		t.Errorf("Expected list_changed for the declared lists, got %s", out.String())
+	}
+	if _, ok := p.listCache.lookup(list); ok {
+		t.Error("Expected the cached listing dropped")
+	}
+}
+
+func TestResyncWithoutListChanged(t *testing.T) {
+	p, out := newBatchTestProxy(&batchTestTransport{})
+
+	// Before the handshake there is nothing to refresh.
+	p.resyncAfterReconnect()
+
+	p.trackRequests(TraceLocalToRemote, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	p.trackRequests(TraceRemoteToLocal, []byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}}}}`))
+	p.resyncAfterReconnect()
+	_ = p.stdioWriter.Flush()
+
+	if strings.TrimSpace(out.String()) != "" {
+		t.Errorf("Expected no notifications for a server not announcing changes, got %s", out.String())
+	}
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+// revokeTimeout bounds how long shutdown waits for token revocation.
+const revokeTimeout = 10 * time.Second
+
+// revokeTokens revokes the OAuth tokens and deletes them when the proxy was
+// started with WithRevokeOnExit. The tokens are deleted even if the server
+// cannot revoke them, so they are not reused.
+func (p *Proxy) revokeTokens() {
+	if !p.revokeOnExit || p.authCoord == nil {
+		return
+	}
+	p.revokeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+		defer cancel()
+		switch err := p.authCoord.RevokeTokens(ctx); {
+		case err == nil:
+			slog.Info("revoked tokens", "server", p.serverLabel())
+		case errors.Is(err, auth.ErrRevocationUnsupported):
+			slog.Info("server does not support token revocation; deleting tokens locally", "server", p.serverLabel())
+		default:
+			slog.Warn("failed to revoke tokens", "server", p.serverLabel(), "error", err)
+		}
+		if err := p.authCoord.DeleteTokens(); err != nil {
+			slog.Warn("failed to delete tokens", "error", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+func TestRevokeTokensOnExit(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+	var revoked safeBuffer
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = revoked.Write([]byte(r.FormValue("token_type_hint") + " "))
+	}))
+	defer server.Close()
+
+	coord, err := auth.NewCoordinator("revoke-on-exit", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coord.SaveTokens(&auth.Tokens{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	metadata, _ := json.Marshal(auth.ServerMetadata{TokenEndpoint: server.URL + "/token", RevocationEndpoint: server.URL + "/revoke"})
+	if err := os.WriteFile(filepath.Join(auth.ServerDir("revoke-on-exit"), "server_metadata.json"), metadata, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.NewFileTokenStore().Save("revoke-on-exit", "client_info.json", []byte(`{"client_id":"client"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Proxy{serverURL: "https://example.com/mcp", authCoord: coord}
+	p.revokeTokens()
+	if revoked.String() != "" {
+		t.Fatalf("Expected no revocation without WithRevokeOnExit, got %q", revoked.String())
+	}
+
+	p.revokeOnExit = true
+	p.revokeTokens()
+	p.revokeTokens()
+	if got := revoked.String(); got != "refresh_token access_token " {
+		t.Errorf("Expected both tokens revoked once, got %q", got)
+	}
+	if _, err := coord.LoadTokens(); err == nil {
+		t.Error("Expected the tokens to be deleted")
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// DefaultSendBuffer is how many messages are held by default while the
+// proxy reconnects to the server.
+const DefaultSendBuffer = 100
+
+// errSendBufferFull is returned for a message that arrives while
+// reconnecting and finds the send buffer full.
+var errSendBufferFull = errors.New("send buffer is full while reconnecting to the server")
+
+// sendQueue buffers messages for the server while the proxy reconnects, so
+// they are sent in order once the connection is back instead of failing.
+type sendQueue struct {
+	mu       sync.Mutex
+	size     int
+	holding  bool
+	messages [][]byte
+}
+
+// hold starts buffering messages.
+func (q *sendQueue) hold() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.holding = q.size > 0
+}
+
+// enqueue buffers message if the queue is holding. ok is false when the
+// message should be sent right away.
+func (q *sendQueue) enqueue(message []byte) (ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.holding {
+		return false, nil
+	}
+	if len(q.messages) >= q.size {
+		return true, errSendBufferFull
+	}
+	q.messages = append(q.messages, message)
+	return true, nil
+}
+
+// next returns the oldest buffered message. Once the queue is empty it
+// stops holding and returns nil, so later messages are sent directly and
+// cannot overtake buffered ones.
+func (q *sendQueue) next() []byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.messages) == 0 {
+		q.holding = false
+		return nil
+	}
+	message := q.messages[0]
+	q.messages[0] = nil
+	q.messages = q.messages[1:]
+	return message
+}
+
+// release stops holding and returns the buffered messages.
+func (q *sendQueue) release() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	messages := q.messages
+	q.messages = nil
+	q.holding = false
+	return messages
+}
+
+// flushSendQueue sends the messages buffered while reconnecting, in order.
+// A request that still cannot be sent is answered with an error.
+func (p *Proxy) flushSendQueue() {
+	for message := p.sendQueue.next(); message != nil; message = p.sendQueue.next() {
+		if err := p.sendTransport(p.ctx, p.currentTransport(), message); err != nil {
+			slog.Error("failed to send buffered message to server", "error", err)
+			p.stats.recordError(err)
+			p.failSend(message, err)
+		}
+	}
+}
+
+// failSendQueue answers the requests buffered while reconnecting once
+// reconnection failed.
+func (p *Proxy) failSendQueue(err error) {
+	for _, message := range p.sendQueue.release() {
+		p.failSend(message, err)
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// flakyTransport fails sends while down is set.
+type flakyTransport struct {
+	batchTestTransport
+	down bool
+}
+
+func (t *flakyTransport) Send(ctx context.Context, message []byte) error {
+	t.mu.Lock()
+	down := t.down
+	t.mu.Unlock()
+	if down {
+		return errors.New("connection reset")
+	}
+	return t.batchTestTransport.Send(ctx, message)
+}
+
+func TestSendQueue(t *testing.T) {
+	q := sendQueue{size: 2}
+	if queued, _ := q.enqueue([]byte("a")); queued {
+		t.Fatal("Expected messages to be sent directly while not holding")
+	}
+
+	q.hold()
+	for _, m := range []string{"a", "b"} {
+		if queued, err := q.enqueue([]byte(m)); !queued || err != nil {
+			t.Fatalf("Expected %s buffered, got %v, %v", m, queued, err)
+		}
+	}
+	if _, err := q.enqueue([]byte("c")); !errors.Is(err, errSendBufferFull) {
+		t.Errorf("Expected errSendBufferFull, got %v", err)
+	}
+
+	var got []string
+	for m := q.next(); m != nil; m = q.next() {
+		got = append(got, string(m))
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("Expected a,b in order, got %q", got)
+	}
+	if queued, _ := q.enqueue([]byte("d")); queued {
+		t.Error("Expected the queue to stop holding once drained")
+	}
+
+	disabled := sendQueue{}
+	disabled.hold()
+	if queued, _ := disabled.enqueue([]byte("a")); queued {
+		t.Error("Expected a zero-size queue not to buffer")
+	}
+}
+
+func TestProxyBuffersWhileReconnecting(t *testing.T) {
+	transport := &flakyTransport{down: true}
+	p, out := newBatchTestProxy(transport)
+	p.sendQueue = sendQueue{size: 10}
+
+	// The send fails, then the transport error starts reconnecting.
+	p.sendQueue.hold()
+	messages := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`,
+		`{"jsonrpc":"2.0","method":"notifications/progress"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+	}
+	for _, m := range messages {
+		if err := p.sendToServer([]byte(m)); err != nil {
+			t.Fatalf("Expected %s buffered, got %v", m, err)
+		}
+	}
+	if len(transport.sent) != 0 {
+		t.Fatalf("Expected nothing sent while reconnecting, got %q", transport.sent)
+	}
+
+	transport.mu.Lock()
+	transport.down = false
+	transport.mu.Unlock()
+	p.flushSendQueue()
+
+	if strings.Join(transport.sent, "\n") != strings.Join(messages, "\n") {
+		t.Errorf("Expected the messages flushed in order, got %q", transport.sent)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no errors delivered, got %s", out.String())
+	}
+
+	// Once flushed, messages are sent directly again.
+	if err := p.sendToServer([]byte(`{"jsonrpc":"2.0","id":3,"method":"ping"}`)); err != nil || len(transport.sent) != 4 {
+		t.Errorf("Expected a direct send, got %v (%d sent)", err, len(transport.sent))
+	}
+}
+
+func TestProxyFailsBufferedRequests(t *testing.T) {
+	p, out := newBatchTestProxy(&flakyTransport{down: true})
+	p.sendQueue = sendQueue{size: 10}
+	p.sendQueue.hold()
+
+	var wg sync.WaitGroup
+	for _, m := range []string{`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`, `{"jsonrpc":"2.0","method":"notifications/progress"}`} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = p.sendToServer([]byte(m))
+		}()
+	}
+	wg.Wait()
+
+	p.failSendQueue(errors.New("reconnection failed"))
+	if got := out.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, `"id":1`) || !strings.Contains(got, "reconnection failed") {
+		t.Errorf("Expected one error for the buffered request, got %s", got)
+	}
+	if n := p.inflight.count(); n != 0 {
+		t.Errorf("Expected no requests left in flight, got %d", n)
+	}
+}
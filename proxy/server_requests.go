@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// serverRequestsReceived records the requests the server sent in data, such
+// as sampling/createMessage, elicitation/create or roots/list, with the
+// session they arrived on, so the client's responses can be matched to them.
+// Once maxInflightRequests go unanswered, the recorded ones are forgotten, so
+// a client that never answers cannot grow the set without limit; responses
+// to them are then posted as to any request the proxy did not see.
+func (t *StreamableHTTPTransport) serverRequestsReceived(data []byte) {
+	ids := requestIDs(data)
+	if len(ids) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.serverRequests == nil || len(t.serverRequests) >= maxInflightRequests {
+		if len(t.serverRequests) > 0 {
+			slog.Warn("too many unanswered server requests, forgetting them", "requests", len(t.serverRequests))
+		}
+		t.serverRequests = make(map[string]string)
+	}
+	for _, id := range ids {
+		t.serverRequests[string(id)] = t.sessionID
+	}
+}
+
+// answerServerRequests matches the client's responses in message to the
+// server requests they answer. It returns message without the responses to
+// requests from a session that has since ended, or nil when nothing is left
+// to post: such a request ended with its session, and the new session would
+// reject the response. Responses to requests the proxy did not see are kept.
+// answers reports whether what is left only answers server requests.
+func (t *StreamableHTTPTransport) answerServerRequests(message []byte) (out []byte, answers bool) {
+	elements, batch := splitBatch(message)
+	if !batch {
+		elements = [][]byte{message}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.serverRequests) == 0 {
+		return message, false
+	}
+	kept := elements[:0:0]
+	answers = true
+	for _, element := range elements {
+		var msg rpcMessage
+		session, known := "", false
+		if json.Unmarshal(element, &msg) == nil && msg.isResponse() {
+			session, known = t.serverRequests[string(msg.ID)]
+		}
+		if !known {
+			answers = false
+			kept = append(kept, element)
+			continue
+		}
+		delete(t.serverRequests, string(msg.ID))
+		if session != t.sessionID {
+			slog.Warn("dropping response to a request from an ended session", "id", string(msg.ID), "session_id", session)
+			continue
+		}
+		kept = append(kept, element)
+	}
+	switch {
+	case len(kept) == len(elements):
+		return message, answers
+	case len(kept) == 0:
+		return nil, answers
+	case batch:
+		return joinBatch(kept), answers
+	default:
+		return kept[0], answers
+	}
+}
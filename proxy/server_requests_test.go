@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestStreamableHTTPTransportAnswersServerRequests(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	sessions := 0
+	live := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get(HeaderMCPSessionID)+" "+msg.Method+string(msg.ID))
+		if msg.Method == "initialize" {
+			sessions++
+			live = fmt.Sprintf("session-%d", sessions)
+			w.Header().Set(HeaderMCPSessionID, live)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-11-25"}}`, msg.ID)
+			return
+		}
+		if r.Header.Get(HeaderMCPSessionID) != live {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: server.URL, Client: &http.Client{}})
+	transport.SetOnMessage(func(event string, data []byte) {})
+	send := func(message string) {
+		t.Helper()
+		if err := transport.Send(t.Context(), []byte(message)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{}}}`)
+
+	// A request from the server on the notification stream is answered on
+	// its session.
+	transport.dispatch("message", []byte(`{"jsonrpc":"2.0","id":"s-1","method":"roots/list"}`))
+	send(`{"jsonrpc":"2.0","id":"s-1","result":{"roots":[]}}`)
+
+	// A request from a session that has since ended is not answered on
+	// the new one.
+	transport.dispatch("message", []byte(`{"jsonrpc":"2.0","id":"s-2","method":"sampling/createMessage"}`))
+	transport.expireSession()
+	send(`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{}}}`)
+	send(`{"jsonrpc":"2.0","id":"s-2","result":{"content":{"type":"text","text":"hi"}}}`)
+
+	// A response whose session expires in flight is not replayed.
+	transport.dispatch("message", []byte(`{"jsonrpc":"2.0","id":"s-3","method":"elicitation/create"}`))
+	mu.Lock()
+	live = "expired"
+	mu.Unlock()
+	send(`{"jsonrpc":"2.0","id":"s-3","result":{"action":"decline"}}`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		" initialize1",
+		`session-1 "s-1"`,
+		" initialize2",
+		`session-2 "s-3"`,
+	}
+	if !slices.Equal(received, want) {
+		t.Errorf("Expected requests %q, got %q", want, received)
+	}
+}
+
+func TestAnswerServerRequestsKeepsUnknownResponses(t *testing.T) {
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: "http://example.com"})
+	transport.setSessionID("old")
+	transport.serverRequestsReceived([]byte(`{"jsonrpc":"2.0","id":7,"method":"roots/list"}`))
+	transport.setSessionID("new")
+
+	batch := []byte(`[{"jsonrpc":"2.0","id":7,"result":{}},{"jsonrpc":"2.0","id":8,"result":{}}]`)
+	if got, answers := transport.answerServerRequests(batch); string(got) != `[{"jsonrpc":"2.0","id":8,"result":{}}]` || answers {
+		t.Errorf("Expected only the stale response removed, got %s (answers %v)", got, answers)
+	}
+	unknown := []byte(`{"jsonrpc":"2.0","id":9,"result":{}}`)
+	if got, _ := transport.answerServerRequests(unknown); string(got) != string(unknown) {
+		t.Errorf("Expected an unknown response kept, got %s", got)
+	}
+}
+
+func TestServerRequestsBounded(t *testing.T) {
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: "http://example.com"})
+	transport.setSessionID("s")
+	for i := range maxInflightRequests + 10 {
+		transport.serverRequestsReceived(fmt.Appendf(nil, `{"jsonrpc":"2.0","id":%d,"method":"roots/list"}`, i))
+	}
+	transport.mu.Lock()
+	n := len(transport.serverRequests)
+	transport.mu.Unlock()
+	if n > maxInflightRequests {
+		t.Errorf("Expected at most %d tracked server requests, got %d", maxInflightRequests, n)
+	}
+
+	// The latest request is still matched to its response.
+	latest := fmt.Appendf(nil, `{"jsonrpc":"2.0","id":%d,"result":{}}`, maxInflightRequests+9)
+	if _, answers := transport.answerServerRequests(latest); !answers {
+		t.Error("Expected the latest server request to be recognised")
+	}
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// serverLogLevels maps the MCP logging levels (RFC 5424 severities) to slog
+// levels.
+var serverLogLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError,
+	"alert":     slog.LevelError,
+	"emergency": slog.LevelError,
+}
+
+// serverLog writes a notifications/message from the server (MCP logging) to
+// the proxy's log at the matching level, labelled with the server it came
+// from. It reports whether data should be passed to the client: always for
+// other messages, and for log messages only with WithForwardServerLogs.
+func (p *Proxy) serverLog(data []byte) bool {
+	var msg rpcMessage
+	if json.Unmarshal(data, &msg) != nil || !msg.isNotification() || msg.Method != "notifications/message" {
+		return true
+	}
+	var params struct {
+		Level  string          `json:"level"`
+		Logger string          `json:"logger"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		slog.Warn("invalid log message from server", "server", p.serverLabel())
+		return p.forwardServerLogs
+	}
+	level, ok := serverLogLevels[params.Level]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	attrs := []any{"server", p.serverLabel()}
+	if params.Logger != "" {
+		attrs = append(attrs, "logger", params.Logger)
+	}
+	var value any
+	if json.Unmarshal(params.Data, &value) == nil {
+		attrs = append(attrs, "data", value)
+	}
+	slog.Log(context.Background(), level, "server log", attrs...)
+	return p.forwardServerLogs
+}
+
+// serverLabel names the server in log records: its name when aggregated,
+// otherwise its URL.
+func (p *Proxy) serverLabel() string {
+	if p.name != "" {
+		return p.name
+	}
+	return p.serverURL
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestServerLogMessages(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	p, out := newBatchTestProxy(&batchTestTransport{})
+	p.serverURL = "https://example.com/mcp"
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"warning","logger":"db","data":{"error":"connection lost"}}}`))
+	p.stdioWriter.Flush()
+	if out.Len() != 0 {
+		t.Errorf("Expected the log message not forwarded by default, got %s", out.String())
+	}
+	record := logs.String()
+	for _, want := range []string{"level=WARN", `msg="server log"`, "server=https://example.com/mcp", "logger=db", "connection lost"} {
+		if !strings.Contains(record, want) {
+			t.Errorf("Expected %q in the log record, got %s", want, record)
+		}
+	}
+
+	logs.Reset()
+	p.name = "github"
+	p.forwardServerLogs = true
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"critical","data":"disk full"}}`))
+	p.stdioWriter.Flush()
+	if !strings.Contains(out.String(), "disk full") {
+		t.Errorf("Expected the log message forwarded, got %q", out.String())
+	}
+	if record := logs.String(); !strings.Contains(record, "level=ERROR") || !strings.Contains(record, "server=github") {
+		t.Errorf("Expected an error record naming the server, got %s", record)
+	}
+
+	out.Reset()
+	p.forwardServerLogs = false
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":1,"progress":1}}`))
+	p.stdioWriter.Flush()
+	if !strings.Contains(out.String(), "notifications/progress") {
+		t.Errorf("Expected other notifications forwarded, got %q", out.String())
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+
+	"github.com/naotama2002/mcp-remote-go/auth"
+)
+
+// sessionStateFile is the per-server document holding the Streamable HTTP
+// session, so that a restarted proxy can resume it.
+const sessionStateFile = "session.json"
+
+// sessionState is the persisted form of a Streamable HTTP session.
+type sessionState struct {
+	Endpoint    string `json:"endpoint"`
+	SessionID   string `json:"session_id"`
+	LastEventID string `json:"last_event_id,omitempty"`
+}
+
+// sessionStore persists sessionState in the per-server config directory.
+type sessionStore struct {
+	store     auth.TokenStore
+	serverKey string
+}
+
+func newSessionStore(serverKey string) *sessionStore {
+	return &sessionStore{store: auth.NewFileTokenStore(), serverKey: serverKey}
+}
+
+// load returns the saved session for endpoint, or nil if there is none.
+func (s *sessionStore) load(endpoint string) *sessionState {
+	data, err := s.store.Load(s.serverKey, sessionStateFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to load session state", "error", err)
+		}
+		return nil
+	}
+
+	var state sessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		slog.Warn("ignoring malformed session state", "error", err)
+		return nil
+	}
+	if state.Endpoint != endpoint || state.SessionID == "" {
+		return nil
+	}
+	return &state
+}
+
+func (s *sessionStore) save(state sessionState) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		slog.Warn("failed to encode session state", "error", err)
+		return
+	}
+	if err := s.store.Save(s.serverKey, sessionStateFile, data); err != nil {
+		slog.Warn("failed to save session state", "error", err)
+	}
+}
+
+func (s *sessionStore) clear() {
+	if err := s.store.Delete(s.serverKey, sessionStateFile); err != nil {
+		slog.Warn("failed to delete session state", "error", err)
+	}
+}
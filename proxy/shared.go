@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// ConnectShared connects to the shared proxy listening at path, a Unix
+// socket or, on Windows, a named pipe. If none is running it starts
+// listening there instead, so the caller becomes the shared proxy. Exactly
+// one of conn and listener is non-nil.
+func ConnectShared(path string) (conn net.Conn, listener net.Listener, err error) {
+	return connectShared(path)
+}
+
+// AttachStdio relays stdio to the shared proxy on conn until either side
+// closes. Options set the stdio framing and maximum message size.
+func AttachStdio(conn net.Conn, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	framing, err := newStdioFraming(o.stdioFraming, o.maxMessageSize)
+	if err != nil {
+		return err
+	}
+	connFraming, _ := newStdioFraming(FramingNewline, o.maxMessageSize)
+	defer func() { _ = conn.Close() }()
+
+	slog.Info("attached to shared MCP proxy", "socket", conn.RemoteAddr().String())
+	done := make(chan error, 2)
+	go func() {
+		done <- relay(bufio.NewReader(os.Stdin), framing, bufio.NewWriter(conn), connFraming)
+	}()
+	go func() {
+		done <- relay(bufio.NewReader(conn), connFraming, bufio.NewWriter(os.Stdout), framing)
+	}()
+	return <-done
+}
+
+// relay copies messages from r to w, converting between their framings.
+func relay(r *bufio.Reader, in *stdioFraming, w *bufio.Writer, out *stdioFraming) error {
+	for {
+		message, err := in.readMessage(r)
+		if len(message) > 0 {
+			if err := out.writeMessage(w, message); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if err == io.EOF || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				slog.Error("dropping message", "error", err)
+				continue
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// connectShared dials the Unix socket at path, or listens on it when
+// nothing answers there.
+func connectShared(path string) (conn net.Conn, listener net.Listener, err error) {
+	if conn, err = net.Dial("unix", path); err == nil {
+		return conn, nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	// Another instance may be starting at the same time; only one may take
+	// over the socket.
+	err = filelock.New(path).WithLock(filelock.DefaultTimeout, func() error {
+		if conn, err = net.Dial("unix", path); err == nil {
+			return nil
+		}
+		// Nothing answers on the socket, so it was left by a proxy that
+		// exited without cleaning up.
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+		listener, err = net.Listen("unix", path)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, listener, nil
+}
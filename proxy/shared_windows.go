@@ -0,0 +1,44 @@
+//go:build windows
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// connectShared dials the named pipe at path, or listens on it when nothing
+// answers there. Pipes disappear with the process that created them, so
+// there is nothing stale to clean up, and only one instance can create a
+// pipe, so no lock is needed either.
+func connectShared(path string) (conn net.Conn, listener net.Listener, err error) {
+	if conn, err = winio.DialPipe(path, nil); err == nil {
+		return conn, nil, nil
+	}
+	sd, err := pipeSecurityDescriptor()
+	if err != nil {
+		return nil, nil, err
+	}
+	listener, err = winio.ListenPipe(path, &winio.PipeConfig{SecurityDescriptor: sd})
+	if err == nil {
+		return nil, listener, nil
+	}
+	// Another instance created the pipe first; attach to it instead.
+	if conn, dialErr := winio.DialPipe(path, nil); dialErr == nil {
+		return conn, nil, nil
+	}
+	return nil, nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+}
+
+// pipeSecurityDescriptor returns an SDDL descriptor granting access to the
+// current user only, as whoever connects uses their credentials.
+func pipeSecurityDescriptor() (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	return "D:P(A;;GA;;;" + user.User.Sid.String() + ")", nil
+}
@@ -5,7 +5,11 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // SSEEvent represents a single Server-Sent Event
@@ -13,48 +17,21 @@ type SSEEvent struct {
 	Event string
 	Data  []byte
 	ID    string
+	// Retry is the reconnection delay the server asked for, if any.
+	Retry time.Duration
 }
 
 // ReadSSEEvents reads SSE events from a reader and calls the handler for each complete event.
-// It returns when the reader is exhausted or ctx is cancelled.
+// It returns when the reader is exhausted or ctx is cancelled, or with
+// ErrMessageTooLarge for an event over DefaultMaxEventSize.
 func ReadSSEEvents(ctx context.Context, reader io.Reader, handler func(SSEEvent)) error {
-	scanner := bufio.NewReader(reader)
-
-	var event string
-	var data bytes.Buffer
-	var id string
-
-	parseLine := func(line []byte) {
-		line = bytes.TrimSpace(line)
-
-		if len(line) == 0 {
-			// Empty line marks the end of an event
-			if data.Len() > 0 {
-				handler(SSEEvent{Event: event, Data: bytes.Clone(data.Bytes()), ID: id})
-				event = ""
-				data.Reset()
-				id = ""
-			}
-			return
-		}
+	return readSSEEvents(ctx, reader, DefaultMaxEventSize, handler)
+}
 
-		// Skip comments
-		if bytes.HasPrefix(line, []byte(":")) {
-			return
-		}
-
-		// Parse field
-		if bytes.HasPrefix(line, []byte("event:")) {
-			event = string(bytes.TrimSpace(line[6:]))
-		} else if bytes.HasPrefix(line, []byte("data:")) {
-			if data.Len() > 0 {
-				data.WriteByte('\n')
-			}
-			data.Write(bytes.TrimSpace(line[5:]))
-		} else if bytes.HasPrefix(line, []byte("id:")) {
-			id = string(bytes.TrimSpace(line[3:]))
-		}
-	}
+// readSSEEvents is ReadSSEEvents with events limited to maxSize bytes.
+func readSSEEvents(ctx context.Context, reader io.Reader, maxSize int, handler func(SSEEvent)) error {
+	d := newSSEDecoder(reader, maxSize)
+	defer d.close()
 
 	for {
 		select {
@@ -63,15 +40,12 @@ func ReadSSEEvents(ctx context.Context, reader io.Reader, handler func(SSEEvent)
 		default:
 		}
 
-		line, err := scanner.ReadBytes('\n')
-		if len(line) > 0 {
-			parseLine(line)
+		evt, err := d.next()
+		// Dispatch any pending event, also before returning
+		if len(evt.Data) > 0 {
+			handler(evt)
 		}
 		if err != nil {
-			// Dispatch any pending event before returning
-			if data.Len() > 0 {
-				handler(SSEEvent{Event: event, Data: bytes.Clone(data.Bytes()), ID: id})
-			}
 			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
 				return nil
 			}
@@ -79,3 +53,169 @@ func ReadSSEEvents(ctx context.Context, reader io.Reader, handler func(SSEEvent)
 		}
 	}
 }
+
+const (
+	// sseReaderSize is the read buffer of an SSE stream. Lines longer than
+	// that are read in pieces, without collecting them first.
+	sseReaderSize = 64 << 10
+	// maxPooledSSEBuffer is the largest data buffer kept for reuse, so one
+	// huge event does not hold on to its memory for good.
+	maxPooledSSEBuffer = 16 << 20
+)
+
+var (
+	sseReaderPool = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, sseReaderSize) }}
+	sseBufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+)
+
+// sseDecoder splits an SSE stream into events. Data lines are copied from
+// the read buffer straight into a pooled buffer, piece by piece for long
+// lines, so a multi-megabyte payload is copied once there and once more
+// into the event handed out. The buffers are returned to their pools by
+// close. An event or line over maxSize bytes fails the stream with
+// ErrMessageTooLarge, so a rogue server cannot make it buffer without
+// bound. CR line endings and byte order marks are accepted, and event data
+// is sanitized by sanitizeSSEData.
+type sseDecoder struct {
+	r       *bufio.Reader
+	cr      crReader
+	maxSize int
+	data    *bytes.Buffer
+	// field collects a line other than data that spans the read buffer.
+	field []byte
+	// onLine, when set, is called for every line read, including comments.
+	onLine func()
+}
+
+// newSSEDecoder returns a decoder of events up to maxSize bytes; zero
+// means DefaultMaxEventSize.
+func newSSEDecoder(r io.Reader, maxSize int) *sseDecoder {
+	d := &sseDecoder{
+		r:       sseReaderPool.Get().(*bufio.Reader),
+		cr:      crReader{r: r},
+		maxSize: orDefault(maxSize, DefaultMaxEventSize),
+		data:    sseBufferPool.Get().(*bytes.Buffer),
+	}
+	d.r.Reset(&d.cr)
+	return d
+}
+
+// tooLarge returns the error for an event or line over the limit.
+func (d *sseDecoder) tooLarge() error {
+	return fmt.Errorf("%w: SSE event over %d bytes", ErrMessageTooLarge, d.maxSize)
+}
+
+// close returns the decoder's buffers to their pools.
+func (d *sseDecoder) close() {
+	d.r.Reset(nil)
+	sseReaderPool.Put(d.r)
+	if d.data.Cap() <= maxPooledSSEBuffer {
+		d.data.Reset()
+		sseBufferPool.Put(d.data)
+	}
+	d.r, d.data = nil, nil
+}
+
+// next reads up to the blank line ending the next event and returns it.
+// Events without data are returned too, as they may carry an ID or retry
+// delay. When the stream ends, the pending event is returned with the
+// error, unless it was too large.
+func (d *sseDecoder) next() (SSEEvent, error) {
+	var evt SSEEvent
+	d.data.Reset()
+	hasData := false
+	for {
+		blank, err := d.readLine(&evt, &hasData)
+		if errors.Is(err, ErrMessageTooLarge) {
+			return SSEEvent{}, err
+		}
+		if blank || err != nil {
+			if d.data.Len() > 0 {
+				evt.Data = sanitizeSSEData(bytes.Clone(d.data.Bytes()))
+			}
+			return evt, err
+		}
+	}
+}
+
+// readLine reads one line and applies its field to evt. It reports whether
+// the line was blank, ending the event.
+func (d *sseDecoder) readLine(evt *SSEEvent, hasData *bool) (blank bool, err error) {
+	chunk, err := d.r.ReadSlice('\n')
+	if d.onLine != nil && (len(chunk) > 0 || err == nil) {
+		d.onLine()
+	}
+	// Leading whitespace is ignored, as is a line of whitespace only.
+	chunk = bytes.TrimLeft(chunk, " \t\r\n")
+	for len(chunk) == 0 && errors.Is(err, bufio.ErrBufferFull) {
+		chunk, err = d.r.ReadSlice('\n')
+		chunk = bytes.TrimLeft(chunk, " \t\r\n")
+	}
+	// A byte order mark, usually at the start of the stream, is ignored.
+	chunk = bytes.TrimPrefix(chunk, utf8BOM)
+	if len(chunk) == 0 {
+		return err == nil, err
+	}
+
+	switch {
+	case chunk[0] == ':':
+		// Comment
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = d.r.ReadSlice('\n')
+		}
+		return false, err
+	case bytes.HasPrefix(chunk, []byte("data:")):
+		if *hasData {
+			d.data.WriteByte('\n')
+		}
+		*hasData = true
+		start := d.data.Len()
+		d.data.Write(bytes.TrimLeft(chunk[5:], " \t"))
+		for errors.Is(err, bufio.ErrBufferFull) {
+			if d.data.Len() > d.maxSize {
+				return false, d.tooLarge()
+			}
+			chunk, err = d.r.ReadSlice('\n')
+			d.data.Write(chunk)
+		}
+		if d.data.Len() > d.maxSize {
+			return false, d.tooLarge()
+		}
+		b := d.data.Bytes()
+		end := len(b)
+		for end > start && isSSESpace(b[end-1]) {
+			end--
+		}
+		d.data.Truncate(end)
+		return false, err
+	}
+
+	line := chunk
+	if errors.Is(err, bufio.ErrBufferFull) {
+		d.field = append(d.field[:0], chunk...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			if len(d.field) > d.maxSize {
+				return false, d.tooLarge()
+			}
+			chunk, err = d.r.ReadSlice('\n')
+			d.field = append(d.field, chunk...)
+		}
+		line = d.field
+	}
+	line = bytes.TrimSpace(line)
+
+	if bytes.HasPrefix(line, []byte("event:")) {
+		evt.Event = string(bytes.TrimSpace(line[6:]))
+	} else if bytes.HasPrefix(line, []byte("id:")) {
+		evt.ID = string(bytes.TrimSpace(line[3:]))
+	} else if bytes.HasPrefix(line, []byte("retry:")) {
+		if ms, err := strconv.Atoi(string(bytes.TrimSpace(line[6:]))); err == nil && ms > 0 {
+			evt.Retry = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return false, err
+}
+
+func isSSESpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadSSEEvents(t *testing.T) {
@@ -110,3 +113,60 @@ func TestReadSSEEventsContextCancellation(t *testing.T) {
 		t.Errorf("Expected context.Canceled, got %v", err)
 	}
 }
+
+func TestReadSSEEventsLargePayload(t *testing.T) {
+	payload := `{"jsonrpc":"2.0","id":1,"result":{"contents":[{"text":"` + strings.Repeat("x", 3*sseReaderSize+17) + `"}]}}`
+	longID := strings.Repeat("7", sseReaderSize+1)
+	input := "id: " + longID + "\nretry: 1500\ndata:   " + payload + "  \r\n\ndata: second\n\n"
+
+	var received []SSEEvent
+	err := ReadSSEEvents(t.Context(), strings.NewReader(input), func(evt SSEEvent) {
+		received = append(received, evt)
+	})
+	if err != nil {
+		t.Fatalf("ReadSSEEvents returned error: %v", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(received))
+	}
+	if !bytes.Equal(received[0].Data, []byte(payload)) {
+		t.Errorf("Expected the payload intact, got %d bytes", len(received[0].Data))
+	}
+	if received[0].ID != longID || received[0].Retry != 1500*time.Millisecond {
+		t.Errorf("Expected the long ID and retry, got %d bytes and %v", len(received[0].ID), received[0].Retry)
+	}
+	// Events must not share the decoder's reused buffer.
+	if string(received[1].Data) != "second" || received[0].Data[0] != '{' {
+		t.Errorf("Expected independent event data, got %q", received[1].Data)
+	}
+}
+
+func TestReadSSEEventsTooLarge(t *testing.T) {
+	for name, input := range map[string]string{
+		"data":       "data: small\n\ndata: " + strings.Repeat("x", 3*sseReaderSize) + "\n\n",
+		"data lines": "data: small\n\n" + strings.Repeat("data: xxxxxxxx\n", sseReaderSize) + "\n",
+		"field":      "data: small\n\nid: " + strings.Repeat("7", 3*sseReaderSize) + "\ndata: x\n\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var received []string
+			err := readSSEEvents(t.Context(), strings.NewReader(input), sseReaderSize, func(evt SSEEvent) {
+				received = append(received, string(evt.Data))
+			})
+			if !errors.Is(err, ErrMessageTooLarge) {
+				t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+			}
+			if len(received) != 1 || received[0] != "small" {
+				t.Errorf("Expected only the small event, got %d events", len(received))
+			}
+		})
+	}
+}
+
+func BenchmarkReadSSEEventsLargePayload(b *testing.B) {
+	input := "data: " + strings.Repeat("x", 4<<20) + "\n\n"
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ReadSSEEvents(context.Background(), strings.NewReader(input), func(SSEEvent) {})
+	}
+}
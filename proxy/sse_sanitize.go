@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// utf8BOM is the byte order mark some servers put at the start of the
+// stream or of an event's data.
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// crReader turns the CR and CRLF line endings SSE allows into LF, so lines
+// can be split on LF alone. A CR inside a data line ends the line too, as
+// the SSE specification has it, instead of reaching the client.
+type crReader struct {
+	r io.Reader
+	// skipLF is set after a CR, whose LF, if it follows, is dropped.
+	skipLF bool
+}
+
+func (c *crReader) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		out := 0
+		for _, b := range p[:n] {
+			switch {
+			case b == '\r':
+				p[out] = '\n'
+				out++
+				c.skipLF = true
+			case b == '\n' && c.skipLF:
+				c.skipLF = false
+			default:
+				p[out] = b
+				out++
+				c.skipLF = false
+			}
+		}
+		// Reading on after a lone dropped LF saves the caller an empty read.
+		if out > 0 || err != nil || n == 0 {
+			return out, err
+		}
+	}
+}
+
+// sanitizeSSEData makes event data safe to forward as one line of
+// newline-delimited JSON: it drops a leading BOM, replaces invalid UTF-8
+// with U+FFFD, strips control characters other than tab and newline, and
+// compacts JSON spread over several data lines onto one. data is modified
+// in place; the result may share its memory.
+func sanitizeSSEData(data []byte) []byte {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	}
+
+	out := 0
+	multiline := false
+	for _, b := range data {
+		if isStrippedControl(b) {
+			continue
+		}
+		if b == '\n' {
+			multiline = true
+		}
+		data[out] = b
+		out++
+	}
+	data = data[:out]
+
+	if multiline && json.Valid(data) {
+		var compact bytes.Buffer
+		if json.Compact(&compact, data) == nil {
+			return compact.Bytes()
+		}
+	}
+	return data
+}
+
+// isStrippedControl reports whether b is a control character dropped from
+// event data. Tab and newline are kept as JSON whitespace.
+func isStrippedControl(b byte) bool {
+	return (b < 0x20 && b != '\t' && b != '\n') || b == 0x7F
+}
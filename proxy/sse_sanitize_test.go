@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSanitizeSSEData(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"clean", `{"jsonrpc":"2.0","id":1}`, `{"jsonrpc":"2.0","id":1}`},
+		{"bom", "\xEF\xBB\xBF{\"id\":1}", `{"id":1}`},
+		{"invalid utf-8", "{\"text\":\"a\xffb\"}", "{\"text\":\"a\uFFFDb\"}"},
+		{"control characters", "{\"text\":\"a\x00b\x1bc\x7f\"}", `{"text":"abc"}`},
+		{"multi-line json", "{\n  \"id\": 1,\n\t\"ok\": true\n}", `{"id":1,"ok":true}`},
+		{"multi-line text", "line one\nline two", "line one\nline two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(sanitizeSSEData([]byte(tt.input))); got != tt.want {
+				t.Errorf("sanitizeSSEData(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEEventsLineEndings(t *testing.T) {
+	input := "\xEF\xBB\xBFevent: message\rdata: {\"id\":1}\r\rid: 7\r\ndata: {\"id\":\r\ndata: 2}\r\n\r\n"
+	// One byte at a time, so a CRLF is split across reads.
+	var received []SSEEvent
+	err := ReadSSEEvents(t.Context(), iotest.OneByteReader(strings.NewReader(input)), func(evt SSEEvent) {
+		received = append(received, evt)
+	})
+	if err != nil {
+		t.Fatalf("ReadSSEEvents returned error: %v", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", received)
+	}
+	if received[0].Event != "message" || string(received[0].Data) != `{"id":1}` {
+		t.Errorf("Expected the first event despite the BOM and CR endings, got %+v", received[0])
+	}
+	if received[1].ID != "7" || string(received[1].Data) != `{"id":2}` {
+		t.Errorf("Expected the second event on one line, got %q", received[1].Data)
+	}
+}
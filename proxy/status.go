@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is a snapshot of a running proxy, reported on the status endpoint.
+type Status struct {
+	// Name is the upstream name in aggregation mode.
+	Name             string     `json:"name,omitempty"`
+	Server           string     `json:"server"`
+	Connected        bool       `json:"connected"`
+	Transport        string     `json:"transport,omitempty"`
+	SessionID        string     `json:"session_id,omitempty"`
+	TokenExpiresAt   *time.Time `json:"token_expires_at,omitempty"`
+	MessagesSent     uint64     `json:"messages_sent"`
+	MessagesReceived uint64     `json:"messages_received"`
+	LastError        string     `json:"last_error,omitempty"`
+	LastErrorAt      *time.Time `json:"last_error_at,omitempty"`
+	// ProgressNotifications counts the notifications/progress received;
+	// UnmatchedProgress those whose token no outstanding request carries.
+	// ActiveProgressTokens is the number of outstanding requests that
+	// asked for progress.
+	ProgressNotifications uint64 `json:"progress_notifications"`
+	UnmatchedProgress     uint64 `json:"unmatched_progress,omitempty"`
+	ActiveProgressTokens  int    `json:"active_progress_tokens"`
+	// Breaker is the state of the circuit breaker, when one is configured.
+	Breaker string `json:"breaker,omitempty"`
+	// Latency summarizes the answered requests by method, slowest first.
+	Latency []MethodLatency `json:"latency,omitempty"`
+	// Negotiation is how the transport was chosen, when it was negotiated.
+	Negotiation []NegotiationStep `json:"negotiation,omitempty"`
+}
+
+// proxyStats counts messages and remembers the most recent error and
+// transport negotiation.
+type proxyStats struct {
+	sent     atomic.Uint64
+	received atomic.Uint64
+
+	progress          atomic.Uint64
+	unmatchedProgress atomic.Uint64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	negotiation []NegotiationStep
+}
+
+func (s *proxyStats) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+func (s *proxyStats) recordNegotiation(trace []NegotiationStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.negotiation = trace
+}
+
+// Status returns the current state of the proxy.
+func (p *Proxy) Status() Status {
+	st := Status{
+		Server:           p.serverURL,
+		MessagesSent:     p.stats.sent.Load(),
+		MessagesReceived: p.stats.received.Load(),
+
+		ProgressNotifications: p.stats.progress.Load(),
+		UnmatchedProgress:     p.stats.unmatchedProgress.Load(),
+		ActiveProgressTokens:  p.inflight.progressTokens(),
+
+		Latency: p.latency.summary(),
+	}
+
+	if t := p.currentTransport(); t != nil && p.ctx.Err() == nil {
+		st.Connected = true
+		st.Transport = string(p.currentTransportMode())
+		st.SessionID = t.SessionID()
+	}
+
+	if p.authCoord != nil {
+		if tokens, err := p.authCoord.LoadTokens(); err == nil && tokens.ExpiresAt > 0 {
+			expiresAt := time.Unix(tokens.ExpiresAt, 0).UTC()
+			st.TokenExpiresAt = &expiresAt
+		}
+	}
+
+	if p.breaker != nil {
+		st.Breaker = p.breaker.State().String()
+	}
+
+	p.stats.mu.Lock()
+	if p.stats.lastError != "" {
+		st.LastError = p.stats.lastError
+		lastErrorAt := p.stats.lastErrorAt.UTC()
+		st.LastErrorAt = &lastErrorAt
+	}
+	st.Negotiation = p.stats.negotiation
+	p.stats.mu.Unlock()
+
+	return st
+}
+
+// Status returns the state of every upstream.
+func (a *Aggregator) Status() []Status {
+	statuses := make([]Status, 0, len(a.upstreams))
+	for _, u := range a.upstreams {
+		st := u.proxy.Status()
+		st.Name = u.name
+		a.mu.Lock()
+		st.Connected = st.Connected && u.connected
+		a.mu.Unlock()
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
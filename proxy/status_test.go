@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProxyStatus(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(HeaderMCPSessionID, "status-session")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer server.Close()
+
+	received := make(chan []byte, 1)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "status-test", TransportModeStreamableHTTP, "",
+		WithMessageHandler(func(data []byte) { received <- data }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+
+	if st := p.Status(); st.Connected {
+		t.Error("Expected not connected before Connect")
+	}
+
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a response")
+	}
+
+	st := p.Status()
+	if !st.Connected || st.Transport != string(TransportModeStreamableHTTP) {
+		t.Errorf("Expected connected streamable-http transport, got %+v", st)
+	}
+	if st.SessionID != "status-session" {
+		t.Errorf("Expected session ID 'status-session', got '%s'", st.SessionID)
+	}
+	if st.MessagesSent != 1 || st.MessagesReceived != 1 {
+		t.Errorf("Expected 1 message each way, got sent=%d received=%d", st.MessagesSent, st.MessagesReceived)
+	}
+	if st.LastError != "" {
+		t.Errorf("Expected no error, got %q", st.LastError)
+	}
+
+	server.Close()
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)); err == nil {
+		t.Fatal("Expected Send to fail after the server closed")
+	}
+	if st := p.Status(); st.LastError == "" || st.LastErrorAt == nil {
+		t.Errorf("Expected the send error to be recorded, got %+v", st)
+	}
+}
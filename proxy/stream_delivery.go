@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// errNoStreamDelivery is the error the client receives for a request the
+// server accepted with 202 when the stream its response would come on is
+// not available.
+const errNoStreamDelivery = "the server accepted the request but its response cannot be received: the notification stream is not available"
+
+// awaitStreamResponses records the requests in message, which the server
+// accepted with 202, so their responses are expected on the notification
+// stream. The stream is reopened if it had given up; if the server does not
+// offer one, the requests are answered with an error right away instead of
+// waiting forever.
+func (t *StreamableHTTPTransport) awaitStreamResponses(message []byte) {
+	ids := requestIDs(message)
+	if len(ids) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	if t.streamUnsupported || t.notifyCtx == nil {
+		t.mu.Unlock()
+		slog.Warn("request accepted without a notification stream to answer it", "requests", len(ids))
+		t.answerAwaiting(ids)
+		return
+	}
+	if t.awaiting == nil {
+		t.awaiting = make(map[string]bool)
+	}
+	for _, id := range ids {
+		t.awaiting[string(id)] = true
+	}
+	restart := !t.streamRunning && t.notifyCtx.Err() == nil
+	if restart {
+		t.streamRunning = true
+	}
+	ctx := t.notifyCtx
+	t.mu.Unlock()
+
+	if restart {
+		slog.Info("reopening notification stream for accepted requests", "requests", len(ids))
+		go t.runNotificationStream(ctx)
+	}
+}
+
+// streamResponseReceived forgets the requests answered in data.
+func (t *StreamableHTTPTransport) streamResponseReceived(data []byte) {
+	t.mu.Lock()
+	pending := len(t.awaiting) > 0
+	t.mu.Unlock()
+	if !pending {
+		return
+	}
+	ids := responseIDs(data)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range ids {
+		delete(t.awaiting, string(id))
+	}
+}
+
+// notificationStreamStopped records that the notification stream will not
+// be reopened, and answers the requests still waiting for it with an error.
+// unsupported means the server does not offer the stream at all.
+func (t *StreamableHTTPTransport) notificationStreamStopped(unsupported bool, err error) {
+	t.mu.Lock()
+	t.streamRunning = false
+	if unsupported {
+		t.streamUnsupported = true
+	}
+	ids := make([]json.RawMessage, 0, len(t.awaiting))
+	for id := range t.awaiting {
+		ids = append(ids, json.RawMessage(id))
+	}
+	t.awaiting = nil
+	t.mu.Unlock()
+
+	if len(ids) > 0 {
+		slog.Warn("answering accepted requests with an error", "requests", len(ids), "error", err)
+		t.answerAwaiting(ids)
+	}
+}
+
+// answerAwaiting passes an error response for each of ids to the message
+// handler.
+func (t *StreamableHTTPTransport) answerAwaiting(ids []json.RawMessage) {
+	if t.onMessage == nil {
+		return
+	}
+	for _, id := range ids {
+		t.onMessage("message", newErrorMessage(id, jsonRPCInternalError, errNoStreamDelivery))
+	}
+}
+
+// requestIDs returns the IDs of the requests in data, a message or a batch,
+// leaving out the proxy's own keepalive and initialize requests.
+func requestIDs(data []byte) []json.RawMessage {
+	elements, ok := splitBatch(data)
+	if !ok {
+		elements = [][]byte{data}
+	}
+	var ids []json.RawMessage
+	for _, element := range elements {
+		var msg rpcMessage
+		if json.Unmarshal(element, &msg) != nil || !msg.isRequest() {
+			continue
+		}
+		var own string
+		if json.Unmarshal(msg.ID, &own) == nil && (strings.HasPrefix(own, keepaliveIDPrefix) || strings.HasPrefix(own, reinitIDPrefix)) {
+			continue
+		}
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+)
+
+func TestStreamableHTTPTransportReopensStreamForAcceptedRequest(t *testing.T) {
+	var ready atomic.Bool
+	responses := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if !ready.Load() {
+				http.Error(w, "not yet", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case resp := <-responses:
+				_, _ = fmt.Fprintf(w, "data: %s\n\n", resp)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+			<-r.Context().Done()
+			return
+		}
+		var msg rpcMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		ready.Store(true)
+		responses <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{}}`, msg.ID)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:  server.URL,
+		Client:    &http.Client{},
+		Reconnect: backoff.Policy{Initial: time.Millisecond, MaxAttempts: 1},
+	})
+	received := make(chan string, 1)
+	transport.SetOnMessage(func(event string, data []byte) { received <- string(data) })
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	// Wait for the stream to give up.
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		transport.mu.Lock()
+		running := transport.streamRunning
+		transport.mu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the notification stream to give up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(data, `"id":7`) || !strings.Contains(data, `"result"`) {
+			t.Errorf("Expected the response from the reopened stream, got %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the response to arrive on the reopened stream")
+	}
+}
+
+func TestStreamableHTTPTransportAnswersAcceptedRequestWithoutStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{Endpoint: server.URL, Client: &http.Client{}})
+	received := make(chan string, 2)
+	transport.SetOnMessage(func(event string, data []byte) { received <- string(data) })
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	if err := transport.Send(t.Context(), []byte(`[{"jsonrpc":"2.0","id":"a","method":"tools/list"},{"jsonrpc":"2.0","method":"notifications/progress"}]`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(data, `"id":"a"`) || !strings.Contains(data, "notification stream is not available") {
+			t.Errorf("Expected an error response for the request, got %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the accepted request to be answered with an error")
+	}
+	select {
+	case data := <-received:
+		t.Errorf("Expected no answer for the notification, got %s", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	ids := requestIDs([]byte(`[{"jsonrpc":"2.0","id":1,"method":"a"},{"jsonrpc":"2.0","method":"b"},{"jsonrpc":"2.0","id":"mcp-remote-go-keepalive-3","method":"ping"},{"jsonrpc":"2.0","id":2,"result":{}}]`))
+	if len(ids) != 1 || string(ids[0]) != "1" {
+		t.Errorf("Expected only the client's request ID, got %q", ids)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// throttle is a token bucket limiting the rate of requests sent to the
+// server. It holds up to burst tokens and gains rate tokens per second;
+// each request takes one. With queue set, a request waits for its token;
+// otherwise it fails when the bucket is empty.
+type throttle struct {
+	rate  float64
+	burst float64
+	queue bool
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newThrottle returns a throttle allowing rps requests per second with
+// bursts of burst, or nil when rps is not positive. A burst below one is
+// set to rps rounded up.
+func newThrottle(rps float64, burst int, queue bool) *throttle {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rps))
+	}
+	t := &throttle{rate: rps, burst: float64(burst), queue: queue, now: time.Now}
+	t.tokens = t.burst
+	t.last = t.now()
+	return t
+}
+
+// reserve takes a token and returns how long to wait before using it. When
+// the bucket is empty and the throttle does not queue, no token is taken
+// and ok is false.
+func (t *throttle) reserve() (wait time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.tokens = math.Min(t.burst, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	if t.tokens >= 1 {
+		t.tokens--
+		return 0, true
+	}
+	if !t.queue {
+		return 0, false
+	}
+	// Queued requests borrow from future tokens, so they go out in order
+	// at the configured rate.
+	t.tokens--
+	return time.Duration(-t.tokens / t.rate * float64(time.Second)), true
+}
+
+// wait blocks until a request may be sent, or returns an error if the rate
+// limit is exceeded and the throttle does not queue.
+func (t *throttle) wait(ctx context.Context) error {
+	d, ok := t.reserve()
+	if !ok {
+		return fmt.Errorf("client rate limit of %g requests per second exceeded", t.rate)
+	}
+	if d <= 0 {
+		return nil
+	}
+	slog.Debug("client rate limit reached, holding request", "wait", d)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttleRequest applies the client rate limit to message if it is a
+// request. Notifications and responses are never held back. A request that
+// is not sent is no longer tracked as in flight.
+func (p *Proxy) throttleRequest(ctx context.Context, message []byte) error {
+	if p.throttle == nil {
+		return nil
+	}
+	var msg rpcMessage
+	if json.Unmarshal(message, &msg) != nil || !msg.isRequest() {
+		return nil
+	}
+	if err := p.throttle.wait(ctx); err != nil {
+		p.inflight.remove(string(msg.ID))
+		return err
+	}
+	return nil
+}
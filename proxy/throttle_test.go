@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottleReserve(t *testing.T) {
+	th := newThrottle(2, 2, false)
+	now := time.Now()
+	th.now = func() time.Time { return now }
+	th.last = now
+
+	for i := 0; i < 2; i++ {
+		if _, ok := th.reserve(); !ok {
+			t.Fatalf("Expected request %d within the burst", i+1)
+		}
+	}
+	if _, ok := th.reserve(); ok {
+		t.Error("Expected the empty bucket to reject")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := th.reserve(); !ok {
+		t.Error("Expected a token after half a second at 2 per second")
+	}
+
+	th.queue = true
+	wait, ok := th.reserve()
+	if !ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected a queued request to wait 500ms, got %v, %v", wait, ok)
+	}
+	if wait, _ := th.reserve(); wait != time.Second {
+		t.Errorf("Expected the next queued request to wait 1s, got %v", wait)
+	}
+
+	if newThrottle(0, 5, false) != nil {
+		t.Error("Expected no throttle without a rate")
+	}
+	if th := newThrottle(2.5, 0, false); th.burst != 3 {
+		t.Errorf("Expected the burst to default to the rate rounded up, got %v", th.burst)
+	}
+}
+
+func TestProxyThrottlesRequests(t *testing.T) {
+	transport := &batchTestTransport{}
+	p, out := newBatchTestProxy(transport)
+	p.throttle = newThrottle(1, 1, false)
+
+	if err := p.Send(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := p.Send(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/progress"}`)); err != nil {
+		t.Errorf("Expected notifications not to be limited, got %v", err)
+	}
+	err := p.Send(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if err == nil || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+
+	if err := p.sendToServer([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)); err == nil {
+		t.Fatal("Expected sendToServer to be limited too")
+	} else {
+		p.failSend([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`), err)
+	}
+	_ = p.stdioWriter.Flush()
+	if !strings.Contains(out.String(), `"id":3`) || !strings.Contains(out.String(), "rate limit") {
+		t.Errorf("Expected the client answered with an error, got %q", out.String())
+	}
+	if len(transport.sent) != 2 {
+		t.Errorf("Expected two messages sent, got %q", transport.sent)
+	}
+}
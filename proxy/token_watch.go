@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tokenPollInterval is how often tokens kept outside a file, e.g. in the OS
+// keychain, are checked for a new access token. Each check reads the store,
+// so it is done rarely; the token is refreshed well before it expires.
+const tokenPollInterval = time.Minute
+
+// tokenReloader is implemented by transports holding an event stream that
+// was opened with the bearer token of the time. Requests read the token as
+// they are sent, but a stream keeps the one it was opened with.
+type tokenReloader interface {
+	// reloadAuthToken reopens the stream if the access token has changed
+	// since it was opened.
+	reloadAuthToken()
+}
+
+// watchTokens reopens the transport's event stream whenever the stored
+// access token changes, refreshed by this process or by another one, until
+// the proxy stops. A token file is watched for changes; other stores are
+// polled every tokenPollInterval.
+func (p *Proxy) watchTokens() {
+	if path := p.authCoord.TokenFile(); path != "" {
+		err := p.watchTokenFile(path)
+		if err == nil {
+			return
+		}
+		slog.Debug("cannot watch token file, polling instead", "path", path, "error", err)
+	}
+
+	ticker := time.NewTicker(tokenPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.reloadAuthToken()
+	}
+}
+
+// watchTokenFile reloads the token each time the file at path is written,
+// until the proxy stops. Its directory is watched, as the file is replaced
+// rather than written in place.
+func (p *Proxy) watchTokenFile(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = watcher.Close() }()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(path) && event.Has(fsnotify.Create|fsnotify.Write) {
+				p.reloadAuthToken()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Debug("token file watch error", "error", err)
+		}
+	}
+}
+
+// reloadAuthToken reopens the current transport's event stream if the
+// access token has changed.
+func (p *Proxy) reloadAuthToken() {
+	if r, ok := p.currentTransport().(tokenReloader); ok {
+		r.reloadAuthToken()
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/filelock"
+)
+
+// newStreamAuthServer returns a server whose GET event streams report the
+// Authorization header they were opened with on streams. POST requests are
+// answered with an empty result.
+func newStreamAuthServer(t *testing.T, streams chan<- string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+			return
+		}
+		streams <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "event: endpoint\ndata: /message\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func expectStream(t *testing.T, streams <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-streams:
+		if got != want {
+			t.Errorf("stream opened with Authorization %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for a stream opened with %q", want)
+	}
+}
+
+func TestStreamableHTTPTransportReloadAuthToken(t *testing.T) {
+	streams := make(chan string, 4)
+	server := newStreamAuthServer(t, streams)
+
+	var token atomic.Value
+	token.Store("old")
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint:     server.URL,
+		Client:       server.Client(),
+		GetAuthToken: func() string { return token.Load().(string) },
+	})
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+	expectStream(t, streams, "Bearer old")
+
+	// An unchanged token leaves the stream alone.
+	transport.reloadAuthToken()
+	select {
+	case got := <-streams:
+		t.Fatalf("stream reopened with %q although the token did not change", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	token.Store("new")
+	transport.reloadAuthToken()
+	expectStream(t, streams, "Bearer new")
+}
+
+func TestSSETransportReloadAuthToken(t *testing.T) {
+	streams := make(chan string, 4)
+	server := newStreamAuthServer(t, streams)
+
+	var token atomic.Value
+	token.Store("old")
+	transport := NewSSETransport(SSETransportConfig{
+		ServerURL:    server.URL,
+		Client:       server.Client(),
+		GetAuthToken: func() string { return token.Load().(string) },
+	})
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+	expectStream(t, streams, "Bearer old")
+
+	token.Store("new")
+	transport.reloadAuthToken()
+	expectStream(t, streams, "Bearer new")
+}
+
+// reloadTestTransport reports each reloadAuthToken call on reloads.
+type reloadTestTransport struct {
+	batchTestTransport
+	reloads chan struct{}
+}
+
+func (t *reloadTestTransport) reloadAuthToken() {
+	t.reloads <- struct{}{}
+}
+
+func TestWatchTokenFile(t *testing.T) {
+	transport := &reloadTestTransport{reloads: make(chan struct{}, 16)}
+	p, _ := newBatchTestProxy(transport)
+	ctx, cancel := context.WithCancel(t.Context())
+	p.ctx = ctx
+	path := filepath.Join(t.TempDir(), "server", "tokens.json")
+
+	done := make(chan error, 1)
+	go func() { done <- p.watchTokenFile(path) }()
+
+	// The watch is set up asynchronously, so write until it is noticed.
+	deadline := time.After(2 * time.Second)
+	for noticed := false; !noticed; {
+		if err := filelock.WriteFile(path, []byte(`{"access_token":"new"}`), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		select {
+		case <-transport.reloads:
+			noticed = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Expected a reload after the token file was replaced")
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the watch to end cleanly, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the watch to stop with the proxy")
+	}
+}
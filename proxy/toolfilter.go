@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+)
+
+// toolFilter hides and blocks tools by name. As a middleware it answers
+// tools/call requests for tools that are not permitted with an error instead
+// of forwarding them, and removes those tools from tools/list responses.
+type toolFilter struct {
+	allow []string
+	deny  []string
+
+	// namespace, when set, is the aggregation upstream name. Patterns then
+	// also match "namespace.tool", the name the client sees.
+	namespace string
+
+	mu      sync.Mutex
+	listIDs map[string]struct{} // outstanding tools/list request IDs
+}
+
+// newToolFilter validates the glob patterns and returns a filter, or nil
+// when there is nothing to filter.
+func newToolFilter(allow, deny []string) (*toolFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string(nil), allow...), deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+	}
+	return &toolFilter{allow: allow, deny: deny, listIDs: make(map[string]struct{})}, nil
+}
+
+// allowed reports whether the tool may be listed and called. Deny patterns
+// take precedence; without allow patterns every other tool is permitted.
+func (f *toolFilter) allowed(name string) bool {
+	if f.matches(f.deny, name) {
+		return false
+	}
+	return len(f.allow) == 0 || f.matches(f.allow, name)
+}
+
+func (f *toolFilter) matches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if f.namespace != "" {
+			if ok, _ := path.Match(pattern, f.namespace+"."+name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handle is the filter's Middleware.
+func (f *toolFilter) handle(direction string, message []byte) ([]byte, error) {
+	if direction == TraceRemoteToLocal {
+		return f.filterResponse(message), nil
+	}
+	if err := f.checkRequest(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// checkRequest inspects a message sent to the server and rejects calls to
+// tools that are not permitted.
+func (f *toolFilter) checkRequest(data []byte) error {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil || !msg.isRequest() {
+		return nil
+	}
+
+	switch msg.Method {
+	case "tools/list":
+		f.mu.Lock()
+		f.listIDs[string(msg.ID)] = struct{}{}
+		f.mu.Unlock()
+	case "tools/call":
+		var params struct {
+			Name string `json:"name"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		if !f.allowed(params.Name) {
+			slog.Info("blocked call to filtered tool", "tool", params.Name)
+			return &RPCError{Code: jsonRPCInvalidParams, Message: fmt.Sprintf("Unknown tool: %s", params.Name)}
+		}
+	}
+	return nil
+}
+
+// filterResponse removes filtered tools from tools/list responses and
+// returns the message to deliver to the client.
+func (f *toolFilter) filterResponse(data []byte) []byte {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil || !msg.isResponse() {
+		return data
+	}
+
+	f.mu.Lock()
+	_, isList := f.listIDs[string(msg.ID)]
+	delete(f.listIDs, string(msg.ID))
+	f.mu.Unlock()
+	if !isList || msg.Result == nil {
+		return data
+	}
+
+	// Keep every field of the result other than the tools array unchanged.
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return data
+	}
+	var tools []json.RawMessage
+	if err := json.Unmarshal(result["tools"], &tools); err != nil {
+		return data
+	}
+
+	kept := make([]json.RawMessage, 0, len(tools))
+	for _, tool := range tools {
+		var t struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(tool, &t); err == nil && !f.allowed(t.Name) {
+			continue
+		}
+		kept = append(kept, tool)
+	}
+	if len(kept) == len(tools) {
+		return data
+	}
+
+	result["tools"] = mustMarshal(kept)
+	msg.Result = mustMarshal(result)
+	return mustMarshal(msg)
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestToolFilterAllowed(t *testing.T) {
+	f, err := newToolFilter([]string{"search_*", "get_issue"}, []string{"search_private*"})
+	if err != nil {
+		t.Fatalf("newToolFilter failed: %v", err)
+	}
+
+	tests := map[string]bool{
+		"search_issues":  true,
+		"get_issue":      true,
+		"search_private": false,
+		"delete_repo":    false,
+	}
+	for name, want := range tests {
+		if got := f.allowed(name); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	denyOnly, _ := newToolFilter(nil, []string{"delete_*"})
+	if !denyOnly.allowed("search_issues") || denyOnly.allowed("delete_repo") {
+		t.Error("Expected deny-only filter to permit everything except denied tools")
+	}
+}
+
+func TestToolFilterNamespace(t *testing.T) {
+	f, _ := newToolFilter([]string{"github.*"}, nil)
+	f.namespace = "github"
+	if !f.allowed("search_issues") {
+		t.Error("Expected namespaced pattern to match upstream tool")
+	}
+	f.namespace = "linear"
+	if f.allowed("search_issues") {
+		t.Error("Expected pattern for another upstream not to match")
+	}
+}
+
+func TestNewToolFilter(t *testing.T) {
+	if f, err := newToolFilter(nil, nil); f != nil || err != nil {
+		t.Errorf("Expected no filter without patterns, got %v (err %v)", f, err)
+	}
+	if _, err := newToolFilter([]string{"[bad"}, nil); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestToolFilterMessages(t *testing.T) {
+	f, _ := newToolFilter(nil, []string{"delete_*"})
+
+	err := f.checkRequest([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"delete_repo"}}`))
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != jsonRPCInvalidParams {
+		t.Errorf("Expected invalid params error for denied tools/call, got %v", err)
+	}
+	if err := f.checkRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"search"}}`)); err != nil {
+		t.Errorf("Expected permitted tools/call to be forwarded, got %v", err)
+	}
+
+	if err := f.checkRequest([]byte(`{"jsonrpc":"2.0","id":"list-1","method":"tools/list"}`)); err != nil {
+		t.Errorf("Expected tools/list to be forwarded, got %v", err)
+	}
+	out := f.filterResponse([]byte(`{"jsonrpc":"2.0","id":"list-1","result":{"tools":[{"name":"search"},{"name":"delete_repo"}],"nextCursor":"c2"}}`))
+	var list struct {
+		Result struct {
+			Tools      []struct{ Name string } `json:"tools"`
+			NextCursor string                  `json:"nextCursor"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		t.Fatalf("Invalid filtered response %s: %v", out, err)
+	}
+	if len(list.Result.Tools) != 1 || list.Result.Tools[0].Name != "search" {
+		t.Errorf("Expected only 'search' to remain, got %s", out)
+	}
+	if list.Result.NextCursor != "c2" {
+		t.Errorf("Expected other result fields to be kept, got %s", out)
+	}
+
+	// Responses to other requests are passed through untouched.
+	other := []byte(`{"jsonrpc":"2.0","id":"x","result":{"tools":[{"name":"delete_repo"}]}}`)
+	if got := f.filterResponse(other); string(got) != string(other) {
+		t.Errorf("Expected unrelated response unchanged, got %s", got)
+	}
+}
+
+func TestProxyToolFilter(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"read_file"},{"name":"write_file"}]}}`))
+	}))
+	defer server.Close()
+
+	received := make(chan []byte, 2)
+	p, err := NewProxyWithOptions(server.URL, 0, map[string]string{}, "tool-filter-test", TransportModeStreamableHTTP, "",
+		WithToolFilter([]string{"read_*"}, nil),
+		WithMessageHandler(func(data []byte) { received <- data }))
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer p.Shutdown()
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case data := <-received:
+		if strings.Contains(string(data), "write_file") || !strings.Contains(string(data), "read_file") {
+			t.Errorf("Expected only read_file to be listed, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected tools/list response")
+	}
+
+	if err := p.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"write_file"}}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(string(data), "Unknown tool: write_file") {
+			t.Errorf("Expected rejection of write_file, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected tools/call rejection")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected only tools/list to reach the server, got %d requests", calls.Load())
+	}
+}
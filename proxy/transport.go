@@ -24,3 +24,38 @@ type Transport interface {
 	// SessionID returns the current session ID, if any.
 	SessionID() string
 }
+
+// HeaderProvider returns the custom headers to send with a request. It is
+// called for every request, so the headers may change over time, e.g. as
+// short-lived credentials are rotated. The returned map must not be
+// modified.
+type HeaderProvider func() map[string]string
+
+// StaticHeaders returns a HeaderProvider that always returns headers.
+func StaticHeaders(headers map[string]string) HeaderProvider {
+	return func() map[string]string { return headers }
+}
+
+// headerSource returns get, or when it is nil, a provider of the fixed
+// headers.
+func headerSource(headers map[string]string, get HeaderProvider) HeaderProvider {
+	if get != nil {
+		return get
+	}
+	return StaticHeaders(headers)
+}
+
+// layerHeaders returns a provider of base's headers with those of top set
+// over them.
+func layerHeaders(base, top HeaderProvider) HeaderProvider {
+	return func() map[string]string {
+		headers := make(map[string]string)
+		for k, v := range base() {
+			headers[k] = v
+		}
+		for k, v := range top() {
+			headers[k] = v
+		}
+		return headers
+	}
+}
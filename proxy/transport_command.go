@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// commandStopTimeout is how long Close waits for the server to exit after
+// its stdin is closed before killing it.
+const commandStopTimeout = 5 * time.Second
+
+// CommandTransport runs a local MCP server as a child process and exchanges
+// newline-delimited JSON-RPC messages with it over its stdin and stdout, the
+// MCP stdio transport. The server's stderr is passed through.
+type CommandTransport struct {
+	command []string
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	exited    chan struct{}
+	closed    bool
+	onMessage func(event string, data []byte)
+	onError   func(err error)
+}
+
+// NewCommandTransport returns a transport running command, the program and
+// its arguments.
+func NewCommandTransport(command []string) *CommandTransport {
+	return &CommandTransport{command: command}
+}
+
+// Connect starts the server. The process outlives ctx; Close stops it.
+func (t *CommandTransport) Connect(ctx context.Context) error {
+	if len(t.command) == 0 {
+		return errors.New("no server command given")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(t.command[0], t.command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start server command: %w", err)
+	}
+	slog.Info("started server command", "command", t.command[0], "pid", cmd.Process.Pid)
+
+	exited := make(chan struct{})
+	t.mu.Lock()
+	t.cmd, t.stdin, t.exited = cmd, stdin, exited
+	t.mu.Unlock()
+
+	go t.read(cmd, stdout, exited)
+	return nil
+}
+
+// read passes the server's messages on until it closes stdout, then waits
+// for it to exit and reports the exit unless the transport was closed.
+func (t *CommandTransport) read(cmd *exec.Cmd, stdout io.Reader, exited chan struct{}) {
+	framing, _ := newStdioFraming(FramingNewline, 0)
+	r := bufio.NewReader(stdout)
+	for {
+		message, err := framing.readMessage(r)
+		if len(message) > 0 {
+			t.mu.Lock()
+			onMessage := t.onMessage
+			t.mu.Unlock()
+			if onMessage != nil {
+				onMessage("message", message)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, ErrMessageTooLarge) {
+				slog.Error("dropping message from server command", "error", err)
+				continue
+			}
+			break
+		}
+	}
+
+	err := cmd.Wait()
+	close(exited)
+
+	t.mu.Lock()
+	closed, onError := t.closed, t.onError
+	t.mu.Unlock()
+	if closed || onError == nil {
+		return
+	}
+	if err == nil {
+		err = errors.New("server command exited")
+	} else {
+		err = fmt.Errorf("server command exited: %w", err)
+	}
+	onError(err)
+}
+
+// Send writes message to the server's stdin.
+func (t *CommandTransport) Send(_ context.Context, message []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stdin == nil || t.closed {
+		return errors.New("server command is not running")
+	}
+	data := append(append([]byte(nil), message...), '\n')
+	if _, err := t.stdin.Write(data); err != nil {
+		return fmt.Errorf("failed to write to server command: %w", err)
+	}
+	return nil
+}
+
+func (t *CommandTransport) SetOnMessage(handler func(event string, data []byte)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onMessage = handler
+}
+
+func (t *CommandTransport) SetOnError(handler func(err error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onError = handler
+}
+
+// Close closes the server's stdin, which asks a stdio server to exit, and
+// kills it if it has not exited after commandStopTimeout.
+func (t *CommandTransport) Close() error {
+	t.mu.Lock()
+	if t.closed || t.cmd == nil {
+		t.closed = true
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	cmd, stdin, exited := t.cmd, t.stdin, t.exited
+	t.mu.Unlock()
+
+	_ = stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(commandStopTimeout):
+		slog.Warn("server command did not exit, killing it", "pid", cmd.Process.Pid)
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// SessionID returns "": the stdio transport has no sessions.
+func (t *CommandTransport) SessionID() string {
+	return ""
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandTransportExchangesMessages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	transport := NewCommandTransport([]string{"cat"})
+	received := make(chan string, 1)
+	transport.SetOnMessage(func(event string, data []byte) {
+		received <- event + " " + string(data)
+	})
+	transport.SetOnError(func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	})
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// cat plays a server that sends back whatever it is sent.
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != `message {"jsonrpc":"2.0","id":1,"method":"ping"}` {
+			t.Errorf("received %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the server's message")
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := transport.Send(t.Context(), []byte(`{}`)); err == nil {
+		t.Error("Expected Send to fail after Close")
+	}
+}
+
+func TestCommandTransportReportsExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	transport := NewCommandTransport([]string{"sh", "-c", "exit 3"})
+	errs := make(chan error, 1)
+	transport.SetOnError(func(err error) { errs <- err })
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "server command exited") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the exit to be reported")
+	}
+}
+
+func TestCommandTransportMissingCommand(t *testing.T) {
+	if err := NewCommandTransport(nil).Connect(t.Context()); err == nil {
+		t.Error("Expected an error without a command")
+	}
+	if err := NewCommandTransport([]string{"mcp-remote-go-no-such-command"}).Connect(t.Context()); err == nil {
+		t.Error("Expected an error for a command that does not exist")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
 
 // SSETransport implements the legacy SSE transport (MCP 2024-11-05).
@@ -17,11 +20,16 @@ import (
 type SSETransport struct {
 	serverURL    string
 	client       *http.Client
-	headers      map[string]string
+	headers      HeaderProvider
 	getAuthToken func() string
+	idleTimeout  time.Duration
+	reconnect    backoff.Policy
+	limiter      rateLimiter
+	maxEventSize int
 
 	eventSource     *EventSource
 	commandEndpoint string
+	streamToken     string // bearer token the event stream was opened with
 	mu              sync.Mutex
 
 	onMessage func(event string, data []byte)
@@ -34,16 +42,42 @@ type SSETransportConfig struct {
 	Client       *http.Client
 	Headers      map[string]string
 	GetAuthToken func() string
+
+	// GetHeaders, when set, is called for each request and replaces
+	// Headers.
+	GetHeaders HeaderProvider
+
+	// KeepaliveInterval, when set, reconnects the event stream after
+	// keepaliveMaxMissed intervals without any data, comments included.
+	KeepaliveInterval time.Duration
+
+	// Reconnect spaces the attempts to re-establish a dropped event stream.
+	Reconnect backoff.Policy
+
+	// QueueWhenRateLimited makes a send rejected with 429, or 503 with
+	// Retry-After, wait as asked and try again instead of failing.
+	QueueWhenRateLimited bool
+
+	// MaxEventSize limits the data of one event, in bytes; zero means
+	// DefaultMaxEventSize. A larger event drops the stream.
+	MaxEventSize int
 }
 
 // NewSSETransport creates a new legacy SSE transport.
 func NewSSETransport(cfg SSETransportConfig) *SSETransport {
-	return &SSETransport{
+	t := &SSETransport{
 		serverURL:    cfg.ServerURL,
 		client:       cfg.Client,
-		headers:      cfg.Headers,
+		headers:      headerSource(cfg.Headers, cfg.GetHeaders),
 		getAuthToken: cfg.GetAuthToken,
+		reconnect:    cfg.Reconnect,
+		limiter:      rateLimiter{queue: cfg.QueueWhenRateLimited},
+		maxEventSize: cfg.MaxEventSize,
 	}
+	if cfg.KeepaliveInterval > 0 {
+		t.idleTimeout = keepaliveDeadline(cfg.KeepaliveInterval)
+	}
+	return t
 }
 
 func (t *SSETransport) Connect(ctx context.Context) error {
@@ -52,21 +86,30 @@ func (t *SSETransport) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	for k, v := range t.headers {
-		req.Header.Set(k, v)
-	}
-
-	if t.getAuthToken != nil {
-		if token := t.getAuthToken(); token != "" {
-			req.Header.Set("Authorization", "Bearer "+token)
-		}
-	}
-
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
 
 	t.eventSource = NewEventSource(req, t.client)
+	// The stream may reconnect long after Connect, so pick up the current
+	// (possibly refreshed) headers and token on every attempt.
+	t.eventSource.PrepareRequest = func(req *http.Request) {
+		for k, v := range t.headers() {
+			req.Header.Set(k, v)
+		}
+		if t.getAuthToken != nil {
+			token := t.getAuthToken()
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			t.mu.Lock()
+			t.streamToken = token
+			t.mu.Unlock()
+		}
+	}
+	t.eventSource.IdleTimeout = t.idleTimeout
+	t.eventSource.Backoff = t.reconnect
+	t.eventSource.MaxEventSize = t.maxEventSize
 	t.eventSource.OnMessage = t.handleMessage
 	t.eventSource.OnError = func(err error) {
 		if t.onError != nil {
@@ -81,7 +124,18 @@ func (t *SSETransport) Connect(ctx context.Context) error {
 	return nil
 }
 
+// acceptsBatch reports true: the 2024-11-05 protocol spoken over the SSE
+// transport allows JSON-RPC batches.
+func (t *SSETransport) acceptsBatch() bool {
+	return true
+}
+
 func (t *SSETransport) Send(ctx context.Context, message []byte) error {
+	return t.limiter.send(ctx, func() error { return t.post(ctx, message) })
+}
+
+// post sends message to the command endpoint.
+func (t *SSETransport) post(ctx context.Context, message []byte) error {
 	t.mu.Lock()
 	endpoint := t.commandEndpoint
 	t.mu.Unlock()
@@ -98,7 +152,7 @@ func (t *SSETransport) Send(ctx context.Context, message []byte) error {
 		return fmt.Errorf("failed to create POST request: %w", err)
 	}
 
-	for k, v := range t.headers {
+	for k, v := range t.headers() {
 		req.Header.Set(k, v)
 	}
 
@@ -120,16 +174,18 @@ func (t *SSETransport) Send(ctx context.Context, message []byte) error {
 	if resp.StatusCode == http.StatusUnauthorized {
 		return unauthorizedFromResponse(resp)
 	}
+	if limited := httpclient.RateLimitFromResponse(resp); limited != nil {
+		return limited
+	}
 
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, string(body))
+		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, readErrorBody(resp.Body))
 	}
 
 	return nil
@@ -150,6 +206,22 @@ func (t *SSETransport) Close() error {
 	return nil
 }
 
+// reloadAuthToken reopens the event stream when the access token has
+// changed since it was opened. The server may start a new session on it,
+// announced with a new endpoint event.
+func (t *SSETransport) reloadAuthToken() {
+	if t.getAuthToken == nil || t.eventSource == nil {
+		return
+	}
+	token := t.getAuthToken()
+	t.mu.Lock()
+	stale := token != "" && t.streamToken != token
+	t.mu.Unlock()
+	if stale && t.eventSource.Reopen() {
+		slog.Info("access token changed, reopening SSE stream")
+	}
+}
+
 func (t *SSETransport) SessionID() string {
 	return "" // Legacy SSE has no session concept
 }
@@ -158,7 +230,7 @@ func (t *SSETransport) SessionID() string {
 func (t *SSETransport) handleMessage(event string, data []byte) {
 	if event == "endpoint" {
 		endpoint := string(data)
-		log.Printf("Received command endpoint: %s", endpoint)
+		slog.Debug("received command endpoint", "endpoint", endpoint)
 		t.setCommandEndpoint(endpoint)
 		return
 	}
@@ -192,7 +264,7 @@ func (t *SSETransport) getCommandURL() string {
 		// Extract base URL (scheme + host)
 		baseURL, err := url.Parse(t.serverURL)
 		if err != nil {
-			log.Printf("Failed to parse server URL: %v, using direct concatenation", err)
+			slog.Warn("failed to parse server URL, using direct concatenation", "error", err)
 			return t.serverURL + commandURL
 		}
 		baseURL.Path = ""
@@ -189,3 +189,30 @@ func TestSSETransportAuthToken(t *testing.T) {
 		t.Errorf("Expected 'Bearer test-token', got '%s'", receivedAuth)
 	}
 }
+
+func TestSSETransportHeaderProvider(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Token"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	token := "one"
+	transport := NewSSETransport(SSETransportConfig{
+		ServerURL:  server.URL,
+		Client:     &http.Client{},
+		Headers:    map[string]string{"X-Token": "static"},
+		GetHeaders: func() map[string]string { return map[string]string{"X-Token": token} },
+	})
+	transport.setCommandEndpoint(server.URL)
+
+	for _, token = range []string{"one", "two"} {
+		if err := transport.Send(t.Context(), []byte(`{}`)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if len(received) != 2 || received[0] != "one" || received[1] != "two" {
+		t.Errorf("Expected the provider evaluated per request, got %q", received)
+	}
+}
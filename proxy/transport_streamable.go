@@ -3,21 +3,33 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
 )
 
 // errNotificationStreamNotSupported indicates the server does not support GET notification streams.
 var errNotificationStreamNotSupported = errors.New("server does not support GET notification stream")
 
+// errSessionExpired indicates the server answered 404 to a request carrying a
+// session ID, meaning the session was terminated (MCP 2025-11-25).
+var errSessionExpired = errors.New("session expired")
+
+// sessionSaveDelay is how long a new last event ID waits before it is
+// persisted, so that a burst of events costs one write.
+const sessionSaveDelay = time.Second
+
 const (
-	// MCPProtocolVersion is the protocol version for Streamable HTTP transport.
+	// MCPProtocolVersion is the newest protocol version the proxy supports.
+	// It is offered to the server unless WithProtocolVersion overrides it.
 	MCPProtocolVersion = "2025-11-25"
 
 	// HeaderMCPSessionID is the session ID header name.
@@ -32,15 +44,76 @@ const (
 type StreamableHTTPTransport struct {
 	endpoint     string
 	client       *http.Client
-	headers      map[string]string
+	headers      HeaderProvider
 	getAuthToken func() string
 
+	// protocolVersion returns the Mcp-Protocol-Version header value, or ""
+	// to leave the header out.
+	protocolVersion func() string
+
+	// reauthenticate, when set, obtains a new token after a request was
+	// rejected with an auth challenge; the request is then retried once.
+	reauthenticate func(ctx context.Context, rejectedToken string, err *UnauthorizedError) error
+
 	sessionID   string
 	lastEventID string
 
 	onMessage func(event string, data []byte)
 	onError   func(err error)
 
+	// sessions, when set, persists the session ID and last event ID so the
+	// session survives a restart; Close then keeps the session open.
+	// saveTimer is the pending write of a new last event ID. saveMu
+	// serializes writes, which are done without holding mu.
+	sessions  *sessionStore
+	saveTimer *time.Timer
+	saveMu    sync.Mutex
+
+	// keepaliveInterval, when set, is how often the server is pinged.
+	// pingID is the outstanding ping, "" once it was answered.
+	keepaliveInterval time.Duration
+	pingID            string
+
+	// reconnect spaces the attempts to reopen the notification stream.
+	reconnect backoff.Policy
+
+	// initParams and initialized record the client's initialize handshake,
+	// replayed by reinitialize when the server expires the session.
+	// reinitID is the replayed initialize awaiting its result on
+	// reinitResult; reinits numbers the replays.
+	initParams   json.RawMessage
+	initialized  bool
+	reinitMu     sync.Mutex
+	reinitID     string
+	reinitResult chan *rpcMessage
+	reinits      int
+
+	// serverRequests maps the IDs of requests the server sent, such as
+	// sampling/createMessage, to the session they arrived on, until the
+	// client answers them.
+	serverRequests map[string]string
+
+	// limiter holds back sends while the server is rate limiting us.
+	limiter rateLimiter
+
+	// maxEventSize and maxBodySize limit what is read from the server.
+	maxEventSize int
+	maxBodySize  int
+
+	// notifyCtx is the context of the notification stream, which
+	// streamRunning reports as open or reconnecting. streamUnsupported is
+	// set once the server refused it with 405. awaiting holds the IDs of
+	// requests accepted with 202, whose responses come on that stream.
+	notifyCtx         context.Context
+	streamRunning     bool
+	streamUnsupported bool
+	awaiting          map[string]bool
+
+	// streamToken is the bearer token the open notification stream was
+	// opened with; streamCancel closes it so that it is opened again.
+	streamToken  string
+	streamCancel context.CancelFunc
+
 	notifyCancel context.CancelFunc
 	mu           sync.Mutex
 }
@@ -51,16 +124,68 @@ type StreamableHTTPTransportConfig struct {
 	Client       *http.Client
 	Headers      map[string]string
 	GetAuthToken func() string
+
+	// GetHeaders, when set, is called for each request and replaces
+	// Headers.
+	GetHeaders HeaderProvider
+
+	// ProtocolVersion returns the Mcp-Protocol-Version header value, or ""
+	// to leave the header out. When nil, MCPProtocolVersion is sent.
+	ProtocolVersion func() string
+
+	// Reauthenticate, when set, is called when a POST is rejected with 401
+	// (or 403 with a Bearer challenge). rejectedToken is the token the
+	// request carried. On success the POST is retried once.
+	Reauthenticate func(ctx context.Context, rejectedToken string, err *UnauthorizedError) error
+
+	// KeepaliveInterval, when set, sends an MCP ping that often. After
+	// keepaliveMaxMissed pings in a row fail or go unanswered, the
+	// connection is reported dead through the error handler.
+	KeepaliveInterval time.Duration
+
+	// Reconnect spaces the attempts to reopen the notification stream.
+	Reconnect backoff.Policy
+
+	// QueueWhenRateLimited makes a send rejected with 429, or 503 with
+	// Retry-After, wait as asked and try again instead of failing.
+	QueueWhenRateLimited bool
+
+	// MaxEventSize limits the data of one SSE event and MaxBodySize a JSON
+	// response body, in bytes; zero means DefaultMaxEventSize and
+	// DefaultMaxBodySize. A larger event drops the stream it arrived on; a
+	// larger body fails the request.
+	MaxEventSize int
+	MaxBodySize  int
+
+	// sessions enables session resumption; set by the proxy.
+	sessions *sessionStore
 }
 
 // NewStreamableHTTPTransport creates a new Streamable HTTP transport.
 func NewStreamableHTTPTransport(cfg StreamableHTTPTransportConfig) *StreamableHTTPTransport {
-	return &StreamableHTTPTransport{
+	t := &StreamableHTTPTransport{
 		endpoint:     cfg.Endpoint,
 		client:       cfg.Client,
-		headers:      cfg.Headers,
+		headers:      headerSource(cfg.Headers, cfg.GetHeaders),
 		getAuthToken: cfg.GetAuthToken,
+		sessions:     cfg.sessions,
+
+		protocolVersion:   cfg.ProtocolVersion,
+		reauthenticate:    cfg.Reauthenticate,
+		keepaliveInterval: cfg.KeepaliveInterval,
+		reconnect:         cfg.Reconnect,
+		limiter:           rateLimiter{queue: cfg.QueueWhenRateLimited},
+		maxEventSize:      orDefault(cfg.MaxEventSize, DefaultMaxEventSize),
+		maxBodySize:       orDefault(cfg.MaxBodySize, DefaultMaxBodySize),
 	}
+	if t.sessions != nil {
+		if state := t.sessions.load(t.endpoint); state != nil {
+			slog.Info("resuming streamable HTTP session", "session_id", state.SessionID)
+			t.sessionID = state.SessionID
+			t.lastEventID = state.LastEventID
+		}
+	}
+	return t
 }
 
 func (t *StreamableHTTPTransport) Connect(ctx context.Context) error {
@@ -70,7 +195,121 @@ func (t *StreamableHTTPTransport) Connect(ctx context.Context) error {
 	return nil
 }
 
+// keepalive pings the server every interval so a dead connection is noticed
+// even while the client is idle. A ping that fails, or is still unanswered
+// at the next tick, is missed; after keepaliveMaxMissed misses in a row the
+// connection is reported dead through onError.
+func (t *StreamableHTTPTransport) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(t.keepaliveInterval)
+	defer ticker.Stop()
+
+	missed := 0
+	for n := 1; ; n++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		id := fmt.Sprintf("%s%d", keepaliveIDPrefix, n)
+		t.mu.Lock()
+		unanswered := t.pingID != ""
+		t.pingID = id
+		t.mu.Unlock()
+
+		err := t.Send(ctx, keepalivePing(id))
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err != nil:
+			missed++
+			slog.Warn("keepalive ping failed", "missed", missed, "error", err)
+		case unanswered:
+			missed++
+			slog.Warn("keepalive ping unanswered", "missed", missed)
+		default:
+			missed = 0
+		}
+
+		if missed >= keepaliveMaxMissed {
+			if t.onError != nil {
+				t.onError(fmt.Errorf("%w: %d keepalive pings missed", errConnectionDead, missed))
+			}
+			return
+		}
+	}
+}
+
+// dispatch passes a message from the server to the message handler, except
+// the answer to a keepalive ping or to a replayed initialize.
+func (t *StreamableHTTPTransport) dispatch(event string, data []byte) {
+	if t.reinitResponse(data) {
+		return
+	}
+	t.streamResponseReceived(data)
+	t.serverRequestsReceived(data)
+	if id := keepaliveResponseID(data); id != "" {
+		t.mu.Lock()
+		if t.pingID == id {
+			t.pingID = ""
+		}
+		t.mu.Unlock()
+		return
+	}
+	if t.onMessage != nil {
+		t.onMessage(event, data)
+	}
+}
+
 func (t *StreamableHTTPTransport) Send(ctx context.Context, message []byte) error {
+	return t.limiter.send(ctx, func() error { return t.post(ctx, message) })
+}
+
+// post sends message, retrying once on an expired session or after
+// re-authenticating.
+func (t *StreamableHTTPTransport) post(ctx context.Context, message []byte) error {
+	message, answers := t.answerServerRequests(message)
+	if message == nil {
+		return nil
+	}
+	t.mu.Lock()
+	sentSessionID := t.sessionID
+	t.mu.Unlock()
+	sentToken := t.authToken()
+
+	initialize := t.recordHandshake(message)
+	err := t.send(ctx, message)
+	if errors.Is(err, errSessionExpired) && sentSessionID != "" && answers {
+		// The requests they answer ended with the session.
+		slog.Warn("dropping responses to requests from an expired session", "session_id", sentSessionID)
+		return nil
+	}
+	if errors.Is(err, errSessionExpired) && sentSessionID != "" {
+		// The session is gone (e.g. it expired, or it was resumed after
+		// the server restarted). Start a new one by replaying the
+		// client's initialize, then retry once on it.
+		slog.Info("session expired, starting a new session", "session_id", sentSessionID)
+		if !initialize {
+			if reinitErr := t.reinitialize(ctx); reinitErr != nil {
+				return fmt.Errorf("%w: %w", err, reinitErr)
+			}
+		}
+		err = t.send(ctx, message)
+	}
+
+	var unauth *UnauthorizedError
+	if errors.As(err, &unauth) && t.reauthenticate != nil {
+		slog.Info("request rejected by server, re-authenticating", "status", unauth.StatusCode)
+		if authErr := t.reauthenticate(ctx, sentToken, unauth); authErr != nil {
+			return fmt.Errorf("%w; re-authentication failed: %w", err, authErr)
+		}
+		return t.send(ctx, message)
+	}
+	return err
+}
+
+func (t *StreamableHTTPTransport) send(ctx context.Context, message []byte) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(message))
 	if err != nil {
 		return fmt.Errorf("failed to create POST request: %w", err)
@@ -85,64 +324,72 @@ func (t *StreamableHTTPTransport) Send(ctx context.Context, message []byte) erro
 		return fmt.Errorf("POST request failed: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound && req.Header.Get(HeaderMCPSessionID) != "" {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+		t.expireSession()
+		return errSessionExpired
+	}
+
 	// Extract session ID from response
 	if sid := resp.Header.Get(HeaderMCPSessionID); sid != "" {
-		t.mu.Lock()
-		t.sessionID = sid
-		t.mu.Unlock()
+		t.setSessionID(sid)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 
-	if resp.StatusCode == http.StatusUnauthorized {
+	if isAuthChallenge(resp) {
 		return unauthorizedFromResponse(resp)
 	}
+	if limited := httpclient.RateLimitFromResponse(resp); limited != nil {
+		return limited
+	}
 
 	switch {
 	case resp.StatusCode == http.StatusAccepted:
 		// Server accepted but will send response via notification stream
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
+		t.awaitStreamResponses(message)
 		return nil
 
 	case strings.HasPrefix(contentType, "text/event-stream"):
 		// SSE stream response - read events in background
-		go t.readSSEResponse(ctx, resp)
+		go t.readSSEResponse(ctx, resp, message)
 		return nil
 
 	case strings.HasPrefix(contentType, "application/json"):
 		defer func() {
 			if err := resp.Body.Close(); err != nil {
-				log.Printf("Warning: failed to close response body: %v", err)
+				slog.Warn("failed to close response body", "error", err)
 			}
 		}()
 
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, string(body))
+			return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, readErrorBody(resp.Body))
 		}
 
-		body, err := io.ReadAll(resp.Body)
+		body, err := readResponseBody(resp.Body, t.maxBodySize)
 		if err != nil {
 			return fmt.Errorf("failed to read response body: %w", err)
 		}
 
-		if t.onMessage != nil && len(body) > 0 {
-			t.onMessage("message", body)
+		if len(body) > 0 {
+			t.dispatch("message", body)
 		}
 		return nil
 
 	default:
 		defer func() {
 			if err := resp.Body.Close(); err != nil {
-				log.Printf("Warning: failed to close response body: %v", err)
+				slog.Warn("failed to close response body", "error", err)
 			}
 		}()
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, string(body))
+			return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, readErrorBody(resp.Body))
 		}
 		return nil
 	}
@@ -162,12 +409,15 @@ func (t *StreamableHTTPTransport) Close() error {
 		t.notifyCancel()
 	}
 
-	// Send DELETE to terminate the session
+	// Send DELETE to terminate the session, unless it is kept for resumption
 	t.mu.Lock()
 	sid := t.sessionID
 	t.mu.Unlock()
+	if t.sessions != nil {
+		t.saveSession()
+	}
 
-	if sid != "" {
+	if sid != "" && t.sessions == nil {
 		req, err := http.NewRequest(http.MethodDelete, t.endpoint, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create DELETE request: %w", err)
@@ -176,11 +426,11 @@ func (t *StreamableHTTPTransport) Close() error {
 
 		resp, err := t.client.Do(req)
 		if err != nil {
-			log.Printf("Warning: failed to send session termination: %v", err)
+			slog.Warn("failed to send session termination", "error", err)
 			return nil
 		}
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}
 
@@ -193,19 +443,95 @@ func (t *StreamableHTTPTransport) SessionID() string {
 	return t.sessionID
 }
 
+// setSessionID records the session ID assigned by the server.
+func (t *StreamableHTTPTransport) setSessionID(sid string) {
+	t.mu.Lock()
+	if t.sessionID == sid {
+		t.mu.Unlock()
+		return
+	}
+	t.sessionID = sid
+	t.lastEventID = ""
+	t.mu.Unlock()
+	if t.sessions != nil {
+		t.saveSession()
+	}
+}
+
+// setLastEventID records the ID of the last SSE event received. With
+// session resumption, it is persisted after sessionSaveDelay.
+func (t *StreamableHTTPTransport) setLastEventID(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastEventID = id
+	if t.sessions != nil && t.saveTimer == nil {
+		t.saveTimer = time.AfterFunc(sessionSaveDelay, t.saveSession)
+	}
+}
+
+// expireSession forgets a session the server no longer recognizes.
+func (t *StreamableHTTPTransport) expireSession() {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	t.mu.Lock()
+	t.sessionID = ""
+	t.lastEventID = ""
+	t.stopSaveTimerLocked()
+	t.mu.Unlock()
+	if t.sessions != nil {
+		t.sessions.clear()
+	}
+}
+
+// saveSession persists the session when resumption is enabled, writing
+// any pending last event ID.
+func (t *StreamableHTTPTransport) saveSession() {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	t.mu.Lock()
+	t.stopSaveTimerLocked()
+	state := sessionState{Endpoint: t.endpoint, SessionID: t.sessionID, LastEventID: t.lastEventID}
+	t.mu.Unlock()
+	if t.sessions == nil || state.SessionID == "" {
+		return
+	}
+	t.sessions.save(state)
+}
+
+// stopSaveTimerLocked cancels the pending write of the last event ID.
+// t.mu must be held.
+func (t *StreamableHTTPTransport) stopSaveTimerLocked() {
+	if t.saveTimer != nil {
+		t.saveTimer.Stop()
+		t.saveTimer = nil
+	}
+}
+
+// authToken returns the current bearer token, or "" without one.
+func (t *StreamableHTTPTransport) authToken() string {
+	if t.getAuthToken == nil {
+		return ""
+	}
+	return t.getAuthToken()
+}
+
 // setCommonHeaders sets headers common to all requests.
 func (t *StreamableHTTPTransport) setCommonHeaders(req *http.Request) {
-	for k, v := range t.headers {
+	for k, v := range t.headers() {
 		req.Header.Set(k, v)
 	}
 
-	if t.getAuthToken != nil {
-		if token := t.getAuthToken(); token != "" {
-			req.Header.Set("Authorization", "Bearer "+token)
-		}
+	if token := t.authToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
-	req.Header.Set(HeaderMCPProtocolVersion, MCPProtocolVersion)
+	version := MCPProtocolVersion
+	if t.protocolVersion != nil {
+		version = t.protocolVersion()
+	}
+	if version != "" {
+		req.Header.Set(HeaderMCPProtocolVersion, version)
+	}
 
 	t.mu.Lock()
 	if t.sessionID != "" {
@@ -214,50 +540,113 @@ func (t *StreamableHTTPTransport) setCommonHeaders(req *http.Request) {
 	t.mu.Unlock()
 }
 
-// startNotificationStream opens a GET SSE stream for server-initiated notifications.
+// startNotificationStream opens a GET SSE stream for server-initiated
+// notifications and, with a keepalive interval, starts pinging the server.
+// Both stop on Close. A dropped stream is reopened as set by the reconnect
+// policy; once its attempts are used up, notifications only arrive in POST
+// responses, until a request accepted with 202 needs the stream again.
 func (t *StreamableHTTPTransport) startNotificationStream(ctx context.Context) {
 	notifyCtx, cancel := context.WithCancel(ctx)
 	t.notifyCancel = cancel
+	if t.keepaliveInterval > 0 {
+		go t.keepalive(notifyCtx)
+	}
 
-	go func() {
-		for {
-			select {
-			case <-notifyCtx.Done():
+	t.mu.Lock()
+	t.notifyCtx = notifyCtx
+	t.streamRunning = true
+	t.mu.Unlock()
+	go t.runNotificationStream(notifyCtx)
+}
+
+// runNotificationStream keeps the GET SSE stream open until ctx is done or
+// the reconnect attempts are used up.
+func (t *StreamableHTTPTransport) runNotificationStream(ctx context.Context) {
+	defer func() {
+		t.mu.Lock()
+		t.streamCancel = nil
+		t.mu.Unlock()
+	}()
+	b := t.reconnect.New()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		t.mu.Lock()
+		t.streamCancel = cancel
+		t.mu.Unlock()
+		err := t.openNotificationStream(streamCtx, b.Reset)
+		reopen := streamCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if reopen {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
 				return
-			default:
 			}
-
-			if err := t.openNotificationStream(notifyCtx); err != nil {
-				if notifyCtx.Err() != nil {
-					return
-				}
-				if errors.Is(err, errNotificationStreamNotSupported) {
-					return
-				}
-				var unauth *UnauthorizedError
-				if errors.As(err, &unauth) {
-					if t.onError != nil {
-						t.onError(unauth)
-					}
-					return
+			if errors.Is(err, errNotificationStreamNotSupported) {
+				t.notificationStreamStopped(true, err)
+				return
+			}
+			var unauth *UnauthorizedError
+			if errors.As(err, &unauth) {
+				t.notificationStreamStopped(false, err)
+				if t.onError != nil {
+					t.onError(unauth)
 				}
-				log.Printf("Notification stream error: %v, reconnecting...", err)
-				select {
-				case <-notifyCtx.Done():
-					return
-				case <-time.After(3 * time.Second):
+				return
+			}
+			if errors.Is(err, errSessionExpired) {
+				slog.Info("session expired, starting a new session")
+				if reinitErr := t.reinitialize(ctx); reinitErr != nil {
+					slog.Warn("failed to start a new session", "error", reinitErr)
 				}
 			}
+			delay, ok := b.Next()
+			if !ok {
+				slog.Error("notification stream failed, giving up", "attempts", b.Attempt(), "error", err)
+				t.notificationStreamStopped(false, err)
+				return
+			}
+			slog.Warn("notification stream error, reconnecting", "attempt", b.Attempt(), "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay(delay, err)):
+			}
 		}
-	}()
+	}
 }
 
-func (t *StreamableHTTPTransport) openNotificationStream(ctx context.Context) error {
+// reloadAuthToken reopens the notification stream when the access token
+// has changed since it was opened. Missed events are replayed from
+// Last-Event-ID.
+func (t *StreamableHTTPTransport) reloadAuthToken() {
+	token := t.authToken()
+	t.mu.Lock()
+	cancel := t.streamCancel
+	stale := token != "" && cancel != nil && t.streamToken != token
+	t.mu.Unlock()
+	if stale {
+		slog.Info("access token changed, reopening notification stream")
+		cancel()
+	}
+}
+
+// openNotificationStream reads the GET SSE stream until it ends, calling
+// opened once the server accepted it.
+func (t *StreamableHTTPTransport) openNotificationStream(ctx context.Context, opened func()) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create GET request: %w", err)
 	}
 
+	token := t.authToken()
 	t.setCommonHeaders(req)
 	req.Header.Set("Accept", "text/event-stream")
 
@@ -265,6 +654,7 @@ func (t *StreamableHTTPTransport) openNotificationStream(ctx context.Context) er
 	if t.lastEventID != "" {
 		req.Header.Set("Last-Event-ID", t.lastEventID)
 	}
+	t.streamToken = token
 	t.mu.Unlock()
 
 	resp, err := t.client.Do(req)
@@ -275,9 +665,9 @@ func (t *StreamableHTTPTransport) openNotificationStream(ctx context.Context) er
 	if resp.StatusCode == http.StatusMethodNotAllowed {
 		// Server does not support GET notification stream; stop trying
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
-		log.Println("Server does not support GET notification stream (405), notifications will arrive via POST responses")
+		slog.Info("server does not support GET notification stream, notifications will arrive via POST responses", "status", http.StatusMethodNotAllowed)
 		// Return a sentinel error to stop the reconnection loop
 		return errNotificationStreamNotSupported
 	}
@@ -285,55 +675,77 @@ func (t *StreamableHTTPTransport) openNotificationStream(ctx context.Context) er
 	if resp.StatusCode == http.StatusUnauthorized {
 		return unauthorizedFromResponse(resp)
 	}
+	if limited := httpclient.RateLimitFromResponse(resp); limited != nil {
+		return limited
+	}
+
+	if resp.StatusCode == http.StatusNotFound && req.Header.Get(HeaderMCPSessionID) != "" {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+		t.expireSession()
+		return errSessionExpired
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		body := readErrorBody(resp.Body)
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
-		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, string(body))
+		return fmt.Errorf("server returned error status: %d - %s", resp.StatusCode, body)
 	}
 
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}()
+	opened()
 
-	return ReadSSEEvents(ctx, resp.Body, func(evt SSEEvent) {
+	return readSSEEvents(ctx, resp.Body, t.maxEventSize, func(evt SSEEvent) {
 		if evt.ID != "" {
-			t.mu.Lock()
-			t.lastEventID = evt.ID
-			t.mu.Unlock()
+			t.setLastEventID(evt.ID)
 		}
 
-		if t.onMessage != nil {
-			t.onMessage(evt.Event, evt.Data)
-		}
+		t.dispatch(evt.Event, evt.Data)
 	})
 }
 
-// readSSEResponse reads SSE events from a POST response body.
-func (t *StreamableHTTPTransport) readSSEResponse(ctx context.Context, resp *http.Response) {
+// readSSEResponse reads SSE events from the body of the POST that sent
+// message. When the stream cannot be read to the end, e.g. because an event
+// is too large, only this response is given up: the requests in message
+// not answered yet are answered with an error.
+func (t *StreamableHTTPTransport) readSSEResponse(ctx context.Context, resp *http.Response, message []byte) {
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
+			slog.Warn("failed to close response body", "error", err)
 		}
 	}()
 
-	err := ReadSSEEvents(ctx, resp.Body, func(evt SSEEvent) {
+	answered := make(map[string]bool)
+	err := readSSEEvents(ctx, resp.Body, t.maxEventSize, func(evt SSEEvent) {
 		if evt.ID != "" {
-			t.mu.Lock()
-			t.lastEventID = evt.ID
-			t.mu.Unlock()
+			t.setLastEventID(evt.ID)
 		}
-
-		if t.onMessage != nil {
-			t.onMessage(evt.Event, evt.Data)
+		for _, id := range responseIDs(evt.Data) {
+			answered[string(id)] = true
 		}
+
+		t.dispatch(evt.Event, evt.Data)
 	})
 
-	if err != nil && t.onError != nil {
-		t.onError(err)
+	// A cancelled context means the request was answered, cancelled by
+	// the client or timed out, not that the connection failed.
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	slog.Warn("failed to read response stream", "error", err)
+	if t.onMessage == nil {
+		return
+	}
+	for _, id := range requestIDs(message) {
+		if !answered[string(id)] {
+			t.onMessage("message", newErrorMessage(id, jsonRPCInternalError, fmt.Sprintf("failed to read response from server: %v", err)))
+		}
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -611,3 +612,136 @@ func TestStreamableHTTPTransportE2E(t *testing.T) {
 		t.Fatalf("Close failed: %v", err)
 	}
 }
+
+func TestStreamableHTTPTransportResumeSession(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var mu sync.Mutex
+	var sessionIDsReceived []string
+	deleteCalled := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodDelete {
+			deleteCalled = true
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		sessionIDsReceived = append(sessionIDsReceived, r.Header.Get(HeaderMCPSessionID))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(HeaderMCPSessionID, "session-resume")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer server.Close()
+
+	newTransport := func() *StreamableHTTPTransport {
+		transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+			Endpoint: server.URL,
+			Client:   &http.Client{},
+			sessions: newSessionStore("resume-test"),
+		})
+		transport.SetOnMessage(func(event string, data []byte) {})
+		return transport
+	}
+
+	first := newTransport()
+	if err := first.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A new transport, as after a restart, resumes the saved session.
+	second := newTransport()
+	if second.SessionID() != "session-resume" {
+		t.Errorf("Expected resumed session ID 'session-resume', got '%s'", second.SessionID())
+	}
+	if err := second.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":2}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if deleteCalled {
+		t.Error("Expected no DELETE when the session is kept for resumption")
+	}
+	if len(sessionIDsReceived) != 2 || sessionIDsReceived[1] != "session-resume" {
+		t.Errorf("Expected second request to carry 'session-resume', got %v", sessionIDsReceived)
+	}
+}
+
+func TestStreamableHTTPTransportSavesLastEventIDLazily(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	sessions := newSessionStore("last-event-test")
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint: "http://127.0.0.1:1/mcp",
+		Client:   &http.Client{},
+		sessions: sessions,
+	})
+	transport.setSessionID("session-1")
+	for i := 1; i <= 100; i++ {
+		transport.setLastEventID(strconv.Itoa(i))
+	}
+
+	// Events are not written one by one.
+	if state := sessions.load("http://127.0.0.1:1/mcp"); state == nil || state.LastEventID != "" {
+		t.Errorf("Expected the session saved without the last event ID yet, got %+v", state)
+	}
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if state := sessions.load("http://127.0.0.1:1/mcp"); state == nil || state.LastEventID != "100" {
+		t.Errorf("Expected Close to save the last event ID, got %+v", state)
+	}
+}
+
+func TestStreamableHTTPTransportResumeExpiredSession(t *testing.T) {
+	t.Setenv("MCP_REMOTE_CONFIG_DIR", t.TempDir())
+
+	var mu sync.Mutex
+	var sessionIDsReceived []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sessionIDsReceived = append(sessionIDsReceived, r.Header.Get(HeaderMCPSessionID))
+		mu.Unlock()
+
+		if r.Header.Get(HeaderMCPSessionID) == "session-stale" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(HeaderMCPSessionID, "session-new")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer server.Close()
+
+	sessions := newSessionStore("expired-test")
+	sessions.save(sessionState{Endpoint: server.URL, SessionID: "session-stale", LastEventID: "42"})
+
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint: server.URL,
+		Client:   &http.Client{},
+		sessions: sessions,
+	})
+	transport.SetOnMessage(func(event string, data []byte) {})
+
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","id":1}`)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sessionIDsReceived) != 2 || sessionIDsReceived[0] != "session-stale" || sessionIDsReceived[1] != "" {
+		t.Errorf("Expected a retry without the stale session, got %v", sessionIDsReceived)
+	}
+	if transport.SessionID() != "session-new" {
+		t.Errorf("Expected session ID 'session-new', got '%s'", transport.SessionID())
+	}
+	if state := sessions.load(server.URL); state == nil || state.SessionID != "session-new" || state.LastEventID != "" {
+		t.Errorf("Expected saved session 'session-new' without last event ID, got %+v", state)
+	}
+}
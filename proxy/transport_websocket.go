@@ -0,0 +1,387 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/naotama2002/mcp-remote-go/internal/backoff"
+	"github.com/naotama2002/mcp-remote-go/internal/httpclient"
+)
+
+const (
+	// WebSocketSubprotocol is the subprotocol negotiated for MCP over WebSocket.
+	WebSocketSubprotocol = "mcp"
+)
+
+// WebSocketTransport implements MCP over a single WebSocket connection.
+// Each JSON-RPC message is carried in one text frame in either direction.
+type WebSocketTransport struct {
+	endpoint     string
+	dialer       *websocket.Dialer
+	headers      HeaderProvider
+	getAuthToken func() string
+
+	// keepaliveInterval, when set, is how often a ping frame is sent.
+	keepaliveInterval time.Duration
+
+	// reconnectPolicy spaces the attempts to re-establish a dropped
+	// connection.
+	reconnectPolicy backoff.Policy
+
+	// maxMessageSize limits the messages read from the server.
+	maxMessageSize int
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	closed  bool
+	cancel  context.CancelFunc
+
+	onMessage func(event string, data []byte)
+	onError   func(err error)
+}
+
+// WebSocketTransportConfig holds configuration for creating a WebSocketTransport.
+type WebSocketTransportConfig struct {
+	Endpoint     string
+	Client       *http.Client
+	Headers      map[string]string
+	GetAuthToken func() string
+
+	// GetHeaders, when set, is called for each request and replaces
+	// Headers.
+	GetHeaders HeaderProvider
+
+	// KeepaliveInterval, when set, sends a ping frame that often and
+	// reconnects after keepaliveMaxMissed intervals without any frame or
+	// pong from the server.
+	KeepaliveInterval time.Duration
+
+	// Reconnect spaces the attempts to re-establish a dropped connection.
+	Reconnect backoff.Policy
+
+	// MaxMessageSize limits a message from the server, in bytes; zero
+	// means DefaultMaxBodySize. A larger message drops the connection.
+	MaxMessageSize int
+}
+
+// NewWebSocketTransport creates a new WebSocket transport. Proxy and TLS
+// settings are taken from the client's *http.Transport when available.
+func NewWebSocketTransport(cfg WebSocketTransportConfig) *WebSocketTransport {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+		Subprotocols:     []string{WebSocketSubprotocol},
+	}
+	if cfg.Client != nil {
+		rt := cfg.Client.Transport
+		if ct, ok := rt.(*httpclient.CompressionTransport); ok {
+			rt = ct.Base
+		}
+		if ht, ok := rt.(*http.Transport); ok {
+			dialer.Proxy = ht.Proxy
+			dialer.TLSClientConfig = ht.TLSClientConfig
+		}
+	}
+
+	return &WebSocketTransport{
+		endpoint:     cfg.Endpoint,
+		dialer:       dialer,
+		headers:      headerSource(cfg.Headers, cfg.GetHeaders),
+		getAuthToken: cfg.GetAuthToken,
+
+		keepaliveInterval: cfg.KeepaliveInterval,
+		reconnectPolicy:   cfg.Reconnect,
+		maxMessageSize:    orDefault(cfg.MaxMessageSize, DefaultMaxBodySize),
+	}
+}
+
+// webSocketURL converts an http(s) URL to the equivalent ws(s) URL. URLs that
+// already use a WebSocket scheme are returned unchanged.
+func webSocketURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid WebSocket URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported WebSocket URL scheme %q", u.Scheme)
+	}
+	return u.String(), nil
+}
+
+// isWebSocketURL reports whether rawURL uses the ws or wss scheme.
+func isWebSocketURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+	return strings.HasPrefix(lower, "ws://") || strings.HasPrefix(lower, "wss://")
+}
+
+func (t *WebSocketTransport) Connect(ctx context.Context) error {
+	connCtx, cancel := context.WithCancel(ctx)
+
+	conn, err := t.dial(connCtx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	t.mu.Lock()
+	t.conn = conn
+	t.cancel = cancel
+	t.closed = false
+	t.mu.Unlock()
+
+	go t.readLoop(connCtx, conn)
+	if t.keepaliveInterval > 0 {
+		go t.keepalive(connCtx)
+	}
+	return nil
+}
+
+// keepalive sends a ping frame every interval until ctx is done. The read
+// deadline set by extendDeadline detects a server that stopped answering.
+func (t *WebSocketTransport) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(t.keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		t.mu.Lock()
+		conn := t.conn
+		t.mu.Unlock()
+		if conn == nil {
+			continue
+		}
+		t.writeMu.Lock()
+		err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(t.keepaliveInterval))
+		t.writeMu.Unlock()
+		if err != nil {
+			slog.Warn("WebSocket keepalive ping failed", "error", err)
+		}
+	}
+}
+
+// extendDeadline gives the server keepaliveMaxMissed more intervals to send
+// a frame or pong before the read fails and the connection is re-established.
+func (t *WebSocketTransport) extendDeadline(conn *websocket.Conn) {
+	if t.keepaliveInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(keepaliveDeadline(t.keepaliveInterval)))
+	}
+}
+
+// dial performs the WebSocket handshake.
+func (t *WebSocketTransport) dial(ctx context.Context) (*websocket.Conn, error) {
+	wsURL, err := webSocketURL(t.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for k, v := range t.headers() {
+		header.Set(k, v)
+	}
+	if t.getAuthToken != nil {
+		if token := t.getAuthToken(); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	conn, resp, err := t.dialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, unauthorizedFromResponse(resp)
+		}
+		if resp != nil {
+			if limited := httpclient.RateLimitFromResponse(resp); limited != nil {
+				return nil, fmt.Errorf("WebSocket handshake failed: %w", limited)
+			}
+			if closeErr := resp.Body.Close(); closeErr != nil {
+				slog.Warn("failed to close response body", "error", closeErr)
+			}
+			return nil, fmt.Errorf("WebSocket handshake failed with status %d: %w", resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("WebSocket connection failed: %w", err)
+	}
+	conn.SetReadLimit(int64(t.maxMessageSize))
+
+	if proto := conn.Subprotocol(); proto != "" && proto != WebSocketSubprotocol {
+		slog.Warn("server selected unexpected WebSocket subprotocol", "subprotocol", proto)
+	}
+	if t.keepaliveInterval > 0 {
+		t.extendDeadline(conn)
+		conn.SetPongHandler(func(string) error {
+			t.extendDeadline(conn)
+			return nil
+		})
+	}
+
+	return conn, nil
+}
+
+// readLoop reads frames from conn until it fails, then attempts to reconnect.
+func (t *WebSocketTransport) readLoop(ctx context.Context, conn *websocket.Conn) {
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil || t.isClosed() {
+				return
+			}
+			if errors.Is(err, websocket.ErrReadLimit) {
+				err = fmt.Errorf("%w: WebSocket message over %d bytes", ErrMessageTooLarge, t.maxMessageSize)
+			}
+			slog.Warn("WebSocket read error, reconnecting", "error", err)
+			next, err := t.reconnect(ctx)
+			if err != nil {
+				if ctx.Err() == nil && t.onError != nil {
+					t.onError(err)
+				}
+				return
+			}
+			conn = next
+			continue
+		}
+
+		t.extendDeadline(conn)
+
+		if msgType != websocket.TextMessage && msgType != websocket.BinaryMessage {
+			continue
+		}
+		if t.onMessage != nil && len(data) > 0 {
+			t.onMessage("message", data)
+		}
+	}
+}
+
+// reconnect re-establishes the connection with exponential backoff.
+func (t *WebSocketTransport) reconnect(ctx context.Context) (*websocket.Conn, error) {
+	t.mu.Lock()
+	if t.conn != nil {
+		_ = t.conn.Close()
+		t.conn = nil
+	}
+	t.mu.Unlock()
+
+	b := t.reconnectPolicy.New()
+	var lastErr error
+	for {
+		delay, ok := b.Next()
+		if !ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay(delay, lastErr)):
+		}
+
+		conn, err := t.dial(ctx)
+		if err == nil {
+			t.mu.Lock()
+			t.conn = conn
+			t.mu.Unlock()
+			slog.Info("WebSocket reconnected")
+			return conn, nil
+		}
+
+		var unauth *UnauthorizedError
+		if errors.As(err, &unauth) {
+			return nil, unauth
+		}
+
+		lastErr = err
+		slog.Warn("WebSocket reconnect attempt failed", "attempt", b.Attempt(), "max_attempts", t.reconnectPolicy.Attempts(), "error", err)
+	}
+
+	return nil, fmt.Errorf("WebSocket reconnection failed after %d attempts: %w", b.Attempt(), lastErr)
+}
+
+func (t *WebSocketTransport) Send(ctx context.Context, message []byte) error {
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+
+	if conn == nil {
+		return errors.New("not connected to server")
+	}
+
+	// Stdio lines carry a trailing newline that is not part of the frame.
+	message = bytes.TrimSpace(message)
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+		defer func() { _ = conn.SetWriteDeadline(time.Time{}) }()
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		return fmt.Errorf("WebSocket write failed: %w", err)
+	}
+	return nil
+}
+
+func (t *WebSocketTransport) SetOnMessage(handler func(event string, data []byte)) {
+	t.onMessage = handler
+}
+
+func (t *WebSocketTransport) SetOnError(handler func(err error)) {
+	t.onError = handler
+}
+
+func (t *WebSocketTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	conn := t.conn
+	t.conn = nil
+	cancel := t.cancel
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if conn == nil {
+		return nil
+	}
+
+	t.writeMu.Lock()
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+		slog.Warn("failed to send WebSocket close frame", "error", err)
+	}
+	t.writeMu.Unlock()
+
+	return conn.Close()
+}
+
+func (t *WebSocketTransport) SessionID() string {
+	return "" // WebSocket connections carry no MCP session header
+}
+
+func (t *WebSocketTransport) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
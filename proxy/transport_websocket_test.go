@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newWebSocketEchoServer(t *testing.T, onConn func(conn *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{Subprotocols: []string{WebSocketSubprotocol}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		if onConn != nil {
+			onConn(conn)
+			return
+		}
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, data); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestWebSocketURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "https://example.com/mcp", want: "wss://example.com/mcp"},
+		{in: "http://localhost:8080/mcp", want: "ws://localhost:8080/mcp"},
+		{in: "wss://example.com/mcp", want: "wss://example.com/mcp"},
+		{in: "ftp://example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := webSocketURL(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %s", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWebSocketTransportSendReceive(t *testing.T) {
+	server := newWebSocketEchoServer(t, nil)
+	defer server.Close()
+
+	transport := NewWebSocketTransport(WebSocketTransportConfig{
+		Endpoint: server.URL,
+		Client:   &http.Client{},
+	})
+
+	received := make(chan []byte, 1)
+	transport.SetOnMessage(func(event string, data []byte) {
+		if event != "message" {
+			t.Errorf("Expected event 'message', got %q", event)
+		}
+		received <- data
+	})
+
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`+"\n")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != `{"jsonrpc":"2.0","method":"ping","id":1}` {
+			t.Errorf("Unexpected echoed frame: %q", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for echoed message")
+	}
+
+	if transport.SessionID() != "" {
+		t.Errorf("Expected empty session ID, got %q", transport.SessionID())
+	}
+}
+
+func TestWebSocketTransportHeadersAndSubprotocol(t *testing.T) {
+	var gotAuth, gotCustom, gotProto string
+	upgrader := websocket.Upgrader{Subprotocols: []string{WebSocketSubprotocol}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotCustom = r.Header.Get("X-Custom")
+		gotProto = r.Header.Get("Sec-WebSocket-Protocol")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	transport := NewWebSocketTransport(WebSocketTransportConfig{
+		Endpoint:     server.URL,
+		Client:       &http.Client{},
+		Headers:      map[string]string{"X-Custom": "value"},
+		GetAuthToken: func() string { return "ws-token" },
+	})
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	_ = transport.Close()
+
+	if gotAuth != "Bearer ws-token" {
+		t.Errorf("Expected Authorization 'Bearer ws-token', got %q", gotAuth)
+	}
+	if gotCustom != "value" {
+		t.Errorf("Expected X-Custom 'value', got %q", gotCustom)
+	}
+	if gotProto != WebSocketSubprotocol {
+		t.Errorf("Expected subprotocol %q, got %q", WebSocketSubprotocol, gotProto)
+	}
+}
+
+func TestWebSocketTransportUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderWWWAuthenticate, `Bearer resource_metadata="https://example.com/.well-known/oauth-protected-resource"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	transport := NewWebSocketTransport(WebSocketTransportConfig{
+		Endpoint: server.URL,
+		Client:   &http.Client{},
+	})
+
+	err := transport.Connect(t.Context())
+	var unauth *UnauthorizedError
+	if !errors.As(err, &unauth) {
+		t.Fatalf("Expected UnauthorizedError, got %v", err)
+	}
+	if !strings.Contains(unauth.WWWAuthenticate, "resource_metadata") {
+		t.Errorf("Expected WWW-Authenticate to be preserved, got %q", unauth.WWWAuthenticate)
+	}
+}
+
+func TestWebSocketTransportReconnects(t *testing.T) {
+	var connections atomic.Int32
+	server := newWebSocketEchoServer(t, func(conn *websocket.Conn) {
+		if connections.Add(1) == 1 {
+			// Drop the first connection immediately to force a reconnect.
+			return
+		}
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, data); err != nil {
+				return
+			}
+		}
+	})
+	defer server.Close()
+
+	transport := NewWebSocketTransport(WebSocketTransportConfig{
+		Endpoint: server.URL,
+		Client:   &http.Client{},
+	})
+
+	var mu sync.Mutex
+	var received []string
+	transport.SetOnMessage(func(event string, data []byte) {
+		mu.Lock()
+		received = append(received, string(data))
+		mu.Unlock()
+	})
+
+	if err := transport.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for connections.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if connections.Load() < 2 {
+		t.Fatal("Expected transport to reconnect after connection drop")
+	}
+
+	// Allow the reconnected conn to be installed before sending.
+	time.Sleep(100 * time.Millisecond)
+	if err := transport.Send(t.Context(), []byte(`{"jsonrpc":"2.0","method":"ping","id":2}`)); err != nil {
+		t.Fatalf("Send after reconnect failed: %v", err)
+	}
+
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected echoed message after reconnect")
+}
+
+func TestProxyAutoSelectsWebSocketForWSURL(t *testing.T) {
+	server := newWebSocketEchoServer(t, nil)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	proxy, err := NewProxyWithTransport(wsURL, 0, map[string]string{}, "ws-auto-test", TransportModeAuto)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	defer proxy.cancel()
+
+	if err := proxy.connectToServer(); err != nil {
+		t.Fatalf("connectToServer failed: %v", err)
+	}
+	defer func() { _ = proxy.transport.Close() }()
+
+	if proxy.transportMode != TransportModeWebSocket {
+		t.Errorf("Expected websocket transport mode, got %s", proxy.transportMode)
+	}
+	if _, ok := proxy.transport.(*WebSocketTransport); !ok {
+		t.Errorf("Expected *WebSocketTransport, got %T", proxy.transport)
+	}
+}
@@ -233,3 +233,128 @@ func TestSSESendReturnsUnauthorizedError(t *testing.T) {
 		t.Errorf("WWWAuthenticate = %q, want %q", unauth.WWWAuthenticate, wwwAuth)
 	}
 }
+
+// TestStreamableHTTPSendReauthenticatesAndRetries verifies that a POST
+// rejected with 401 triggers re-authentication and is retried once with the
+// new token, so the message is not lost.
+func TestStreamableHTTPSendReauthenticatesAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	token := "old"
+	posts := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		posts++
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	var rejected string
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint: srv.URL,
+		Client:   srv.Client(),
+		GetAuthToken: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return token
+		},
+		Reauthenticate: func(ctx context.Context, rejectedToken string, err *UnauthorizedError) error {
+			mu.Lock()
+			defer mu.Unlock()
+			rejected = rejectedToken
+			token = "new"
+			return nil
+		},
+	})
+	var received []byte
+	transport.SetOnMessage(func(event string, data []byte) { received = data })
+
+	if err := transport.Send(context.Background(), []byte(`{"jsonrpc":"2.0","method":"tools/call","id":1}`)); err != nil {
+		t.Fatalf("expected Send to succeed after re-authentication, got %v", err)
+	}
+	if rejected != "old" {
+		t.Errorf("rejectedToken = %q, want %q", rejected, "old")
+	}
+	if posts != 2 {
+		t.Errorf("posts = %d, want 2", posts)
+	}
+	if !bytes.Contains(received, []byte(`"result"`)) {
+		t.Errorf("expected the response to be delivered, got %s", received)
+	}
+}
+
+// TestStreamableHTTPSendReauthenticationFails verifies that when
+// re-authentication fails the original 401 is surfaced without a retry.
+func TestStreamableHTTPSendReauthenticationFails(t *testing.T) {
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	authErr := errors.New("no refresh token")
+	transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+		Endpoint: srv.URL,
+		Client:   srv.Client(),
+		Reauthenticate: func(context.Context, string, *UnauthorizedError) error {
+			return authErr
+		},
+	})
+
+	err := transport.Send(context.Background(), []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`))
+	var unauth *UnauthorizedError
+	if !errors.As(err, &unauth) || !errors.Is(err, authErr) {
+		t.Errorf("expected the 401 and the re-authentication error, got %v", err)
+	}
+	if posts != 1 {
+		t.Errorf("posts = %d, want 1", posts)
+	}
+}
+
+// TestStreamableHTTPSendForbidden verifies that a 403 triggers
+// re-authentication only when it carries a Bearer challenge.
+func TestStreamableHTTPSendForbidden(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		challenge string
+		wantAuth  bool
+	}{
+		{"insufficient scope", `Bearer error="insufficient_scope", scope="admin"`, true},
+		{"no challenge", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.challenge != "" {
+					w.Header().Set("WWW-Authenticate", tc.challenge)
+				}
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			defer srv.Close()
+
+			called := false
+			transport := NewStreamableHTTPTransport(StreamableHTTPTransportConfig{
+				Endpoint: srv.URL,
+				Client:   srv.Client(),
+				Reauthenticate: func(context.Context, string, *UnauthorizedError) error {
+					called = true
+					return errors.New("declined")
+				},
+			})
+
+			if err := transport.Send(context.Background(), []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`)); err == nil {
+				t.Fatal("expected error from Send, got nil")
+			}
+			if called != tc.wantAuth {
+				t.Errorf("re-authentication called = %v, want %v", called, tc.wantAuth)
+			}
+		})
+	}
+}
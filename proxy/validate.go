@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// errParse marks a message that is not JSON at all, as opposed to JSON that
+// is not a valid JSON-RPC message.
+var errParse = errors.New("parse error")
+
+// validateMessage checks that message is a JSON-RPC 2.0 request,
+// notification or response: a "jsonrpc" member of "2.0", an id that is a
+// string, number or null, and either a method or exactly one of result and
+// error.
+func validateMessage(message []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		if json.Valid(message) {
+			return errors.New("message is not a JSON object")
+		}
+		return errParse
+	}
+
+	var version string
+	if json.Unmarshal(fields["jsonrpc"], &version) != nil || version != "2.0" {
+		return errors.New(`"jsonrpc" must be "2.0"`)
+	}
+
+	id, hasID := fields["id"]
+	if hasID {
+		switch jsonKind(id) {
+		case '"', '0':
+		case 'n':
+			if _, isError := fields["error"]; !isError {
+				return errors.New(`"id" may only be null in an error response`)
+			}
+		default:
+			return errors.New(`"id" must be a string or a number`)
+		}
+	}
+
+	if raw, ok := fields["method"]; ok {
+		var method string
+		if json.Unmarshal(raw, &method) != nil || method == "" {
+			return errors.New(`"method" must be a non-empty string`)
+		}
+		if params, ok := fields["params"]; ok {
+			if kind := jsonKind(params); kind != '{' && kind != '[' {
+				return errors.New(`"params" must be an object or an array`)
+			}
+		}
+		if _, ok := fields["result"]; ok {
+			return errors.New(`a request cannot have a "result"`)
+		}
+		if _, ok := fields["error"]; ok {
+			return errors.New(`a request cannot have an "error"`)
+		}
+		return nil
+	}
+
+	if !hasID {
+		return errors.New(`a message needs a "method" or an "id"`)
+	}
+	_, hasResult := fields["result"]
+	rawError, hasError := fields["error"]
+	switch {
+	case hasResult && hasError:
+		return errors.New(`a response cannot have both "result" and "error"`)
+	case hasResult:
+	case hasError:
+		var e struct {
+			Code    *json.Number `json:"code"`
+			Message *string      `json:"message"`
+		}
+		if json.Unmarshal(rawError, &e) != nil || e.Code == nil || e.Message == nil {
+			return errors.New(`"error" must be an object with a code and a message`)
+		}
+		if _, err := e.Code.Int64(); err != nil {
+			return errors.New(`"error.code" must be an integer`)
+		}
+	default:
+		return errors.New(`a response needs a "result" or an "error"`)
+	}
+	return nil
+}
+
+// jsonKind returns the first byte of a JSON value, with '0' standing for any
+// number and 'n' for null.
+func jsonKind(raw json.RawMessage) byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 0
+	}
+	switch c := raw[0]; {
+	case c == '-' || (c >= '0' && c <= '9'):
+		return '0'
+	default:
+		return c
+	}
+}
+
+// invalidMessageResponse answers a message that failed validateMessage,
+// using its id when that is usable.
+func invalidMessageResponse(message []byte, err error) []byte {
+	if errors.Is(err, errParse) {
+		return newErrorMessage(nil, jsonRPCParseError, "parse error")
+	}
+	return newErrorMessage(usableID(message), jsonRPCInvalidRequest, fmt.Sprintf("invalid request: %v", err))
+}
+
+// usableID returns the id of message if it is a string or a number.
+func usableID(message []byte) json.RawMessage {
+	var msg struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(message, &msg) != nil {
+		return nil
+	}
+	if kind := jsonKind(msg.ID); kind == '"' || kind == '0' {
+		return msg.ID
+	}
+	return nil
+}
+
+// acceptFromServer reports whether a message from the server may be
+// delivered. In strict mode an invalid message is dropped. An invalid
+// response is turned into an error for the request it answers, so the
+// client does not wait for it; anything else is answered to the server.
+func (p *Proxy) acceptFromServer(message []byte) bool {
+	if !p.strict {
+		return true
+	}
+	err := validateMessage(message)
+	if err == nil {
+		return true
+	}
+	slog.Warn("dropping invalid message from server", "error", err)
+
+	var msg struct {
+		Method json.RawMessage `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(message, &msg) == nil && msg.Method == nil && (msg.Result != nil || msg.Error != nil) {
+		if id := usableID(message); id != nil {
+			p.deliver(newErrorMessage(id, jsonRPCInternalError, fmt.Sprintf("invalid response from server: %v", err)))
+		}
+		return false
+	}
+	if p.currentTransport() != nil {
+		_ = p.sendToServer(invalidMessageResponse(message, err))
+	}
+	return false
+}
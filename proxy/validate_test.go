@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateMessage(t *testing.T) {
+	valid := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"x"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"m","params":[1,2]}`,
+		`{"jsonrpc":"2.0","id":-1.5,"result":null}`,
+		`{"jsonrpc":"2.0","id":2,"result":{}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+	}
+	for _, message := range valid {
+		if err := validateMessage([]byte(message)); err != nil {
+			t.Errorf("%s: expected valid, got %v", message, err)
+		}
+	}
+
+	invalid := []string{
+		`{"id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"1.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":true,"method":"m"}`,
+		`{"jsonrpc":"2.0","id":{},"method":"m"}`,
+		`{"jsonrpc":"2.0","id":null,"method":"m"}`,
+		`{"jsonrpc":"2.0","id":1,"method":""}`,
+		`{"jsonrpc":"2.0","id":1,"method":7}`,
+		`{"jsonrpc":"2.0","method":"m","params":"x"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"m","result":{}}`,
+		`{"jsonrpc":"2.0"}`,
+		`{"jsonrpc":"2.0","id":1}`,
+		`{"jsonrpc":"2.0","id":1,"result":{},"error":{"code":1,"message":"x"}}`,
+		`{"jsonrpc":"2.0","id":1,"error":"boom"}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":1.5,"message":"x"}}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"message":"x"}}`,
+		`"text"`,
+	}
+	for _, message := range invalid {
+		if err := validateMessage([]byte(message)); err == nil {
+			t.Errorf("%s: expected invalid", message)
+		}
+	}
+
+	if err := validateMessage([]byte(`{"jsonrpc":`)); !errors.Is(err, errParse) {
+		t.Errorf("Expected errParse for truncated JSON, got %v", err)
+	}
+}
+
+func TestInvalidMessageResponse(t *testing.T) {
+	tests := []struct {
+		message string
+		id      string
+		code    int
+	}{
+		{`{"id":7,"method":"m"}`, "7", jsonRPCInvalidRequest},
+		{`{"jsonrpc":"2.0","id":true,"method":"m"}`, "null", jsonRPCInvalidRequest},
+		{`not json`, "null", jsonRPCParseError},
+	}
+	for _, tt := range tests {
+		var resp rpcMessage
+		reply := invalidMessageResponse([]byte(tt.message), validateMessage([]byte(tt.message)))
+		if err := json.Unmarshal(reply, &resp); err != nil || resp.Error == nil {
+			t.Fatalf("%s: expected an error response, got %s", tt.message, reply)
+		}
+		if string(resp.ID) != tt.id || resp.Error.Code != tt.code {
+			t.Errorf("%s: expected id %s code %d, got %s", tt.message, tt.id, tt.code, reply)
+		}
+	}
+}
+
+func TestStrictProxyRejectsInvalidClientMessage(t *testing.T) {
+	transport := &batchTestTransport{}
+	p, out := newBatchTestProxy(transport)
+	p.strict = true
+
+	if message := p.outgoing([]byte(`{"id":1,"method":"tools/list"}`)); message != nil {
+		t.Errorf("Expected the message not to be forwarded, got %s", message)
+	}
+	if !strings.Contains(out.String(), `"id":1`) || !strings.Contains(out.String(), `"code":-32600`) {
+		t.Errorf("Expected an invalid request error for id 1, got %s", out.String())
+	}
+
+	out.Reset()
+	p.strict = false
+	if message := p.outgoing([]byte(`{"id":1,"method":"tools/list"}`)); message == nil || out.Len() != 0 {
+		t.Errorf("Expected the message forwarded without strict mode, got %s (output %s)", message, out.String())
+	}
+}
+
+func TestStrictProxyDropsInvalidServerMessage(t *testing.T) {
+	transport := &batchTestTransport{}
+	p, out := newBatchTestProxy(transport)
+	p.strict = true
+
+	// A malformed response fails the request it answers.
+	p.handleServerMessage("message", []byte(`{"jsonrpc":"2.0","id":5,"result":{},"error":{"code":1,"message":"x"}}`))
+	var resp rpcMessage
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil || string(resp.ID) != "5" || resp.Error == nil || resp.Error.Code != jsonRPCInternalError {
+		t.Errorf("Expected an internal error for request 5, got %s", out.String())
+	}
+	if len(transport.sent) != 0 {
+		t.Errorf("Expected nothing sent to the server for a bad response, got %q", transport.sent)
+	}
+
+	// A malformed request is answered to the server.
+	out.Reset()
+	p.handleServerMessage("message", []byte(`{"id":"s1","method":"sampling/createMessage"}`))
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing delivered to the client, got %s", out.String())
+	}
+	if len(transport.sent) != 1 || !strings.Contains(transport.sent[0], `"id":"s1"`) || !strings.Contains(transport.sent[0], `-32600`) {
+		t.Errorf("Expected an invalid request error sent to the server, got %q", transport.sent)
+	}
+}
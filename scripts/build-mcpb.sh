@@ -89,7 +89,7 @@ for platform in "${PLATFORMS[@]}"; do
     "transport": {
       "type": "string",
       "title": "Transport Mode",
-      "description": "Transport protocol: auto (recommended), streamable-http, or sse",
+      "description": "Transport protocol: auto (recommended), http-first, sse-first, streamable-http, sse, or websocket",
       "default": "auto"
     },
     "port": {